@@ -0,0 +1,70 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/Ekvo/golang-chi-postgres-api/internal/model"
+	"github.com/Ekvo/golang-chi-postgres-api/internal/servises"
+	"github.com/Ekvo/golang-chi-postgres-api/internal/source"
+)
+
+// runImport - subcommand 'import', same rules and report as 'POST /task/import'
+//
+//	task import [-format csv|ndjson] [-dry-run] <file|->
+func runImport(ctx context.Context, base *source.Dbinstance, args []string) error {
+	flags := flag.NewFlagSet("import", flag.ContinueOnError)
+	format := flags.String("format", "", "csv or ndjson, by default from file extension")
+	dryRun := flags.Bool("dry-run", false, "check all rows and save nothing")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if flags.NArg() != 1 {
+		return errors.New("import: need one file or '-' for stdin")
+	}
+	path := flags.Arg(0)
+	if *format == "" {
+		*format = strings.TrimPrefix(filepath.Ext(path), ".")
+		if *format == "jsonl" {
+			*format = servises.ImportNDJSON
+		}
+	}
+
+	var in io.Reader = os.Stdin
+	if path != "-" {
+		file, err := os.Open(path)
+		if err != nil {
+			return fmt.Errorf("import: open error - %w", err)
+		}
+		defer func() {
+			if err := file.Close(); err != nil {
+				log.Printf("import: file.Close error - %v", err)
+			}
+		}()
+		in = file
+	}
+	reader, err := servises.NewTaskImportReader(in, *format)
+	if err != nil {
+		return fmt.Errorf("import: %w", err)
+	}
+	count, err := base.ImportTasks(ctx, model.TaskImport{Next: reader.Next, DryRun: *dryRun})
+	if err != nil {
+		return fmt.Errorf("import: %w", err)
+	}
+	enc := json.NewEncoder(os.Stdout)
+	enc.SetIndent("", "  ")
+	return enc.Encode(servises.ImportResponse{
+		Imported:      count,
+		DryRun:        *dryRun,
+		RejectedCount: reader.RejectedCount(),
+		Rejected:      reader.Rejected(),
+	})
+}
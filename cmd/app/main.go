@@ -2,14 +2,32 @@ package main
 
 import (
 	"context"
+	"expvar"
 	"log"
+	"net"
+	"os"
+	// time zones of quiet hours of reminders and of recurring tasks, image 'alpine' has no tzdata
+	_ "time/tzdata"
 
 	"github.com/go-chi/chi/v5"
 
+	"github.com/Ekvo/golang-chi-postgres-api/internal/blob"
 	"github.com/Ekvo/golang-chi-postgres-api/internal/config"
+	"github.com/Ekvo/golang-chi-postgres-api/internal/events"
+	"github.com/Ekvo/golang-chi-postgres-api/internal/idempotency"
+	"github.com/Ekvo/golang-chi-postgres-api/internal/jobs"
+	"github.com/Ekvo/golang-chi-postgres-api/internal/model"
+	"github.com/Ekvo/golang-chi-postgres-api/internal/outbox"
+	"github.com/Ekvo/golang-chi-postgres-api/internal/rank"
+	"github.com/Ekvo/golang-chi-postgres-api/internal/ratelimit"
+	"github.com/Ekvo/golang-chi-postgres-api/internal/recurrence"
+	"github.com/Ekvo/golang-chi-postgres-api/internal/reminder"
+	"github.com/Ekvo/golang-chi-postgres-api/internal/rpc"
 	"github.com/Ekvo/golang-chi-postgres-api/internal/server"
+	"github.com/Ekvo/golang-chi-postgres-api/internal/shed"
 	"github.com/Ekvo/golang-chi-postgres-api/internal/source"
 	"github.com/Ekvo/golang-chi-postgres-api/internal/transport"
+	"github.com/Ekvo/golang-chi-postgres-api/internal/webhook"
 )
 
 func main() {
@@ -32,9 +50,86 @@ func main() {
 	if err := base.CreateTables(ctx); err != nil {
 		log.Fatalf("create tables error - %v", err)
 	}
+	if len(os.Args) > 1 && os.Args[1] == "import" {
+		if err := runImport(ctx, base, os.Args[2:]); err != nil {
+			log.Fatalf("main: %v", err)
+		}
+		return
+	}
+	// changes of all replicas - trigger of 'tasks' and LISTEN/NOTIFY
+	hub := events.NewHub()
 	r := chi.NewRouter()
 	connect := server.Init(cfg, r)
-	transport.NewTransport(r).Routes(base)
+	// streams of events end at start of shutdown, http.Server waits for them
+	connect.RegisterOnShutdown(hub.Close)
+	connect.Attach(source.NewListener(cfg, base, hub))
+	// buckets of one replica or of all replicas in PostgreSQL
+	var limiter model.RateLimiter = ratelimit.NewMemory()
+	if cfg.RateLimitStore == config.RateLimitStorePostgres {
+		limiter = base
+	}
+	routes := transport.NewTransport(r, cfg).WithEvents(hub).WithWebhooks(base).
+		WithIdempotency(base, cfg.IdempotencyKeyTTL()).WithRateLimiter(limiter)
+	// export and calendar feed end at start of shutdown too
+	connect.RegisterOnShutdown(routes.CloseStreams)
+	// requests of '/task' in flight, counters of rejections in 'GET /debug/vars'
+	if limit := cfg.MaxConcurrency(); limit > 0 {
+		shedder := shed.NewLimiter(limit, cfg.QueueDelayTarget(), cfg.LatencyTarget())
+		expvar.Publish("shed", expvar.Func(func() any { return shedder.Stats() }))
+		routes.WithLoadShedder(shedder)
+	}
+	var blobs blob.Store
+	if cfg.BlobURL != "" {
+		blobs, err = blob.NewStore(cfg.BlobURL)
+		if err != nil {
+			log.Fatalf("main: blob error - %v", err)
+		}
+		routes.WithAttachments(base, blobs, cfg.AttachmentMaxSize())
+	}
+	routes.Routes(base)
+	if cfg.OutboxURL != "" {
+		pub, err := outbox.NewPublisher(cfg.OutboxURL)
+		if err != nil {
+			log.Fatalf("main: outbox error - %v", err)
+		}
+		connect.Attach(outbox.NewRelay(base, pub))
+	}
+	// background jobs, the pool drains running jobs at shutdown
+	pool := jobs.NewPool(base, cfg.JobPoolSize())
+	reminders, err := reminder.NewScheduler(base, cfg)
+	if err != nil {
+		log.Fatalf("main: reminder error - %v", err)
+	}
+	if reminders.Enabled() {
+		reminders.Register(pool)
+	}
+	// recurring tasks move to the next occurrence when period of current one is elapsed
+	recurrence.NewScheduler(base).Register(pool)
+	// long rank keys of board are replaced by evenly spaced ones
+	rank.NewRebalancer(base).Register(pool)
+	// deliveries of outbox of webhooks and removal of finished ones
+	webhook.NewWorker(base).Register(pool)
+	// events of stream after retention
+	events.NewPurger(base).Register(pool)
+	// events of outbox after retention, also without relay
+	outbox.NewPurger(base).Register(pool)
+	// responses of 'Idempotency-Key' after their TTL
+	idempotency.NewPurger(base).Register(pool)
+	if cfg.RateLimitStore == config.RateLimitStorePostgres {
+		ratelimit.NewPurger(base).Register(pool)
+	}
+	// blobs of deleted attachments and of deleted tasks
+	if blobs != nil {
+		blob.NewCleaner(blobs).Register(pool)
+	}
+	connect.Attach(pool)
+	if cfg.GRPCHost != "" {
+		tasks := rpc.NewTaskServer(base, hub)
+		if quota := cfg.DailyTaskQuota(); quota > 0 {
+			tasks.WithQuota(ratelimit.NewTaskQuota(limiter, quota))
+		}
+		connect.Attach(rpc.NewService(net.JoinHostPort("", cfg.GRPCHost), tasks))
+	}
 
 	if err := connect.ListenAndServeAndShut(ctx, server.TimeShutServer); err != nil {
 		log.Fatalf("main: server error - %v", err)
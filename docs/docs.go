@@ -15,6 +15,10 @@
 
 // package main ~> ../cmd/app
 // logic of application
+/*
+ - import.go
+ * func - runImport - subcommand 'import' [-format csv|ndjson] [-dry-run] <file|-> - COPY of file into the store
+*/
 
 // package config ~> ../internal/config
 // parse data for run application from file or ENV
@@ -23,6 +27,22 @@
  * struct - Config      - contain critical data for run of application
  * func   - NewConfig
  * func   - getNameENV  - returns array of string  with hanes all name of ENV variables
+ * func   - APIKeyUsers - member of Config - 'SRV_API_KEYS' ("user:key,user:key") as map key -> user
+ * func   - JobPoolSize - member of Config - 'JOB_WORKERS' as number
+ * field  - CalendarSecret - 'CALENDAR_SECRET' key of tokens of calendar feed, from 16 characters, empty - feed is off
+ * field  - BlobURL - 'BLOB_URL' store of attachments file:///path or s3://key:secret@host/bucket, empty - attachments are off
+ * func   - AttachmentMaxSize - member of Config - 'ATTACH_MAX_SIZE' max size of one attachment in bytes
+ * func   - IdempotencyKeyTTL - member of Config - 'IDEMPOTENCY_TTL' time of keeping of responses of 'Idempotency-Key'
+ * struct - RateLimit   - bucket of method and prefix of path (or "default"): Count per Per, Burst
+ * func   - RateLimitRules - member of Config - 'RATE_LIMITS' ("default=20/s:40,POST /task=5/s,/task/export=2/m")
+ * func   - DailyTaskQuota - member of Config - 'TASK_DAILY_QUOTA' tasks of user in a day, 0 - off
+ * func   - MaxConcurrency, QueueDelayTarget, LatencyTarget - member of Config - 'CONCURRENCY_LIMIT' (0 - off),
+'SHED_QUEUE_TARGET' max wait in queue, 'SHED_LATENCY_TARGET' time of request which cuts limit
+ * field  - RateLimitBy, RateLimitStore - 'RATE_LIMIT_BY' client of bucket (key, user, ip),
+'RATE_LIMIT_STORE' buckets of replica (memory) or of all replicas (postgres)
+ * func   - AdminUsers - member of Config - 'SRV_ADMINS' ("user,user") users of 'SRV_API_KEYS' who change schema of custom fields
+ * struct - QuietHours  - time of day in time zone when reminders are not sent, Contains
+ * func   - QuietHoursOfUsers - member of Config - 'REMIND_QUIET_HOURS' as map user -> QuietHours
  * func   - validConfig - member of Config - create 'common.Message' see pkg/common/common.go
 check all fields for validity. If field after viper.Unmarhal is broken exept 'DBNameForTest'
 add name field (key) and set Error(value).
@@ -33,17 +53,277 @@ if 'common.Message' is not empty, an error describing all corrupted fields is re
 // describe property of Task - object stored in the database
 /*
  - model.go
- * struct - Task
+ * struct - Task - RemindAt and RemindUser - reminder and user who set it,
+DueAt and TimeZone - current occurrence in IANA zone, Recurrence - RRULE and DTSTART of recurring Task,
+ProjectID - Project of Task, Done - Task is finished, Status and Position - column of board and rank key in it
  * 4 interface - object maintenance in strore
+ * struct - TaskPatch  - id and func for change stored Task, TextPatch - only description and note
+(gRPC, GraphQL and batch keep project, done, reminder, due time and recurrence)
+ * interface - TaskModify - PatchTask
+ * struct(s) - Batch, BatchOperation, BatchResult
+ * interface - TaskBatch  - ExecuteBatch
+ * struct    - TaskImport   - stream of Task and dry run flag
+ * interface - TaskImporter - ImportTasks
+ * struct    - TaskFilter, TaskExport - conditions of many Task (order, created, only with due time, limit, offset,
+custom fields, project, with Task of archived Project, column, order of board) and func for each of them
+ * struct    - TaskMove, RankRebalance - move of Task between neighbours in column, new keys of long columns
+ * interface - TaskBoard - MoveTask, RebalancePositions
+ * struct    - IdempotencyClaim, IdempotencyRecord - key of request of user and its kept response
+ * interface - IdempotencyStore - ClaimIdempotencyKey, SaveIdempotentResponse, ReleaseIdempotencyKey, PurgeIdempotencyKeys
+ * struct    - RateTake, RateState, QuotaTake, QuotaState - token of bucket and count of daily quota of client
+ * interface - RateLimiter - TakeRate, TakeQuota, PurgeRateLimits
+ * interface - TaskExporter - ExportTasks
+ * interface - TaskFindMany - FindTasksByID - many Task by one query
+ * struct    - TaskEvent    - created, updated or deleted Task, ID - number of event
+ * struct    - EventCursor  - transaction and id of event, order of commit (Less, String "tx-id")
+ * interface - TaskEventLog - TaskEventsSince - kept events for resume of stream
+ * interface - TaskEventRetention - PurgeTaskEvents - removal of old events
+ * interface - TaskStore    - all interfaces of Task in store
+ * struct    - Webhook, WebhookDelivery - subscription of URL and one event for it (row of outbox)
+ * interface - WebhookStore  - create, list, delete webhooks, log of deliveries, redelivery
+ * interface - WebhookOutbox - ClaimDeliveries, FinishDelivery, PurgeDeliveries (DeliveryPurge) - for worker of deliveries
+ * struct    - DomainEvent, OutboxRelay, OutboxPurge - row of table 'outbox', batch of relay with 'Publish', ages of purge
+ * interface - TaskOutbox - RelayOutbox, PurgeOutbox - for relay of domain events
+ * struct    - Job, JobClaim, JobResult - background job, claim with lease and result of run
+ * interface - JobQueue - EnqueueJob (unique Kind + Key), ClaimJobs, FinishJob, PurgeJobs
+ * struct    - ReminderScan, ReminderClaim - due reminders and reminder of Task for one channel
+ * interface - TaskReminders - DueReminders, ClaimReminder, ReleaseReminder - deduplication of reminders,
+done Task and Task of archived Project have no reminders
+ * struct    - RecurrenceScan  - recurring Task with due time before, pages by id
+ * interface - TaskRecurrences - RecurringTasks - Task whose period may be elapsed, done Task and Task of archived Project
+are skipped
+ * struct    - TimeEntry, TimerStop - time spent on Task by user (without stop - running timer), stop of timer
+ * struct    - TimeReport, TimeTotal - conditions of report and time of Task in one day
+ * interface - TimeTracker - StartTimer, StopTimer, SaveTimeEntry, TimeEntries, TimeTotals
+ * struct    - Attachment, AttachmentRef - file of Task (name, type, size, checksum, key of blob), id of one file
+ * interface - AttachmentStore - SaveAttachment, FindAttachment, TaskAttachments, DeleteAttachment
+ * const     - KindBlobCleanup - job of removal of blobs of deleted attachments and of deleted Task
+ * struct    - CustomField - field of schema of Task (name, type string|number|boolean|date, required, allowed values),
+values of Task are in CustomFields, TaskFilter.CustomFields - all of them must be equal
+ * interface - CustomFieldStore - SaveCustomField (create or replace by name), CustomFields, DeleteCustomField
+ * struct    - Project, ProjectList - group of Task (name, description, archived, position, counts of open and done Task)
+ * interface - ProjectStore - SaveProject, UpdateProject, DeleteProject, FindProject, FindProjects
+*/
+
+// package events ~> ../internal/events
+// changes of Task inside the process
+/*
+ - hub.go
+ * struct - Hub          - fan-out of TaskEvent, slow subscriber is dropped (Lagged)
+ * struct - Subscription - channel of events, Close
+ * source of events - source.Listener (LISTEN/NOTIFY), events of all replicas
+------------------------------------------------------------------------------------------------------------
+ - purge.go
+ * struct - Purger - periodic job of jobs.Pool, events of store older than Retention (resume of 'Last-Event-ID')
+*/
+
+// package webhook ~> ../internal/webhook
+// outgoing webhooks
+/*
+ - worker.go
+ * struct - Worker  - periodic jobs of jobs.Pool: Deliver - claim due deliveries (SKIP LOCKED) and POST them
+in parallel, end of ctx of job cancels deliveries in progress; Purge - succeeded after Retention, dead after DeadRetention
+------------------------------------------------------------------------------------------------------------
+ - dial.go
+ * func   - newClient  - client of deliveries, address is checked at connection, redirects are not followed
+ * func   - publicOnly - net.Dialer.Control, loopback, private, link-local and shared addresses - ErrWebhookAddress
+ * func   - Sign    - "sha256=" + HMAC-SHA256 of "<timestamp>.<body>" with secret of webhook
+ * func   - Backoff - 10s, 20s, 40s ... up to 1h, after MaxAttempts delivery is dead
+*/
+
+// package outbox ~> ../internal/outbox
+// transactional outbox of domain events
+/*
+ - publisher.go
+ * interface - Publisher       - Publish in order, Close
+ * func      - NewPublisher    - by 'OUTBOX_URL': stdout, file:///path, nats://[user:pass@|token@]host:4222
+ * struct    - WriterPublisher - NDJSON line of each message
+------------------------------------------------------------------------------------------------------------
+ - nats.go
+ * struct - NATSPublisher - HPUB with 'Nats-Msg-Id' (or PUB), PING - PONG confirms batch is read by server
+(not PubAck of JetStream), credentials of URL in CONNECT, reconnect after error
+------------------------------------------------------------------------------------------------------------
+ - relay.go
+ * struct - Relay      - events of 'outbox' to Publisher (at least once), server.Service
+------------------------------------------------------------------------------------------------------------
+ - purge.go
+ * struct - Purger - periodic job of jobs.Pool, published events after Retention, not published after UnpublishedRetention
+ * func   - NewMessage - Envelope of DomainEvent, subject "tasks.<type>"
+*/
+
+// package idempotency ~> ../internal/idempotency
+// responses of requests with 'Idempotency-Key' for retries of clients
+/*
+ - key.go
+ * func   - ValidKey    - from 1 to 255 visible ASCII characters
+ * func   - Fingerprint - SHA-256 of method, URI and body of request
+ * struct - Recorder    - http.ResponseWriter which keeps copy of status, headers and body
+ * func   - Replay      - kept response with 'Idempotent-Replayed: true'
+------------------------------------------------------------------------------------------------------------
+ - purge.go
+ * struct - Purger - periodic job of jobs.Pool, expired keys are removed by batches
+*/
+
+// package ratelimit ~> ../internal/ratelimit
+// token buckets of clients by GCRA (theoretical arrival time) and daily quotas
+/*
+ - gcra.go
+ * func   - Step        - new TAT of bucket and if token is taken
+ * struct - Decision    - values of 'RateLimit-*' headers and 'Retry-After'
+ * func   - NewDecision - Decision of RateState
+------------------------------------------------------------------------------------------------------------
+ - memory.go
+ * struct - Memory - model.RateLimiter of one replica, full buckets and past days are swept
+------------------------------------------------------------------------------------------------------------
+ - purge.go
+ * struct - Purger - periodic job of jobs.Pool, full buckets and quotas of past days of shared store are removed
+------------------------------------------------------------------------------------------------------------
+ - quota.go
+ * struct - TaskQuota - 'TASK_DAILY_QUOTA' tasks of client in a day (UTC), the same for REST, GraphQL and gRPC
+ * struct - Charge    - tasks taken by one request (Take, Reserve), tasks which are not created are returned (Refund)
+ * func   - NewContext, FromContext - Charge of request in context
+*/
+
+// package shed ~> ../internal/shed
+// adaptive limit of requests in flight and load shedding
+/*
+ - limiter.go
+ * struct - Limiter - AIMD limit up to max, queues of Read and Write (writes first, reads up to 3/4 of limit),
+wait above 'queueTarget' - ErrShed, Stats - counters of admitted and rejected requests
+ * struct - Token   - place of request, Hold and Done of each holder, the last one gives place back,
+time of Bulk request does not change limit
+ * func   - NewContext, FromContext - Token of request in context
+*/
+
+// package jobs ~> ../internal/jobs
+// durable background jobs on PostgresSQL
+/*
+ - pool.go
+ * struct - Pool    - workers of registered kinds (Handle, Every), drain of running jobs at Shutdown, server.Service
+ * func   - NewJob  - job of kind with JSON payload
+ * func   - Backoff - 5s, 10s, 20s ... up to 1h, after MaxAttempts (or ErrJobsPermanent) job is failed
+*/
+
+// package blob ~> ../internal/blob
+// content of attachments of Task
+/*
+ - store.go
+ * interface - Store    - Put (whole content or nothing), Open (io.ReadSeekCloser for ranges), Delete
+ * func      - NewStore - by 'BLOB_URL': file:///path, s3://key:secret@host:9000/bucket?region=&tls=false
+------------------------------------------------------------------------------------------------------------
+ - local.go
+ * struct - LocalStore - files of directory, temporary file and rename
+------------------------------------------------------------------------------------------------------------
+ - s3.go
+ * struct - S3Store - S3-compatible bucket (AWS S3, MinIO), path-style requests with AWS Signature Version 4,
+checksum of content is checked by storage, Open sends GET (missing object - ErrBlobNotFound),
+read after Seek - GET with 'Range'
+------------------------------------------------------------------------------------------------------------
+ - cleanup.go
+ * struct - Cleaner - handler of job 'model.KindBlobCleanup' of jobs.Pool, retry until all blobs are removed
+*/
+
+// package reminder ~> ../internal/reminder
+// reminders of Task
+/*
+ - notifier.go
+ * interface - Notifier      - channel of reminders
+ * func      - NewNotifiers  - channels of config: SMTPNotifier, SlackNotifier (incoming webhook), HTTPNotifier (JSON)
+------------------------------------------------------------------------------------------------------------
+ - scheduler.go
+ * struct - Scheduler - periodic job of jobs.Pool, due reminders by template, quiet hours of users,
+each channel is claimed before sending - no second sending after restart, due reminders by pages after
+'remind_at' and id of last one - reminders in quiet hours and failed ones do not hold back later ones
+*/
+
+// package recurrence ~> ../internal/recurrence
+// recurring Task - RFC 5545 RRULE in time zone of Task
+/*
+ - rrule.go
+ * func   - Parse     - RRULE: FREQ (DAILY ... YEARLY), INTERVAL, COUNT, UNTIL, BYDAY, BYMONTHDAY, BYMONTH,
+BYHOUR, BYMINUTE, BYSETPOS, WKST
+ * struct - Series    - occurrences from DTSTART in local time of zone - Next, Last, Between
+ * func   - WallClock - local time by RFC 5545 - time in gap of DST is moved by the gap, time which is twice - the first
+------------------------------------------------------------------------------------------------------------
+ - scheduler.go
+ * func   - Complete, Elapse - Task moves to the next occurrence or to the last which has come, reminder keeps offset
+ * func   - Occurrences      - occurrences of Task from current due time
+ * struct - Scheduler        - periodic job of jobs.Pool, moves Task whose period is elapsed by PatchTask
+*/
+
+// package rank ~> ../internal/rank
+// order of Task in column of board - lexicographic fractional indexes in base 62
+/*
+ - rank.go
+ * func - Between - key between two keys ("" - edge of column), grows by one digit only without room
+ * func - Spread  - evenly spaced keys of column
+------------------------------------------------------------------------------------------------------------
+ - rebalance.go
+ * struct - Rebalancer - periodic job of jobs.Pool, columns with keys longer than MaxKeyLen, unranked or equal keys
+get keys of Spread
+*/
+
+// package gql ~> ../internal/gql
+// GraphQL - github.com/graph-gophers/graphql-go
+/*
+ - schema.go
+ * const  - Schema   - Task, TaskConnection (cursor pagination), Query and Mutation
+ * struct - resolver - task, tasks, createTask, updateTask, deleteTask over TaskStore, createTask takes quota of request,
+updateTask - TextPatch of description and note
+------------------------------------------------------------------------------------------------------------
+ - handler.go
+ * struct - Handler - 'POST /graphql', complexity is checked before execution, MaxDepth and MaxParallelism
+(not less than batch of taskLoader) are options of graphql-go, errors of query have 'extensions.code'
+------------------------------------------------------------------------------------------------------------
+ - parse.go
+ * func - parseQuery - document of query in AST of graphql-go (package types), parser of graphql-go is internal
+------------------------------------------------------------------------------------------------------------
+ - limits.go
+ * func - checkComplexity - MaxComplexity of operation (children multiplied by 'first', fragment for each spread)
+------------------------------------------------------------------------------------------------------------
+ - loader.go
+ * struct - taskLoader - DataLoader of one Request, parallel 'Load' of any level (aliases, items of list)
+is one FindTasksByID up to 100 ids
+------------------------------------------------------------------------------------------------------------
+ - ratelimit.go
+ * func - RateLimit - middlweare function, token of bucket of client and route, 'RateLimit-*' headers,
+no token - 429 with 'Retry-After', error of store - request passes
+ * func - TaskQuota - middlweare function, 'ratelimit.Charge' of user in context, create, batch (N creates),
+import (by blocks of 100) and 'createTask' of GraphQL take it, used - 429 with 'Retry-After', task is not created - quota is returned
+------------------------------------------------------------------------------------------------------------
+ - shed.go
+ * func - LoadShed - middlweare function, place of 'shed.Limiter' (GET, HEAD, OPTIONS - reads), long wait - 503
+with 'Retry-After', TaskHandler holds place until its query ends
+ * func - BulkLoad - middlweare function, time of 'POST /task/batch' does not cut limit ('Token.Bulk')
+------------------------------------------------------------------------------------------------------------
+ - errors.go
+ * func - resolveError - taxonomy of 'source' to 'extensions.code' (NOT_FOUND, BAD_USER_INPUT, ...)
+*/
+
+// package rpc ~> ../internal/rpc
+// gRPC TaskService (look ~> ../api/task.proto, code ~> ../pkg/taskpb)
+/*
+ - server.go
+ * struct - TaskServer - Create, Get, Update, Delete, List and Watch over TaskStore, rules of TaskValidator,
+Update - TextPatch of description and note
+ * func   - WithQuota  - daily quota of CreateTask by IP of peer, used - ResourceExhausted
+------------------------------------------------------------------------------------------------------------
+ - service.go
+ * struct - Service - grpc.Server on own port with health and reflection, Serve and Shutdown (GracefulStop)
+------------------------------------------------------------------------------------------------------------
+ - errors.go
+ * func - statusError - taxonomy of 'source' to gRPC codes, invalid fields in 'errdetails.BadRequest'
 */
 
 // packege server ~> ../internal/server
 // rules for use http.Server in application
 /*
  - server.go
- * struct - Connect        - contain http.Server
+ * struct - Connect        - contain http.Server and attached Service(s)
+ * interface - Service     - Serve and Shutdown together with http.Server (gRPC)
  * func   - Init function  - get property from  config.Config for initialize http.Serve
- * func   - ListenAndServe - property of connect and shut http.Server
+ * func   - ListenAndServe - property of connect and shut http.Server, http.Server and each Service are shut
+at the same time, each has whole 'timeShut'
 */
 
 // packege servises ~> ../internal/servises
@@ -51,14 +331,84 @@ if 'common.Message' is not empty, an error describing all corrupted fields is re
 /*
  - validator.go
  * struct - TaskValidator - rules for body from Request
- * func   - DecodeJSON    - TaskValidator member - get body for Task
+ * func   - Decode        - TaskValidator member - get body for Task (format by 'Content-Type')
  * func   - TaskModel     - return object Task
+ * func   - validate      - TaskValidator member - declarative rules of 'Data'
+ * func   - TaskFromData  - Task by rules of TaskValidator without Request (gRPC)
+------------------------------------------------------------------------------------------------------------
+ - rules.go
+ * type   - Rule             - check (and normalize) one field value, returns reason
+ * struct - Field            - name, ptr of value and rules
+ * type   - ValidationErrors - all invalid fields, body 422 with 'invalid_params'
+ * func   - Validate         - check all fields and collect all errors at once
+ * func(s) - Trim, Required, MaxRunes, MinRunes, NoControl, OneOf, HTTPURL, Timestamp - rules
+ * func(s) - RRule, TimeZone, RequiredWith - rules of recurring Task
+ * func(s) - RequiredWithout, ExcludedWith, After, Duration - rules of time entries
+------------------------------------------------------------------------------------------------------------
+ - patch.go
+ * struct - TaskPatchValidator - patch document from PATCH Request (merge-patch+json or json-patch+json)
+ * func   - DecodePatch        - TaskPatchValidator member - get document, check media type
+ * func   - Apply              - TaskPatchValidator member - patch Task and re-run rules of TaskValidator
+------------------------------------------------------------------------------------------------------------
+ - batch.go
+ * struct - BatchValidator - rules for 'task_batch' (mode "atomic" or "best_effort", up to 1000 operations)
+ * func   - Decode         - BatchValidator member - get valid operations, in best effort keep invalid apart
+------------------------------------------------------------------------------------------------------------
+ - importer.go
+ * struct - TaskImportReader - read Task row by row from CSV (header) or NDJSON
+ * func   - Next             - TaskImportReader member - next valid Task by all rules of TaskValidator (TaskFromData),
+invalid rows are rejected with line
+------------------------------------------------------------------------------------------------------------
+ - exporter.go
+ * struct - TaskExportWriter - write Task one by one in CSV, NDJSON or JSON array (Begin, Write..., End)
+ * func   - NewTaskFilter    - TaskFilter from params 'order', 'created_from', 'created_to', 'limit', 'offset',
+'project_id', 'archived', 'status'
+------------------------------------------------------------------------------------------------------------
+ - calendar.go
+ * struct - CalendarWriter - iCalendar (RFC 5545) feed, VEVENT (recurring - with RRULE) or VTODO of Task with due time,
+VALARM of reminder, VTIMEZONE of each zone of feed (Begin, Write..., End)
 ------------------------------------------------------------------------------------------------------------
  - serializer.go
- * struct - TaskSerializer     - rules for creating a body for ResponseWriter from one Task
+ * struct - TaskSerializer     - rules for creating a body for ResponseWriter from one Task (with totals of time)
  * func   - Response           - TaskSerializer member - create body of Task
  * struct - TaskListSerializer - body for ResponseWriter from array of Tasks
  * func   - Response           - member of TaskListSerializer
+ * struct - TaskEventSerializer - body of one event of stream
+ * struct - OccurrencesResponse - body of occurrences of Task in its time zone
+ * struct - CalendarFeedResponse - user, token and URL of calendar feed
+------------------------------------------------------------------------------------------------------------
+ - timetrack.go
+ * struct - TimeEntryValidator - 'time_entry' manual ('start' and 'stop' or 'duration' up to 24h) or timer ('note')
+ * func   - NewTimeReport      - TimeReport from params 'from', 'to', 'user', 'time_zone', 'task_id'
+ * struct - TimeEntryResponse, TimeTrackedResponse, TimeReportResponse - entry, totals of Task, report
+ * func   - NewTimeTracked     - totals by id of Task, NewTimeReportResponse - totals by Task and by day
+------------------------------------------------------------------------------------------------------------
+ - attachment.go
+ * struct - AttachmentReader - files of field 'file' of multipart/form-data (up to 10) in temporary files,
+size limit, SHA-256 and sniffed type (png, jpeg, gif, webp, text, pdf, zip, gzip)
+ * struct - Upload - temporary file of form, Close removes it
+ * struct - AttachmentResponse - body of one attachment
+------------------------------------------------------------------------------------------------------------
+ - customfield.go
+ * struct - CustomFieldValidator - 'custom_field' (type, required, allowed values only of string and number)
+ * func   - ValidCustomFields    - values of 'custom_fields' of Task by schema, unknown field - invalid
+ * func   - NewCustomFieldFilter - values of params "cf.<name>" converted by type of field
+ * struct - CustomFieldResponse  - body of one field
+------------------------------------------------------------------------------------------------------------
+ - project.go
+ * struct - ProjectValidator - rules for 'project' (name up to 128, description, archived, position)
+ * struct - ProjectResponse  - body of one project with counts of open and done Task
+------------------------------------------------------------------------------------------------------------
+ - board.go
+ * func   - BoardStatus   - name of column (lower case letter, then letters, digits or '_', up to 32)
+ * struct - MoveValidator - 'move' (status, after, before), anchors are other Task
+ * struct - BoardTaskResponse, BoardColumnResponse - Task with id, column and key, Task of one column
+ * func   - NewBoardResponse - columns of Task in order of board
+------------------------------------------------------------------------------------------------------------
+ - webhook.go
+ * struct - WebhookValidator - rules for 'webhook' (http(s) url, types of events, secret from 16 characters)
+ * struct - WebhookListSerializer, WebhookDeliveriesSerializer - bodies of list and log (without secret)
+ * struct - WebhookPayload   - body of delivery, TaskEventResponse with 'event_id'
 */
 
 // packege source ~> ../internal/source
@@ -71,7 +421,101 @@ if 'common.Message' is not empty, an error describing all corrupted fields is re
 ------------------------------------------------------------------------------------------------------------
  - query.go
  * describe logic of interfaces Task look. (look: package model ~> ../internal/model)
+ * func      - FindTasksByID - Dbinstance member - WHERE id = ANY($1)
+ * func      - patchTaskRow  - SELECT ... FOR UPDATE, TaskPatch.Apply and UPDATE of all columns in transaction
  * interface - RowScaner - logic for 'Scan' data from a database
+------------------------------------------------------------------------------------------------------------
+ - errors.go
+ * var(s)  - ErrSourceConflict, ErrSourceValidation, ErrSourceUnavailable - taxonomy of store errors
+ * struct  - ValidationError - ErrSourceValidation with field-level reasons
+ * func    - classifyError   - reduce 'database/sql' and 'lib/pq' errors to the taxonomy
+------------------------------------------------------------------------------------------------------------
+ - batch.go
+ * func - ExecuteBatch   - Dbinstance member - create, update, delete in one transaction in order of operations,
+consecutive creates - one multi-row INSERT, error of atomic batch has index of failed operation,
+update - TextPatch of description and note
+ * func - insertTaskRows - one INSERT with many VALUES
+ * func - savepoint      - best effort: failed operation rollback only itself
+------------------------------------------------------------------------------------------------------------
+ - import.go
+ * func - ImportTasks       - Dbinstance member - COPY FROM STDIN into temporary table in one transaction,
+dry run rolls back
+ * func - moveImportedTasks - INSERT ... SELECT ... RETURNING by pages of 1000 rows, 'writeOutbox' of each page
+------------------------------------------------------------------------------------------------------------
+ - export.go
+ * func - ExportTasks - Dbinstance member - DECLARE CURSOR and FETCH by 500 rows, 'Each' for every Task
+------------------------------------------------------------------------------------------------------------
+ - events.go
+ * func   - createEventTables - table 'task_events' (with transaction 'tx_id'), trigger of 'tasks' with pg_notify,
+UPDATE of only 'position' is not an event, CreateTables needs PostgreSQL 14+ (CREATE OR REPLACE TRIGGER, xid8)
+ * func   - TaskEventsSince   - Dbinstance member - events after cursor of transactions older than the oldest running,
+ErrSourceEventsGone after retention (cursor of the last removed event - 'task_events_purged')
+ * func   - PurgeTaskEvents   - Dbinstance member - events older than retention, cursor of the last of them is kept
+ * struct - Listener          - LISTEN 'task_events' as wake-up, events after cursor by pages are published to Hub
+------------------------------------------------------------------------------------------------------------
+ - webhook.go
+ * func - createWebhookTables - 'webhooks' and 'webhook_deliveries' (outbox, rows are added by trigger of 'tasks')
+ * func - CreateWebhook, FindWebhooks, DeleteWebhook, WebhookDeliveries, RedeliverWebhook - Dbinstance member
+ * func - ClaimDeliveries - Dbinstance member - due deliveries by FOR UPDATE SKIP LOCKED with lease
+ * func - FinishDelivery  - Dbinstance member - succeeded, next attempt or dead
+ * func - PurgeDeliveries - Dbinstance member - succeeded and dead deliveries older than their retention
+------------------------------------------------------------------------------------------------------------
+ - outbox.go
+ * func - createOutboxTable - table 'outbox' of domain events
+ * func - writeOutbox       - events of Task in transaction of change (save, update, patch, delete, batch, import)
+ * func - RelayOutbox       - Dbinstance member - FOR UPDATE SKIP LOCKED, Publish, mark published in one transaction
+ * func - PurgeOutbox       - Dbinstance member - published and not published events older than their retention
+------------------------------------------------------------------------------------------------------------
+ - jobs.go
+ * func - createJobTable - table 'jobs', unique (kind, key) of queued and running jobs
+ * func - EnqueueJob     - Dbinstance member - INSERT ... ON CONFLICT DO NOTHING, id of existing job with key
+ * func - ClaimJobs      - Dbinstance member - due jobs and jobs with expired lease by FOR UPDATE SKIP LOCKED
+ * func - FinishJob      - Dbinstance member - done, next attempt, failed or released
+------------------------------------------------------------------------------------------------------------
+ - reminders.go
+ * func - createReminderTable - table 'reminders_sent', claims of reminders by channel
+ * func - DueReminders        - Dbinstance member - due 'remind_at' which is not sent to all channels,
+page after ('remind_at', id) of cursor
+ * func - ClaimReminder, ReleaseReminder - Dbinstance member - INSERT ... ON CONFLICT DO NOTHING, DELETE
+------------------------------------------------------------------------------------------------------------
+ - recurrence.go
+ * func - RecurringTasks - Dbinstance member - Task with 'rrule' and 'due_at' before time, in order of id
+------------------------------------------------------------------------------------------------------------
+ - timetrack.go
+ * func - createTimeEntryTable - table 'time_entries', unique index of running entry of user
+ * func - StartTimer, StopTimer, SaveTimeEntry, TimeEntries - Dbinstance member - entries of existing Task
+ * func - TimeTotals - Dbinstance member - sum by Task and day of start in zone of report or of Task
+------------------------------------------------------------------------------------------------------------
+ - attachments.go
+ * func - createAttachmentTable - table 'attachments', unique key of blob
+ * func - SaveAttachment, FindAttachment, TaskAttachments - Dbinstance member - attachments of existing Task
+ * func - DeleteAttachment - Dbinstance member - DELETE and job 'blobs.cleanup' in one transaction
+ * func - enqueueTaskBlobCleanup - job of blobs of Task in transaction of its delete (EndTaskLife, batch)
+------------------------------------------------------------------------------------------------------------
+ - customfields.go
+ * func - createCustomFieldTable - table 'custom_fields', values of Task - JSONB 'tasks.custom_fields' with GIN index
+ * func - SaveCustomField, CustomFields, DeleteCustomField - Dbinstance member - UPSERT by name, list, DELETE
+------------------------------------------------------------------------------------------------------------
+ - projects.go
+ * func  - createProjectTable - table 'projects', 'tasks.project_id' refers to it (ON DELETE SET NULL)
+ * func  - SaveProject, UpdateProject, DeleteProject, FindProject, FindProjects - Dbinstance member - counts by COUNT FILTER
+ * const - visibleTask - condition of Task not in archived project (FindTaskList, ExportTasks without 'Archived')
+------------------------------------------------------------------------------------------------------------
+ - idempotency.go
+ * func - createIdempotencyTable - table 'idempotency_keys', key of user, NULL 'status' - request in flight
+ * func - ClaimIdempotencyKey - Dbinstance member - INSERT ... ON CONFLICT, expired key or abandoned request is replaced
+ * func - SaveIdempotentResponse, ReleaseIdempotencyKey, PurgeIdempotencyKeys - Dbinstance member
+------------------------------------------------------------------------------------------------------------
+ - ratelimit.go
+ * func - createRateLimitTables - tables 'rate_limits' (TAT of bucket) and 'quota_usage' (count of client in day)
+ * func - TakeRate  - Dbinstance member - one UPSERT by time of base, no token - row is not changed
+ * func - TakeQuota - Dbinstance member - INSERT ... ON CONFLICT up to limit, negative count is returned
+ * func - PurgeRateLimits - Dbinstance member
+------------------------------------------------------------------------------------------------------------
+ - board.go
+ * func - MoveTask - Dbinstance member - only row of Task is changed, neighbours are locked FOR UPDATE,
+equal keys or too long key - column is rebalanced in the same transaction
+ * func - RebalancePositions - Dbinstance member - each column with long, empty or equal keys in own transaction
 */
 
 // packege transport ~> ../internal/transport
@@ -79,8 +523,13 @@ if 'common.Message' is not empty, an error describing all corrupted fields is re
 /*
  - transport.go
  * struct - Transport  - contain ptr of chi.Mux
- * Routes - Transport member
- * func   - taskRoutes - logic application handlers
+ * Routes - Transport member - '/task', '/project', '/fields', 'POST /graphql', 'GET /ws' and '/webhooks', all behind 'Authenticate',
+only 'GET /task/calendar.ics' (with 'CALENDAR_SECRET') is checked by token of feed
+ * func   - WithIdempotency - Transport member - store and TTL of 'Idempotency' of '/task' and '/project'
+ * func   - WithRateLimiter - Transport member - store of 'RateLimit' of all routes and 'TaskQuota' of '/task' and 'POST /graphql'
+ * func   - WithLoadShedder - Transport member - 'LoadShed' of '/task', 'GET /debug/vars' (expvar) for admins
+ * func   - CloseStreams - Transport member - ends export and calendar feed, called at start of shutdown
+ * func   - taskRoutes - logic application handlers, 'POST /task/import' - own deadline, without 'loadShed' and 'idempotent'
  * func   - Timeout    - midddleware func
 ------------------------------------------------------------------------------------------------------------
  - middlweare.go
@@ -88,18 +537,81 @@ if 'common.Message' is not empty, an error describing all corrupted fields is re
 create context.WithTimeout,
 request = request.WithContext(ctx),
 call next(w,r)
+ * func - ErrorFormat - middlweare function, set format of error body ("problem" or "legacy") in context
+ * func - Authenticate - middlweare function, key of 'Authorization: Bearer' or 'access_token' -> user in context,
+unknown key - 401, without keys - off
+ * func - User - user of request set by 'Authenticate'
+ * func - Admin - middlweare function, user not of 'SRV_ADMINS' - 403, without keys - off
+ * func - Idempotency - middlweare function, POST with 'Idempotency-Key' of user is claimed, response is kept
+and replayed on retry, other request of key - 422, request in flight - 409, 5xx - key is released
+ * func - StreamEnd - middlweare function, context of request is canceled at 'CloseStreams', stream without 'Timeout'
+does not hold shutdown of http.Server
+------------------------------------------------------------------------------------------------------------
+ - errors.go
+ * func - errorData      - response with error and the given status
+ * func - storeErrorData - response with error from store, status from taxonomy of 'source'
+ * func - writeError     - body 'application/problem+json' (RFC 7807) or legacy {"errors":{...}},
+reasons of fields are only in 'invalid_params' of problem, legacy body has message of error without them
+------------------------------------------------------------------------------------------------------------
+ - export.go
+ * func - ExportHandler - stream of Task to ResponseWriter with flush, 'Content-Disposition', no 'Timeout'
+ * func - streamTasks   - Task of filter by 'taskWriter' (export, calendar), error before the first row - response of error
+------------------------------------------------------------------------------------------------------------
+ - calendar.go
+ * func - FeedToken       - "<user>.<HMAC-SHA256(CALENDAR_SECRET, user)>", not stored, new secret revokes all
+ * func - CalendarHandler - 'GET /task/calendar.ics?token=' iCalendar of Task with due time, filters of export,
+'component' - VEVENT (default) or VTODO, unknown token - 401
+ * func - calendarToken   - 'GET /task/calendar/token' token and URL of feed of authenticated user
+------------------------------------------------------------------------------------------------------------
+ - timetrack.go
+ * func - timerStart, timerStop - 'POST /task/{id}/timer/start|stop' timer of user, second running timer - 409
+ * func - timeEntryCreate, timeEntryList - 'POST, GET /task/{id}/time' manual entry, entries with totals
+ * func - timeReport  - 'GET /task/time/report' totals by Task and by day
+ * func - timeTracked - totals for 'time_tracked' of 'GET /task/{id}' and list
+------------------------------------------------------------------------------------------------------------
+ - attachments.go
+ * func - attachmentRoutes  - '/task/{id}/attachments' (with 'BLOB_URL'), upload and download without 'Timeout'
+ * func - attachmentUpload  - 'POST' files of form to blob store and store, 413 - size, 415 - type
+ * func - attachmentList, attachmentRemove - 'GET /', 'DELETE /{aid}', blob is removed by cleanup job
+ * func - AttachmentHandler - 'GET /{aid}' content by http.ServeContent: Range, ETag (checksum), attachment
+------------------------------------------------------------------------------------------------------------
+ - customfields.go
+ * func - customFieldRoutes - '/fields' schema of custom fields, 'PUT, DELETE /{name}' only by 'Admin'
+ * func - taskSearch - 'GET /task/' Task by filters of export and params "cf.<name>=value" (up to 1000)
+------------------------------------------------------------------------------------------------------------
+ - project.go
+ * func - projectRoutes - '/project' create, list ('archived=true' - with archived), get, replace, delete
+ * func - projectTasks  - 'GET /project/{id}/tasks' Task of project (archived too) by filters of 'GET /task/'
+------------------------------------------------------------------------------------------------------------
+ - board.go
+ * func - taskMove  - 'POST /task/{id}/move' to column after and/or before other Task, without them - to the end
+ * func - taskBoard - 'GET /task/board' columns of Task by filters of 'GET /task/', 'status' - one column
+------------------------------------------------------------------------------------------------------------
+ - events.go
+ * func - EventsHandler - 'GET /task/events' Server-Sent Events, id - cursor "tx-id", resume by 'Last-Event-ID', heartbeat
+------------------------------------------------------------------------------------------------------------
+ - ws.go
+ * func   - WSHandler - 'GET /ws' WebSocket, messages subscribe/unsubscribe (by task ids and types of event),
+presence ("user X is editing task 5") and ping, server ping each 30s, slow client is closed with 1013
+ * struct - presence  - users of connections of process by task
+------------------------------------------------------------------------------------------------------------
+ - webhook.go
+ * func - webhookRoutes - POST, GET '/webhooks', DELETE '/webhooks/{id}', GET '/webhooks/{id}/deliveries',
+POST '/webhooks/deliveries/{id}/redeliver', only for admins
 ------------------------------------------------------------------------------------------------------------
  - route.go
 describe application handlers
  * struct - responseData - body and status for 'ResponseWriter'
- * type   - taskFunc     - layout of func for 'Decode', work with Store(DB) and Encode object
+ * type   - taskFunc     - layout of func for 'Decode', work with Store(DB) and Encode object, generic by store
  * func TaskHandler - main function on route
 accepts an interface for interaction with the database,
 function 'taskFunc' describing the logic of processing the object and obtaining the result.
 create chan 'responseData', call in goroutine function 'taskFunc' for create 'responseData',
 in select inside TaskHandler get data from chan 'responseData',
-call 'common.EncodeJSON' for  create Response
+call 'common.Encode' for  create Response in format of 'Accept' (406 if format is not supported)
  * func(s) - create, read, update and delete of Task
+ * func    - taskComplete    - 'POST /task/{id}/complete' - recurring Task moves to the next occurrence
+ * func    - taskOccurrences - 'GET /task/{id}/occurrences?until=' - upcoming occurrences in time zone of Task
 */
 
 // packege variables ~> ../internal/variables
@@ -114,5 +626,20 @@ call 'common.EncodeJSON' for  create Response
  * struct - MessageError - wrap error for Response
  * func   - DecodeJSON   - rules for getting object body from Request
  * func   - EncodeJSON   - set COntent-type, code status, and write body for ResponseWriter
+------------------------------------------------------------------------------------------------------------
+ - problem.go
+ * struct - Problem       - RFC 7807 error body with 'invalid_params'
+ * func   - EncodeProblem - write Problem with media type 'application/problem+json'
+------------------------------------------------------------------------------------------------------------
+ - patch.go
+ * func   - MergePatch - JSON Merge Patch (RFC 7396)
+ * func   - JSONPatch  - JSON Patch (RFC 6902) add, remove, replace, move, copy, test
+------------------------------------------------------------------------------------------------------------
+ - codec.go
+ * struct - Codec              - encoder and decoder of JSON, YAML, XML, MessagePack, CBOR (uses 'json' tags),
+text of XML is converted by type of field (numbers, booleans, arrays), member which is not XML name - <entry name="...">
+ * func   - NegotiateCodec     - codec by 'Accept', ErrCommonNotAcceptable (406)
+ * func   - CodecByContentType - codec by 'Content-Type', ErrCommonInvalidMedia (415)
+ * func   - Decode, Encode     - body of Request and Response in format of codec
 */
 package docs
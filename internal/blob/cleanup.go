@@ -0,0 +1,43 @@
+package blob
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"github.com/Ekvo/golang-chi-postgres-api/internal/jobs"
+	"github.com/Ekvo/golang-chi-postgres-api/internal/model"
+)
+
+// cleanupPayload - payload of job 'model.KindBlobCleanup', it is written by store in transaction of delete
+type cleanupPayload struct {
+	Keys []string `json:"keys"`
+}
+
+// Cleaner - removes blobs of deleted attachments and of deleted 'Task', job is retried until all are removed
+type Cleaner struct {
+	blobs Store
+}
+
+func NewCleaner(blobs Store) *Cleaner {
+	return &Cleaner{blobs: blobs}
+}
+
+// Register - 'Cleanup' runs jobs of 'model.KindBlobCleanup' of 'pool'
+func (c *Cleaner) Register(pool *jobs.Pool) {
+	pool.Handle(model.KindBlobCleanup, c.Cleanup)
+}
+
+// Cleanup - delete blobs of job, missing blob is removed already (retry of job)
+func (c *Cleaner) Cleanup(ctx context.Context, job model.Job) error {
+	payload := cleanupPayload{}
+	if err := json.Unmarshal(job.Payload, &payload); err != nil {
+		return fmt.Errorf("%w - %v", jobs.ErrJobsPermanent, err)
+	}
+	for _, key := range payload.Keys {
+		if err := c.blobs.Delete(ctx, key); err != nil {
+			return err
+		}
+	}
+	return nil
+}
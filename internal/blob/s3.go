@@ -0,0 +1,333 @@
+package blob
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	// s3DefaultRegion - region of signature if 'region' is not set (MinIO accepts it)
+	s3DefaultRegion = "us-east-1"
+	// s3EmptyHash - SHA-256 of empty payload of GET and DELETE
+	s3EmptyHash = "e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855"
+	// s3ErrorBody - part of body of error response in error of store
+	s3ErrorBody = 512
+)
+
+// S3Store - objects of bucket of S3-compatible storage (AWS S3, MinIO, Ceph), path-style requests
+// signed by AWS Signature Version 4
+type S3Store struct {
+	endpoint  *url.URL
+	bucket    string
+	region    string
+	accessKey string
+	secretKey string
+	client    *http.Client
+	now       func() time.Time
+}
+
+// NewS3Store - 'endpoint' is "https://host:port" of storage
+func NewS3Store(endpoint, bucket, region, accessKey, secretKey string) (*S3Store, error) {
+	u, err := url.Parse(endpoint)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" || bucket == "" {
+		return nil, ErrBlobURL
+	}
+	if region == "" {
+		region = s3DefaultRegion
+	}
+	return &S3Store{
+		endpoint:  &url.URL{Scheme: u.Scheme, Host: u.Host, Path: strings.TrimSuffix(u.Path, "/")},
+		bucket:    bucket,
+		region:    region,
+		accessKey: accessKey,
+		secretKey: secretKey,
+		client:    &http.Client{},
+		now:       time.Now,
+	}, nil
+}
+
+// newS3StoreFromURL - "s3://access:secret@host:port/bucket?region=...&tls=false"
+func newS3StoreFromURL(u *url.URL) (*S3Store, error) {
+	secret, ok := u.User.Password()
+	bucket := strings.Trim(u.Path, "/")
+	if !ok || u.User.Username() == "" || u.Host == "" || bucket == "" || strings.Contains(bucket, "/") {
+		return nil, ErrBlobURL
+	}
+	scheme := "https"
+	if tls := u.Query().Get("tls"); tls != "" {
+		on, err := strconv.ParseBool(tls)
+		if err != nil {
+			return nil, ErrBlobURL
+		}
+		if !on {
+			scheme = "http"
+		}
+	}
+	return NewS3Store(scheme+"://"+u.Host, bucket, u.Query().Get("region"), u.User.Username(), secret)
+}
+
+// Put - PUT Object, checksum of 'info' is checked by storage (x-amz-content-sha256)
+func (s *S3Store) Put(ctx context.Context, key string, r io.Reader, info Info) error {
+	if err := validKey(key); err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPut, s.objectURL(key), io.LimitReader(r, info.Size))
+	if err != nil {
+		return err
+	}
+	req.ContentLength = info.Size
+	if info.Size == 0 {
+		// body of zero length is sent as chunked, storage needs 'Content-Length'
+		req.Body = http.NoBody
+	}
+	if info.ContentType != "" {
+		req.Header.Set("Content-Type", info.ContentType)
+	}
+	resp, err := s.do(req, info.SHA256)
+	if err != nil {
+		return err
+	}
+	return closeBody(resp)
+}
+
+// Open - GET Object at once, missing object - ErrBlobNotFound before response is started,
+// 'Read' after 'Seek' to other position is one more GET with 'Range'
+func (s *S3Store) Open(ctx context.Context, key string, size int64) (io.ReadSeekCloser, error) {
+	if err := validKey(key); err != nil {
+		return nil, err
+	}
+	o := &s3Object{ctx: ctx, store: s, key: key, size: size}
+	if err := o.open(); err != nil {
+		return nil, err
+	}
+	return o, nil
+}
+
+func (s *S3Store) Delete(ctx context.Context, key string) error {
+	if err := validKey(key); err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodDelete, s.objectURL(key), nil)
+	if err != nil {
+		return err
+	}
+	resp, err := s.do(req, s3EmptyHash)
+	if errors.Is(err, ErrBlobNotFound) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	return closeBody(resp)
+}
+
+func (s *S3Store) objectURL(key string) string {
+	u := *s.endpoint
+	u.Path = u.Path + "/" + s.bucket + "/" + key
+	return u.String()
+}
+
+// do - signed request, status not 2xx - error with code of storage, 404 - ErrBlobNotFound
+func (s *S3Store) do(req *http.Request, payloadHash string) (*http.Response, error) {
+	s.sign(req, payloadHash, s.now())
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+		return resp, nil
+	}
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, s3ErrorBody))
+	if err := resp.Body.Close(); err != nil {
+		log.Printf("blob: resp.Body.Close error - %v", err)
+	}
+	if resp.StatusCode == http.StatusNotFound {
+		return nil, ErrBlobNotFound
+	}
+	return nil, fmt.Errorf("blob: %s %s - status %d %s", req.Method, req.URL.Path, resp.StatusCode, body)
+}
+
+func closeBody(resp *http.Response) error {
+	if _, err := io.Copy(io.Discard, resp.Body); err != nil {
+		return err
+	}
+	return resp.Body.Close()
+}
+
+// sign - header 'Authorization' of AWS Signature Version 4
+//
+// signed headers: host, content-type, range and x-amz-*
+func (s *S3Store) sign(req *http.Request, payloadHash string, now time.Time) {
+	amzDate := now.UTC().Format("20060102T150405Z")
+	day := amzDate[:8]
+	req.Header.Set("X-Amz-Date", amzDate)
+	req.Header.Set("X-Amz-Content-Sha256", payloadHash)
+
+	headers := map[string]string{"host": req.URL.Host}
+	for name, values := range req.Header {
+		lower := strings.ToLower(name)
+		if lower == "content-type" || lower == "range" || strings.HasPrefix(lower, "x-amz-") {
+			headers[lower] = strings.TrimSpace(strings.Join(values, ","))
+		}
+	}
+	names := make([]string, 0, len(headers))
+	for name := range headers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	canonicalHeaders := strings.Builder{}
+	for _, name := range names {
+		canonicalHeaders.WriteString(name + ":" + headers[name] + "\n")
+	}
+	signedHeaders := strings.Join(names, ";")
+
+	canonicalRequest := strings.Join([]string{
+		req.Method,
+		uriEncode(req.URL.Path, false),
+		canonicalQuery(req.URL.Query()),
+		canonicalHeaders.String(),
+		signedHeaders,
+		payloadHash,
+	}, "\n")
+	scope := day + "/" + s.region + "/s3/aws4_request"
+	stringToSign := "AWS4-HMAC-SHA256\n" + amzDate + "\n" + scope + "\n" + hexSHA256([]byte(canonicalRequest))
+
+	key := hmacSHA256([]byte("AWS4"+s.secretKey), day)
+	key = hmacSHA256(key, s.region)
+	key = hmacSHA256(key, "s3")
+	key = hmacSHA256(key, "aws4_request")
+	signature := hex.EncodeToString(hmacSHA256(key, stringToSign))
+
+	req.Header.Set("Authorization", "AWS4-HMAC-SHA256 Credential="+s.accessKey+"/"+scope+
+		", SignedHeaders="+signedHeaders+", Signature="+signature)
+}
+
+func canonicalQuery(query url.Values) string {
+	pairs := make([]string, 0, len(query))
+	for name, values := range query {
+		for _, value := range values {
+			pairs = append(pairs, uriEncode(name, true)+"="+uriEncode(value, true))
+		}
+	}
+	sort.Strings(pairs)
+	return strings.Join(pairs, "&")
+}
+
+// uriEncode - percent-encoding of SigV4, only unreserved characters are kept ('/' too for path)
+func uriEncode(value string, encodeSlash bool) string {
+	buf := strings.Builder{}
+	for i := 0; i < len(value); i++ {
+		b := value[i]
+		switch {
+		case 'A' <= b && b <= 'Z', 'a' <= b && b <= 'z', '0' <= b && b <= '9',
+			b == '-', b == '_', b == '.', b == '~':
+			buf.WriteByte(b)
+		case b == '/' && !encodeSlash:
+			buf.WriteByte(b)
+		default:
+			fmt.Fprintf(&buf, "%%%02X", b)
+		}
+	}
+	return buf.String()
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(data))
+	return mac.Sum(nil)
+}
+
+func hexSHA256(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+// s3Object - reader of object, body of GET is open from 'bodyPos' till the end,
+// 'Seek' moves 'pos' only, body is open again by 'Read' at other position
+type s3Object struct {
+	ctx     context.Context
+	store   *S3Store
+	key     string
+	size    int64
+	pos     int64
+	body    io.ReadCloser
+	bodyPos int64
+}
+
+// open - GET of object from 'pos'
+func (o *s3Object) open() error {
+	req, err := http.NewRequestWithContext(o.ctx, http.MethodGet, o.store.objectURL(o.key), nil)
+	if err != nil {
+		return err
+	}
+	if o.pos > 0 {
+		req.Header.Set("Range", fmt.Sprintf("bytes=%d-", o.pos))
+	}
+	resp, err := o.store.do(req, s3EmptyHash)
+	if err != nil {
+		return err
+	}
+	o.body = resp.Body
+	o.bodyPos = o.pos
+	return nil
+}
+
+func (o *s3Object) Read(p []byte) (int, error) {
+	if o.pos >= o.size {
+		return 0, io.EOF
+	}
+	if o.body != nil && o.bodyPos != o.pos {
+		if err := o.Close(); err != nil {
+			log.Printf("blob: body.Close error - %v", err)
+		}
+	}
+	if o.body == nil {
+		if err := o.open(); err != nil {
+			return 0, err
+		}
+	}
+	n, err := o.body.Read(p)
+	o.pos += int64(n)
+	o.bodyPos = o.pos
+	return n, err
+}
+
+func (o *s3Object) Seek(offset int64, whence int) (int64, error) {
+	pos := o.pos
+	switch whence {
+	case io.SeekStart:
+		pos = offset
+	case io.SeekCurrent:
+		pos += offset
+	case io.SeekEnd:
+		pos = o.size + offset
+	default:
+		return o.pos, errors.New("blob: invalid whence")
+	}
+	if pos < 0 {
+		return o.pos, errors.New("blob: negative position")
+	}
+	o.pos = pos
+	return pos, nil
+}
+
+func (o *s3Object) Close() error {
+	if o.body == nil {
+		return nil
+	}
+	err := o.body.Close()
+	o.body = nil
+	return err
+}
@@ -0,0 +1,71 @@
+// blob - storage of content of attachments of 'Task': local directory or S3-compatible bucket
+package blob
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/url"
+	"regexp"
+)
+
+var (
+	// ErrBlobURL - 'BLOB_URL' is not "file:///path" or "s3://key:secret@host/bucket"
+	ErrBlobURL = errors.New("unknown blob url")
+
+	// ErrBlobKey - key is not relative path of safe characters
+	ErrBlobKey = errors.New("invalid blob key")
+
+	// ErrBlobNotFound - no blob with key
+	ErrBlobNotFound = errors.New("blob not found")
+)
+
+// Info - metadata of blob for 'Put', SHA256 - hex of checksum of content (it is checked by S3)
+type Info struct {
+	Size        int64
+	ContentType string
+	SHA256      string
+}
+
+// Store - content of blobs by key, blob is immutable, new content - new key
+type Store interface {
+	// Put - write all 'Info.Size' bytes of 'r', blob is visible only after success
+	Put(ctx context.Context, key string, r io.Reader, info Info) error
+	// Open - content of blob of 'size' bytes with 'Seek' (ranges of download), missing blob - ErrBlobNotFound
+	Open(ctx context.Context, key string, size int64) (io.ReadSeekCloser, error)
+	// Delete - remove blob, missing blob is not an error
+	Delete(ctx context.Context, key string) error
+}
+
+// NewStore - store by 'BLOB_URL'
+//
+//	file:///var/lib/tasks/blobs                         - local directory
+//	s3://access:secret@host:9000/bucket?region=eu-west-1 - S3-compatible bucket (path-style),
+//	                                                       'tls=false' - http (MinIO in local network)
+func NewStore(rawURL string) (Store, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return nil, fmt.Errorf("%w - %v", ErrBlobURL, err)
+	}
+	switch u.Scheme {
+	case "file":
+		if u.Path == "" {
+			return nil, ErrBlobURL
+		}
+		return NewLocalStore(u.Path)
+	case "s3":
+		return newS3StoreFromURL(u)
+	}
+	return nil, ErrBlobURL
+}
+
+// rekey - keys are relative paths of segments without "." and ".."
+var rekey = regexp.MustCompile(`^[A-Za-z0-9_-][A-Za-z0-9._-]*(/[A-Za-z0-9_-][A-Za-z0-9._-]*)*$`)
+
+func validKey(key string) error {
+	if len(key) > 512 || !rekey.MatchString(key) {
+		return fmt.Errorf("%w - %q", ErrBlobKey, key)
+	}
+	return nil
+}
@@ -0,0 +1,93 @@
+// events - changes of 'Task' for subscribers inside the process
+package events
+
+import (
+	"sync"
+
+	"github.com/Ekvo/golang-chi-postgres-api/internal/model"
+)
+
+// SubscriptionBuffer - events waiting for a subscriber, more - subscriber is dropped
+const SubscriptionBuffer = 64
+
+// Hub - fan-out of 'model.TaskEvent' to all subscribers
+type Hub struct {
+	mu     sync.Mutex
+	subs   map[*Subscription]struct{}
+	closed bool
+}
+
+func NewHub() *Hub {
+	return &Hub{subs: map[*Subscription]struct{}{}}
+}
+
+// Subscription - events of 'Hub' after 'Subscribe'
+//
+// channel 'Events' is closed by 'Close' or when the subscriber is too slow ('Lagged' = true)
+type Subscription struct {
+	hub    *Hub
+	events chan model.TaskEvent
+	lagged bool
+}
+
+// Subscribe - new subscriber, must be closed by 'Close'
+func (h *Hub) Subscribe() *Subscription {
+	s := &Subscription{hub: h, events: make(chan model.TaskEvent, SubscriptionBuffer)}
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.closed {
+		close(s.events)
+		return s
+	}
+	h.subs[s] = struct{}{}
+	return s
+}
+
+// Close - end all subscriptions (shutdown of server), 'Publish' after 'Close' does nothing
+func (h *Hub) Close() {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.closed = true
+	for s := range h.subs {
+		h.drop(s)
+	}
+}
+
+// Publish - send event to all subscribers without waiting
+func (h *Hub) Publish(ev model.TaskEvent) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	for s := range h.subs {
+		select {
+		case s.events <- ev:
+		default:
+			s.lagged = true
+			h.drop(s)
+		}
+	}
+}
+
+// drop - call under 'mu'
+func (h *Hub) drop(s *Subscription) {
+	if _, ok := h.subs[s]; ok {
+		delete(h.subs, s)
+		close(s.events)
+	}
+}
+
+func (s *Subscription) Events() <-chan model.TaskEvent {
+	return s.events
+}
+
+// Lagged - true if subscriber was dropped by 'Publish'
+func (s *Subscription) Lagged() bool {
+	s.hub.mu.Lock()
+	defer s.hub.mu.Unlock()
+	return s.lagged
+}
+
+func (s *Subscription) Close() {
+	s.hub.mu.Lock()
+	defer s.hub.mu.Unlock()
+	s.hub.drop(s)
+}
@@ -0,0 +1,38 @@
+package events
+
+import (
+	"context"
+	"time"
+
+	"github.com/Ekvo/golang-chi-postgres-api/internal/jobs"
+	"github.com/Ekvo/golang-chi-postgres-api/internal/model"
+)
+
+// parameters of removal of old events
+const (
+	// KindPurge - periodic job of removal of events of store
+	KindPurge = "events.purge"
+	// Retention - events are kept for resume by 'Last-Event-ID'
+	Retention = 15 * time.Minute
+
+	purgeInterval = time.Minute
+)
+
+// Purger - removes events older than 'Retention', runs as periodic job of 'jobs.Pool'
+type Purger struct {
+	db model.TaskEventRetention
+}
+
+func NewPurger(db model.TaskEventRetention) *Purger {
+	return &Purger{db: db}
+}
+
+// Register - 'Purge' is periodic job of 'pool'
+func (p *Purger) Register(pool *jobs.Pool) {
+	pool.Every(KindPurge, purgeInterval, p.Purge)
+}
+
+func (p *Purger) Purge(ctx context.Context, job model.Job) error {
+	_, err := p.db.PurgeTaskEvents(ctx, Retention)
+	return err
+}
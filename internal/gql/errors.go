@@ -0,0 +1,78 @@
+// errors - errors of resolvers with 'extensions.code'
+package gql
+
+import (
+	"context"
+	"errors"
+	"log"
+
+	"github.com/Ekvo/golang-chi-postgres-api/internal/ratelimit"
+	"github.com/Ekvo/golang-chi-postgres-api/internal/servises"
+	"github.com/Ekvo/golang-chi-postgres-api/internal/source"
+	c "github.com/Ekvo/golang-chi-postgres-api/pkg/common"
+)
+
+// codes of 'extensions.code'
+const (
+	CodeBadUserInput     = "BAD_USER_INPUT"
+	CodeNotFound         = "NOT_FOUND"
+	CodeConflict         = "CONFLICT"
+	CodeUnavailable      = "UNAVAILABLE"
+	CodeInternal         = "INTERNAL_SERVER_ERROR"
+	CodeQueryTooComplex  = "QUERY_TOO_COMPLEX"
+	CodeQuotaExceeded    = "QUOTA_EXCEEDED"
+	CodeValidationFailed = "GRAPHQL_VALIDATION_FAILED"
+)
+
+// invalidParamser - error with field-level reasons (look ~> ../transport/errors.go)
+type invalidParamser interface {
+	InvalidParams() []c.InvalidParam
+}
+
+// gqlError - error of resolver, 'Extensions' are written by graphql-go
+type gqlError struct {
+	code string
+	err  error
+}
+
+func (e gqlError) Error() string {
+	if e.code == CodeInternal {
+		return "internal error"
+	}
+	return e.err.Error()
+}
+
+func (e gqlError) Unwrap() error {
+	return e.err
+}
+
+func (e gqlError) Extensions() map[string]any {
+	ext := map[string]any{"code": e.code}
+	var ip invalidParamser
+	if errors.As(e.err, &ip) {
+		ext["invalid_params"] = ip.InvalidParams()
+	}
+	return ext
+}
+
+// resolveError - the same taxonomy as HTTP status of REST, unknown error - INTERNAL_SERVER_ERROR
+func resolveError(err error) error {
+	code := CodeInternal
+	switch {
+	case errors.Is(err, ErrGQLInvalidID), errors.Is(err, ErrGQLInvalidCursor),
+		errors.Is(err, servises.ErrservisesValidatorInvalidTask), errors.Is(err, source.ErrSourceValidation):
+		code = CodeBadUserInput
+	case errors.Is(err, source.ErrSourceNotFound):
+		code = CodeNotFound
+	case errors.Is(err, source.ErrSourceConflict):
+		code = CodeConflict
+	case errors.Is(err, ratelimit.ErrQuotaExceeded):
+		code = CodeQuotaExceeded
+	case errors.Is(err, source.ErrSourceUnavailable), errors.Is(err, context.DeadlineExceeded):
+		code = CodeUnavailable
+	}
+	if code == CodeInternal {
+		log.Printf("gql: internal error - %v", err)
+	}
+	return gqlError{code: code, err: err}
+}
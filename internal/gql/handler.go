@@ -0,0 +1,90 @@
+package gql
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+
+	"github.com/graph-gophers/graphql-go"
+	gqlerrors "github.com/graph-gophers/graphql-go/errors"
+
+	"github.com/Ekvo/golang-chi-postgres-api/internal/model"
+	c "github.com/Ekvo/golang-chi-postgres-api/pkg/common"
+)
+
+// maxRequestSize - max body of GraphQL Request
+const maxRequestSize = 1 << 20
+
+var ErrGQLEmptyQuery = errors.New("empty query")
+
+// Handler - 'POST /graphql' with body {"query":"...","operationName":"...","variables":{...}}
+//
+// complexity is checked by 'checkComplexity' before execution, depth - by graphql-go ('MaxDepth'),
+// errors of query and resolvers - status 200 and "errors", broken body - 400
+type Handler struct {
+	db     model.TaskStore
+	schema *graphql.Schema
+}
+
+// NewHandler - resolvers of one level run in parallel up to 'loaderMaxBatch',
+// so 'Load' of list or aliases fills one batch of 'taskLoader'
+func NewHandler(db model.TaskStore) *Handler {
+	return &Handler{
+		db:     db,
+		schema: graphql.MustParseSchema(Schema, &resolver{db: db}, graphql.MaxDepth(MaxDepth), graphql.MaxParallelism(loaderMaxBatch)),
+	}
+}
+
+type request struct {
+	Query         string         `json:"query"`
+	OperationName string         `json:"operationName"`
+	Variables     map[string]any `json:"variables"`
+}
+
+func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	req := request{}
+	dec := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxRequestSize))
+	if err := dec.Decode(&req); err != nil {
+		writeResponse(w, http.StatusBadRequest, &graphql.Response{Errors: []*gqlerrors.QueryError{{Message: err.Error()}}})
+		return
+	}
+	if req.Query == "" {
+		writeResponse(w, http.StatusBadRequest, &graphql.Response{Errors: []*gqlerrors.QueryError{{Message: ErrGQLEmptyQuery.Error()}}})
+		return
+	}
+	doc, qErr := parseQuery(req.Query)
+	if qErr == nil {
+		qErr = checkComplexity(h.schema.ASTSchema(), doc, req.OperationName, req.Variables)
+	}
+	if qErr != nil {
+		writeResponse(w, http.StatusOK, &graphql.Response{Errors: queryErrors([]*gqlerrors.QueryError{qErr})})
+		return
+	}
+	ctx := withLoader(r.Context(), newTaskLoader(h.db))
+	resp := h.schema.Exec(ctx, req.Query, req.OperationName, req.Variables)
+	resp.Errors = queryErrors(resp.Errors)
+	writeResponse(w, http.StatusOK, resp)
+}
+
+// queryErrors - 'extensions.code' of errors of query (without path), errors of resolvers have code of 'resolveError'
+func queryErrors(errs []*gqlerrors.QueryError) []*gqlerrors.QueryError {
+	for _, qe := range errs {
+		switch {
+		case qe.Extensions != nil || qe.Path != nil:
+		case qe.Rule == ruleMaxDepth:
+			qe.Extensions = map[string]any{"code": CodeQueryTooComplex, "max_depth": MaxDepth}
+		default:
+			qe.Extensions = map[string]any{"code": CodeValidationFailed}
+		}
+	}
+	return errs
+}
+
+func writeResponse(w http.ResponseWriter, status int, resp *graphql.Response) {
+	w.Header().Set("Content-Type", c.MediaJSON)
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(resp); err != nil {
+		log.Printf("gql: encode error - %v", err)
+	}
+}
@@ -0,0 +1,184 @@
+// limits - complexity of query before execution, depth is checked by graphql-go ('graphql.MaxDepth')
+package gql
+
+import (
+	"math"
+	"strconv"
+	"strings"
+	"text/scanner"
+
+	gqlerrors "github.com/graph-gophers/graphql-go/errors"
+	"github.com/graph-gophers/graphql-go/types"
+)
+
+// limits of one operation, depth of introspection of GraphiQL is 13
+const (
+	MaxDepth      = 15
+	MaxComplexity = 1000
+)
+
+// ruleMaxDepth - rule of error of graphql-go when depth is above 'MaxDepth'
+const ruleMaxDepth = "MaxDepthExceeded"
+
+// checkComplexity - complexity of operation 'name' of 'doc', each field is 1,
+// children of field with argument 'first' are multiplied by 'first'
+//
+// unknown operation, fragment or field is not counted - it is error of validation of graphql-go
+func checkComplexity(schema *types.Schema, doc *types.ExecutableDefinition, name string, vars map[string]any) *gqlerrors.QueryError {
+	var op *types.OperationDefinition
+	if name == "" && len(doc.Operations) == 1 {
+		op = doc.Operations[0]
+	} else {
+		op = doc.Operations.Get(name)
+	}
+	if op == nil {
+		return nil
+	}
+	w := &complexity{
+		schema:    schema,
+		doc:       doc,
+		vars:      vars,
+		defaults:  map[string]types.Value{},
+		fragments: map[string]int{},
+	}
+	for _, v := range op.Vars {
+		if v.Default != nil {
+			w.defaults[v.Name.Name] = v.Default
+		}
+	}
+	cost := w.selections(op.Selections, schema.EntryPoints[strings.ToLower(string(op.Type))])
+	if cost <= MaxComplexity {
+		return nil
+	}
+	qErr := gqlerrors.Errorf("query complexity %d exceeds limit %d", cost, MaxComplexity)
+	qErr.Extensions = map[string]any{"code": CodeQueryTooComplex, "complexity": cost, "max_complexity": MaxComplexity}
+	return qErr
+}
+
+// complexity - walk of selections of one operation
+type complexity struct {
+	schema   *types.Schema
+	doc      *types.ExecutableDefinition
+	vars     map[string]any
+	defaults map[string]types.Value
+	// fragments - cost of fragment by name, -1 while fragment is walked (cycle is not counted)
+	fragments map[string]int
+}
+
+// selections - cost of 'set' of fields of type 'on' (nil - type is not known)
+func (w *complexity) selections(set types.SelectionSet, on types.NamedType) int {
+	cost := 0
+	for _, sel := range set {
+		switch s := sel.(type) {
+		case *types.Field:
+			var def *types.FieldDefinition
+			if fields := fieldsOf(on); fields != nil {
+				def = fields.Get(s.Name.Name)
+			}
+			children := 0
+			if len(s.SelectionSet) > 0 {
+				var typ types.NamedType
+				if def != nil {
+					typ = namedOf(def.Type)
+				}
+				children = w.selections(s.SelectionSet, typ)
+			}
+			cost += 1 + w.first(s, def)*children
+		case *types.InlineFragment:
+			typ := on
+			if s.On.Name != "" {
+				typ = w.schema.Types[s.On.Name]
+			}
+			cost += w.selections(s.Selections, typ)
+		case *types.FragmentSpread:
+			cost += w.fragment(s.Name.Name)
+		}
+		// big numbers of 'first' - stop before overflow
+		if cost > MaxComplexity {
+			return cost
+		}
+	}
+	return cost
+}
+
+// fragment - cost of fragment is counted once for all spreads
+func (w *complexity) fragment(name string) int {
+	if cost, ok := w.fragments[name]; ok {
+		return max(cost, 0)
+	}
+	f := w.doc.Fragments.Get(name)
+	if f == nil {
+		return 0
+	}
+	w.fragments[name] = -1
+	cost := w.selections(f.Selections, w.schema.Types[f.On.Name])
+	w.fragments[name] = cost
+	return cost
+}
+
+// first - value of argument 'first' (literal, variable, default of variable or of schema), 1 if field has no 'first'
+func (w *complexity) first(f *types.Field, def *types.FieldDefinition) int {
+	value, ok := f.Arguments.Get("first")
+	if !ok && def != nil {
+		if arg := def.Arguments.Get("first"); arg != nil {
+			value = arg.Default
+		}
+	}
+	if v, ok := value.(*types.Variable); ok {
+		if n, ok := w.vars[v.Name]; ok {
+			return count(n)
+		}
+		value = w.defaults[v.Name]
+	}
+	if lit, ok := value.(*types.PrimitiveValue); ok && lit.Type == scanner.Int {
+		n, err := strconv.ParseInt(lit.Text, 10, 64)
+		if err != nil {
+			return MaxComplexity + 1
+		}
+		return count(n)
+	}
+	return 1
+}
+
+// count - multiplier of value of variable (JSON number), not above MaxComplexity+1
+func count(v any) int {
+	var n float64
+	switch x := v.(type) {
+	case float64:
+		n = x
+	case int64:
+		n = float64(x)
+	default:
+		return 1
+	}
+	if math.IsNaN(n) || n < 1 {
+		return 1
+	}
+	return int(min(n, MaxComplexity+1))
+}
+
+func fieldsOf(t types.NamedType) types.FieldsDefinition {
+	switch x := t.(type) {
+	case *types.ObjectTypeDefinition:
+		return x.Fields
+	case *types.InterfaceTypeDefinition:
+		return x.Fields
+	}
+	return nil
+}
+
+// namedOf - type without List and NonNull
+func namedOf(t types.Type) types.NamedType {
+	for {
+		switch x := t.(type) {
+		case *types.List:
+			t = x.OfType
+		case *types.NonNull:
+			t = x.OfType
+		case types.NamedType:
+			return x
+		default:
+			return nil
+		}
+	}
+}
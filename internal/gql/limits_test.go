@@ -0,0 +1,112 @@
+package gql
+
+import (
+	"log"
+	"testing"
+
+	"github.com/graph-gophers/graphql-go"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var complexityTestData = []struct {
+	description string
+	query       string
+	vars        map[string]any
+	// complexity - 0 if query is below MaxComplexity
+	complexity int
+	msg        string
+}{
+	{
+		description: "Default of first",
+		query:       `{ tasks { nodes { id description note createdAt } edges { cursor node { id } } } }`,
+		msg:         "valid - 1 + 10 * 9",
+	},
+	{
+		description: "First of variable",
+		query:       `query($n: Int) { tasks(first: $n) { edges { node { id description note createdAt } } nodes { id description note createdAt } } }`,
+		vars:        map[string]any{"n": float64(100)},
+		complexity:  1101,
+		msg:         "invalid - children are multiplied by first",
+	},
+	{
+		description: "Default of variable",
+		query:       `query Page($n: Int = 100) { tasks(first: $n) { nodes { id description note createdAt } edges { node { id description note createdAt } } } }`,
+		complexity:  1101,
+		msg:         "invalid - default of variable is used without value",
+	},
+	{
+		description: "Fragments",
+		query: `
+# comment, commas
+query { tasks(first: 100,) { nodes { ...F } ... on TaskConnection { edges { node { ...F } } } } }
+fragment F on Task { id description note createdAt }`,
+		complexity: 1101,
+		msg:        "invalid - fields of fragment and inline fragment are counted for each spread",
+	},
+	{
+		description: "Cycle of fragments",
+		query:       `{ task(id: "1") { ...A } } fragment A on Task { id ...B } fragment B on Task { note ...A }`,
+		msg:         "valid - cycle is error of validation of graphql-go, walk ends",
+	},
+	{
+		description: "Big first",
+		query:       `{ tasks(first: 99999999999999999999) { nodes { id } } }`,
+		complexity:  2003,
+		msg:         "invalid - number out of range is not overflow",
+	},
+	{
+		description: "Operation by name",
+		query:       `query A { task(id: "1") { id } } query B { tasks(first: 100) { nodes { id description note createdAt } edges { node { id description note createdAt } } } }`,
+		vars:        map[string]any{"operationName": "A"},
+		msg:         "valid - only operation of request is counted",
+	},
+}
+
+func TestCheckComplexity(t *testing.T) {
+	asserts := assert.New(t)
+	requires := require.New(t)
+
+	schema := graphql.MustParseSchema(Schema, &resolver{}).ASTSchema()
+	for i, test := range complexityTestData {
+		log.Printf("\t %d test complexity: %s\n", i+1, test.description)
+		doc, qErr := parseQuery(test.query)
+		requires.Nil(qErr, test.msg)
+
+		name, _ := test.vars["operationName"].(string)
+		qErr = checkComplexity(schema, doc, name, test.vars)
+		if test.complexity == 0 {
+			asserts.Nil(qErr, test.msg)
+			continue
+		}
+		requires.NotNil(qErr, test.msg)
+		asserts.Equal(CodeQueryTooComplex, qErr.Extensions["code"], test.msg)
+		asserts.Equal(test.complexity, qErr.Extensions["complexity"], test.msg)
+	}
+}
+
+func TestParseQuery(t *testing.T) {
+	asserts := assert.New(t)
+	requires := require.New(t)
+
+	log.Print("\t 1 test parse query: arguments, directives and variables\n")
+	doc, qErr := parseQuery(`mutation M($in: TaskInput!, $ids: [ID!]) {
+		createTask(input: $in) @include(if: true) { id }
+		updateTask(id: "1", input: {description: "new", note: null}) { a: note }
+	}`)
+	requires.Nil(qErr)
+	requires.Len(doc.Operations, 1)
+	op := doc.Operations[0]
+	asserts.Equal("M", op.Name.Name)
+	asserts.Len(op.Vars, 2)
+	asserts.Len(op.Selections, 2)
+
+	log.Print("\t 2 test parse query: syntax error with location\n")
+	_, qErr = parseQuery("{ task(id: \"1\") {\n id ")
+	requires.NotNil(qErr)
+	asserts.Contains(qErr.Message, "syntax error")
+	asserts.Equal(2, qErr.Locations[0].Line)
+
+	_, qErr = parseQuery(`{ task(id: 'x') { id } }`)
+	asserts.NotNil(qErr, "invalid - char is not value as in graphql-go")
+}
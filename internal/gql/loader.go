@@ -0,0 +1,138 @@
+package gql
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/Ekvo/golang-chi-postgres-api/internal/model"
+	"github.com/Ekvo/golang-chi-postgres-api/internal/source"
+)
+
+// parameters of 'taskLoader'
+const (
+	// loaderWait - time to collect ids of one batch, goroutines of resolvers of one level are started in it
+	loaderWait = 5 * time.Millisecond
+	// loaderMaxBatch - ids in one 'FindTasksByID', more - batch is sent at once
+	loaderMaxBatch = 100
+)
+
+// taskLoader - DataLoader of one Request: 'Load' of parallel resolvers of any level (aliases, items of list)
+// is one 'FindTasksByID', each id is read once (cache of Request)
+//
+// graphql-go runs up to 'MaxParallelism' resolvers at once (look ~> handler.go), it is not less than 'loaderMaxBatch'
+type taskLoader struct {
+	db    model.TaskFindMany
+	mu    sync.Mutex
+	cache map[uint]*taskPromise
+	batch []uint
+}
+
+// taskPromise - result of one id, ready after 'done' is closed
+type taskPromise struct {
+	done chan struct{}
+	task model.Task
+	err  error
+}
+
+func newTaskLoader(db model.TaskFindMany) *taskLoader {
+	return &taskLoader{db: db, cache: map[uint]*taskPromise{}}
+}
+
+type loaderKey struct{}
+
+func withLoader(ctx context.Context, l *taskLoader) context.Context {
+	return context.WithValue(ctx, loaderKey{}, l)
+}
+
+func loaderFrom(ctx context.Context) *taskLoader {
+	return ctx.Value(loaderKey{}).(*taskLoader)
+}
+
+// Load - 'Task' by id, source.ErrSourceNotFound if there is no such 'Task'
+func (l *taskLoader) Load(ctx context.Context, id uint) (model.Task, error) {
+	l.mu.Lock()
+	p, ok := l.cache[id]
+	if !ok {
+		p = &taskPromise{done: make(chan struct{})}
+		l.cache[id] = p
+		l.batch = append(l.batch, id)
+		switch len(l.batch) {
+		case 1:
+			// first id - batch is sent after 'loaderWait'
+			time.AfterFunc(loaderWait, func() { l.dispatch(ctx) })
+		case loaderMaxBatch:
+			go l.dispatch(ctx)
+		}
+	}
+	l.mu.Unlock()
+
+	select {
+	case <-p.done:
+		return p.task, p.err
+	case <-ctx.Done():
+		return model.Task{}, ctx.Err()
+	}
+}
+
+// Prime - put 'Task' to cache (result of list or mutation)
+func (l *taskLoader) Prime(task model.Task) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if p, ok := l.cache[task.ID]; ok {
+		select {
+		case <-p.done:
+		default:
+			// waiting for batch - result of store will be used
+			return
+		}
+	}
+	p := &taskPromise{done: make(chan struct{}), task: task}
+	close(p.done)
+	l.cache[task.ID] = p
+}
+
+// Clear - remove id from cache (deleted 'Task')
+func (l *taskLoader) Clear(id uint) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if p, ok := l.cache[id]; ok {
+		select {
+		case <-p.done:
+			delete(l.cache, id)
+		default:
+		}
+	}
+}
+
+// dispatch - send collected ids to the store, empty batch (already sent) does nothing
+func (l *taskLoader) dispatch(ctx context.Context) {
+	l.mu.Lock()
+	ids := l.batch
+	l.batch = nil
+	promises := make([]*taskPromise, len(ids))
+	for i, id := range ids {
+		promises[i] = l.cache[id]
+	}
+	l.mu.Unlock()
+	if len(ids) == 0 {
+		return
+	}
+
+	tasks, err := l.db.FindTasksByID(ctx, ids)
+	found := make(map[uint]model.Task, len(tasks))
+	for _, task := range tasks {
+		found[task.ID] = task
+	}
+	for i, id := range ids {
+		p := promises[i]
+		if err != nil {
+			p.err = err
+		} else if task, ok := found[id]; ok {
+			p.task = task
+		} else {
+			p.err = source.ErrSourceNotFound
+		}
+		close(p.done)
+	}
+}
@@ -0,0 +1,101 @@
+package gql
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/Ekvo/golang-chi-postgres-api/internal/model"
+)
+
+// tasksMock - 'FindTasksByID' of ids up to 100, batches are written, other methods of store are not used
+type tasksMock struct {
+	model.TaskStore
+	mu      sync.Mutex
+	batches [][]uint
+}
+
+func (m *tasksMock) FindTasksByID(_ context.Context, data any) ([]model.Task, error) {
+	ids := data.([]uint)
+	m.mu.Lock()
+	m.batches = append(m.batches, ids)
+	m.mu.Unlock()
+	tasks := []model.Task{}
+	for _, id := range ids {
+		if id <= 100 {
+			tasks = append(tasks, model.Task{ID: id, Description: fmt.Sprint("task ", id), CreatedAt: time.Now().UTC()})
+		}
+	}
+	return tasks, nil
+}
+
+func TestTaskLoader(t *testing.T) {
+	asserts := assert.New(t)
+	requires := require.New(t)
+	ctx := context.Background()
+
+	log.Print("\t 1 test task loader: parallel loads are one batch, each id once\n")
+	db := &tasksMock{}
+	loader := newTaskLoader(db)
+	var wg sync.WaitGroup
+	for i := 0; i < 40; i++ {
+		wg.Add(1)
+		go func(id uint) {
+			defer wg.Done()
+			task, err := loader.Load(ctx, id)
+			if id > 100 {
+				asserts.Error(err)
+				return
+			}
+			asserts.NoError(err)
+			asserts.Equal(id, task.ID)
+		}(uint(i%20) + 90)
+	}
+	wg.Wait()
+	requires.Len(db.batches, 1)
+	asserts.Len(db.batches[0], 20)
+
+	log.Print("\t 2 test task loader: primed task is not read\n")
+	loader.Prime(model.Task{ID: 7, Description: "primed"})
+	task, err := loader.Load(ctx, 7)
+	requires.NoError(err)
+	asserts.Equal("primed", task.Description)
+	asserts.Len(db.batches, 1)
+}
+
+func TestHandlerBatch(t *testing.T) {
+	asserts := assert.New(t)
+	requires := require.New(t)
+
+	log.Print("\t 1 test handler: aliases above parallelism of graphql-go are one batch\n")
+	db := &tasksMock{}
+	h := NewHandler(db)
+	fields := make([]string, 50)
+	for i := range fields {
+		fields[i] = fmt.Sprintf(`t%d: task(id: \"%d\") { id description }`, i, i+1)
+	}
+	body := `{"query":"{ ` + strings.Join(fields, " ") + ` }"}`
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/graphql", strings.NewReader(body)))
+
+	requires.Equal(http.StatusOK, w.Code)
+	asserts.Contains(w.Body.String(), `"t49":{"id":"50","description":"task 50"}`)
+	asserts.NotContains(w.Body.String(), `"errors"`)
+	requires.Len(db.batches, 1)
+	asserts.Len(db.batches[0], 50)
+
+	log.Print("\t 2 test handler: depth of graphql-go with code of limits\n")
+	w = httptest.NewRecorder()
+	query := `{"query":"{ __schema { types { fields { type { ofType { ofType { ofType { ofType { ofType { ofType { ofType { ofType { ofType { ofType { ofType { name } } } } } } } } } } } } } } } }"}`
+	h.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/graphql", strings.NewReader(query)))
+	asserts.Contains(w.Body.String(), `"extensions":{"code":"QUERY_TOO_COMPLEX","max_depth":15}`)
+}
@@ -0,0 +1,278 @@
+// parse - document of query in AST of graphql-go (package types) for 'checkComplexity'
+package gql
+
+import (
+	"fmt"
+	"strings"
+	"text/scanner"
+
+	gqlerrors "github.com/graph-gophers/graphql-go/errors"
+	"github.com/graph-gophers/graphql-go/types"
+)
+
+// types of operation, the same values as of graphql-go
+const (
+	opQuery        types.OperationType = "QUERY"
+	opMutation     types.OperationType = "MUTATION"
+	opSubscription types.OperationType = "SUBSCRIPTION"
+)
+
+// syntaxError - panic of 'lexer', recovered by 'parseQuery'
+type syntaxError string
+
+// lexer - tokens of text/scanner with Go tokens (as lexer of graphql-go), ',' and '#' comments are skipped
+type lexer struct {
+	sc   scanner.Scanner
+	next rune
+}
+
+// parseQuery - operations and fragments of query by grammar of graphql-go,
+// parser of graphql-go is internal, the same query is parsed by 'Schema.Exec' again
+func parseQuery(query string) (doc *types.ExecutableDefinition, qErr *gqlerrors.QueryError) {
+	l := &lexer{}
+	l.sc.Init(strings.NewReader(query))
+	l.sc.Error = func(_ *scanner.Scanner, msg string) { l.fail(msg) }
+	defer func() {
+		if r := recover(); r != nil {
+			msg, ok := r.(syntaxError)
+			if !ok {
+				panic(r)
+			}
+			qErr = gqlerrors.Errorf("syntax error: %s", msg)
+			qErr.Locations = []gqlerrors.Location{l.location()}
+		}
+	}()
+
+	doc = &types.ExecutableDefinition{}
+	l.skip()
+	for l.next != scanner.EOF {
+		if l.next == '{' {
+			doc.Operations = append(doc.Operations, &types.OperationDefinition{Type: opQuery, Loc: l.location(), Selections: l.selectionSet()})
+			continue
+		}
+		loc := l.location()
+		switch word := l.ident().Name; word {
+		case "query":
+			doc.Operations = append(doc.Operations, l.operation(opQuery, loc))
+		case "mutation":
+			doc.Operations = append(doc.Operations, l.operation(opMutation, loc))
+		case "subscription":
+			doc.Operations = append(doc.Operations, l.operation(opSubscription, loc))
+		case "fragment":
+			doc.Fragments = append(doc.Fragments, l.fragment(loc))
+		default:
+			l.fail(fmt.Sprintf(`unexpected %q, expecting "fragment"`, word))
+		}
+	}
+	return doc, nil
+}
+
+func (l *lexer) fail(msg string) {
+	panic(syntaxError(msg))
+}
+
+func (l *lexer) location() gqlerrors.Location {
+	return gqlerrors.Location{Line: l.sc.Line, Column: l.sc.Column}
+}
+
+// skip - next token after commas and comments
+func (l *lexer) skip() {
+	for {
+		l.next = l.sc.Scan()
+		switch l.next {
+		case ',':
+			continue
+		case '#':
+			for ch := l.sc.Next(); ch != '\r' && ch != '\n' && ch != scanner.EOF; ch = l.sc.Next() {
+			}
+			continue
+		}
+		return
+	}
+}
+
+func (l *lexer) token(expected rune) {
+	if l.next != expected {
+		l.fail(fmt.Sprintf("unexpected %q, expecting %s", l.sc.TokenText(), scanner.TokenString(expected)))
+	}
+	l.skip()
+}
+
+func (l *lexer) ident() types.Ident {
+	id := types.Ident{Name: l.sc.TokenText(), Loc: l.location()}
+	l.token(scanner.Ident)
+	return id
+}
+
+func (l *lexer) operation(typ types.OperationType, loc gqlerrors.Location) *types.OperationDefinition {
+	op := &types.OperationDefinition{Type: typ, Loc: loc}
+	if l.next == scanner.Ident {
+		op.Name = l.ident()
+	}
+	op.Directives = l.directives()
+	if l.next == '(' {
+		l.token('(')
+		for l.next != ')' {
+			iv := &types.InputValueDefinition{Loc: l.location()}
+			l.token('$')
+			iv.Name = l.ident()
+			l.token(':')
+			iv.Type = l.typ()
+			if l.next == '=' {
+				l.token('=')
+				iv.Default = l.value()
+			}
+			iv.Directives = l.directives()
+			op.Vars = append(op.Vars, iv)
+		}
+		l.token(')')
+	}
+	op.Selections = l.selectionSet()
+	return op
+}
+
+func (l *lexer) fragment(loc gqlerrors.Location) *types.FragmentDefinition {
+	f := &types.FragmentDefinition{Loc: loc, Name: l.ident()}
+	if on := l.ident(); on.Name != "on" {
+		l.fail(fmt.Sprintf("unexpected %q, expecting %q", on.Name, "on"))
+	}
+	f.On = types.TypeName{Ident: l.ident()}
+	f.Directives = l.directives()
+	f.Selections = l.selectionSet()
+	return f
+}
+
+// typ - type of variable, the name is enough for limits
+func (l *lexer) typ() types.Type {
+	var t types.Type
+	if l.next == '[' {
+		l.token('[')
+		t = &types.List{OfType: l.typ()}
+		l.token(']')
+	} else {
+		t = &types.TypeName{Ident: l.ident()}
+	}
+	if l.next == '!' {
+		l.token('!')
+		t = &types.NonNull{OfType: t}
+	}
+	return t
+}
+
+func (l *lexer) selectionSet() types.SelectionSet {
+	var set types.SelectionSet
+	l.token('{')
+	for l.next != '}' {
+		if l.next == '.' {
+			set = append(set, l.spread())
+		} else {
+			set = append(set, l.field())
+		}
+	}
+	l.token('}')
+	return set
+}
+
+func (l *lexer) field() *types.Field {
+	f := &types.Field{Alias: l.ident()}
+	f.Name = f.Alias
+	if l.next == ':' {
+		l.token(':')
+		f.Name = l.ident()
+	}
+	if l.next == '(' {
+		f.Arguments = l.arguments()
+	}
+	f.Directives = l.directives()
+	if l.next == '{' {
+		f.SelectionSetLoc = l.location()
+		f.SelectionSet = l.selectionSet()
+	}
+	return f
+}
+
+func (l *lexer) spread() types.Selection {
+	loc := l.location()
+	l.token('.')
+	l.token('.')
+	l.token('.')
+	f := &types.InlineFragment{Loc: loc}
+	if l.next == scanner.Ident {
+		id := l.ident()
+		if id.Name != "on" {
+			return &types.FragmentSpread{Name: id, Loc: loc, Directives: l.directives()}
+		}
+		f.On = types.TypeName{Ident: l.ident()}
+	}
+	f.Directives = l.directives()
+	f.Selections = l.selectionSet()
+	return f
+}
+
+func (l *lexer) directives() types.DirectiveList {
+	var list types.DirectiveList
+	for l.next == '@' {
+		l.token('@')
+		d := &types.Directive{Name: l.ident()}
+		if l.next == '(' {
+			d.Arguments = l.arguments()
+		}
+		list = append(list, d)
+	}
+	return list
+}
+
+func (l *lexer) arguments() types.ArgumentList {
+	var args types.ArgumentList
+	l.token('(')
+	for l.next != ')' {
+		arg := &types.Argument{Name: l.ident()}
+		l.token(':')
+		arg.Value = l.value()
+		arg.Directives = l.directives()
+		args = append(args, arg)
+	}
+	l.token(')')
+	return args
+}
+
+func (l *lexer) value() types.Value {
+	loc := l.location()
+	switch l.next {
+	case '$':
+		l.token('$')
+		return &types.Variable{Name: l.ident().Name, Loc: loc}
+	case scanner.Int, scanner.Float, scanner.String, scanner.Ident:
+		lit := &types.PrimitiveValue{Type: l.next, Text: l.sc.TokenText(), Loc: loc}
+		l.skip()
+		if lit.Type == scanner.Ident && lit.Text == "null" {
+			return &types.NullValue{Loc: loc}
+		}
+		return lit
+	case '-':
+		l.token('-')
+		lit := &types.PrimitiveValue{Type: l.next, Text: "-" + l.sc.TokenText(), Loc: loc}
+		l.skip()
+		return lit
+	case '[':
+		l.token('[')
+		list := &types.ListValue{Loc: loc}
+		for l.next != ']' {
+			list.Values = append(list.Values, l.value())
+		}
+		l.token(']')
+		return list
+	case '{':
+		l.token('{')
+		obj := &types.ObjectValue{Loc: loc}
+		for l.next != '}' {
+			name := l.ident()
+			l.token(':')
+			obj.Fields = append(obj.Fields, &types.ObjectField{Name: name, Value: l.value()})
+		}
+		l.token('}')
+		return obj
+	}
+	l.fail("invalid value")
+	return nil
+}
@@ -0,0 +1,314 @@
+// gql - GraphQL endpoint over the store of 'Task'
+package gql
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"strconv"
+	"strings"
+
+	"github.com/graph-gophers/graphql-go"
+
+	"github.com/Ekvo/golang-chi-postgres-api/internal/model"
+	"github.com/Ekvo/golang-chi-postgres-api/internal/ratelimit"
+	"github.com/Ekvo/golang-chi-postgres-api/internal/servises"
+	"github.com/Ekvo/golang-chi-postgres-api/internal/source"
+)
+
+// Schema - GraphQL schema of 'Task', 'first' of 'tasks' is used by complexity (look ~> limits.go)
+const Schema = `
+schema {
+	query: Query
+	mutation: Mutation
+}
+
+scalar Time
+
+enum Order {
+	ASC
+	DESC
+}
+
+type Task {
+	id: ID!
+	description: String!
+	note: String!
+	createdAt: Time!
+	updatedAt: Time
+}
+
+type TaskEdge {
+	cursor: String!
+	node: Task!
+}
+
+type PageInfo {
+	hasNextPage: Boolean!
+	endCursor: String
+}
+
+type TaskConnection {
+	edges: [TaskEdge!]!
+	nodes: [Task!]!
+	pageInfo: PageInfo!
+}
+
+input TaskInput {
+	description: String!
+	note: String
+}
+
+type Query {
+	task(id: ID!): Task
+	tasks(first: Int = 10, after: String, order: Order = ASC): TaskConnection!
+}
+
+type Mutation {
+	createTask(input: TaskInput!): Task!
+	updateTask(id: ID!, input: TaskInput!): Task!
+	deleteTask(id: ID!): ID!
+}
+`
+
+// MaxFirst - max 'first' of 'tasks'
+const MaxFirst = 100
+
+var ErrGQLInvalidID = errors.New("invalid id")
+
+var ErrGQLInvalidCursor = errors.New("invalid cursor")
+
+// resolver - root of 'Query' and 'Mutation'
+type resolver struct {
+	db model.TaskStore
+}
+
+func (r *resolver) Task(ctx context.Context, args struct{ ID graphql.ID }) (*taskResolver, error) {
+	id, err := parseID(args.ID)
+	if err != nil {
+		return nil, resolveError(err)
+	}
+	task, err := loaderFrom(ctx).Load(ctx, id)
+	if errors.Is(err, source.ErrSourceNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, resolveError(err)
+	}
+	return &taskResolver{task: task}, nil
+}
+
+func (r *resolver) Tasks(ctx context.Context, args struct {
+	First int32
+	After *string
+	Order string
+}) (*connectionResolver, error) {
+	if args.First < 1 || args.First > MaxFirst {
+		return nil, resolveError(servises.ValidationErrors{{Name: "first", Reason: "must be from 1 to " + strconv.Itoa(MaxFirst)}})
+	}
+	offset := 0
+	if args.After != nil {
+		var err error
+		if offset, err = parseCursor(*args.After); err != nil {
+			return nil, resolveError(err)
+		}
+	}
+	// one more row - is there next page
+	tasks, err := r.db.FindTaskList(ctx, []string{
+		strings.ToLower(args.Order),
+		strconv.Itoa(int(args.First) + 1),
+		strconv.Itoa(offset),
+	})
+	if err != nil {
+		return nil, resolveError(err)
+	}
+	conn := &connectionResolver{offset: offset}
+	if len(tasks) > int(args.First) {
+		tasks = tasks[:args.First]
+		conn.hasNext = true
+	}
+	loader := loaderFrom(ctx)
+	for _, task := range tasks {
+		loader.Prime(task)
+	}
+	conn.tasks = tasks
+	return conn, nil
+}
+
+type taskInput struct {
+	Description string
+	Note        *string
+}
+
+func (ti taskInput) task() (model.Task, error) {
+	note := ""
+	if ti.Note != nil {
+		note = *ti.Note
+	}
+	return servises.TaskFromData(ti.Description, note)
+}
+
+func (r *resolver) CreateTask(ctx context.Context, args struct{ Input taskInput }) (*taskResolver, error) {
+	task, err := args.Input.task()
+	if err != nil {
+		return nil, resolveError(err)
+	}
+	// quota of request is put by transport (look ~> ../transport/ratelimit.go)
+	charge := ratelimit.FromContext(ctx)
+	if err := charge.Take(ctx, 1); err != nil {
+		return nil, resolveError(err)
+	}
+	if task.ID, err = r.db.SaveOneTask(ctx, task); err != nil {
+		if ctx.Err() == nil {
+			charge.Refund(ctx, 1)
+		}
+		return nil, resolveError(err)
+	}
+	loaderFrom(ctx).Prime(task)
+	return &taskResolver{task: task}, nil
+}
+
+func (r *resolver) UpdateTask(ctx context.Context, args struct {
+	ID    graphql.ID
+	Input taskInput
+}) (*taskResolver, error) {
+	id, err := parseID(args.ID)
+	if err != nil {
+		return nil, resolveError(err)
+	}
+	task, err := args.Input.task()
+	if err != nil {
+		return nil, resolveError(err)
+	}
+	task.ID = id
+	task.UpdatedAt = &task.CreatedAt
+	// only text of 'Task' is in 'taskInput', other values are kept
+	task, err = r.db.PatchTask(ctx, model.TextPatch(task))
+	if err != nil {
+		return nil, resolveError(err)
+	}
+	loaderFrom(ctx).Prime(task)
+	return &taskResolver{task: task}, nil
+}
+
+func (r *resolver) DeleteTask(ctx context.Context, args struct{ ID graphql.ID }) (graphql.ID, error) {
+	id, err := parseID(args.ID)
+	if err != nil {
+		return "", resolveError(err)
+	}
+	if err := r.db.EndTaskLife(ctx, id); err != nil {
+		return "", resolveError(err)
+	}
+	loaderFrom(ctx).Clear(id)
+	return args.ID, nil
+}
+
+type taskResolver struct {
+	task model.Task
+}
+
+func (t *taskResolver) ID() graphql.ID {
+	return graphql.ID(strconv.FormatUint(uint64(t.task.ID), 10))
+}
+
+func (t *taskResolver) Description() string {
+	return t.task.Description
+}
+
+func (t *taskResolver) Note() string {
+	return t.task.Note
+}
+
+func (t *taskResolver) CreatedAt() graphql.Time {
+	return graphql.Time{Time: t.task.CreatedAt}
+}
+
+func (t *taskResolver) UpdatedAt() *graphql.Time {
+	if t.task.UpdatedAt == nil {
+		return nil
+	}
+	return &graphql.Time{Time: *t.task.UpdatedAt}
+}
+
+// connectionResolver - page of 'tasks', cursor is offset after the row
+type connectionResolver struct {
+	tasks   []model.Task
+	offset  int
+	hasNext bool
+}
+
+type edgeResolver struct {
+	cursor string
+	task   model.Task
+}
+
+func (c *connectionResolver) Edges() []*edgeResolver {
+	edges := make([]*edgeResolver, len(c.tasks))
+	for i, task := range c.tasks {
+		edges[i] = &edgeResolver{cursor: cursor(c.offset + i + 1), task: task}
+	}
+	return edges
+}
+
+func (c *connectionResolver) Nodes() []*taskResolver {
+	nodes := make([]*taskResolver, len(c.tasks))
+	for i, task := range c.tasks {
+		nodes[i] = &taskResolver{task: task}
+	}
+	return nodes
+}
+
+func (c *connectionResolver) PageInfo() *pageInfoResolver {
+	pi := &pageInfoResolver{hasNext: c.hasNext}
+	if len(c.tasks) > 0 {
+		end := cursor(c.offset + len(c.tasks))
+		pi.endCursor = &end
+	}
+	return pi
+}
+
+func (e *edgeResolver) Cursor() string {
+	return e.cursor
+}
+
+func (e *edgeResolver) Node() *taskResolver {
+	return &taskResolver{task: e.task}
+}
+
+type pageInfoResolver struct {
+	hasNext   bool
+	endCursor *string
+}
+
+func (p *pageInfoResolver) HasNextPage() bool {
+	return p.hasNext
+}
+
+func (p *pageInfoResolver) EndCursor() *string {
+	return p.endCursor
+}
+
+func parseID(id graphql.ID) (uint, error) {
+	n, err := strconv.ParseUint(string(id), 10, 0)
+	if err != nil || n == 0 {
+		return 0, ErrGQLInvalidID
+	}
+	return uint(n), nil
+}
+
+// cursor - opaque value of offset
+func cursor(offset int) string {
+	return base64.RawURLEncoding.EncodeToString([]byte("offset:" + strconv.Itoa(offset)))
+}
+
+func parseCursor(c string) (int, error) {
+	line, err := base64.RawURLEncoding.DecodeString(c)
+	if err != nil {
+		return 0, ErrGQLInvalidCursor
+	}
+	offset, err := strconv.Atoi(strings.TrimPrefix(string(line), "offset:"))
+	if err != nil || offset < 0 || !strings.HasPrefix(string(line), "offset:") {
+		return 0, ErrGQLInvalidCursor
+	}
+	return offset, nil
+}
@@ -0,0 +1,100 @@
+// idempotency - responses of requests with 'Idempotency-Key' are kept and replayed on retries
+//
+// key is unique for user, fingerprint (method, URI and body) of retry must be the same as of the first request
+package idempotency
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"net/http"
+
+	"github.com/Ekvo/golang-chi-postgres-api/internal/model"
+)
+
+const (
+	// Header - key of request chosen by client
+	Header = "Idempotency-Key"
+
+	// ReplayedHeader - "true" in stored response which is sent again
+	ReplayedHeader = "Idempotent-Replayed"
+
+	// MaxKeyLen - length of key
+	MaxKeyLen = 255
+)
+
+// ErrIdempotencyKey - key is empty, too long or has not visible ASCII characters
+var ErrIdempotencyKey = errors.New("invalid idempotency key")
+
+// ValidKey - from 1 to 'MaxKeyLen' visible ASCII characters
+func ValidKey(key string) bool {
+	if key == "" || len(key) > MaxKeyLen {
+		return false
+	}
+	for i := 0; i < len(key); i++ {
+		if key[i] < '!' || key[i] > '~' {
+			return false
+		}
+	}
+	return true
+}
+
+// Fingerprint - hex of SHA-256 of method, URI and body of request
+func Fingerprint(method, uri string, body []byte) string {
+	h := sha256.New()
+	h.Write([]byte(method + " " + uri + "\n"))
+	h.Write(body)
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// Recorder - http.ResponseWriter which keeps copy of status, headers and body of response
+type Recorder struct {
+	http.ResponseWriter
+	status int
+	header http.Header
+	body   bytes.Buffer
+}
+
+func NewRecorder(w http.ResponseWriter) *Recorder {
+	return &Recorder{ResponseWriter: w}
+}
+
+func (rec *Recorder) WriteHeader(status int) {
+	if rec.status == 0 {
+		rec.status = status
+		rec.header = rec.Header().Clone()
+	}
+	rec.ResponseWriter.WriteHeader(status)
+}
+
+func (rec *Recorder) Write(b []byte) (int, error) {
+	if rec.status == 0 {
+		rec.WriteHeader(http.StatusOK)
+	}
+	rec.body.Write(b)
+	return rec.ResponseWriter.Write(b)
+}
+
+// Status - status of response, 0 - nothing is written
+func (rec *Recorder) Status() int {
+	return rec.status
+}
+
+// Record - response for 'model.IdempotencyStore.SaveIdempotentResponse' of 'claimed' key
+func (rec *Recorder) Record(claimed model.IdempotencyRecord) model.IdempotencyRecord {
+	claimed.Status = rec.status
+	claimed.Header = rec.header
+	claimed.Body = rec.body.Bytes()
+	return claimed
+}
+
+// Replay - write stored response with 'ReplayedHeader'
+func Replay(w http.ResponseWriter, record model.IdempotencyRecord) {
+	for name, values := range record.Header {
+		w.Header()[name] = append([]string(nil), values...)
+	}
+	w.Header().Set(ReplayedHeader, "true")
+	w.WriteHeader(record.Status)
+	_, _ = w.Write(record.Body)
+}
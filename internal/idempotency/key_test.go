@@ -0,0 +1,154 @@
+package idempotency
+
+import (
+	"context"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/Ekvo/golang-chi-postgres-api/internal/model"
+)
+
+var validKeyTestData = []struct {
+	description string
+	key         string
+	expected    bool
+	msg         string
+}{
+	{
+		description: "UUID",
+		key:         "9b2f6c1e-5d4a-4c1b-9f0e-2a7d3b8c6e51",
+		expected:    true,
+		msg:         "valid - visible ASCII",
+	},
+	{
+		description: "Longest key",
+		key:         strings.Repeat("k", MaxKeyLen),
+		expected:    true,
+		msg:         "valid - up to MaxKeyLen",
+	},
+	{
+		description: "Wrong key - empty",
+		key:         "",
+		msg:         "invalid - empty key",
+	},
+	{
+		description: "Wrong key - too long",
+		key:         strings.Repeat("k", MaxKeyLen+1),
+		msg:         "invalid - longer than MaxKeyLen",
+	},
+	{
+		description: "Wrong key - space",
+		key:         "order 1",
+		msg:         "invalid - space is not visible",
+	},
+	{
+		description: "Wrong key - not ASCII",
+		key:         "заказ-1",
+		msg:         "invalid - not ASCII",
+	},
+}
+
+func TestValidKey(t *testing.T) {
+	asserts := assert.New(t)
+
+	for i, test := range validKeyTestData {
+		log.Printf("\t %d test key: %s\n", i+1, test.description)
+		asserts.Equal(test.expected, ValidKey(test.key), test.msg)
+	}
+}
+
+func TestFingerprint(t *testing.T) {
+	asserts := assert.New(t)
+
+	log.Print("\t 1 test fingerprint: the same request - the same fingerprint\n")
+	first := Fingerprint(http.MethodPost, "/task/", []byte(`{"a":1}`))
+	asserts.Len(first, 64)
+	asserts.Equal(first, Fingerprint(http.MethodPost, "/task/", []byte(`{"a":1}`)))
+
+	log.Print("\t 2 test fingerprint: other method, URI or body - other fingerprint\n")
+	asserts.NotEqual(first, Fingerprint(http.MethodPut, "/task/", []byte(`{"a":1}`)))
+	asserts.NotEqual(first, Fingerprint(http.MethodPost, "/task/?a=1", []byte(`{"a":1}`)))
+	asserts.NotEqual(first, Fingerprint(http.MethodPost, "/task/", []byte(`{"a":2}`)))
+}
+
+func TestRecorderReplay(t *testing.T) {
+	asserts := assert.New(t)
+
+	log.Print("\t 1 test recorder: response is written and kept\n")
+	w := httptest.NewRecorder()
+	rec := NewRecorder(w)
+	rec.Header().Set("Content-Type", "application/json")
+	rec.WriteHeader(http.StatusCreated)
+	// headers after WriteHeader are not sent, so they are not kept
+	rec.Header().Set("X-Late", "1")
+	_, err := rec.Write([]byte(`{"task":1}`))
+	asserts.NoError(err)
+	record := rec.Record(model.IdempotencyRecord{User: "alice", Key: "k"})
+	asserts.Equal(http.StatusCreated, w.Code)
+	asserts.Equal(`{"task":1}`, w.Body.String())
+	asserts.Equal(http.StatusCreated, record.Status)
+	asserts.Equal(`{"task":1}`, string(record.Body))
+	asserts.Equal("application/json", http.Header(record.Header).Get("Content-Type"))
+	asserts.Empty(http.Header(record.Header).Get("X-Late"))
+
+	log.Print("\t 2 test recorder: Write without WriteHeader - 200\n")
+	rec = NewRecorder(httptest.NewRecorder())
+	_, err = rec.Write([]byte("ok"))
+	asserts.NoError(err)
+	asserts.Equal(http.StatusOK, rec.Status())
+
+	log.Print("\t 3 test replay: kept response with header of replay\n")
+	w = httptest.NewRecorder()
+	Replay(w, record)
+	asserts.Equal(http.StatusCreated, w.Code)
+	asserts.Equal(`{"task":1}`, w.Body.String())
+	asserts.Equal("application/json", w.Header().Get("Content-Type"))
+	asserts.Equal("true", w.Header().Get(ReplayedHeader))
+}
+
+// purgeMock - 'model.IdempotencyStore' with 'expired' keys
+type purgeMock struct {
+	expired int64
+	calls   int
+}
+
+func (m *purgeMock) ClaimIdempotencyKey(ctx context.Context, data any) (model.IdempotencyRecord, bool, error) {
+	return model.IdempotencyRecord{}, true, ctx.Err()
+}
+
+func (m *purgeMock) SaveIdempotentResponse(ctx context.Context, data any) error {
+	return ctx.Err()
+}
+
+func (m *purgeMock) ReleaseIdempotencyKey(ctx context.Context, data any) error {
+	return ctx.Err()
+}
+
+func (m *purgeMock) PurgeIdempotencyKeys(ctx context.Context, data any) (int64, error) {
+	m.calls++
+	removed := min(m.expired, int64(data.(int)))
+	m.expired -= removed
+	return removed, ctx.Err()
+}
+
+func TestPurger(t *testing.T) {
+	asserts := assert.New(t)
+	requires := require.New(t)
+
+	log.Print("\t 1 test purge: expired keys by batches\n")
+	db := &purgeMock{expired: 2*purgeBatch + 1}
+	requires.NoError(NewPurger(db).Purge(context.Background(), model.Job{}))
+	asserts.Zero(db.expired)
+	asserts.Equal(3, db.calls)
+
+	log.Print("\t 2 test purge: full last batch - one more DELETE\n")
+	db = &purgeMock{expired: purgeBatch}
+	requires.NoError(NewPurger(db).Purge(context.Background(), model.Job{}))
+	asserts.Equal(2, db.calls)
+}
@@ -0,0 +1,54 @@
+package idempotency
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"github.com/Ekvo/golang-chi-postgres-api/internal/jobs"
+	"github.com/Ekvo/golang-chi-postgres-api/internal/model"
+)
+
+// parameters of removal of expired keys
+const (
+	// KindPurge - periodic job of removal of expired keys
+	KindPurge = "idempotency.purge"
+
+	purgeInterval = 15 * time.Minute
+
+	// purgeBatch - keys of one DELETE
+	purgeBatch = 1000
+)
+
+// Purger - removes expired keys and their responses, runs as periodic job of 'jobs.Pool'
+type Purger struct {
+	db model.IdempotencyStore
+}
+
+func NewPurger(db model.IdempotencyStore) *Purger {
+	return &Purger{db: db}
+}
+
+// Register - 'Purge' is periodic job of 'pool'
+func (p *Purger) Register(pool *jobs.Pool) {
+	pool.Every(KindPurge, purgeInterval, p.Purge)
+}
+
+// Purge - expired keys by batches of 'purgeBatch' until the last one is not full
+func (p *Purger) Purge(ctx context.Context, job model.Job) error {
+	total := int64(0)
+	for {
+		removed, err := p.db.PurgeIdempotencyKeys(ctx, purgeBatch)
+		if err != nil {
+			return err
+		}
+		total += removed
+		if removed < purgeBatch {
+			break
+		}
+	}
+	if total > 0 {
+		log.Printf("idempotency: %d expired keys are removed", total)
+	}
+	return nil
+}
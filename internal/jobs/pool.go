@@ -0,0 +1,274 @@
+// jobs - background jobs of 'model.JobQueue' with pool of workers
+package jobs
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/Ekvo/golang-chi-postgres-api/internal/model"
+)
+
+// parameters of queue
+const (
+	// MaxAttempts - default attempts of job, after the last failed one job is failed
+	MaxAttempts = 10
+	// BaseBackoff - delay after first failed attempt, each next is twice longer up to 'MaxBackoff'
+	BaseBackoff = 5 * time.Second
+	MaxBackoff  = time.Hour
+	// Timeout - time of one run of 'Handler'
+	Timeout = time.Minute
+	// Retention - done and failed jobs are kept for investigation
+	Retention = 7 * 24 * time.Hour
+
+	// KindPurge - periodic job of pool, removes jobs older than 'Retention'
+	KindPurge = "jobs.purge"
+
+	// claimLease - claimed job is hidden from other pools, longer than 'Timeout'
+	claimLease    = 3 * Timeout
+	pollInterval  = time.Second
+	purgeInterval = time.Hour
+	// finishTimeout - result of job is saved after cancel of its context too
+	finishTimeout = 5 * time.Second
+)
+
+var (
+	// ErrJobsPermanent - wrap error of 'Handler' by it, job is failed without next attempts
+	ErrJobsPermanent = errors.New("permanent error")
+	ErrJobsLease     = errors.New("lease of the last attempt is expired")
+	ErrJobsPanic     = errors.New("panic of handler")
+)
+
+// Handler - run of one job, error - next attempt by 'Backoff'
+//
+// ctx is canceled after 'Timeout' or when drain of 'Pool.Shutdown' is out of time
+type Handler func(ctx context.Context, job model.Job) error
+
+// Backoff - delay after failed attempt number 'attempt' (from 1)
+func Backoff(attempt int) time.Duration {
+	delay := BaseBackoff
+	for i := 1; i < attempt && delay < MaxBackoff; i++ {
+		delay *= 2
+	}
+	return min(delay, MaxBackoff)
+}
+
+// NewJob - job of 'kind' with JSON of 'payload' which runs at once with 'MaxAttempts'
+func NewJob(kind string, payload any) (model.Job, error) {
+	job := model.Job{Kind: kind, MaxAttempts: MaxAttempts}
+	if payload == nil {
+		return job, nil
+	}
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return model.Job{}, err
+	}
+	job.Payload = body
+	return job, nil
+}
+
+// Pool - runs jobs of registered kinds by 'workers' goroutines, life cycle of 'server.Service'
+//
+// each replica has own pool, jobs are claimed by SKIP LOCKED - one job runs in one pool,
+// job of stopped replica is claimed again after 'claimLease'
+type Pool struct {
+	db       model.JobQueue
+	workers  int
+	handlers map[string]Handler
+	periodic map[string]time.Duration
+	poll     time.Duration
+	now      func() time.Time
+
+	// ctx - context of handlers, canceled when drain is out of time
+	ctx     context.Context
+	cancel  context.CancelFunc
+	free    chan struct{}
+	done    chan struct{}
+	stopped chan struct{}
+}
+
+func NewPool(db model.JobQueue, workers int) *Pool {
+	ctx, cancel := context.WithCancel(context.Background())
+	p := &Pool{
+		db:       db,
+		workers:  max(workers, 1),
+		handlers: map[string]Handler{},
+		periodic: map[string]time.Duration{},
+		poll:     pollInterval,
+		now:      time.Now,
+		ctx:      ctx,
+		cancel:   cancel,
+		free:     make(chan struct{}, 1),
+		done:     make(chan struct{}),
+		stopped:  make(chan struct{}),
+	}
+	p.Every(KindPurge, purgeInterval, func(ctx context.Context, job model.Job) error {
+		return db.PurgeJobs(ctx, Retention)
+	})
+	return p
+}
+
+// Handle - 'h' runs jobs of 'kind', call before 'Serve'
+func (p *Pool) Handle(kind string, h Handler) {
+	p.handlers[kind] = h
+}
+
+// Every - 'h' runs job of 'kind' each 'interval' in one pool of all replicas
+//
+// job has key 'kind', next one is enqueued after the previous is finished
+func (p *Pool) Every(kind string, interval time.Duration, h Handler) {
+	p.Handle(kind, h)
+	p.periodic[kind] = interval
+}
+
+// kinds - registered kinds in order of name
+func (p *Pool) kinds() []string {
+	kinds := make([]string, 0, len(p.handlers))
+	for kind := range p.handlers {
+		kinds = append(kinds, kind)
+	}
+	sort.Strings(kinds)
+	return kinds
+}
+
+// Serve - claim jobs for free workers until 'Shutdown'
+func (p *Pool) Serve() error {
+	defer close(p.stopped)
+	for _, kind := range p.kinds() {
+		if _, ok := p.periodic[kind]; ok {
+			p.schedule(kind, time.Time{})
+		}
+	}
+	var wg sync.WaitGroup
+	defer wg.Wait()
+	busy := make(chan struct{}, p.workers)
+	for {
+		next := p.poll
+		if free := p.workers - len(busy); free > 0 {
+			jobs, err := p.db.ClaimJobs(p.ctx, model.JobClaim{Kinds: p.kinds(), Limit: free, Lease: claimLease})
+			if err != nil {
+				log.Printf("jobs: claim error - %v", err)
+			}
+			for _, job := range jobs {
+				busy <- struct{}{}
+				wg.Add(1)
+				go func(job model.Job) {
+					defer wg.Done()
+					p.run(job)
+					<-busy
+					select {
+					case p.free <- struct{}{}:
+					default:
+					}
+				}(job)
+			}
+			if len(jobs) == free {
+				// there are more due jobs, next claim when a worker is free
+				next = time.Hour
+			}
+		}
+		timer := time.NewTimer(next)
+		select {
+		case <-p.done:
+			timer.Stop()
+			return nil
+		case <-p.free:
+		case <-timer.C:
+		}
+		timer.Stop()
+	}
+}
+
+// Shutdown - stop claims and wait for running jobs (drain),
+// if 'ctx' is done before, handlers are canceled and their jobs are released to the queue
+func (p *Pool) Shutdown(ctx context.Context) error {
+	close(p.done)
+	select {
+	case <-p.stopped:
+		p.cancel()
+		return nil
+	case <-ctx.Done():
+		p.cancel()
+		return ctx.Err()
+	}
+}
+
+// RunPending - one claim of due jobs for all workers, they run in parallel, returns number of claimed
+func (p *Pool) RunPending(ctx context.Context) (int, error) {
+	jobs, err := p.db.ClaimJobs(ctx, model.JobClaim{Kinds: p.kinds(), Limit: p.workers, Lease: claimLease})
+	if err != nil {
+		return 0, err
+	}
+	var wg sync.WaitGroup
+	for _, job := range jobs {
+		wg.Add(1)
+		go func(job model.Job) {
+			defer wg.Done()
+			p.run(job)
+		}(job)
+	}
+	wg.Wait()
+	return len(jobs), nil
+}
+
+// run - one attempt of 'job' and its result, next run of periodic job
+func (p *Pool) run(job model.Job) {
+	result := model.JobResult{ID: job.ID, Attempts: job.Attempts}
+	if job.Attempts > job.MaxAttempts {
+		// pool of the last attempt is stopped without result
+		result.Err = ErrJobsLease
+	} else {
+		result.Err = p.call(job)
+	}
+	switch {
+	case result.Err == nil:
+	case p.ctx.Err() != nil:
+		result.Release = true
+	case errors.Is(result.Err, ErrJobsPermanent) || errors.Is(result.Err, ErrJobsLease) ||
+		job.Attempts >= job.MaxAttempts:
+		result.Dead = true
+		log.Printf("jobs: %s %d is failed - %v", job.Kind, job.ID, result.Err)
+	default:
+		result.RunAt = p.now().Add(Backoff(job.Attempts))
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), finishTimeout)
+	defer cancel()
+	if err := p.db.FinishJob(ctx, result); err != nil {
+		log.Printf("jobs: finish of %s %d error - %v", job.Kind, job.ID, err)
+		return
+	}
+	if interval, ok := p.periodic[job.Kind]; ok && (result.Err == nil || result.Dead) {
+		p.schedule(job.Kind, p.now().Add(interval))
+	}
+}
+
+// call - 'Handler' of job with 'Timeout', panic is error of job
+func (p *Pool) call(job model.Job) (err error) {
+	h, ok := p.handlers[job.Kind]
+	if !ok {
+		return fmt.Errorf("%w - no handler of %q", ErrJobsPermanent, job.Kind)
+	}
+	ctx, cancel := context.WithTimeout(p.ctx, Timeout)
+	defer cancel()
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("%w - %v", ErrJobsPanic, r)
+		}
+	}()
+	return h(ctx, job)
+}
+
+// schedule - periodic job of 'kind' at 'runAt' (zero - now), queued one is not duplicated
+func (p *Pool) schedule(kind string, runAt time.Time) {
+	ctx, cancel := context.WithTimeout(context.Background(), finishTimeout)
+	defer cancel()
+	job := model.Job{Kind: kind, Key: kind, RunAt: runAt, MaxAttempts: MaxAttempts}
+	if _, err := p.db.EnqueueJob(ctx, job); err != nil {
+		log.Printf("jobs: schedule of %s error - %v", kind, err)
+	}
+}
@@ -0,0 +1,321 @@
+package jobs
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"sort"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/Ekvo/golang-chi-postgres-api/internal/model"
+)
+
+// queueMock - 'model.JobQueue' in memory with rules of table 'jobs', 'now' is the clock of queue
+type queueMock struct {
+	mu     sync.Mutex
+	now    time.Time
+	jobs   map[uint64]*model.Job
+	lastID uint64
+	purged int
+}
+
+func newQueueMock() *queueMock {
+	return &queueMock{now: time.Date(2025, 3, 1, 12, 0, 0, 0, time.UTC), jobs: map[uint64]*model.Job{}}
+}
+
+func (m *queueMock) clock() time.Time {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.now
+}
+
+func (m *queueMock) advance(d time.Duration) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.now = m.now.Add(d)
+}
+
+func (m *queueMock) job(id uint64) model.Job {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return *m.jobs[id]
+}
+
+func (m *queueMock) EnqueueJob(ctx context.Context, data any) (uint64, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	job := data.(model.Job)
+	if job.Key != "" {
+		for id, ex := range m.jobs {
+			if ex.Kind == job.Kind && ex.Key == job.Key && (ex.Status == model.JobQueued || ex.Status == model.JobRunning) {
+				return id, nil
+			}
+		}
+	}
+	if job.RunAt.IsZero() {
+		job.RunAt = m.now
+	}
+	m.lastID++
+	job.ID, job.Status, job.CreatedAt = m.lastID, model.JobQueued, m.now
+	m.jobs[job.ID] = &job
+	return job.ID, ctx.Err()
+}
+
+func (m *queueMock) ClaimJobs(ctx context.Context, data any) ([]model.Job, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	claim := data.(model.JobClaim)
+	var due []*model.Job
+	for _, job := range m.jobs {
+		kind := false
+		for _, k := range claim.Kinds {
+			kind = kind || k == job.Kind
+		}
+		if kind && job.Status == model.JobQueued && !job.RunAt.After(m.now) {
+			due = append(due, job)
+		}
+	}
+	sort.Slice(due, func(i, j int) bool { return due[i].ID < due[j].ID })
+	jobs := make([]model.Job, 0, claim.Limit)
+	for _, job := range due[:min(claim.Limit, len(due))] {
+		job.Status = model.JobRunning
+		job.Attempts++
+		jobs = append(jobs, *job)
+	}
+	return jobs, ctx.Err()
+}
+
+func (m *queueMock) FinishJob(ctx context.Context, data any) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	result := data.(model.JobResult)
+	job := m.jobs[result.ID]
+	if job == nil || job.Attempts != result.Attempts || job.Status != model.JobRunning {
+		return nil
+	}
+	job.Status, job.LastError = model.JobQueued, ""
+	switch {
+	case result.Err == nil:
+		job.Status = model.JobDone
+	case result.Release:
+		job.Attempts--
+	case result.Dead:
+		job.Status = model.JobFailed
+	default:
+		job.RunAt = result.RunAt
+	}
+	if result.Err != nil {
+		job.LastError = result.Err.Error()
+	}
+	return ctx.Err()
+}
+
+func (m *queueMock) PurgeJobs(ctx context.Context, data any) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.purged++
+	return ctx.Err()
+}
+
+func newTestPool(db *queueMock, workers int) *Pool {
+	p := NewPool(db, workers)
+	p.now = db.clock
+	p.poll = 10 * time.Millisecond
+	return p
+}
+
+func TestBackoff(t *testing.T) {
+	asserts := assert.New(t)
+
+	log.Print("\t 1 test backoff: doubling up to MaxBackoff\n")
+	asserts.Equal(5*time.Second, Backoff(1))
+	asserts.Equal(10*time.Second, Backoff(2))
+	asserts.Equal(40*time.Second, Backoff(4))
+	asserts.Equal(MaxBackoff, Backoff(20))
+}
+
+func TestPoolRetries(t *testing.T) {
+	asserts := assert.New(t)
+	requires := require.New(t)
+
+	db := newQueueMock()
+	p := newTestPool(db, 2)
+	calls := 0
+	p.Handle("flaky", func(ctx context.Context, job model.Job) error {
+		calls++
+		if calls < 3 {
+			return fmt.Errorf("attempt %d", calls)
+		}
+		return nil
+	})
+	p.Handle("broken", func(ctx context.Context, job model.Job) error {
+		return fmt.Errorf("%w - bad payload", ErrJobsPermanent)
+	})
+	p.Handle("panic", func(ctx context.Context, job model.Job) error {
+		panic("nil map")
+	})
+
+	flaky, err := NewJob("flaky", map[string]int{"task_id": 5})
+	requires.NoError(err)
+	asserts.Equal(`{"task_id":5}`, string(flaky.Payload))
+	flakyID, err := db.EnqueueJob(context.Background(), flaky)
+	requires.NoError(err)
+
+	log.Print("\t 1 test pool: failed attempt is queued by backoff\n")
+	n, err := p.RunPending(context.Background())
+	requires.NoError(err)
+	asserts.Equal(1, n)
+	job := db.job(flakyID)
+	asserts.Equal(model.JobQueued, job.Status)
+	asserts.Equal(db.clock().Add(Backoff(1)), job.RunAt)
+	asserts.Equal("attempt 1", job.LastError)
+
+	log.Print("\t 2 test pool: job is not due before backoff\n")
+	n, err = p.RunPending(context.Background())
+	requires.NoError(err)
+	asserts.Equal(0, n)
+
+	log.Print("\t 3 test pool: retries until success\n")
+	db.advance(Backoff(1))
+	_, err = p.RunPending(context.Background())
+	requires.NoError(err)
+	asserts.Equal(db.clock().Add(Backoff(2)), db.job(flakyID).RunAt)
+	db.advance(Backoff(2))
+	_, err = p.RunPending(context.Background())
+	requires.NoError(err)
+	job = db.job(flakyID)
+	asserts.Equal(model.JobDone, job.Status)
+	asserts.Equal(3, job.Attempts)
+
+	log.Print("\t 4 test pool: permanent error, panic and unknown kind fail at once\n")
+	brokenID, err := db.EnqueueJob(context.Background(), model.Job{Kind: "broken", MaxAttempts: MaxAttempts})
+	requires.NoError(err)
+	panicID, err := db.EnqueueJob(context.Background(), model.Job{Kind: "panic", MaxAttempts: MaxAttempts})
+	requires.NoError(err)
+	n, err = p.RunPending(context.Background())
+	requires.NoError(err)
+	asserts.Equal(2, n)
+	asserts.Equal(model.JobFailed, db.job(brokenID).Status)
+	asserts.Equal(model.JobQueued, db.job(panicID).Status, "panic is error of attempt")
+	asserts.Contains(db.job(panicID).LastError, ErrJobsPanic.Error())
+
+	log.Print("\t 5 test pool: the last attempt is failed\n")
+	lastID, err := db.EnqueueJob(context.Background(), model.Job{Kind: "panic", MaxAttempts: 1})
+	requires.NoError(err)
+	db.advance(time.Minute)
+	_, err = p.RunPending(context.Background())
+	requires.NoError(err)
+	asserts.Equal(model.JobFailed, db.job(lastID).Status)
+}
+
+func TestPoolUniqueKey(t *testing.T) {
+	asserts := assert.New(t)
+	requires := require.New(t)
+
+	db := newQueueMock()
+	p := newTestPool(db, 1)
+	p.Handle("remind", func(ctx context.Context, job model.Job) error { return nil })
+
+	log.Print("\t 1 test unique key: queued job is not duplicated\n")
+	job := model.Job{Kind: "remind", Key: "task-5", MaxAttempts: MaxAttempts}
+	first, err := db.EnqueueJob(context.Background(), job)
+	requires.NoError(err)
+	second, err := db.EnqueueJob(context.Background(), job)
+	requires.NoError(err)
+	asserts.Equal(first, second)
+
+	log.Print("\t 2 test unique key: finished job - new one\n")
+	_, err = p.RunPending(context.Background())
+	requires.NoError(err)
+	third, err := db.EnqueueJob(context.Background(), job)
+	requires.NoError(err)
+	asserts.NotEqual(first, third)
+}
+
+func TestPoolServe(t *testing.T) {
+	asserts := assert.New(t)
+	requires := require.New(t)
+
+	db := newQueueMock()
+	p := newTestPool(db, 2)
+	started := make(chan uint64, 10)
+	p.Handle("slow", func(ctx context.Context, job model.Job) error {
+		started <- job.ID
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(50 * time.Millisecond):
+			return nil
+		}
+	})
+	p.Handle("stuck", func(ctx context.Context, job model.Job) error {
+		started <- job.ID
+		<-ctx.Done()
+		return ctx.Err()
+	})
+	ids := make([]uint64, 3)
+	for i := range ids {
+		id, err := db.EnqueueJob(context.Background(), model.Job{Kind: "slow", MaxAttempts: MaxAttempts})
+		requires.NoError(err)
+		ids[i] = id
+	}
+	served := make(chan error, 1)
+	go func() { served <- p.Serve() }()
+
+	log.Print("\t 1 test serve: periodic purge is scheduled and jobs run by free workers\n")
+	for range ids {
+		select {
+		case <-started:
+		case <-time.After(time.Second):
+			t.Fatal("job is not started")
+		}
+	}
+	requires.Eventually(func() bool {
+		for _, id := range ids {
+			if db.job(id).Status != model.JobDone {
+				return false
+			}
+		}
+		return true
+	}, time.Second, 10*time.Millisecond)
+	requires.Eventually(func() bool {
+		db.mu.Lock()
+		defer db.mu.Unlock()
+		return db.purged == 1
+	}, time.Second, 10*time.Millisecond)
+	var next *model.Job
+	requires.Eventually(func() bool {
+		db.mu.Lock()
+		defer db.mu.Unlock()
+		for _, job := range db.jobs {
+			if job.Kind == KindPurge && job.Status == model.JobQueued {
+				next = job
+				return true
+			}
+		}
+		return false
+	}, time.Second, 10*time.Millisecond)
+	asserts.Equal(db.clock().Add(purgeInterval), next.RunAt, "next run of periodic job")
+
+	log.Print("\t 2 test serve: drain out of time releases job\n")
+	stuckID, err := db.EnqueueJob(context.Background(), model.Job{Kind: "stuck", MaxAttempts: MaxAttempts})
+	requires.NoError(err)
+	select {
+	case <-started:
+	case <-time.After(time.Second):
+		t.Fatal("job is not started")
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	asserts.True(errors.Is(p.Shutdown(ctx), context.DeadlineExceeded))
+	requires.NoError(<-served)
+	job := db.job(stuckID)
+	asserts.Equal(model.JobQueued, job.Status)
+	asserts.Equal(0, job.Attempts, "released attempt is not counted")
+}
@@ -3,15 +3,39 @@ package model
 
 import (
 	"context"
+	"strconv"
 	"time"
 )
 
+// Task - RemindAt is time of reminder (nil - no reminder), RemindUser - user who set it (look: TaskReminders)
+//
+// DueAt - time of current occurrence (nil - no due time), TimeZone - IANA name of zone of 'DueAt' ("" - UTC),
+// Recurrence - nil for not recurring 'Task', CustomFields - values of 'CustomField' by name (nil - none),
+// ProjectID - 'Project' of 'Task' (0 - none), Done - 'Task' is finished,
+// Status - column of board, Position - rank key inside column ("" - not ranked yet, first by id)
 type Task struct {
 	ID          uint
 	Description string
 	Note        string
 	CreatedAt   time.Time
 	UpdatedAt   *time.Time
+	RemindAt    *time.Time
+	RemindUser  string
+	DueAt       *time.Time
+	TimeZone    string
+	Recurrence  *Recurrence
+	// CustomFields - JSON values: string ('FieldString', 'FieldDate'), float64 ('FieldNumber'), bool ('FieldBoolean')
+	CustomFields map[string]any
+	ProjectID    uint
+	Done         bool
+	Status       string
+	Position     string
+}
+
+// Recurrence - RFC 5545 RRULE of 'Task', occurrences are counted from 'Start' (DTSTART) in 'Task.TimeZone'
+type Recurrence struct {
+	RRule string
+	Start time.Time
 }
 
 // TaskTables - create table in database of 'Task'
@@ -26,8 +50,656 @@ type TaskUpdate interface {
 	EndTaskLife(ctx context.Context, data any) error
 }
 
+// TaskPatch - data for 'PatchTask'
+//
+// 'Apply' gets stored 'Task' and returns changed 'Task', it is called inside the transaction
+type TaskPatch struct {
+	ID    uint
+	Apply func(task Task) (Task, error)
+}
+
+// TextPatch - 'TaskPatch' of 'Description', 'Note' and 'UpdatedAt' of 'task', other values are kept
+// (gRPC, GraphQL and batch have only text of 'Task')
+func TextPatch(task Task) TaskPatch {
+	return TaskPatch{ID: task.ID, Apply: func(stored Task) (Task, error) {
+		stored.Description = task.Description
+		stored.Note = task.Note
+		stored.UpdatedAt = task.UpdatedAt
+		return stored, nil
+	}}
+}
+
+// TaskModify - change part of 'Task'
+type TaskModify interface {
+	PatchTask(ctx context.Context, data any) (Task, error)
+}
+
+// operations of 'Batch'
+const (
+	BatchCreate = "create"
+	BatchUpdate = "update"
+	BatchDelete = "delete"
+)
+
+// BatchOperation - one change of 'Batch', for 'BatchDelete' only 'Task.ID' is used
+type BatchOperation struct {
+	Op   string
+	Task Task
+}
+
+// Batch - data for 'ExecuteBatch'
+//
+// Atomic = true - all operations or nothing, false - best effort, each operation by itself
+type Batch struct {
+	Atomic     bool
+	Operations []BatchOperation
+}
+
+// BatchResult - result of one 'BatchOperation', 'ID' of created, updated or deleted 'Task'
+type BatchResult struct {
+	ID  uint
+	Err error
+}
+
+// TaskBatch - many changes of 'Task' in one transaction
+type TaskBatch interface {
+	ExecuteBatch(ctx context.Context, data any) ([]BatchResult, error)
+}
+
+// TaskImport - data for 'ImportTasks'
+//
+// 'Next' returns next 'Task' for save, io.EOF at the end
+// DryRun = true - all rows are written and then the transaction is rolled back
+type TaskImport struct {
+	Next   func() (Task, error)
+	DryRun bool
+}
+
+// TaskImporter - save stream of 'Task' by Postgres COPY
+type TaskImporter interface {
+	ImportTasks(ctx context.Context, data any) (int, error)
+}
+
+// TaskFilter - conditions of many 'Task', nil time - no condition
+//
+// Order - "asc" or "desc" by 'ID', CreatedFrom <= created_at < CreatedTo,
+// DueOnly - only 'Task' with 'DueAt', Limit - 0 is all rows, Offset - rows skipped,
+// CustomFields - 'Task' whose custom fields contain all of these values (nil - no condition),
+// ProjectID - only 'Task' of 'Project' (0 - all), Archived - with 'Task' of archived 'Project' (hidden by default),
+// Status - only 'Task' of column ("" - all), Board - order by column and position instead of 'ID'
+type TaskFilter struct {
+	Order        string
+	CreatedFrom  *time.Time
+	CreatedTo    *time.Time
+	DueOnly      bool
+	Limit        int
+	Offset       int
+	CustomFields map[string]any
+	ProjectID    uint
+	Archived     bool
+	Status       string
+	Board        bool
+}
+
+// TaskExport - data for 'ExportTasks', 'Each' is called for every 'Task', its error stops export
+type TaskExport struct {
+	Filter TaskFilter
+	Each   func(task Task) error
+}
+
+// TaskExporter - read many 'Task' row by row without loading all of them
+type TaskExporter interface {
+	ExportTasks(ctx context.Context, data any) (int, error)
+}
+
 // TaskFind - find 'Task', 'TaskList'
 type TaskFind interface {
 	FindOneTask(ctx context.Context, data any) (Task, error)
 	FindTaskList(ctx context.Context, data any) ([]Task, error)
 }
+
+// TaskFindMany - find many 'Task' by ids in one query, missing ids are skipped
+type TaskFindMany interface {
+	FindTasksByID(ctx context.Context, data any) ([]Task, error)
+}
+
+// types of 'TaskEvent'
+const (
+	EventCreated = "created"
+	EventUpdated = "updated"
+	EventDeleted = "deleted"
+)
+
+// TaskEvent - change of 'Task', 'Task' is nil for 'EventDeleted'
+//
+// ID - number of event in store, it is taken before commit, so events are ordered by 'Cursor', not by ID
+// Tx - transaction of change
+type TaskEvent struct {
+	ID     uint64
+	Tx     uint64
+	Type   string
+	TaskID uint
+	Task   *Task
+	At     time.Time
+}
+
+// Cursor - position of event in order of commit
+func (ev TaskEvent) Cursor() EventCursor {
+	return EventCursor{Tx: ev.Tx, ID: ev.ID}
+}
+
+// EventCursor - position in stream of events, transactions are seen in stream when all older ones are ended,
+// so event with lower cursor can not come after higher one
+type EventCursor struct {
+	Tx uint64
+	ID uint64
+}
+
+func (c EventCursor) Less(other EventCursor) bool {
+	return c.Tx < other.Tx || (c.Tx == other.Tx && c.ID < other.ID)
+}
+
+// String - "tx-id", 'id' of SSE
+func (c EventCursor) String() string {
+	return strconv.FormatUint(c.Tx, 10) + "-" + strconv.FormatUint(c.ID, 10)
+}
+
+// TaskEventLog - events of 'Task' kept for a short time, for resume of stream ('data' is 'EventCursor')
+type TaskEventLog interface {
+	TaskEventsSince(ctx context.Context, data any) ([]TaskEvent, error)
+}
+
+// TaskEventRetention - removal of events older than age ('data' is time.Duration), returns number of removed
+type TaskEventRetention interface {
+	PurgeTaskEvents(ctx context.Context, data any) (int64, error)
+}
+
+// TaskStore - all work with 'Task' in store
+type TaskStore interface {
+	TaskFind
+	TaskFindMany
+	TaskUpdate
+	TaskModify
+	TaskBatch
+	TaskImporter
+	TaskExporter
+	TaskEventLog
+}
+
+// Webhook - subscription of URL to events of 'Task', empty 'Events' - all types
+//
+// body of each delivery is signed by HMAC-SHA256 with 'Secret'
+type Webhook struct {
+	ID        uint
+	URL       string
+	Events    []string
+	Secret    string
+	CreatedAt time.Time
+}
+
+// states of 'WebhookDelivery'
+const (
+	DeliveryPending   = "pending"
+	DeliverySucceeded = "succeeded"
+	DeliveryDead      = "dead"
+)
+
+// WebhookDelivery - one event for one 'Webhook' (row of outbox)
+//
+// it is saved in the transaction of change of 'Task', so no event is lost,
+// URL and Secret of 'Webhook' are filled only by 'ClaimDeliveries'
+type WebhookDelivery struct {
+	ID            uint64
+	WebhookID     uint
+	Event         TaskEvent
+	Status        string
+	Attempts      int
+	NextAttemptAt time.Time
+	// LastStatus - HTTP status of last attempt, 0 - no response
+	LastStatus int
+	LastError  string
+	UpdatedAt  *time.Time
+	URL        string
+	Secret     string
+}
+
+// DeliveryFilter - data for 'WebhookDeliveries', empty 'Status' - all, newest first
+type DeliveryFilter struct {
+	WebhookID uint
+	Status    string
+	Limit     int
+}
+
+// WebhookStore - subscriptions and log of deliveries
+type WebhookStore interface {
+	CreateWebhook(ctx context.Context, data any) (uint, error)
+	FindWebhooks(ctx context.Context) ([]Webhook, error)
+	DeleteWebhook(ctx context.Context, data any) error
+	WebhookDeliveries(ctx context.Context, data any) ([]WebhookDelivery, error)
+	// RedeliverWebhook - delivery (id is uint64) is pending again with new attempts
+	RedeliverWebhook(ctx context.Context, data any) error
+}
+
+// DeliveryClaim - data for 'ClaimDeliveries', claimed deliveries are hidden from others for 'Lease'
+type DeliveryClaim struct {
+	Limit int
+	Lease time.Duration
+}
+
+// DeliveryResult - data for 'FinishDelivery'
+//
+// Err = nil - delivered, otherwise next attempt at 'NextAttemptAt' or 'Dead'
+type DeliveryResult struct {
+	ID            uint64
+	Status        int
+	Err           error
+	NextAttemptAt time.Time
+	Dead          bool
+}
+
+// DeliveryPurge - data for 'PurgeDeliveries', age of succeeded and of dead deliveries
+type DeliveryPurge struct {
+	Succeeded time.Duration
+	Dead      time.Duration
+}
+
+// WebhookOutbox - deliveries for worker
+type WebhookOutbox interface {
+	ClaimDeliveries(ctx context.Context, data any) ([]WebhookDelivery, error)
+	FinishDelivery(ctx context.Context, data any) error
+	// PurgeDeliveries - remove finished deliveries older than age of 'DeliveryPurge', returns number of removed
+	PurgeDeliveries(ctx context.Context, data any) (int64, error)
+}
+
+// types of 'DomainEvent'
+const (
+	TaskCreated = "TaskCreated"
+	TaskUpdated = "TaskUpdated"
+	TaskDeleted = "TaskDeleted"
+)
+
+// DomainEvent - row of table 'outbox', written in the transaction of change of 'Task'
+//
+// Payload - JSON of 'Task' after change, only id for 'TaskDeleted'
+type DomainEvent struct {
+	ID         uint64
+	Type       string
+	TaskID     uint
+	Payload    []byte
+	OccurredAt time.Time
+}
+
+// OutboxRelay - data for 'RelayOutbox'
+//
+// 'Publish' is called inside the transaction which holds the events,
+// its error - events stay unpublished for the next relay (at least once)
+type OutboxRelay struct {
+	Limit   int
+	Publish func(ctx context.Context, events []DomainEvent) error
+}
+
+// OutboxPurge - data for 'PurgeOutbox', age of published and of not published events
+type OutboxPurge struct {
+	Published   time.Duration
+	Unpublished time.Duration
+}
+
+// TaskOutbox - relay of unpublished 'DomainEvent' in order of id
+type TaskOutbox interface {
+	RelayOutbox(ctx context.Context, data any) (int, error)
+	// PurgeOutbox - remove events older than age of 'OutboxPurge'
+	PurgeOutbox(ctx context.Context, data any) error
+}
+
+// states of 'Job'
+const (
+	JobQueued  = "queued"
+	JobRunning = "running"
+	JobDone    = "done"
+	JobFailed  = "failed"
+)
+
+// Job - row of table 'jobs', background work of 'Kind' with JSON 'Payload'
+//
+// Key - unique key of queued or running job of one 'Kind', empty - no uniqueness,
+// Attempts - number of claims with the current one
+type Job struct {
+	ID          uint64
+	Kind        string
+	Key         string
+	Payload     []byte
+	RunAt       time.Time
+	Status      string
+	Attempts    int
+	MaxAttempts int
+	LastError   string
+	CreatedAt   time.Time
+	UpdatedAt   *time.Time
+}
+
+// JobClaim - data for 'ClaimJobs', claimed jobs are running for 'Lease', after it they are claimed again
+type JobClaim struct {
+	Kinds []string
+	Limit int
+	Lease time.Duration
+}
+
+// JobResult - data for 'FinishJob', 'Attempts' of claim - result of stale claim is skipped
+//
+// Err is nil - done, Dead - failed, other - queued again at 'RunAt',
+// Release - job is stopped by shutdown, it is queued at once and attempt is not counted
+type JobResult struct {
+	ID       uint64
+	Attempts int
+	Err      error
+	RunAt    time.Time
+	Dead     bool
+	Release  bool
+}
+
+// JobQueue - durable queue of background jobs
+type JobQueue interface {
+	// EnqueueJob - id of new job, if job of the same Kind and Key is queued or running - its id
+	EnqueueJob(ctx context.Context, data any) (uint64, error)
+	ClaimJobs(ctx context.Context, data any) ([]Job, error)
+	FinishJob(ctx context.Context, data any) error
+	// PurgeJobs - remove done and failed jobs older than age ('data' is time.Duration)
+	PurgeJobs(ctx context.Context, data any) error
+}
+
+// ReminderScan - data for 'DueReminders', 'Task' with 'RemindAt' in (now - Window, now]
+// which is not sent to all 'Channels', in order of 'RemindAt' and id, pages by 'After' and 'AfterID'
+type ReminderScan struct {
+	Channels []string
+	Window   time.Duration
+	After    time.Time
+	AfterID  uint
+	Limit    int
+}
+
+// ReminderClaim - data for 'ClaimReminder' and 'ReleaseReminder', reminder of 'Task' for one channel
+type ReminderClaim struct {
+	TaskID   uint
+	RemindAt time.Time
+	Channel  string
+}
+
+// TaskReminders - due reminders and their deduplication
+type TaskReminders interface {
+	DueReminders(ctx context.Context, data any) ([]Task, error)
+	// ClaimReminder - true if reminder is not claimed before, claims are kept after restart
+	ClaimReminder(ctx context.Context, data any) (bool, error)
+	// ReleaseReminder - remove claim after failed send, next scan sends it again
+	ReleaseReminder(ctx context.Context, data any) error
+}
+
+// RecurrenceScan - data for 'RecurringTasks', recurring 'Task' with 'DueAt' before 'Due', pages by 'AfterID'
+type RecurrenceScan struct {
+	Due     time.Time
+	AfterID uint
+	Limit   int
+}
+
+// TaskRecurrences - recurring 'Task' whose period may be elapsed, they are moved by 'TaskModify'
+type TaskRecurrences interface {
+	RecurringTasks(ctx context.Context, data any) ([]Task, error)
+}
+
+// TimeEntry - time spent on 'Task' by 'User' from 'StartAt' till 'StopAt'
+//
+// StopAt = nil - running timer, each user has one running timer at most
+type TimeEntry struct {
+	ID        uint64
+	TaskID    uint
+	User      string
+	StartAt   time.Time
+	StopAt    *time.Time
+	Note      string
+	CreatedAt time.Time
+}
+
+// Duration - time of entry, running timer - till 'now'
+func (e TimeEntry) Duration(now time.Time) time.Duration {
+	if e.StopAt != nil {
+		return e.StopAt.Sub(e.StartAt)
+	}
+	return now.Sub(e.StartAt)
+}
+
+// TimerStop - data for 'StopTimer', running timer of 'User' on 'Task' is stopped at 'At'
+type TimerStop struct {
+	TaskID uint
+	User   string
+	At     time.Time
+}
+
+// TimeReport - data for 'TimeTotals', nil time and empty fields - no condition
+//
+// From <= start of entry < To, day of entry is the day of its start in 'TimeZone' ("" - zone of each 'Task')
+type TimeReport struct {
+	TaskIDs  []uint
+	User     string
+	From     *time.Time
+	To       *time.Time
+	TimeZone string
+}
+
+// TimeTotal - time spent on 'Task' in one day, Day - date ("2006-01-02"), running timers are counted till now
+type TimeTotal struct {
+	TaskID   uint
+	Day      string
+	Duration time.Duration
+}
+
+// TimeTracker - entries of time spent on 'Task' and their totals
+type TimeTracker interface {
+	// StartTimer - running entry of user, other running timer of the same user - conflict
+	StartTimer(ctx context.Context, data any) (TimeEntry, error)
+	StopTimer(ctx context.Context, data any) (TimeEntry, error)
+	// SaveTimeEntry - manual entry with 'StopAt'
+	SaveTimeEntry(ctx context.Context, data any) (TimeEntry, error)
+	// TimeEntries - entries of 'Task' (id is uint) in order of start
+	TimeEntries(ctx context.Context, data any) ([]TimeEntry, error)
+	// TimeTotals - totals by 'Task' and day in order of 'Task' and day
+	TimeTotals(ctx context.Context, data any) ([]TimeTotal, error)
+}
+
+// KindBlobCleanup - job of removal of blobs, it is enqueued by store with delete of 'Attachment' or of its 'Task'
+const KindBlobCleanup = "blobs.cleanup"
+
+// Attachment - file of 'Task', content is blob of 'Key' in blob store, SHA256 - hex of checksum of content
+type Attachment struct {
+	ID          uint64
+	TaskID      uint
+	Name        string
+	ContentType string
+	Size        int64
+	SHA256      string
+	Key         string
+	User        string
+	CreatedAt   time.Time
+}
+
+// AttachmentRef - data for 'FindAttachment' and 'DeleteAttachment', attachment of one 'Task'
+type AttachmentRef struct {
+	TaskID uint
+	ID     uint64
+}
+
+// AttachmentStore - metadata of attachments, their blobs are removed by job 'KindBlobCleanup'
+type AttachmentStore interface {
+	// SaveAttachment - attachment of existing 'Task', unknown 'Task' - not found
+	SaveAttachment(ctx context.Context, data any) (Attachment, error)
+	FindAttachment(ctx context.Context, data any) (Attachment, error)
+	// TaskAttachments - attachments of 'Task' (id is uint) in order of id, unknown 'Task' - not found
+	TaskAttachments(ctx context.Context, data any) ([]Attachment, error)
+	DeleteAttachment(ctx context.Context, data any) error
+}
+
+// types of 'CustomField'
+const (
+	FieldString  = "string"
+	FieldNumber  = "number"
+	FieldBoolean = "boolean"
+	// FieldDate - string "2006-01-02"
+	FieldDate = "date"
+)
+
+// CustomField - schema of one custom field of 'Task', defined by admin
+//
+// Allowed - values of 'FieldString' (string) or 'FieldNumber' (float64), empty - any value of the type
+type CustomField struct {
+	Name      string
+	Type      string
+	Required  bool
+	Allowed   []any
+	CreatedAt time.Time
+	UpdatedAt *time.Time
+}
+
+// CustomFieldStore - registry of custom fields, values of 'Task' are checked by it on write
+type CustomFieldStore interface {
+	// SaveCustomField - create or replace 'CustomField' by 'Name'
+	SaveCustomField(ctx context.Context, data any) (CustomField, error)
+	// CustomFields - all fields in order of name
+	CustomFields(ctx context.Context) ([]CustomField, error)
+	// DeleteCustomField - field by name, values of 'Task' are kept and skipped on next write of 'Task'
+	DeleteCustomField(ctx context.Context, data any) error
+}
+
+// Project - group of 'Task', 'Position' - place in list of projects,
+// archived 'Project' hides its 'Task' from default listings (look: TaskFilter.Archived)
+type Project struct {
+	ID          uint
+	Name        string
+	Description string
+	Archived    bool
+	Position    int
+	CreatedAt   time.Time
+	UpdatedAt   *time.Time
+	// Open, Done - counts of 'Task' of 'Project', only for reading
+	Open int
+	Done int
+}
+
+// ProjectList - data for 'FindProjects', Archived - with archived 'Project'
+type ProjectList struct {
+	Archived bool
+}
+
+// ProjectStore - projects with counts of their 'Task', 'Task' of deleted 'Project' have no project
+type ProjectStore interface {
+	SaveProject(ctx context.Context, data any) (Project, error)
+	// UpdateProject - name, description, archived and position of 'Project' by 'ID'
+	UpdateProject(ctx context.Context, data any) (Project, error)
+	DeleteProject(ctx context.Context, data any) error
+	FindProject(ctx context.Context, data any) (Project, error)
+	// FindProjects - in order of 'Position' and 'ID'
+	FindProjects(ctx context.Context, data any) ([]Project, error)
+}
+
+// StatusDefault - column of new 'Task'
+const StatusDefault = "todo"
+
+// TaskMove - data for 'MoveTask', 'Task' goes to column 'Status' ("" - its column) between anchors
+//
+// After, Before - 'Task' of column next to which 'Task' is placed (0 - none, both 0 - end of column),
+// Rank - key between keys of neighbours ("" - edge of column), Keys - 'n' new keys of column
+// if neighbours have equal keys or key is too long
+type TaskMove struct {
+	ID        uint
+	Status    string
+	After     uint
+	Before    uint
+	UpdatedAt time.Time
+	Rank      func(after, before string) (string, error)
+	Keys      func(n int) []string
+}
+
+// RankRebalance - data for 'RebalancePositions', columns with key longer than 'MaxLen',
+// not ranked or equal keys get 'Keys'
+type RankRebalance struct {
+	MaxLen int
+	Keys   func(n int) []string
+}
+
+// TaskBoard - order of 'Task' in columns of board
+type TaskBoard interface {
+	// MoveTask - change of one row, returns moved 'Task'
+	MoveTask(ctx context.Context, data any) (Task, error)
+	// RebalancePositions - returns number of rebalanced columns
+	RebalancePositions(ctx context.Context, data any) (int, error)
+}
+
+// IdempotencyClaim - data for 'ClaimIdempotencyKey', request of 'User' with 'Key' and hex of its 'Fingerprint',
+// key is kept for 'TTL', request in flight is not overtaken during 'Lease'
+type IdempotencyClaim struct {
+	User        string
+	Key         string
+	Fingerprint string
+	TTL         time.Duration
+	Lease       time.Duration
+}
+
+// IdempotencyRecord - key of 'User' and response of its request, 'Status' 0 - request is in flight
+type IdempotencyRecord struct {
+	User        string
+	Key         string
+	Fingerprint string
+	Status      int
+	Header      map[string][]string
+	Body        []byte
+	CreatedAt   time.Time
+	ExpiresAt   time.Time
+}
+
+// IdempotencyStore - responses of requests with 'Idempotency-Key' for their retries
+type IdempotencyStore interface {
+	// ClaimIdempotencyKey - true if key is new, expired or its request in flight is abandoned,
+	// false - stored record of key
+	ClaimIdempotencyKey(ctx context.Context, data any) (IdempotencyRecord, bool, error)
+	// SaveIdempotentResponse - response of claimed key ('data' is IdempotencyRecord)
+	SaveIdempotentResponse(ctx context.Context, data any) error
+	// ReleaseIdempotencyKey - remove claim of failed request, retry runs it again ('data' is IdempotencyRecord)
+	ReleaseIdempotencyKey(ctx context.Context, data any) error
+	// PurgeIdempotencyKeys - remove up to 'data' (int) expired keys, returns number of removed
+	PurgeIdempotencyKeys(ctx context.Context, data any) (int64, error)
+}
+
+// RateTake - data for 'TakeRate', one request of 'Key' by GCRA (token bucket):
+// one token for 'Interval', up to 'Burst' tokens at once
+type RateTake struct {
+	Key      string
+	Interval time.Duration
+	Burst    int
+}
+
+// RateState - result of 'TakeRate', TAT - theoretical arrival time of 'Key' after request,
+// Now - time of store, Allowed - token is taken
+type RateState struct {
+	Allowed bool
+	TAT     time.Time
+	Now     time.Time
+}
+
+// QuotaTake - data for 'TakeQuota', 'Count' (negative - return) of 'Key' in 'Day' up to 'Limit'
+type QuotaTake struct {
+	Key   string
+	Day   time.Time
+	Count int
+	Limit int
+}
+
+// QuotaState - result of 'TakeQuota', Used - count of 'Day' after take, Allowed - 'Count' is taken
+type QuotaState struct {
+	Allowed bool
+	Used    int
+}
+
+// RateLimiter - token buckets and daily quotas of clients, each take is atomic
+type RateLimiter interface {
+	TakeRate(ctx context.Context, data any) (RateState, error)
+	TakeQuota(ctx context.Context, data any) (QuotaState, error)
+	// PurgeRateLimits - remove full buckets and quotas of past days ('data' is time.Time - now)
+	PurgeRateLimits(ctx context.Context, data any) error
+}
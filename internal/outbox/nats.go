@@ -0,0 +1,193 @@
+// outbox - publisher of NATS text protocol without client library
+package outbox
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+// natsTimeout - dial and one 'Publish' when context has no deadline
+const natsTimeout = 10 * time.Second
+
+var (
+	ErrOutboxNATS        = errors.New("nats error")
+	ErrOutboxNATSPayload = errors.New("nats max payload exceeded")
+)
+
+// natsInfo - fields of INFO of server which are used
+type natsInfo struct {
+	Headers    bool `json:"headers"`
+	MaxPayload int  `json:"max_payload"`
+}
+
+// NATSPublisher - PUB (HPUB with 'Nats-Msg-Id' if server supports headers) of each message,
+// then PING - PONG confirms that server has read all of them, -ERR before PONG - error of 'Publish'
+//
+// guarantee is 'read by server', not 'stored': PubAck of JetStream is not awaited,
+// message without subscriber or lost by crash of server before it is stored is not sent again;
+// connection is opened on first 'Publish' and opened again after error
+type NATSPublisher struct {
+	addr string
+	user *url.Userinfo
+	mu   sync.Mutex
+	conn net.Conn
+	r    *bufio.Reader
+	info natsInfo
+}
+
+func NewNATSPublisher(addr string) *NATSPublisher {
+	return &NATSPublisher{addr: addr}
+}
+
+// WithUser - credentials of CONNECT: name and password or token (name without password), nil - without them
+func (p *NATSPublisher) WithUser(user *url.Userinfo) *NATSPublisher {
+	p.user = user
+	return p
+}
+
+func (p *NATSPublisher) Publish(ctx context.Context, msgs []Message) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if err := p.publish(ctx, msgs); err != nil {
+		p.drop()
+		return err
+	}
+	return nil
+}
+
+func (p *NATSPublisher) publish(ctx context.Context, msgs []Message) error {
+	deadline, ok := ctx.Deadline()
+	if !ok {
+		deadline = time.Now().Add(natsTimeout)
+	}
+	if p.conn == nil {
+		if err := p.connect(ctx, deadline); err != nil {
+			return err
+		}
+	}
+	if err := p.conn.SetDeadline(deadline); err != nil {
+		return err
+	}
+	w := bufio.NewWriter(p.conn)
+	for _, msg := range msgs {
+		if p.info.MaxPayload > 0 && len(msg.Body) > p.info.MaxPayload {
+			return fmt.Errorf("%w - message %s", ErrOutboxNATSPayload, msg.ID)
+		}
+		if p.info.Headers {
+			header := "NATS/1.0\r\nNats-Msg-Id: " + msg.ID + "\r\n\r\n"
+			fmt.Fprintf(w, "HPUB %s %d %d\r\n%s", msg.Subject, len(header), len(header)+len(msg.Body), header)
+		} else {
+			fmt.Fprintf(w, "PUB %s %d\r\n", msg.Subject, len(msg.Body))
+		}
+		w.Write(msg.Body)
+		w.WriteString("\r\n")
+	}
+	w.WriteString("PING\r\n")
+	if err := w.Flush(); err != nil {
+		return err
+	}
+	return p.waitPong()
+}
+
+// connect - INFO of server, CONNECT and PING - PONG
+func (p *NATSPublisher) connect(ctx context.Context, deadline time.Time) error {
+	dialer := net.Dialer{Deadline: deadline}
+	conn, err := dialer.DialContext(ctx, "tcp", p.addr)
+	if err != nil {
+		return err
+	}
+	p.conn, p.r = conn, bufio.NewReader(conn)
+	if err := conn.SetDeadline(deadline); err != nil {
+		return err
+	}
+	first, err := p.r.ReadString('\n')
+	if err != nil {
+		return err
+	}
+	op, args, _ := strings.Cut(strings.TrimRight(first, "\r\n"), " ")
+	if !strings.EqualFold(op, "INFO") {
+		return fmt.Errorf("%w - expected INFO, got %q", ErrOutboxNATS, op)
+	}
+	p.info = natsInfo{}
+	if err := json.Unmarshal([]byte(args), &p.info); err != nil {
+		return fmt.Errorf("%w - INFO - %v", ErrOutboxNATS, err)
+	}
+	options := map[string]any{
+		"verbose":  false,
+		"pedantic": false,
+		"headers":  p.info.Headers,
+		"name":     "task-outbox",
+		"lang":     "go",
+		"version":  "1",
+	}
+	if p.user != nil {
+		if pass, ok := p.user.Password(); ok {
+			options["user"], options["pass"] = p.user.Username(), pass
+		} else {
+			options["auth_token"] = p.user.Username()
+		}
+	}
+	connect, err := json.Marshal(options)
+	if err != nil {
+		return err
+	}
+	if _, err := fmt.Fprintf(conn, "CONNECT %s\r\nPING\r\n", connect); err != nil {
+		return err
+	}
+	return p.waitPong()
+}
+
+// waitPong - read until PONG, answer PING of server, -ERR is error
+func (p *NATSPublisher) waitPong() error {
+	for {
+		text, err := p.r.ReadString('\n')
+		if err != nil {
+			return err
+		}
+		text = strings.TrimRight(text, "\r\n")
+		op, args, _ := strings.Cut(text, " ")
+		switch strings.ToUpper(op) {
+		case "PONG":
+			return nil
+		case "PING":
+			if _, err := p.conn.Write([]byte("PONG\r\n")); err != nil {
+				return err
+			}
+		case "-ERR":
+			return fmt.Errorf("%w - %s", ErrOutboxNATS, strings.Trim(args, "'"))
+		case "+OK", "INFO":
+		default:
+			return fmt.Errorf("%w - unexpected %q", ErrOutboxNATS, text)
+		}
+	}
+}
+
+func (p *NATSPublisher) drop() {
+	if p.conn == nil {
+		return
+	}
+	if err := p.conn.Close(); err != nil {
+		log.Printf("outbox: nats conn.Close error - %v", err)
+	}
+	p.conn, p.r = nil, nil
+}
+
+func (p *NATSPublisher) Close() error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.conn == nil {
+		return nil
+	}
+	err := p.conn.Close()
+	p.conn, p.r = nil, nil
+	return err
+}
@@ -0,0 +1,36 @@
+package outbox
+
+import (
+	"context"
+	"time"
+
+	"github.com/Ekvo/golang-chi-postgres-api/internal/jobs"
+	"github.com/Ekvo/golang-chi-postgres-api/internal/model"
+)
+
+// parameters of removal of old events
+const (
+	// KindPurge - periodic job of removal of events of 'outbox'
+	KindPurge = "outbox.purge"
+
+	purgeInterval = time.Hour
+)
+
+// Purger - removes published events after 'Retention' and not published after 'UnpublishedRetention',
+// runs as periodic job of 'jobs.Pool' with relay and without it ('OUTBOX_URL' is empty)
+type Purger struct {
+	db model.TaskOutbox
+}
+
+func NewPurger(db model.TaskOutbox) *Purger {
+	return &Purger{db: db}
+}
+
+// Register - 'Purge' is periodic job of 'pool'
+func (p *Purger) Register(pool *jobs.Pool) {
+	pool.Every(KindPurge, purgeInterval, p.Purge)
+}
+
+func (p *Purger) Purge(ctx context.Context, job model.Job) error {
+	return p.db.PurgeOutbox(ctx, model.OutboxPurge{Published: Retention, Unpublished: UnpublishedRetention})
+}
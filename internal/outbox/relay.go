@@ -0,0 +1,128 @@
+// outbox - relay of table 'outbox' to 'Publisher'
+package outbox
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"strconv"
+	"time"
+
+	"github.com/Ekvo/golang-chi-postgres-api/internal/model"
+	vr "github.com/Ekvo/golang-chi-postgres-api/internal/variables"
+)
+
+// parameters of relay
+const (
+	// SubjectPrefix - subject of message is prefix + type of event ("tasks.TaskCreated")
+	SubjectPrefix = "tasks."
+	// Retention - published events are kept for investigation
+	Retention = 24 * time.Hour
+	// UnpublishedRetention - events which are not published (relay is off or broken) are removed after it
+	UnpublishedRetention = 7 * 24 * time.Hour
+
+	relayLimit = 100
+	relayPoll  = time.Second
+)
+
+// Envelope - body of message
+type Envelope struct {
+	ID         uint64          `json:"id"`
+	Type       string          `json:"type"`
+	TaskID     uint            `json:"task_id"`
+	OccurredAt string          `json:"occurred_at"`
+	Data       json.RawMessage `json:"data"`
+}
+
+// NewMessage - 'Message' of 'model.DomainEvent'
+func NewMessage(ev model.DomainEvent) (Message, error) {
+	body, err := json.Marshal(Envelope{
+		ID:         ev.ID,
+		Type:       ev.Type,
+		TaskID:     ev.TaskID,
+		OccurredAt: ev.OccurredAt.UTC().Format(vr.RFC3339Milli),
+		Data:       ev.Payload,
+	})
+	if err != nil {
+		return Message{}, err
+	}
+	return Message{
+		ID:      strconv.FormatUint(ev.ID, 10),
+		Subject: SubjectPrefix + ev.Type,
+		Key:     strconv.FormatUint(uint64(ev.TaskID), 10),
+		Body:    body,
+	}, nil
+}
+
+// Relay - publish events of 'model.TaskOutbox' by 'Publisher', life cycle of 'server.Service'
+//
+// events are marked as published only after 'Publish' returns nil - at least once up to 'Publisher'
+// (look at guarantee of each one), consumers use 'Message.ID' for deduplication; old events are removed by 'Purger'
+type Relay struct {
+	db      model.TaskOutbox
+	pub     Publisher
+	poll    time.Duration
+	done    chan struct{}
+	stopped chan struct{}
+}
+
+func NewRelay(db model.TaskOutbox, pub Publisher) *Relay {
+	return &Relay{
+		db:      db,
+		pub:     pub,
+		poll:    relayPoll,
+		done:    make(chan struct{}),
+		stopped: make(chan struct{}),
+	}
+}
+
+// Serve - relay until 'Shutdown', full batch - next one at once
+func (r *Relay) Serve() error {
+	defer close(r.stopped)
+	timer := time.NewTimer(0)
+	defer timer.Stop()
+	for {
+		select {
+		case <-r.done:
+			return nil
+		case <-timer.C:
+		}
+		next := r.poll
+		n, err := r.RelayOnce(context.Background())
+		if err != nil {
+			log.Printf("outbox: relay error - %v", err)
+		} else if n == relayLimit {
+			next = 0
+		}
+		timer.Reset(next)
+	}
+}
+
+// RelayOnce - one batch of unpublished events, returns number of published
+func (r *Relay) RelayOnce(ctx context.Context) (int, error) {
+	return r.db.RelayOutbox(ctx, model.OutboxRelay{
+		Limit: relayLimit,
+		Publish: func(ctx context.Context, events []model.DomainEvent) error {
+			msgs := make([]Message, len(events))
+			for i, ev := range events {
+				msg, err := NewMessage(ev)
+				if err != nil {
+					return err
+				}
+				msgs[i] = msg
+			}
+			return r.pub.Publish(ctx, msgs)
+		},
+	})
+}
+
+// Shutdown - stop 'Serve' after current batch and close 'Publisher'
+func (r *Relay) Shutdown(ctx context.Context) error {
+	close(r.done)
+	select {
+	case <-r.stopped:
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+	return r.pub.Close()
+}
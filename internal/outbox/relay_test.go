@@ -0,0 +1,322 @@
+package outbox
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/Ekvo/golang-chi-postgres-api/internal/model"
+)
+
+// outboxMock - events are published in order of id, failed 'Publish' keeps them
+type outboxMock struct {
+	mu     sync.Mutex
+	events []model.DomainEvent
+	purged int
+}
+
+func (m *outboxMock) RelayOutbox(ctx context.Context, data any) (int, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	relay := data.(model.OutboxRelay)
+	n := min(relay.Limit, len(m.events))
+	if n == 0 {
+		return 0, nil
+	}
+	if err := relay.Publish(ctx, m.events[:n]); err != nil {
+		return 0, err
+	}
+	m.events = m.events[n:]
+	return n, nil
+}
+
+func (m *outboxMock) PurgeOutbox(ctx context.Context, data any) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.purged++
+	return ctx.Err()
+}
+
+// natsMsg - message received by 'natsStandIn'
+type natsMsg struct {
+	subject string
+	msgID   string
+	body    string
+}
+
+// natsStandIn - in-process server of NATS text protocol, subject "tasks.reject" gets -ERR,
+// 'auth' - "user:pass" or token of CONNECT, empty - without authorization
+type natsStandIn struct {
+	ln   net.Listener
+	auth string
+	mu   sync.Mutex
+	msgs []natsMsg
+}
+
+func newNATSStandIn(t *testing.T, auth string) *natsStandIn {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	s := &natsStandIn{ln: ln, auth: auth}
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go s.serve(conn)
+		}
+	}()
+	t.Cleanup(func() { _ = ln.Close() })
+	return s
+}
+
+func (s *natsStandIn) received() []natsMsg {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]natsMsg(nil), s.msgs...)
+}
+
+func (s *natsStandIn) serve(conn net.Conn) {
+	defer conn.Close()
+	r := bufio.NewReader(conn)
+	if _, err := io.WriteString(conn, `INFO {"server_id":"stand-in","headers":true,"max_payload":1024}`+"\r\n"); err != nil {
+		return
+	}
+	for {
+		text, err := r.ReadString('\n')
+		if err != nil {
+			return
+		}
+		fields := strings.Fields(text)
+		if len(fields) == 0 {
+			continue
+		}
+		switch fields[0] {
+		case "CONNECT":
+			options := struct {
+				User  string `json:"user"`
+				Pass  string `json:"pass"`
+				Token string `json:"auth_token"`
+			}{}
+			if err := json.Unmarshal([]byte(strings.TrimPrefix(strings.TrimSpace(text), "CONNECT ")), &options); err != nil {
+				return
+			}
+			if s.auth != "" && s.auth != options.Token && s.auth != options.User+":"+options.Pass {
+				_, _ = io.WriteString(conn, "-ERR 'Authorization Violation'\r\n")
+				return
+			}
+		case "PING":
+			if _, err := io.WriteString(conn, "PONG\r\n"); err != nil {
+				return
+			}
+		case "HPUB":
+			if len(fields) != 4 {
+				return
+			}
+			hdrLen, _ := strconv.Atoi(fields[2])
+			total, _ := strconv.Atoi(fields[3])
+			data := make([]byte, total+2)
+			if _, err := io.ReadFull(r, data); err != nil {
+				return
+			}
+			msg := natsMsg{subject: fields[1], body: string(data[hdrLen:total])}
+			for _, h := range strings.Split(string(data[:hdrLen]), "\r\n") {
+				if v, ok := strings.CutPrefix(h, "Nats-Msg-Id: "); ok {
+					msg.msgID = v
+				}
+			}
+			if msg.subject == "tasks.reject" {
+				if _, err := io.WriteString(conn, "-ERR 'Permissions Violation for Publish'\r\n"); err != nil {
+					return
+				}
+				continue
+			}
+			s.mu.Lock()
+			s.msgs = append(s.msgs, msg)
+			s.mu.Unlock()
+		default:
+			return
+		}
+	}
+}
+
+func domainEvents(from, n int, eventType string) []model.DomainEvent {
+	at := time.Date(2025, 3, 1, 12, 0, 0, 0, time.UTC)
+	events := make([]model.DomainEvent, n)
+	for i := range events {
+		id := from + i
+		events[i] = model.DomainEvent{
+			ID:         uint64(id),
+			Type:       eventType,
+			TaskID:     uint(id * 10),
+			Payload:    []byte(fmt.Sprintf(`{"id":%d}`, id*10)),
+			OccurredAt: at,
+		}
+	}
+	return events
+}
+
+func TestRelayNATS(t *testing.T) {
+	asserts := assert.New(t)
+	requires := require.New(t)
+
+	standIn := newNATSStandIn(t, "")
+	pub, err := NewPublisher("nats://" + standIn.ln.Addr().String())
+	requires.NoError(err)
+	db := &outboxMock{events: domainEvents(1, relayLimit+5, model.TaskCreated)}
+	relay := NewRelay(db, pub)
+
+	log.Print("\t 1 test relay: full batch\n")
+	n, err := relay.RelayOnce(context.Background())
+	requires.NoError(err)
+	asserts.Equal(relayLimit, n)
+	log.Print("\t 2 test relay: rest of events\n")
+	n, err = relay.RelayOnce(context.Background())
+	requires.NoError(err)
+	asserts.Equal(5, n)
+
+	msgs := standIn.received()
+	requires.Len(msgs, relayLimit+5)
+	for i, msg := range msgs {
+		asserts.Equal(strconv.Itoa(i+1), msg.msgID, "order and id of deduplication")
+	}
+	asserts.Equal("tasks.TaskCreated", msgs[0].subject)
+	envelope := Envelope{}
+	requires.NoError(json.Unmarshal([]byte(msgs[0].body), &envelope))
+	asserts.Equal(Envelope{
+		ID:         1,
+		Type:       model.TaskCreated,
+		TaskID:     10,
+		OccurredAt: "2025-03-01T12:00:00Z",
+		Data:       json.RawMessage(`{"id":10}`),
+	}, envelope)
+
+	log.Print("\t 3 test relay: error of server keeps events\n")
+	db.events = domainEvents(200, 1, "reject")
+	_, err = relay.RelayOnce(context.Background())
+	requires.ErrorIs(err, ErrOutboxNATS)
+	asserts.Len(db.events, 1, "event is not published")
+
+	log.Print("\t 4 test relay: connection is opened again\n")
+	db.events = domainEvents(300, 1, model.TaskDeleted)
+	n, err = relay.RelayOnce(context.Background())
+	requires.NoError(err)
+	asserts.Equal(1, n)
+	asserts.Equal("tasks.TaskDeleted", standIn.received()[relayLimit+5].subject)
+
+	log.Print("\t 5 test relay: too big message\n")
+	big := domainEvents(400, 1, model.TaskUpdated)
+	big[0].Payload = []byte(`"` + strings.Repeat("a", 2048) + `"`)
+	db.events = big
+	_, err = relay.RelayOnce(context.Background())
+	requires.ErrorIs(err, ErrOutboxNATSPayload)
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	served := make(chan error, 1)
+	go func() { served <- relay.Serve() }()
+	requires.NoError(relay.Shutdown(ctx))
+	requires.NoError(<-served)
+}
+
+func TestRelayNATSAuth(t *testing.T) {
+	asserts := assert.New(t)
+	requires := require.New(t)
+	ctx := context.Background()
+
+	for i, test := range []struct {
+		description string
+		auth        string
+		userinfo    string
+		hasErr      bool
+	}{
+		{"User and password", "outbox:secret", "outbox:secret@", false},
+		{"Token", "s3cr3t", "s3cr3t@", false},
+		{"Wrong password", "outbox:secret", "outbox:wrong@", true},
+		{"Without credentials", "outbox:secret", "", true},
+	} {
+		log.Printf("\t %d test nats auth: %s\n", i+1, test.description)
+		standIn := newNATSStandIn(t, test.auth)
+		pub, err := NewPublisher("nats://" + test.userinfo + standIn.ln.Addr().String())
+		requires.NoError(err)
+		err = pub.Publish(ctx, []Message{{ID: "1", Subject: "tasks.TaskCreated", Body: []byte("{}")}})
+		if test.hasErr {
+			asserts.ErrorIs(err, ErrOutboxNATS, test.description)
+		} else {
+			asserts.NoError(err, test.description)
+			asserts.Len(standIn.received(), 1, test.description)
+		}
+		requires.NoError(pub.Close())
+	}
+}
+
+// bufferCloser - 'WriterPublisher' of test
+type bufferCloser struct {
+	bytes.Buffer
+	closed bool
+}
+
+func (b *bufferCloser) Close() error {
+	b.closed = true
+	return nil
+}
+
+func TestWriterPublisher(t *testing.T) {
+	asserts := assert.New(t)
+	requires := require.New(t)
+
+	buf := &bufferCloser{}
+	relay := NewRelay(&outboxMock{events: domainEvents(7, 2, model.TaskUpdated)}, NewWriterPublisher(buf))
+	n, err := relay.RelayOnce(context.Background())
+	requires.NoError(err)
+	asserts.Equal(2, n)
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	requires.Len(lines, 2)
+	asserts.Equal(`{"id":"7","subject":"tasks.TaskUpdated","key":"70","data":`+
+		`{"id":7,"type":"TaskUpdated","task_id":70,"occurred_at":"2025-03-01T12:00:00Z","data":{"id":70}}}`, lines[0])
+
+	requires.NoError(relay.pub.Close())
+	asserts.True(buf.closed)
+}
+
+var publisherTestData = []struct {
+	url     string
+	isError bool
+	msg     string
+}{
+	{url: "stdout", msg: "valid - lines in stdout"},
+	{url: "nats://127.0.0.1:4222", msg: "valid - NATS"},
+	{url: "nats://", isError: true, msg: "invalid - NATS without host"},
+	{url: "file://", isError: true, msg: "invalid - file without path"},
+	{url: "kafka://127.0.0.1:9092", isError: true, msg: "invalid - unknown scheme"},
+}
+
+func TestNewPublisher(t *testing.T) {
+	asserts := assert.New(t)
+
+	for i, test := range publisherTestData {
+		log.Printf("\t %d test publisher: %s\n", i+1, test.url)
+		pub, err := NewPublisher(test.url)
+		if test.isError {
+			asserts.True(errors.Is(err, ErrOutboxURL), test.msg)
+			continue
+		}
+		if asserts.NoError(err, test.msg) {
+			asserts.NoError(pub.Close(), test.msg)
+		}
+	}
+}
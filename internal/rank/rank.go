@@ -0,0 +1,118 @@
+// rank - lexicographic fractional indexes, order of 'Task' in column of board
+//
+// key is fraction in base 62 without "0." and trailing zeros: "V" = 31/62,
+// byte order of keys is order of fractions, so key between two others is always found
+package rank
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// Digits - digits of key in byte order
+const Digits = "0123456789ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz"
+
+var (
+	// ErrRankKey - key has digit which is not in 'Digits' or ends with zero
+	ErrRankKey = errors.New("invalid rank key")
+
+	// ErrRankOrder - first key is not before the second one
+	ErrRankOrder = errors.New("rank keys are not in order")
+)
+
+// Valid - key is empty or has only 'Digits' and no trailing zero
+func Valid(key string) bool {
+	if key == "" {
+		return true
+	}
+	for i := 0; i < len(key); i++ {
+		if strings.IndexByte(Digits, key[i]) < 0 {
+			return false
+		}
+	}
+	return key[len(key)-1] != Digits[0]
+}
+
+// Between - key after 'a' and before 'b', empty 'a' - start of column, empty 'b' - end of column
+//
+// key grows by one digit only when there is no room between 'a' and 'b'
+func Between(a, b string) (string, error) {
+	if !Valid(a) || !Valid(b) {
+		return "", fmt.Errorf("%w - %q, %q", ErrRankKey, a, b)
+	}
+	if b != "" && a >= b {
+		return "", fmt.Errorf("%w - %q, %q", ErrRankOrder, a, b)
+	}
+	return midpoint(a, b), nil
+}
+
+// midpoint - 'a' < 'b' ("" - end) without trailing zeros
+func midpoint(a, b string) string {
+	if b != "" {
+		// common prefix, 'a' is padded by zeros
+		n := 0
+		for n < len(b) && digitAt(a, n) == b[n] {
+			n++
+		}
+		if n > 0 {
+			return b[:n] + midpoint(a[min(n, len(a)):], b[n:])
+		}
+	}
+	digitA := 0
+	if a != "" {
+		digitA = strings.IndexByte(Digits, a[0])
+	}
+	digitB := len(Digits)
+	if b != "" {
+		digitB = strings.IndexByte(Digits, b[0])
+	}
+	if digitB-digitA > 1 {
+		return string(Digits[(digitA+digitB+1)/2])
+	}
+	// neighbour digits
+	if len(b) > 1 {
+		return b[:1]
+	}
+	rest := ""
+	if a != "" {
+		rest = a[1:]
+	}
+	return string(Digits[digitA]) + midpoint(rest, "")
+}
+
+func digitAt(key string, i int) byte {
+	if i < len(key) {
+		return key[i]
+	}
+	return Digits[0]
+}
+
+// Spread - 'n' keys of equal length evenly spaced in order, new keys of column at rebalance
+func Spread(n int) []string {
+	keys := make([]string, n)
+	if n == 0 {
+		return keys
+	}
+	// digits enough for n+1 gaps
+	width, space := 1, len(Digits)
+	for space <= n {
+		width++
+		space *= len(Digits)
+	}
+	step := space / (n + 1)
+	for i := range keys {
+		keys[i] = strings.TrimRight(format((i+1)*step, width), Digits[:1])
+	}
+	return keys
+}
+
+// format - 'value' by 'Digits' with leading zeros up to 'width'
+func format(value, width int) string {
+	key := make([]byte, width)
+	for i := width - 1; i >= 0; i-- {
+		key[i] = Digits[value%len(Digits)]
+		value /= len(Digits)
+	}
+	return string(key)
+}
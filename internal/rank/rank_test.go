@@ -0,0 +1,177 @@
+package rank
+
+import (
+	"context"
+	"log"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/Ekvo/golang-chi-postgres-api/internal/model"
+)
+
+var betweenTestData = []struct {
+	description string
+	a           string
+	b           string
+	expected    string
+	err         error
+	msg         string
+}{
+	{
+		description: "Empty column",
+		expected:    "V",
+		msg:         "valid - middle of digits",
+	},
+	{
+		description: "End of column",
+		a:           "V",
+		expected:    "l",
+		msg:         "valid - middle of rest",
+	},
+	{
+		description: "Start of column",
+		b:           "V",
+		expected:    "G",
+		msg:         "valid - middle before key",
+	},
+	{
+		description: "Neighbour digits",
+		a:           "V",
+		b:           "W",
+		expected:    "VV",
+		msg:         "valid - key grows by one digit",
+	},
+	{
+		description: "Last digit",
+		a:           "z",
+		expected:    "zV",
+		msg:         "valid - key after the last digit",
+	},
+	{
+		description: "Common prefix",
+		a:           "V",
+		b:           "V1",
+		expected:    "V0V",
+		msg:         "valid - shorter key is padded by zeros",
+	},
+	{
+		description: "Before first digit",
+		b:           "1",
+		expected:    "0V",
+		msg:         "valid - key before the smallest one",
+	},
+	{
+		description: "Wrong order",
+		a:           "l",
+		b:           "V",
+		err:         ErrRankOrder,
+		msg:         "invalid - first key after the second one",
+	},
+	{
+		description: "Wrong equal keys",
+		a:           "V",
+		b:           "V",
+		err:         ErrRankOrder,
+		msg:         "invalid - no key between equal keys",
+	},
+	{
+		description: "Wrong key",
+		a:           "V0",
+		err:         ErrRankKey,
+		msg:         "invalid - trailing zero",
+	},
+	{
+		description: "Wrong digit",
+		b:           "V-",
+		err:         ErrRankKey,
+		msg:         "invalid - digit is not in Digits",
+	},
+}
+
+func TestBetween(t *testing.T) {
+	asserts := assert.New(t)
+
+	for i, test := range betweenTestData {
+		log.Printf("\t %d test between: %s\n", i+1, test.description)
+		key, err := Between(test.a, test.b)
+		if test.err != nil {
+			asserts.ErrorIs(err, test.err, test.msg)
+			continue
+		}
+		asserts.NoError(err, test.msg)
+		asserts.Equal(test.expected, key, test.msg)
+		asserts.True(key > test.a && (test.b == "" || key < test.b), test.msg)
+	}
+}
+
+func TestBetweenRepeated(t *testing.T) {
+	asserts := assert.New(t)
+	requires := require.New(t)
+
+	log.Print("\t 1 test between: keys at the end of column grow by one digit of five keys\n")
+	last := ""
+	for i := 0; i < 100; i++ {
+		key, err := Between(last, "")
+		requires.NoError(err)
+		requires.Greater(key, last)
+		last = key
+	}
+	asserts.Len(last, 20)
+
+	log.Print("\t 2 test between: keys in the same gap stay in order\n")
+	a, b := "V", "W"
+	for i := 0; i < 100; i++ {
+		key, err := Between(a, b)
+		requires.NoError(err)
+		requires.True(a < key && key < b)
+		a = key
+	}
+	asserts.Greater(len(a), MaxKeyLen, "column must be rebalanced")
+}
+
+func TestSpread(t *testing.T) {
+	asserts := assert.New(t)
+
+	for i, n := range []int{0, 1, 2, 61, 62, 5000} {
+		log.Printf("\t %d test spread: %d keys\n", i+1, n)
+		keys := Spread(n)
+		asserts.Len(keys, n)
+		asserts.True(sort.StringsAreSorted(keys), "keys in order")
+		for j, key := range keys {
+			asserts.True(Valid(key) && key != "", "key %q", key)
+			if j > 0 {
+				asserts.NotEqual(keys[j-1], key, "keys are distinct")
+			}
+			asserts.LessOrEqual(len(key), 3)
+		}
+	}
+}
+
+// boardMock - 'model.TaskBoard' which records rebalance
+type boardMock struct {
+	rebalance model.RankRebalance
+}
+
+func (m *boardMock) MoveTask(ctx context.Context, data any) (model.Task, error) {
+	return model.Task{}, ctx.Err()
+}
+
+func (m *boardMock) RebalancePositions(ctx context.Context, data any) (int, error) {
+	m.rebalance = data.(model.RankRebalance)
+	return 1, ctx.Err()
+}
+
+func TestRebalancer(t *testing.T) {
+	asserts := assert.New(t)
+	requires := require.New(t)
+
+	log.Print("\t 1 test rebalance: long keys are replaced by spread keys\n")
+	db := &boardMock{}
+	requires.NoError(NewRebalancer(db).Rebalance(context.Background(), model.Job{}))
+	asserts.Equal(MaxKeyLen, db.rebalance.MaxLen)
+	asserts.Equal(strings.Join(Spread(3), ","), strings.Join(db.rebalance.Keys(3), ","))
+}
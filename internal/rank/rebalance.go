@@ -0,0 +1,47 @@
+package rank
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"github.com/Ekvo/golang-chi-postgres-api/internal/jobs"
+	"github.com/Ekvo/golang-chi-postgres-api/internal/model"
+)
+
+// parameters of rebalancing
+const (
+	// KindRebalance - periodic job of new keys of columns whose keys grow long
+	KindRebalance = "rank.rebalance"
+
+	rebalanceInterval = 10 * time.Minute
+
+	// MaxKeyLen - column with longer key is rebalanced
+	MaxKeyLen = 16
+)
+
+// Rebalancer - new evenly spaced keys of columns, runs as periodic job of 'jobs.Pool'
+type Rebalancer struct {
+	db model.TaskBoard
+}
+
+func NewRebalancer(db model.TaskBoard) *Rebalancer {
+	return &Rebalancer{db: db}
+}
+
+// Register - 'Rebalance' is periodic job of 'pool'
+func (rb *Rebalancer) Register(pool *jobs.Pool) {
+	pool.Every(KindRebalance, rebalanceInterval, rb.Rebalance)
+}
+
+// Rebalance - columns with key longer than 'MaxKeyLen', not ranked or equal keys get keys of 'Spread'
+func (rb *Rebalancer) Rebalance(ctx context.Context, job model.Job) error {
+	columns, err := rb.db.RebalancePositions(ctx, model.RankRebalance{MaxLen: MaxKeyLen, Keys: Spread})
+	if err != nil {
+		return err
+	}
+	if columns > 0 {
+		log.Printf("rank: %d columns are rebalanced", columns)
+	}
+	return nil
+}
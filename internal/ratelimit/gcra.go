@@ -0,0 +1,47 @@
+// ratelimit - token buckets of clients by GCRA (generic cell rate algorithm) and daily quotas
+//
+// bucket is one time - theoretical arrival time (TAT): time when bucket is full again,
+// request takes one token - TAT moves by interval of token, TAT later than now + burst * interval - no token
+package ratelimit
+
+import (
+	"time"
+
+	"github.com/Ekvo/golang-chi-postgres-api/internal/model"
+)
+
+// Step - TAT after request at 'now', false - no token and TAT is not changed
+func Step(tat, now time.Time, interval time.Duration, burst int) (time.Time, bool) {
+	if tat.Before(now) {
+		tat = now
+	}
+	next := tat.Add(interval)
+	if next.Sub(now) > time.Duration(burst)*interval {
+		return tat, false
+	}
+	return next, true
+}
+
+// Decision - values of headers 'RateLimit-*' and 'Retry-After' of request
+type Decision struct {
+	Allowed   bool
+	Limit     int
+	Remaining int
+	// Reset - time until bucket is full
+	Reset time.Duration
+	// RetryAfter - time until the next token, only without token
+	RetryAfter time.Duration
+}
+
+// NewDecision - 'Decision' of 'state' of bucket of 'interval' and 'burst'
+func NewDecision(state model.RateState, interval time.Duration, burst int) Decision {
+	debt := max(state.TAT.Sub(state.Now), 0)
+	capacity := time.Duration(burst) * interval
+	decision := Decision{Allowed: state.Allowed, Limit: burst, Reset: debt}
+	if !state.Allowed {
+		decision.RetryAfter = max(debt+interval-capacity, 0)
+		return decision
+	}
+	decision.Remaining = int((capacity - debt) / interval)
+	return decision
+}
@@ -0,0 +1,127 @@
+package ratelimit
+
+import (
+	"context"
+	"log"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/Ekvo/golang-chi-postgres-api/internal/model"
+)
+
+var start = time.Date(2025, 3, 1, 12, 0, 0, 0, time.UTC)
+
+var stepTestData = []struct {
+	description string
+	tat         time.Time
+	now         time.Time
+	expectedTAT time.Time
+	allowed     bool
+	msg         string
+}{
+	{
+		description: "Full bucket",
+		now:         start,
+		expectedTAT: start.Add(time.Second),
+		allowed:     true,
+		msg:         "valid - TAT from now",
+	},
+	{
+		description: "Last token",
+		tat:         start.Add(2 * time.Second),
+		now:         start,
+		expectedTAT: start.Add(3 * time.Second),
+		allowed:     true,
+		msg:         "valid - TAT up to burst",
+	},
+	{
+		description: "Empty bucket",
+		tat:         start.Add(3 * time.Second),
+		now:         start,
+		expectedTAT: start.Add(3 * time.Second),
+		msg:         "invalid - no token, TAT is not changed",
+	},
+	{
+		description: "Refilled token",
+		tat:         start.Add(3 * time.Second),
+		now:         start.Add(time.Second),
+		expectedTAT: start.Add(4 * time.Second),
+		allowed:     true,
+		msg:         "valid - token after interval",
+	},
+}
+
+func TestStep(t *testing.T) {
+	asserts := assert.New(t)
+
+	for i, test := range stepTestData {
+		log.Printf("\t %d test step: %s\n", i+1, test.description)
+		tat, allowed := Step(test.tat, test.now, time.Second, 3)
+		asserts.Equal(test.allowed, allowed, test.msg)
+		asserts.Equal(test.expectedTAT, tat, test.msg)
+	}
+}
+
+func TestNewDecision(t *testing.T) {
+	asserts := assert.New(t)
+
+	log.Print("\t 1 test decision: token is taken\n")
+	decision := NewDecision(model.RateState{Allowed: true, TAT: start.Add(2 * time.Second), Now: start}, time.Second, 3)
+	asserts.Equal(Decision{Allowed: true, Limit: 3, Remaining: 1, Reset: 2 * time.Second}, decision)
+
+	log.Print("\t 2 test decision: no token\n")
+	decision = NewDecision(model.RateState{TAT: start.Add(3 * time.Second), Now: start.Add(500 * time.Millisecond)},
+		time.Second, 3)
+	asserts.Equal(Decision{Limit: 3, Reset: 2500 * time.Millisecond, RetryAfter: 500 * time.Millisecond}, decision)
+}
+
+func TestMemory(t *testing.T) {
+	asserts := assert.New(t)
+	requires := require.New(t)
+
+	m := NewMemory()
+	now := start
+	m.now = func() time.Time { return now }
+	ctx := context.Background()
+	take := model.RateTake{Key: "user:alice|POST /task", Interval: time.Minute, Burst: 2}
+
+	log.Print("\t 1 test memory: burst of tokens, then none\n")
+	for i, expected := range []bool{true, true, false} {
+		state, err := m.TakeRate(ctx, take)
+		requires.NoError(err)
+		asserts.Equal(expected, state.Allowed, "take %d", i+1)
+	}
+	state, err := m.TakeRate(ctx, model.RateTake{Key: "user:bob|POST /task", Interval: time.Minute, Burst: 2})
+	requires.NoError(err)
+	asserts.True(state.Allowed, "valid - buckets of clients are apart")
+
+	log.Print("\t 2 test memory: token after interval\n")
+	now = now.Add(time.Minute)
+	state, err = m.TakeRate(ctx, take)
+	requires.NoError(err)
+	asserts.True(state.Allowed)
+
+	log.Print("\t 3 test memory: quota of day, return of count\n")
+	quota := model.QuotaTake{Key: "user:alice|tasks", Day: now, Count: 1, Limit: 2}
+	for i, expected := range []bool{true, true, false} {
+		state, err := m.TakeQuota(ctx, quota)
+		requires.NoError(err)
+		asserts.Equal(expected, state.Allowed, "take %d", i+1)
+	}
+	quota.Count = -1
+	back, err := m.TakeQuota(ctx, quota)
+	requires.NoError(err)
+	asserts.Equal(model.QuotaState{Allowed: true, Used: 1}, back)
+	quota.Count, quota.Day = 1, now.Add(24*time.Hour)
+	next, err := m.TakeQuota(ctx, quota)
+	requires.NoError(err)
+	asserts.Equal(model.QuotaState{Allowed: true, Used: 1}, next, "valid - new day - new quota")
+
+	log.Print("\t 4 test memory: purge of full buckets and past days\n")
+	requires.NoError(m.PurgeRateLimits(ctx, now.Add(24*time.Hour)))
+	asserts.Empty(m.tats)
+	asserts.Len(m.quotas, 1)
+}
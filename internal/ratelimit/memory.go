@@ -0,0 +1,92 @@
+package ratelimit
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/Ekvo/golang-chi-postgres-api/internal/model"
+)
+
+// sweepEvery - full buckets and quotas of past days are removed each 'sweepEvery' takes
+const sweepEvery = 1024
+
+// Memory - 'model.RateLimiter' of one replica, each replica has own buckets
+type Memory struct {
+	mu     sync.Mutex
+	tats   map[string]time.Time
+	quotas map[quotaKey]int
+	takes  int
+	now    func() time.Time
+}
+
+type quotaKey struct {
+	key string
+	day string
+}
+
+func NewMemory() *Memory {
+	return &Memory{
+		tats:   map[string]time.Time{},
+		quotas: map[quotaKey]int{},
+		now:    time.Now,
+	}
+}
+
+func (m *Memory) TakeRate(ctx context.Context, data any) (model.RateState, error) {
+	take := data.(model.RateTake)
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	now := m.now().UTC()
+	m.sweep(now)
+	tat, allowed := Step(m.tats[take.Key], now, take.Interval, take.Burst)
+	if allowed {
+		m.tats[take.Key] = tat
+	}
+	return model.RateState{Allowed: allowed, TAT: tat, Now: now}, ctx.Err()
+}
+
+func (m *Memory) TakeQuota(ctx context.Context, data any) (model.QuotaState, error) {
+	take := data.(model.QuotaTake)
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.sweep(m.now().UTC())
+	key := quotaKey{key: take.Key, day: take.Day.UTC().Format(time.DateOnly)}
+	used := m.quotas[key]
+	if used+take.Count > take.Limit && take.Count > 0 {
+		return model.QuotaState{Used: used}, ctx.Err()
+	}
+	m.quotas[key] = max(used+take.Count, 0)
+	return model.QuotaState{Allowed: true, Used: m.quotas[key]}, ctx.Err()
+}
+
+func (m *Memory) PurgeRateLimits(ctx context.Context, data any) error {
+	now := data.(time.Time)
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.purge(now.UTC())
+	return ctx.Err()
+}
+
+// sweep - 'purge' of each 'sweepEvery' take, under lock
+func (m *Memory) sweep(now time.Time) {
+	m.takes++
+	if m.takes%sweepEvery == 0 {
+		m.purge(now)
+	}
+}
+
+// purge - full buckets and quotas before day of 'now', under lock
+func (m *Memory) purge(now time.Time) {
+	for key, tat := range m.tats {
+		if !tat.After(now) {
+			delete(m.tats, key)
+		}
+	}
+	today := now.Format(time.DateOnly)
+	for key := range m.quotas {
+		if key.day < today {
+			delete(m.quotas, key)
+		}
+	}
+}
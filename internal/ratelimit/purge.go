@@ -0,0 +1,36 @@
+package ratelimit
+
+import (
+	"context"
+	"time"
+
+	"github.com/Ekvo/golang-chi-postgres-api/internal/jobs"
+	"github.com/Ekvo/golang-chi-postgres-api/internal/model"
+)
+
+// parameters of removal of full buckets and of quotas of past days
+const (
+	// KindPurge - periodic job of removal of buckets and quotas of shared store
+	KindPurge = "ratelimit.purge"
+
+	purgeInterval = time.Hour
+)
+
+// Purger - removes full buckets and quotas of past days of store of all replicas, runs as periodic job of 'jobs.Pool',
+// 'Memory' is cleaned by itself
+type Purger struct {
+	db model.RateLimiter
+}
+
+func NewPurger(db model.RateLimiter) *Purger {
+	return &Purger{db: db}
+}
+
+// Register - 'Purge' is periodic job of 'pool'
+func (p *Purger) Register(pool *jobs.Pool) {
+	pool.Every(KindPurge, purgeInterval, p.Purge)
+}
+
+func (p *Purger) Purge(ctx context.Context, job model.Job) error {
+	return p.db.PurgeRateLimits(ctx, time.Now().UTC())
+}
@@ -0,0 +1,131 @@
+package ratelimit
+
+import (
+	"context"
+	"errors"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/Ekvo/golang-chi-postgres-api/internal/model"
+)
+
+// ErrQuotaExceeded - daily quota of tasks of client is used
+var ErrQuotaExceeded = errors.New("daily quota of tasks is exceeded")
+
+// refundTimeout - return of quota after end of request
+const refundTimeout = 5 * time.Second
+
+// TaskQuota - 'limit' tasks created by one client in a day (UTC), the same for REST, GraphQL and gRPC
+type TaskQuota struct {
+	limiter model.RateLimiter
+	limit   int
+	now     func() time.Time
+}
+
+func NewTaskQuota(limiter model.RateLimiter, limit int) *TaskQuota {
+	return &TaskQuota{limiter: limiter, limit: limit, now: time.Now}
+}
+
+// Begin - 'Charge' of one request of 'client' ("user:<name>", "ip:<host>")
+func (q *TaskQuota) Begin(client string) *Charge {
+	return &Charge{q: q, key: client + "|tasks", day: q.now().UTC()}
+}
+
+// Charge - tasks taken by one request, tasks which are not created are given back by 'Refund'
+//
+// error of limiter - tasks are created without quota, nil Charge - no quota
+type Charge struct {
+	q   *TaskQuota
+	key string
+	day time.Time
+
+	mu    sync.Mutex
+	taken int
+}
+
+// Take - 'n' tasks more, not enough quota - ErrQuotaExceeded and nothing is taken
+func (ch *Charge) Take(ctx context.Context, n int) error {
+	if ch == nil || n < 1 {
+		return nil
+	}
+	state, err := ch.take(ctx, n)
+	if err != nil {
+		return nil
+	}
+	if !state.Allowed {
+		return ErrQuotaExceeded
+	}
+	return nil
+}
+
+// Reserve - up to 'n' tasks more, less if the rest of quota is smaller, returns taken,
+// nothing is left - ErrQuotaExceeded
+func (ch *Charge) Reserve(ctx context.Context, n int) (int, error) {
+	if ch == nil || n < 1 {
+		return n, nil
+	}
+	state, err := ch.take(ctx, n)
+	if err != nil || state.Allowed {
+		return n, nil
+	}
+	if rest := ch.q.limit - state.Used; rest > 0 && rest < n {
+		return ch.Reserve(ctx, rest)
+	}
+	return 0, ErrQuotaExceeded
+}
+
+// take - 'n' of limiter, error is logged
+func (ch *Charge) take(ctx context.Context, n int) (model.QuotaState, error) {
+	state, err := ch.q.limiter.TakeQuota(ctx, model.QuotaTake{Key: ch.key, Day: ch.day, Count: n, Limit: ch.q.limit})
+	if err != nil {
+		log.Printf("ratelimit: task quota error - %v", err)
+		return state, err
+	}
+	if state.Allowed {
+		ch.mu.Lock()
+		ch.taken += n
+		ch.mu.Unlock()
+	}
+	return state, nil
+}
+
+// Refund - 'n' taken tasks are not created, they are given back even after end of 'ctx'
+func (ch *Charge) Refund(ctx context.Context, n int) {
+	if ch == nil {
+		return
+	}
+	ch.mu.Lock()
+	n = min(n, ch.taken)
+	ch.taken -= n
+	ch.mu.Unlock()
+	if n < 1 {
+		return
+	}
+	ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), refundTimeout)
+	defer cancel()
+	if _, err := ch.q.limiter.TakeQuota(ctx, model.QuotaTake{Key: ch.key, Day: ch.day, Count: -n, Limit: ch.q.limit}); err != nil {
+		log.Printf("ratelimit: task quota return error - %v", err)
+	}
+}
+
+// RetryAfter - time till the next day of quota
+func (ch *Charge) RetryAfter() time.Duration {
+	if ch == nil {
+		return 0
+	}
+	return ch.day.Truncate(24 * time.Hour).Add(24 * time.Hour).Sub(ch.q.now().UTC())
+}
+
+type chargeKey struct{}
+
+// NewContext - ctx with Charge of request
+func NewContext(ctx context.Context, ch *Charge) context.Context {
+	return context.WithValue(ctx, chargeKey{}, ch)
+}
+
+// FromContext - Charge of request, nil - request has no quota
+func FromContext(ctx context.Context) *Charge {
+	ch, _ := ctx.Value(chargeKey{}).(*Charge)
+	return ch
+}
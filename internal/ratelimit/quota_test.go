@@ -0,0 +1,44 @@
+package ratelimit
+
+import (
+	"context"
+	"log"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTaskQuota(t *testing.T) {
+	asserts := assert.New(t)
+	requires := require.New(t)
+	ctx := context.Background()
+
+	quota := NewTaskQuota(NewMemory(), 5)
+	quota.now = func() time.Time { return start }
+
+	log.Print("\t 1 test task quota: tasks of batch are taken at once\n")
+	first := quota.Begin("user:alice")
+	requires.NoError(first.Take(ctx, 3))
+	asserts.ErrorIs(first.Take(ctx, 3), ErrQuotaExceeded, "invalid - nothing is taken above quota")
+
+	log.Print("\t 2 test task quota: reserve takes the rest of quota\n")
+	second := quota.Begin("user:alice")
+	n, err := second.Reserve(ctx, 100)
+	requires.NoError(err)
+	asserts.Equal(2, n)
+	_, err = second.Reserve(ctx, 100)
+	asserts.ErrorIs(err, ErrQuotaExceeded)
+
+	log.Print("\t 3 test task quota: refund is not above taken of request\n")
+	second.Refund(ctx, 10)
+	asserts.NoError(quota.Begin("user:alice").Take(ctx, 2))
+	asserts.ErrorIs(quota.Begin("user:alice").Take(ctx, 1), ErrQuotaExceeded)
+	asserts.NoError(quota.Begin("user:bob").Take(ctx, 5), "valid - quota of each client")
+
+	log.Print("\t 4 test task quota: next day in UTC\n")
+	asserts.Equal(12*time.Hour, first.RetryAfter())
+	var none *Charge
+	asserts.NoError(none.Take(ctx, 100), "valid - request without quota")
+}
@@ -0,0 +1,512 @@
+// recurrence - RFC 5545 recurrence rules (RRULE) of 'Task' in time zone of 'Task'
+package recurrence
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var (
+	// ErrRecurrenceRule - value of RRULE is invalid or has part which is not supported
+	ErrRecurrenceRule = errors.New("invalid rrule")
+
+	// ErrRecurrenceTimeZone - name of time zone is not in IANA database
+	ErrRecurrenceTimeZone = errors.New("invalid time zone")
+)
+
+// frequencies of RRULE, HOURLY and less are not supported - occurrence of 'Task' is at most daily
+const (
+	Daily   = "DAILY"
+	Weekly  = "WEEKLY"
+	Monthly = "MONTHLY"
+	Yearly  = "YEARLY"
+)
+
+// yearsWithoutOccurrence - series without occurrence during this time is ended (BYMONTH=2;BYMONTHDAY=30),
+// 28 years - full cycle of weekdays and leap years
+const yearsWithoutOccurrence = 28
+
+var weekdays = map[string]time.Weekday{
+	"SU": time.Sunday,
+	"MO": time.Monday,
+	"TU": time.Tuesday,
+	"WE": time.Wednesday,
+	"TH": time.Thursday,
+	"FR": time.Friday,
+	"SA": time.Saturday,
+}
+
+// weekdayNum - part of BYDAY, 'n' - number of weekday in month or year ("-1FR" - last Friday), 0 - each
+type weekdayNum struct {
+	n       int
+	weekday time.Weekday
+}
+
+// until - value of UNTIL: UTC date-time ("Z"), local date-time or date (end of the day) in time zone of series
+type until struct {
+	year, month, day   int
+	hour, minute, sec  int
+	utc, date, present bool
+}
+
+// Rule - parsed RRULE, time of day and defaults of BY parts are taken from DTSTART (look: NewSeries)
+type Rule struct {
+	freq       string
+	interval   int
+	count      int
+	until      until
+	byDay      []weekdayNum
+	byMonthDay []int
+	byMonth    []int
+	byHour     []int
+	byMinute   []int
+	bySetPos   []int
+	wkst       time.Weekday
+}
+
+// Parse - value of RRULE ("FREQ=WEEKLY;BYDAY=MO,TH", prefix "RRULE:" is allowed)
+//
+// supported parts: FREQ (DAILY, WEEKLY, MONTHLY, YEARLY), INTERVAL, COUNT, UNTIL,
+// BYDAY, BYMONTHDAY, BYMONTH, BYHOUR, BYMINUTE, BYSETPOS, WKST
+func Parse(value string) (*Rule, error) {
+	value = strings.TrimPrefix(strings.ToUpper(strings.TrimSpace(value)), "RRULE:")
+	if value == "" {
+		return nil, fmt.Errorf("%w - empty", ErrRecurrenceRule)
+	}
+	r := &Rule{interval: 1, wkst: time.Monday}
+	seen := map[string]bool{}
+	for _, part := range strings.Split(value, ";") {
+		name, val, ok := strings.Cut(part, "=")
+		if !ok || val == "" {
+			return nil, fmt.Errorf("%w - part %q is not NAME=VALUE", ErrRecurrenceRule, part)
+		}
+		if seen[name] {
+			return nil, fmt.Errorf("%w - part %s is repeated", ErrRecurrenceRule, name)
+		}
+		seen[name] = true
+		var err error
+		switch name {
+		case "FREQ":
+			switch val {
+			case Daily, Weekly, Monthly, Yearly:
+				r.freq = val
+			default:
+				err = fmt.Errorf("FREQ=%s is not supported", val)
+			}
+		case "INTERVAL":
+			r.interval, err = positive(val)
+		case "COUNT":
+			r.count, err = positive(val)
+		case "UNTIL":
+			r.until, err = parseUntil(val)
+		case "BYDAY":
+			r.byDay, err = parseByDay(val)
+		case "BYMONTHDAY":
+			r.byMonthDay, err = parseInts(val, -31, 31, false)
+		case "BYMONTH":
+			r.byMonth, err = parseInts(val, 1, 12, false)
+		case "BYHOUR":
+			r.byHour, err = parseInts(val, 0, 23, true)
+		case "BYMINUTE":
+			r.byMinute, err = parseInts(val, 0, 59, true)
+		case "BYSETPOS":
+			r.bySetPos, err = parseInts(val, -366, 366, false)
+		case "WKST":
+			wd, ok := weekdays[val]
+			if !ok {
+				err = fmt.Errorf("WKST=%s is not a weekday", val)
+			}
+			r.wkst = wd
+		default:
+			err = fmt.Errorf("part %s is not supported", name)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("%w - %v", ErrRecurrenceRule, err)
+		}
+	}
+	if err := r.check(); err != nil {
+		return nil, fmt.Errorf("%w - %v", ErrRecurrenceRule, err)
+	}
+	return r, nil
+}
+
+// check - rules of RFC 5545 between parts
+func (r *Rule) check() error {
+	switch {
+	case r.freq == "":
+		return errors.New("FREQ is required")
+	case r.count > 0 && r.until.present:
+		return errors.New("COUNT and UNTIL must not be together")
+	case r.freq == Weekly && len(r.byMonthDay) > 0:
+		return errors.New("BYMONTHDAY must not be with FREQ=WEEKLY")
+	case len(r.bySetPos) > 0 && len(r.byDay)+len(r.byMonthDay)+len(r.byMonth)+len(r.byHour)+len(r.byMinute) == 0:
+		return errors.New("BYSETPOS requires other BY part")
+	}
+	if r.freq == Daily || r.freq == Weekly {
+		for _, wd := range r.byDay {
+			if wd.n != 0 {
+				return fmt.Errorf("BYDAY with number must not be with FREQ=%s", r.freq)
+			}
+		}
+	}
+	return nil
+}
+
+func positive(val string) (int, error) {
+	n, err := strconv.Atoi(val)
+	if err != nil || n < 1 {
+		return 0, fmt.Errorf("%q is not a positive number", val)
+	}
+	return n, nil
+}
+
+// parseInts - list of numbers in [lo, hi], zero is allowed only with 'zero', result is sorted
+func parseInts(val string, lo, hi int, zero bool) ([]int, error) {
+	var nums []int
+	for _, s := range strings.Split(val, ",") {
+		n, err := strconv.Atoi(s)
+		if err != nil || n < lo || n > hi || (n == 0 && !zero) {
+			return nil, fmt.Errorf("%q is not in range %d..%d", s, lo, hi)
+		}
+		nums = append(nums, n)
+	}
+	sort.Ints(nums)
+	return nums, nil
+}
+
+func parseByDay(val string) ([]weekdayNum, error) {
+	var days []weekdayNum
+	for _, s := range strings.Split(val, ",") {
+		if len(s) < 2 {
+			return nil, fmt.Errorf("%q is not a weekday", s)
+		}
+		wd, ok := weekdays[s[len(s)-2:]]
+		if !ok {
+			return nil, fmt.Errorf("%q is not a weekday", s)
+		}
+		day := weekdayNum{weekday: wd}
+		if num := s[:len(s)-2]; num != "" {
+			n, err := strconv.Atoi(num)
+			if err != nil || n == 0 || n < -53 || n > 53 {
+				return nil, fmt.Errorf("%q has invalid number", s)
+			}
+			day.n = n
+		}
+		days = append(days, day)
+	}
+	return days, nil
+}
+
+func parseUntil(val string) (until, error) {
+	u := until{present: true}
+	layout := "20060102T150405"
+	switch {
+	case len(val) == 8:
+		layout, u.date = "20060102", true
+	case strings.HasSuffix(val, "Z"):
+		val, u.utc = strings.TrimSuffix(val, "Z"), true
+	}
+	t, err := time.Parse(layout, val)
+	if err != nil {
+		return u, fmt.Errorf("UNTIL=%s is not a date or date-time", val)
+	}
+	u.year, u.month, u.day = t.Year(), int(t.Month()), t.Day()
+	u.hour, u.minute, u.sec = t.Hour(), t.Minute(), t.Second()
+	if u.date {
+		u.hour, u.minute, u.sec = 23, 59, 59
+	}
+	return u, nil
+}
+
+// LoadLocation - IANA time zone, "" - UTC, "Local" is not allowed - it depends on the server
+func LoadLocation(name string) (*time.Location, error) {
+	if name == "" {
+		return time.UTC, nil
+	}
+	if name == "Local" {
+		return nil, ErrRecurrenceTimeZone
+	}
+	loc, err := time.LoadLocation(name)
+	if err != nil {
+		return nil, fmt.Errorf("%w - %v", ErrRecurrenceTimeZone, err)
+	}
+	return loc, nil
+}
+
+// WallClock - time of day of date in 'loc' by RFC 5545
+//
+// time in gap of daylight saving time is moved forward by length of the gap (02:30 -> 03:30),
+// time which is twice (end of daylight saving time) is the first of two,
+// time.Date does not define it
+func WallClock(year int, month time.Month, day, hour, minute, sec int, loc *time.Location) time.Time {
+	naive := time.Date(year, month, day, hour, minute, sec, 0, time.UTC)
+	_, before := naive.Add(-24 * time.Hour).In(loc).Zone()
+	_, after := naive.Add(24 * time.Hour).In(loc).Zone()
+	for _, offset := range []int{before, after} {
+		t := naive.Add(-time.Duration(offset) * time.Second).In(loc)
+		if y, mo, d := t.Date(); y == year && mo == month && d == day && t.Hour() == hour && t.Minute() == minute {
+			return t
+		}
+	}
+	return naive.Add(-time.Duration(before) * time.Second).In(loc)
+}
+
+// Series - occurrences of 'Rule' from DTSTART in time zone of 'Task'
+//
+// occurrences are counted in local time of the zone, so "09:00" stays "09:00" after change of daylight saving time
+type Series struct {
+	rule  Rule
+	loc   *time.Location
+	start time.Time
+	until time.Time
+}
+
+// NewSeries - 'start' (DTSTART) is the first occurrence, 'timeZone' is IANA name ("" - UTC)
+func NewSeries(rrule, timeZone string, start time.Time) (*Series, error) {
+	rule, err := Parse(rrule)
+	if err != nil {
+		return nil, err
+	}
+	loc, err := LoadLocation(timeZone)
+	if err != nil {
+		return nil, err
+	}
+	s := &Series{rule: *rule, loc: loc, start: start.In(loc)}
+	if u := rule.until; u.present {
+		s.until = WallClock(u.year, time.Month(u.month), u.day, u.hour, u.minute, u.sec, loc)
+		if u.utc {
+			s.until = time.Date(u.year, time.Month(u.month), u.day, u.hour, u.minute, u.sec, 0, time.UTC)
+		}
+	}
+	s.defaults()
+	return s, nil
+}
+
+// defaults - BY parts which are missing are taken from DTSTART (RFC 5545, 3.3.10)
+func (s *Series) defaults() {
+	r := &s.rule
+	switch {
+	case r.freq == Weekly && len(r.byDay) == 0:
+		r.byDay = []weekdayNum{{weekday: s.start.Weekday()}}
+	case r.freq == Monthly && len(r.byDay) == 0 && len(r.byMonthDay) == 0:
+		r.byMonthDay = []int{s.start.Day()}
+	case r.freq == Yearly && len(r.byDay) == 0 && len(r.byMonthDay) == 0:
+		if len(r.byMonth) == 0 {
+			r.byMonth = []int{int(s.start.Month())}
+		}
+		r.byMonthDay = []int{s.start.Day()}
+	}
+	if len(r.byHour) == 0 {
+		r.byHour = []int{s.start.Hour()}
+	}
+	if len(r.byMinute) == 0 {
+		r.byMinute = []int{s.start.Minute()}
+	}
+}
+
+// Location - time zone of occurrences
+func (s *Series) Location() *time.Location {
+	return s.loc
+}
+
+// Next - first occurrence after 't', false - series is ended
+func (s *Series) Next(t time.Time) (time.Time, bool) {
+	next, ok := time.Time{}, false
+	s.each(func(occ time.Time) bool {
+		if occ.After(t) {
+			next, ok = occ, true
+			return false
+		}
+		return true
+	})
+	return next, ok
+}
+
+// Last - last occurrence in (after, t], false - there is no such occurrence
+func (s *Series) Last(after, t time.Time) (time.Time, bool) {
+	last, ok := time.Time{}, false
+	s.each(func(occ time.Time) bool {
+		if occ.After(t) {
+			return false
+		}
+		if occ.After(after) {
+			last, ok = occ, true
+		}
+		return true
+	})
+	return last, ok
+}
+
+// Between - occurrences in [from, to], at most 'limit'
+func (s *Series) Between(from, to time.Time, limit int) []time.Time {
+	var list []time.Time
+	s.each(func(occ time.Time) bool {
+		if occ.After(to) || len(list) >= limit {
+			return false
+		}
+		if !occ.Before(from) {
+			list = append(list, occ)
+		}
+		return len(list) < limit
+	})
+	return list
+}
+
+// each - occurrences in order, DTSTART is the first (RFC 5545), stops when 'yield' returns false,
+// by COUNT, by UNTIL or when there is no occurrence during 'yearsWithoutOccurrence'
+func (s *Series) each(yield func(time.Time) bool) {
+	count := 0
+	emit := func(t time.Time) bool {
+		if (!s.until.IsZero() && t.After(s.until)) || (s.rule.count > 0 && count >= s.rule.count) {
+			return false
+		}
+		count++
+		return yield(t)
+	}
+	if !emit(s.start) {
+		return
+	}
+	limit := s.emptyLimit()
+	for period, empty := 0, 0; empty < limit; period++ {
+		found := false
+		for _, t := range s.period(period) {
+			if !t.After(s.start) {
+				continue
+			}
+			found = true
+			if !emit(t) {
+				return
+			}
+		}
+		if found {
+			empty = 0
+		} else {
+			empty++
+		}
+	}
+}
+
+// emptyLimit - number of periods in 'yearsWithoutOccurrence'
+func (s *Series) emptyLimit() int {
+	perYear := map[string]int{Daily: 366, Weekly: 53, Monthly: 12, Yearly: 1}[s.rule.freq]
+	return yearsWithoutOccurrence*perYear/s.rule.interval + 1
+}
+
+// period - sorted occurrences of period number 'k' (0 - period of DTSTART), before DTSTART too
+func (s *Series) period(k int) []time.Time {
+	r := &s.rule
+	y, m, d := s.start.Date()
+	base := time.Date(y, m, d, 0, 0, 0, 0, time.UTC)
+	var first, last time.Time
+	switch r.freq {
+	case Daily:
+		first = base.AddDate(0, 0, k*r.interval)
+		last = first
+	case Weekly:
+		offset := (int(base.Weekday()) - int(r.wkst) + 7) % 7
+		first = base.AddDate(0, 0, 7*k*r.interval-offset)
+		last = first.AddDate(0, 0, 6)
+	case Monthly:
+		first = time.Date(y, m+time.Month(k*r.interval), 1, 0, 0, 0, 0, time.UTC)
+		last = first.AddDate(0, 1, -1)
+	case Yearly:
+		first = time.Date(y+k*r.interval, time.January, 1, 0, 0, 0, 0, time.UTC)
+		last = first.AddDate(1, 0, -1)
+	}
+	var list []time.Time
+	for day := first; !day.After(last); day = day.AddDate(0, 0, 1) {
+		if !s.matchDay(day) {
+			continue
+		}
+		for _, hour := range r.byHour {
+			for _, minute := range r.byMinute {
+				list = append(list, WallClock(day.Year(), day.Month(), day.Day(), hour, minute, s.start.Second(), s.loc))
+			}
+		}
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].Before(list[j]) })
+	list = unique(list)
+	if len(r.bySetPos) > 0 {
+		list = setPos(list, r.bySetPos)
+	}
+	return list
+}
+
+// matchDay - 'day' (UTC midnight) passes BYMONTH, BYMONTHDAY and BYDAY
+func (s *Series) matchDay(day time.Time) bool {
+	r := &s.rule
+	if len(r.byMonth) > 0 && !contains(r.byMonth, int(day.Month())) {
+		return false
+	}
+	daysInMonth := time.Date(day.Year(), day.Month()+1, 0, 0, 0, 0, 0, time.UTC).Day()
+	if len(r.byMonthDay) > 0 {
+		ok := false
+		for _, md := range r.byMonthDay {
+			if md == day.Day() || (md < 0 && daysInMonth+md+1 == day.Day()) {
+				ok = true
+				break
+			}
+		}
+		if !ok {
+			return false
+		}
+	}
+	if len(r.byDay) == 0 {
+		return true
+	}
+	// number of weekday is counted in month for MONTHLY and for YEARLY with BYMONTH, else in year
+	pos, size := day.Day(), daysInMonth
+	if r.freq == Yearly && len(r.byMonth) == 0 {
+		pos = day.YearDay()
+		size = time.Date(day.Year(), time.December, 31, 0, 0, 0, 0, time.UTC).YearDay()
+	}
+	for _, wd := range r.byDay {
+		if wd.weekday != day.Weekday() {
+			continue
+		}
+		if wd.n == 0 || (wd.n > 0 && (pos-1)/7+1 == wd.n) || (wd.n < 0 && (size-pos)/7+1 == -wd.n) {
+			return true
+		}
+	}
+	return false
+}
+
+func contains(nums []int, n int) bool {
+	for _, v := range nums {
+		if v == n {
+			return true
+		}
+	}
+	return false
+}
+
+// unique - sorted 'list' without equal times
+func unique(list []time.Time) []time.Time {
+	out := list[:0]
+	for i, t := range list {
+		if i == 0 || !t.Equal(out[len(out)-1]) {
+			out = append(out, t)
+		}
+	}
+	return out
+}
+
+// setPos - BYSETPOS, positions from 1 or from the end (-1 - last) of sorted 'list'
+func setPos(list []time.Time, positions []int) []time.Time {
+	var out []time.Time
+	for _, p := range positions {
+		i := p - 1
+		if p < 0 {
+			i = len(list) + p
+		}
+		if i >= 0 && i < len(list) {
+			out = append(out, list[i])
+		}
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Before(out[j]) })
+	return unique(out)
+}
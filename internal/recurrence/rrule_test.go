@@ -0,0 +1,171 @@
+package recurrence
+
+import (
+	"log"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var seriesTestData = []struct {
+	rrule    string
+	timeZone string
+	start    string
+	until    string
+	expected []string
+	msg      string
+}{
+	{
+		rrule:    "FREQ=WEEKLY;BYDAY=MO,TH",
+		timeZone: "Europe/Berlin",
+		start:    "2025-03-24T09:00:00+01:00",
+		until:    "2025-04-04T00:00:00Z",
+		expected: []string{
+			"2025-03-24T09:00:00+01:00",
+			"2025-03-27T09:00:00+01:00",
+			"2025-03-31T09:00:00+02:00",
+			"2025-04-03T09:00:00+02:00",
+		},
+		msg: "valid - weekly, local time through start of daylight saving time",
+	},
+	{
+		rrule:    "FREQ=DAILY;COUNT=3",
+		timeZone: "America/New_York",
+		start:    "2025-03-08T02:30:00-05:00",
+		until:    "2025-12-31T00:00:00Z",
+		expected: []string{
+			"2025-03-08T02:30:00-05:00",
+			"2025-03-09T03:30:00-04:00",
+			"2025-03-10T02:30:00-04:00",
+		},
+		msg: "valid - time in gap of daylight saving time is moved by the gap",
+	},
+	{
+		rrule:    "FREQ=DAILY;UNTIL=20251103T000000Z",
+		timeZone: "America/New_York",
+		start:    "2025-11-01T01:30:00-04:00",
+		until:    "2025-12-31T00:00:00Z",
+		expected: []string{
+			"2025-11-01T01:30:00-04:00",
+			"2025-11-02T01:30:00-04:00",
+		},
+		msg: "valid - time which is twice is the first, UNTIL in UTC",
+	},
+	{
+		rrule:    "FREQ=MONTHLY;BYDAY=-1FR;UNTIL=20250601",
+		timeZone: "UTC",
+		start:    "2025-01-31T18:00:00Z",
+		until:    "2025-12-31T00:00:00Z",
+		expected: []string{
+			"2025-01-31T18:00:00Z",
+			"2025-02-28T18:00:00Z",
+			"2025-03-28T18:00:00Z",
+			"2025-04-25T18:00:00Z",
+			"2025-05-30T18:00:00Z",
+		},
+		msg: "valid - last Friday of month, UNTIL is date",
+	},
+	{
+		rrule:    "FREQ=MONTHLY;BYMONTHDAY=31;COUNT=3",
+		timeZone: "",
+		start:    "2025-01-31T08:00:00Z",
+		until:    "2025-12-31T00:00:00Z",
+		expected: []string{
+			"2025-01-31T08:00:00Z",
+			"2025-03-31T08:00:00Z",
+			"2025-05-31T08:00:00Z",
+		},
+		msg: "valid - months without day are skipped",
+	},
+	{
+		rrule:    "FREQ=MONTHLY;BYDAY=MO,TU,WE,TH,FR;BYSETPOS=-1;COUNT=2",
+		timeZone: "Asia/Tokyo",
+		start:    "2025-05-30T17:00:00+09:00",
+		until:    "2025-12-31T00:00:00Z",
+		expected: []string{
+			"2025-05-30T17:00:00+09:00",
+			"2025-06-30T17:00:00+09:00",
+		},
+		msg: "valid - last work day of month",
+	},
+	{
+		rrule:    "FREQ=WEEKLY;INTERVAL=2;BYDAY=SA;BYHOUR=10,16",
+		timeZone: "Europe/London",
+		start:    "2025-10-18T10:00:00+01:00",
+		until:    "2025-11-02T00:00:00Z",
+		expected: []string{
+			"2025-10-18T10:00:00+01:00",
+			"2025-10-18T16:00:00+01:00",
+			"2025-11-01T10:00:00Z",
+			"2025-11-01T16:00:00Z",
+		},
+		msg: "valid - every other week, two times of day, end of daylight saving time",
+	},
+	{
+		rrule:    "FREQ=YEARLY",
+		timeZone: "UTC",
+		start:    "2024-02-29T12:00:00Z",
+		until:    "2032-12-31T00:00:00Z",
+		expected: []string{
+			"2024-02-29T12:00:00Z",
+			"2028-02-29T12:00:00Z",
+			"2032-02-29T12:00:00Z",
+		},
+		msg: "valid - yearly on 29 February, only leap years",
+	},
+}
+
+func TestSeries(t *testing.T) {
+	asserts := assert.New(t)
+	requires := require.New(t)
+
+	for i, test := range seriesTestData {
+		log.Printf("\t %d test series: %s\n", i+1, test.rrule)
+		start, err := time.Parse(time.RFC3339, test.start)
+		requires.NoError(err)
+		until, err := time.Parse(time.RFC3339, test.until)
+		requires.NoError(err)
+		series, err := NewSeries(test.rrule, test.timeZone, start)
+		requires.NoError(err, test.msg)
+
+		occurrences := series.Between(start, until, 100)
+		list := make([]string, len(occurrences))
+		for j, occ := range occurrences {
+			list[j] = occ.Format(time.RFC3339)
+		}
+		asserts.Equal(test.expected, list, test.msg)
+	}
+}
+
+var parseTestData = []struct {
+	rrule  string
+	reason string
+	msg    string
+}{
+	{rrule: "RRULE:FREQ=DAILY;INTERVAL=2", msg: "valid - prefix RRULE"},
+	{rrule: "", reason: "invalid rrule - empty", msg: "invalid - empty"},
+	{rrule: "INTERVAL=2", reason: "invalid rrule - FREQ is required", msg: "invalid - no FREQ"},
+	{rrule: "FREQ=MINUTELY", reason: "invalid rrule - FREQ=MINUTELY is not supported", msg: "invalid - frequency"},
+	{rrule: "FREQ=DAILY;FREQ=WEEKLY", reason: "invalid rrule - part FREQ is repeated", msg: "invalid - repeated part"},
+	{rrule: "FREQ=DAILY;COUNT=2;UNTIL=20250101", reason: "invalid rrule - COUNT and UNTIL must not be together", msg: "invalid - COUNT and UNTIL"},
+	{rrule: "FREQ=WEEKLY;BYDAY=1MO", reason: "invalid rrule - BYDAY with number must not be with FREQ=WEEKLY", msg: "invalid - number of weekday"},
+	{rrule: "FREQ=MONTHLY;BYMONTHDAY=0", reason: `invalid rrule - "0" is not in range -31..31`, msg: "invalid - day of month"},
+	{rrule: "FREQ=YEARLY;BYWEEKNO=20", reason: "invalid rrule - part BYWEEKNO is not supported", msg: "invalid - unsupported part"},
+}
+
+func TestParse(t *testing.T) {
+	asserts := assert.New(t)
+
+	for i, test := range parseTestData {
+		log.Printf("\t %d test parse: %s\n", i+1, test.rrule)
+		_, err := Parse(test.rrule)
+		if test.reason == "" {
+			asserts.NoError(err, test.msg)
+			continue
+		}
+		asserts.ErrorIs(err, ErrRecurrenceRule, test.msg)
+		asserts.EqualError(err, test.reason, test.msg)
+	}
+}
@@ -0,0 +1,168 @@
+// recurrence - recurring 'Task' moves to the next occurrence on completion or when period is elapsed
+package recurrence
+
+import (
+	"context"
+	"errors"
+	"log"
+	"time"
+
+	"github.com/Ekvo/golang-chi-postgres-api/internal/jobs"
+	"github.com/Ekvo/golang-chi-postgres-api/internal/model"
+)
+
+// parameters of scheduler
+const (
+	// KindAdvance - periodic job of moving of 'Task' whose period is elapsed
+	KindAdvance = "recurrence.advance"
+
+	advanceInterval = time.Minute
+	advanceLimit    = 500
+)
+
+var (
+	// ErrRecurrenceNone - 'Task' has no RRULE or no due time
+	ErrRecurrenceNone = errors.New("task is not recurring")
+
+	// errNotElapsed - 'Task' is changed by other request after scan, nothing to move
+	errNotElapsed = errors.New("period is not elapsed")
+)
+
+// Complete - current occurrence of 'task' is done, 'task' moves to the next occurrence
+//
+// after the last occurrence of series 'Recurrence' is removed and 'DueAt' is kept
+func Complete(task model.Task, now time.Time) (model.Task, error) {
+	series, err := seriesOf(task)
+	if err != nil {
+		return task, err
+	}
+	next, ok := series.Next(*task.DueAt)
+	if !ok {
+		task.Recurrence = nil
+		updatedAt := now.UTC()
+		task.UpdatedAt = &updatedAt
+		return task, nil
+	}
+	return moveTo(task, next, now), nil
+}
+
+// Elapse - 'task' moves to its last occurrence which has come till 'now', false - period of current occurrence is not elapsed
+func Elapse(task model.Task, now time.Time) (model.Task, bool, error) {
+	series, err := seriesOf(task)
+	if err != nil {
+		return task, false, err
+	}
+	last, ok := series.Last(*task.DueAt, now)
+	if !ok {
+		return task, false, nil
+	}
+	return moveTo(task, last, now), true, nil
+}
+
+// Occurrences - occurrences of 'task' from current 'DueAt' till 'until', at most 'limit'
+//
+// not recurring 'Task' has one occurrence - 'DueAt', times are in time zone of 'Task'
+func Occurrences(task model.Task, until time.Time, limit int) ([]time.Time, error) {
+	if task.DueAt == nil {
+		return []time.Time{}, nil
+	}
+	if task.Recurrence == nil {
+		loc, err := LoadLocation(task.TimeZone)
+		if err != nil {
+			return nil, err
+		}
+		if task.DueAt.After(until) || limit < 1 {
+			return []time.Time{}, nil
+		}
+		return []time.Time{task.DueAt.In(loc)}, nil
+	}
+	series, err := seriesOf(task)
+	if err != nil {
+		return nil, err
+	}
+	list := series.Between(*task.DueAt, until, limit)
+	if list == nil {
+		list = []time.Time{}
+	}
+	return list, nil
+}
+
+func seriesOf(task model.Task) (*Series, error) {
+	if task.Recurrence == nil || task.DueAt == nil {
+		return nil, ErrRecurrenceNone
+	}
+	return NewSeries(task.Recurrence.RRule, task.TimeZone, task.Recurrence.Start)
+}
+
+// moveTo - 'DueAt' is 'at', 'RemindAt' keeps its offset to 'DueAt', so reminder of new occurrence is sent again
+func moveTo(task model.Task, at, now time.Time) model.Task {
+	at = at.UTC()
+	if task.RemindAt != nil {
+		remindAt := at.Add(task.RemindAt.Sub(*task.DueAt)).UTC()
+		task.RemindAt = &remindAt
+	}
+	updatedAt := now.UTC()
+	task.DueAt = &at
+	task.UpdatedAt = &updatedAt
+	return task
+}
+
+// Store - recurring 'Task' and their change in transaction
+type Store interface {
+	model.TaskRecurrences
+	model.TaskModify
+}
+
+// Scheduler - moves recurring 'Task' whose period is elapsed, runs as periodic job of 'jobs.Pool'
+type Scheduler struct {
+	db  Store
+	now func() time.Time
+}
+
+func NewScheduler(db Store) *Scheduler {
+	return &Scheduler{db: db, now: time.Now}
+}
+
+// Register - 'Advance' is periodic job of 'pool'
+func (s *Scheduler) Register(pool *jobs.Pool) {
+	pool.Every(KindAdvance, advanceInterval, s.Advance)
+}
+
+// Advance - each 'Task' whose next occurrence has come moves to it by 'PatchTask' (row is locked, event is written),
+// error of one 'Task' is logged, error of scan - error of job
+func (s *Scheduler) Advance(ctx context.Context, job model.Job) error {
+	now := s.now().UTC()
+	scan := model.RecurrenceScan{Due: now, Limit: advanceLimit}
+	for {
+		tasks, err := s.db.RecurringTasks(ctx, scan)
+		if err != nil {
+			return err
+		}
+		for _, task := range tasks {
+			if _, elapsed, err := Elapse(task, now); err != nil || !elapsed {
+				if err != nil {
+					log.Printf("recurrence: rrule of task %d error - %v", task.ID, err)
+				}
+				continue
+			}
+			_, err := s.db.PatchTask(ctx, model.TaskPatch{ID: task.ID, Apply: func(task model.Task) (model.Task, error) {
+				moved, elapsed, err := Elapse(task, now)
+				if err == nil && !elapsed {
+					err = errNotElapsed
+				}
+				return moved, err
+			}})
+			if err == nil || errors.Is(err, errNotElapsed) || errors.Is(err, ErrRecurrenceNone) {
+				continue
+			}
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+			log.Printf("recurrence: move of task %d error - %v", task.ID, err)
+		}
+		if len(tasks) < scan.Limit {
+			return nil
+		}
+		scan.AfterID = tasks[len(tasks)-1].ID
+	}
+}
@@ -0,0 +1,92 @@
+package recurrence
+
+import (
+	"context"
+	"errors"
+	"log"
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/Ekvo/golang-chi-postgres-api/internal/model"
+)
+
+// storeMock - 'Store' in memory, 'patched' - number of saved 'PatchTask'
+type storeMock struct {
+	tasks   map[uint]model.Task
+	patched int
+}
+
+func (m *storeMock) RecurringTasks(ctx context.Context, data any) ([]model.Task, error) {
+	scan := data.(model.RecurrenceScan)
+	var tasks []model.Task
+	for _, task := range m.tasks {
+		if task.Recurrence != nil && !task.DueAt.After(scan.Due) && task.ID > scan.AfterID {
+			tasks = append(tasks, task)
+		}
+	}
+	sort.Slice(tasks, func(i, j int) bool { return tasks[i].ID < tasks[j].ID })
+	return tasks[:min(len(tasks), scan.Limit)], ctx.Err()
+}
+
+func (m *storeMock) PatchTask(ctx context.Context, data any) (model.Task, error) {
+	patch := data.(model.TaskPatch)
+	task, ok := m.tasks[patch.ID]
+	if !ok {
+		return model.Task{}, errors.New("not found")
+	}
+	task, err := patch.Apply(task)
+	if err != nil {
+		return model.Task{}, err
+	}
+	m.tasks[task.ID] = task
+	m.patched++
+	return task, ctx.Err()
+}
+
+func at(value string) *time.Time {
+	t, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		panic(err)
+	}
+	t = t.UTC()
+	return &t
+}
+
+func TestSchedulerAdvance(t *testing.T) {
+	asserts := assert.New(t)
+	requires := require.New(t)
+
+	weekly := &model.Recurrence{RRule: "FREQ=WEEKLY;BYDAY=MO", Start: *at("2025-03-03T09:00:00+01:00")}
+	db := &storeMock{tasks: map[uint]model.Task{
+		1: {ID: 1, Description: "missed weeks", DueAt: at("2025-03-03T09:00:00+01:00"), TimeZone: "Europe/Berlin",
+			RemindAt: at("2025-03-03T08:30:00+01:00"), Recurrence: weekly},
+		2: {ID: 2, Description: "current week", DueAt: at("2025-03-31T09:00:00+02:00"), TimeZone: "Europe/Berlin",
+			Recurrence: weekly},
+		3: {ID: 3, Description: "one time", DueAt: at("2025-03-03T09:00:00+01:00")},
+	}}
+	scheduler := NewScheduler(db)
+	scheduler.now = func() time.Time { return *at("2025-04-02T12:00:00Z") }
+
+	log.Print("\t 1 test advance: task moves to the last occurrence which has come\n")
+	requires.NoError(scheduler.Advance(context.Background(), model.Job{}))
+	asserts.Equal(1, db.patched)
+	moved := db.tasks[1]
+	asserts.Equal(*at("2025-03-31T09:00:00+02:00"), *moved.DueAt, "missed occurrences are skipped")
+	asserts.Equal(*at("2025-03-31T08:30:00+02:00"), *moved.RemindAt, "reminder keeps offset to due time")
+	asserts.Equal(weekly.Start, moved.Recurrence.Start, "DTSTART is kept")
+	asserts.NotNil(moved.UpdatedAt)
+	asserts.Nil(db.tasks[2].UpdatedAt, "period of current occurrence is not elapsed")
+	asserts.Nil(db.tasks[3].UpdatedAt, "not recurring")
+
+	log.Print("\t 2 test advance: next run does not change tasks\n")
+	requires.NoError(scheduler.Advance(context.Background(), model.Job{}))
+	asserts.Equal(1, db.patched)
+
+	log.Print("\t 3 test complete: not recurring task\n")
+	_, err := Complete(db.tasks[3], time.Now())
+	asserts.ErrorIs(err, ErrRecurrenceNone)
+}
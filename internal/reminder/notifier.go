@@ -0,0 +1,268 @@
+// reminder - channels of reminders of 'Task'
+package reminder
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"mime"
+	"net"
+	"net/http"
+	"net/smtp"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/Ekvo/golang-chi-postgres-api/internal/config"
+	c "github.com/Ekvo/golang-chi-postgres-api/pkg/common"
+)
+
+// channels of reminders, name is a part of key of deduplication
+const (
+	ChannelSMTP  = "smtp"
+	ChannelSlack = "slack"
+	ChannelHTTP  = "http"
+)
+
+const (
+	// requestTimeout - one POST of 'Slack' or 'HTTP' when context has no deadline
+	requestTimeout = 10 * time.Second
+	// maxResponseRead - body of response is read for reuse of connection
+	maxResponseRead = 4 << 10
+)
+
+var ErrReminderURL = errors.New("invalid url of channel")
+
+// Message - reminder of one 'Task', 'Subject' - first line of template, 'Text' - other lines
+type Message struct {
+	TaskID   uint   `json:"task_id"`
+	User     string `json:"user"`
+	RemindAt string `json:"remind_at"`
+	Subject  string `json:"subject"`
+	Text     string `json:"text"`
+}
+
+// Notifier - channel of reminders, nil - message is accepted by receiver
+type Notifier interface {
+	Channel() string
+	Notify(ctx context.Context, msg Message) error
+}
+
+// NewNotifiers - channels of 'config.Config' which are set, in order smtp, slack, http
+func NewNotifiers(cfg *config.Config) ([]Notifier, error) {
+	var notifiers []Notifier
+	if cfg.RemindSMTPURL != "" {
+		n, err := NewSMTPNotifier(cfg.RemindSMTPURL)
+		if err != nil {
+			return nil, err
+		}
+		notifiers = append(notifiers, n)
+	}
+	if cfg.RemindSlackURL != "" {
+		notifiers = append(notifiers, NewSlackNotifier(cfg.RemindSlackURL))
+	}
+	if cfg.RemindHTTPURL != "" {
+		notifiers = append(notifiers, NewHTTPNotifier(cfg.RemindHTTPURL))
+	}
+	return notifiers, nil
+}
+
+// SMTPNotifier - plain text email, "smtp" - STARTTLS if server supports it, "smtps" - TLS from start
+//
+// credentials of URL are used by PLAIN auth, net/smtp sends them only over TLS or to localhost
+type SMTPNotifier struct {
+	addr        string
+	host        string
+	implicitTLS bool
+	auth        smtp.Auth
+	from        string
+	to          []string
+}
+
+func NewSMTPNotifier(rawURL string) (*SMTPNotifier, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil || (u.Scheme != "smtp" && u.Scheme != "smtps") || u.Hostname() == "" {
+		return nil, ErrReminderURL
+	}
+	query := u.Query()
+	n := &SMTPNotifier{
+		addr:        u.Host,
+		host:        u.Hostname(),
+		implicitTLS: u.Scheme == "smtps",
+		from:        query.Get("from"),
+	}
+	for _, to := range strings.Split(query.Get("to"), ",") {
+		if to = strings.TrimSpace(to); to != "" {
+			n.to = append(n.to, to)
+		}
+	}
+	if n.from == "" || len(n.to) == 0 {
+		return nil, ErrReminderURL
+	}
+	if u.Port() == "" {
+		port := "25"
+		if n.implicitTLS {
+			port = "465"
+		}
+		n.addr = net.JoinHostPort(n.host, port)
+	}
+	if user := u.User.Username(); user != "" {
+		password, _ := u.User.Password()
+		n.auth = smtp.PlainAuth("", user, password, n.host)
+	}
+	return n, nil
+}
+
+func (n *SMTPNotifier) Channel() string {
+	return ChannelSMTP
+}
+
+func (n *SMTPNotifier) Notify(ctx context.Context, msg Message) error {
+	dialer := net.Dialer{Timeout: requestTimeout}
+	conn, err := dialer.DialContext(ctx, "tcp", n.addr)
+	if err != nil {
+		return err
+	}
+	deadline, ok := ctx.Deadline()
+	if !ok {
+		deadline = time.Now().Add(requestTimeout)
+	}
+	if err := conn.SetDeadline(deadline); err != nil {
+		_ = conn.Close()
+		return err
+	}
+	if n.implicitTLS {
+		conn = tls.Client(conn, &tls.Config{ServerName: n.host})
+	}
+	client, err := smtp.NewClient(conn, n.host)
+	if err != nil {
+		_ = conn.Close()
+		return err
+	}
+	defer func() {
+		if err := client.Close(); err != nil && !errors.Is(err, net.ErrClosed) {
+			log.Printf("reminder: smtp client.Close error - %v", err)
+		}
+	}()
+	if ok, _ := client.Extension("STARTTLS"); ok && !n.implicitTLS {
+		if err := client.StartTLS(&tls.Config{ServerName: n.host}); err != nil {
+			return err
+		}
+	}
+	if n.auth != nil {
+		if err := client.Auth(n.auth); err != nil {
+			return err
+		}
+	}
+	if err := client.Mail(n.from); err != nil {
+		return err
+	}
+	for _, to := range n.to {
+		if err := client.Rcpt(to); err != nil {
+			return err
+		}
+	}
+	w, err := client.Data()
+	if err != nil {
+		return err
+	}
+	if _, err := w.Write(n.email(msg)); err != nil {
+		return err
+	}
+	if err := w.Close(); err != nil {
+		return err
+	}
+	return client.Quit()
+}
+
+// email - headers and body of 'msg', lines end with CRLF
+func (n *SMTPNotifier) email(msg Message) []byte {
+	buf := bytes.Buffer{}
+	fmt.Fprintf(&buf, "From: %s\r\n", n.from)
+	fmt.Fprintf(&buf, "To: %s\r\n", strings.Join(n.to, ", "))
+	fmt.Fprintf(&buf, "Subject: %s\r\n", mime.QEncoding.Encode("utf-8", msg.Subject))
+	fmt.Fprintf(&buf, "Date: %s\r\n", time.Now().UTC().Format(time.RFC1123Z))
+	fmt.Fprintf(&buf, "X-Task-Reminder: %d %s\r\n", msg.TaskID, msg.RemindAt)
+	buf.WriteString("MIME-Version: 1.0\r\n")
+	buf.WriteString("Content-Type: text/plain; charset=utf-8\r\n")
+	buf.WriteString("Content-Transfer-Encoding: 8bit\r\n\r\n")
+	text := strings.ReplaceAll(msg.Text, "\r\n", "\n")
+	buf.WriteString(strings.ReplaceAll(text, "\n", "\r\n"))
+	buf.WriteString("\r\n")
+	return buf.Bytes()
+}
+
+// SlackNotifier - POST {"text": "..."} to Slack-compatible incoming webhook (Slack, Mattermost, Rocket.Chat)
+type SlackNotifier struct {
+	url    string
+	client *http.Client
+}
+
+func NewSlackNotifier(url string) *SlackNotifier {
+	return &SlackNotifier{url: url, client: &http.Client{Timeout: requestTimeout}}
+}
+
+func (n *SlackNotifier) Channel() string {
+	return ChannelSlack
+}
+
+func (n *SlackNotifier) Notify(ctx context.Context, msg Message) error {
+	text := "*" + msg.Subject + "*"
+	if msg.Text != "" {
+		text += "\n" + msg.Text
+	}
+	return postJSON(ctx, n.client, n.url, map[string]string{"text": text})
+}
+
+// HTTPNotifier - POST of 'Message' as JSON
+type HTTPNotifier struct {
+	url    string
+	client *http.Client
+}
+
+func NewHTTPNotifier(url string) *HTTPNotifier {
+	return &HTTPNotifier{url: url, client: &http.Client{Timeout: requestTimeout}}
+}
+
+func (n *HTTPNotifier) Channel() string {
+	return ChannelHTTP
+}
+
+func (n *HTTPNotifier) Notify(ctx context.Context, msg Message) error {
+	return postJSON(ctx, n.client, n.url, msg)
+}
+
+// postJSON - any 2xx status - success
+func postJSON(ctx context.Context, client *http.Client, url string, body any) error {
+	line, err := json.Marshal(body)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(line))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", c.MediaJSON)
+	req.Header.Set("User-Agent", "task-reminder/1")
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if _, err := io.Copy(io.Discard, io.LimitReader(resp.Body, maxResponseRead)); err != nil {
+			log.Printf("reminder: read of response error - %v", err)
+		}
+		if err := resp.Body.Close(); err != nil {
+			log.Printf("reminder: resp.Body.Close error - %v", err)
+		}
+	}()
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("status %d", resp.StatusCode)
+	}
+	return nil
+}
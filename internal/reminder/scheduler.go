@@ -0,0 +1,179 @@
+// reminder - scheduler of reminders of 'Task' on background jobs
+package reminder
+
+import (
+	"bytes"
+	"context"
+	"log"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/Ekvo/golang-chi-postgres-api/internal/config"
+	"github.com/Ekvo/golang-chi-postgres-api/internal/jobs"
+	"github.com/Ekvo/golang-chi-postgres-api/internal/model"
+)
+
+// parameters of scheduler
+const (
+	// KindScan - periodic job of scan of due reminders
+	KindScan = "reminders.scan"
+	// Window - due reminder older than it is not sent, longer than quiet hours
+	Window = 24 * time.Hour
+	// QuietAll - key of 'config.QuietHours' of users without own hours
+	QuietAll = "*"
+
+	// DefaultTemplate - first line is subject, fields of 'TemplateData'
+	DefaultTemplate = `Reminder: {{.Description}}
+Task {{.ID}} is due at {{.RemindAt}}{{if .Note}}
+
+{{.Note}}{{end}}`
+
+	scanInterval  = 15 * time.Second
+	scanLimit     = 500
+	notifyTimeout = 10 * time.Second
+)
+
+// TemplateData - data of template of reminder, 'RemindAt' is in time zone of quiet hours of user
+type TemplateData struct {
+	ID          uint
+	Description string
+	Note        string
+	RemindAt    string
+	User        string
+}
+
+// Scheduler - sends due reminders to all 'Notifier', runs as periodic job of 'jobs.Pool'
+//
+// each channel of reminder is claimed in store before sending, so reminder is sent once
+// after restart and by one of replicas, failed channel is released and sent by the next scan
+type Scheduler struct {
+	db        model.TaskReminders
+	notifiers []Notifier
+	tmpl      *template.Template
+	quiet     map[string]config.QuietHours
+	limit     int
+	now       func() time.Time
+}
+
+func NewScheduler(db model.TaskReminders, cfg *config.Config) (*Scheduler, error) {
+	notifiers, err := NewNotifiers(cfg)
+	if err != nil {
+		return nil, err
+	}
+	text := cfg.RemindTemplate
+	if text == "" {
+		text = DefaultTemplate
+	}
+	tmpl, err := template.New("remind").Option("missingkey=error").Parse(text)
+	if err != nil {
+		return nil, err
+	}
+	quiet, err := cfg.QuietHoursOfUsers()
+	if err != nil {
+		return nil, err
+	}
+	return &Scheduler{db: db, notifiers: notifiers, tmpl: tmpl, quiet: quiet, limit: scanLimit, now: time.Now}, nil
+}
+
+// Enabled - at least one channel is set
+func (s *Scheduler) Enabled() bool {
+	return len(s.notifiers) > 0
+}
+
+// Register - 'Scan' is periodic job of 'pool'
+func (s *Scheduler) Register(pool *jobs.Pool) {
+	pool.Every(KindScan, scanInterval, s.Scan)
+}
+
+// Scan - send due reminders by pages, error of channel is logged, error of store - error of job
+//
+// reminders in quiet hours and failed ones stay due, the next page is after them, so they do not hold back later ones
+func (s *Scheduler) Scan(ctx context.Context, job model.Job) error {
+	channels := make([]string, len(s.notifiers))
+	for i, n := range s.notifiers {
+		channels[i] = n.Channel()
+	}
+	scan := model.ReminderScan{Channels: channels, Window: Window, Limit: s.limit}
+	now := s.now()
+	for {
+		tasks, err := s.db.DueReminders(ctx, scan)
+		if err != nil {
+			return err
+		}
+		for _, task := range tasks {
+			if task.RemindAt == nil {
+				continue
+			}
+			qh, quiet := s.quietHours(task.RemindUser)
+			if quiet && qh.Contains(now) {
+				continue
+			}
+			msg, err := s.message(task, qh.Location)
+			if err != nil {
+				log.Printf("reminder: template of task %d error - %v", task.ID, err)
+				continue
+			}
+			for _, n := range s.notifiers {
+				if err := s.send(ctx, n, task, msg); err != nil {
+					return err
+				}
+			}
+		}
+		if len(tasks) < scan.Limit {
+			return nil
+		}
+		last := tasks[len(tasks)-1]
+		scan.After, scan.AfterID = *last.RemindAt, last.ID
+	}
+}
+
+// quietHours - own hours of 'user' or 'QuietAll', false - no quiet hours
+func (s *Scheduler) quietHours(user string) (config.QuietHours, bool) {
+	if qh, ok := s.quiet[user]; ok {
+		return qh, true
+	}
+	if qh, ok := s.quiet[QuietAll]; ok {
+		return qh, true
+	}
+	return config.QuietHours{Location: time.UTC}, false
+}
+
+// message - 'Message' of 'task' by template
+func (s *Scheduler) message(task model.Task, loc *time.Location) (Message, error) {
+	remindAt := task.RemindAt.In(loc)
+	buf := bytes.Buffer{}
+	if err := s.tmpl.Execute(&buf, TemplateData{
+		ID:          task.ID,
+		Description: task.Description,
+		Note:        task.Note,
+		RemindAt:    remindAt.Format("2006-01-02 15:04 MST"),
+		User:        task.RemindUser,
+	}); err != nil {
+		return Message{}, err
+	}
+	subject, text, _ := strings.Cut(strings.TrimSpace(buf.String()), "\n")
+	return Message{
+		TaskID:   task.ID,
+		User:     task.RemindUser,
+		RemindAt: remindAt.Format(time.RFC3339),
+		Subject:  strings.TrimSpace(subject),
+		Text:     strings.TrimSpace(text),
+	}, nil
+}
+
+// send - claim of channel, 'Notify', release of claim after error of 'Notify'
+func (s *Scheduler) send(ctx context.Context, n Notifier, task model.Task, msg Message) error {
+	claim := model.ReminderClaim{TaskID: task.ID, RemindAt: *task.RemindAt, Channel: n.Channel()}
+	claimed, err := s.db.ClaimReminder(ctx, claim)
+	if err != nil || !claimed {
+		return err
+	}
+	notifyCtx, cancel := context.WithTimeout(ctx, notifyTimeout)
+	defer cancel()
+	if err := n.Notify(notifyCtx, msg); err != nil {
+		log.Printf("reminder: %s of task %d error - %v", n.Channel(), task.ID, err)
+		return s.db.ReleaseReminder(ctx, claim)
+	}
+	return nil
+}
@@ -0,0 +1,347 @@
+package reminder
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/Ekvo/golang-chi-postgres-api/internal/config"
+	"github.com/Ekvo/golang-chi-postgres-api/internal/model"
+)
+
+// remindersMock - 'model.TaskReminders' in memory, 'now' is the clock of store
+type remindersMock struct {
+	mu     sync.Mutex
+	now    time.Time
+	tasks  []model.Task
+	claims map[model.ReminderClaim]bool
+}
+
+func (m *remindersMock) DueReminders(ctx context.Context, data any) ([]model.Task, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	scan := data.(model.ReminderScan)
+	var due []model.Task
+	for _, task := range m.tasks {
+		if task.RemindAt == nil || task.RemindAt.After(m.now) || !task.RemindAt.After(m.now.Add(-scan.Window)) {
+			continue
+		}
+		if task.RemindAt.Before(scan.After) || (task.RemindAt.Equal(scan.After) && task.ID <= scan.AfterID) {
+			continue
+		}
+		for _, channel := range scan.Channels {
+			if !m.claims[model.ReminderClaim{TaskID: task.ID, RemindAt: *task.RemindAt, Channel: channel}] {
+				due = append(due, task)
+				break
+			}
+		}
+	}
+	sort.Slice(due, func(i, j int) bool {
+		if !due[i].RemindAt.Equal(*due[j].RemindAt) {
+			return due[i].RemindAt.Before(*due[j].RemindAt)
+		}
+		return due[i].ID < due[j].ID
+	})
+	if len(due) > scan.Limit {
+		due = due[:scan.Limit]
+	}
+	return due, ctx.Err()
+}
+
+func (m *remindersMock) ClaimReminder(ctx context.Context, data any) (bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	claim := data.(model.ReminderClaim)
+	if m.claims[claim] {
+		return false, nil
+	}
+	m.claims[claim] = true
+	return true, ctx.Err()
+}
+
+func (m *remindersMock) ReleaseReminder(ctx context.Context, data any) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	delete(m.claims, data.(model.ReminderClaim))
+	return ctx.Err()
+}
+
+// email - message received by 'smtpStandIn'
+type email struct {
+	from string
+	to   []string
+	data string
+}
+
+// smtpStandIn - local SMTP server without TLS and auth, keeps received messages
+type smtpStandIn struct {
+	ln     net.Listener
+	mu     sync.Mutex
+	emails []email
+}
+
+func newSMTPStandIn(t *testing.T) *smtpStandIn {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	s := &smtpStandIn{ln: ln}
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go s.serve(conn)
+		}
+	}()
+	t.Cleanup(func() { _ = ln.Close() })
+	return s
+}
+
+func (s *smtpStandIn) received() []email {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]email(nil), s.emails...)
+}
+
+func (s *smtpStandIn) serve(conn net.Conn) {
+	defer conn.Close()
+	r := bufio.NewReader(conn)
+	reply := func(line string) bool {
+		_, err := io.WriteString(conn, line+"\r\n")
+		return err == nil
+	}
+	if !reply("220 stand-in ESMTP") {
+		return
+	}
+	msg := email{}
+	for {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			return
+		}
+		cmd := strings.ToUpper(strings.TrimSpace(line))
+		switch {
+		case strings.HasPrefix(cmd, "EHLO"):
+			reply("250-stand-in\r\n250 8BITMIME")
+		case strings.HasPrefix(cmd, "MAIL FROM:"):
+			msg = email{from: address(line)}
+			reply("250 OK")
+		case strings.HasPrefix(cmd, "RCPT TO:"):
+			msg.to = append(msg.to, address(line))
+			reply("250 OK")
+		case cmd == "DATA":
+			reply("354 end with .")
+			data := strings.Builder{}
+			for {
+				text, err := r.ReadString('\n')
+				if err != nil {
+					return
+				}
+				if text == ".\r\n" {
+					break
+				}
+				data.WriteString(strings.TrimPrefix(text, "."))
+			}
+			msg.data = data.String()
+			s.mu.Lock()
+			s.emails = append(s.emails, msg)
+			s.mu.Unlock()
+			reply("250 queued")
+		case cmd == "QUIT":
+			reply("221 bye")
+			return
+		default:
+			reply("250 OK")
+		}
+	}
+}
+
+// address - "<a@b.c>" of command MAIL or RCPT without parameters
+func address(line string) string {
+	_, addr, _ := strings.Cut(line, "<")
+	addr, _, _ = strings.Cut(addr, ">")
+	return addr
+}
+
+// httpStandIn - receiver of 'Slack' and 'HTTP', 'failures' first requests get 503
+type httpStandIn struct {
+	mu       sync.Mutex
+	failures int
+	bodies   []string
+}
+
+func (h *httpStandIn) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	body, err := io.ReadAll(r.Body)
+	if err != nil || r.Header.Get("Content-Type") != "application/json" {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.failures > 0 {
+		h.failures--
+		w.WriteHeader(http.StatusServiceUnavailable)
+		return
+	}
+	h.bodies = append(h.bodies, string(body))
+	w.WriteHeader(http.StatusOK)
+}
+
+func (h *httpStandIn) received() []string {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return append([]string(nil), h.bodies...)
+}
+
+func TestSchedulerScan(t *testing.T) {
+	asserts := assert.New(t)
+	requires := require.New(t)
+
+	mailer := newSMTPStandIn(t)
+	slack, hook := &httpStandIn{}, &httpStandIn{failures: 1}
+	slackSrv, hookSrv := httptest.NewServer(slack), httptest.NewServer(hook)
+	defer slackSrv.Close()
+	defer hookSrv.Close()
+
+	now := time.Date(2025, 3, 1, 22, 30, 0, 0, time.UTC)
+	due := now.Add(-time.Minute)
+	night := now.Add(-2 * time.Minute)
+	late := now.Add(-2 * Window)
+	future := now.Add(2 * Window)
+	db := &remindersMock{
+		now: now,
+		tasks: []model.Task{
+			{ID: 1, Description: "Call the bank", Note: "ask about card", RemindAt: &due, RemindUser: "alice"},
+			{ID: 2, Description: "Night call", RemindAt: &night, RemindUser: "bob"},
+			{ID: 3, Description: "Too late", RemindAt: &late, RemindUser: "alice"},
+			{ID: 4, Description: "Not yet", RemindAt: &future, RemindUser: "alice"},
+		},
+		claims: map[model.ReminderClaim]bool{},
+	}
+	scheduler, err := NewScheduler(db, &config.Config{
+		RemindSMTPURL:    fmt.Sprintf("smtp://%s?from=tasks@example.com&to=team@example.com", mailer.ln.Addr()),
+		RemindSlackURL:   slackSrv.URL,
+		RemindHTTPURL:    hookSrv.URL,
+		RemindQuietHours: "bob=22:00-07:00@Europe/London,alice=01:00-02:00",
+	})
+	requires.NoError(err)
+	requires.True(scheduler.Enabled())
+	scheduler.now = func() time.Time { return now }
+
+	// page of one reminder, quiet reminder of bob is before reminder of alice
+	scheduler.limit = 1
+
+	log.Print("\t 1 test scan: due reminder is sent, quiet hours of user are kept\n")
+	requires.NoError(scheduler.Scan(context.Background(), model.Job{}))
+	emails := mailer.received()
+	requires.Len(emails, 1)
+	asserts.Equal("tasks@example.com", emails[0].from)
+	asserts.Equal([]string{"team@example.com"}, emails[0].to)
+	asserts.Contains(emails[0].data, "Subject: Reminder: Call the bank\r\n")
+	asserts.Contains(emails[0].data, "\r\n\r\nTask 1 is due at 2025-03-01 22:29 UTC\r\n\r\nask about card\r\n")
+	requires.Len(slack.received(), 1)
+	asserts.JSONEq(`{"text":"*Reminder: Call the bank*\nTask 1 is due at 2025-03-01 22:29 UTC\n\nask about card"}`,
+		slack.received()[0])
+	asserts.Empty(hook.received(), "failed channel")
+
+	log.Print("\t 2 test scan: restart does not send again, failed channel is sent\n")
+	restarted, err := NewScheduler(db, &config.Config{
+		RemindSMTPURL:    fmt.Sprintf("smtp://%s?from=tasks@example.com&to=team@example.com", mailer.ln.Addr()),
+		RemindSlackURL:   slackSrv.URL,
+		RemindHTTPURL:    hookSrv.URL,
+		RemindQuietHours: "bob=22:00-07:00@Europe/London",
+	})
+	requires.NoError(err)
+	restarted.now = func() time.Time { return now }
+	requires.NoError(restarted.Scan(context.Background(), model.Job{}))
+	asserts.Len(mailer.received(), 1)
+	asserts.Len(slack.received(), 1)
+	requires.Len(hook.received(), 1)
+	msg := Message{}
+	requires.NoError(json.Unmarshal([]byte(hook.received()[0]), &msg))
+	asserts.Equal(Message{
+		TaskID:   1,
+		User:     "alice",
+		RemindAt: "2025-03-01T22:29:00Z",
+		Subject:  "Reminder: Call the bank",
+		Text:     "Task 1 is due at 2025-03-01 22:29 UTC\n\nask about card",
+	}, msg)
+
+	log.Print("\t 3 test scan: reminder after quiet hours\n")
+	morning := now.Add(9 * time.Hour)
+	db.mu.Lock()
+	db.now = morning
+	db.mu.Unlock()
+	restarted.now = func() time.Time { return morning }
+	requires.NoError(restarted.Scan(context.Background(), model.Job{}))
+	emails = mailer.received()
+	requires.Len(emails, 2)
+	asserts.Contains(emails[1].data, "Subject: Reminder: Night call\r\n")
+	asserts.Contains(emails[1].data, "Task 2 is due at 2025-03-01 22:28 GMT", "time zone of quiet hours")
+	asserts.Len(hook.received(), 2)
+}
+
+var quietHoursTestData = []struct {
+	hours string
+	at    time.Time
+	quiet bool
+	msg   string
+}{
+	{
+		hours: "u=22:00-07:00",
+		at:    time.Date(2025, 3, 1, 23, 0, 0, 0, time.UTC),
+		quiet: true,
+		msg:   "valid - through midnight, before midnight",
+	},
+	{
+		hours: "u=22:00-07:00",
+		at:    time.Date(2025, 3, 1, 6, 59, 0, 0, time.UTC),
+		quiet: true,
+		msg:   "valid - through midnight, after midnight",
+	},
+	{
+		hours: "u=22:00-07:00",
+		at:    time.Date(2025, 3, 1, 7, 0, 0, 0, time.UTC),
+		quiet: false,
+		msg:   "valid - end is not quiet",
+	},
+	{
+		hours: "u=12:00-13:00@Europe/Berlin",
+		at:    time.Date(2025, 7, 1, 10, 30, 0, 0, time.UTC),
+		quiet: true,
+		msg:   "valid - time zone with daylight saving time",
+	},
+	{
+		hours: "u=12:00-13:00@Europe/Berlin",
+		at:    time.Date(2025, 1, 1, 10, 30, 0, 0, time.UTC),
+		quiet: false,
+		msg:   "valid - time zone without daylight saving time",
+	},
+}
+
+func TestQuietHours(t *testing.T) {
+	asserts := assert.New(t)
+	requires := require.New(t)
+
+	for i, test := range quietHoursTestData {
+		log.Printf("\t %d test quiet hours: %s\n", i+1, test.hours)
+		cfg := &config.Config{RemindQuietHours: test.hours}
+		hours, err := cfg.QuietHoursOfUsers()
+		requires.NoError(err, test.msg)
+		asserts.Equal(test.quiet, hours["u"].Contains(test.at), test.msg)
+	}
+}
@@ -0,0 +1,64 @@
+// errors - mapping of errors of store and rules to gRPC status
+package rpc
+
+import (
+	"context"
+	"errors"
+	"log"
+
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"github.com/Ekvo/golang-chi-postgres-api/internal/ratelimit"
+	"github.com/Ekvo/golang-chi-postgres-api/internal/servises"
+	"github.com/Ekvo/golang-chi-postgres-api/internal/source"
+	c "github.com/Ekvo/golang-chi-postgres-api/pkg/common"
+)
+
+// invalidParamser - error with field-level reasons (look ~> ../transport/errors.go)
+type invalidParamser interface {
+	InvalidParams() []c.InvalidParam
+}
+
+// statusError - gRPC status of error, the same taxonomy as HTTP status of REST
+//
+// field-level reasons are in details 'errdetails.BadRequest'
+func statusError(err error) error {
+	code := codes.Internal
+	switch {
+	case errors.Is(err, servises.ErrservisesValidatorInvalidTask), errors.Is(err, source.ErrSourceValidation):
+		code = codes.InvalidArgument
+	case errors.Is(err, source.ErrSourceNotFound):
+		code = codes.NotFound
+	case errors.Is(err, source.ErrSourceConflict):
+		code = codes.Aborted
+	case errors.Is(err, ratelimit.ErrQuotaExceeded):
+		code = codes.ResourceExhausted
+	case errors.Is(err, source.ErrSourceUnavailable):
+		code = codes.Unavailable
+	case errors.Is(err, context.DeadlineExceeded):
+		code = codes.DeadlineExceeded
+	case errors.Is(err, context.Canceled):
+		code = codes.Canceled
+	}
+	if code == codes.Internal {
+		log.Printf("rpc: internal error - %v", err)
+	}
+	st := status.New(code, err.Error())
+	var ip invalidParamser
+	if !errors.As(err, &ip) {
+		return st.Err()
+	}
+	br := &errdetails.BadRequest{}
+	for _, p := range ip.InvalidParams() {
+		br.FieldViolations = append(br.FieldViolations, &errdetails.BadRequest_FieldViolation{
+			Field:       p.Name,
+			Description: p.Reason,
+		})
+	}
+	if withDetails, err := st.WithDetails(br); err == nil {
+		st = withDetails
+	}
+	return st.Err()
+}
@@ -0,0 +1,226 @@
+// rpc - gRPC 'TaskService' (look ~> ../../api/task.proto) over the same store as REST
+package rpc
+
+import (
+	"context"
+	"net"
+	"strconv"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/emptypb"
+	"google.golang.org/protobuf/types/known/timestamppb"
+
+	"github.com/Ekvo/golang-chi-postgres-api/internal/events"
+	"github.com/Ekvo/golang-chi-postgres-api/internal/model"
+	"github.com/Ekvo/golang-chi-postgres-api/internal/ratelimit"
+	"github.com/Ekvo/golang-chi-postgres-api/internal/servises"
+	"github.com/Ekvo/golang-chi-postgres-api/pkg/taskpb"
+)
+
+// limits of 'ListTasks'
+const (
+	defaultListLimit = 10
+	maxListLimit     = 100
+)
+
+// TaskServer - implementation of 'taskpb.TaskServiceServer'
+type TaskServer struct {
+	taskpb.UnimplementedTaskServiceServer
+	db    model.TaskStore
+	hub   *events.Hub
+	quota *ratelimit.TaskQuota
+}
+
+// NewTaskServer - 'hub' is source of 'WatchTasks', nil - 'WatchTasks' is Unimplemented
+func NewTaskServer(db model.TaskStore, hub *events.Hub) *TaskServer {
+	return &TaskServer{db: db, hub: hub}
+}
+
+// WithQuota - daily quota of 'CreateTask' by IP of peer, the same as quota of REST
+func (s *TaskServer) WithQuota(quota *ratelimit.TaskQuota) *TaskServer {
+	s.quota = quota
+	return s
+}
+
+// charge - quota of call, nil - without 'WithQuota'
+func (s *TaskServer) charge(ctx context.Context) *ratelimit.Charge {
+	if s.quota == nil {
+		return nil
+	}
+	client := "unknown"
+	if p, ok := peer.FromContext(ctx); ok {
+		client = p.Addr.String()
+		if host, _, err := net.SplitHostPort(client); err == nil {
+			client = host
+		}
+	}
+	return s.quota.Begin("ip:" + client)
+}
+
+func (s *TaskServer) CreateTask(ctx context.Context, req *taskpb.CreateTaskRequest) (*taskpb.CreateTaskResponse, error) {
+	task, err := servises.TaskFromData(req.GetDescription(), req.GetNote())
+	if err != nil {
+		return nil, statusError(err)
+	}
+	charge := s.charge(ctx)
+	if err := charge.Take(ctx, 1); err != nil {
+		return nil, statusError(err)
+	}
+	id, err := s.db.SaveOneTask(ctx, task)
+	if err != nil {
+		if ctx.Err() == nil {
+			charge.Refund(ctx, 1)
+		}
+		return nil, statusError(err)
+	}
+	return &taskpb.CreateTaskResponse{Id: uint64(id)}, nil
+}
+
+func (s *TaskServer) GetTask(ctx context.Context, req *taskpb.GetTaskRequest) (*taskpb.Task, error) {
+	id, err := taskID(req.GetId())
+	if err != nil {
+		return nil, err
+	}
+	task, err := s.db.FindOneTask(ctx, id)
+	if err != nil {
+		return nil, statusError(err)
+	}
+	return toProto(task), nil
+}
+
+func (s *TaskServer) UpdateTask(ctx context.Context, req *taskpb.UpdateTaskRequest) (*emptypb.Empty, error) {
+	id, err := taskID(req.GetId())
+	if err != nil {
+		return nil, err
+	}
+	task, err := servises.TaskFromData(req.GetDescription(), req.GetNote())
+	if err != nil {
+		return nil, statusError(err)
+	}
+	task.ID = id
+	task.UpdatedAt = &task.CreatedAt
+	if _, err := s.db.PatchTask(ctx, model.TextPatch(task)); err != nil {
+		return nil, statusError(err)
+	}
+	return &emptypb.Empty{}, nil
+}
+
+func (s *TaskServer) DeleteTask(ctx context.Context, req *taskpb.DeleteTaskRequest) (*emptypb.Empty, error) {
+	id, err := taskID(req.GetId())
+	if err != nil {
+		return nil, err
+	}
+	if err := s.db.EndTaskLife(ctx, id); err != nil {
+		return nil, statusError(err)
+	}
+	return &emptypb.Empty{}, nil
+}
+
+func (s *TaskServer) ListTasks(ctx context.Context, req *taskpb.ListTasksRequest) (*taskpb.ListTasksResponse, error) {
+	order := "asc"
+	switch req.GetOrder() {
+	case taskpb.ListTasksRequest_ORDER_UNSPECIFIED, taskpb.ListTasksRequest_ORDER_ASC:
+	case taskpb.ListTasksRequest_ORDER_DESC:
+		order = "desc"
+	default:
+		return nil, status.Error(codes.InvalidArgument, "unknown order")
+	}
+	limit := req.GetLimit()
+	if limit == 0 {
+		limit = defaultListLimit
+	}
+	if limit > maxListLimit {
+		return nil, status.Errorf(codes.InvalidArgument, "limit must be from 1 to %d", maxListLimit)
+	}
+	tasks, err := s.db.FindTaskList(ctx, []string{
+		order,
+		strconv.FormatUint(uint64(limit), 10),
+		strconv.FormatUint(uint64(req.GetOffset()), 10),
+	})
+	if err != nil {
+		return nil, statusError(err)
+	}
+	resp := &taskpb.ListTasksResponse{Tasks: make([]*taskpb.Task, 0, len(tasks))}
+	for _, task := range tasks {
+		resp.Tasks = append(resp.Tasks, toProto(task))
+	}
+	return resp, nil
+}
+
+// WatchTasks - events of 'events.Hub' until the client leaves or server stops,
+// headers are sent after subscription, slow client gets ResourceExhausted
+func (s *TaskServer) WatchTasks(req *taskpb.WatchTasksRequest, stream taskpb.TaskService_WatchTasksServer) error {
+	if s.hub == nil {
+		return status.Error(codes.Unimplemented, "watch is disabled")
+	}
+	ids := make(map[uint]bool, len(req.GetIds()))
+	for _, id := range req.GetIds() {
+		ids[uint(id)] = true
+	}
+	sub := s.hub.Subscribe()
+	defer sub.Close()
+	// headers - client knows that events after this point are not lost
+	if err := stream.SendHeader(metadata.MD{}); err != nil {
+		return err
+	}
+	for {
+		select {
+		case <-stream.Context().Done():
+			return nil
+		case ev, ok := <-sub.Events():
+			if !ok {
+				if sub.Lagged() {
+					return status.Error(codes.ResourceExhausted, "client is too slow, watch again")
+				}
+				return nil
+			}
+			if len(ids) > 0 && !ids[ev.TaskID] {
+				continue
+			}
+			if err := stream.Send(eventToProto(ev)); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+func taskID(id uint64) (uint, error) {
+	if id == 0 {
+		return 0, status.Error(codes.InvalidArgument, "id is required")
+	}
+	return uint(id), nil
+}
+
+func toProto(task model.Task) *taskpb.Task {
+	pb := &taskpb.Task{
+		Id:          uint64(task.ID),
+		Description: task.Description,
+		Note:        task.Note,
+		CreatedAt:   timestamppb.New(task.CreatedAt),
+	}
+	if task.UpdatedAt != nil {
+		pb.UpdatedAt = timestamppb.New(*task.UpdatedAt)
+	}
+	return pb
+}
+
+var eventTypes = map[string]taskpb.TaskEvent_Type{
+	model.EventCreated: taskpb.TaskEvent_TYPE_CREATED,
+	model.EventUpdated: taskpb.TaskEvent_TYPE_UPDATED,
+	model.EventDeleted: taskpb.TaskEvent_TYPE_DELETED,
+}
+
+func eventToProto(ev model.TaskEvent) *taskpb.TaskEvent {
+	pb := &taskpb.TaskEvent{
+		Type:       eventTypes[ev.Type],
+		TaskId:     uint64(ev.TaskID),
+		OccurredAt: timestamppb.New(ev.At),
+	}
+	if ev.Task != nil {
+		pb.Task = toProto(*ev.Task)
+	}
+	return pb
+}
@@ -0,0 +1,286 @@
+package rpc
+
+import (
+	"context"
+	"net"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+
+	"github.com/Ekvo/golang-chi-postgres-api/internal/events"
+	"github.com/Ekvo/golang-chi-postgres-api/internal/model"
+	"github.com/Ekvo/golang-chi-postgres-api/internal/source"
+	"github.com/Ekvo/golang-chi-postgres-api/pkg/taskpb"
+)
+
+// storeMock - only methods used by 'TaskServer', others panic
+type storeMock struct {
+	model.TaskStore
+	mu     sync.Mutex
+	nextID uint
+	tasks  map[uint]model.Task
+}
+
+func newStoreMock() *storeMock {
+	return &storeMock{nextID: 1, tasks: map[uint]model.Task{}}
+}
+
+func (m *storeMock) SaveOneTask(ctx context.Context, data any) (uint, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	task := data.(model.Task)
+	task.ID = m.nextID
+	m.tasks[task.ID] = task
+	m.nextID++
+	return task.ID, ctx.Err()
+}
+
+func (m *storeMock) PatchTask(ctx context.Context, data any) (model.Task, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	patch := data.(model.TaskPatch)
+	old, ex := m.tasks[patch.ID]
+	if !ex {
+		return model.Task{}, source.ErrSourceNotFound
+	}
+	task, err := patch.Apply(old)
+	if err != nil {
+		return model.Task{}, err
+	}
+	m.tasks[task.ID] = task
+	return task, ctx.Err()
+}
+
+func (m *storeMock) EndTaskLife(ctx context.Context, data any) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	id := data.(uint)
+	if _, ex := m.tasks[id]; !ex {
+		return source.ErrSourceNotFound
+	}
+	delete(m.tasks, id)
+	return ctx.Err()
+}
+
+func (m *storeMock) FindOneTask(ctx context.Context, data any) (model.Task, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	task, ex := m.tasks[data.(uint)]
+	if !ex {
+		return model.Task{}, source.ErrSourceNotFound
+	}
+	return task, ctx.Err()
+}
+
+func (m *storeMock) FindTaskList(ctx context.Context, data any) ([]model.Task, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	params := data.([]string)
+	tasks := make([]model.Task, 0, len(m.tasks))
+	for id := uint(1); id < m.nextID; id++ {
+		if task, ex := m.tasks[id]; ex {
+			tasks = append(tasks, task)
+		}
+	}
+	if params[0] == "desc" {
+		for i, j := 0, len(tasks)-1; i < j; i, j = i+1, j-1 {
+			tasks[i], tasks[j] = tasks[j], tasks[i]
+		}
+	}
+	return tasks, ctx.Err()
+}
+
+// newClient - 'TaskService' over 'storeMock' on bufconn
+func newClient(t *testing.T) (taskpb.TaskServiceClient, *events.Hub) {
+	return newClientOf(t, newStoreMock())
+}
+
+func newClientOf(t *testing.T, db *storeMock) (taskpb.TaskServiceClient, *events.Hub) {
+	hub := events.NewHub()
+	svc := NewService("", NewTaskServer(db, hub))
+	lis := bufconn.Listen(1 << 20)
+	go func() {
+		_ = svc.Server.Serve(lis)
+	}()
+	conn, err := grpc.NewClient("passthrough:///bufnet",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) {
+			return lis.DialContext(ctx)
+		}),
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+	)
+	require.NoError(t, err)
+	t.Cleanup(func() {
+		_ = conn.Close()
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+		defer cancel()
+		_ = svc.Shutdown(ctx)
+	})
+	return taskpb.NewTaskServiceClient(conn), hub
+}
+
+func TestTaskServer(t *testing.T) {
+	client, _ := newClient(t)
+	ctx := context.Background()
+
+	created, err := client.CreateTask(ctx, &taskpb.CreateTaskRequest{Description: " first ", Note: "note"})
+	require.NoError(t, err)
+	assert.Equal(t, uint64(1), created.GetId())
+
+	task, err := client.GetTask(ctx, &taskpb.GetTaskRequest{Id: created.GetId()})
+	require.NoError(t, err)
+	assert.Equal(t, "first", task.GetDescription())
+	assert.Nil(t, task.GetUpdatedAt())
+
+	_, err = client.UpdateTask(ctx, &taskpb.UpdateTaskRequest{Id: created.GetId(), Description: "second"})
+	require.NoError(t, err)
+	task, err = client.GetTask(ctx, &taskpb.GetTaskRequest{Id: created.GetId()})
+	require.NoError(t, err)
+	assert.Equal(t, "second", task.GetDescription())
+	assert.NotNil(t, task.GetUpdatedAt())
+
+	_, err = client.CreateTask(ctx, &taskpb.CreateTaskRequest{Description: "third"})
+	require.NoError(t, err)
+	list, err := client.ListTasks(ctx, &taskpb.ListTasksRequest{Order: taskpb.ListTasksRequest_ORDER_DESC})
+	require.NoError(t, err)
+	require.Len(t, list.GetTasks(), 2)
+	assert.Equal(t, uint64(2), list.GetTasks()[0].GetId())
+
+	_, err = client.DeleteTask(ctx, &taskpb.DeleteTaskRequest{Id: created.GetId()})
+	require.NoError(t, err)
+
+	for i, test := range []struct {
+		description string
+		call        func() error
+		code        codes.Code
+	}{
+		{
+			description: "not found",
+			call: func() error {
+				_, err := client.GetTask(ctx, &taskpb.GetTaskRequest{Id: created.GetId()})
+				return err
+			},
+			code: codes.NotFound,
+		},
+		{
+			description: "id is required",
+			call: func() error {
+				_, err := client.DeleteTask(ctx, &taskpb.DeleteTaskRequest{})
+				return err
+			},
+			code: codes.InvalidArgument,
+		},
+		{
+			description: "limit",
+			call: func() error {
+				_, err := client.ListTasks(ctx, &taskpb.ListTasksRequest{Limit: maxListLimit + 1})
+				return err
+			},
+			code: codes.InvalidArgument,
+		},
+		{
+			description: "update of deleted",
+			call: func() error {
+				_, err := client.UpdateTask(ctx, &taskpb.UpdateTaskRequest{Id: created.GetId(), Description: "x"})
+				return err
+			},
+			code: codes.NotFound,
+		},
+	} {
+		err := test.call()
+		assert.Equal(t, test.code, status.Code(err), "test #%d - %s", i, test.description)
+	}
+}
+
+func TestTaskServerUpdateKeepsTask(t *testing.T) {
+	db := newStoreMock()
+	client, _ := newClientOf(t, db)
+	ctx := context.Background()
+
+	remindAt := time.Date(2025, 3, 1, 9, 0, 0, 0, time.UTC)
+	dueAt := time.Date(2025, 3, 3, 9, 0, 0, 0, time.UTC)
+	stored := model.Task{
+		Description: "first",
+		CreatedAt:   time.Date(2025, 3, 1, 8, 0, 0, 0, time.UTC),
+		RemindAt:    &remindAt,
+		RemindUser:  "alice",
+		DueAt:       &dueAt,
+		TimeZone:    "Europe/Berlin",
+		Recurrence:  &model.Recurrence{RRule: "FREQ=WEEKLY", Start: dueAt},
+		ProjectID:   7,
+		Done:        true,
+	}
+	id, err := db.SaveOneTask(ctx, stored)
+	require.NoError(t, err)
+
+	_, err = client.UpdateTask(ctx, &taskpb.UpdateTaskRequest{Id: uint64(id), Description: "second", Note: "note"})
+	require.NoError(t, err)
+	task, err := db.FindOneTask(ctx, id)
+	require.NoError(t, err)
+	assert.Equal(t, "second", task.Description)
+	assert.Equal(t, "note", task.Note)
+	assert.NotNil(t, task.UpdatedAt)
+	task.Description, task.Note, task.UpdatedAt, stored.ID = stored.Description, stored.Note, nil, id
+	assert.Equal(t, stored, task, "project, done, reminder, due time and recurrence are kept")
+}
+
+func TestTaskServerValidation(t *testing.T) {
+	client, _ := newClient(t)
+
+	_, err := client.CreateTask(context.Background(), &taskpb.CreateTaskRequest{Description: "  ", Note: "a\x00b"})
+	st := status.Convert(err)
+	require.Equal(t, codes.InvalidArgument, st.Code())
+	require.Len(t, st.Details(), 1)
+	br, ok := st.Details()[0].(*errdetails.BadRequest)
+	require.True(t, ok)
+	fields := map[string]bool{}
+	for _, v := range br.GetFieldViolations() {
+		fields[v.GetField()] = true
+	}
+	assert.Equal(t, map[string]bool{"description": true, "note": true}, fields)
+}
+
+func TestTaskServerWatch(t *testing.T) {
+	client, hub := newClient(t)
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	stream, err := client.WatchTasks(ctx, &taskpb.WatchTasksRequest{Ids: []uint64{2}})
+	require.NoError(t, err)
+	// headers - stream is subscribed
+	_, err = stream.Header()
+	require.NoError(t, err)
+
+	// events of store (look ~> ../source/events.go)
+	task := model.Task{ID: 2, Description: "two", CreatedAt: time.Now().UTC()}
+	for _, ev := range []model.TaskEvent{
+		{ID: 1, Type: model.EventCreated, TaskID: 1, Task: &model.Task{ID: 1, Description: "one"}},
+		{ID: 2, Type: model.EventCreated, TaskID: 2, Task: &task},
+		{ID: 3, Type: model.EventDeleted, TaskID: 2},
+	} {
+		hub.Publish(ev)
+	}
+
+	ev, err := stream.Recv()
+	require.NoError(t, err)
+	assert.Equal(t, taskpb.TaskEvent_TYPE_CREATED, ev.GetType())
+	assert.Equal(t, "two", ev.GetTask().GetDescription())
+
+	ev, err = stream.Recv()
+	require.NoError(t, err)
+	assert.Equal(t, taskpb.TaskEvent_TYPE_DELETED, ev.GetType())
+	assert.Equal(t, uint64(2), ev.GetTaskId())
+	assert.Nil(t, ev.GetTask())
+
+	hub.Close()
+	_, err = stream.Recv()
+	assert.Error(t, err)
+}
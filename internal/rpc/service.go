@@ -0,0 +1,66 @@
+package rpc
+
+import (
+	"context"
+	"errors"
+	"net"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/health"
+	"google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/reflection"
+
+	"github.com/Ekvo/golang-chi-postgres-api/pkg/taskpb"
+)
+
+// Service - gRPC server of 'TaskServer' with own port, life cycle of 'server.Service'
+// (look ~> ../server/server.go)
+type Service struct {
+	*grpc.Server
+	addr   string
+	tasks  *TaskServer
+	health *health.Server
+}
+
+// NewService - 'addr' for 'net.Listen', service 'grpc.health.v1' and reflection are registered too
+func NewService(addr string, tasks *TaskServer, opts ...grpc.ServerOption) *Service {
+	srv := grpc.NewServer(opts...)
+	taskpb.RegisterTaskServiceServer(srv, tasks)
+	hs := health.NewServer()
+	grpc_health_v1.RegisterHealthServer(srv, hs)
+	reflection.Register(srv)
+	return &Service{Server: srv, addr: addr, tasks: tasks, health: hs}
+}
+
+// Serve - listen 'addr', returns nil after 'Shutdown'
+func (s *Service) Serve() error {
+	lis, err := net.Listen("tcp", s.addr)
+	if err != nil {
+		return err
+	}
+	if err := s.Server.Serve(lis); err != nil && !errors.Is(err, grpc.ErrServerStopped) {
+		return err
+	}
+	return nil
+}
+
+// Shutdown - end streams of 'WatchTasks' and wait for unary calls,
+// if 'ctx' is done first - all connections are closed
+func (s *Service) Shutdown(ctx context.Context) error {
+	s.health.Shutdown()
+	if s.tasks.hub != nil {
+		s.tasks.hub.Close()
+	}
+	done := make(chan struct{})
+	go func() {
+		s.GracefulStop()
+		close(done)
+	}()
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		s.Stop()
+		return ctx.Err()
+	}
+}
@@ -10,6 +10,7 @@ import (
 	"net/http"
 	"os"
 	"os/signal"
+	"sync"
 	"syscall"
 	"time"
 
@@ -18,9 +19,17 @@ import (
 
 const TimeShutServer = 10 * time.Second
 
+// Service - part of application with own listener, served and shut together with http.Server
+type Service interface {
+	// Serve - blocks until 'Shutdown', returns nil after 'Shutdown'
+	Serve() error
+	Shutdown(ctx context.Context) error
+}
+
 // Connect - wrapper http.Server
 type Connect struct {
 	*http.Server
+	services []Service
 }
 
 func NewServer(srv *http.Server) *Connect {
@@ -35,6 +44,14 @@ func Init(cfg *config.Config, r http.Handler) *Connect {
 	return NewServer(srv)
 }
 
+// Attach - add 'Service' to life cycle of 'ListenAndServeAndShut'
+func (c *Connect) Attach(services ...Service) {
+	c.services = append(c.services, services...)
+}
+
+// ListenAndServeAndShut - serve http.Server and all 'Service' until SIGINT or SIGTERM,
+// then shut them at the same time, each of them has the whole 'timeShut' (jobs.Pool drains
+// its jobs while http.Server waits for requests)
 func (c *Connect) ListenAndServeAndShut(ctx context.Context, timeShut time.Duration) error {
 	go func() {
 		log.Print("server: Listen and serve - start\n")
@@ -43,6 +60,13 @@ func (c *Connect) ListenAndServeAndShut(ctx context.Context, timeShut time.Durat
 		}
 		log.Print("server: stopped serving\n")
 	}()
+	for _, srv := range c.services {
+		go func(srv Service) {
+			if err := srv.Serve(); err != nil {
+				log.Fatalf("server: %T error - %v", srv, err)
+			}
+		}(srv)
+	}
 
 	sigChan := make(chan os.Signal, 1)
 	signal.Notify(sigChan, syscall.SIGINT, syscall.SIGTERM)
@@ -51,8 +75,23 @@ func (c *Connect) ListenAndServeAndShut(ctx context.Context, timeShut time.Durat
 	shutdownCtx, shutdownRelease := context.WithTimeout(ctx, timeShut)
 	defer shutdownRelease()
 
+	errs := make([]error, len(c.services)+1)
+	var wg sync.WaitGroup
+	for i, srv := range c.services {
+		wg.Add(1)
+		go func(i int, srv Service) {
+			defer wg.Done()
+			if err := srv.Shutdown(shutdownCtx); err != nil {
+				errs[i] = fmt.Errorf("server: %T shutdown error - %w", srv, err)
+			}
+		}(i, srv)
+	}
 	if err := c.Shutdown(shutdownCtx); err != nil {
-		return fmt.Errorf("server: HTTP shutdown error - %w", err)
+		errs[len(c.services)] = fmt.Errorf("server: HTTP shutdown error - %w", err)
+	}
+	wg.Wait()
+	if err := errors.Join(errs...); err != nil {
+		return err
 	}
 	log.Print("server: graceful shutdown complete\n")
 	return nil
@@ -0,0 +1,248 @@
+package servises
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"mime"
+	"net/http"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/Ekvo/golang-chi-postgres-api/internal/model"
+	"github.com/Ekvo/golang-chi-postgres-api/internal/variables"
+)
+
+var (
+	// ErrservisesAttachmentForm - body is not 'multipart/form-data' with files in field "file", or file is empty
+	ErrservisesAttachmentForm = errors.New("invalid attachment form")
+
+	// ErrservisesAttachmentSize - file or body is larger than limit
+	ErrservisesAttachmentSize = errors.New("invalid attachment size")
+
+	// ErrservisesAttachmentType - content of file is not one of 'AttachmentTypes'
+	ErrservisesAttachmentType = errors.New("unsupported attachment type")
+)
+
+const (
+	// AttachmentField - field of form with files
+	AttachmentField = "file"
+
+	// MaxAttachments - files of one upload
+	MaxAttachments = 10
+
+	// maxAttachmentName - length of name of file in runes
+	maxAttachmentName = 255
+
+	// sniffLen - bytes of content for 'http.DetectContentType'
+	sniffLen = 512
+)
+
+// AttachmentTypes - media types of content (sniffed, 'Content-Type' of part is ignored)
+var AttachmentTypes = []string{
+	"application/pdf",
+	"application/x-gzip",
+	"application/zip",
+	"image/gif",
+	"image/jpeg",
+	"image/png",
+	"image/webp",
+	"text/plain",
+}
+
+// Upload - file of form in temporary file, 'Close' removes it
+type Upload struct {
+	File        *os.File
+	Name        string
+	ContentType string
+	Size        int64
+	SHA256      string
+}
+
+func (u *Upload) Close() error {
+	err := u.File.Close()
+	if errRemove := os.Remove(u.File.Name()); err == nil {
+		err = errRemove
+	}
+	return err
+}
+
+// AttachmentModel - 'model.Attachment' of upload, 'TaskID', 'Key' and 'User' are set by caller
+func (u *Upload) AttachmentModel() model.Attachment {
+	return model.Attachment{Name: u.Name, ContentType: u.ContentType, Size: u.Size, SHA256: u.SHA256}
+}
+
+// AttachmentReader - describe property of getting files of 'multipart/form-data' from a Request
+type AttachmentReader struct {
+	maxSize int64
+}
+
+// NewAttachmentReader - 'maxSize' - max size of one file in bytes
+func NewAttachmentReader(maxSize int64) *AttachmentReader {
+	return &AttachmentReader{maxSize: maxSize}
+}
+
+// Read - files of field "file" (1-'MaxAttachments'), other fields are skipped
+//
+// content is written to temporary files with checksum, with error all of them are removed
+func (ar *AttachmentReader) Read(r *http.Request) (uploads []*Upload, err error) {
+	mr, err := r.MultipartReader()
+	if err != nil {
+		return nil, fmt.Errorf("%w - %v", ErrservisesAttachmentForm, err)
+	}
+	defer func() {
+		if err == nil {
+			return
+		}
+		for _, upload := range uploads {
+			if err := upload.Close(); err != nil {
+				log.Printf("servises: upload.Close error - %v", err)
+			}
+		}
+		uploads = nil
+	}()
+	for {
+		part, err := mr.NextPart()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return uploads, formError(err)
+		}
+		if part.FormName() != AttachmentField {
+			if _, err := io.Copy(io.Discard, part); err != nil {
+				return uploads, formError(err)
+			}
+			continue
+		}
+		if len(uploads) == MaxAttachments {
+			return uploads, fmt.Errorf("%w - more than %d files", ErrservisesAttachmentForm, MaxAttachments)
+		}
+		upload, err := ar.spool(part, part.FileName())
+		if err != nil {
+			return uploads, err
+		}
+		uploads = append(uploads, upload)
+	}
+	if len(uploads) == 0 {
+		return nil, fmt.Errorf("%w - no field %q", ErrservisesAttachmentForm, AttachmentField)
+	}
+	return uploads, nil
+}
+
+// spool - content of part in temporary file, checksum and sniffed type
+func (ar *AttachmentReader) spool(r io.Reader, fileName string) (*Upload, error) {
+	name := attachmentName(fileName)
+	f, err := os.CreateTemp("", "attachment-*")
+	if err != nil {
+		return nil, err
+	}
+	upload := &Upload{File: f, Name: name}
+	hash := sha256.New()
+	upload.Size, err = io.Copy(io.MultiWriter(f, hash), io.LimitReader(r, ar.maxSize+1))
+	if err == nil {
+		err = ar.check(upload)
+	}
+	if err != nil {
+		if errClose := upload.Close(); errClose != nil {
+			log.Printf("servises: upload.Close error - %v", errClose)
+		}
+		return nil, formError(err)
+	}
+	upload.SHA256 = hex.EncodeToString(hash.Sum(nil))
+	return upload, nil
+}
+
+// check - size and type of content of 'upload', position of file is set to start
+func (ar *AttachmentReader) check(upload *Upload) error {
+	if upload.Size == 0 {
+		return fmt.Errorf("%w - %q is empty", ErrservisesAttachmentForm, upload.Name)
+	}
+	if upload.Size > ar.maxSize {
+		return fmt.Errorf("%w - %q is larger than %d bytes", ErrservisesAttachmentSize, upload.Name, ar.maxSize)
+	}
+	head := make([]byte, sniffLen)
+	n, err := upload.File.ReadAt(head, 0)
+	if err != nil && !errors.Is(err, io.EOF) {
+		return err
+	}
+	upload.ContentType = http.DetectContentType(head[:n])
+	mediaType, _, _ := mime.ParseMediaType(upload.ContentType)
+	if !slices.Contains(AttachmentTypes, mediaType) {
+		return fmt.Errorf("%w - %q: %s", ErrservisesAttachmentType, upload.Name, mediaType)
+	}
+	_, err = upload.File.Seek(0, io.SeekStart)
+	return err
+}
+
+// formError - error of reading of body, too large body ('http.MaxBytesReader') - ErrservisesAttachmentSize
+func formError(err error) error {
+	var maxBytes *http.MaxBytesError
+	switch {
+	case errors.As(err, &maxBytes):
+		return fmt.Errorf("%w - body is larger than %d bytes", ErrservisesAttachmentSize, maxBytes.Limit)
+	case errors.Is(err, ErrservisesAttachmentSize), errors.Is(err, ErrservisesAttachmentType),
+		errors.Is(err, ErrservisesAttachmentForm):
+		return err
+	}
+	return fmt.Errorf("%w - %v", ErrservisesAttachmentForm, err)
+}
+
+// attachmentName - base name of file without control characters, at most 'maxAttachmentName' runes
+func attachmentName(fileName string) string {
+	fileName = strings.ReplaceAll(fileName, `\`, "/")
+	name := strings.Map(func(r rune) rune {
+		if unicode.IsControl(r) || r == utf8.RuneError {
+			return -1
+		}
+		return r
+	}, filepath.Base(fileName))
+	name = strings.TrimSpace(name)
+	if name == "" || name == "." || name == "/" {
+		return "attachment"
+	}
+	if runes := []rune(name); len(runes) > maxAttachmentName {
+		name = string(runes[len(runes)-maxAttachmentName:])
+	}
+	return name
+}
+
+// AttachmentResponse - 'model.Attachment' for 'Response'
+type AttachmentResponse struct {
+	ID          uint64 `json:"id"`
+	TaskID      uint   `json:"task_id"`
+	Name        string `json:"name"`
+	ContentType string `json:"content_type"`
+	Size        int64  `json:"size"`
+	SHA256      string `json:"sha256"`
+	User        string `json:"user"`
+	CreatedAt   string `json:"created_at"`
+}
+
+func NewAttachmentResponse(attachment model.Attachment) AttachmentResponse {
+	return AttachmentResponse{
+		ID:          attachment.ID,
+		TaskID:      attachment.TaskID,
+		Name:        attachment.Name,
+		ContentType: attachment.ContentType,
+		Size:        attachment.Size,
+		SHA256:      attachment.SHA256,
+		User:        attachment.User,
+		CreatedAt:   attachment.CreatedAt.UTC().Format(variables.RFC3339Milli),
+	}
+}
+
+func NewAttachmentListResponse(attachments []model.Attachment) []AttachmentResponse {
+	resp := make([]AttachmentResponse, len(attachments))
+	for i, attachment := range attachments {
+		resp[i] = NewAttachmentResponse(attachment)
+	}
+	return resp
+}
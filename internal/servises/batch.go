@@ -0,0 +1,149 @@
+package servises
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/Ekvo/golang-chi-postgres-api/internal/model"
+	"github.com/Ekvo/golang-chi-postgres-api/pkg/common"
+)
+
+// MaxBatchSize - max operations in one 'task_batch'
+const MaxBatchSize = 1000
+
+// modes of 'task_batch'
+const (
+	BatchAtomic     = "atomic"
+	BatchBestEffort = "best_effort"
+)
+
+var ErrservisesBatchInvalid = errors.New("invalid task batch")
+
+// batchOperation - one operation of Request, 'ID' for update and delete, 'Task' for create and update
+type batchOperation struct {
+	Op   string `json:"op"`
+	ID   uint   `json:"id"`
+	Task *struct {
+		Description string `json:"description"`
+		Note        string `json:"note"`
+	} `json:"task_update"`
+}
+
+// BatchValidator - describe property of getting 'model.Batch' from a Request
+//
+// in atomic mode any invalid operation rejects the whole batch,
+// in best effort mode invalid operations are kept in 'Invalid' and not passed to the store
+type BatchValidator struct {
+	Data struct {
+		Mode       string           `json:"mode"`
+		Operations []batchOperation `json:"operations"`
+	} `json:"task_batch"`
+	batch   model.Batch   `json:"-"`
+	index   []int         `json:"-"`
+	invalid map[int]error `json:"-"`
+}
+
+func NewBatchValidator() *BatchValidator {
+	return &BatchValidator{invalid: map[int]error{}}
+}
+
+// Batch - valid operations for the store
+func (bv *BatchValidator) Batch() model.Batch {
+	return bv.batch
+}
+
+// Index - index of operation of 'Batch' in Request
+func (bv *BatchValidator) Index() []int {
+	return bv.index
+}
+
+// Invalid - errors of rejected operations by index in Request (best effort mode)
+func (bv *BatchValidator) Invalid() map[int]error {
+	return bv.invalid
+}
+
+// Size - number of operations in Request
+func (bv *BatchValidator) Size() int {
+	return len(bv.Data.Operations)
+}
+
+// Decode - get 'Data' and create 'model.Batch', format of body by 'Content-Type'
+func (bv *BatchValidator) Decode(r *http.Request) error {
+	if err := common.Decode(r, bv); err != nil {
+		return err
+	}
+	if bv.Data.Mode == "" {
+		bv.Data.Mode = BatchAtomic
+	}
+	if err := Validate(Field{
+		Name:  "mode",
+		Value: &bv.Data.Mode,
+		Rules: []Rule{OneOf(BatchAtomic, BatchBestEffort)},
+	}); err != nil {
+		return err
+	}
+	if n := len(bv.Data.Operations); n == 0 || n > MaxBatchSize {
+		return fmt.Errorf("%w - operations must contain from 1 to %d items", ErrservisesBatchInvalid, MaxBatchSize)
+	}
+	bv.batch.Atomic = bv.Data.Mode == BatchAtomic
+
+	var all ValidationErrors
+	now := time.Now().UTC()
+	for i := range bv.Data.Operations {
+		op, err := bv.Data.Operations[i].operation(i, now)
+		if err != nil {
+			if bv.batch.Atomic {
+				all = append(all, err.(ValidationErrors)...)
+			}
+			bv.invalid[i] = err
+			continue
+		}
+		bv.batch.Operations = append(bv.batch.Operations, op)
+		bv.index = append(bv.index, i)
+	}
+	if len(all) > 0 {
+		return all
+	}
+	return nil
+}
+
+// operation - validate one operation of Request, error is 'ValidationErrors'
+func (bo *batchOperation) operation(i int, now time.Time) (model.BatchOperation, error) {
+	prefix := fmt.Sprintf("operations[%d].", i)
+	fields := []Field{{
+		Name:  prefix + "op",
+		Value: &bo.Op,
+		Rules: []Rule{Required(), OneOf(model.BatchCreate, model.BatchUpdate, model.BatchDelete)},
+	}}
+	if err := Validate(fields...); err != nil {
+		return model.BatchOperation{}, err
+	}
+	var ve ValidationErrors
+	if bo.Op != model.BatchCreate && bo.ID == 0 {
+		ve = append(ve, common.InvalidParam{Name: prefix + "id", Reason: "required"})
+	}
+	if bo.Op != model.BatchDelete {
+		if bo.Task == nil {
+			ve = append(ve, common.InvalidParam{Name: prefix + "task_update", Reason: "required"})
+		} else if err := Validate(taskFields(prefix+"task_update.", &bo.Task.Description, &bo.Task.Note)...); err != nil {
+			ve = append(ve, err.(ValidationErrors)...)
+		}
+	}
+	if len(ve) > 0 {
+		return model.BatchOperation{}, ve
+	}
+	op := model.BatchOperation{Op: bo.Op, Task: model.Task{ID: bo.ID}}
+	if bo.Task != nil {
+		op.Task.Description = bo.Task.Description
+		op.Task.Note = bo.Task.Note
+	}
+	switch bo.Op {
+	case model.BatchCreate:
+		op.Task.CreatedAt = now
+	case model.BatchUpdate:
+		op.Task.UpdatedAt = &now
+	}
+	return op, nil
+}
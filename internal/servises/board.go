@@ -0,0 +1,116 @@
+package servises
+
+import (
+	"net/http"
+	"regexp"
+	"time"
+
+	"github.com/Ekvo/golang-chi-postgres-api/internal/model"
+	"github.com/Ekvo/golang-chi-postgres-api/pkg/common"
+)
+
+// reBoardStatus - name of column of board, look 'tasks.status'
+var reBoardStatus = regexp.MustCompile(`^[a-z][a-z0-9_]{0,31}$`)
+
+// BoardStatus - name of column, empty value is skipped (use with Required)
+func BoardStatus() Rule {
+	return func(value *string) string {
+		if *value != "" && !reBoardStatus.MatchString(*value) {
+			return "must be lower case letter, then letters, digits or '_' (up to 32)"
+		}
+		return ""
+	}
+}
+
+// MoveValidator - describe property of getting 'model.TaskMove' from a Request
+type MoveValidator struct {
+	Data struct {
+		Status string `json:"status"`
+		After  uint   `json:"after"`
+		Before uint   `json:"before"`
+	} `json:"move"`
+	move model.TaskMove `json:"-"`
+}
+
+func NewMoveValidator() *MoveValidator {
+	return &MoveValidator{}
+}
+
+// TaskMoveModel - without 'Rank' and 'Keys' (look: package rank)
+func (mv *MoveValidator) TaskMoveModel() model.TaskMove {
+	return mv.move
+}
+
+// Decode - get 'Data' of move of 'Task' 'id', anchors are other 'Task' and not the same one
+func (mv *MoveValidator) Decode(r *http.Request, id uint) error {
+	if err := common.Decode(r, mv); err != nil {
+		return err
+	}
+	ve, _ := Validate(Field{
+		Name:  "status",
+		Value: &mv.Data.Status,
+		Rules: []Rule{Trim(), BoardStatus()},
+	}).(ValidationErrors)
+	for _, anchor := range []struct {
+		name string
+		id   uint
+	}{
+		{"after", mv.Data.After},
+		{"before", mv.Data.Before},
+	} {
+		if anchor.id != 0 && anchor.id == id {
+			ve = append(ve, common.InvalidParam{Name: anchor.name, Reason: "must be other task"})
+		}
+	}
+	if mv.Data.After != 0 && mv.Data.After == mv.Data.Before {
+		ve = append(ve, common.InvalidParam{Name: "before", Reason: "must be other task than 'after'"})
+	}
+	if len(ve) > 0 {
+		return ve
+	}
+	mv.move = model.TaskMove{
+		ID:        id,
+		Status:    mv.Data.Status,
+		After:     mv.Data.After,
+		Before:    mv.Data.Before,
+		UpdatedAt: time.Now().UTC(),
+	}
+	return nil
+}
+
+// BoardTaskResponse - 'Task' in column of board, 'ID' and 'Position' are for anchors of move
+type BoardTaskResponse struct {
+	ID       uint   `json:"id"`
+	Status   string `json:"status"`
+	Position string `json:"position"`
+	TaskResponse
+}
+
+func NewBoardTaskResponse(task model.Task) BoardTaskResponse {
+	serializer := TaskSerializer{Task: task}
+	return BoardTaskResponse{
+		ID:           task.ID,
+		Status:       task.Status,
+		Position:     task.Position,
+		TaskResponse: serializer.Response(),
+	}
+}
+
+// BoardColumnResponse - 'Task' of one column in order of position
+type BoardColumnResponse struct {
+	Status string              `json:"status"`
+	Tasks  []BoardTaskResponse `json:"tasks"`
+}
+
+// NewBoardResponse - columns of 'tasks' ordered by column and position (look: model.TaskFilter.Board)
+func NewBoardResponse(tasks []model.Task) []BoardColumnResponse {
+	board := []BoardColumnResponse{}
+	for _, task := range tasks {
+		if n := len(board); n == 0 || board[n-1].Status != task.Status {
+			board = append(board, BoardColumnResponse{Status: task.Status})
+		}
+		column := &board[len(board)-1]
+		column.Tasks = append(column.Tasks, NewBoardTaskResponse(task))
+	}
+	return board
+}
@@ -0,0 +1,312 @@
+package servises
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/Ekvo/golang-chi-postgres-api/internal/model"
+	"github.com/Ekvo/golang-chi-postgres-api/internal/recurrence"
+)
+
+// components of calendar feed, 'Task' is an event (calendar) or a to-do (task list)
+const (
+	CalendarEvent = "VEVENT"
+	CalendarTodo  = "VTODO"
+)
+
+const (
+	calendarProdID = "-//Ekvo//golang-chi-postgres-api//EN"
+	calendarDomain = "golang-chi-postgres-api"
+	// calendarRefresh - how often clients should reload the feed (RFC 7986)
+	calendarRefresh = "PT15M"
+	// calendarYears - VTIMEZONE has transitions till this number of years from now
+	calendarYears = 5
+	// calendarLineLen - long lines are folded at 75 octets (RFC 5545, 3.1)
+	calendarLineLen = 75
+)
+
+var ErrservisesCalendarComponent = errors.New("invalid calendar component")
+
+// CalendarWriter - iCalendar (RFC 5545) feed, one VEVENT or VTODO for each 'Task' with due time
+//
+// VEVENT of recurring 'Task' has RRULE from DTSTART of series, VTODO - only current occurrence (it is completed one by one),
+// times of zone are written with TZID, VTIMEZONE of each zone is written by 'End'
+//
+// call order: Begin, Write..., End
+type CalendarWriter struct {
+	component string
+	w         io.Writer
+	now       time.Time
+	// zones - IANA name -> the earliest time of the zone in feed
+	zones map[string]time.Time
+}
+
+func NewCalendarWriter(w io.Writer, component string, now time.Time) (*CalendarWriter, error) {
+	if component == "" {
+		component = CalendarEvent
+	}
+	if component != CalendarEvent && component != CalendarTodo {
+		return nil, ErrservisesCalendarComponent
+	}
+	return &CalendarWriter{component: component, w: w, now: now, zones: map[string]time.Time{}}, nil
+}
+
+// ContentType - media type of iCalendar
+func (cw *CalendarWriter) ContentType() string {
+	return "text/calendar; charset=utf-8"
+}
+
+// FileName - name for 'Content-Disposition'
+func (cw *CalendarWriter) FileName() string {
+	return "tasks.ics"
+}
+
+// Begin - header of VCALENDAR
+func (cw *CalendarWriter) Begin() error {
+	return cw.lines(
+		"BEGIN:VCALENDAR",
+		"VERSION:2.0",
+		"PRODID:"+calendarProdID,
+		"CALSCALE:GREGORIAN",
+		"METHOD:PUBLISH",
+		"X-WR-CALNAME:Tasks",
+		"REFRESH-INTERVAL;VALUE=DURATION:"+calendarRefresh,
+		"X-PUBLISHED-TTL:"+calendarRefresh,
+	)
+}
+
+// Write - VEVENT or VTODO of 'task', 'Task' without due time is skipped
+func (cw *CalendarWriter) Write(task model.Task) error {
+	if task.DueAt == nil {
+		return nil
+	}
+	loc, err := recurrence.LoadLocation(task.TimeZone)
+	if err != nil {
+		return err
+	}
+	stamp := task.CreatedAt
+	if task.UpdatedAt != nil {
+		stamp = *task.UpdatedAt
+	}
+	lines := []string{
+		"BEGIN:" + cw.component,
+		fmt.Sprintf("UID:task-%d@%s", task.ID, calendarDomain),
+		"DTSTAMP:" + utcDateTime(stamp),
+		"CREATED:" + utcDateTime(task.CreatedAt),
+	}
+	if task.UpdatedAt != nil {
+		lines = append(lines, "LAST-MODIFIED:"+utcDateTime(*task.UpdatedAt))
+	}
+	lines = append(lines, "SUMMARY:"+escapeText(task.Description))
+	if task.Note != "" {
+		lines = append(lines, "DESCRIPTION:"+escapeText(task.Note))
+	}
+	trigger := "TRIGGER"
+	if cw.component == CalendarEvent {
+		start := *task.DueAt
+		if task.Recurrence != nil {
+			start = task.Recurrence.Start
+		}
+		lines = append(lines, "DTSTART"+cw.dateTime(task.TimeZone, loc, start))
+		if task.Recurrence != nil {
+			lines = append(lines, "RRULE:"+task.Recurrence.RRule)
+		}
+	} else {
+		lines = append(lines, "DUE"+cw.dateTime(task.TimeZone, loc, *task.DueAt))
+		// VTODO without DTSTART - alarm is related to DUE
+		trigger += ";RELATED=END"
+	}
+	if task.RemindAt != nil {
+		lines = append(lines,
+			"BEGIN:VALARM",
+			"ACTION:DISPLAY",
+			"DESCRIPTION:"+escapeText(task.Description),
+			trigger+":"+duration(task.RemindAt.Sub(*task.DueAt)),
+			"END:VALARM",
+		)
+	}
+	lines = append(lines, "END:"+cw.component)
+	return cw.lines(lines...)
+}
+
+// Flush - lines are written at once, nothing is buffered
+func (cw *CalendarWriter) Flush() error {
+	return nil
+}
+
+// End - VTIMEZONE of zones of feed and end of VCALENDAR
+func (cw *CalendarWriter) End() error {
+	names := make([]string, 0, len(cw.zones))
+	for name := range cw.zones {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		loc, err := recurrence.LoadLocation(name)
+		if err != nil {
+			return err
+		}
+		from := cw.zones[name].AddDate(0, 0, -1)
+		if err := cw.lines(vtimezone(name, loc, from, cw.now.AddDate(calendarYears, 0, 0))...); err != nil {
+			return err
+		}
+	}
+	return cw.lines("END:VCALENDAR")
+}
+
+// dateTime - ":20250324T080000Z" for UTC, ";TZID=Europe/Berlin:20250324T090000" for zone, zone is kept for 'End'
+func (cw *CalendarWriter) dateTime(name string, loc *time.Location, t time.Time) string {
+	if loc.String() == "UTC" {
+		return ":" + utcDateTime(t)
+	}
+	if first, ok := cw.zones[name]; !ok || t.Before(first) {
+		cw.zones[name] = t
+	}
+	return ";TZID=" + name + ":" + t.In(loc).Format("20060102T150405")
+}
+
+// lines - content lines with CRLF, long lines are folded
+func (cw *CalendarWriter) lines(lines ...string) error {
+	buf := strings.Builder{}
+	for _, line := range lines {
+		buf.WriteString(fold(line))
+		buf.WriteString("\r\n")
+	}
+	_, err := io.WriteString(cw.w, buf.String())
+	return err
+}
+
+func utcDateTime(t time.Time) string {
+	return t.UTC().Format("20060102T150405Z")
+}
+
+// escapeText - value of TEXT (RFC 5545, 3.3.11)
+func escapeText(text string) string {
+	text = strings.ReplaceAll(text, "\r\n", "\n")
+	return strings.NewReplacer(`\`, `\\`, ";", `\;`, ",", `\,`, "\n", `\n`, "\r", `\n`).Replace(text)
+}
+
+// fold - line longer than 75 octets is split by CRLF and space, UTF-8 characters are not split
+func fold(line string) string {
+	if len(line) <= calendarLineLen {
+		return line
+	}
+	buf := strings.Builder{}
+	size := 0
+	for _, r := range line {
+		n := utf8.RuneLen(r)
+		if size+n > calendarLineLen {
+			buf.WriteString("\r\n ")
+			// space of continuation line is counted
+			size = 1
+		}
+		buf.WriteRune(r)
+		size += n
+	}
+	return buf.String()
+}
+
+// duration - value of DURATION ("-PT30M", "P1DT2H"), units between the first and the last are written
+func duration(d time.Duration) string {
+	sign := ""
+	if d < 0 {
+		sign, d = "-", -d
+	}
+	secs := int64(d / time.Second)
+	days := secs / 86400
+	parts := []struct {
+		n    int64
+		unit string
+	}{{secs % 86400 / 3600, "H"}, {secs % 3600 / 60, "M"}, {secs % 60, "S"}}
+	first, last := -1, -1
+	for i, p := range parts {
+		if p.n > 0 {
+			if first < 0 {
+				first = i
+			}
+			last = i
+		}
+	}
+	buf := strings.Builder{}
+	buf.WriteString(sign + "P")
+	if days > 0 {
+		buf.WriteString(strconv.FormatInt(days, 10) + "D")
+	}
+	if first < 0 && days == 0 {
+		return buf.String() + "T0S"
+	}
+	if first >= 0 {
+		buf.WriteString("T")
+		for _, p := range parts[first : last+1] {
+			buf.WriteString(strconv.FormatInt(p.n, 10) + p.unit)
+		}
+	}
+	return buf.String()
+}
+
+// vtimezone - VTIMEZONE of 'loc' with observance at 'from' and each transition till 'to'
+//
+// transitions are found day by day and then to the second, zone without them has one STANDARD
+func vtimezone(name string, loc *time.Location, from, to time.Time) []string {
+	lines := []string{"BEGIN:VTIMEZONE", "TZID:" + name}
+	at := from.In(loc)
+	abbr, offset := at.Zone()
+	lines = append(lines, observance(at.IsDST(), abbr, offset, offset, at)...)
+	for day := at; day.Before(to); {
+		next := day.Add(24 * time.Hour)
+		if _, off := next.In(loc).Zone(); off == offset {
+			day = next
+			continue
+		}
+		lo, hi := day, next
+		for hi.Sub(lo) > time.Second {
+			mid := lo.Add(hi.Sub(lo) / 2)
+			if _, off := mid.In(loc).Zone(); off == offset {
+				lo = mid
+			} else {
+				hi = mid
+			}
+		}
+		change := hi.In(loc)
+		abbr, off := change.Zone()
+		lines = append(lines, observance(change.IsDST(), abbr, offset, off, change)...)
+		offset, day = off, change
+	}
+	return append(lines, "END:VTIMEZONE")
+}
+
+// observance - STANDARD or DAYLIGHT from 'at', DTSTART is local time with offset before change
+func observance(dst bool, abbr string, from, to int, at time.Time) []string {
+	kind := "STANDARD"
+	if dst {
+		kind = "DAYLIGHT"
+	}
+	local := at.UTC().Add(time.Duration(from) * time.Second)
+	return []string{
+		"BEGIN:" + kind,
+		"DTSTART:" + local.Format("20060102T150405"),
+		"TZOFFSETFROM:" + utcOffset(from),
+		"TZOFFSETTO:" + utcOffset(to),
+		"TZNAME:" + abbr,
+		"END:" + kind,
+	}
+}
+
+// utcOffset - "+0100", "-0430", seconds only if they are
+func utcOffset(secs int) string {
+	sign := "+"
+	if secs < 0 {
+		sign, secs = "-", -secs
+	}
+	value := fmt.Sprintf("%s%02d%02d", sign, secs/3600, secs%3600/60)
+	if secs%60 != 0 {
+		value += fmt.Sprintf("%02d", secs%60)
+	}
+	return value
+}
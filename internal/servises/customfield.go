@@ -0,0 +1,298 @@
+package servises
+
+import (
+	"fmt"
+	"math"
+	"net/http"
+	"net/url"
+	"regexp"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Ekvo/golang-chi-postgres-api/internal/model"
+	"github.com/Ekvo/golang-chi-postgres-api/internal/variables"
+	"github.com/Ekvo/golang-chi-postgres-api/pkg/common"
+)
+
+const (
+	// MaxCustomFieldLen - length of value of 'model.FieldString' in runes
+	MaxCustomFieldLen = 256
+
+	// MaxAllowedValues - allowed values of one custom field
+	MaxAllowedValues = 100
+
+	// CustomFieldParam - prefix of params of filter by custom fields ("cf.component=api")
+	CustomFieldParam = "cf."
+
+	// customDate - layout of value of 'model.FieldDate'
+	customDate = "2006-01-02"
+)
+
+// reCustomField - name of custom field, it is a key of JSON and a part of name of param of filter
+var reCustomField = regexp.MustCompile(`^[a-z][a-z0-9_]{0,63}$`)
+
+// CustomFieldValidator - describe property of getting 'model.CustomField' from a Request, name is taken from path
+type CustomFieldValidator struct {
+	Data struct {
+		Type     string `json:"type"`
+		Required bool   `json:"required,omitempty"`
+		Allowed  []any  `json:"allowed,omitempty"`
+	} `json:"custom_field"`
+	field model.CustomField `json:"-"`
+}
+
+func NewCustomFieldValidator() *CustomFieldValidator {
+	return &CustomFieldValidator{}
+}
+
+func (cfv *CustomFieldValidator) CustomFieldModel() model.CustomField {
+	return cfv.field
+}
+
+// Decode - get 'Data' of field 'name', allowed values only for 'model.FieldString' and 'model.FieldNumber'
+func (cfv *CustomFieldValidator) Decode(r *http.Request, name string) error {
+	if err := common.Decode(r, cfv); err != nil {
+		return err
+	}
+	err := Validate(
+		Field{
+			Name:  "name",
+			Value: &name,
+			Rules: []Rule{Required(), CustomFieldName()},
+		},
+		Field{
+			Name:  "type",
+			Value: &cfv.Data.Type,
+			Rules: []Rule{Trim(), Required(),
+				OneOf(model.FieldString, model.FieldNumber, model.FieldBoolean, model.FieldDate)},
+		},
+	)
+	ve, _ := err.(ValidationErrors)
+	if err == nil {
+		ve = append(ve, cfv.validAllowed()...)
+	}
+	if len(ve) > 0 {
+		return ve
+	}
+	cfv.field = model.CustomField{
+		Name:      name,
+		Type:      cfv.Data.Type,
+		Required:  cfv.Data.Required,
+		Allowed:   cfv.Data.Allowed,
+		CreatedAt: time.Now().UTC(),
+	}
+	return nil
+}
+
+// validAllowed - each allowed value has type of field and it is unique
+func (cfv *CustomFieldValidator) validAllowed() ValidationErrors {
+	var ve ValidationErrors
+	allowed := cfv.Data.Allowed
+	switch {
+	case len(allowed) == 0:
+		return nil
+	case cfv.Data.Type != model.FieldString && cfv.Data.Type != model.FieldNumber:
+		return ValidationErrors{{Name: "allowed", Reason: "must be empty for type " + cfv.Data.Type}}
+	case len(allowed) > MaxAllowedValues:
+		return ValidationErrors{{Name: "allowed", Reason: fmt.Sprintf("must have at most %d values", MaxAllowedValues)}}
+	}
+	field := model.CustomField{Type: cfv.Data.Type}
+	for i := range allowed {
+		name := fmt.Sprintf("allowed[%d]", i)
+		value, reason := customValue(field, allowed[i])
+		if reason == "" && slices.Contains(allowed[:i], value) {
+			reason = "must be unique"
+		}
+		if reason != "" {
+			ve = append(ve, common.InvalidParam{Name: name, Reason: reason})
+			continue
+		}
+		allowed[i] = value
+	}
+	return ve
+}
+
+// CustomFieldName - name of custom field: lower case latin letter, then letters, digits or '_', up to 64
+func CustomFieldName() Rule {
+	return func(value *string) string {
+		if *value != "" && !reCustomField.MatchString(*value) {
+			return "must be lower case letter, then letters, digits or '_' (up to 64)"
+		}
+		return ""
+	}
+}
+
+// ValidCustomFields - check 'values' of 'Task' by 'schema', all fields are checked at once
+//
+// values are normalized in place (trimmed strings), null value is removed (field is absent),
+// field which is not in 'schema' - error, required field of 'schema' - must have value
+func ValidCustomFields(prefix string, schema []model.CustomField, values map[string]any) ValidationErrors {
+	fields := make(map[string]model.CustomField, len(schema))
+	for _, field := range schema {
+		fields[field.Name] = field
+	}
+	names := make([]string, 0, len(values))
+	for name, value := range values {
+		if value == nil {
+			delete(values, name)
+			continue
+		}
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	var ve ValidationErrors
+	for _, name := range names {
+		field, ok := fields[name]
+		if !ok {
+			ve = append(ve, common.InvalidParam{Name: prefix + name, Reason: "unknown custom field"})
+			continue
+		}
+		value, reason := customValue(field, values[name])
+		if reason != "" {
+			ve = append(ve, common.InvalidParam{Name: prefix + name, Reason: reason})
+			continue
+		}
+		values[name] = value
+	}
+	for _, field := range schema {
+		if _, ex := values[field.Name]; field.Required && !ex {
+			ve = append(ve, common.InvalidParam{Name: prefix + field.Name, Reason: "required"})
+		}
+	}
+	return ve
+}
+
+// customValue - value of JSON by type and allowed values of 'field', returns normalized value or reason
+func customValue(field model.CustomField, value any) (any, string) {
+	switch field.Type {
+	case model.FieldString:
+		line, ok := value.(string)
+		if !ok {
+			return nil, "must be string"
+		}
+		line = strings.TrimSpace(line)
+		for _, rule := range []Rule{Required(), MaxRunes(MaxCustomFieldLen), NoControl()} {
+			if reason := rule(&line); reason != "" {
+				return nil, reason
+			}
+		}
+		value = line
+	case model.FieldNumber:
+		number, ok := value.(float64)
+		if !ok || math.IsNaN(number) || math.IsInf(number, 0) {
+			return nil, "must be number"
+		}
+	case model.FieldBoolean:
+		if _, ok := value.(bool); !ok {
+			return nil, "must be true or false"
+		}
+	case model.FieldDate:
+		line, ok := value.(string)
+		if !ok {
+			return nil, "must be date 2006-01-02"
+		}
+		if _, err := time.Parse(customDate, line); err != nil {
+			return nil, "must be date 2006-01-02"
+		}
+	default:
+		return nil, "unknown type of custom field"
+	}
+	if len(field.Allowed) > 0 && !slices.Contains(field.Allowed, value) {
+		return nil, "must be one of " + allowedList(field.Allowed)
+	}
+	return value, ""
+}
+
+func allowedList(allowed []any) string {
+	items := make([]string, len(allowed))
+	for i, value := range allowed {
+		items[i] = fmt.Sprint(value)
+	}
+	return strings.Join(items, ", ")
+}
+
+// NewCustomFieldFilter - values of params "cf.<name>" for 'model.TaskFilter.CustomFields', nil if there are none
+//
+// value of param is converted by type of field of 'schema', unknown field - error
+func NewCustomFieldFilter(query url.Values, schema []model.CustomField) (map[string]any, error) {
+	params := make([]string, 0)
+	for param := range query {
+		if strings.HasPrefix(param, CustomFieldParam) {
+			params = append(params, param)
+		}
+	}
+	if len(params) == 0 {
+		return nil, nil
+	}
+	sort.Strings(params)
+	fields := make(map[string]model.CustomField, len(schema))
+	for _, field := range schema {
+		fields[field.Name] = field
+	}
+	values := make(map[string]any, len(params))
+	var ve ValidationErrors
+	for _, param := range params {
+		field, ok := fields[strings.TrimPrefix(param, CustomFieldParam)]
+		if !ok {
+			ve = append(ve, common.InvalidParam{Name: param, Reason: "unknown custom field"})
+			continue
+		}
+		var value any = query.Get(param)
+		switch field.Type {
+		case model.FieldNumber:
+			if number, err := strconv.ParseFloat(query.Get(param), 64); err == nil {
+				value = number
+			}
+		case model.FieldBoolean:
+			if flag, err := strconv.ParseBool(query.Get(param)); err == nil {
+				value = flag
+			}
+		}
+		// allowed values are not checked - unknown value finds nothing
+		value, reason := customValue(model.CustomField{Type: field.Type}, value)
+		if reason != "" {
+			ve = append(ve, common.InvalidParam{Name: param, Reason: reason})
+			continue
+		}
+		values[field.Name] = value
+	}
+	if len(ve) > 0 {
+		return nil, ve
+	}
+	return values, nil
+}
+
+// CustomFieldResponse - format object 'CustomField' for 'Response'
+type CustomFieldResponse struct {
+	Name      string `json:"name"`
+	Type      string `json:"type"`
+	Required  bool   `json:"required"`
+	Allowed   []any  `json:"allowed,omitempty"`
+	CreatedAt string `json:"created_at"`
+	UpdatedAt string `json:"updated_at,omitempty"`
+}
+
+func NewCustomFieldResponse(field model.CustomField) CustomFieldResponse {
+	resp := CustomFieldResponse{
+		Name:      field.Name,
+		Type:      field.Type,
+		Required:  field.Required,
+		Allowed:   field.Allowed,
+		CreatedAt: field.CreatedAt.UTC().Format(variables.RFC3339Milli),
+	}
+	if field.UpdatedAt != nil {
+		resp.UpdatedAt = field.UpdatedAt.UTC().Format(variables.RFC3339Milli)
+	}
+	return resp
+}
+
+func NewCustomFieldListResponse(fields []model.CustomField) []CustomFieldResponse {
+	resp := make([]CustomFieldResponse, len(fields))
+	for i, field := range fields {
+		resp[i] = NewCustomFieldResponse(field)
+	}
+	return resp
+}
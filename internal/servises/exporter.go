@@ -0,0 +1,208 @@
+package servises
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/Ekvo/golang-chi-postgres-api/internal/model"
+	"github.com/Ekvo/golang-chi-postgres-api/pkg/common"
+)
+
+// formats of export
+const (
+	ExportCSV    = "csv"
+	ExportNDJSON = "ndjson"
+	ExportJSON   = "json"
+)
+
+var ErrservisesExportFormat = errors.New("invalid export format")
+
+// TaskExportResponse - one 'Task' of export, 'TaskResponse' with 'ID'
+type TaskExportResponse struct {
+	ID uint `json:"id"`
+	TaskResponse
+}
+
+// TaskExportWriter - write 'Task' one by one in CSV, NDJSON or JSON array
+//
+// call order: Begin, Write..., End
+type TaskExportWriter struct {
+	format string
+	w      io.Writer
+	csv    *csv.Writer
+	enc    *json.Encoder
+	count  int
+}
+
+func NewTaskExportWriter(w io.Writer, format string) (*TaskExportWriter, error) {
+	ew := &TaskExportWriter{format: format, w: w}
+	switch format {
+	case ExportCSV:
+		ew.csv = csv.NewWriter(w)
+	case ExportNDJSON:
+		ew.enc = json.NewEncoder(w)
+	case ExportJSON:
+	default:
+		return nil, ErrservisesExportFormat
+	}
+	return ew, nil
+}
+
+// ContentType - media type of format
+func (ew *TaskExportWriter) ContentType() string {
+	switch ew.format {
+	case ExportCSV:
+		return "text/csv; charset=utf-8"
+	case ExportNDJSON:
+		return "application/x-ndjson"
+	}
+	return "application/json"
+}
+
+// FileName - name for 'Content-Disposition'
+func (ew *TaskExportWriter) FileName() string {
+	return "tasks." + ew.format
+}
+
+// Begin - CSV header or '[' of JSON array
+func (ew *TaskExportWriter) Begin() error {
+	switch ew.format {
+	case ExportCSV:
+		return ew.csv.Write([]string{"id", "description", "note", "created_at", "updated_at"})
+	case ExportJSON:
+		_, err := io.WriteString(ew.w, "[")
+		return err
+	}
+	return nil
+}
+
+// Write - one 'Task', format of time is the same as in 'TaskSerializer'
+func (ew *TaskExportWriter) Write(task model.Task) error {
+	serializer := TaskSerializer{Task: task}
+	row := TaskExportResponse{ID: task.ID, TaskResponse: serializer.Response()}
+	defer func() { ew.count++ }()
+	switch ew.format {
+	case ExportCSV:
+		return ew.csv.Write([]string{
+			strconv.FormatUint(uint64(row.ID), 10),
+			row.Description,
+			row.Note,
+			row.CreatedAt,
+			row.UpdatedAt,
+		})
+	case ExportJSON:
+		line, err := json.Marshal(row)
+		if err != nil {
+			return err
+		}
+		sep := ",\n"
+		if ew.count == 0 {
+			sep = "\n"
+		}
+		if _, err := io.WriteString(ew.w, sep); err != nil {
+			return err
+		}
+		_, err = ew.w.Write(line)
+		return err
+	}
+	return ew.enc.Encode(row)
+}
+
+// Flush - send buffered rows (CSV) to underlying writer
+func (ew *TaskExportWriter) Flush() error {
+	if ew.csv != nil {
+		ew.csv.Flush()
+		return ew.csv.Error()
+	}
+	return nil
+}
+
+// End - ']' of JSON array and flush of CSV
+func (ew *TaskExportWriter) End() error {
+	if ew.format == ExportJSON {
+		end := "\n]\n"
+		if ew.count == 0 {
+			end = "]\n"
+		}
+		if _, err := io.WriteString(ew.w, end); err != nil {
+			return err
+		}
+	}
+	return ew.Flush()
+}
+
+// NewTaskFilter - 'model.TaskFilter' from params 'order', 'created_from', 'created_to' (RFC 3339), 'limit', 'offset',
+// 'project_id', 'archived' (true - with 'Task' of archived projects), 'status' (column of board)
+func NewTaskFilter(query url.Values) (model.TaskFilter, error) {
+	filter := model.TaskFilter{Order: query.Get("order")}
+	if filter.Order == "" {
+		filter.Order = "asc"
+	}
+	var ve ValidationErrors
+	projectID := 0
+	if reason := OneOf("asc", "desc")(&filter.Order); reason != "" {
+		ve = append(ve, common.InvalidParam{Name: "order", Reason: reason})
+	}
+	for _, param := range []struct {
+		name string
+		dst  **time.Time
+	}{
+		{"created_from", &filter.CreatedFrom},
+		{"created_to", &filter.CreatedTo},
+	} {
+		value := query.Get(param.name)
+		if value == "" {
+			continue
+		}
+		t, err := time.Parse(time.RFC3339, value)
+		if err != nil {
+			ve = append(ve, common.InvalidParam{Name: param.name, Reason: "must be RFC 3339 time"})
+			continue
+		}
+		t = t.UTC()
+		*param.dst = &t
+	}
+	for _, param := range []struct {
+		name string
+		dst  *int
+		min  int
+	}{
+		{"limit", &filter.Limit, 1},
+		{"offset", &filter.Offset, 0},
+		{"project_id", &projectID, 1},
+	} {
+		value := query.Get(param.name)
+		if value == "" {
+			continue
+		}
+		n, err := strconv.Atoi(value)
+		if err != nil || n < param.min {
+			ve = append(ve, common.InvalidParam{Name: param.name, Reason: fmt.Sprintf("must be number from %d", param.min)})
+			continue
+		}
+		*param.dst = n
+	}
+	filter.ProjectID = uint(projectID)
+	if filter.Status = query.Get("status"); filter.Status != "" {
+		if reason := BoardStatus()(&filter.Status); reason != "" {
+			ve = append(ve, common.InvalidParam{Name: "status", Reason: reason})
+		}
+	}
+	if value := query.Get("archived"); value != "" {
+		archived, err := strconv.ParseBool(value)
+		if err != nil {
+			ve = append(ve, common.InvalidParam{Name: "archived", Reason: "must be true or false"})
+		}
+		filter.Archived = archived
+	}
+	if len(ve) > 0 {
+		return filter, ve
+	}
+	return filter, nil
+}
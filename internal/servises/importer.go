@@ -0,0 +1,172 @@
+package servises
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/Ekvo/golang-chi-postgres-api/internal/model"
+	"github.com/Ekvo/golang-chi-postgres-api/pkg/common"
+)
+
+// formats of import
+const (
+	ImportCSV    = "csv"
+	ImportNDJSON = "ndjson"
+)
+
+// MaxImportRejected - max rejected rows kept for the report, others are only counted
+const MaxImportRejected = 1000
+
+var ErrservisesImportFormat = errors.New("invalid import format")
+
+// RejectedRow - row of import that did not pass the rules of 'TaskValidator'
+type RejectedRow struct {
+	Line   int                   `json:"line"`
+	Errors []common.InvalidParam `json:"errors"`
+}
+
+// TaskImportReader - read 'model.Task' row by row from CSV or NDJSON
+//
+// CSV - first line is header with columns "description" and optional "note"
+// NDJSON - one object {"description":"...","note":"..."} in line, empty lines are skipped
+// row is checked by all rules of 'TaskValidator' as 'Task' of create ('TaskFromData'), row has only
+// description and note, so other fields are empty; invalid rows are skipped and kept in 'Rejected' with number of line
+type TaskImportReader struct {
+	next     func() (int, string, string, error)
+	now      time.Time
+	rejected []RejectedRow
+	count    int
+}
+
+// NewTaskImportReader - 'format' is 'ImportCSV' or 'ImportNDJSON'
+func NewTaskImportReader(r io.Reader, format string) (*TaskImportReader, error) {
+	ir := &TaskImportReader{now: time.Now().UTC()}
+	switch format {
+	case ImportCSV:
+		next, err := csvRows(r)
+		if err != nil {
+			return nil, err
+		}
+		ir.next = next
+	case ImportNDJSON:
+		ir.next = ndjsonRows(r)
+	default:
+		return nil, ErrservisesImportFormat
+	}
+	return ir, nil
+}
+
+// Next - next valid 'Task', io.EOF at the end of input
+//
+// layout of 'model.TaskImport.Next'
+func (ir *TaskImportReader) Next() (model.Task, error) {
+	for {
+		line, description, note, err := ir.next()
+		if err != nil {
+			return model.Task{}, err
+		}
+		task, err := TaskFromData(description, note)
+		if err != nil {
+			ir.reject(line, err.(ValidationErrors))
+			continue
+		}
+		task.CreatedAt = ir.now
+		return task, nil
+	}
+}
+
+// Rejected - rows which did not pass the rules (no more than 'MaxImportRejected')
+func (ir *TaskImportReader) Rejected() []RejectedRow {
+	return ir.rejected
+}
+
+// RejectedCount - number of all rejected rows
+func (ir *TaskImportReader) RejectedCount() int {
+	return ir.count
+}
+
+func (ir *TaskImportReader) reject(line int, ve ValidationErrors) {
+	ir.count++
+	if len(ir.rejected) < MaxImportRejected {
+		ir.rejected = append(ir.rejected, RejectedRow{Line: line, Errors: ve})
+	}
+}
+
+// csvRows - reader of CSV rows, header is read here
+func csvRows(r io.Reader) (func() (int, string, string, error), error) {
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = -1
+	header, err := reader.Read()
+	if err != nil {
+		return nil, fmt.Errorf("%w - csv header: %v", ErrservisesImportFormat, err)
+	}
+	descIdx, noteIdx := -1, -1
+	for i, name := range header {
+		switch strings.ToLower(strings.TrimSpace(strings.TrimPrefix(name, "\ufeff"))) {
+		case "description":
+			descIdx = i
+		case "note":
+			noteIdx = i
+		}
+	}
+	if descIdx < 0 {
+		return nil, fmt.Errorf("%w - csv header must contain column description", ErrservisesImportFormat)
+	}
+	return func() (int, string, string, error) {
+		record, err := reader.Read()
+		if err != nil {
+			var parseErr *csv.ParseError
+			if errors.As(err, &parseErr) {
+				return 0, "", "", fmt.Errorf("%w - %v", ErrservisesImportFormat, err)
+			}
+			return 0, "", "", err
+		}
+		line, _ := reader.FieldPos(0)
+		var description, note string
+		if descIdx < len(record) {
+			description = record[descIdx]
+		}
+		if noteIdx >= 0 && noteIdx < len(record) {
+			note = record[noteIdx]
+		}
+		return line, description, note, nil
+	}, nil
+}
+
+// ndjsonRows - reader of NDJSON rows, broken JSON in line is an error of the whole import
+func ndjsonRows(r io.Reader) func() (int, string, string, error) {
+	scanner := bufio.NewScanner(r)
+	// one line - two fields of 'MaxTextLen' runes, each may be escaped as \uXXXX
+	scanner.Buffer(make([]byte, 0, 64*1024), 2*6*MaxTextLen+1024)
+	line := 0
+	return func() (int, string, string, error) {
+		for scanner.Scan() {
+			line++
+			text := bytes.TrimSpace(scanner.Bytes())
+			if len(text) == 0 {
+				continue
+			}
+			row := struct {
+				Description string `json:"description"`
+				Note        string `json:"note"`
+			}{}
+			dec := json.NewDecoder(bytes.NewReader(text))
+			dec.DisallowUnknownFields()
+			if err := dec.Decode(&row); err != nil {
+				return 0, "", "", fmt.Errorf("%w - line %d: %v", ErrservisesImportFormat, line, err)
+			}
+			return line, row.Description, row.Note, nil
+		}
+		if err := scanner.Err(); err != nil {
+			return 0, "", "", fmt.Errorf("%w - line %d: %v", ErrservisesImportFormat, line+1, err)
+		}
+		return 0, "", "", io.EOF
+	}
+}
@@ -0,0 +1,158 @@
+package servises
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"mime"
+	"net/http"
+	"time"
+
+	"github.com/Ekvo/golang-chi-postgres-api/internal/model"
+	"github.com/Ekvo/golang-chi-postgres-api/pkg/common"
+)
+
+var (
+	// ErrservisesPatchMedia - 'Content-Type' of PATCH is not a supported patch format
+	ErrservisesPatchMedia = errors.New("unsupported patch media type")
+
+	// ErrservisesPatchMalformed - body of PATCH is not a patch document
+	ErrservisesPatchMalformed = errors.New("malformed patch document")
+
+	// ErrservisesPatchApply - patch document cannot be applied to the task
+	ErrservisesPatchApply = errors.New("patch cannot be applied")
+)
+
+// TaskPatchValidator - describe property of changing stored 'Task' by PATCH Request
+//
+// supported: 'application/merge-patch+json' (RFC 7396) and 'application/json-patch+json' (RFC 6902)
+type TaskPatchValidator struct {
+	media  string
+	merge  any
+	ops    []common.PatchOperation
+	schema []model.CustomField
+}
+
+func NewTaskPatchValidator() *TaskPatchValidator {
+	return &TaskPatchValidator{}
+}
+
+// WithCustomFields - schema of 'custom_fields', stored values of fields which are not in it are dropped
+func (pv *TaskPatchValidator) WithCustomFields(schema []model.CustomField) *TaskPatchValidator {
+	pv.schema = schema
+	return pv
+}
+
+// DecodePatch - get patch document from Request, it is not applied here
+func (pv *TaskPatchValidator) DecodePatch(r *http.Request) error {
+	media, _, err := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	if err != nil || (media != common.MediaMergePatch && media != common.MediaJSONPatch) {
+		return ErrservisesPatchMedia
+	}
+	defer func() {
+		if err := r.Body.Close(); err != nil {
+			log.Printf("servises: r.Body.Close error - %v", err)
+		}
+	}()
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		return err
+	}
+	pv.media = media
+	if media == common.MediaMergePatch {
+		err = json.Unmarshal(body, &pv.merge)
+	} else {
+		dec := json.NewDecoder(bytes.NewReader(body))
+		dec.DisallowUnknownFields()
+		err = dec.Decode(&pv.ops)
+	}
+	if err != nil {
+		return fmt.Errorf("%w - %v", ErrservisesPatchMalformed, err)
+	}
+	return nil
+}
+
+// Apply - change 'task' by patch document and check result with rules of 'TaskValidator'
+//
+// layout of 'model.TaskPatch.Apply', document is the same as 'TaskValidator.Data'
+func (pv *TaskPatchValidator) Apply(task model.Task) (model.Task, error) {
+	fields := map[string]any{
+		"description": task.Description,
+		"note":        task.Note,
+	}
+	if task.RemindAt != nil {
+		fields["remind_at"] = task.RemindAt.UTC().Format(time.RFC3339)
+	}
+	if task.DueAt != nil {
+		fields["due_at"] = task.DueAt.UTC().Format(time.RFC3339)
+	}
+	if task.TimeZone != "" {
+		fields["time_zone"] = task.TimeZone
+	}
+	if task.Recurrence != nil {
+		fields["rrule"] = task.Recurrence.RRule
+	}
+	// object is always in document, so json-patch can "add" to it
+	customFields := map[string]any{}
+	for _, field := range pv.schema {
+		if value, ex := task.CustomFields[field.Name]; ex {
+			customFields[field.Name] = value
+		}
+	}
+	fields["custom_fields"] = customFields
+	if task.ProjectID != 0 {
+		fields["project_id"] = task.ProjectID
+	}
+	if task.Done {
+		fields["done"] = true
+	}
+	var doc any = fields
+	if pv.media == common.MediaMergePatch {
+		doc = common.MergePatch(doc, pv.merge)
+	} else {
+		var err error
+		if doc, err = common.JSONPatch(doc, pv.ops); err != nil {
+			return task, err
+		}
+	}
+	line, err := json.Marshal(doc)
+	if err != nil {
+		return task, fmt.Errorf("%w - %v", ErrservisesPatchApply, err)
+	}
+	tv := NewTaskValidator().WithCustomFields(pv.schema)
+	dec := json.NewDecoder(bytes.NewReader(line))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&tv.Data); err != nil {
+		return task, fmt.Errorf("%w - %v", ErrservisesPatchApply, err)
+	}
+	if err := tv.validate(); err != nil {
+		return task, err
+	}
+	updatedAt := time.Now().UTC()
+	task.Description = tv.Data.Description
+	task.Note = tv.Data.Note
+	task.RemindAt = tv.remindAt()
+	task.Recurrence = keepStart(task, tv.recurrence(), tv.dueAt(), tv.Data.TimeZone)
+	task.DueAt = tv.dueAt()
+	task.TimeZone = tv.Data.TimeZone
+	task.CustomFields = tv.customFields()
+	task.ProjectID = tv.Data.ProjectID
+	task.Done = tv.Data.Done
+	task.UpdatedAt = &updatedAt
+	return task, nil
+}
+
+// keepStart - series of 'task' with the same RRULE, due time and time zone keeps its DTSTART,
+// else 'rec' starts from new due time
+func keepStart(task model.Task, rec *model.Recurrence, dueAt *time.Time, timeZone string) *model.Recurrence {
+	if rec == nil || task.Recurrence == nil || task.DueAt == nil || dueAt == nil {
+		return rec
+	}
+	if rec.RRule == task.Recurrence.RRule && timeZone == task.TimeZone && dueAt.Equal(*task.DueAt) {
+		rec.Start = task.Recurrence.Start
+	}
+	return rec
+}
@@ -0,0 +1,115 @@
+package servises
+
+import (
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/Ekvo/golang-chi-postgres-api/internal/model"
+	"github.com/Ekvo/golang-chi-postgres-api/internal/variables"
+	"github.com/Ekvo/golang-chi-postgres-api/pkg/common"
+)
+
+// rules of 'Project', look table 'projects'
+const (
+	MaxProjectNameLen  = 128
+	MaxProjectPosition = 1000000
+)
+
+// ProjectValidator - describe property of getting 'model.Project' from a Request
+type ProjectValidator struct {
+	Data struct {
+		Name        string `json:"name"`
+		Description string `json:"description"`
+		Archived    bool   `json:"archived"`
+		Position    int    `json:"position"`
+	} `json:"project"`
+	project model.Project `json:"-"`
+}
+
+func NewProjectValidator() *ProjectValidator {
+	return &ProjectValidator{}
+}
+
+func (pv *ProjectValidator) ProjectModel() model.Project {
+	return pv.project
+}
+
+// Decode - get 'Data' and create 'Project', 'position' from 0 to 'MaxProjectPosition'
+func (pv *ProjectValidator) Decode(r *http.Request) error {
+	if err := common.Decode(r, pv); err != nil {
+		return err
+	}
+	err := Validate(
+		Field{
+			Name:  "name",
+			Value: &pv.Data.Name,
+			Rules: []Rule{Trim(), Required(), MaxRunes(MaxProjectNameLen), NoControl()},
+		},
+		Field{
+			Name:  "description",
+			Value: &pv.Data.Description,
+			Rules: []Rule{Trim(), MaxRunes(MaxTextLen), NoControl()},
+		},
+	)
+	ve, _ := err.(ValidationErrors)
+	if pv.Data.Position < 0 || pv.Data.Position > MaxProjectPosition {
+		ve = append(ve, common.InvalidParam{
+			Name:   "position",
+			Reason: fmt.Sprintf("must be number from 0 to %d", MaxProjectPosition),
+		})
+	}
+	if len(ve) > 0 {
+		return ve
+	}
+	pv.project = model.Project{
+		Name:        pv.Data.Name,
+		Description: pv.Data.Description,
+		Archived:    pv.Data.Archived,
+		Position:    pv.Data.Position,
+		CreatedAt:   time.Now().UTC(),
+	}
+	return nil
+}
+
+// ProjectTasksResponse - counts of 'Task' of 'Project'
+type ProjectTasksResponse struct {
+	Open int `json:"open"`
+	Done int `json:"done"`
+}
+
+// ProjectResponse - format object 'Project' for 'Response'
+type ProjectResponse struct {
+	ID          uint                 `json:"id"`
+	Name        string               `json:"name"`
+	Description string               `json:"description,omitempty"`
+	Archived    bool                 `json:"archived"`
+	Position    int                  `json:"position"`
+	CreatedAt   string               `json:"created_at"`
+	UpdatedAt   string               `json:"updated_at,omitempty"`
+	Tasks       ProjectTasksResponse `json:"tasks"`
+}
+
+func NewProjectResponse(project model.Project) ProjectResponse {
+	resp := ProjectResponse{
+		ID:          project.ID,
+		Name:        project.Name,
+		Description: project.Description,
+		Archived:    project.Archived,
+		Position:    project.Position,
+		CreatedAt:   project.CreatedAt.UTC().Format(variables.RFC3339Milli),
+		Tasks:       ProjectTasksResponse{Open: project.Open, Done: project.Done},
+	}
+	if project.UpdatedAt != nil {
+		resp.UpdatedAt = project.UpdatedAt.UTC().Format(variables.RFC3339Milli)
+	}
+	return resp
+}
+
+func NewProjectListResponse(projects []model.Project) []ProjectResponse {
+	resp := make([]ProjectResponse, len(projects))
+	for i, project := range projects {
+		resp[i] = NewProjectResponse(project)
+	}
+	return resp
+}
@@ -0,0 +1,260 @@
+package servises
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/Ekvo/golang-chi-postgres-api/internal/recurrence"
+	"github.com/Ekvo/golang-chi-postgres-api/pkg/common"
+)
+
+// MaxTextLen - size of 'VARCHAR(2048)' columns of table 'tasks'
+const MaxTextLen = 2048
+
+// Rule - check of one field value, returns reason if value is invalid or "" if it is valid
+//
+// rule may normalize value (look Trim), so it gets pointer
+type Rule func(value *string) string
+
+// Field - value of Request and rules for it, rules are called in order
+// after first broken rule next rules of field are not called
+type Field struct {
+	Name  string
+	Value *string
+	Rules []Rule
+}
+
+// ValidationErrors - all invalid fields of one object
+type ValidationErrors []common.InvalidParam
+
+func (ve ValidationErrors) Error() string {
+	reasons := make([]string, 0, len(ve))
+	for _, p := range ve {
+		reasons = append(reasons, p.Name+": "+p.Reason)
+	}
+	return fmt.Sprintf("%s - %s", ErrservisesValidatorInvalidTask, strings.Join(reasons, ", "))
+}
+
+func (ve ValidationErrors) Unwrap() error {
+	return ErrservisesValidatorInvalidTask
+}
+
+// InvalidParams - field-level reasons for 'problem+json' (look ~> ../../pkg/common/problem.go)
+func (ve ValidationErrors) InvalidParams() []common.InvalidParam {
+	return ve
+}
+
+// Validate - check all fields and collect all errors at once
+//
+// returns nil or 'ValidationErrors'
+func Validate(fields ...Field) error {
+	var ve ValidationErrors
+	for _, field := range fields {
+		for _, rule := range field.Rules {
+			if reason := rule(field.Value); reason != "" {
+				ve = append(ve, common.InvalidParam{Name: field.Name, Reason: reason})
+				break
+			}
+		}
+	}
+	if len(ve) > 0 {
+		return ve
+	}
+	return nil
+}
+
+// Trim - remove leading and trailing white space, never fails
+func Trim() Rule {
+	return func(value *string) string {
+		*value = strings.TrimSpace(*value)
+		return ""
+	}
+}
+
+// Required - value is not empty
+func Required() Rule {
+	return func(value *string) string {
+		if *value == "" {
+			return "required"
+		}
+		return ""
+	}
+}
+
+// MaxRunes - length of value in runes (characters of Postgres VARCHAR)
+func MaxRunes(n int) Rule {
+	return func(value *string) string {
+		if utf8.RuneCountInString(*value) > n {
+			return fmt.Sprintf("must be at most %d characters", n)
+		}
+		return ""
+	}
+}
+
+// MinRunes - length of value in runes, empty value is skipped (use with Required)
+func MinRunes(n int) Rule {
+	return func(value *string) string {
+		if *value != "" && utf8.RuneCountInString(*value) < n {
+			return fmt.Sprintf("must be at least %d characters", n)
+		}
+		return ""
+	}
+}
+
+// HTTPURL - absolute URL with scheme http or https, empty value is skipped (use with Required)
+func HTTPURL() Rule {
+	return func(value *string) string {
+		if *value == "" {
+			return ""
+		}
+		u, err := url.Parse(*value)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return "must be absolute http or https URL"
+		}
+		return ""
+	}
+}
+
+// NoControl - value is valid UTF-8 without control characters, '\n', '\r' and '\t' are allowed
+func NoControl() Rule {
+	return func(value *string) string {
+		if !utf8.ValidString(*value) {
+			return "must be valid UTF-8"
+		}
+		for _, r := range *value {
+			if unicode.IsControl(r) && r != '\n' && r != '\r' && r != '\t' {
+				return "must not contain control characters"
+			}
+		}
+		return ""
+	}
+}
+
+// OneOf - value is one of 'allowed', empty value is skipped (use with Required)
+func OneOf(allowed ...string) Rule {
+	return func(value *string) string {
+		if *value == "" {
+			return ""
+		}
+		for _, a := range allowed {
+			if *value == a {
+				return ""
+			}
+		}
+		return "must be one of: " + strings.Join(allowed, ", ")
+	}
+}
+
+// Timestamp - RFC 3339 time with offset ("2025-03-01T09:00:00+01:00"), empty value is skipped (use with Required)
+func Timestamp() Rule {
+	return func(value *string) string {
+		if *value == "" {
+			return ""
+		}
+		if _, err := time.Parse(time.RFC3339, *value); err != nil {
+			return "must be RFC 3339 time"
+		}
+		return ""
+	}
+}
+
+// RRule - value of RFC 5545 RRULE ("FREQ=WEEKLY;BYDAY=MO"), it is normalized to upper case without prefix "RRULE:",
+// empty value is skipped (use with Required)
+func RRule() Rule {
+	return func(value *string) string {
+		if *value == "" {
+			return ""
+		}
+		if _, err := recurrence.Parse(*value); err != nil {
+			return strings.TrimPrefix(err.Error(), recurrence.ErrRecurrenceRule.Error()+" - ")
+		}
+		*value = strings.TrimPrefix(strings.ToUpper(*value), "RRULE:")
+		return ""
+	}
+}
+
+// TimeZone - IANA name of time zone ("Europe/Berlin"), empty value is skipped (use with Required)
+func TimeZone() Rule {
+	return func(value *string) string {
+		if *value == "" {
+			return ""
+		}
+		if _, err := recurrence.LoadLocation(*value); err != nil {
+			return "must be IANA time zone"
+		}
+		return ""
+	}
+}
+
+// RequiredWith - value is required if 'other' is not empty
+func RequiredWith(other *string, name string) Rule {
+	return func(value *string) string {
+		if *value == "" && *other != "" {
+			return "required with " + name
+		}
+		return ""
+	}
+}
+
+// RequiredWithout - value is required if 'other' is empty
+func RequiredWithout(other *string, name string) Rule {
+	return func(value *string) string {
+		if *value == "" && *other == "" {
+			return "required without " + name
+		}
+		return ""
+	}
+}
+
+// ExcludedWith - value must be empty if 'other' is not empty
+func ExcludedWith(other *string, name string) Rule {
+	return func(value *string) string {
+		if *value != "" && *other != "" {
+			return "must not be with " + name
+		}
+		return ""
+	}
+}
+
+// After - RFC 3339 time after valid time 'start' and not later than 'max' after it,
+// empty value or invalid 'start' is skipped (use with Timestamp)
+func After(start *string, name string, max time.Duration) Rule {
+	return func(value *string) string {
+		from, err := time.Parse(time.RFC3339, *start)
+		if *value == "" || err != nil {
+			return ""
+		}
+		to, err := time.Parse(time.RFC3339, *value)
+		if err != nil {
+			return ""
+		}
+		if !to.After(from) {
+			return "must be after " + name
+		}
+		if to.Sub(from) > max {
+			return fmt.Sprintf("must be at most %s after %s", max, name)
+		}
+		return ""
+	}
+}
+
+// Duration - positive Go duration ("1h30m") not longer than 'max', empty value is skipped (use with Required)
+func Duration(max time.Duration) Rule {
+	return func(value *string) string {
+		if *value == "" {
+			return ""
+		}
+		d, err := time.ParseDuration(*value)
+		if err != nil || d <= 0 {
+			return "must be positive duration (\"1h30m\")"
+		}
+		if d > max {
+			return fmt.Sprintf("must be at most %s", max)
+		}
+		return ""
+	}
+}
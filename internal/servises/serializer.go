@@ -1,13 +1,17 @@
 package servises
 
 import (
+	"time"
+
 	"github.com/Ekvo/golang-chi-postgres-api/internal/model"
+	"github.com/Ekvo/golang-chi-postgres-api/internal/recurrence"
 	"github.com/Ekvo/golang-chi-postgres-api/internal/variables"
 )
 
-// TaskSerializer - contains one "models.Task" to serialize into Response
+// TaskSerializer - contains one "models.Task" to serialize into Response, 'Tracked' - its time entries (nil - none)
 type TaskSerializer struct {
 	model.Task
+	Tracked *TimeTrackedResponse
 }
 
 // TaskResponse - format object 'Task' for 'Response'
@@ -16,10 +20,23 @@ type TaskResponse struct {
 	Note        string `json:"note,omitempty"`
 	CreatedAt   string `json:"created_at"`
 	UpdatedAt   string `json:"updated_at,omitempty"`
+	RemindAt    string `json:"remind_at,omitempty"`
+	DueAt       string `json:"due_at,omitempty"`
+	TimeZone    string `json:"time_zone,omitempty"`
+	RRule       string `json:"rrule,omitempty"`
+	// CustomFields - values of custom fields by name
+	CustomFields map[string]any `json:"custom_fields,omitempty"`
+	ProjectID    uint           `json:"project_id,omitempty"`
+	Done         bool           `json:"done,omitempty"`
+	// TimeTracked - totals of time entries, only for reading of 'Task'
+	TimeTracked *TimeTrackedResponse `json:"time_tracked,omitempty"`
 }
 
 // (ts *TaskSerializer) Response() - returns an object to write to 'ResponseWriter'
+//
+// 'remind_at' and 'due_at' are in time zone of 'Task', 'created_at' and 'updated_at' - in UTC
 func (ts *TaskSerializer) Response() TaskResponse {
+	loc := taskLocation(ts.TimeZone)
 	tr := TaskResponse{
 		Description: ts.Description,
 		Note:        ts.Note,
@@ -28,11 +45,66 @@ func (ts *TaskSerializer) Response() TaskResponse {
 	if ptrUpAt := ts.UpdatedAt; ptrUpAt != nil {
 		tr.UpdatedAt = ptrUpAt.UTC().Format(variables.RFC3339Milli)
 	}
+	if remindAt := ts.RemindAt; remindAt != nil {
+		tr.RemindAt = remindAt.In(loc).Format(variables.RFC3339Milli)
+	}
+	if dueAt := ts.DueAt; dueAt != nil {
+		tr.DueAt = dueAt.In(loc).Format(variables.RFC3339Milli)
+	}
+	tr.TimeZone = ts.TimeZone
+	if rec := ts.Recurrence; rec != nil {
+		tr.RRule = rec.RRule
+	}
+	tr.CustomFields = ts.CustomFields
+	tr.ProjectID = ts.ProjectID
+	tr.Done = ts.Done
+	if tracked := ts.Tracked; tracked != nil {
+		tr.TimeTracked = &TimeTrackedResponse{TotalSeconds: tracked.TotalSeconds, Days: tracked.Days}
+	}
 	return tr
 }
 
+// taskLocation - time zone of 'Task', invalid name (it is checked before save) - UTC
+func taskLocation(name string) *time.Location {
+	loc, err := recurrence.LoadLocation(name)
+	if err != nil {
+		return time.UTC
+	}
+	return loc
+}
+
+// OccurrencesResponse - upcoming occurrences of 'Task' for 'Response', times are in time zone of 'Task'
+type OccurrencesResponse struct {
+	TaskID      uint     `json:"task_id"`
+	TimeZone    string   `json:"time_zone,omitempty"`
+	RRule       string   `json:"rrule,omitempty"`
+	Occurrences []string `json:"occurrences"`
+}
+
+// CalendarFeedResponse - secret feed of user for 'Response', 'URL' is relative to the server
+type CalendarFeedResponse struct {
+	User  string `json:"user"`
+	Token string `json:"token"`
+	URL   string `json:"url"`
+}
+
+// NewOccurrencesResponse - 'occurrences' of 'task' (look: recurrence.Occurrences)
+func NewOccurrencesResponse(task model.Task, occurrences []time.Time) OccurrencesResponse {
+	loc := taskLocation(task.TimeZone)
+	resp := OccurrencesResponse{TaskID: task.ID, TimeZone: task.TimeZone, Occurrences: make([]string, len(occurrences))}
+	if task.Recurrence != nil {
+		resp.RRule = task.Recurrence.RRule
+	}
+	for i, occ := range occurrences {
+		resp.Occurrences[i] = occ.In(loc).Format(variables.RFC3339Milli)
+	}
+	return resp
+}
+
+// TaskListSerializer - 'Tracked' - totals of time entries by id of 'Task' (look: NewTimeTracked)
 type TaskListSerializer struct {
-	Tasks []model.Task
+	Tasks   []model.Task
+	Tracked map[uint]*TimeTrackedResponse
 }
 
 func (tls *TaskListSerializer) Response() []TaskResponse {
@@ -40,8 +112,52 @@ func (tls *TaskListSerializer) Response() []TaskResponse {
 	n := len(aliasTask)
 	tasksResponse := make([]TaskResponse, n)
 	for i := 0; i < n; i++ {
-		serialize := TaskSerializer{aliasTask[i]}
+		serialize := TaskSerializer{Task: aliasTask[i], Tracked: tls.Tracked[aliasTask[i].ID]}
 		tasksResponse[i] = serialize.Response()
 	}
 	return tasksResponse
 }
+
+// BatchItemResponse - result of one operation of 'task_batch' for 'Response'
+type BatchItemResponse struct {
+	Index  int    `json:"index"`
+	Op     string `json:"op"`
+	Status int    `json:"status"`
+	ID     uint   `json:"id,omitempty"`
+	Error  string `json:"error,omitempty"`
+}
+
+// ImportResponse - report of import for 'Response'
+type ImportResponse struct {
+	Imported      int           `json:"imported"`
+	DryRun        bool          `json:"dry_run"`
+	RejectedCount int           `json:"rejected_count"`
+	Rejected      []RejectedRow `json:"rejected,omitempty"`
+}
+
+// TaskEventSerializer - contains one "model.TaskEvent" to serialize into stream of events
+type TaskEventSerializer struct {
+	model.TaskEvent
+}
+
+// TaskEventResponse - format object 'TaskEvent' for 'Response', 'Task' is empty for "deleted"
+type TaskEventResponse struct {
+	Type       string        `json:"type"`
+	TaskID     uint          `json:"task_id"`
+	Task       *TaskResponse `json:"task,omitempty"`
+	OccurredAt string        `json:"occurred_at"`
+}
+
+func (tes *TaskEventSerializer) Response() TaskEventResponse {
+	ter := TaskEventResponse{
+		Type:       tes.Type,
+		TaskID:     tes.TaskID,
+		OccurredAt: tes.At.UTC().Format(variables.RFC3339Milli),
+	}
+	if tes.Task != nil {
+		serialize := TaskSerializer{Task: *tes.Task}
+		task := serialize.Response()
+		ter.Task = &task
+	}
+	return ter
+}
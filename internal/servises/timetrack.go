@@ -0,0 +1,234 @@
+package servises
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Ekvo/golang-chi-postgres-api/internal/model"
+	"github.com/Ekvo/golang-chi-postgres-api/internal/variables"
+	"github.com/Ekvo/golang-chi-postgres-api/pkg/common"
+)
+
+// MaxTimeEntry - the longest manual entry
+const MaxTimeEntry = 24 * time.Hour
+
+// TimeEntryValidator - describe property of getting 'model.TimeEntry' from a Request
+//
+// manual entry has 'start' and one of 'stop' or 'duration' ("1h30m"), timer has only 'note'
+type TimeEntryValidator struct {
+	Data struct {
+		Start    string `json:"start,omitempty"`
+		Stop     string `json:"stop,omitempty"`
+		Duration string `json:"duration,omitempty"`
+		Note     string `json:"note"`
+	} `json:"time_entry"`
+	entry model.TimeEntry `json:"-"`
+}
+
+func NewTimeEntryValidator() *TimeEntryValidator {
+	return &TimeEntryValidator{}
+}
+
+func (tev *TimeEntryValidator) TimeEntryModel() model.TimeEntry {
+	return tev.entry
+}
+
+// Decode - get 'Data' of manual entry and create 'TimeEntry' with 'StopAt'
+func (tev *TimeEntryValidator) Decode(r *http.Request) error {
+	if err := common.Decode(r, tev); err != nil {
+		return err
+	}
+	if err := Validate(
+		Field{
+			Name:  "start",
+			Value: &tev.Data.Start,
+			Rules: []Rule{Trim(), Required(), Timestamp()},
+		},
+		Field{
+			Name:  "stop",
+			Value: &tev.Data.Stop,
+			Rules: []Rule{Trim(), RequiredWithout(&tev.Data.Duration, "duration"), Timestamp(),
+				After(&tev.Data.Start, "start", MaxTimeEntry)},
+		},
+		Field{
+			Name:  "duration",
+			Value: &tev.Data.Duration,
+			Rules: []Rule{Trim(), ExcludedWith(&tev.Data.Stop, "stop"), Duration(MaxTimeEntry)},
+		},
+		tev.noteField(),
+	); err != nil {
+		return err
+	}
+	start := parseUTC(tev.Data.Start)
+	stop := parseUTC(tev.Data.Stop)
+	if stop == nil {
+		d, _ := time.ParseDuration(tev.Data.Duration)
+		end := start.Add(d)
+		stop = &end
+	}
+	tev.entry = model.TimeEntry{StartAt: *start, StopAt: stop, Note: tev.Data.Note, CreatedAt: time.Now().UTC()}
+	return nil
+}
+
+// DecodeTimer - running 'TimeEntry' from now, body is optional (only 'note')
+func (tev *TimeEntryValidator) DecodeTimer(r *http.Request) error {
+	if r.ContentLength != 0 {
+		if err := common.Decode(r, tev); err != nil {
+			return err
+		}
+	}
+	if err := Validate(tev.noteField()); err != nil {
+		return err
+	}
+	now := time.Now().UTC()
+	tev.entry = model.TimeEntry{StartAt: now, Note: tev.Data.Note, CreatedAt: now}
+	return nil
+}
+
+func (tev *TimeEntryValidator) noteField() Field {
+	return Field{
+		Name:  "note",
+		Value: &tev.Data.Note,
+		Rules: []Rule{Trim(), MaxRunes(MaxTextLen), NoControl()},
+	}
+}
+
+// NewTimeReport - 'model.TimeReport' from params 'from', 'to' (RFC 3339), 'user', 'time_zone' (IANA)
+// and 'task_id' (list "1,2" or repeated param)
+func NewTimeReport(query url.Values) (model.TimeReport, error) {
+	from, to := query.Get("from"), query.Get("to")
+	report := model.TimeReport{User: query.Get("user"), TimeZone: query.Get("time_zone")}
+	fields := []Field{
+		{Name: "from", Value: &from, Rules: []Rule{Trim(), Timestamp()}},
+		{Name: "to", Value: &to, Rules: []Rule{Trim(), Timestamp()}},
+		{Name: "user", Value: &report.User, Rules: []Rule{Trim(), MaxRunes(256)}},
+		{Name: "time_zone", Value: &report.TimeZone, Rules: []Rule{Trim(), MaxRunes(64), TimeZone()}},
+	}
+	err := Validate(fields...)
+	ve, _ := err.(ValidationErrors)
+	for _, value := range query["task_id"] {
+		for _, part := range strings.Split(value, ",") {
+			id, err := strconv.ParseUint(strings.TrimSpace(part), 10, 32)
+			if err != nil || id == 0 {
+				ve = append(ve, common.InvalidParam{Name: "task_id", Reason: fmt.Sprintf("%q is not id of task", part)})
+				continue
+			}
+			report.TaskIDs = append(report.TaskIDs, uint(id))
+		}
+	}
+	if len(ve) > 0 {
+		return report, ve
+	}
+	report.From = parseUTC(from)
+	report.To = parseUTC(to)
+	return report, nil
+}
+
+// TimeEntryResponse - format object 'TimeEntry' for 'Response', running timer is counted till now
+type TimeEntryResponse struct {
+	ID        uint64 `json:"id"`
+	TaskID    uint   `json:"task_id"`
+	User      string `json:"user"`
+	Start     string `json:"start"`
+	Stop      string `json:"stop,omitempty"`
+	Seconds   int64  `json:"seconds"`
+	Running   bool   `json:"running"`
+	Note      string `json:"note,omitempty"`
+	CreatedAt string `json:"created_at"`
+}
+
+func NewTimeEntryResponse(entry model.TimeEntry, now time.Time) TimeEntryResponse {
+	resp := TimeEntryResponse{
+		ID:        entry.ID,
+		TaskID:    entry.TaskID,
+		User:      entry.User,
+		Start:     entry.StartAt.UTC().Format(variables.RFC3339Milli),
+		Seconds:   int64(entry.Duration(now) / time.Second),
+		Running:   entry.StopAt == nil,
+		Note:      entry.Note,
+		CreatedAt: entry.CreatedAt.UTC().Format(variables.RFC3339Milli),
+	}
+	if entry.StopAt != nil {
+		resp.Stop = entry.StopAt.UTC().Format(variables.RFC3339Milli)
+	}
+	return resp
+}
+
+// DayTotalResponse - time of one day for 'Response'
+type DayTotalResponse struct {
+	Day     string `json:"day"`
+	Seconds int64  `json:"seconds"`
+}
+
+// TimeTrackedResponse - totals of one 'Task' for 'Response'
+type TimeTrackedResponse struct {
+	TaskID       uint               `json:"task_id,omitempty"`
+	TotalSeconds int64              `json:"total_seconds"`
+	Days         []DayTotalResponse `json:"days"`
+}
+
+// TimeEntriesResponse - entries of 'Task' and their totals
+type TimeEntriesResponse struct {
+	Entries []TimeEntryResponse `json:"entries"`
+	TimeTrackedResponse
+}
+
+// TimeReportResponse - totals of report by 'Task' and by day
+type TimeReportResponse struct {
+	From         string                `json:"from,omitempty"`
+	To           string                `json:"to,omitempty"`
+	User         string                `json:"user,omitempty"`
+	TimeZone     string                `json:"time_zone,omitempty"`
+	TotalSeconds int64                 `json:"total_seconds"`
+	Tasks        []TimeTrackedResponse `json:"tasks"`
+	Days         []DayTotalResponse    `json:"days"`
+}
+
+// NewTimeTracked - totals of each 'Task' by id, 'totals' are in order of 'Task' and day (look: model.TimeTracker)
+func NewTimeTracked(totals []model.TimeTotal) map[uint]*TimeTrackedResponse {
+	tracked := map[uint]*TimeTrackedResponse{}
+	for _, total := range totals {
+		resp, ok := tracked[total.TaskID]
+		if !ok {
+			resp = &TimeTrackedResponse{TaskID: total.TaskID}
+			tracked[total.TaskID] = resp
+		}
+		secs := int64(total.Duration / time.Second)
+		resp.TotalSeconds += secs
+		resp.Days = append(resp.Days, DayTotalResponse{Day: total.Day, Seconds: secs})
+	}
+	return tracked
+}
+
+// NewTimeReportResponse - 'totals' by 'Task' and by day of all tasks, days in order of date
+func NewTimeReportResponse(report model.TimeReport, totals []model.TimeTotal) TimeReportResponse {
+	resp := TimeReportResponse{User: report.User, TimeZone: report.TimeZone, Tasks: []TimeTrackedResponse{}, Days: []DayTotalResponse{}}
+	if report.From != nil {
+		resp.From = report.From.UTC().Format(variables.RFC3339Milli)
+	}
+	if report.To != nil {
+		resp.To = report.To.UTC().Format(variables.RFC3339Milli)
+	}
+	tracked := NewTimeTracked(totals)
+	byDay := map[string]int64{}
+	for _, total := range totals {
+		if len(resp.Tasks) == 0 || resp.Tasks[len(resp.Tasks)-1].TaskID != total.TaskID {
+			resp.Tasks = append(resp.Tasks, *tracked[total.TaskID])
+			resp.TotalSeconds += tracked[total.TaskID].TotalSeconds
+		}
+		if _, ok := byDay[total.Day]; !ok {
+			resp.Days = append(resp.Days, DayTotalResponse{Day: total.Day})
+		}
+		byDay[total.Day] += int64(total.Duration / time.Second)
+	}
+	sort.Slice(resp.Days, func(i, j int) bool { return resp.Days[i].Day < resp.Days[j].Day })
+	for i := range resp.Days {
+		resp.Days[i].Seconds = byDay[resp.Days[i].Day]
+	}
+	return resp
+}
@@ -16,8 +16,18 @@ type TaskValidator struct {
 	Data struct {
 		Description string `json:"description"`
 		Note        string `json:"note"`
+		RemindAt    string `json:"remind_at,omitempty"`
+		DueAt       string `json:"due_at,omitempty"`
+		TimeZone    string `json:"time_zone,omitempty"`
+		RRule       string `json:"rrule,omitempty"`
+		// CustomFields - values by name, null - no value
+		CustomFields map[string]any `json:"custom_fields,omitempty"`
+		// ProjectID - 'Project' of 'Task', it is checked by store (0 - none)
+		ProjectID uint `json:"project_id,omitempty"`
+		Done      bool `json:"done,omitempty"`
 	} `json:"task_update"`
-	task model.Task `json:"-"`
+	task   model.Task          `json:"-"`
+	schema []model.CustomField `json:"-"`
 }
 
 // NewTaskValidator - if need add 'Default' params
@@ -25,20 +35,137 @@ func NewTaskValidator() *TaskValidator {
 	return &TaskValidator{}
 }
 
+// WithCustomFields - schema of 'Data.CustomFields', without it 'Task' has no custom fields
+// and stored values are kept by update
+func (tv *TaskValidator) WithCustomFields(schema []model.CustomField) *TaskValidator {
+	tv.schema = append([]model.CustomField{}, schema...)
+	return tv
+}
+
 func (tv *TaskValidator) TaskModel() model.Task {
 	return tv.task
 }
 
-// DecodeJSON - get 'Data' and create 'Task'
-func (tv *TaskValidator) DecodeJSON(r *http.Request) error {
-	if err := common.DecodeJSON(r, tv); err != nil {
+// Decode - get 'Data' and create 'Task', format of body by 'Content-Type'
+func (tv *TaskValidator) Decode(r *http.Request) error {
+	if err := common.Decode(r, tv); err != nil {
 		return err
 	}
-	if tv.Data.Description == "" {
-		return ErrservisesValidatorInvalidTask
+	return tv.build()
+}
+
+// TaskFromData - create 'Task' by rules of 'TaskValidator' without Request (gRPC, jobs)
+func TaskFromData(description, note string) (model.Task, error) {
+	tv := NewTaskValidator()
+	tv.Data.Description = description
+	tv.Data.Note = note
+	if err := tv.build(); err != nil {
+		return model.Task{}, err
+	}
+	return tv.task, nil
+}
+
+// build - validate 'Data' and create 'Task'
+func (tv *TaskValidator) build() error {
+	if err := tv.validate(); err != nil {
+		return err
 	}
 	tv.task.Description = tv.Data.Description
 	tv.task.Note = tv.Data.Note
+	tv.task.RemindAt = tv.remindAt()
+	tv.task.DueAt = tv.dueAt()
+	tv.task.TimeZone = tv.Data.TimeZone
+	tv.task.Recurrence = tv.recurrence()
+	tv.task.CustomFields = tv.customFields()
+	tv.task.ProjectID = tv.Data.ProjectID
+	tv.task.Done = tv.Data.Done
 	tv.task.CreatedAt = time.Now().UTC()
 	return nil
 }
+
+// validate - rules of 'Data', all fields are checked at once
+func (tv *TaskValidator) validate() error {
+	fields := taskFields("", &tv.Data.Description, &tv.Data.Note)
+	fields = append(fields, Field{
+		Name:  "remind_at",
+		Value: &tv.Data.RemindAt,
+		Rules: []Rule{Trim(), Timestamp()},
+	}, Field{
+		Name:  "time_zone",
+		Value: &tv.Data.TimeZone,
+		Rules: []Rule{Trim(), MaxRunes(64), TimeZone()},
+	}, Field{
+		Name:  "rrule",
+		Value: &tv.Data.RRule,
+		Rules: []Rule{Trim(), MaxRunes(512), RRule()},
+	}, Field{
+		Name:  "due_at",
+		Value: &tv.Data.DueAt,
+		Rules: []Rule{Trim(), RequiredWith(&tv.Data.RRule, "rrule"), Timestamp()},
+	})
+	ve, _ := Validate(fields...).(ValidationErrors)
+	ve = append(ve, ValidCustomFields("custom_fields.", tv.schema, tv.Data.CustomFields)...)
+	if len(ve) > 0 {
+		return ve
+	}
+	return nil
+}
+
+// customFields - valid 'Data.CustomFields', without schema - nil, empty - empty map (values are removed)
+func (tv *TaskValidator) customFields() map[string]any {
+	if tv.schema == nil {
+		return nil
+	}
+	if len(tv.Data.CustomFields) == 0 {
+		return map[string]any{}
+	}
+	return tv.Data.CustomFields
+}
+
+// remindAt - valid 'Data.RemindAt' in UTC, empty - nil
+func (tv *TaskValidator) remindAt() *time.Time {
+	return parseUTC(tv.Data.RemindAt)
+}
+
+// dueAt - valid 'Data.DueAt' in UTC, empty - nil
+func (tv *TaskValidator) dueAt() *time.Time {
+	return parseUTC(tv.Data.DueAt)
+}
+
+// recurrence - 'Data.RRule' from 'Data.DueAt' (DTSTART), empty - nil
+func (tv *TaskValidator) recurrence() *model.Recurrence {
+	dueAt := tv.dueAt()
+	if tv.Data.RRule == "" || dueAt == nil {
+		return nil
+	}
+	return &model.Recurrence{RRule: tv.Data.RRule, Start: *dueAt}
+}
+
+// parseUTC - RFC 3339 time in UTC, empty or invalid - nil
+func parseUTC(value string) *time.Time {
+	if value == "" {
+		return nil
+	}
+	at, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		return nil
+	}
+	at = at.UTC()
+	return &at
+}
+
+// taskFields - rules of fields 'description' and 'note', 'prefix' is added to the names of fields
+func taskFields(prefix string, description, note *string) []Field {
+	return []Field{
+		{
+			Name:  prefix + "description",
+			Value: description,
+			Rules: []Rule{Trim(), Required(), MaxRunes(MaxTextLen), NoControl()},
+		},
+		{
+			Name:  prefix + "note",
+			Value: note,
+			Rules: []Rule{Trim(), MaxRunes(MaxTextLen), NoControl()},
+		},
+	}
+}
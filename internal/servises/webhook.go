@@ -0,0 +1,156 @@
+package servises
+
+import (
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/Ekvo/golang-chi-postgres-api/internal/model"
+	"github.com/Ekvo/golang-chi-postgres-api/internal/variables"
+	"github.com/Ekvo/golang-chi-postgres-api/pkg/common"
+)
+
+// rules of 'Webhook', look table 'webhooks'
+const (
+	MaxWebhookURLLen = 2048
+	MinSecretLen     = 16
+	MaxSecretLen     = 256
+)
+
+// WebhookValidator - describe property of getting 'model.Webhook' from a Request
+type WebhookValidator struct {
+	Data struct {
+		URL    string   `json:"url"`
+		Events []string `json:"events"`
+		Secret string   `json:"secret"`
+	} `json:"webhook"`
+	hook model.Webhook `json:"-"`
+}
+
+func NewWebhookValidator() *WebhookValidator {
+	return &WebhookValidator{}
+}
+
+func (wv *WebhookValidator) WebhookModel() model.Webhook {
+	return wv.hook
+}
+
+// Decode - get 'Data' and create 'Webhook', empty 'events' - all types of events
+func (wv *WebhookValidator) Decode(r *http.Request) error {
+	if err := common.Decode(r, wv); err != nil {
+		return err
+	}
+	fields := []Field{
+		{
+			Name:  "url",
+			Value: &wv.Data.URL,
+			Rules: []Rule{Trim(), Required(), MaxRunes(MaxWebhookURLLen), HTTPURL()},
+		},
+		{
+			Name:  "secret",
+			Value: &wv.Data.Secret,
+			Rules: []Rule{Required(), MinRunes(MinSecretLen), MaxRunes(MaxSecretLen)},
+		},
+	}
+	for i := range wv.Data.Events {
+		fields = append(fields, Field{
+			Name:  fmt.Sprintf("events[%d]", i),
+			Value: &wv.Data.Events[i],
+			Rules: []Rule{Required(), OneOf(model.EventCreated, model.EventUpdated, model.EventDeleted)},
+		})
+	}
+	if err := Validate(fields...); err != nil {
+		return err
+	}
+	wv.hook = model.Webhook{
+		URL:       wv.Data.URL,
+		Events:    wv.Data.Events,
+		Secret:    wv.Data.Secret,
+		CreatedAt: time.Now().UTC(),
+	}
+	return nil
+}
+
+// WebhookResponse - format object 'Webhook' for 'Response', secret is never shown
+type WebhookResponse struct {
+	ID        uint     `json:"id"`
+	URL       string   `json:"url"`
+	Events    []string `json:"events"`
+	CreatedAt string   `json:"created_at"`
+}
+
+type WebhookListSerializer struct {
+	Webhooks []model.Webhook
+}
+
+func (wls *WebhookListSerializer) Response() []WebhookResponse {
+	hooks := make([]WebhookResponse, len(wls.Webhooks))
+	for i, hook := range wls.Webhooks {
+		events := hook.Events
+		if events == nil {
+			events = []string{}
+		}
+		hooks[i] = WebhookResponse{
+			ID:        hook.ID,
+			URL:       hook.URL,
+			Events:    events,
+			CreatedAt: hook.CreatedAt.UTC().Format(variables.RFC3339Milli),
+		}
+	}
+	return hooks
+}
+
+// WebhookDeliveryResponse - format object 'WebhookDelivery' for log of deliveries
+type WebhookDeliveryResponse struct {
+	ID            uint64 `json:"id"`
+	WebhookID     uint   `json:"webhook_id"`
+	EventID       uint64 `json:"event_id"`
+	Type          string `json:"type"`
+	TaskID        uint   `json:"task_id"`
+	Status        string `json:"status"`
+	Attempts      int    `json:"attempts"`
+	NextAttemptAt string `json:"next_attempt_at,omitempty"`
+	LastStatus    int    `json:"last_status,omitempty"`
+	LastError     string `json:"last_error,omitempty"`
+	UpdatedAt     string `json:"updated_at,omitempty"`
+}
+
+type WebhookDeliveriesSerializer struct {
+	Deliveries []model.WebhookDelivery
+}
+
+func (wds *WebhookDeliveriesSerializer) Response() []WebhookDeliveryResponse {
+	out := make([]WebhookDeliveryResponse, len(wds.Deliveries))
+	for i, dl := range wds.Deliveries {
+		out[i] = WebhookDeliveryResponse{
+			ID:         dl.ID,
+			WebhookID:  dl.WebhookID,
+			EventID:    dl.Event.ID,
+			Type:       dl.Event.Type,
+			TaskID:     dl.Event.TaskID,
+			Status:     dl.Status,
+			Attempts:   dl.Attempts,
+			LastStatus: dl.LastStatus,
+			LastError:  dl.LastError,
+		}
+		// time of next attempt has sense only for pending delivery
+		if dl.Status == model.DeliveryPending {
+			out[i].NextAttemptAt = dl.NextAttemptAt.UTC().Format(variables.RFC3339Milli)
+		}
+		if dl.UpdatedAt != nil {
+			out[i].UpdatedAt = dl.UpdatedAt.UTC().Format(variables.RFC3339Milli)
+		}
+	}
+	return out
+}
+
+// WebhookPayload - body of delivery, 'EventID' is the same for all attempts (key of deduplication)
+type WebhookPayload struct {
+	EventID uint64 `json:"event_id"`
+	TaskEventResponse
+}
+
+func NewWebhookPayload(ev model.TaskEvent) WebhookPayload {
+	serialize := TaskEventSerializer{TaskEvent: ev}
+	return WebhookPayload{EventID: ev.ID, TaskEventResponse: serialize.Response()}
+}
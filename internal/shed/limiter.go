@@ -0,0 +1,235 @@
+// shed - adaptive limit of requests in flight (AIMD), waiting in queue and load shedding
+package shed
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// ErrShed - request waited in queue longer than target or queue is full
+var ErrShed = errors.New("server is overloaded")
+
+// Priority - order of requests in queue, 'Write' is served before 'Read'
+type Priority int
+
+const (
+	Read Priority = iota
+	Write
+)
+
+// parameters of limit
+const (
+	// backoff - multiplicative decrease of limit, at most once in 'latencyTarget'
+	backoff = 0.9
+
+	// readShare - part of limit for reads, the rest is kept for writes
+	readShare = 0.75
+)
+
+// Limiter - limit of requests in flight between 1 and 'max'
+//
+// limit grows by 1 in a window of full use (additive increase) and is cut by 'backoff'
+// when time of request is above 'latencyTarget' (multiplicative decrease);
+// request above limit waits in queue up to 'queueTarget', then it is shed
+type Limiter struct {
+	mu       sync.Mutex
+	limit    float64
+	max      int
+	inFlight int
+	queues   [2][]*waiter
+	decrease time.Time
+
+	queueTarget   time.Duration
+	latencyTarget time.Duration
+
+	admitted  [2]int64
+	rejected  [2]int64
+	decreases int64
+
+	now func() time.Time
+}
+
+type waiter struct {
+	ready    chan struct{}
+	admitted bool
+}
+
+// NewLimiter - limit starts from 'max'
+func NewLimiter(max int, queueTarget, latencyTarget time.Duration) *Limiter {
+	return &Limiter{
+		limit:         float64(max),
+		max:           max,
+		queueTarget:   queueTarget,
+		latencyTarget: latencyTarget,
+		now:           time.Now,
+	}
+}
+
+// Acquire - place of request in flight, above limit request waits in queue of its priority
+// up to 'queueTarget', then 'ErrShed'; Token is released by 'Done'
+func (l *Limiter) Acquire(ctx context.Context, p Priority) (*Token, error) {
+	l.mu.Lock()
+	if len(l.queues[Write]) == 0 && (p == Write || len(l.queues[Read]) == 0) && l.inFlight < l.capacity(p) {
+		l.inFlight++
+		l.admitted[p]++
+		l.mu.Unlock()
+		return l.token(), nil
+	}
+	if len(l.queues[Read])+len(l.queues[Write]) >= l.max {
+		l.rejected[p]++
+		l.mu.Unlock()
+		return nil, ErrShed
+	}
+	w := &waiter{ready: make(chan struct{})}
+	l.queues[p] = append(l.queues[p], w)
+	l.mu.Unlock()
+
+	timer := time.NewTimer(l.queueTarget)
+	defer timer.Stop()
+	err := ErrShed
+	select {
+	case <-w.ready:
+		return l.token(), nil
+	case <-timer.C:
+	case <-ctx.Done():
+		err = ctx.Err()
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	// place was given between end of wait and lock
+	if w.admitted {
+		return l.token(), nil
+	}
+	l.remove(p, w)
+	if errors.Is(err, ErrShed) {
+		l.rejected[p]++
+	}
+	return nil, err
+}
+
+// capacity - limit of priority, reads leave part of limit for writes, under lock
+func (l *Limiter) capacity(p Priority) int {
+	if p == Write {
+		return int(l.limit)
+	}
+	return max(int(l.limit*readShare), 1)
+}
+
+// remove - waiter out of queue, under lock
+func (l *Limiter) remove(p Priority, w *waiter) {
+	for i, queued := range l.queues[p] {
+		if queued == w {
+			l.queues[p] = append(l.queues[p][:i], l.queues[p][i+1:]...)
+			return
+		}
+	}
+}
+
+// release - end of request which took 'latency', new limit and next requests of queues, writes first,
+// latency of bulk request does not change limit
+func (l *Limiter) release(latency time.Duration, bulk bool) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	now := l.now()
+	switch {
+	case bulk:
+	case latency > l.latencyTarget:
+		if now.Sub(l.decrease) >= l.latencyTarget {
+			l.limit = max(l.limit*backoff, 1)
+			l.decrease = now
+			l.decreases++
+		}
+	case l.inFlight >= int(l.limit):
+		l.limit = min(l.limit+1/l.limit, float64(l.max))
+	}
+	l.inFlight--
+	for _, p := range []Priority{Write, Read} {
+		for len(l.queues[p]) > 0 && l.inFlight < l.capacity(p) {
+			w := l.queues[p][0]
+			l.queues[p] = l.queues[p][1:]
+			w.admitted = true
+			close(w.ready)
+			l.inFlight++
+			l.admitted[p]++
+		}
+		if len(l.queues[p]) > 0 {
+			// reads do not pass waiting writes
+			return
+		}
+	}
+}
+
+func (l *Limiter) token() *Token {
+	return &Token{limiter: l, start: l.now(), holds: 1}
+}
+
+// Stats - state of limiter and counters from start
+type Stats struct {
+	Limit          int   `json:"limit"`
+	InFlight       int   `json:"in_flight"`
+	QueuedReads    int   `json:"queued_reads"`
+	QueuedWrites   int   `json:"queued_writes"`
+	AdmittedReads  int64 `json:"admitted_reads"`
+	AdmittedWrites int64 `json:"admitted_writes"`
+	RejectedReads  int64 `json:"rejected_reads"`
+	RejectedWrites int64 `json:"rejected_writes"`
+	Decreases      int64 `json:"decreases"`
+}
+
+func (l *Limiter) Stats() Stats {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return Stats{
+		Limit:          int(l.limit),
+		InFlight:       l.inFlight,
+		QueuedReads:    len(l.queues[Read]),
+		QueuedWrites:   len(l.queues[Write]),
+		AdmittedReads:  l.admitted[Read],
+		AdmittedWrites: l.admitted[Write],
+		RejectedReads:  l.rejected[Read],
+		RejectedWrites: l.rejected[Write],
+		Decreases:      l.decreases,
+	}
+}
+
+// Token - place of one request in flight, it is free after 'Done' of each holder
+type Token struct {
+	limiter *Limiter
+	start   time.Time
+	holds   int32
+	bulk    atomic.Bool
+}
+
+// Bulk - request of many rows (batch), its long time is not a sign of overload and does not cut limit
+func (t *Token) Bulk() {
+	t.bulk.Store(true)
+}
+
+// Hold - one more holder of Token, work which outlives handler (goroutine of query) holds it
+func (t *Token) Hold() {
+	atomic.AddInt32(&t.holds, 1)
+}
+
+// Done - end of holder, the last one gives place back
+func (t *Token) Done() {
+	if atomic.AddInt32(&t.holds, -1) == 0 {
+		t.limiter.release(t.limiter.now().Sub(t.start), t.bulk.Load())
+	}
+}
+
+type tokenKey struct{}
+
+// NewContext - ctx with Token of request
+func NewContext(ctx context.Context, t *Token) context.Context {
+	return context.WithValue(ctx, tokenKey{}, t)
+}
+
+// FromContext - Token of request, nil - request is not limited
+func FromContext(ctx context.Context) *Token {
+	t, _ := ctx.Value(tokenKey{}).(*Token)
+	return t
+}
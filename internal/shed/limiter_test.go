@@ -0,0 +1,162 @@
+package shed
+
+import (
+	"context"
+	"log"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// queueTarget - short wait of tests
+const queueTarget = 100 * time.Millisecond
+
+func TestLimiterQueue(t *testing.T) {
+	asserts := assert.New(t)
+	requires := require.New(t)
+	ctx := context.Background()
+
+	l := NewLimiter(4, queueTarget, time.Second)
+
+	log.Print("\t 1 test limiter: reads leave part of limit for writes\n")
+	var reads []*Token
+	for i := 0; i < 3; i++ {
+		token, err := l.Acquire(ctx, Read)
+		requires.NoError(err)
+		reads = append(reads, token)
+	}
+	_, err := l.Acquire(ctx, Read)
+	asserts.ErrorIs(err, ErrShed, "invalid - read above its share waits and is shed")
+	write, err := l.Acquire(ctx, Write)
+	requires.NoError(err, "valid - write takes kept place")
+
+	log.Print("\t 2 test limiter: waiting write is served before waiting read\n")
+	order := make(chan Priority, 2)
+	for _, p := range []Priority{Read, Write} {
+		go func(p Priority) {
+			token, err := l.Acquire(ctx, p)
+			if err == nil {
+				order <- p
+				token.Done()
+			}
+		}(p)
+		time.Sleep(time.Millisecond)
+	}
+	write.Done()
+	asserts.Equal(Write, <-order)
+	reads[0].Done()
+	asserts.Equal(Read, <-order)
+
+	log.Print("\t 3 test limiter: end of wait by context\n")
+	canceled, cancel := context.WithCancel(ctx)
+	cancel()
+	for i := 1; i < len(reads); i++ {
+		reads[i].Done()
+	}
+	full := make([]*Token, 0, 4)
+	for i := 0; i < 4; i++ {
+		token, err := l.Acquire(ctx, Write)
+		requires.NoError(err)
+		full = append(full, token)
+	}
+	_, err = l.Acquire(canceled, Write)
+	asserts.ErrorIs(err, context.Canceled)
+	for _, token := range full {
+		token.Done()
+	}
+
+	stats := l.Stats()
+	asserts.Equal(0, stats.InFlight)
+	asserts.Equal(int64(1), stats.RejectedReads)
+	asserts.Equal(int64(0), stats.RejectedWrites, "valid - canceled request is not shed")
+	asserts.Equal(int64(4), stats.AdmittedReads)
+	asserts.Equal(int64(6), stats.AdmittedWrites)
+}
+
+func TestLimiterQueueFull(t *testing.T) {
+	asserts := assert.New(t)
+	requires := require.New(t)
+	ctx := context.Background()
+
+	l := NewLimiter(1, time.Second, time.Second)
+	token, err := l.Acquire(ctx, Write)
+	requires.NoError(err)
+	waited := make(chan error)
+	go func() {
+		token, err := l.Acquire(ctx, Write)
+		if err == nil {
+			token.Done()
+		}
+		waited <- err
+	}()
+	for l.Stats().QueuedWrites == 0 {
+		time.Sleep(time.Millisecond)
+	}
+
+	log.Print("\t 1 test limiter: full queue - request is shed at once\n")
+	start := time.Now()
+	_, err = l.Acquire(ctx, Read)
+	asserts.ErrorIs(err, ErrShed)
+	asserts.Less(time.Since(start), time.Second)
+
+	token.Done()
+	asserts.NoError(<-waited)
+}
+
+func TestLimiterAdaptive(t *testing.T) {
+	asserts := assert.New(t)
+	requires := require.New(t)
+	ctx := context.Background()
+
+	l := NewLimiter(10, queueTarget, time.Second)
+	now := time.Date(2025, 3, 1, 12, 0, 0, 0, time.UTC)
+	l.now = func() time.Time { return now }
+
+	log.Print("\t 1 test limiter: slow requests cut limit once in latency target\n")
+	var tokens []*Token
+	for i := 0; i < 3; i++ {
+		token, err := l.Acquire(ctx, Write)
+		requires.NoError(err)
+		tokens = append(tokens, token)
+	}
+	now = now.Add(2 * time.Second)
+	for _, token := range tokens {
+		token.Done()
+	}
+	asserts.Equal(9, l.Stats().Limit)
+	asserts.Equal(int64(1), l.Stats().Decreases)
+
+	log.Print("\t 2 test limiter: limit grows when it is fully used\n")
+	for i := 0; i < 20; i++ {
+		tokens = tokens[:0]
+		for j := 0; j < l.Stats().Limit; j++ {
+			token, err := l.Acquire(ctx, Write)
+			requires.NoError(err)
+			tokens = append(tokens, token)
+		}
+		for _, token := range tokens {
+			token.Done()
+		}
+	}
+	asserts.Equal(10, l.Stats().Limit, "valid - limit is not above max")
+
+	log.Print("\t 3 test limiter: slow bulk request does not cut limit\n")
+	token, err := l.Acquire(ctx, Write)
+	requires.NoError(err)
+	token.Bulk()
+	now = now.Add(time.Minute)
+	token.Done()
+	asserts.Equal(10, l.Stats().Limit)
+	asserts.Equal(int64(1), l.Stats().Decreases)
+
+	log.Print("\t 4 test limiter: holder keeps place after Done of request\n")
+	token, err = l.Acquire(ctx, Write)
+	requires.NoError(err)
+	token.Hold()
+	token.Done()
+	asserts.Equal(1, l.Stats().InFlight)
+	token.Done()
+	asserts.Equal(0, l.Stats().InFlight)
+}
@@ -0,0 +1,170 @@
+// source - metadata of attachments of 'Task', their blobs are removed by job 'model.KindBlobCleanup'
+package source
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"log"
+
+	"github.com/Ekvo/golang-chi-postgres-api/internal/model"
+)
+
+// blobCleanupAttempts - attempts of job of removal of blobs, store of blobs may be unavailable for a while
+const blobCleanupAttempts = 20
+
+// createAttachmentTable - table 'attachments'
+//
+// blob_key is unique - blob belongs to one attachment and is removed with it
+func (d *Dbinstance) createAttachmentTable(ctx context.Context) error {
+	_, err := d.db.ExecContext(ctx, `
+CREATE TABLE IF NOT EXISTS attachments
+(
+    id BIGSERIAL PRIMARY KEY,
+    task_id INTEGER NOT NULL REFERENCES tasks(id) ON DELETE CASCADE,
+    name VARCHAR(255) NOT NULL,
+    content_type VARCHAR(128) NOT NULL,
+    size BIGINT NOT NULL CHECK (size >= 0),
+    sha256 CHAR(64) NOT NULL,
+    blob_key VARCHAR(512) NOT NULL UNIQUE,
+    user_name VARCHAR(256) NOT NULL,
+    created_at TIMESTAMP NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS attachments_task ON attachments(task_id, id);`)
+	return err
+}
+
+const attachmentColumns = `id, task_id, name, content_type, size, sha256, blob_key, user_name, created_at`
+
+// SaveAttachment - 'Attachment' of existing 'Task', unknown 'Task' - ErrSourceNotFound
+func (d *Dbinstance) SaveAttachment(ctx context.Context, data any) (model.Attachment, error) {
+	attachment := data.(model.Attachment)
+	row := d.db.QueryRowContext(ctx, `
+INSERT INTO attachments(task_id, name, content_type, size, sha256, blob_key, user_name, created_at)
+SELECT id, $2, $3, $4, $5, $6, $7, $8
+FROM tasks
+WHERE id = $1
+RETURNING `+attachmentColumns+`;`,
+		attachment.TaskID,
+		attachment.Name,
+		attachment.ContentType,
+		attachment.Size,
+		attachment.SHA256,
+		attachment.Key,
+		attachment.User,
+		attachment.CreatedAt.UTC(),
+	)
+	return scanAttachment[*sql.Row](row)
+}
+
+func (d *Dbinstance) FindAttachment(ctx context.Context, data any) (model.Attachment, error) {
+	ref := data.(model.AttachmentRef)
+	row := d.db.QueryRowContext(ctx, `
+SELECT `+attachmentColumns+`
+FROM attachments
+WHERE task_id = $1 AND id = $2;`, ref.TaskID, ref.ID)
+	return scanAttachment[*sql.Row](row)
+}
+
+// TaskAttachments - attachments of 'Task' in order of id, unknown 'Task' - ErrSourceNotFound
+func (d *Dbinstance) TaskAttachments(ctx context.Context, data any) ([]model.Attachment, error) {
+	taskID := data.(uint)
+	rows, err := d.db.QueryContext(ctx, `
+SELECT `+attachmentColumns+`
+FROM attachments
+WHERE task_id = $1
+ORDER BY id;`, taskID)
+	if err != nil {
+		return nil, classifyError(err)
+	}
+	defer func() {
+		if err := rows.Close(); err != nil {
+			log.Printf("query: rows.Close error - %v", err)
+		}
+	}()
+	var attachments []model.Attachment
+	for rows.Next() {
+		attachment, err := scanAttachment[*sql.Rows](rows)
+		if err != nil {
+			return nil, err
+		}
+		attachments = append(attachments, attachment)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, classifyError(err)
+	}
+	if len(attachments) == 0 {
+		return nil, d.taskExists(ctx, taskID)
+	}
+	return attachments, nil
+}
+
+// taskExists - nil if there is 'Task' with 'taskID', otherwise ErrSourceNotFound
+func (d *Dbinstance) taskExists(ctx context.Context, taskID uint) error {
+	id := 0
+	err := d.db.QueryRowContext(ctx, `SELECT id FROM tasks WHERE id = $1;`, taskID).Scan(&id)
+	return classifyError(err)
+}
+
+// DeleteAttachment - DELETE of 'Attachment' and job of removal of its blob in one transaction
+func (d *Dbinstance) DeleteAttachment(ctx context.Context, data any) error {
+	ref := data.(model.AttachmentRef)
+	tx, err := d.db.BeginTx(ctx, nil)
+	if err != nil {
+		return classifyError(err)
+	}
+	defer func() {
+		if err := tx.Rollback(); err != nil && !errors.Is(err, sql.ErrTxDone) {
+			log.Printf("query: delete attachment tx.Rollback error - %v", err)
+		}
+	}()
+	key := ""
+	err = tx.QueryRowContext(ctx, `
+DELETE
+FROM attachments
+WHERE task_id = $1 AND id = $2
+RETURNING blob_key;`, ref.TaskID, ref.ID).Scan(&key)
+	if err != nil {
+		return classifyError(err)
+	}
+	_, err = tx.ExecContext(ctx, `
+INSERT INTO jobs(kind, payload, run_at, max_attempts)
+VALUES($1, jsonb_build_object('keys', jsonb_build_array($2::TEXT)), (now() AT TIME ZONE 'utc'), $3);`,
+		model.KindBlobCleanup, key, blobCleanupAttempts)
+	if err != nil {
+		return classifyError(err)
+	}
+	return classifyError(tx.Commit())
+}
+
+// enqueueTaskBlobCleanup - job of removal of blobs of all attachments of 'Task' inside 'tx',
+// rows of attachments are removed by ON DELETE CASCADE with 'Task'
+func enqueueTaskBlobCleanup(ctx context.Context, tx *sql.Tx, taskID uint) error {
+	_, err := tx.ExecContext(ctx, `
+INSERT INTO jobs(kind, payload, run_at, max_attempts)
+SELECT $2, jsonb_build_object('task_id', $1::INTEGER, 'keys', jsonb_agg(blob_key ORDER BY id)),
+       (now() AT TIME ZONE 'utc'), $3
+FROM attachments
+WHERE task_id = $1
+HAVING count(*) > 0;`, taskID, model.KindBlobCleanup, blobCleanupAttempts)
+	return classifyError(err)
+}
+
+func scanAttachment[S RowScaner](r S) (model.Attachment, error) {
+	attachment := model.Attachment{}
+	if err := r.Scan(
+		&attachment.ID,
+		&attachment.TaskID,
+		&attachment.Name,
+		&attachment.ContentType,
+		&attachment.Size,
+		&attachment.SHA256,
+		&attachment.Key,
+		&attachment.User,
+		&attachment.CreatedAt,
+	); err != nil {
+		return attachment, classifyError(err)
+	}
+	return attachment, nil
+}
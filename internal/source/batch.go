@@ -0,0 +1,192 @@
+// source - many changes of 'Task' in one transaction
+package source
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+
+	"github.com/Ekvo/golang-chi-postgres-api/internal/model"
+)
+
+// insertChunk - rows in one multi-row INSERT, 3 params per row (Postgres limit 65535 params)
+const insertChunk = 1000
+
+// ExecuteBatch - apply 'model.Batch' in one transaction in order of operations
+//
+// consecutive 'BatchCreate' are saved by one multi-row INSERT, 'BatchUpdate' and 'BatchDelete' - one by one
+// Atomic - first error rollback all, error with index of failed operation is returned with results
+// best effort - each operation in SAVEPOINT, failed operation does not affect others
+func (d *Dbinstance) ExecuteBatch(ctx context.Context, data any) ([]model.BatchResult, error) {
+	batch := data.(model.Batch)
+	results := make([]model.BatchResult, len(batch.Operations))
+	tx, err := d.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, classifyError(err)
+	}
+	defer func() {
+		if err := tx.Rollback(); err != nil && !errors.Is(err, sql.ErrTxDone) {
+			log.Printf("query: batch tx.Rollback error - %v", err)
+		}
+	}()
+
+	for i := 0; i < len(batch.Operations); {
+		op := batch.Operations[i]
+		if op.Op == model.BatchCreate {
+			end := i + 1
+			for end < len(batch.Operations) && end-i < insertChunk && batch.Operations[end].Op == model.BatchCreate {
+				end++
+			}
+			if err := batchCreate(ctx, tx, batch, i, end, results); err != nil {
+				return results, err
+			}
+			i = end
+			continue
+		}
+		var exec func() error
+		switch op.Op {
+		case model.BatchUpdate:
+			exec = func() error {
+				_, err := patchTaskRow(ctx, tx, model.TextPatch(op.Task))
+				return err
+			}
+		case model.BatchDelete:
+			exec = func() error { return deleteTaskRow(ctx, tx, op.Task.ID) }
+		default:
+			exec = func() error { return ErrSourceIncorrectData }
+		}
+		results[i].ID = op.Task.ID
+		if batch.Atomic {
+			err = exec()
+		} else {
+			err = savepoint(ctx, tx, exec)
+		}
+		if err != nil {
+			results[i].Err = err
+			if batch.Atomic {
+				return results, fmt.Errorf("operation %d: %w", i, err)
+			}
+		}
+		i++
+	}
+	return results, classifyError(tx.Commit())
+}
+
+// batchCreate - multi-row INSERT of 'BatchCreate' operations from 'start' till 'end'
+//
+// failed INSERT is repeated row by row to find failed operation: atomic - error of the first one,
+// best effort - error of each one
+func batchCreate(ctx context.Context, tx *sql.Tx, batch model.Batch, start, end int, results []model.BatchResult) error {
+	tasks := make([]model.Task, 0, end-start)
+	for _, op := range batch.Operations[start:end] {
+		tasks = append(tasks, op.Task)
+	}
+	var ids []uint
+	insert := func() error {
+		var err error
+		ids, err = insertTaskRows(ctx, tx, tasks)
+		return err
+	}
+	if err := savepoint(ctx, tx, insert); err == nil {
+		for i, id := range ids {
+			results[start+i].ID = id
+		}
+		return nil
+	}
+	for i := range tasks {
+		oneRow := func() error {
+			one, err := insertTaskRows(ctx, tx, tasks[i:i+1])
+			if err == nil {
+				results[start+i].ID = one[0]
+			}
+			return err
+		}
+		if err := savepoint(ctx, tx, oneRow); err != nil {
+			results[start+i].Err = err
+			if batch.Atomic {
+				return fmt.Errorf("operation %d: %w", start+i, err)
+			}
+		}
+	}
+	return nil
+}
+
+// insertTaskRows - one INSERT with many VALUES and 'TaskCreated' of each, ids are returned in order of 'tasks'
+func insertTaskRows(ctx context.Context, tx *sql.Tx, tasks []model.Task) ([]uint, error) {
+	query := strings.Builder{}
+	args := make([]any, 0, 4*len(tasks))
+	query.WriteString(`
+WITH input(description, note, created_at, custom_fields, ord) AS (
+VALUES `)
+	for i, task := range tasks {
+		if i > 0 {
+			query.WriteByte(',')
+		}
+		customFields, err := customFieldsJSON(task.CustomFields)
+		if err != nil {
+			return nil, err
+		}
+		n := len(args)
+		fmt.Fprintf(&query, "($%d::VARCHAR,$%d::VARCHAR,$%d::TIMESTAMP,$%d::JSONB,%d)", n+1, n+2, n+3, n+4, i)
+		args = append(args, task.Description, emptyStringWriteNULL(task.Note), task.CreatedAt, customFields)
+	}
+	query.WriteString(`
+), inserted AS (
+INSERT INTO tasks(description,note,created_at,custom_fields)
+SELECT description, note, created_at, custom_fields
+FROM input
+ORDER BY ord
+RETURNING id
+)
+SELECT id
+FROM inserted
+ORDER BY id;`)
+
+	rows, err := tx.QueryContext(ctx, query.String(), args...)
+	if err != nil {
+		return nil, classifyError(err)
+	}
+	defer func() {
+		if err := rows.Close(); err != nil {
+			log.Printf("query: rows.Close error - %v", err)
+		}
+	}()
+	ids := make([]uint, 0, len(tasks))
+	for rows.Next() {
+		var id uint
+		if err := rows.Scan(&id); err != nil {
+			return nil, classifyError(err)
+		}
+		ids = append(ids, id)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, classifyError(err)
+	}
+	if len(ids) != len(tasks) {
+		return nil, ErrSourceIncorrectData
+	}
+	created := make([]model.Task, len(tasks))
+	for i, task := range tasks {
+		task.ID = ids[i]
+		created[i] = task
+	}
+	return ids, writeOutbox(ctx, tx, model.TaskCreated, created...)
+}
+
+// savepoint - call 'fn' inside SAVEPOINT, error of 'fn' rollback only changes of 'fn'
+func savepoint(ctx context.Context, tx *sql.Tx, fn func() error) error {
+	if _, err := tx.ExecContext(ctx, `SAVEPOINT batch_item;`); err != nil {
+		return classifyError(err)
+	}
+	if err := fn(); err != nil {
+		if _, rbErr := tx.ExecContext(ctx, `ROLLBACK TO SAVEPOINT batch_item;`); rbErr != nil {
+			return classifyError(rbErr)
+		}
+		return err
+	}
+	_, err := tx.ExecContext(ctx, `RELEASE SAVEPOINT batch_item;`)
+	return classifyError(err)
+}
@@ -0,0 +1,255 @@
+// source - board of 'Task': columns 'tasks.status' and rank keys 'tasks.position'
+package source
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+
+	"github.com/lib/pq"
+
+	"github.com/Ekvo/golang-chi-postgres-api/internal/model"
+	"github.com/Ekvo/golang-chi-postgres-api/pkg/common"
+)
+
+// positionLen - length of column 'tasks.position', longer key - column is rebalanced in transaction of move
+const positionLen = 128
+
+// boardRow - neighbour of moved 'Task', id 0 - edge of column
+type boardRow struct {
+	id       uint
+	position string
+}
+
+// MoveTask - 'Task' to column between neighbours, only its row is changed
+//
+// neighbours with equal keys (or too long new key) - keys of column are replaced by 'model.TaskMove.Keys' first
+func (d *Dbinstance) MoveTask(ctx context.Context, data any) (model.Task, error) {
+	move := data.(model.TaskMove)
+	tx, err := d.db.BeginTx(ctx, nil)
+	if err != nil {
+		return model.Task{}, classifyError(err)
+	}
+	defer func() {
+		if err := tx.Rollback(); err != nil && !errors.Is(err, sql.ErrTxDone) {
+			log.Printf("query: move task tx.Rollback error - %v", err)
+		}
+	}()
+	status := ""
+	err = tx.QueryRowContext(ctx, `
+SELECT status
+FROM tasks
+WHERE id = $1
+FOR UPDATE;`, move.ID).Scan(&status)
+	if err != nil {
+		return model.Task{}, classifyError(err)
+	}
+	if move.Status != "" {
+		status = move.Status
+	}
+	key := ""
+	for rebalanced := false; ; rebalanced = true {
+		after, before, err := neighbours(ctx, tx, move, status)
+		if err != nil {
+			return model.Task{}, err
+		}
+		room := before.id == 0 || after.position < before.position
+		if room {
+			if key, err = move.Rank(after.position, before.position); err != nil {
+				return model.Task{}, fmt.Errorf("%w - %v", ErrSourceIncorrectData, err)
+			}
+		}
+		if room && len(key) <= positionLen {
+			break
+		}
+		if rebalanced {
+			return model.Task{}, fmt.Errorf("%w - no room for key in column %q", ErrSourceConflict, status)
+		}
+		if err := rebalanceColumn(ctx, tx, status, move.ID, move.Keys); err != nil {
+			return model.Task{}, err
+		}
+	}
+	row := tx.QueryRowContext(ctx, `
+UPDATE tasks
+SET status = $2,
+    position = $3,
+    updated_at = $4
+WHERE id = $1
+RETURNING *;`, move.ID, status, key, move.UpdatedAt)
+	task, err := scanOneTask[*sql.Row](row)
+	if err != nil {
+		return model.Task{}, err
+	}
+	if err := writeOutbox(ctx, tx, model.TaskUpdated, task); err != nil {
+		return model.Task{}, err
+	}
+	return task, classifyError(tx.Commit())
+}
+
+// neighbours - rows of column between which moved 'Task' is placed, anchors are checked and locked
+func neighbours(ctx context.Context, tx *sql.Tx, move model.TaskMove, status string) (boardRow, boardRow, error) {
+	var after, before boardRow
+	var err error
+	switch {
+	case move.After != 0 && move.Before != 0:
+		if after, err = anchorRow(ctx, tx, "after", move.After, status); err != nil {
+			return after, before, err
+		}
+		if before, err = anchorRow(ctx, tx, "before", move.Before, status); err != nil {
+			return after, before, err
+		}
+		if after.position > before.position || (after.position == before.position && after.id > before.id) {
+			return after, before, &ValidationError{Params: []common.InvalidParam{{
+				Name:   "before",
+				Reason: "must be after task of 'after'",
+			}}}
+		}
+	case move.After != 0:
+		if after, err = anchorRow(ctx, tx, "after", move.After, status); err != nil {
+			return after, before, err
+		}
+		before, err = nextRow(ctx, tx, `(position, id) > ($3, $4)
+ORDER BY position, id`, status, move.ID, after.position, after.id)
+	case move.Before != 0:
+		if before, err = anchorRow(ctx, tx, "before", move.Before, status); err != nil {
+			return after, before, err
+		}
+		after, err = nextRow(ctx, tx, `(position, id) < ($3, $4)
+ORDER BY position DESC, id DESC`, status, move.ID, before.position, before.id)
+	default:
+		// end of column
+		after, err = nextRow(ctx, tx, `TRUE
+ORDER BY position DESC, id DESC`, status, move.ID)
+	}
+	return after, before, err
+}
+
+// anchorRow - 'Task' of 'id' in column 'status', ValidationError of param 'name' if it is unknown or in other column
+func anchorRow(ctx context.Context, tx *sql.Tx, name string, id uint, status string) (boardRow, error) {
+	row, rowStatus := boardRow{id: id}, ""
+	err := tx.QueryRowContext(ctx, `
+SELECT status, position
+FROM tasks
+WHERE id = $1
+FOR UPDATE;`, id).Scan(&rowStatus, &row.position)
+	if errors.Is(err, sql.ErrNoRows) {
+		return row, &ValidationError{Params: []common.InvalidParam{{Name: name, Reason: "unknown task"}}}
+	}
+	if err != nil {
+		return row, classifyError(err)
+	}
+	if rowStatus != status {
+		return row, &ValidationError{Params: []common.InvalidParam{{
+			Name:   name,
+			Reason: fmt.Sprintf("task is not in column %q", status),
+		}}}
+	}
+	return row, nil
+}
+
+// nextRow - first row of column by 'condition' (with ORDER BY) without moved 'Task', none - edge of column
+//
+// args: $1 - column, $2 - id of moved 'Task', then args of 'condition'
+func nextRow(ctx context.Context, tx *sql.Tx, condition string, args ...any) (boardRow, error) {
+	row := boardRow{}
+	err := tx.QueryRowContext(ctx, `
+SELECT id, position
+FROM tasks
+WHERE status = $1 AND id <> $2 AND `+condition+`
+LIMIT 1
+FOR UPDATE;`, args...).Scan(&row.id, &row.position)
+	if errors.Is(err, sql.ErrNoRows) {
+		return boardRow{}, nil
+	}
+	return row, classifyError(err)
+}
+
+// rebalanceColumn - rows of column (without 'exclude') get 'keys' in their order, rows are locked
+func rebalanceColumn(ctx context.Context, tx *sql.Tx, status string, exclude uint, keys func(n int) []string) error {
+	rows, err := tx.QueryContext(ctx, `
+SELECT id
+FROM tasks
+WHERE status = $1 AND id <> $2
+ORDER BY position, id
+FOR UPDATE;`, status, exclude)
+	if err != nil {
+		return classifyError(err)
+	}
+	ids := pq.Int64Array{}
+	for rows.Next() {
+		id := int64(0)
+		if err := rows.Scan(&id); err != nil {
+			rows.Close()
+			return classifyError(err)
+		}
+		ids = append(ids, id)
+	}
+	if err := rows.Close(); err != nil {
+		return classifyError(err)
+	}
+	if err := rows.Err(); err != nil {
+		return classifyError(err)
+	}
+	positions := pq.StringArray(keys(len(ids)))
+	_, err = tx.ExecContext(ctx, `
+UPDATE tasks t
+SET position = k.position
+FROM unnest($1::INTEGER[], $2::TEXT[]) AS k(id, position)
+WHERE t.id = k.id;`, ids, positions)
+	return classifyError(err)
+}
+
+// RebalancePositions - each column with long, empty or equal keys is rebalanced in own transaction
+func (d *Dbinstance) RebalancePositions(ctx context.Context, data any) (int, error) {
+	rebalance := data.(model.RankRebalance)
+	rows, err := d.db.QueryContext(ctx, `
+SELECT status
+FROM tasks
+GROUP BY status
+HAVING MAX(length(position)) > $1
+    OR bool_or(position = '')
+    OR COUNT(*) > COUNT(DISTINCT position)
+ORDER BY status;`, rebalance.MaxLen)
+	if err != nil {
+		return 0, classifyError(err)
+	}
+	var columns []string
+	for rows.Next() {
+		status := ""
+		if err := rows.Scan(&status); err != nil {
+			rows.Close()
+			return 0, classifyError(err)
+		}
+		columns = append(columns, status)
+	}
+	if err := rows.Close(); err != nil {
+		return 0, classifyError(err)
+	}
+	if err := rows.Err(); err != nil {
+		return 0, classifyError(err)
+	}
+	for i, status := range columns {
+		if err := d.rebalance(ctx, status, rebalance.Keys); err != nil {
+			return i, err
+		}
+	}
+	return len(columns), nil
+}
+
+func (d *Dbinstance) rebalance(ctx context.Context, status string, keys func(n int) []string) error {
+	tx, err := d.db.BeginTx(ctx, nil)
+	if err != nil {
+		return classifyError(err)
+	}
+	defer func() {
+		if err := tx.Rollback(); err != nil && !errors.Is(err, sql.ErrTxDone) {
+			log.Printf("query: rebalance tx.Rollback error - %v", err)
+		}
+	}()
+	if err := rebalanceColumn(ctx, tx, status, 0, keys); err != nil {
+		return err
+	}
+	return classifyError(tx.Commit())
+}
@@ -0,0 +1,119 @@
+// source - registry of custom fields of 'Task', values are in column 'tasks.custom_fields'
+package source
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"log"
+
+	"github.com/Ekvo/golang-chi-postgres-api/internal/model"
+)
+
+// createCustomFieldTable - table 'custom_fields', allowed values are JSON array
+func (d *Dbinstance) createCustomFieldTable(ctx context.Context) error {
+	_, err := d.db.ExecContext(ctx, `
+CREATE TABLE IF NOT EXISTS custom_fields
+(
+    name VARCHAR(64) PRIMARY KEY,
+    type VARCHAR(16) NOT NULL,
+    required BOOLEAN NOT NULL DEFAULT FALSE,
+    allowed JSONB NOT NULL DEFAULT '[]',
+    created_at TIMESTAMP NOT NULL,
+    updated_at TIMESTAMP NULL
+);`)
+	return err
+}
+
+const customFieldColumns = `name, type, required, allowed, created_at, updated_at`
+
+// SaveCustomField - INSERT of 'CustomField' or UPDATE of field with the same name, 'CreatedAt' of it is kept
+func (d *Dbinstance) SaveCustomField(ctx context.Context, data any) (model.CustomField, error) {
+	field := data.(model.CustomField)
+	allowed := []any{}
+	if len(field.Allowed) > 0 {
+		allowed = field.Allowed
+	}
+	line, err := json.Marshal(allowed)
+	if err != nil {
+		return model.CustomField{}, fmt.Errorf("%w - %v", ErrSourceIncorrectData, err)
+	}
+	row := d.db.QueryRowContext(ctx, `
+INSERT INTO custom_fields(name, type, required, allowed, created_at)
+VALUES($1, $2, $3, $4, $5)
+ON CONFLICT (name) DO UPDATE
+SET type = EXCLUDED.type,
+    required = EXCLUDED.required,
+    allowed = EXCLUDED.allowed,
+    updated_at = EXCLUDED.created_at
+RETURNING `+customFieldColumns+`;`,
+		field.Name,
+		field.Type,
+		field.Required,
+		string(line),
+		field.CreatedAt.UTC(),
+	)
+	return scanCustomField[*sql.Row](row)
+}
+
+func (d *Dbinstance) CustomFields(ctx context.Context) ([]model.CustomField, error) {
+	rows, err := d.db.QueryContext(ctx, `
+SELECT `+customFieldColumns+`
+FROM custom_fields
+ORDER BY name;`)
+	if err != nil {
+		return nil, classifyError(err)
+	}
+	defer func() {
+		if err := rows.Close(); err != nil {
+			log.Printf("query: rows.Close error - %v", err)
+		}
+	}()
+	var fields []model.CustomField
+	for rows.Next() {
+		field, err := scanCustomField[*sql.Rows](rows)
+		if err != nil {
+			return nil, err
+		}
+		fields = append(fields, field)
+	}
+	return fields, classifyError(rows.Err())
+}
+
+// DeleteCustomField - field by name (string), values of 'Task' are not changed
+func (d *Dbinstance) DeleteCustomField(ctx context.Context, data any) error {
+	name := data.(string)
+	deleted := ""
+	err := d.db.QueryRowContext(ctx, `
+DELETE
+FROM custom_fields
+WHERE name = $1
+RETURNING name;`, name).Scan(&deleted)
+	return classifyError(err)
+}
+
+func scanCustomField[S RowScaner](r S) (model.CustomField, error) {
+	field := model.CustomField{}
+	allowed, updatedAt := []byte{}, sql.NullTime{}
+	if err := r.Scan(
+		&field.Name,
+		&field.Type,
+		&field.Required,
+		&allowed,
+		&field.CreatedAt,
+		&updatedAt,
+	); err != nil {
+		return field, classifyError(err)
+	}
+	if err := json.Unmarshal(allowed, &field.Allowed); err != nil {
+		return field, fmt.Errorf("%w - %v", ErrSourceIncorrectData, err)
+	}
+	if len(field.Allowed) == 0 {
+		field.Allowed = nil
+	}
+	if updatedAt.Valid {
+		field.UpdatedAt = &updatedAt.Time
+	}
+	return field, nil
+}
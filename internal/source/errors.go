@@ -0,0 +1,111 @@
+// source - error taxonomy of the store
+package source
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"net"
+	"strings"
+
+	"github.com/lib/pq"
+
+	"github.com/Ekvo/golang-chi-postgres-api/pkg/common"
+)
+
+var (
+	// ErrSourceConflict - data conflicts with the current state of the store
+	ErrSourceConflict = errors.New("conflict")
+
+	// ErrSourceValidation - data rejected by the store constraints
+	ErrSourceValidation = errors.New("invalid data in store")
+
+	// ErrSourceUnavailable - store cannot serve the query now (connection, timeout, overload)
+	ErrSourceUnavailable = errors.New("store unavailable")
+)
+
+// ValidationError - 'ErrSourceValidation' with a description of the rejected fields
+type ValidationError struct {
+	Params []common.InvalidParam
+}
+
+func (e *ValidationError) Error() string {
+	reasons := make([]string, 0, len(e.Params))
+	for _, p := range e.Params {
+		reasons = append(reasons, p.Name+": "+p.Reason)
+	}
+	return fmt.Sprintf("%s - %s", ErrSourceValidation, strings.Join(reasons, ", "))
+}
+
+func (e *ValidationError) Unwrap() error {
+	return ErrSourceValidation
+}
+
+// InvalidParams - field-level reasons for 'problem+json' (look ~> ../../pkg/common/problem.go)
+func (e *ValidationError) InvalidParams() []common.InvalidParam {
+	return e.Params
+}
+
+// classifyError - reduce an error of 'database/sql' or 'lib/pq' to the taxonomy above
+//
+// unknown errors are returned as is
+func classifyError(err error) error {
+	if err == nil {
+		return nil
+	}
+	if errors.Is(err, sql.ErrNoRows) {
+		return ErrSourceNotFound
+	}
+	if errors.Is(err, context.DeadlineExceeded) ||
+		errors.Is(err, context.Canceled) ||
+		errors.Is(err, driver.ErrBadConn) ||
+		errors.Is(err, sql.ErrConnDone) {
+		return fmt.Errorf("%w - %v", ErrSourceUnavailable, err)
+	}
+	var netErr net.Error
+	if errors.As(err, &netErr) {
+		return fmt.Errorf("%w - %v", ErrSourceUnavailable, err)
+	}
+	var pqErr *pq.Error
+	if !errors.As(err, &pqErr) {
+		return err
+	}
+	switch pqErr.Code.Class() {
+	case "23": // integrity constraint violation
+		if pqErr.Code == "23505" || pqErr.Code == "23P01" {
+			return fmt.Errorf("%w - %s", ErrSourceConflict, pqErr.Message)
+		}
+		return &ValidationError{Params: []common.InvalidParam{{
+			Name:   fieldOf(pqErr),
+			Reason: pqErr.Message,
+		}}}
+	case "22": // data exception
+		return &ValidationError{Params: []common.InvalidParam{{
+			Name:   fieldOf(pqErr),
+			Reason: pqErr.Message,
+		}}}
+	case "40": // transaction rollback (serialization failure, deadlock)
+		return fmt.Errorf("%w - %s", ErrSourceConflict, pqErr.Message)
+	case "08", "53", "57": // connection exception, insufficient resources, operator intervention
+		return fmt.Errorf("%w - %s", ErrSourceUnavailable, pqErr.Message)
+	}
+	return err
+}
+
+// constraintFields - name of field by constraint whose error has no column (foreign keys)
+var constraintFields = map[string]string{
+	"tasks_project_id_fkey": "project_id",
+}
+
+// fieldOf - name of column from 'pq.Error', "task" if Postgres did not report it
+func fieldOf(pqErr *pq.Error) string {
+	if pqErr.Column != "" {
+		return pqErr.Column
+	}
+	if name, ok := constraintFields[pqErr.Constraint]; ok {
+		return name
+	}
+	return "task"
+}
@@ -0,0 +1,331 @@
+// source - change stream of 'Task' by trigger of table 'tasks' and LISTEN/NOTIFY
+package source
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/lib/pq"
+
+	"github.com/Ekvo/golang-chi-postgres-api/internal/config"
+	"github.com/Ekvo/golang-chi-postgres-api/internal/model"
+)
+
+// parameters of table 'task_events'
+const (
+	// MaxEventReplay - max events of one resume, more - ErrSourceEventsGone
+	MaxEventReplay = 1000
+
+	eventsChannel = "task_events"
+	// eventsPing - check of connection of 'pq.Listener' when there are no notifications
+	eventsPing = 90 * time.Second
+	// eventsPoll - next read of events which wait for the end of older transactions
+	eventsPoll = time.Second
+	// eventsPage - max events of one read of 'Listener'
+	eventsPage = 500
+)
+
+// ErrSourceEventsGone - events after the given cursor are removed by retention, client must read tasks again
+var ErrSourceEventsGone = errors.New("events are gone")
+
+// createEventTables - table 'task_events' and trigger of 'tasks'
+//
+// each change of row of 'tasks' (INSERT, UPDATE, DELETE, COPY) is saved in 'task_events' with its transaction
+// (UPDATE of only 'position' is skipped)
+// and in 'webhook_deliveries' for each matching webhook, NOTIFY after commit wakes 'Listener' up;
+// 'task_events_purged' - cursor of the last removed event
+func (d *Dbinstance) createEventTables(ctx context.Context) error {
+	_, err := d.db.ExecContext(ctx, `
+CREATE TABLE IF NOT EXISTS task_events
+(
+    id BIGSERIAL PRIMARY KEY,
+    type VARCHAR(16) NOT NULL,
+    task_id BIGINT NOT NULL,
+    description VARCHAR(2048) NULL,
+    note VARCHAR(2048) NULL,
+    task_created_at TIMESTAMP NULL,
+    task_updated_at TIMESTAMP NULL,
+    occurred_at TIMESTAMP NOT NULL DEFAULT (now() AT TIME ZONE 'utc')
+);
+
+ALTER TABLE task_events
+ADD COLUMN IF NOT EXISTS tx_id XID8 NOT NULL DEFAULT pg_current_xact_id();
+
+CREATE INDEX IF NOT EXISTS task_events_occurred_at ON task_events(occurred_at);
+CREATE INDEX IF NOT EXISTS task_events_cursor ON task_events(tx_id, id);
+
+CREATE TABLE IF NOT EXISTS task_events_purged
+(
+    singleton BOOLEAN PRIMARY KEY DEFAULT TRUE CHECK (singleton),
+    tx_id XID8 NOT NULL,
+    id BIGINT NOT NULL
+);
+
+CREATE OR REPLACE FUNCTION task_events_notify() RETURNS trigger AS $$
+DECLARE
+    new_event_id BIGINT;
+BEGIN
+    IF TG_OP = 'DELETE' THEN
+        INSERT INTO task_events(type, task_id)
+        VALUES ('deleted', OLD.id)
+        RETURNING id INTO new_event_id;
+    ELSE
+        INSERT INTO task_events(type, task_id, description, note, task_created_at, task_updated_at)
+        VALUES (CASE TG_OP WHEN 'INSERT' THEN 'created' ELSE 'updated' END,
+                NEW.id, NEW.description, NEW.note, NEW.created_at, NEW.updated_at)
+        RETURNING id INTO new_event_id;
+    END IF;
+    -- outbox of webhooks, in the transaction of the change
+    INSERT INTO webhook_deliveries(webhook_id, event_id, type, task_id, description, note,
+                                   task_created_at, task_updated_at, occurred_at)
+    SELECT w.id, e.id, e.type, e.task_id, e.description, e.note, e.task_created_at, e.task_updated_at, e.occurred_at
+    FROM task_events e, webhooks w
+    WHERE e.id = new_event_id AND (cardinality(w.events) = 0 OR e.type = ANY(w.events));
+    PERFORM pg_notify('task_events', new_event_id::TEXT);
+    RETURN NULL;
+END;
+$$ LANGUAGE plpgsql;
+
+-- replace in place, without window when changes of other replicas are not captured (PostgreSQL 14+)
+CREATE OR REPLACE TRIGGER tasks_events
+AFTER INSERT OR DELETE ON tasks
+FOR EACH ROW EXECUTE FUNCTION task_events_notify();
+
+-- change of only 'position' (rebalance of column of board) is not an event
+CREATE OR REPLACE TRIGGER tasks_events_update
+AFTER UPDATE ON tasks
+FOR EACH ROW
+WHEN ((to_jsonb(OLD) - 'position') IS DISTINCT FROM (to_jsonb(NEW) - 'position'))
+EXECUTE FUNCTION task_events_notify();`)
+	return err
+}
+
+// eventsAfter - events after cursor in order of commit, only of transactions older than the oldest running one:
+// event of running (or just committed) transaction waits, so it can not appear behind cursor of reader
+const eventsAfter = `
+SELECT id, tx_id, type, task_id, description, note, task_created_at, task_updated_at, occurred_at
+FROM task_events
+WHERE (tx_id, id) > ($1::XID8, $2) AND tx_id < pg_snapshot_xmin(pg_current_snapshot())
+ORDER BY tx_id, id
+LIMIT $3;`
+
+// TaskEventsSince - events after cursor ('data' is 'model.EventCursor') in order of commit
+//
+// ErrSourceEventsGone - some of them are removed by retention or there are more than 'MaxEventReplay'
+func (d *Dbinstance) TaskEventsSince(ctx context.Context, data any) ([]model.TaskEvent, error) {
+	after := data.(model.EventCursor)
+	purged := model.EventCursor{}
+	err := d.db.QueryRowContext(ctx, `
+SELECT tx_id, id
+FROM task_events_purged;`).Scan(&purged.Tx, &purged.ID)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return nil, classifyError(err)
+	}
+	if after.Less(purged) {
+		return nil, ErrSourceEventsGone
+	}
+	evs, err := d.taskEvents(ctx, eventsAfter, after.Tx, after.ID, MaxEventReplay+1)
+	if err != nil {
+		return nil, err
+	}
+	if len(evs) > MaxEventReplay {
+		return nil, ErrSourceEventsGone
+	}
+	return evs, nil
+}
+
+// PurgeTaskEvents - events older than 'data' (time.Duration) which are already seen in stream,
+// cursor of the last of them is kept for 'TaskEventsSince', returns number of removed
+func (d *Dbinstance) PurgeTaskEvents(ctx context.Context, data any) (int64, error) {
+	age := data.(time.Duration)
+	purged := int64(0)
+	err := d.db.QueryRowContext(ctx, `
+WITH purged AS (
+    DELETE FROM task_events
+    WHERE occurred_at < (now() AT TIME ZONE 'utc') - make_interval(secs => $1)
+      AND tx_id < pg_snapshot_xmin(pg_current_snapshot())
+    RETURNING tx_id, id
+), horizon AS (
+    INSERT INTO task_events_purged AS p (tx_id, id)
+    SELECT tx_id, id
+    FROM (SELECT tx_id, id FROM purged ORDER BY tx_id DESC, id DESC LIMIT 1) last
+    ON CONFLICT (singleton) DO UPDATE
+    SET tx_id = EXCLUDED.tx_id, id = EXCLUDED.id
+    WHERE (p.tx_id, p.id) < (EXCLUDED.tx_id, EXCLUDED.id)
+)
+SELECT count(*)
+FROM purged;`, age.Seconds()).Scan(&purged)
+	return purged, classifyError(err)
+}
+
+func (d *Dbinstance) taskEvents(ctx context.Context, query string, args ...any) ([]model.TaskEvent, error) {
+	rows, err := d.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, classifyError(err)
+	}
+	defer func() {
+		if err := rows.Close(); err != nil {
+			log.Printf("query: rows.Close error - %v", err)
+		}
+	}()
+	var evs []model.TaskEvent
+	for rows.Next() {
+		ev := model.TaskEvent{}
+		description, note := sql.NullString{}, sql.NullString{}
+		createdAt, updatedAt := sql.NullTime{}, sql.NullTime{}
+		if err := rows.Scan(&ev.ID, &ev.Tx, &ev.Type, &ev.TaskID, &description, &note, &createdAt, &updatedAt,
+			&ev.At); err != nil {
+			return nil, classifyError(err)
+		}
+		setEventTask(&ev, description, note, createdAt, updatedAt)
+		evs = append(evs, ev)
+	}
+	return evs, classifyError(rows.Err())
+}
+
+// setEventTask - 'Task' of event from columns of snapshot, nil for 'EventDeleted'
+func setEventTask(ev *model.TaskEvent, description, note sql.NullString, createdAt, updatedAt sql.NullTime) {
+	ev.At = ev.At.UTC()
+	if ev.Type == model.EventDeleted {
+		return
+	}
+	ev.Task = &model.Task{
+		ID:          ev.TaskID,
+		Description: description.String,
+		Note:        note.String,
+		CreatedAt:   createdAt.Time,
+	}
+	if updatedAt.Valid {
+		ev.Task.UpdatedAt = &updatedAt.Time
+	}
+}
+
+// eventPublisher - receiver of events of 'Listener' (look ~> ../events/hub.go)
+type eventPublisher interface {
+	Publish(ev model.TaskEvent)
+}
+
+// Listener - LISTEN of channel 'task_events', events of all replicas are published to 'eventPublisher'
+// in order of commit (look: eventsAfter)
+//
+// life cycle of 'server.Service', old events are removed by job (look ~> ../events/purge.go)
+type Listener struct {
+	d        *Dbinstance
+	listener *pq.Listener
+	pub      eventPublisher
+	last     model.EventCursor
+	// pending - there are events which wait for the end of older transactions
+	pending bool
+	done    chan struct{}
+	stopped chan struct{}
+}
+
+func NewListener(cfg *config.Config, d *Dbinstance, pub eventPublisher) *Listener {
+	report := func(ev pq.ListenerEventType, err error) {
+		if err != nil {
+			log.Printf("source: listener event %d error - %v", ev, err)
+		}
+	}
+	return &Listener{
+		d:        d,
+		listener: pq.NewListener(dbURL(cfg), time.Second, time.Minute, report),
+		pub:      pub,
+		done:     make(chan struct{}),
+		stopped:  make(chan struct{}),
+	}
+}
+
+// Serve - publish events until 'Shutdown', events before start are only for 'TaskEventsSince'
+func (l *Listener) Serve() error {
+	defer close(l.stopped)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go func() {
+		select {
+		case <-l.done:
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
+
+	if err := l.listener.Listen(eventsChannel); err != nil {
+		return fmt.Errorf("listen %s - %w", eventsChannel, err)
+	}
+	// transactions older than the oldest running one are before start
+	if err := l.d.db.QueryRowContext(ctx, `SELECT pg_snapshot_xmin(pg_current_snapshot());`).
+		Scan(&l.last.Tx); err != nil {
+		return classifyError(err)
+	}
+	ping := time.NewTicker(eventsPing)
+	defer ping.Stop()
+	poll := time.NewTicker(eventsPoll)
+	defer poll.Stop()
+	for {
+		select {
+		case <-l.done:
+			return nil
+		case <-l.listener.Notify:
+			// payload is not used, nil - connection was lost, notifications could be lost too
+			l.drain()
+			l.catchUp(ctx)
+		case <-poll.C:
+			if l.pending {
+				l.catchUp(ctx)
+			}
+		case <-ping.C:
+			if err := l.listener.Ping(); err != nil {
+				log.Printf("source: listener ping error - %v", err)
+			}
+		}
+	}
+}
+
+// drain - notifications which are already waiting, one read of 'catchUp' is for all of them
+func (l *Listener) drain() {
+	for {
+		select {
+		case <-l.listener.Notify:
+		default:
+			return
+		}
+	}
+}
+
+// catchUp - events after the last published one by pages of 'eventsPage'
+func (l *Listener) catchUp(ctx context.Context) {
+	for {
+		evs, err := l.d.taskEvents(ctx, eventsAfter, l.last.Tx, l.last.ID, eventsPage)
+		if err != nil {
+			log.Printf("source: catch up of task_events error - %v", err)
+			l.pending = true
+			return
+		}
+		for _, ev := range evs {
+			l.pub.Publish(ev)
+			l.last = ev.Cursor()
+		}
+		if len(evs) < eventsPage {
+			break
+		}
+	}
+	if err := l.d.db.QueryRowContext(ctx, `
+SELECT EXISTS (SELECT 1 FROM task_events WHERE tx_id >= pg_snapshot_xmin(pg_current_snapshot()));`).
+		Scan(&l.pending); err != nil {
+		log.Printf("source: pending task_events error - %v", err)
+		l.pending = true
+	}
+}
+
+// Shutdown - stop 'Serve' and close connection of LISTEN
+func (l *Listener) Shutdown(ctx context.Context) error {
+	close(l.done)
+	select {
+	case <-l.stopped:
+	case <-ctx.Done():
+	}
+	return l.listener.Close()
+}
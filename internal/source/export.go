@@ -0,0 +1,109 @@
+// source - read of table 'tasks' row by row by server-side cursor
+package source
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"log"
+	"strconv"
+
+	"github.com/Ekvo/golang-chi-postgres-api/internal/model"
+)
+
+// exportFetch - rows of one FETCH from the cursor, memory of export does not depend on size of table
+const exportFetch = 500
+
+// ExportTasks - call 'model.TaskExport.Each' for every 'Task' of filter, returns number of rows
+//
+// rows are read by 'FETCH' from cursor inside read only transaction
+func (d *Dbinstance) ExportTasks(ctx context.Context, data any) (int, error) {
+	export := data.(model.TaskExport)
+	tx, err := d.db.BeginTx(ctx, &sql.TxOptions{ReadOnly: true})
+	if err != nil {
+		return 0, classifyError(err)
+	}
+	defer func() {
+		if err := tx.Rollback(); err != nil && !errors.Is(err, sql.ErrTxDone) {
+			log.Printf("query: export tx.Rollback error - %v", err)
+		}
+	}()
+	order := "id ASC"
+	if export.Filter.Order == "desc" {
+		order = "id DESC"
+	}
+	if export.Filter.Board {
+		order = "status, position, id"
+	}
+	var customFields *string
+	if len(export.Filter.CustomFields) > 0 {
+		line, err := customFieldsJSON(export.Filter.CustomFields)
+		if err != nil {
+			return 0, err
+		}
+		customFields = &line
+	}
+	_, err = tx.ExecContext(ctx, `
+DECLARE task_export NO SCROLL CURSOR FOR
+SELECT *
+FROM tasks
+WHERE ($1::TIMESTAMP IS NULL OR created_at >= $1)
+  AND ($2::TIMESTAMP IS NULL OR created_at < $2)
+  AND (NOT $3 OR due_at IS NOT NULL)
+  AND ($6::JSONB IS NULL OR custom_fields @> $6)
+  AND ($7 = 0 OR project_id = $7)
+  AND ($8 OR `+visibleTask+`)
+  AND ($9 = '' OR status = $9)
+ORDER BY `+order+`
+LIMIT NULLIF($4, 0) OFFSET $5;`,
+		export.Filter.CreatedFrom,
+		export.Filter.CreatedTo,
+		export.Filter.DueOnly,
+		export.Filter.Limit,
+		export.Filter.Offset,
+		customFields,
+		int64(export.Filter.ProjectID),
+		export.Filter.Archived,
+		export.Filter.Status,
+	)
+	if err != nil {
+		return 0, classifyError(err)
+	}
+	count := 0
+	for {
+		n, err := fetchTasks(ctx, tx, export.Each)
+		count += n
+		if err != nil {
+			return count, err
+		}
+		if n < exportFetch {
+			break
+		}
+	}
+	return count, classifyError(tx.Commit())
+}
+
+// fetchTasks - one 'FETCH' from cursor 'task_export', returns number of rows
+func fetchTasks(ctx context.Context, tx *sql.Tx, each func(task model.Task) error) (int, error) {
+	rows, err := tx.QueryContext(ctx, `FETCH FORWARD `+strconv.Itoa(exportFetch)+` FROM task_export;`)
+	if err != nil {
+		return 0, classifyError(err)
+	}
+	defer func() {
+		if err := rows.Close(); err != nil {
+			log.Printf("query: rows.Close error - %v", err)
+		}
+	}()
+	n := 0
+	for rows.Next() {
+		task, err := scanOneTask[*sql.Rows](rows)
+		if err != nil {
+			return n, err
+		}
+		if err := each(task); err != nil {
+			return n, err
+		}
+		n++
+	}
+	return n, classifyError(rows.Err())
+}
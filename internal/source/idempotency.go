@@ -0,0 +1,154 @@
+// source - keys of requests with 'Idempotency-Key' and their stored responses
+package source
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"github.com/Ekvo/golang-chi-postgres-api/internal/model"
+)
+
+// claimAttempts - claim and read of key are repeated if key is released between them
+const claimAttempts = 3
+
+// createIdempotencyTable - table 'idempotency_keys', key is unique for user,
+// 'status' NULL - request is in flight till 'locked_until'
+func (d *Dbinstance) createIdempotencyTable(ctx context.Context) error {
+	_, err := d.db.ExecContext(ctx, `
+CREATE TABLE IF NOT EXISTS idempotency_keys
+(
+    user_name VARCHAR(64) NOT NULL,
+    key VARCHAR(255) NOT NULL,
+    fingerprint VARCHAR(64) NOT NULL,
+    status INTEGER NULL,
+    header JSONB NULL,
+    body BYTEA NULL,
+    locked_until TIMESTAMP NOT NULL,
+    created_at TIMESTAMP NOT NULL DEFAULT (now() AT TIME ZONE 'utc'),
+    expires_at TIMESTAMP NOT NULL,
+    PRIMARY KEY (user_name, key)
+);
+
+CREATE INDEX IF NOT EXISTS idempotency_keys_expires_at ON idempotency_keys(expires_at);`)
+	return err
+}
+
+// ClaimIdempotencyKey - INSERT of key, expired key or abandoned request in flight is replaced,
+// otherwise stored record of key (look: model.IdempotencyStore)
+func (d *Dbinstance) ClaimIdempotencyKey(ctx context.Context, data any) (model.IdempotencyRecord, bool, error) {
+	claim := data.(model.IdempotencyClaim)
+	for i := 0; i < claimAttempts; i++ {
+		claimed := false
+		err := d.db.QueryRowContext(ctx, `
+INSERT INTO idempotency_keys(user_name, key, fingerprint, locked_until, expires_at)
+VALUES($1, $2, $3,
+       (now() AT TIME ZONE 'utc') + make_interval(secs => $4),
+       (now() AT TIME ZONE 'utc') + make_interval(secs => $5))
+ON CONFLICT (user_name, key) DO UPDATE
+SET fingerprint = EXCLUDED.fingerprint,
+    status = NULL,
+    header = NULL,
+    body = NULL,
+    locked_until = EXCLUDED.locked_until,
+    created_at = (now() AT TIME ZONE 'utc'),
+    expires_at = EXCLUDED.expires_at
+WHERE idempotency_keys.expires_at <= (now() AT TIME ZONE 'utc')
+   OR (idempotency_keys.status IS NULL AND idempotency_keys.locked_until <= (now() AT TIME ZONE 'utc'))
+RETURNING TRUE;`, claim.User, claim.Key, claim.Fingerprint, claim.Lease.Seconds(), claim.TTL.Seconds()).Scan(&claimed)
+		if err == nil {
+			return model.IdempotencyRecord{User: claim.User, Key: claim.Key, Fingerprint: claim.Fingerprint}, true, nil
+		}
+		if !errors.Is(err, sql.ErrNoRows) {
+			return model.IdempotencyRecord{}, false, classifyError(err)
+		}
+		record, err := d.findIdempotencyKey(ctx, claim.User, claim.Key)
+		if errors.Is(err, ErrSourceNotFound) {
+			// released by its request - claim again
+			continue
+		}
+		return record, false, err
+	}
+	return model.IdempotencyRecord{}, false, fmt.Errorf("%w - idempotency key %q is released repeatedly", ErrSourceConflict, claim.Key)
+}
+
+func (d *Dbinstance) findIdempotencyKey(ctx context.Context, user, key string) (model.IdempotencyRecord, error) {
+	record := model.IdempotencyRecord{User: user, Key: key}
+	var status sql.NullInt64
+	var header []byte
+	err := d.db.QueryRowContext(ctx, `
+SELECT fingerprint, status, header, body, created_at, expires_at
+FROM idempotency_keys
+WHERE user_name = $1 AND key = $2;`, user, key).Scan(
+		&record.Fingerprint,
+		&status,
+		&header,
+		&record.Body,
+		&record.CreatedAt,
+		&record.ExpiresAt,
+	)
+	if err != nil {
+		return model.IdempotencyRecord{}, classifyError(err)
+	}
+	record.Status = int(status.Int64)
+	if header != nil {
+		if err := json.Unmarshal(header, &record.Header); err != nil {
+			return model.IdempotencyRecord{}, fmt.Errorf("%w - %v", ErrSourceIncorrectData, err)
+		}
+	}
+	return record, nil
+}
+
+// SaveIdempotentResponse - UPDATE of claimed key by response, key which is overtaken by other request - ErrSourceConflict
+func (d *Dbinstance) SaveIdempotentResponse(ctx context.Context, data any) error {
+	record := data.(model.IdempotencyRecord)
+	header, err := json.Marshal(record.Header)
+	if err != nil {
+		return fmt.Errorf("%w - %v", ErrSourceIncorrectData, err)
+	}
+	body := record.Body
+	if body == nil {
+		// empty body of response is not NULL
+		body = []byte{}
+	}
+	result, err := d.db.ExecContext(ctx, `
+UPDATE idempotency_keys
+SET status = $4,
+    header = $5,
+    body = $6
+WHERE user_name = $1 AND key = $2 AND fingerprint = $3 AND status IS NULL;`,
+		record.User, record.Key, record.Fingerprint, record.Status, header, body)
+	if err != nil {
+		return classifyError(err)
+	}
+	if n, err := result.RowsAffected(); err != nil || n == 0 {
+		return fmt.Errorf("%w - idempotency key %q is not claimed", ErrSourceConflict, record.Key)
+	}
+	return nil
+}
+
+func (d *Dbinstance) ReleaseIdempotencyKey(ctx context.Context, data any) error {
+	record := data.(model.IdempotencyRecord)
+	_, err := d.db.ExecContext(ctx, `
+DELETE FROM idempotency_keys
+WHERE user_name = $1 AND key = $2 AND fingerprint = $3 AND status IS NULL;`,
+		record.User, record.Key, record.Fingerprint)
+	return classifyError(err)
+}
+
+func (d *Dbinstance) PurgeIdempotencyKeys(ctx context.Context, data any) (int64, error) {
+	limit := data.(int)
+	result, err := d.db.ExecContext(ctx, `
+DELETE FROM idempotency_keys
+WHERE (user_name, key) IN (
+    SELECT user_name, key
+    FROM idempotency_keys
+    WHERE expires_at <= (now() AT TIME ZONE 'utc')
+    LIMIT $1);`, limit)
+	if err != nil {
+		return 0, classifyError(err)
+	}
+	return result.RowsAffected()
+}
@@ -0,0 +1,115 @@
+// source - stream of 'Task' into table 'tasks' by COPY FROM STDIN
+package source
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"io"
+	"log"
+
+	"github.com/lib/pq"
+
+	"github.com/Ekvo/golang-chi-postgres-api/internal/model"
+)
+
+// ImportTasks - COPY of all 'Task' from 'model.TaskImport.Next' in one transaction, returns number of rows
+//
+// rows are copied into temporary table 'import_tasks' and moved into 'tasks' by 'moveImportedTasks',
+// error of 'Next' (except io.EOF) or of COPY rollback the whole import,
+// 'TaskCreated' of all rows are written in outbox in the same transaction
+func (d *Dbinstance) ImportTasks(ctx context.Context, data any) (int, error) {
+	taskImport := data.(model.TaskImport)
+	tx, err := d.db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, classifyError(err)
+	}
+	defer func() {
+		if err := tx.Rollback(); err != nil && !errors.Is(err, sql.ErrTxDone) {
+			log.Printf("query: import tx.Rollback error - %v", err)
+		}
+	}()
+	// types of columns are the same as of 'tasks', errors of rows are reported by COPY
+	if _, err := tx.ExecContext(ctx, `
+CREATE TEMPORARY TABLE import_tasks
+(
+    ord BIGSERIAL PRIMARY KEY,
+    description VARCHAR(2048) NOT NULL,
+    note VARCHAR(2048) NULL,
+    created_at TIMESTAMP NOT NULL
+) ON COMMIT DROP;`); err != nil {
+		return 0, classifyError(err)
+	}
+	stmt, err := tx.PrepareContext(ctx, pq.CopyIn("import_tasks", "description", "note", "created_at"))
+	if err != nil {
+		return 0, classifyError(err)
+	}
+	defer func() {
+		if err := stmt.Close(); err != nil && !errors.Is(err, sql.ErrTxDone) {
+			log.Printf("query: import stmt.Close error - %v", err)
+		}
+	}()
+	count := 0
+	for {
+		task, err := taskImport.Next()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return 0, err
+		}
+		if _, err := stmt.ExecContext(ctx,
+			task.Description,
+			emptyStringWriteNULL(task.Note),
+			task.CreatedAt,
+		); err != nil {
+			return 0, classifyError(err)
+		}
+		count++
+	}
+	// flush of COPY buffer, errors of rows are reported here
+	if _, err := stmt.ExecContext(ctx); err != nil {
+		return 0, classifyError(err)
+	}
+	if taskImport.DryRun {
+		return count, nil
+	}
+	if err := moveImportedTasks(ctx, tx); err != nil {
+		return 0, err
+	}
+	return count, classifyError(tx.Commit())
+}
+
+// moveImportedTasks - rows of 'import_tasks' into 'tasks' by pages of 'insertChunk' in order of COPY,
+// 'TaskCreated' of each page by 'writeOutbox' from rows of 'RETURNING'
+//
+// 'ord' of COPY of one transaction has no gaps, page is range of 'ord'
+func moveImportedTasks(ctx context.Context, tx *sql.Tx) error {
+	for after := 0; ; after += insertChunk {
+		rows, err := tx.QueryContext(ctx, `
+INSERT INTO tasks(description, note, created_at)
+SELECT description, note, created_at
+FROM import_tasks
+WHERE ord > $1 AND ord <= $1 + $2
+ORDER BY ord
+RETURNING *;`, after, insertChunk)
+		if err != nil {
+			return classifyError(err)
+		}
+		tasks, err := scanTakList(rows)
+		if err := rows.Close(); err != nil {
+			log.Printf("query: rows.Close error - %v", err)
+		}
+		if err != nil {
+			return err
+		}
+		if len(tasks) > 0 {
+			if err := writeOutbox(ctx, tx, model.TaskCreated, tasks...); err != nil {
+				return err
+			}
+		}
+		if len(tasks) < insertChunk {
+			return nil
+		}
+	}
+}
@@ -0,0 +1,188 @@
+// source - durable queue of background jobs
+package source
+
+import (
+	"context"
+	"database/sql"
+	"log"
+	"sort"
+	"time"
+
+	"github.com/lib/pq"
+
+	"github.com/Ekvo/golang-chi-postgres-api/internal/model"
+)
+
+// maxJobError - length of 'last_error' of job
+const maxJobError = 1024
+
+// createJobTable - table 'jobs'
+//
+// unique index of (kind, key) covers only queued and running jobs,
+// so job with the same key can be enqueued again after the previous one is finished
+func (d *Dbinstance) createJobTable(ctx context.Context) error {
+	_, err := d.db.ExecContext(ctx, `
+CREATE TABLE IF NOT EXISTS jobs
+(
+    id BIGSERIAL PRIMARY KEY,
+    kind VARCHAR(64) NOT NULL,
+    key VARCHAR(256) NULL,
+    payload JSONB NOT NULL DEFAULT '{}',
+    run_at TIMESTAMP NOT NULL,
+    status VARCHAR(16) NOT NULL DEFAULT 'queued',
+    attempts INTEGER NOT NULL DEFAULT 0,
+    max_attempts INTEGER NOT NULL,
+    locked_until TIMESTAMP NULL,
+    last_error VARCHAR(1024) NULL,
+    created_at TIMESTAMP NOT NULL DEFAULT (now() AT TIME ZONE 'utc'),
+    updated_at TIMESTAMP NULL
+);
+
+CREATE INDEX IF NOT EXISTS jobs_queued ON jobs(run_at) WHERE status = 'queued';
+
+CREATE INDEX IF NOT EXISTS jobs_running ON jobs(locked_until) WHERE status = 'running';
+
+CREATE UNIQUE INDEX IF NOT EXISTS jobs_unique_key
+ON jobs(kind, key) WHERE key IS NOT NULL AND status IN ('queued', 'running');`)
+	return err
+}
+
+// EnqueueJob - INSERT of 'model.Job', zero 'RunAt' - now
+//
+// job with the same kind and key which is queued or running is not duplicated, its id is returned
+func (d *Dbinstance) EnqueueJob(ctx context.Context, data any) (uint64, error) {
+	job := data.(model.Job)
+	var runAt any
+	if !job.RunAt.IsZero() {
+		runAt = job.RunAt.UTC()
+	}
+	var id uint64
+	err := d.db.QueryRowContext(ctx, `
+WITH ins AS (
+    INSERT INTO jobs(kind, key, payload, run_at, max_attempts)
+    VALUES($1, $2, COALESCE($3::JSONB, '{}'), COALESCE($4::TIMESTAMP, (now() AT TIME ZONE 'utc')), $5)
+    ON CONFLICT (kind, key) WHERE key IS NOT NULL AND status IN ('queued', 'running') DO NOTHING
+    RETURNING id
+)
+SELECT id FROM ins
+UNION ALL
+SELECT id FROM jobs WHERE kind = $1 AND key = $2 AND status IN ('queued', 'running')
+LIMIT 1;`,
+		job.Kind,
+		emptyStringWriteNULL(job.Key),
+		emptyStringWriteNULL(string(job.Payload)),
+		runAt,
+		job.MaxAttempts,
+	).Scan(&id)
+	return id, classifyError(err)
+}
+
+// ClaimJobs - due jobs of 'Kinds' by 'model.JobClaim', in order of run_at
+//
+// rows are locked by SKIP LOCKED, pools of all replicas take different jobs,
+// running job with expired lease (its pool is stopped) is claimed again
+func (d *Dbinstance) ClaimJobs(ctx context.Context, data any) ([]model.Job, error) {
+	claim := data.(model.JobClaim)
+	rows, err := d.db.QueryContext(ctx, `
+UPDATE jobs
+SET status = 'running', attempts = attempts + 1,
+    locked_until = (now() AT TIME ZONE 'utc') + make_interval(secs => $3),
+    updated_at = (now() AT TIME ZONE 'utc')
+WHERE id IN (
+    SELECT id
+    FROM jobs
+    WHERE kind = ANY($1) AND (
+        (status = 'queued' AND run_at <= (now() AT TIME ZONE 'utc')) OR
+        (status = 'running' AND locked_until <= (now() AT TIME ZONE 'utc')))
+    ORDER BY run_at, id
+    LIMIT $2
+    FOR UPDATE SKIP LOCKED
+)
+RETURNING id, kind, key, payload, run_at, status, attempts, max_attempts, last_error, created_at, updated_at;`,
+		pq.StringArray(append([]string{}, claim.Kinds...)), claim.Limit, claim.Lease.Seconds())
+	if err != nil {
+		return nil, classifyError(err)
+	}
+	defer func() {
+		if err := rows.Close(); err != nil {
+			log.Printf("query: rows.Close error - %v", err)
+		}
+	}()
+	var jobs []model.Job
+	for rows.Next() {
+		job := model.Job{}
+		key, lastError := sql.NullString{}, sql.NullString{}
+		updatedAt := sql.NullTime{}
+		if err := rows.Scan(
+			&job.ID, &job.Kind, &key, &job.Payload, &job.RunAt, &job.Status,
+			&job.Attempts, &job.MaxAttempts, &lastError, &job.CreatedAt, &updatedAt,
+		); err != nil {
+			return nil, classifyError(err)
+		}
+		job.Key = key.String
+		job.LastError = lastError.String
+		job.RunAt = job.RunAt.UTC()
+		job.CreatedAt = job.CreatedAt.UTC()
+		if updatedAt.Valid {
+			at := updatedAt.Time.UTC()
+			job.UpdatedAt = &at
+		}
+		jobs = append(jobs, job)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, classifyError(err)
+	}
+	// order of RETURNING is not defined
+	sort.Slice(jobs, func(i, j int) bool {
+		if !jobs[i].RunAt.Equal(jobs[j].RunAt) {
+			return jobs[i].RunAt.Before(jobs[j].RunAt)
+		}
+		return jobs[i].ID < jobs[j].ID
+	})
+	return jobs, nil
+}
+
+// FinishJob - result of run, job which is claimed again (other 'Attempts') or not running is skipped
+func (d *Dbinstance) FinishJob(ctx context.Context, data any) error {
+	result := data.(model.JobResult)
+	status, lastError, released := model.JobQueued, "", 0
+	var runAt any
+	switch {
+	case result.Err == nil:
+		status = model.JobDone
+	case result.Release:
+		released = 1
+	case result.Dead:
+		status = model.JobFailed
+	default:
+		runAt = result.RunAt.UTC()
+	}
+	if result.Err != nil {
+		lastError = result.Err.Error()
+		if runes := []rune(lastError); len(runes) > maxJobError {
+			lastError = string(runes[:maxJobError])
+		}
+	}
+	_, err := d.db.ExecContext(ctx, `
+UPDATE jobs
+SET status = $3, attempts = attempts - $4, run_at = COALESCE($5::TIMESTAMP, run_at), locked_until = NULL,
+    last_error = $6, updated_at = (now() AT TIME ZONE 'utc')
+WHERE id = $1 AND attempts = $2 AND status = 'running';`,
+		result.ID,
+		result.Attempts,
+		status,
+		released,
+		runAt,
+		emptyStringWriteNULL(lastError),
+	)
+	return classifyError(err)
+}
+
+func (d *Dbinstance) PurgeJobs(ctx context.Context, data any) error {
+	age := data.(time.Duration)
+	_, err := d.db.ExecContext(ctx, `
+DELETE FROM jobs
+WHERE status IN ('done', 'failed') AND updated_at < (now() AT TIME ZONE 'utc') - make_interval(secs => $1);`,
+		age.Seconds())
+	return classifyError(err)
+}
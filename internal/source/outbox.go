@@ -0,0 +1,173 @@
+// source - transactional outbox of domain events of 'Task'
+package source
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"log"
+
+	"github.com/lib/pq"
+
+	"github.com/Ekvo/golang-chi-postgres-api/internal/model"
+)
+
+// outboxTime - format of time in payload
+const outboxTime = "2006-01-02T15:04:05.000000Z07:00"
+
+// createOutboxTable - table 'outbox', rows are written by 'writeOutbox' in transactions of changes
+func (d *Dbinstance) createOutboxTable(ctx context.Context) error {
+	_, err := d.db.ExecContext(ctx, `
+CREATE TABLE IF NOT EXISTS outbox
+(
+    id BIGSERIAL PRIMARY KEY,
+    event_type VARCHAR(32) NOT NULL,
+    task_id BIGINT NOT NULL,
+    payload JSONB NOT NULL,
+    occurred_at TIMESTAMP NOT NULL,
+    published_at TIMESTAMP NULL
+);
+
+CREATE INDEX IF NOT EXISTS outbox_unpublished ON outbox(id) WHERE published_at IS NULL;`)
+	return err
+}
+
+// outboxTask - payload of 'model.DomainEvent'
+type outboxTask struct {
+	ID          uint   `json:"id"`
+	Description string `json:"description,omitempty"`
+	Note        string `json:"note,omitempty"`
+	CreatedAt   string `json:"created_at,omitempty"`
+	UpdatedAt   string `json:"updated_at,omitempty"`
+	RemindAt    string `json:"remind_at,omitempty"`
+	DueAt       string `json:"due_at,omitempty"`
+	TimeZone    string `json:"time_zone,omitempty"`
+	RRule       string `json:"rrule,omitempty"`
+
+	CustomFields map[string]any `json:"custom_fields,omitempty"`
+	ProjectID    uint           `json:"project_id,omitempty"`
+	Done         bool           `json:"done,omitempty"`
+	Status       string         `json:"status,omitempty"`
+	Position     string         `json:"position,omitempty"`
+}
+
+func outboxPayload(eventType string, task model.Task) ([]byte, error) {
+	payload := outboxTask{ID: task.ID}
+	if eventType != model.TaskDeleted {
+		payload.Description = task.Description
+		payload.Note = task.Note
+		payload.CreatedAt = task.CreatedAt.UTC().Format(outboxTime)
+		if task.UpdatedAt != nil {
+			payload.UpdatedAt = task.UpdatedAt.UTC().Format(outboxTime)
+		}
+		if task.RemindAt != nil {
+			payload.RemindAt = task.RemindAt.UTC().Format(outboxTime)
+		}
+		if task.DueAt != nil {
+			payload.DueAt = task.DueAt.UTC().Format(outboxTime)
+		}
+		payload.TimeZone = task.TimeZone
+		if task.Recurrence != nil {
+			payload.RRule = task.Recurrence.RRule
+		}
+		payload.CustomFields = task.CustomFields
+		payload.ProjectID = task.ProjectID
+		payload.Done = task.Done
+		payload.Status = task.Status
+		payload.Position = task.Position
+	}
+	return json.Marshal(payload)
+}
+
+// writeOutbox - events of 'tasks' inside 'tx', they are committed or rolled back with the change
+func writeOutbox(ctx context.Context, tx *sql.Tx, eventType string, tasks ...model.Task) error {
+	ids := make(pq.Int64Array, len(tasks))
+	payloads := make(pq.StringArray, len(tasks))
+	for i, task := range tasks {
+		payload, err := outboxPayload(eventType, task)
+		if err != nil {
+			return err
+		}
+		ids[i] = int64(task.ID)
+		payloads[i] = string(payload)
+	}
+	_, err := tx.ExecContext(ctx, `
+INSERT INTO outbox(event_type, task_id, payload, occurred_at)
+SELECT $1, t.id, t.payload::JSONB, (now() AT TIME ZONE 'utc')
+FROM unnest($2::BIGINT[], $3::TEXT[]) WITH ORDINALITY AS t(id, payload, ord)
+ORDER BY t.ord;`, eventType, ids, payloads)
+	return classifyError(err)
+}
+
+// RelayOutbox - unpublished events by 'model.OutboxRelay', returns number of published
+//
+// rows are locked by SKIP LOCKED, relays of all replicas take different rows,
+// order of events of one relay is order of id
+func (d *Dbinstance) RelayOutbox(ctx context.Context, data any) (int, error) {
+	relay := data.(model.OutboxRelay)
+	tx, err := d.db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, classifyError(err)
+	}
+	defer func() {
+		if err := tx.Rollback(); err != nil && !errors.Is(err, sql.ErrTxDone) {
+			log.Printf("query: outbox tx.Rollback error - %v", err)
+		}
+	}()
+	rows, err := tx.QueryContext(ctx, `
+SELECT id, event_type, task_id, payload, occurred_at
+FROM outbox
+WHERE published_at IS NULL
+ORDER BY id
+LIMIT $1
+FOR UPDATE SKIP LOCKED;`, relay.Limit)
+	if err != nil {
+		return 0, classifyError(err)
+	}
+	var events []model.DomainEvent
+	for rows.Next() {
+		ev := model.DomainEvent{}
+		if err := rows.Scan(&ev.ID, &ev.Type, &ev.TaskID, &ev.Payload, &ev.OccurredAt); err != nil {
+			_ = rows.Close()
+			return 0, classifyError(err)
+		}
+		ev.OccurredAt = ev.OccurredAt.UTC()
+		events = append(events, ev)
+	}
+	if err := rows.Close(); err != nil {
+		return 0, classifyError(err)
+	}
+	if err := rows.Err(); err != nil {
+		return 0, classifyError(err)
+	}
+	if len(events) == 0 {
+		return 0, nil
+	}
+	if err := relay.Publish(ctx, events); err != nil {
+		return 0, err
+	}
+	ids := make(pq.Int64Array, len(events))
+	for i, ev := range events {
+		ids[i] = int64(ev.ID)
+	}
+	if _, err := tx.ExecContext(ctx, `
+UPDATE outbox
+SET published_at = (now() AT TIME ZONE 'utc')
+WHERE id = ANY($1);`, ids); err != nil {
+		return 0, classifyError(err)
+	}
+	return len(events), classifyError(tx.Commit())
+}
+
+// PurgeOutbox - events published before 'Published' and not published before 'Unpublished' ('model.OutboxPurge'),
+// so table does not grow without relay
+func (d *Dbinstance) PurgeOutbox(ctx context.Context, data any) error {
+	purge := data.(model.OutboxPurge)
+	_, err := d.db.ExecContext(ctx, `
+DELETE FROM outbox
+WHERE published_at < (now() AT TIME ZONE 'utc') - make_interval(secs => $1)
+   OR (published_at IS NULL AND occurred_at < (now() AT TIME ZONE 'utc') - make_interval(secs => $2));`,
+		purge.Published.Seconds(), purge.Unpublished.Seconds())
+	return classifyError(err)
+}
@@ -0,0 +1,146 @@
+// source - projects of 'Task', column 'tasks.project_id'
+package source
+
+import (
+	"context"
+	"database/sql"
+	"log"
+
+	"github.com/Ekvo/golang-chi-postgres-api/internal/model"
+)
+
+// createProjectTable - table 'projects', it is created before column 'tasks.project_id' which refers to it
+func (d *Dbinstance) createProjectTable(ctx context.Context) error {
+	_, err := d.db.ExecContext(ctx, `
+CREATE TABLE IF NOT EXISTS projects
+(
+    id SERIAL PRIMARY KEY,
+    name VARCHAR(128) NOT NULL UNIQUE,
+    description VARCHAR(2048) NULL,
+    archived BOOLEAN NOT NULL DEFAULT FALSE,
+    position INTEGER NOT NULL DEFAULT 0,
+    created_at TIMESTAMP NOT NULL,
+    updated_at TIMESTAMP NULL
+);`)
+	return err
+}
+
+// projectSelect - 'Project' with counts of open and done 'Task'
+const projectSelect = `
+SELECT p.id, p.name, p.description, p.archived, p.position, p.created_at, p.updated_at,
+       COUNT(t.id) FILTER (WHERE NOT t.done),
+       COUNT(t.id) FILTER (WHERE t.done)
+FROM projects p
+LEFT JOIN tasks t ON t.project_id = p.id`
+
+// visibleTask - condition of 'Task' which is not in archived 'Project' (alias of table - 'tasks')
+const visibleTask = `NOT EXISTS (SELECT 1 FROM projects p WHERE p.id = tasks.project_id AND p.archived)`
+
+func (d *Dbinstance) SaveProject(ctx context.Context, data any) (model.Project, error) {
+	project := data.(model.Project)
+	err := d.db.QueryRowContext(ctx, `
+INSERT INTO projects(name, description, archived, position, created_at)
+VALUES($1, $2, $3, $4, $5)
+RETURNING id;`,
+		project.Name,
+		emptyStringWriteNULL(project.Description),
+		project.Archived,
+		project.Position,
+		project.CreatedAt,
+	).Scan(&project.ID)
+	return project, classifyError(err)
+}
+
+// UpdateProject - returns 'Project' with its counts, ErrSourceNotFound if there is no such 'Project'
+func (d *Dbinstance) UpdateProject(ctx context.Context, data any) (model.Project, error) {
+	project := data.(model.Project)
+	projectID := 0
+	err := d.db.QueryRowContext(ctx, `
+UPDATE projects
+SET name = $2,
+    description = $3,
+    archived = $4,
+    position = $5,
+    updated_at = $6
+WHERE id = $1
+RETURNING id;`,
+		project.ID,
+		project.Name,
+		emptyStringWriteNULL(project.Description),
+		project.Archived,
+		project.Position,
+		project.UpdatedAt,
+	).Scan(&projectID)
+	if err != nil {
+		return model.Project{}, classifyError(err)
+	}
+	return d.FindProject(ctx, project.ID)
+}
+
+// DeleteProject - 'Project' by id (uint), its 'Task' are kept without project (ON DELETE SET NULL)
+func (d *Dbinstance) DeleteProject(ctx context.Context, data any) error {
+	projectID := data.(uint)
+	deleted := 0
+	err := d.db.QueryRowContext(ctx, `
+DELETE
+FROM projects
+WHERE id = $1
+RETURNING id;`, projectID).Scan(&deleted)
+	return classifyError(err)
+}
+
+func (d *Dbinstance) FindProject(ctx context.Context, data any) (model.Project, error) {
+	projectID := data.(uint)
+	row := d.db.QueryRowContext(ctx, projectSelect+`
+WHERE p.id = $1
+GROUP BY p.id;`, projectID)
+	return scanProject[*sql.Row](row)
+}
+
+func (d *Dbinstance) FindProjects(ctx context.Context, data any) ([]model.Project, error) {
+	list := data.(model.ProjectList)
+	rows, err := d.db.QueryContext(ctx, projectSelect+`
+WHERE $1 OR NOT p.archived
+GROUP BY p.id
+ORDER BY p.position, p.id;`, list.Archived)
+	if err != nil {
+		return nil, classifyError(err)
+	}
+	defer func() {
+		if err := rows.Close(); err != nil {
+			log.Printf("query: rows.Close error - %v", err)
+		}
+	}()
+	var projects []model.Project
+	for rows.Next() {
+		project, err := scanProject[*sql.Rows](rows)
+		if err != nil {
+			return nil, err
+		}
+		projects = append(projects, project)
+	}
+	return projects, classifyError(rows.Err())
+}
+
+func scanProject[S RowScaner](r S) (model.Project, error) {
+	project := model.Project{}
+	description, updatedAt := sql.NullString{}, sql.NullTime{}
+	if err := r.Scan(
+		&project.ID,
+		&project.Name,
+		&description,
+		&project.Archived,
+		&project.Position,
+		&project.CreatedAt,
+		&updatedAt,
+		&project.Open,
+		&project.Done,
+	); err != nil {
+		return project, classifyError(err)
+	}
+	project.Description = description.String
+	if updatedAt.Valid {
+		project.UpdatedAt = &updatedAt.Time
+	}
+	return project, nil
+}
@@ -4,10 +4,15 @@ package source
 import (
 	"context"
 	"database/sql"
+	"encoding/json"
 	"errors"
+	"fmt"
 	"log"
 	"strconv"
 	"strings"
+	"time"
+
+	"github.com/lib/pq"
 
 	"github.com/Ekvo/golang-chi-postgres-api/internal/model"
 )
@@ -19,7 +24,20 @@ var (
 	ErrSourceIncorrectData = errors.New("invalid data")
 )
 
+// minServerVersion - 'server_version_num' of PostgreSQL 14 ('CREATE OR REPLACE TRIGGER', type 'xid8')
+const minServerVersion = 140000
+
 func (d *Dbinstance) CreateTables(ctx context.Context) error {
+	version := 0
+	if err := d.db.QueryRowContext(ctx, `SELECT current_setting('server_version_num')::INTEGER;`).Scan(&version); err != nil {
+		return classifyError(err)
+	}
+	if version < minServerVersion {
+		return fmt.Errorf("source: PostgreSQL %d is older than %d", version/10000, minServerVersion/10000)
+	}
+	if err := d.createProjectTable(ctx); err != nil {
+		return err
+	}
 	_, err := d.db.ExecContext(ctx, `
 CREATE TABLE IF NOT EXISTS tasks
 (
@@ -28,81 +46,189 @@ CREATE TABLE IF NOT EXISTS tasks
     note VARCHAR(2048) NULL,
     created_at TIMESTAMP NOT NULL,
     updated_at TIMESTAMP NULL
-);`)
-	return err
+);
+
+ALTER TABLE tasks
+ADD COLUMN IF NOT EXISTS remind_at TIMESTAMP NULL,
+ADD COLUMN IF NOT EXISTS remind_user VARCHAR(64) NULL;
+
+CREATE INDEX IF NOT EXISTS tasks_remind_at ON tasks(remind_at) WHERE remind_at IS NOT NULL;
+
+ALTER TABLE tasks
+ADD COLUMN IF NOT EXISTS due_at TIMESTAMP NULL,
+ADD COLUMN IF NOT EXISTS time_zone VARCHAR(64) NULL,
+ADD COLUMN IF NOT EXISTS rrule VARCHAR(512) NULL,
+ADD COLUMN IF NOT EXISTS rrule_start TIMESTAMP NULL;
+
+CREATE INDEX IF NOT EXISTS tasks_recurring ON tasks(id) WHERE rrule IS NOT NULL;
+
+ALTER TABLE tasks
+ADD COLUMN IF NOT EXISTS custom_fields JSONB NOT NULL DEFAULT '{}';
+
+CREATE INDEX IF NOT EXISTS tasks_custom_fields ON tasks USING GIN (custom_fields jsonb_path_ops);
+
+ALTER TABLE tasks
+ADD COLUMN IF NOT EXISTS project_id INTEGER NULL REFERENCES projects(id) ON DELETE SET NULL,
+ADD COLUMN IF NOT EXISTS done BOOLEAN NOT NULL DEFAULT FALSE;
+
+CREATE INDEX IF NOT EXISTS tasks_project ON tasks(project_id) WHERE project_id IS NOT NULL;
+
+ALTER TABLE tasks
+ADD COLUMN IF NOT EXISTS status VARCHAR(32) NOT NULL DEFAULT 'todo',
+ADD COLUMN IF NOT EXISTS position VARCHAR(128) COLLATE "C" NOT NULL DEFAULT '';
+
+CREATE INDEX IF NOT EXISTS tasks_board ON tasks(status, position, id);`)
+	if err != nil {
+		return err
+	}
+	if err := d.createWebhookTables(ctx); err != nil {
+		return err
+	}
+	if err := d.createOutboxTable(ctx); err != nil {
+		return err
+	}
+	if err := d.createJobTable(ctx); err != nil {
+		return err
+	}
+	if err := d.createReminderTable(ctx); err != nil {
+		return err
+	}
+	if err := d.createTimeEntryTable(ctx); err != nil {
+		return err
+	}
+	if err := d.createAttachmentTable(ctx); err != nil {
+		return err
+	}
+	if err := d.createCustomFieldTable(ctx); err != nil {
+		return err
+	}
+	if err := d.createIdempotencyTable(ctx); err != nil {
+		return err
+	}
+	if err := d.createRateLimitTables(ctx); err != nil {
+		return err
+	}
+	return d.createEventTables(ctx)
 }
 
+// SaveOneTask - INSERT of 'Task' and its 'TaskCreated' in outbox in one transaction
 func (d *Dbinstance) SaveOneTask(ctx context.Context, data any) (uint, error) {
 	newTask := data.(model.Task)
-	tx, err := d.db.BeginTx(ctx, nil)
+	if newTask.Status == "" {
+		newTask.Status = model.StatusDefault
+	}
+	customFields, err := customFieldsJSON(newTask.CustomFields)
 	if err != nil {
 		return 0, err
 	}
+	tx, err := d.db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, classifyError(err)
+	}
 	defer func() {
 		if err := tx.Rollback(); err != nil && !errors.Is(err, sql.ErrTxDone) {
 			log.Printf("query: insert task tx.Rollback error - %v", err)
 		}
 	}()
-	err = d.db.QueryRowContext(ctx, `
-INSERT INTO tasks(description,note,created_at)
-VALUES($1,$2,$3)
+	err = tx.QueryRowContext(ctx, `
+INSERT INTO tasks(description,note,created_at,remind_at,remind_user,due_at,time_zone,rrule,rrule_start,custom_fields,
+                  project_id,done,status,position)
+VALUES($1,$2,$3,$4,$5,$6,$7,$8,$9,$10,$11,$12,$13,$14)
 RETURNING id;`,
 		newTask.Description,
 		emptyStringWriteNULL(newTask.Note),
 		newTask.CreatedAt,
+		newTask.RemindAt,
+		emptyStringWriteNULL(newTask.RemindUser),
+		utcOrNULL(newTask.DueAt),
+		emptyStringWriteNULL(newTask.TimeZone),
+		rruleOrNULL(newTask.Recurrence),
+		rruleStartOrNULL(newTask.Recurrence),
+		customFields,
+		projectOrNULL(newTask.ProjectID),
+		newTask.Done,
+		newTask.Status,
+		newTask.Position,
 	).Scan(&newTask.ID)
-	return newTask.ID, err
+	if err != nil {
+		return 0, classifyError(err)
+	}
+	if err := writeOutbox(ctx, tx, model.TaskCreated, newTask); err != nil {
+		return 0, err
+	}
+	return newTask.ID, classifyError(tx.Commit())
 }
 
 func (d *Dbinstance) UpdateTask(ctx context.Context, data any) error {
 	updateTask := data.(model.Task)
-	taskID := 0
 	tx, err := d.db.BeginTx(ctx, nil)
 	if err != nil {
-		return err
+		return classifyError(err)
 	}
 	defer func() {
 		if err := tx.Rollback(); err != nil && !errors.Is(err, sql.ErrTxDone) {
 			log.Printf("query: update task tx.Rollback error - %v", err)
 		}
 	}()
-	err = tx.QueryRowContext(ctx, `
-UPDATE tasks
-SET description = $2,
-    note = $3,
-    updated_at = $4
-WHERE id = $1
-RETURNING id;`,
-		updateTask.ID,
-		updateTask.Description,
-		emptyStringWriteNULL(updateTask.Note),
-		updateTask.UpdatedAt,
-	).Scan(&taskID)
-	if err != nil || uint(taskID) != updateTask.ID {
-		return ErrSourceNotFound
+	if err := updateTaskRow(ctx, tx, updateTask); err != nil {
+		return err
 	}
 	return tx.Commit()
 }
 
+// PatchTask - read 'Task' with lock, change it by 'model.TaskPatch.Apply' and save in one transaction
+func (d *Dbinstance) PatchTask(ctx context.Context, data any) (model.Task, error) {
+	patch := data.(model.TaskPatch)
+	tx, err := d.db.BeginTx(ctx, nil)
+	if err != nil {
+		return model.Task{}, classifyError(err)
+	}
+	defer func() {
+		if err := tx.Rollback(); err != nil && !errors.Is(err, sql.ErrTxDone) {
+			log.Printf("query: patch task tx.Rollback error - %v", err)
+		}
+	}()
+	task, err := patchTaskRow(ctx, tx, patch)
+	if err != nil {
+		return model.Task{}, err
+	}
+	return task, classifyError(tx.Commit())
+}
+
+// patchTaskRow - 'Task' with lock, 'model.TaskPatch.Apply' and UPDATE inside 'tx'
+func patchTaskRow(ctx context.Context, tx *sql.Tx, patch model.TaskPatch) (model.Task, error) {
+	row := tx.QueryRowContext(ctx, `
+SELECT *
+FROM tasks
+WHERE id = $1
+FOR UPDATE;`, patch.ID)
+	task, err := scanOneTask[*sql.Row](row)
+	if err != nil {
+		return model.Task{}, err
+	}
+	task, err = patch.Apply(task)
+	if err != nil {
+		return model.Task{}, err
+	}
+	if err := updateTaskRow(ctx, tx, task); err != nil {
+		return model.Task{}, err
+	}
+	return task, nil
+}
+
 func (d *Dbinstance) EndTaskLife(ctx context.Context, data any) error {
 	taskID := data.(uint)
-	delTaskID := 0
 	tx, err := d.db.BeginTx(ctx, nil)
 	if err != nil {
-		return err
+		return classifyError(err)
 	}
 	defer func() {
 		if err := tx.Rollback(); err != nil && !errors.Is(err, sql.ErrTxDone) {
 			log.Printf("query: delete task tx.Rollback error - %v", err)
 		}
 	}()
-	err = tx.QueryRowContext(ctx, `
-DELETE 
-FROM tasks
-WHERE id = $1
-RETURNING id;`, taskID).Scan(&delTaskID)
-	if err != nil || taskID != uint(delTaskID) {
-		return ErrSourceNotFound
+	if err := deleteTaskRow(ctx, tx, taskID); err != nil {
+		return err
 	}
 	return tx.Commit()
 }
@@ -135,6 +261,7 @@ func (d *Dbinstance) FindTaskList(ctx context.Context, data any) ([]model.Task,
 	query.WriteString(`
 SELECT * 
 FROM tasks
+WHERE ` + visibleTask + `
 ORDER BY id`)
 	if taskList[0] == "desc" {
 		query.WriteString(" DESC")
@@ -149,7 +276,30 @@ ORDER BY id`)
 
 	rows, err := d.db.QueryContext(ctx, query.String(), args...)
 	if err != nil {
-		return nil, err
+		return nil, classifyError(err)
+	}
+	defer func() {
+		if err := rows.Close(); err != nil {
+			log.Printf("query: rows.Close error - %v", err)
+		}
+	}()
+	return scanTakList(rows)
+}
+
+// FindTasksByID - 'Task' of ids ([]uint) in one query ordered by id, missing ids are skipped
+func (d *Dbinstance) FindTasksByID(ctx context.Context, data any) ([]model.Task, error) {
+	ids := data.([]uint)
+	arr := make(pq.Int64Array, len(ids))
+	for i, id := range ids {
+		arr[i] = int64(id)
+	}
+	rows, err := d.db.QueryContext(ctx, `
+SELECT *
+FROM tasks
+WHERE id = ANY($1)
+ORDER BY id;`, arr)
+	if err != nil {
+		return nil, classifyError(err)
 	}
 	defer func() {
 		if err := rows.Close(); err != nil {
@@ -159,6 +309,86 @@ ORDER BY id`)
 	return scanTakList(rows)
 }
 
+// updateTaskRow - UPDATE of one 'Task' and its 'TaskUpdated' inside 'tx', ErrSourceNotFound if there is no such 'Task'
+//
+// all other columns are replaced by 'task' (PUT, 'PatchTask'), nil 'CustomFields' - stored values are kept
+// (PUT without schema of custom fields), column and position are changed only by 'MoveTask'
+func updateTaskRow(ctx context.Context, tx *sql.Tx, task model.Task) error {
+	var customFields any
+	if task.CustomFields != nil {
+		line, err := customFieldsJSON(task.CustomFields)
+		if err != nil {
+			return err
+		}
+		customFields = line
+	}
+	taskID, stored := 0, []byte{}
+	err := tx.QueryRowContext(ctx, `
+UPDATE tasks
+SET description = $2,
+    note = $3,
+    updated_at = $4,
+    remind_at = $5,
+    remind_user = $6,
+    due_at = $7,
+    time_zone = $8,
+    rrule = $9,
+    rrule_start = $10,
+    custom_fields = COALESCE($11::JSONB, custom_fields),
+    project_id = $12,
+    done = $13
+WHERE id = $1
+RETURNING id, created_at, custom_fields, status, position;`,
+		task.ID,
+		task.Description,
+		emptyStringWriteNULL(task.Note),
+		task.UpdatedAt,
+		task.RemindAt,
+		emptyStringWriteNULL(task.RemindUser),
+		utcOrNULL(task.DueAt),
+		emptyStringWriteNULL(task.TimeZone),
+		rruleOrNULL(task.Recurrence),
+		rruleStartOrNULL(task.Recurrence),
+		customFields,
+		projectOrNULL(task.ProjectID),
+		task.Done,
+	).Scan(&taskID, &task.CreatedAt, &stored, &task.Status, &task.Position)
+	if err != nil {
+		return classifyError(err)
+	}
+	if uint(taskID) != task.ID {
+		return ErrSourceNotFound
+	}
+	// event has values which are stored
+	task.CustomFields = nil
+	if err := json.Unmarshal(stored, &task.CustomFields); err != nil {
+		return fmt.Errorf("%w - %v", ErrSourceIncorrectData, err)
+	}
+	return writeOutbox(ctx, tx, model.TaskUpdated, task)
+}
+
+// deleteTaskRow - DELETE of one 'Task' and its 'TaskDeleted' inside 'tx', ErrSourceNotFound if there is no such 'Task'
+//
+// blobs of attachments of 'Task' are removed later by job 'model.KindBlobCleanup'
+func deleteTaskRow(ctx context.Context, tx *sql.Tx, taskID uint) error {
+	if err := enqueueTaskBlobCleanup(ctx, tx, taskID); err != nil {
+		return err
+	}
+	delTaskID := 0
+	err := tx.QueryRowContext(ctx, `
+DELETE 
+FROM tasks
+WHERE id = $1
+RETURNING id;`, taskID).Scan(&delTaskID)
+	if err != nil {
+		return classifyError(err)
+	}
+	if taskID != uint(delTaskID) {
+		return ErrSourceNotFound
+	}
+	return writeOutbox(ctx, tx, model.TaskDeleted, model.Task{ID: taskID})
+}
+
 func emptyStringWriteNULL(line string) *string {
 	if line == "" {
 		return nil
@@ -166,6 +396,50 @@ func emptyStringWriteNULL(line string) *string {
 	return &line
 }
 
+// utcOrNULL - time for column TIMESTAMP (without zone) is written in UTC
+func utcOrNULL(t *time.Time) *time.Time {
+	if t == nil {
+		return nil
+	}
+	utc := t.UTC()
+	return &utc
+}
+
+// customFieldsJSON - value of column 'custom_fields', nil - empty object
+func customFieldsJSON(values map[string]any) (string, error) {
+	if len(values) == 0 {
+		return "{}", nil
+	}
+	line, err := json.Marshal(values)
+	if err != nil {
+		return "", fmt.Errorf("%w - %v", ErrSourceIncorrectData, err)
+	}
+	return string(line), nil
+}
+
+// projectOrNULL - id of 'Project' of 'Task', 0 - NULL
+func projectOrNULL(projectID uint) *int64 {
+	if projectID == 0 {
+		return nil
+	}
+	id := int64(projectID)
+	return &id
+}
+
+func rruleOrNULL(rec *model.Recurrence) *string {
+	if rec == nil {
+		return nil
+	}
+	return &rec.RRule
+}
+
+func rruleStartOrNULL(rec *model.Recurrence) *time.Time {
+	if rec == nil {
+		return nil
+	}
+	return utcOrNULL(&rec.Start)
+}
+
 // TaskScaner - for generic function 'scannerTask'
 type RowScaner interface {
 	*sql.Row | *sql.Rows
@@ -174,23 +448,53 @@ type RowScaner interface {
 
 func scanOneTask[S RowScaner](r S) (model.Task, error) {
 	task := model.Task{}
-	updatedAt := sql.NullTime{}
-	note := sql.NullString{}
+	updatedAt, remindAt, dueAt, rruleStart := sql.NullTime{}, sql.NullTime{}, sql.NullTime{}, sql.NullTime{}
+	note, remindUser, timeZone, rrule := sql.NullString{}, sql.NullString{}, sql.NullString{}, sql.NullString{}
+	customFields, projectID := []byte{}, sql.NullInt64{}
 	if err := r.Scan(
 		&task.ID,
 		&task.Description,
 		&note,
 		&task.CreatedAt,
 		&updatedAt,
+		&remindAt,
+		&remindUser,
+		&dueAt,
+		&timeZone,
+		&rrule,
+		&rruleStart,
+		&customFields,
+		&projectID,
+		&task.Done,
+		&task.Status,
+		&task.Position,
 	); err != nil {
-		return task, ErrSourceNotFound
+		return task, classifyError(err)
 	}
+	task.ProjectID = uint(projectID.Int64)
 	if note.Valid {
 		task.Note = note.String
 	}
 	if updatedAt.Valid {
 		task.UpdatedAt = &updatedAt.Time
 	}
+	if remindAt.Valid {
+		task.RemindAt = &remindAt.Time
+	}
+	task.RemindUser = remindUser.String
+	if dueAt.Valid {
+		task.DueAt = &dueAt.Time
+	}
+	task.TimeZone = timeZone.String
+	if rrule.Valid && rruleStart.Valid {
+		task.Recurrence = &model.Recurrence{RRule: rrule.String, Start: rruleStart.Time}
+	}
+	if err := json.Unmarshal(customFields, &task.CustomFields); err != nil {
+		return task, fmt.Errorf("%w - %v", ErrSourceIncorrectData, err)
+	}
+	if len(task.CustomFields) == 0 {
+		task.CustomFields = nil
+	}
 	return task, nil
 }
 
@@ -203,5 +507,5 @@ func scanTakList(rows *sql.Rows) ([]model.Task, error) {
 		}
 		tasks = append(tasks, task)
 	}
-	return tasks, rows.Err()
+	return tasks, classifyError(rows.Err())
 }
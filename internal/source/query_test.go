@@ -2,8 +2,13 @@ package source
 
 import (
 	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
 	"log"
+	"reflect"
+	"strings"
 	"testing"
 	"time"
 
@@ -12,6 +17,7 @@ import (
 
 	"github.com/Ekvo/golang-chi-postgres-api/internal/config"
 	"github.com/Ekvo/golang-chi-postgres-api/internal/model"
+	"github.com/Ekvo/golang-chi-postgres-api/internal/rank"
 )
 
 // to check when receiving a task from the database
@@ -40,6 +46,20 @@ func updateTask(id uint) model.Task {
 	}
 }
 
+// newTaskImport - 'n' valid tasks for 'ImportTasks'
+func newTaskImport(n int, dryRun bool) model.TaskImport {
+	return model.TaskImport{
+		Next: func() (model.Task, error) {
+			if n == 0 {
+				return model.Task{}, io.EOF
+			}
+			n--
+			return newValidTask(), nil
+		},
+		DryRun: dryRun,
+	}
+}
+
 var qq = []struct {
 	description    string
 	init           func(ctx context.Context, d *Dbinstance, data any) (any, error)
@@ -61,6 +81,21 @@ var qq = []struct {
 		haveErr:        false,
 		msg:            "success - table must be created",
 	},
+	{
+		description: ("create webhook"),
+		init: func(ctx context.Context, d *Dbinstance, data any) (any, error) {
+			return d.CreateWebhook(ctx, data)
+		},
+		ctxTimeOut: 1 * time.Second,
+		data: model.Webhook{
+			URL:       "http://127.0.0.1:9/hook",
+			Secret:    "0123456789abcdef",
+			CreatedAt: time.Now().UTC(),
+		},
+		expectedResutl: uint(1),
+		haveErr:        false,
+		msg:            "valid - webhook of all events, each next change of task is its delivery",
+	},
 	{
 		description: ("save task"),
 		init: func(ctx context.Context, d *Dbinstance, data any) (any, error) {
@@ -119,6 +154,41 @@ var qq = []struct {
 		err:            ErrSourceNotFound,
 		msg:            "invalid - task must br return err and empty Task",
 	},
+	{
+		description: ("patch task"),
+		init: func(ctx context.Context, d *Dbinstance, data any) (any, error) {
+			return d.PatchTask(ctx, data)
+		},
+		ctxTimeOut: 1 * time.Second,
+		data: model.TaskPatch{ID: 1, Apply: func(task model.Task) (model.Task, error) {
+			task.Note = "patched"
+			task.UpdatedAt = &timeUpdate
+			return task, nil
+		}},
+		expectedResutl: model.Task{
+			ID:          1,
+			Description: updateTask(1).Description,
+			Note:        "patched",
+			CreatedAt:   timeCreate,
+			UpdatedAt:   &timeUpdate,
+		},
+		haveErr: false,
+		msg:     "valid - task must be patched inside transaction",
+	},
+	{
+		description: ("wrong patch task"),
+		init: func(ctx context.Context, d *Dbinstance, data any) (any, error) {
+			return d.PatchTask(ctx, data)
+		},
+		ctxTimeOut: 1 * time.Second,
+		data: model.TaskPatch{ID: 200, Apply: func(task model.Task) (model.Task, error) {
+			return task, nil
+		}},
+		expectedResutl: model.Task{},
+		haveErr:        true,
+		err:            ErrSourceNotFound,
+		msg:            "invalid - task not found, nothing patched",
+	},
 	{
 		description: ("delete task"),
 		init: func(ctx context.Context, d *Dbinstance, data any) (any, error) {
@@ -143,6 +213,960 @@ var qq = []struct {
 		err:            ErrSourceNotFound,
 		msg:            "Invalid - task cannot be deleted task does not exist",
 	},
+	{
+		description: ("atomic batch"),
+		init: func(ctx context.Context, d *Dbinstance, data any) (any, error) {
+			return d.ExecuteBatch(ctx, data)
+		},
+		ctxTimeOut: 1 * time.Second,
+		data: model.Batch{Atomic: true, Operations: []model.BatchOperation{
+			{Op: model.BatchCreate, Task: newValidTask()},
+			{Op: model.BatchCreate, Task: newValidTask()},
+		}},
+		expectedResutl: []model.BatchResult{{ID: 2}, {ID: 3}},
+		haveErr:        false,
+		msg:            "valid - tasks must be created by one INSERT",
+	},
+	{
+		description: ("best effort batch"),
+		init: func(ctx context.Context, d *Dbinstance, data any) (any, error) {
+			return d.ExecuteBatch(ctx, data)
+		},
+		ctxTimeOut: 1 * time.Second,
+		data: model.Batch{Atomic: false, Operations: []model.BatchOperation{
+			{Op: model.BatchDelete, Task: model.Task{ID: 200}},
+			{Op: model.BatchDelete, Task: model.Task{ID: 2}},
+		}},
+		expectedResutl: []model.BatchResult{{ID: 200, Err: ErrSourceNotFound}, {ID: 2}},
+		haveErr:        false,
+		msg:            "valid - failed operation must not affect others",
+	},
+	{
+		description: ("atomic batch in order"),
+		init: func(ctx context.Context, d *Dbinstance, data any) (any, error) {
+			results, err := d.ExecuteBatch(ctx, data)
+			if err == nil || len(results) != 2 {
+				return nil, errors.New("atomic batch is not failed")
+			}
+			return []any{strings.HasPrefix(err.Error(), "operation 0:"), errors.Is(results[0].Err, ErrSourceNotFound), results[1]}, nil
+		},
+		ctxTimeOut: 1 * time.Second,
+		data: model.Batch{Atomic: true, Operations: []model.BatchOperation{
+			{Op: model.BatchDelete, Task: model.Task{ID: 200}},
+			{Op: model.BatchCreate, Task: newValidTask()},
+		}},
+		expectedResutl: []any{true, true, model.BatchResult{}},
+		haveErr:        false,
+		msg:            "valid - create after failed delete is not executed, index of failed operation is in error",
+	},
+	{
+		description: ("find tasks by id"),
+		init: func(ctx context.Context, d *Dbinstance, data any) (any, error) {
+			tasks, err := d.FindTasksByID(ctx, data)
+			ids := make([]uint, 0, len(tasks))
+			for _, task := range tasks {
+				ids = append(ids, task.ID)
+			}
+			return ids, err
+		},
+		ctxTimeOut:     1 * time.Second,
+		data:           []uint{3, 2, 200},
+		expectedResutl: []uint{3},
+		haveErr:        false,
+		msg:            "valid - only existing tasks by one query",
+	},
+	{
+		description: ("import dry run"),
+		init: func(ctx context.Context, d *Dbinstance, data any) (any, error) {
+			return d.ImportTasks(ctx, data)
+		},
+		ctxTimeOut:     1 * time.Second,
+		data:           newTaskImport(3, true),
+		expectedResutl: 3,
+		haveErr:        false,
+		msg:            "valid - rows must be copied and rolled back",
+	},
+	{
+		description: ("import"),
+		init: func(ctx context.Context, d *Dbinstance, data any) (any, error) {
+			return d.ImportTasks(ctx, data)
+		},
+		ctxTimeOut:     1 * time.Second,
+		data:           newTaskImport(3, false),
+		expectedResutl: 3,
+		haveErr:        false,
+		msg:            "valid - rows must be saved by COPY",
+	},
+	{
+		description: ("outbox of import"),
+		init: func(ctx context.Context, d *Dbinstance, data any) (any, error) {
+			rows, err := d.db.QueryContext(ctx, `
+SELECT task_id, payload
+FROM outbox
+WHERE event_type = $1
+ORDER BY id DESC
+LIMIT $2;`, model.TaskCreated, data)
+			if err != nil {
+				return nil, err
+			}
+			defer rows.Close()
+			stored := map[uint]string{}
+			for rows.Next() {
+				id, payload := uint(0), ""
+				if err := rows.Scan(&id, &payload); err != nil {
+					return nil, err
+				}
+				stored[id] = payload
+			}
+			if err := rows.Err(); err != nil {
+				return nil, err
+			}
+			same := 0
+			for id, payload := range stored {
+				task, err := d.FindOneTask(ctx, id)
+				if err != nil {
+					return nil, err
+				}
+				expected, err := outboxPayload(model.TaskCreated, task)
+				if err != nil {
+					return nil, err
+				}
+				var want, got any
+				if err := json.Unmarshal(expected, &want); err != nil {
+					return nil, err
+				}
+				if err := json.Unmarshal([]byte(payload), &got); err != nil {
+					return nil, err
+				}
+				if reflect.DeepEqual(want, got) {
+					same++
+				}
+			}
+			return same, nil
+		},
+		ctxTimeOut:     1 * time.Second,
+		data:           3,
+		expectedResutl: 3,
+		haveErr:        false,
+		msg:            "valid - payload of imported task is the same as of other creates",
+	},
+	{
+		description: ("export"),
+		init: func(ctx context.Context, d *Dbinstance, data any) (any, error) {
+			return d.ExportTasks(ctx, data)
+		},
+		ctxTimeOut: 1 * time.Second,
+		data: model.TaskExport{
+			Filter: model.TaskFilter{Order: "desc"},
+			Each:   func(task model.Task) error { return nil },
+		},
+		expectedResutl: 4,
+		haveErr:        false,
+		msg:            "valid - all tasks (batch and import) must be read by cursor",
+	},
+	{
+		description: ("task events"),
+		init: func(ctx context.Context, d *Dbinstance, data any) (any, error) {
+			evs, err := d.TaskEventsSince(ctx, data)
+			return len(evs), err
+		},
+		ctxTimeOut:     1 * time.Second,
+		data:           model.EventCursor{},
+		expectedResutl: 10,
+		haveErr:        false,
+		msg:            "valid - each committed change of row is saved by trigger (dry run of import is not)",
+	},
+	{
+		description: ("relay outbox"),
+		init: func(ctx context.Context, d *Dbinstance, data any) (any, error) {
+			return d.RelayOutbox(ctx, data)
+		},
+		ctxTimeOut: 1 * time.Second,
+		data: model.OutboxRelay{
+			Limit:   100,
+			Publish: func(ctx context.Context, events []model.DomainEvent) error { return nil },
+		},
+		expectedResutl: 10,
+		haveErr:        false,
+		msg:            "valid - outbox is written in transaction of each change (dry run of import is not)",
+	},
+	{
+		description: ("relay outbox again"),
+		init: func(ctx context.Context, d *Dbinstance, data any) (any, error) {
+			return d.RelayOutbox(ctx, data)
+		},
+		ctxTimeOut: 1 * time.Second,
+		data: model.OutboxRelay{
+			Limit:   100,
+			Publish: func(ctx context.Context, events []model.DomainEvent) error { return errors.New("must not be called") },
+		},
+		expectedResutl: 0,
+		haveErr:        false,
+		msg:            "valid - published events are not relayed again",
+	},
+	{
+		description: ("purge outbox"),
+		init: func(ctx context.Context, d *Dbinstance, data any) (any, error) {
+			if _, err := d.db.ExecContext(ctx, `
+INSERT INTO outbox(event_type, task_id, payload, occurred_at)
+VALUES ('TaskCreated', 1, '{}', '2000-01-01');`); err != nil {
+				return nil, err
+			}
+			if err := d.PurgeOutbox(ctx, data); err != nil {
+				return nil, err
+			}
+			count := 0
+			err := d.db.QueryRowContext(ctx, `SELECT COUNT(*) FROM outbox;`).Scan(&count)
+			return count, err
+		},
+		ctxTimeOut:     1 * time.Second,
+		data:           model.OutboxPurge{Published: time.Hour, Unpublished: time.Hour},
+		expectedResutl: 10,
+		haveErr:        false,
+		msg:            "valid - old event which is not published is removed, new published ones are kept",
+	},
+	{
+		description: ("webhook deliveries"),
+		init: func(ctx context.Context, d *Dbinstance, data any) (any, error) {
+			deliveries, err := d.WebhookDeliveries(ctx, data)
+			return len(deliveries), err
+		},
+		ctxTimeOut:     1 * time.Second,
+		data:           model.DeliveryFilter{WebhookID: 1, Limit: 100},
+		expectedResutl: 10,
+		haveErr:        false,
+		msg:            "valid - outbox is written by trigger in transaction of each change",
+	},
+	{
+		description: ("claim deliveries"),
+		init: func(ctx context.Context, d *Dbinstance, data any) (any, error) {
+			deliveries, err := d.ClaimDeliveries(ctx, data)
+			urls := make([]string, len(deliveries))
+			for i, dl := range deliveries {
+				urls[i] = dl.URL
+			}
+			return urls, err
+		},
+		ctxTimeOut:     1 * time.Second,
+		data:           model.DeliveryClaim{Limit: 2, Lease: time.Minute},
+		expectedResutl: []string{"http://127.0.0.1:9/hook", "http://127.0.0.1:9/hook"},
+		haveErr:        false,
+		msg:            "valid - due deliveries with URL of webhook",
+	},
+	{
+		description: ("finish delivery dead"),
+		init: func(ctx context.Context, d *Dbinstance, data any) (any, error) {
+			if err := d.FinishDelivery(ctx, data); err != nil {
+				return nil, err
+			}
+			deliveries, err := d.WebhookDeliveries(ctx, model.DeliveryFilter{WebhookID: 1, Status: model.DeliveryDead, Limit: 100})
+			return len(deliveries), err
+		},
+		ctxTimeOut:     1 * time.Second,
+		data:           model.DeliveryResult{ID: 1, Status: 500, Err: errors.New("status 500"), Dead: true},
+		expectedResutl: 1,
+		haveErr:        false,
+		msg:            "valid - delivery must be dead",
+	},
+	{
+		description: ("redeliver"),
+		init: func(ctx context.Context, d *Dbinstance, data any) (any, error) {
+			return nil, d.RedeliverWebhook(ctx, data)
+		},
+		ctxTimeOut:     1 * time.Second,
+		data:           uint64(1),
+		expectedResutl: nil,
+		haveErr:        false,
+		msg:            "valid - dead delivery is pending again",
+	},
+	{
+		description: ("wrong redeliver"),
+		init: func(ctx context.Context, d *Dbinstance, data any) (any, error) {
+			return nil, d.RedeliverWebhook(ctx, data)
+		},
+		ctxTimeOut:     1 * time.Second,
+		data:           uint64(1000),
+		expectedResutl: nil,
+		haveErr:        true,
+		err:            ErrSourceNotFound,
+		msg:            "invalid - no such delivery",
+	},
+	{
+		description: ("purge deliveries"),
+		init: func(ctx context.Context, d *Dbinstance, data any) (any, error) {
+			if err := d.FinishDelivery(ctx, model.DeliveryResult{ID: 2, Status: 204}); err != nil {
+				return nil, err
+			}
+			return d.PurgeDeliveries(ctx, data)
+		},
+		ctxTimeOut:     1 * time.Second,
+		data:           model.DeliveryPurge{},
+		expectedResutl: int64(1),
+		haveErr:        false,
+		msg:            "valid - succeeded delivery is removed, pending ones are kept",
+	},
+	{
+		description: ("delete webhook"),
+		init: func(ctx context.Context, d *Dbinstance, data any) (any, error) {
+			return nil, d.DeleteWebhook(ctx, data)
+		},
+		ctxTimeOut:     1 * time.Second,
+		data:           uint(1),
+		expectedResutl: nil,
+		haveErr:        false,
+		msg:            "valid - webhook is deleted with its deliveries",
+	},
+	{
+		description: ("deliveries of deleted webhook"),
+		init: func(ctx context.Context, d *Dbinstance, data any) (any, error) {
+			return d.WebhookDeliveries(ctx, data)
+		},
+		ctxTimeOut:     1 * time.Second,
+		data:           model.DeliveryFilter{WebhookID: 1, Limit: 100},
+		expectedResutl: []model.WebhookDelivery(nil),
+		haveErr:        true,
+		err:            ErrSourceNotFound,
+		msg:            "invalid - no such webhook",
+	},
+	{
+		description: ("enqueue job"),
+		init: func(ctx context.Context, d *Dbinstance, data any) (any, error) {
+			return d.EnqueueJob(ctx, data)
+		},
+		ctxTimeOut:     1 * time.Second,
+		data:           model.Job{Kind: "remind", Key: "task-1", Payload: []byte(`{"task_id":1}`), MaxAttempts: 3},
+		expectedResutl: uint64(1),
+		haveErr:        false,
+		msg:            "valid - job is queued at once",
+	},
+	{
+		description: ("enqueue job with the same key"),
+		init: func(ctx context.Context, d *Dbinstance, data any) (any, error) {
+			return d.EnqueueJob(ctx, data)
+		},
+		ctxTimeOut:     1 * time.Second,
+		data:           model.Job{Kind: "remind", Key: "task-1", MaxAttempts: 3},
+		expectedResutl: uint64(1),
+		haveErr:        false,
+		msg:            "valid - queued job with the same key is returned",
+	},
+	{
+		description: ("claim jobs"),
+		init: func(ctx context.Context, d *Dbinstance, data any) (any, error) {
+			jobs, err := d.ClaimJobs(ctx, data)
+			payloads := make([]string, len(jobs))
+			for i, job := range jobs {
+				payloads[i] = fmt.Sprintf("%d %s %s", job.Attempts, job.Status, job.Payload)
+			}
+			return payloads, err
+		},
+		ctxTimeOut:     1 * time.Second,
+		data:           model.JobClaim{Kinds: []string{"remind"}, Limit: 10, Lease: time.Minute},
+		expectedResutl: []string{`1 running {"task_id": 1}`},
+		haveErr:        false,
+		msg:            "valid - due job is running with first attempt",
+	},
+	{
+		description: ("finish job with next attempt"),
+		init: func(ctx context.Context, d *Dbinstance, data any) (any, error) {
+			if err := d.FinishJob(ctx, data); err != nil {
+				return nil, err
+			}
+			jobs, err := d.ClaimJobs(ctx, model.JobClaim{Kinds: []string{"remind"}, Limit: 10, Lease: time.Minute})
+			return len(jobs), err
+		},
+		ctxTimeOut:     1 * time.Second,
+		data:           model.JobResult{ID: 1, Attempts: 1, Err: errors.New("smtp error"), RunAt: time.Now().Add(time.Hour)},
+		expectedResutl: 0,
+		haveErr:        false,
+		msg:            "valid - job is queued after backoff",
+	},
+	{
+		description: ("purge jobs"),
+		init: func(ctx context.Context, d *Dbinstance, data any) (any, error) {
+			return nil, d.PurgeJobs(ctx, data)
+		},
+		ctxTimeOut:     1 * time.Second,
+		data:           time.Hour,
+		expectedResutl: nil,
+		haveErr:        false,
+		msg:            "valid - only old finished jobs are removed",
+	},
+	{
+		description: ("due reminders"),
+		init: func(ctx context.Context, d *Dbinstance, data any) (any, error) {
+			remindAt := time.Now().UTC().Add(-time.Minute)
+			if _, err := d.SaveOneTask(ctx, model.Task{
+				Description: "call the bank",
+				CreatedAt:   time.Now().UTC(),
+				RemindAt:    &remindAt,
+				RemindUser:  "alice",
+			}); err != nil {
+				return nil, err
+			}
+			tasks, err := d.DueReminders(ctx, data)
+			users := make([]string, len(tasks))
+			for i, task := range tasks {
+				users[i] = task.RemindUser
+			}
+			return users, err
+		},
+		ctxTimeOut:     1 * time.Second,
+		data:           model.ReminderScan{Channels: []string{"smtp"}, Window: time.Hour, Limit: 10},
+		expectedResutl: []string{"alice"},
+		haveErr:        false,
+		msg:            "valid - only task with due reminder",
+	},
+	{
+		description: ("claim reminder twice"),
+		init: func(ctx context.Context, d *Dbinstance, data any) (any, error) {
+			tasks, err := d.DueReminders(ctx, data)
+			if err != nil || len(tasks) != 1 {
+				return nil, err
+			}
+			claim := model.ReminderClaim{TaskID: tasks[0].ID, RemindAt: *tasks[0].RemindAt, Channel: "smtp"}
+			first, err := d.ClaimReminder(ctx, claim)
+			if err != nil {
+				return nil, err
+			}
+			second, err := d.ClaimReminder(ctx, claim)
+			if err != nil {
+				return nil, err
+			}
+			due, err := d.DueReminders(ctx, data)
+			return []any{first, second, len(due)}, err
+		},
+		ctxTimeOut:     1 * time.Second,
+		data:           model.ReminderScan{Channels: []string{"smtp"}, Window: time.Hour, Limit: 10},
+		expectedResutl: []any{true, false, 0},
+		haveErr:        false,
+		msg:            "valid - reminder is claimed once, claimed reminder is not due",
+	},
+	{
+		description: ("recurring tasks"),
+		init: func(ctx context.Context, d *Dbinstance, data any) (any, error) {
+			dueAt := time.Date(2025, 3, 24, 8, 0, 0, 0, time.UTC)
+			id, err := d.SaveOneTask(ctx, model.Task{
+				Description: "water plants",
+				CreatedAt:   time.Now().UTC(),
+				DueAt:       &dueAt,
+				TimeZone:    "Europe/Berlin",
+				Recurrence:  &model.Recurrence{RRule: "FREQ=WEEKLY;BYDAY=MO", Start: dueAt},
+			})
+			if err != nil {
+				return nil, err
+			}
+			tasks, err := d.RecurringTasks(ctx, data)
+			if err != nil || len(tasks) != 1 || tasks[0].ID != id {
+				return nil, err
+			}
+			task := tasks[0]
+			return []string{task.DueAt.Format(time.RFC3339), task.TimeZone, task.Recurrence.RRule,
+				task.Recurrence.Start.Format(time.RFC3339)}, nil
+		},
+		ctxTimeOut:     1 * time.Second,
+		data:           model.RecurrenceScan{Due: time.Date(2025, 4, 1, 0, 0, 0, 0, time.UTC), Limit: 10},
+		expectedResutl: []string{"2025-03-24T08:00:00Z", "Europe/Berlin", "FREQ=WEEKLY;BYDAY=MO", "2025-03-24T08:00:00Z"},
+		haveErr:        false,
+		msg:            "valid - only task with rrule, due time and series are kept",
+	},
+	{
+		description: ("time entries"),
+		init: func(ctx context.Context, d *Dbinstance, data any) (any, error) {
+			id, err := d.SaveOneTask(ctx, model.Task{Description: "write report", CreatedAt: time.Now().UTC(), TimeZone: "Asia/Tokyo"})
+			if err != nil {
+				return nil, err
+			}
+			start := time.Date(2025, 3, 1, 23, 30, 0, 0, time.UTC)
+			stop := start.Add(time.Hour)
+			if _, err := d.SaveTimeEntry(ctx, model.TimeEntry{TaskID: id, User: "alice", StartAt: start, StopAt: &stop,
+				CreatedAt: time.Now()}); err != nil {
+				return nil, err
+			}
+			running, err := d.StartTimer(ctx, model.TimeEntry{TaskID: id, User: "alice", StartAt: stop, CreatedAt: time.Now()})
+			if err != nil {
+				return nil, err
+			}
+			_, errConflict := d.StartTimer(ctx, model.TimeEntry{TaskID: id, User: "alice", StartAt: stop, CreatedAt: time.Now()})
+			stopped, err := d.StopTimer(ctx, model.TimerStop{TaskID: id, User: "alice", At: stop.Add(30 * time.Minute)})
+			if err != nil || stopped.ID != running.ID {
+				return nil, err
+			}
+			report := data.(model.TimeReport)
+			report.TaskIDs = []uint{id}
+			totals, err := d.TimeTotals(ctx, report)
+			if err != nil || len(totals) != 1 {
+				return nil, err
+			}
+			return []any{errors.Is(errConflict, ErrSourceConflict), totals[0].Day, totals[0].Duration}, nil
+		},
+		ctxTimeOut:     1 * time.Second,
+		data:           model.TimeReport{User: "alice"},
+		expectedResutl: []any{true, "2025-03-02", 90 * time.Minute},
+		haveErr:        false,
+		msg:            "valid - one running timer per user, day in zone of task",
+	},
+	{
+		description: ("attachments"),
+		init: func(ctx context.Context, d *Dbinstance, data any) (any, error) {
+			id, err := d.SaveOneTask(ctx, model.Task{Description: "send invoice", CreatedAt: time.Now().UTC()})
+			if err != nil {
+				return nil, err
+			}
+			keys := data.([]string)
+			var saved []model.Attachment
+			for _, key := range keys {
+				attachment, err := d.SaveAttachment(ctx, model.Attachment{TaskID: id, Name: "invoice.pdf",
+					ContentType: "application/pdf", Size: 3, SHA256: strings.Repeat("a", 64), Key: key, User: "alice",
+					CreatedAt: time.Now()})
+				if err != nil {
+					return nil, err
+				}
+				saved = append(saved, attachment)
+			}
+			_, errUnknown := d.SaveAttachment(ctx, model.Attachment{TaskID: id + 1000, Key: "tasks/unknown"})
+			if err := d.DeleteAttachment(ctx, model.AttachmentRef{TaskID: id, ID: saved[0].ID}); err != nil {
+				return nil, err
+			}
+			left, err := d.TaskAttachments(ctx, id)
+			if err != nil {
+				return nil, err
+			}
+			if err := d.EndTaskLife(ctx, id); err != nil {
+				return nil, err
+			}
+			rows, err := d.db.QueryContext(ctx, `
+SELECT payload->>'keys'
+FROM jobs
+WHERE kind = $1
+ORDER BY id;`, model.KindBlobCleanup)
+			if err != nil {
+				return nil, err
+			}
+			defer rows.Close()
+			payloads := []string{}
+			for rows.Next() {
+				payload := ""
+				if err := rows.Scan(&payload); err != nil {
+					return nil, err
+				}
+				payloads = append(payloads, payload)
+			}
+			return []any{errors.Is(errUnknown, ErrSourceNotFound), len(left), payloads}, rows.Err()
+		},
+		ctxTimeOut:     1 * time.Second,
+		data:           []string{"tasks/1/a", "tasks/1/b", "tasks/1/c"},
+		expectedResutl: []any{true, 2, []string{`["tasks/1/a"]`, `["tasks/1/b", "tasks/1/c"]`}},
+		haveErr:        false,
+		msg:            "valid - blobs of deleted attachment and of deleted task are queued for cleanup",
+	},
+	{
+		description: ("custom fields"),
+		init: func(ctx context.Context, d *Dbinstance, data any) (any, error) {
+			field := data.(model.CustomField)
+			created, err := d.SaveCustomField(ctx, field)
+			if err != nil {
+				return nil, err
+			}
+			field.Required = true
+			replaced, err := d.SaveCustomField(ctx, field)
+			if err != nil {
+				return nil, err
+			}
+			id := uint(0)
+			for _, component := range []string{"api", "web"} {
+				if id, err = d.SaveOneTask(ctx, model.Task{Description: "fix " + component, CreatedAt: time.Now().UTC(),
+					CustomFields: map[string]any{field.Name: component, "estimate": 2.5}}); err != nil {
+					return nil, err
+				}
+			}
+			// without 'CustomFields' stored values are kept
+			updatedAt := time.Now().UTC()
+			if err := d.UpdateTask(ctx, model.Task{ID: id, Description: "fix web", UpdatedAt: &updatedAt}); err != nil {
+				return nil, err
+			}
+			found := []string{}
+			_, err = d.ExportTasks(ctx, model.TaskExport{
+				Filter: model.TaskFilter{Order: "asc", CustomFields: map[string]any{field.Name: "web"}},
+				Each: func(task model.Task) error {
+					found = append(found, fmt.Sprint(task.Description, task.CustomFields))
+					return nil
+				}})
+			if err != nil {
+				return nil, err
+			}
+			if err := d.DeleteCustomField(ctx, field.Name); err != nil {
+				return nil, err
+			}
+			errDeleted := d.DeleteCustomField(ctx, field.Name)
+			fields, err := d.CustomFields(ctx)
+			if err != nil {
+				return nil, err
+			}
+			return []any{created.UpdatedAt == nil, replaced.Required && replaced.UpdatedAt != nil, found,
+				errors.Is(errDeleted, ErrSourceNotFound), len(fields)}, nil
+		},
+		ctxTimeOut:     1 * time.Second,
+		data:           model.CustomField{Name: "component", Type: model.FieldString, Allowed: []any{"api", "web"}, CreatedAt: time.Now()},
+		expectedResutl: []any{true, true, []string{"fix webmap[component:web estimate:2.5]"}, true, 0},
+		haveErr:        false,
+		msg:            "valid - field is replaced by name, tasks are found by containment of custom fields",
+	},
+	{
+		description: ("projects"),
+		init: func(ctx context.Context, d *Dbinstance, data any) (any, error) {
+			project, err := d.SaveProject(ctx, data.(model.Project))
+			if err != nil {
+				return nil, err
+			}
+			taskID := uint(0)
+			for _, done := range []bool{false, true} {
+				if taskID, err = d.SaveOneTask(ctx, model.Task{Description: "plan sprint", CreatedAt: time.Now().UTC(),
+					ProjectID: project.ID, Done: done}); err != nil {
+					return nil, err
+				}
+			}
+			_, errUnknown := d.SaveOneTask(ctx, model.Task{Description: "lost", CreatedAt: time.Now().UTC(),
+				ProjectID: project.ID + 1000})
+			var ve *ValidationError
+			unknown := errors.As(errUnknown, &ve) && ve.Params[0].Name == "project_id"
+			updatedAt := time.Now().UTC()
+			project.Archived, project.UpdatedAt = true, &updatedAt
+			archived, err := d.UpdateProject(ctx, project)
+			if err != nil {
+				return nil, err
+			}
+			visible := 0
+			for _, withArchived := range []bool{false, true} {
+				_, err := d.ExportTasks(ctx, model.TaskExport{
+					Filter: model.TaskFilter{Order: "asc", ProjectID: project.ID, Archived: withArchived},
+					Each: func(task model.Task) error {
+						visible++
+						return nil
+					}})
+				if err != nil {
+					return nil, err
+				}
+			}
+			if err := d.DeleteProject(ctx, project.ID); err != nil {
+				return nil, err
+			}
+			task, err := d.FindOneTask(ctx, taskID)
+			if err != nil {
+				return nil, err
+			}
+			return []any{unknown, archived.Open, archived.Done, visible, task.ProjectID, task.Done}, nil
+		},
+		ctxTimeOut:     1 * time.Second,
+		data:           model.Project{Name: "backend", Position: 1, CreatedAt: time.Now().UTC()},
+		expectedResutl: []any{true, 1, 1, 2, uint(0), true},
+		haveErr:        false,
+		msg:            "valid - counts of open and done tasks, archived project hides tasks, deleted project keeps them",
+	},
+	{
+		description: ("reminders and recurrence of done task and archived project"),
+		init: func(ctx context.Context, d *Dbinstance, data any) (any, error) {
+			project, err := d.SaveProject(ctx, model.Project{Name: "old", Archived: true, CreatedAt: time.Now().UTC()})
+			if err != nil {
+				return nil, err
+			}
+			remindAt := time.Now().UTC().Add(-time.Minute)
+			dueAt := time.Date(2025, 3, 24, 8, 0, 0, 0, time.UTC)
+			ids := map[uint]bool{}
+			for _, task := range []model.Task{{Done: true}, {ProjectID: project.ID}} {
+				task.Description, task.CreatedAt, task.RemindAt, task.RemindUser = "hidden", time.Now().UTC(), &remindAt, "bob"
+				task.DueAt, task.TimeZone = &dueAt, "UTC"
+				task.Recurrence = &model.Recurrence{RRule: "FREQ=DAILY", Start: dueAt}
+				id, err := d.SaveOneTask(ctx, task)
+				if err != nil {
+					return nil, err
+				}
+				ids[id] = true
+			}
+			found := 0
+			due, err := d.DueReminders(ctx, data)
+			if err != nil {
+				return nil, err
+			}
+			recurring, err := d.RecurringTasks(ctx, model.RecurrenceScan{Due: time.Now().UTC(), Limit: 1000})
+			if err != nil {
+				return nil, err
+			}
+			for _, task := range append(due, recurring...) {
+				if ids[task.ID] {
+					found++
+				}
+			}
+			return found, nil
+		},
+		ctxTimeOut:     1 * time.Second,
+		data:           model.ReminderScan{Channels: []string{"smtp"}, Window: time.Hour, Limit: 100},
+		expectedResutl: 0,
+		haveErr:        false,
+		msg:            "valid - done task and task of archived project have no reminder and do not move",
+	},
+	{
+		description: ("due reminders by pages"),
+		init: func(ctx context.Context, d *Dbinstance, data any) (any, error) {
+			ids := map[uint]string{}
+			for i, description := range []string{"second page", "first page"} {
+				remindAt := time.Now().UTC().Add(-time.Duration(i+1) * 10 * time.Second)
+				id, err := d.SaveOneTask(ctx, model.Task{
+					Description: description,
+					CreatedAt:   time.Now().UTC(),
+					RemindAt:    &remindAt,
+					RemindUser:  "carol",
+				})
+				if err != nil {
+					return nil, err
+				}
+				ids[id] = description
+			}
+			scan := data.(model.ReminderScan)
+			var pages []string
+			for {
+				due, err := d.DueReminders(ctx, scan)
+				if err != nil || len(due) == 0 {
+					return pages, err
+				}
+				if description, ok := ids[due[0].ID]; ok {
+					pages = append(pages, description)
+				}
+				scan.After, scan.AfterID = *due[0].RemindAt, due[0].ID
+			}
+		},
+		ctxTimeOut:     1 * time.Second,
+		data:           model.ReminderScan{Channels: []string{"smtp"}, Window: time.Hour, Limit: 1},
+		expectedResutl: []string{"first page", "second page"},
+		haveErr:        false,
+		msg:            "valid - next page is after 'remind_at' and id of last reminder",
+	},
+	{
+		description: ("batch update of text"),
+		init: func(ctx context.Context, d *Dbinstance, data any) (any, error) {
+			project, err := d.SaveProject(ctx, model.Project{Name: "kept", CreatedAt: time.Now().UTC()})
+			if err != nil {
+				return nil, err
+			}
+			remindAt := time.Date(2025, 3, 24, 7, 0, 0, 0, time.UTC)
+			dueAt := time.Date(2025, 3, 24, 8, 0, 0, 0, time.UTC)
+			id, err := d.SaveOneTask(ctx, model.Task{
+				Description: "before",
+				CreatedAt:   time.Now().UTC(),
+				RemindAt:    &remindAt,
+				RemindUser:  "dave",
+				DueAt:       &dueAt,
+				TimeZone:    "Europe/Berlin",
+				Recurrence:  &model.Recurrence{RRule: "FREQ=DAILY", Start: dueAt},
+				ProjectID:   project.ID,
+				Done:        true,
+			})
+			if err != nil {
+				return nil, err
+			}
+			now := time.Now().UTC()
+			batch := data.(model.Batch)
+			batch.Operations[0].Task.ID, batch.Operations[0].Task.UpdatedAt = id, &now
+			if _, err := d.ExecuteBatch(ctx, batch); err != nil {
+				return nil, err
+			}
+			task, err := d.FindOneTask(ctx, id)
+			if err != nil {
+				return nil, err
+			}
+			return []any{task.Description, task.ProjectID == project.ID, task.Done, task.RemindAt.Equal(remindAt),
+				task.RemindUser, task.DueAt.Equal(dueAt), task.TimeZone, task.Recurrence.RRule}, nil
+		},
+		ctxTimeOut: 1 * time.Second,
+		data: model.Batch{Atomic: true, Operations: []model.BatchOperation{
+			{Op: model.BatchUpdate, Task: model.Task{Description: "after"}},
+		}},
+		expectedResutl: []any{"after", true, true, true, "dave", true, "Europe/Berlin", "FREQ=DAILY"},
+		haveErr:        false,
+		msg:            "valid - update of batch changes text only, project, done, reminder and recurrence are kept",
+	},
+	{
+		description: ("board"),
+		init: func(ctx context.Context, d *Dbinstance, data any) (any, error) {
+			ids := make([]uint, 3)
+			for i := range ids {
+				id, err := d.SaveOneTask(ctx, model.Task{Description: "card", CreatedAt: time.Now().UTC()})
+				if err != nil {
+					return nil, err
+				}
+				ids[i] = id
+			}
+			var positions []any
+			for _, move := range []model.TaskMove{
+				{ID: ids[0], Status: data.(string)},
+				{ID: ids[1], Status: data.(string), After: ids[0]},
+				{ID: ids[2], Status: data.(string), Before: ids[1]},
+			} {
+				move.UpdatedAt, move.Rank, move.Keys = time.Now().UTC(), rank.Between, rank.Spread
+				task, err := d.MoveTask(ctx, move)
+				if err != nil {
+					return nil, err
+				}
+				positions = append(positions, task.Position)
+			}
+			_, errAnchor := d.MoveTask(ctx, model.TaskMove{ID: ids[0], After: 1,
+				UpdatedAt: time.Now().UTC(), Rank: rank.Between, Keys: rank.Spread})
+			var ve *ValidationError
+			anchor := errors.As(errAnchor, &ve) && ve.Params[0].Name == "after"
+			events := func() (n int, err error) {
+				err = d.db.QueryRowContext(ctx, `SELECT COUNT(*) FROM task_events;`).Scan(&n)
+				return n, err
+			}
+			before, err := events()
+			if err != nil {
+				return nil, err
+			}
+			columns, err := d.RebalancePositions(ctx, model.RankRebalance{MaxLen: rank.MaxKeyLen, Keys: rank.Spread})
+			if err != nil {
+				return nil, err
+			}
+			after, err := events()
+			if err != nil {
+				return nil, err
+			}
+			var column []uint
+			_, err = d.ExportTasks(ctx, model.TaskExport{
+				Filter: model.TaskFilter{Order: "asc", Status: data.(string), Board: true},
+				Each: func(task model.Task) error {
+					column = append(column, task.ID)
+					return nil
+				}})
+			if err != nil {
+				return nil, err
+			}
+			inOrder := len(column) == 3 && column[0] == ids[0] && column[1] == ids[2] && column[2] == ids[1]
+			return append(positions, anchor, columns > 0, inOrder, after == before), nil
+		},
+		ctxTimeOut:     1 * time.Second,
+		data:           "doing",
+		expectedResutl: []any{"V", "l", "d", true, true, true, true},
+		haveErr:        false,
+		msg:            "valid - keys between neighbours, anchor of other column, unranked column is rebalanced without events",
+	},
+	{
+		description: ("idempotency keys"),
+		init: func(ctx context.Context, d *Dbinstance, data any) (any, error) {
+			claim := data.(model.IdempotencyClaim)
+			first, claimed, err := d.ClaimIdempotencyKey(ctx, claim)
+			if err != nil {
+				return nil, err
+			}
+			inFlight, claimedAgain, err := d.ClaimIdempotencyKey(ctx, claim)
+			if err != nil {
+				return nil, err
+			}
+			first.Status, first.Header, first.Body = 201, map[string][]string{"Content-Type": {"application/json"}},
+				[]byte(`{"task":1}`)
+			if err := d.SaveIdempotentResponse(ctx, first); err != nil {
+				return nil, err
+			}
+			errSaved := d.SaveIdempotentResponse(ctx, first)
+			kept, _, err := d.ClaimIdempotencyKey(ctx, claim)
+			if err != nil {
+				return nil, err
+			}
+			expired := claim
+			expired.Key, expired.TTL = "expired", 0
+			for i := 0; i < 2; i++ {
+				if _, claimed, err = d.ClaimIdempotencyKey(ctx, expired); err != nil || !claimed {
+					return nil, fmt.Errorf("expired key is not claimed - %v", err)
+				}
+			}
+			purged, err := d.PurgeIdempotencyKeys(ctx, 1000)
+			if err != nil {
+				return nil, err
+			}
+			released := claim
+			released.Key = "released"
+			record, _, err := d.ClaimIdempotencyKey(ctx, released)
+			if err != nil {
+				return nil, err
+			}
+			if err := d.ReleaseIdempotencyKey(ctx, record); err != nil {
+				return nil, err
+			}
+			_, claimedReleased, err := d.ClaimIdempotencyKey(ctx, released)
+			if err != nil {
+				return nil, err
+			}
+			return []any{claimed, claimedAgain, inFlight.Status, errors.Is(errSaved, ErrSourceConflict),
+				kept.Status, kept.Header["Content-Type"][0], string(kept.Body), purged, claimedReleased}, nil
+		},
+		ctxTimeOut: 1 * time.Second,
+		data: model.IdempotencyClaim{User: "alice", Key: "create-1", Fingerprint: strings.Repeat("a", 64),
+			TTL: time.Hour, Lease: time.Minute},
+		expectedResutl: []any{true, false, 0, true, 201, "application/json", `{"task":1}`, int64(1), true},
+		haveErr:        false,
+		msg:            "valid - claim, in flight, kept response, expired key is claimed again and purged, released key",
+	},
+	{
+		description: ("rate limits"),
+		init: func(ctx context.Context, d *Dbinstance, data any) (any, error) {
+			take := data.(model.RateTake)
+			var allowed []bool
+			for i := 0; i < 3; i++ {
+				state, err := d.TakeRate(ctx, take)
+				if err != nil {
+					return nil, err
+				}
+				allowed = append(allowed, state.Allowed)
+			}
+			quota := model.QuotaTake{Key: take.Key, Day: time.Now().UTC(), Count: 1, Limit: 1}
+			first, err := d.TakeQuota(ctx, quota)
+			if err != nil {
+				return nil, err
+			}
+			used, err := d.TakeQuota(ctx, quota)
+			if err != nil {
+				return nil, err
+			}
+			quota.Count = -1
+			back, err := d.TakeQuota(ctx, quota)
+			if err != nil {
+				return nil, err
+			}
+			if err := d.PurgeRateLimits(ctx, time.Now().UTC().Add(48*time.Hour)); err != nil {
+				return nil, err
+			}
+			full, err := d.TakeRate(ctx, take)
+			if err != nil {
+				return nil, err
+			}
+			return []any{allowed, first.Allowed, used.Allowed, used.Used, back.Used, full.Allowed}, nil
+		},
+		ctxTimeOut:     1 * time.Second,
+		data:           model.RateTake{Key: "user:alice|POST /task", Interval: time.Minute, Burst: 2},
+		expectedResutl: []any{[]bool{true, true, false}, true, false, 1, 0, true},
+		haveErr:        false,
+		msg:            "valid - burst of tokens then none, quota of day is used and returned, purge of full buckets",
+	},
+	{
+		description: ("purge task events"),
+		init: func(ctx context.Context, d *Dbinstance, data any) (any, error) {
+			evs, err := d.TaskEventsSince(ctx, model.EventCursor{})
+			if err != nil {
+				return nil, err
+			}
+			purged, err := d.PurgeTaskEvents(ctx, data)
+			if err != nil {
+				return nil, err
+			}
+			last := evs[len(evs)-1].Cursor()
+			_, errGone := d.TaskEventsSince(ctx, evs[0].Cursor())
+			rest, err := d.TaskEventsSince(ctx, last)
+			return []any{purged == int64(len(evs)), errors.Is(errGone, ErrSourceEventsGone), len(rest), err}, nil
+		},
+		ctxTimeOut:     1 * time.Second,
+		data:           time.Duration(0),
+		expectedResutl: []any{true, true, 0, nil},
+		haveErr:        false,
+		msg:            "valid - cursor before the last removed event is gone, cursor of the last one is not",
+	},
 }
 
 // connect for other test base 'postgres'
@@ -160,8 +1184,30 @@ func TestQueryDbinstance(t *testing.T) {
 
 	base := NewDbinstance(db)
 	// for clear test
+	_, err = db.Exec(`DROP TABLE IF EXISTS reminders_sent;`)
+	requires.NoError(err, fmt.Sprintf("query_test: drop table error -%v", err))
+	_, err = db.Exec(`DROP TABLE IF EXISTS time_entries;`)
+	requires.NoError(err, fmt.Sprintf("query_test: drop table error -%v", err))
+	_, err = db.Exec(`DROP TABLE IF EXISTS attachments;`)
+	requires.NoError(err, fmt.Sprintf("query_test: drop table error -%v", err))
+	_, err = db.Exec(`DROP TABLE IF EXISTS idempotency_keys;`)
+	requires.NoError(err, fmt.Sprintf("query_test: drop table error -%v", err))
+	_, err = db.Exec(`DROP TABLE IF EXISTS rate_limits, quota_usage;`)
+	requires.NoError(err, fmt.Sprintf("query_test: drop table error -%v", err))
+	_, err = db.Exec(`DROP TABLE IF EXISTS custom_fields;`)
+	requires.NoError(err, fmt.Sprintf("query_test: drop table error -%v", err))
 	_, err = db.Exec(`DROP TABLE tasks;`)
 	requires.NoError(err, fmt.Sprintf("query_test: drop table error -%v", err))
+	_, err = db.Exec(`DROP TABLE IF EXISTS projects;`)
+	requires.NoError(err, fmt.Sprintf("query_test: drop table error -%v", err))
+	_, err = db.Exec(`DROP TABLE IF EXISTS task_events, task_events_purged;`)
+	requires.NoError(err, fmt.Sprintf("query_test: drop table error -%v", err))
+	_, err = db.Exec(`DROP TABLE IF EXISTS webhook_deliveries, webhooks;`)
+	requires.NoError(err, fmt.Sprintf("query_test: drop table error -%v", err))
+	_, err = db.Exec(`DROP TABLE IF EXISTS outbox;`)
+	requires.NoError(err, fmt.Sprintf("query_test: drop table error -%v", err))
+	_, err = db.Exec(`DROP TABLE IF EXISTS jobs;`)
+	requires.NoError(err, fmt.Sprintf("query_test: drop table error -%v", err))
 
 	for i, query := range qq {
 		log.Printf("\t %d query: %s\n", i+1, query.description)
@@ -0,0 +1,93 @@
+// source - token buckets (GCRA) and daily quotas of clients shared by all replicas
+package source
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/Ekvo/golang-chi-postgres-api/internal/model"
+)
+
+// createRateLimitTables - table 'rate_limits' (TAT of bucket) and 'quota_usage' (count of client in day)
+func (d *Dbinstance) createRateLimitTables(ctx context.Context) error {
+	_, err := d.db.ExecContext(ctx, `
+CREATE TABLE IF NOT EXISTS rate_limits
+(
+    key VARCHAR(320) PRIMARY KEY,
+    tat TIMESTAMP NOT NULL
+);
+
+CREATE TABLE IF NOT EXISTS quota_usage
+(
+    key VARCHAR(320) NOT NULL,
+    day DATE NOT NULL,
+    used INTEGER NOT NULL,
+    PRIMARY KEY (key, day)
+);`)
+	return err
+}
+
+// TakeRate - one UPSERT of TAT by time of store (look ~> ../ratelimit/gcra.go),
+// no token - TAT is not changed and is read
+func (d *Dbinstance) TakeRate(ctx context.Context, data any) (model.RateState, error) {
+	take := data.(model.RateTake)
+	state := model.RateState{Allowed: true}
+	err := d.db.QueryRowContext(ctx, `
+INSERT INTO rate_limits AS l (key, tat)
+VALUES ($1, (now() AT TIME ZONE 'utc') + make_interval(secs => $2))
+ON CONFLICT (key) DO UPDATE
+SET tat = GREATEST(l.tat, now() AT TIME ZONE 'utc') + make_interval(secs => $2)
+WHERE l.tat <= (now() AT TIME ZONE 'utc') + make_interval(secs => $3)
+RETURNING tat, now() AT TIME ZONE 'utc';`,
+		take.Key, take.Interval.Seconds(), (time.Duration(take.Burst-1)*take.Interval).Seconds(),
+	).Scan(&state.TAT, &state.Now)
+	if !errors.Is(err, sql.ErrNoRows) {
+		return state, classifyError(err)
+	}
+	state.Allowed = false
+	err = d.db.QueryRowContext(ctx, `
+SELECT tat, now() AT TIME ZONE 'utc'
+FROM rate_limits
+WHERE key = $1;`, take.Key).Scan(&state.TAT, &state.Now)
+	return state, classifyError(err)
+}
+
+// TakeQuota - 'Count' is added if sum is not above 'Limit', negative 'Count' is returned down to zero
+func (d *Dbinstance) TakeQuota(ctx context.Context, data any) (model.QuotaState, error) {
+	take := data.(model.QuotaTake)
+	day := take.Day.UTC().Format(time.DateOnly)
+	state := model.QuotaState{Allowed: true}
+	err := d.db.QueryRowContext(ctx, `
+INSERT INTO quota_usage AS q (key, day, used)
+SELECT $1, $2::DATE, GREATEST($3::INTEGER, 0)
+WHERE $3::INTEGER <= $4::INTEGER
+ON CONFLICT (key, day) DO UPDATE
+SET used = GREATEST(q.used + $3::INTEGER, 0)
+WHERE q.used + $3::INTEGER <= $4::INTEGER OR $3::INTEGER <= 0
+RETURNING used;`, take.Key, day, take.Count, take.Limit).Scan(&state.Used)
+	if !errors.Is(err, sql.ErrNoRows) {
+		return state, classifyError(err)
+	}
+	state.Allowed = false
+	err = d.db.QueryRowContext(ctx, `
+SELECT COALESCE((SELECT used FROM quota_usage WHERE key = $1 AND day = $2::DATE), 0);`, take.Key, day).
+		Scan(&state.Used)
+	return state, classifyError(err)
+}
+
+// PurgeRateLimits - full buckets and quotas of days before 'now'
+func (d *Dbinstance) PurgeRateLimits(ctx context.Context, data any) error {
+	now := data.(time.Time).UTC()
+	_, err := d.db.ExecContext(ctx, `
+DELETE FROM rate_limits
+WHERE tat <= $1;`, now)
+	if err != nil {
+		return classifyError(err)
+	}
+	_, err = d.db.ExecContext(ctx, `
+DELETE FROM quota_usage
+WHERE day < $1::DATE;`, now.Format(time.DateOnly))
+	return classifyError(err)
+}
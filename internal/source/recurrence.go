@@ -0,0 +1,34 @@
+// source - recurring 'Task' whose period may be elapsed
+package source
+
+import (
+	"context"
+	"log"
+
+	"github.com/Ekvo/golang-chi-postgres-api/internal/model"
+)
+
+// RecurringTasks - 'Task' with RRULE and 'due_at' before 'model.RecurrenceScan.Due' in order of id after 'AfterID',
+// done 'Task' and 'Task' of archived 'Project' are skipped
+func (d *Dbinstance) RecurringTasks(ctx context.Context, data any) ([]model.Task, error) {
+	scan := data.(model.RecurrenceScan)
+	rows, err := d.db.QueryContext(ctx, `
+SELECT *
+FROM tasks
+WHERE rrule IS NOT NULL
+  AND due_at <= $1
+  AND id > $2
+  AND NOT done
+  AND `+visibleTask+`
+ORDER BY id
+LIMIT $3;`, scan.Due.UTC(), scan.AfterID, scan.Limit)
+	if err != nil {
+		return nil, classifyError(err)
+	}
+	defer func() {
+		if err := rows.Close(); err != nil {
+			log.Printf("query: rows.Close error - %v", err)
+		}
+	}()
+	return scanTakList(rows)
+}
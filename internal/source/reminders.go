@@ -0,0 +1,85 @@
+// source - due reminders of 'Task' and claims of their sending
+package source
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"log"
+
+	"github.com/lib/pq"
+
+	"github.com/Ekvo/golang-chi-postgres-api/internal/model"
+)
+
+// createReminderTable - table 'reminders_sent', one row - reminder of 'Task' is claimed for channel
+//
+// 'remind_at' is a part of key, so new time of reminder of the same 'Task' is sent again
+func (d *Dbinstance) createReminderTable(ctx context.Context) error {
+	_, err := d.db.ExecContext(ctx, `
+CREATE TABLE IF NOT EXISTS reminders_sent
+(
+    task_id INTEGER NOT NULL REFERENCES tasks(id) ON DELETE CASCADE,
+    remind_at TIMESTAMP NOT NULL,
+    channel VARCHAR(16) NOT NULL,
+    sent_at TIMESTAMP NOT NULL DEFAULT (now() AT TIME ZONE 'utc'),
+    PRIMARY KEY (task_id, remind_at, channel)
+);`)
+	return err
+}
+
+// DueReminders - 'Task' with due reminder which is not claimed for one of 'Channels' at least,
+// after ('After', 'AfterID') in order of 'remind_at' and id, done 'Task' and 'Task' of archived 'Project' are skipped
+func (d *Dbinstance) DueReminders(ctx context.Context, data any) ([]model.Task, error) {
+	scan := data.(model.ReminderScan)
+	rows, err := d.db.QueryContext(ctx, `
+SELECT tasks.*
+FROM tasks
+WHERE tasks.remind_at <= (now() AT TIME ZONE 'utc')
+  AND tasks.remind_at > (now() AT TIME ZONE 'utc') - make_interval(secs => $1)
+  AND (tasks.remind_at, tasks.id) > ($4, $5)
+  AND NOT tasks.done
+  AND `+visibleTask+`
+  AND EXISTS (
+    SELECT 1
+    FROM unnest($2::TEXT[]) AS c(channel)
+    WHERE NOT EXISTS (
+        SELECT 1
+        FROM reminders_sent s
+        WHERE s.task_id = tasks.id AND s.remind_at = tasks.remind_at AND s.channel = c.channel))
+ORDER BY tasks.remind_at, tasks.id
+LIMIT $3;`, scan.Window.Seconds(), pq.StringArray(append([]string{}, scan.Channels...)), scan.Limit,
+		scan.After.UTC(), scan.AfterID)
+	if err != nil {
+		return nil, classifyError(err)
+	}
+	defer func() {
+		if err := rows.Close(); err != nil {
+			log.Printf("query: rows.Close error - %v", err)
+		}
+	}()
+	return scanTakList(rows)
+}
+
+// ClaimReminder - row of 'reminders_sent' before sending, claim of other replica or of run before restart - false
+func (d *Dbinstance) ClaimReminder(ctx context.Context, data any) (bool, error) {
+	claim := data.(model.ReminderClaim)
+	taskID := uint(0)
+	err := d.db.QueryRowContext(ctx, `
+INSERT INTO reminders_sent(task_id, remind_at, channel)
+VALUES($1, $2, $3)
+ON CONFLICT DO NOTHING
+RETURNING task_id;`, claim.TaskID, claim.RemindAt.UTC(), claim.Channel).Scan(&taskID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return false, nil
+	}
+	return err == nil, classifyError(err)
+}
+
+func (d *Dbinstance) ReleaseReminder(ctx context.Context, data any) error {
+	claim := data.(model.ReminderClaim)
+	_, err := d.db.ExecContext(ctx, `
+DELETE FROM reminders_sent
+WHERE task_id = $1 AND remind_at = $2 AND channel = $3;`, claim.TaskID, claim.RemindAt.UTC(), claim.Channel)
+	return classifyError(err)
+}
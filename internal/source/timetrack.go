@@ -0,0 +1,177 @@
+// source - entries of time spent on 'Task' and their totals
+package source
+
+import (
+	"context"
+	"database/sql"
+	"log"
+	"time"
+
+	"github.com/lib/pq"
+
+	"github.com/Ekvo/golang-chi-postgres-api/internal/model"
+)
+
+// createTimeEntryTable - table 'time_entries'
+//
+// unique index of running entries (stop_at IS NULL) keeps one running timer per user
+func (d *Dbinstance) createTimeEntryTable(ctx context.Context) error {
+	_, err := d.db.ExecContext(ctx, `
+CREATE TABLE IF NOT EXISTS time_entries
+(
+    id BIGSERIAL PRIMARY KEY,
+    task_id INTEGER NOT NULL REFERENCES tasks(id) ON DELETE CASCADE,
+    user_name VARCHAR(256) NOT NULL,
+    start_at TIMESTAMP NOT NULL,
+    stop_at TIMESTAMP NULL,
+    note VARCHAR(2048) NULL,
+    created_at TIMESTAMP NOT NULL,
+    CHECK (stop_at IS NULL OR stop_at >= start_at)
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS time_entries_running
+ON time_entries(user_name) WHERE stop_at IS NULL;
+
+CREATE INDEX IF NOT EXISTS time_entries_task
+ON time_entries(task_id, start_at);`)
+	return err
+}
+
+const timeEntryColumns = `id, task_id, user_name, start_at, stop_at, note, created_at`
+
+// StartTimer - running 'TimeEntry' of existing 'Task', running timer of user - ErrSourceConflict
+func (d *Dbinstance) StartTimer(ctx context.Context, data any) (model.TimeEntry, error) {
+	entry := data.(model.TimeEntry)
+	row := d.db.QueryRowContext(ctx, `
+INSERT INTO time_entries(task_id, user_name, start_at, note, created_at)
+SELECT id, $2, $3, $4, $5
+FROM tasks
+WHERE id = $1
+RETURNING `+timeEntryColumns+`;`,
+		entry.TaskID,
+		entry.User,
+		entry.StartAt.UTC(),
+		emptyStringWriteNULL(entry.Note),
+		entry.CreatedAt.UTC(),
+	)
+	return scanTimeEntry[*sql.Row](row)
+}
+
+// StopTimer - stop running timer of user on 'Task', no running timer - ErrSourceNotFound
+//
+// timer is not stopped before its start
+func (d *Dbinstance) StopTimer(ctx context.Context, data any) (model.TimeEntry, error) {
+	stop := data.(model.TimerStop)
+	row := d.db.QueryRowContext(ctx, `
+UPDATE time_entries
+SET stop_at = GREATEST($3, start_at)
+WHERE user_name = $1 AND task_id = $2 AND stop_at IS NULL
+RETURNING `+timeEntryColumns+`;`, stop.User, stop.TaskID, stop.At.UTC())
+	return scanTimeEntry[*sql.Row](row)
+}
+
+// SaveTimeEntry - finished 'TimeEntry' of existing 'Task', unknown 'Task' - ErrSourceNotFound
+func (d *Dbinstance) SaveTimeEntry(ctx context.Context, data any) (model.TimeEntry, error) {
+	entry := data.(model.TimeEntry)
+	row := d.db.QueryRowContext(ctx, `
+INSERT INTO time_entries(task_id, user_name, start_at, stop_at, note, created_at)
+SELECT id, $2, $3, $4, $5, $6
+FROM tasks
+WHERE id = $1
+RETURNING `+timeEntryColumns+`;`,
+		entry.TaskID,
+		entry.User,
+		entry.StartAt.UTC(),
+		utcOrNULL(entry.StopAt),
+		emptyStringWriteNULL(entry.Note),
+		entry.CreatedAt.UTC(),
+	)
+	return scanTimeEntry[*sql.Row](row)
+}
+
+func (d *Dbinstance) TimeEntries(ctx context.Context, data any) ([]model.TimeEntry, error) {
+	taskID := data.(uint)
+	rows, err := d.db.QueryContext(ctx, `
+SELECT `+timeEntryColumns+`
+FROM time_entries
+WHERE task_id = $1
+ORDER BY start_at, id;`, taskID)
+	if err != nil {
+		return nil, classifyError(err)
+	}
+	defer func() {
+		if err := rows.Close(); err != nil {
+			log.Printf("query: rows.Close error - %v", err)
+		}
+	}()
+	var entries []model.TimeEntry
+	for rows.Next() {
+		entry, err := scanTimeEntry[*sql.Rows](rows)
+		if err != nil {
+			return nil, err
+		}
+		entries = append(entries, entry)
+	}
+	return entries, classifyError(rows.Err())
+}
+
+// TimeTotals - sum of entries by 'model.TimeReport', day is counted in zone of report or of 'Task'
+func (d *Dbinstance) TimeTotals(ctx context.Context, data any) ([]model.TimeTotal, error) {
+	report := data.(model.TimeReport)
+	ids := make(pq.Int64Array, len(report.TaskIDs))
+	for i, id := range report.TaskIDs {
+		ids[i] = int64(id)
+	}
+	rows, err := d.db.QueryContext(ctx, `
+SELECT e.task_id,
+       to_char((e.start_at AT TIME ZONE 'UTC') AT TIME ZONE COALESCE(NULLIF($5, ''), NULLIF(t.time_zone, ''), 'UTC'),
+               'YYYY-MM-DD') AS day,
+       ROUND(SUM(EXTRACT(EPOCH FROM COALESCE(e.stop_at, now() AT TIME ZONE 'utc') - e.start_at)))::BIGINT
+FROM time_entries e
+JOIN tasks t ON t.id = e.task_id
+WHERE (cardinality($1::BIGINT[]) = 0 OR e.task_id = ANY($1))
+  AND ($2 = '' OR e.user_name = $2)
+  AND ($3::TIMESTAMP IS NULL OR e.start_at >= $3)
+  AND ($4::TIMESTAMP IS NULL OR e.start_at < $4)
+GROUP BY e.task_id, day
+ORDER BY e.task_id, day;`, ids, report.User, utcOrNULL(report.From), utcOrNULL(report.To), report.TimeZone)
+	if err != nil {
+		return nil, classifyError(err)
+	}
+	defer func() {
+		if err := rows.Close(); err != nil {
+			log.Printf("query: rows.Close error - %v", err)
+		}
+	}()
+	var totals []model.TimeTotal
+	for rows.Next() {
+		total, secs := model.TimeTotal{}, int64(0)
+		if err := rows.Scan(&total.TaskID, &total.Day, &secs); err != nil {
+			return nil, classifyError(err)
+		}
+		total.Duration = time.Duration(secs) * time.Second
+		totals = append(totals, total)
+	}
+	return totals, classifyError(rows.Err())
+}
+
+func scanTimeEntry[S RowScaner](r S) (model.TimeEntry, error) {
+	entry := model.TimeEntry{}
+	stopAt, note := sql.NullTime{}, sql.NullString{}
+	if err := r.Scan(
+		&entry.ID,
+		&entry.TaskID,
+		&entry.User,
+		&entry.StartAt,
+		&stopAt,
+		&note,
+		&entry.CreatedAt,
+	); err != nil {
+		return entry, classifyError(err)
+	}
+	if stopAt.Valid {
+		entry.StopAt = &stopAt.Time
+	}
+	entry.Note = note.String
+	return entry, nil
+}
@@ -0,0 +1,254 @@
+// source - webhooks and outbox of their deliveries
+package source
+
+import (
+	"context"
+	"database/sql"
+	"log"
+
+	"github.com/lib/pq"
+
+	"github.com/Ekvo/golang-chi-postgres-api/internal/model"
+)
+
+// maxDeliveryError - length of 'last_error' of delivery
+const maxDeliveryError = 1024
+
+// createWebhookTables - tables 'webhooks' and 'webhook_deliveries'
+//
+// rows of 'webhook_deliveries' are added by trigger of 'tasks' (look ~> ./events.go)
+// and keep snapshot of event, so log and redelivery do not depend on retention of 'task_events'
+func (d *Dbinstance) createWebhookTables(ctx context.Context) error {
+	_, err := d.db.ExecContext(ctx, `
+CREATE TABLE IF NOT EXISTS webhooks
+(
+    id SERIAL PRIMARY KEY,
+    url VARCHAR(2048) NOT NULL,
+    events VARCHAR(16)[] NOT NULL DEFAULT '{}',
+    secret VARCHAR(256) NOT NULL,
+    created_at TIMESTAMP NOT NULL
+);
+
+CREATE TABLE IF NOT EXISTS webhook_deliveries
+(
+    id BIGSERIAL PRIMARY KEY,
+    webhook_id INTEGER NOT NULL REFERENCES webhooks(id) ON DELETE CASCADE,
+    event_id BIGINT NOT NULL,
+    type VARCHAR(16) NOT NULL,
+    task_id BIGINT NOT NULL,
+    description VARCHAR(2048) NULL,
+    note VARCHAR(2048) NULL,
+    task_created_at TIMESTAMP NULL,
+    task_updated_at TIMESTAMP NULL,
+    occurred_at TIMESTAMP NOT NULL,
+    status VARCHAR(16) NOT NULL DEFAULT 'pending',
+    attempts INTEGER NOT NULL DEFAULT 0,
+    next_attempt_at TIMESTAMP NOT NULL DEFAULT (now() AT TIME ZONE 'utc'),
+    last_status INTEGER NOT NULL DEFAULT 0,
+    last_error VARCHAR(1024) NULL,
+    updated_at TIMESTAMP NULL,
+    UNIQUE (webhook_id, event_id)
+);
+
+CREATE INDEX IF NOT EXISTS webhook_deliveries_pending
+ON webhook_deliveries(next_attempt_at) WHERE status = 'pending';`)
+	return err
+}
+
+func (d *Dbinstance) CreateWebhook(ctx context.Context, data any) (uint, error) {
+	hook := data.(model.Webhook)
+	var id uint
+	err := d.db.QueryRowContext(ctx, `
+INSERT INTO webhooks(url, events, secret, created_at)
+VALUES($1, $2, $3, $4)
+RETURNING id;`,
+		hook.URL,
+		pq.StringArray(append([]string{}, hook.Events...)),
+		hook.Secret,
+		hook.CreatedAt,
+	).Scan(&id)
+	return id, classifyError(err)
+}
+
+// FindWebhooks - all webhooks ordered by id
+func (d *Dbinstance) FindWebhooks(ctx context.Context) ([]model.Webhook, error) {
+	rows, err := d.db.QueryContext(ctx, `
+SELECT id, url, events, secret, created_at
+FROM webhooks
+ORDER BY id;`)
+	if err != nil {
+		return nil, classifyError(err)
+	}
+	defer func() {
+		if err := rows.Close(); err != nil {
+			log.Printf("query: rows.Close error - %v", err)
+		}
+	}()
+	var hooks []model.Webhook
+	for rows.Next() {
+		hook := model.Webhook{}
+		events := pq.StringArray{}
+		if err := rows.Scan(&hook.ID, &hook.URL, &events, &hook.Secret, &hook.CreatedAt); err != nil {
+			return nil, classifyError(err)
+		}
+		hook.Events = events
+		hooks = append(hooks, hook)
+	}
+	return hooks, classifyError(rows.Err())
+}
+
+// DeleteWebhook - webhook (id is uint) with its deliveries
+func (d *Dbinstance) DeleteWebhook(ctx context.Context, data any) error {
+	id := data.(uint)
+	delID := uint(0)
+	err := d.db.QueryRowContext(ctx, `
+DELETE
+FROM webhooks
+WHERE id = $1
+RETURNING id;`, id).Scan(&delID)
+	return classifyError(err)
+}
+
+const deliveryColumns = `d.id, d.webhook_id, d.event_id, d.type, d.task_id, d.description, d.note,
+d.task_created_at, d.task_updated_at, d.occurred_at,
+d.status, d.attempts, d.next_attempt_at, d.last_status, d.last_error, d.updated_at`
+
+// WebhookDeliveries - log of deliveries by 'model.DeliveryFilter', unknown webhook - ErrSourceNotFound
+func (d *Dbinstance) WebhookDeliveries(ctx context.Context, data any) ([]model.WebhookDelivery, error) {
+	filter := data.(model.DeliveryFilter)
+	deliveries, err := d.deliveries(ctx, false, `
+SELECT `+deliveryColumns+`
+FROM webhook_deliveries d
+WHERE d.webhook_id = $1 AND ($2 = '' OR d.status = $2)
+ORDER BY d.id DESC
+LIMIT $3;`, filter.WebhookID, filter.Status, filter.Limit)
+	if err != nil || len(deliveries) > 0 {
+		return deliveries, err
+	}
+	exists := false
+	if err := d.db.QueryRowContext(ctx, `SELECT EXISTS (SELECT 1 FROM webhooks WHERE id = $1);`,
+		filter.WebhookID).Scan(&exists); err != nil {
+		return nil, classifyError(err)
+	}
+	if !exists {
+		return nil, ErrSourceNotFound
+	}
+	return deliveries, nil
+}
+
+func (d *Dbinstance) RedeliverWebhook(ctx context.Context, data any) error {
+	id := data.(uint64)
+	updID := uint64(0)
+	err := d.db.QueryRowContext(ctx, `
+UPDATE webhook_deliveries
+SET status = 'pending', attempts = 0, next_attempt_at = (now() AT TIME ZONE 'utc'),
+    updated_at = (now() AT TIME ZONE 'utc')
+WHERE id = $1
+RETURNING id;`, id).Scan(&updID)
+	return classifyError(err)
+}
+
+// ClaimDeliveries - pending deliveries which are due, with URL and Secret of webhook
+//
+// rows are locked by SKIP LOCKED, so workers of all replicas take different rows,
+// 'next_attempt_at' is moved by 'Lease' - delivery of stopped worker is taken again after it
+func (d *Dbinstance) ClaimDeliveries(ctx context.Context, data any) ([]model.WebhookDelivery, error) {
+	claim := data.(model.DeliveryClaim)
+	return d.deliveries(ctx, true, `
+UPDATE webhook_deliveries d
+SET next_attempt_at = (now() AT TIME ZONE 'utc') + make_interval(secs => $2)
+FROM webhooks w
+WHERE w.id = d.webhook_id AND d.id IN (
+    SELECT id
+    FROM webhook_deliveries
+    WHERE status = 'pending' AND next_attempt_at <= (now() AT TIME ZONE 'utc')
+    ORDER BY next_attempt_at, id
+    LIMIT $1
+    FOR UPDATE SKIP LOCKED
+)
+RETURNING `+deliveryColumns+`, w.url, w.secret;`, claim.Limit, claim.Lease.Seconds())
+}
+
+// PurgeDeliveries - succeeded and dead deliveries older than their age ('model.DeliveryPurge'),
+// pending ones are kept
+func (d *Dbinstance) PurgeDeliveries(ctx context.Context, data any) (int64, error) {
+	purge := data.(model.DeliveryPurge)
+	res, err := d.db.ExecContext(ctx, `
+DELETE FROM webhook_deliveries
+WHERE (status = $1 AND updated_at < (now() AT TIME ZONE 'utc') - make_interval(secs => $2))
+   OR (status = $3 AND updated_at < (now() AT TIME ZONE 'utc') - make_interval(secs => $4));`,
+		model.DeliverySucceeded, purge.Succeeded.Seconds(), model.DeliveryDead, purge.Dead.Seconds())
+	if err != nil {
+		return 0, classifyError(err)
+	}
+	removed, err := res.RowsAffected()
+	return removed, classifyError(err)
+}
+
+// FinishDelivery - result of attempt, delivery which is not pending (redelivered or deleted) is skipped
+func (d *Dbinstance) FinishDelivery(ctx context.Context, data any) error {
+	result := data.(model.DeliveryResult)
+	status, lastError := model.DeliveryPending, ""
+	switch {
+	case result.Err == nil:
+		status = model.DeliverySucceeded
+	case result.Dead:
+		status = model.DeliveryDead
+	}
+	if result.Err != nil {
+		lastError = result.Err.Error()
+		if runes := []rune(lastError); len(runes) > maxDeliveryError {
+			lastError = string(runes[:maxDeliveryError])
+		}
+	}
+	_, err := d.db.ExecContext(ctx, `
+UPDATE webhook_deliveries
+SET status = $2, attempts = attempts + 1, next_attempt_at = $3, last_status = $4, last_error = $5,
+    updated_at = (now() AT TIME ZONE 'utc')
+WHERE id = $1 AND status = 'pending';`,
+		result.ID,
+		status,
+		result.NextAttemptAt.UTC(),
+		result.Status,
+		emptyStringWriteNULL(lastError),
+	)
+	return classifyError(err)
+}
+
+func (d *Dbinstance) deliveries(ctx context.Context, withHook bool, query string, args ...any) ([]model.WebhookDelivery, error) {
+	rows, err := d.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, classifyError(err)
+	}
+	defer func() {
+		if err := rows.Close(); err != nil {
+			log.Printf("query: rows.Close error - %v", err)
+		}
+	}()
+	var deliveries []model.WebhookDelivery
+	for rows.Next() {
+		dl := model.WebhookDelivery{}
+		description, note, lastError := sql.NullString{}, sql.NullString{}, sql.NullString{}
+		createdAt, updatedAt, changedAt := sql.NullTime{}, sql.NullTime{}, sql.NullTime{}
+		dest := []any{
+			&dl.ID, &dl.WebhookID, &dl.Event.ID, &dl.Event.Type, &dl.Event.TaskID, &description, &note,
+			&createdAt, &updatedAt, &dl.Event.At,
+			&dl.Status, &dl.Attempts, &dl.NextAttemptAt, &dl.LastStatus, &lastError, &changedAt,
+		}
+		if withHook {
+			dest = append(dest, &dl.URL, &dl.Secret)
+		}
+		if err := rows.Scan(dest...); err != nil {
+			return nil, classifyError(err)
+		}
+		setEventTask(&dl.Event, description, note, createdAt, updatedAt)
+		dl.NextAttemptAt = dl.NextAttemptAt.UTC()
+		dl.LastError = lastError.String
+		if changedAt.Valid {
+			at := changedAt.Time.UTC()
+			dl.UpdatedAt = &at
+		}
+		deliveries = append(deliveries, dl)
+	}
+	return deliveries, classifyError(rows.Err())
+}
@@ -0,0 +1,207 @@
+// attachments - files of 'Task': upload of 'multipart/form-data', list, download with ranges and removal
+package transport
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log"
+	"mime"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+
+	"github.com/Ekvo/golang-chi-postgres-api/internal/blob"
+	"github.com/Ekvo/golang-chi-postgres-api/internal/model"
+	"github.com/Ekvo/golang-chi-postgres-api/internal/servises"
+	vr "github.com/Ekvo/golang-chi-postgres-api/internal/variables"
+	c "github.com/Ekvo/golang-chi-postgres-api/pkg/common"
+)
+
+// formOverhead - bytes of body of upload besides files (boundaries, headers of parts)
+const formOverhead = 1 << 20
+
+// attachmentRoutes - '/task/{id}/attachments', upload and download are streams without 'Timeout'
+func attachmentRoutes(db model.AttachmentStore, blobs blob.Store, maxSize int64) chi.Router {
+	r := chi.NewRouter()
+	r.Post("/", TaskHandler(db, attachmentUpload(blobs, maxSize)))
+	r.Get("/{aid}", AttachmentHandler(db, blobs))
+	r.Group(func(r chi.Router) {
+		r.Use(Timeout(timeOut))
+		r.Get("/", TaskHandler(db, attachmentList))
+		r.Delete("/{aid}", TaskHandler(db, attachmentRemove))
+	})
+	return r
+}
+
+// attachmentUpload - files of field "file" (look: servises.AttachmentReader), each file is written to 'blobs'
+// and then to the store, with error all files of upload are removed
+func attachmentUpload(blobs blob.Store, maxSize int64) taskFunc[model.AttachmentStore] {
+	return func(db model.AttachmentStore, r *http.Request) responseData {
+		id, err := strconv.Atoi(chi.URLParam(r, "id"))
+		if err != nil {
+			return errorData(http.StatusBadRequest, vr.Params, ErrTransportParam)
+		}
+		// body of unknown 'Task' is not read
+		if _, err := db.TaskAttachments(r.Context(), uint(id)); err != nil {
+			return storeErrorData(vr.Task, err)
+		}
+		r.Body = http.MaxBytesReader(nil, r.Body, maxSize*servises.MaxAttachments+formOverhead)
+		uploads, err := servises.NewAttachmentReader(maxSize).Read(r)
+		if err != nil {
+			return uploadErrorData(err)
+		}
+		defer func() {
+			for _, upload := range uploads {
+				if err := upload.Close(); err != nil {
+					log.Printf("transport: upload.Close error - %v", err)
+				}
+			}
+		}()
+		saved := make([]model.Attachment, 0, len(uploads))
+		for _, upload := range uploads {
+			attachment, err := saveUpload(r, db, blobs, uint(id), upload)
+			if err != nil {
+				removeAttachments(r, db, saved)
+				return storeErrorData(vr.Attachment, err)
+			}
+			saved = append(saved, attachment)
+		}
+		return responseData{http.StatusCreated, c.Message{vr.Attachments: servises.NewAttachmentListResponse(saved)}}
+	}
+}
+
+// saveUpload - blob with new key and then 'model.Attachment', blob is removed if the store fails
+func saveUpload(r *http.Request, db model.AttachmentStore, blobs blob.Store, taskID uint,
+	upload *servises.Upload) (model.Attachment, error) {
+	key, err := attachmentKey(taskID)
+	if err != nil {
+		return model.Attachment{}, err
+	}
+	info := blob.Info{Size: upload.Size, ContentType: upload.ContentType, SHA256: upload.SHA256}
+	if err := blobs.Put(r.Context(), key, upload.File, info); err != nil {
+		return model.Attachment{}, err
+	}
+	attachment := upload.AttachmentModel()
+	attachment.TaskID = taskID
+	attachment.Key = key
+	attachment.User = User(r.Context())
+	attachment.CreatedAt = time.Now().UTC()
+	attachment, err = db.SaveAttachment(r.Context(), attachment)
+	if err != nil {
+		if errDelete := blobs.Delete(r.Context(), key); errDelete != nil {
+			log.Printf("transport: blob %s of unsaved attachment is left - %v", key, errDelete)
+		}
+	}
+	return attachment, err
+}
+
+// removeAttachments - attachments saved before error of upload, their blobs are removed by cleanup job
+func removeAttachments(r *http.Request, db model.AttachmentStore, attachments []model.Attachment) {
+	for _, attachment := range attachments {
+		ref := model.AttachmentRef{TaskID: attachment.TaskID, ID: attachment.ID}
+		if err := db.DeleteAttachment(r.Context(), ref); err != nil {
+			log.Printf("transport: delete of attachment %d error - %v", attachment.ID, err)
+		}
+	}
+}
+
+// attachmentKey - "tasks/{id}/{random}", new content - new key
+func attachmentKey(taskID uint) (string, error) {
+	random := make([]byte, 16)
+	if _, err := rand.Read(random); err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("tasks/%d/%s", taskID, hex.EncodeToString(random)), nil
+}
+
+// uploadErrorData - 413 for size of file or body, 415 for type of file, others 400
+func uploadErrorData(err error) responseData {
+	switch {
+	case errors.Is(err, servises.ErrservisesAttachmentSize):
+		return errorData(http.StatusRequestEntityTooLarge, vr.Attachment, err)
+	case errors.Is(err, servises.ErrservisesAttachmentType):
+		return errorData(http.StatusUnsupportedMediaType, vr.Attachment, err)
+	case errors.Is(err, servises.ErrservisesAttachmentForm):
+		return errorData(http.StatusBadRequest, vr.Attachment, err)
+	}
+	return errorData(http.StatusInternalServerError, vr.Attachment, err)
+}
+
+func attachmentList(db model.AttachmentStore, r *http.Request) responseData {
+	id, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		return errorData(http.StatusBadRequest, vr.Params, ErrTransportParam)
+	}
+	attachments, err := db.TaskAttachments(r.Context(), uint(id))
+	if err != nil {
+		return storeErrorData(vr.Attachments, err)
+	}
+	return responseData{http.StatusOK, c.Message{vr.Attachments: servises.NewAttachmentListResponse(attachments)}}
+}
+
+// attachmentRemove - row is removed at once, blob - by job 'model.KindBlobCleanup'
+func attachmentRemove(db model.AttachmentStore, r *http.Request) responseData {
+	ref, err := attachmentRef(r)
+	if err != nil {
+		return errorData(http.StatusBadRequest, vr.Params, err)
+	}
+	if err := db.DeleteAttachment(r.Context(), ref); err != nil {
+		return storeErrorData(vr.Attachment, err)
+	}
+	return responseData{http.StatusOK, c.Message{vr.Attachment: "deleted"}}
+}
+
+// AttachmentHandler - 'GET /task/{id}/attachments/{aid}' content of file (works without 'Timeout')
+//
+// 'Range', 'If-Range' and 'If-None-Match' are served by 'http.ServeContent', ETag - checksum of content
+func AttachmentHandler(db model.AttachmentStore, blobs blob.Store) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ref, err := attachmentRef(r)
+		if err != nil {
+			writeError(w, r, http.StatusBadRequest, taskError{key: vr.Params, err: err})
+			return
+		}
+		attachment, err := db.FindAttachment(r.Context(), ref)
+		if err != nil {
+			writeError(w, r, storeStatus(err), taskError{key: vr.Attachment, err: err})
+			return
+		}
+		content, err := blobs.Open(r.Context(), attachment.Key, attachment.Size)
+		if err != nil {
+			status := http.StatusInternalServerError
+			if errors.Is(err, blob.ErrBlobNotFound) {
+				status = http.StatusNotFound
+			}
+			writeError(w, r, status, taskError{key: vr.Attachment, err: err})
+			return
+		}
+		defer func() {
+			if err := content.Close(); err != nil {
+				log.Printf("transport: blob Close error - %v", err)
+			}
+		}()
+		w.Header().Set("Content-Type", attachment.ContentType)
+		w.Header().Set("Content-Disposition",
+			mime.FormatMediaType("attachment", map[string]string{"filename": attachment.Name}))
+		w.Header().Set("X-Content-Type-Options", "nosniff")
+		w.Header().Set("ETag", `"`+attachment.SHA256+`"`)
+		http.ServeContent(w, r, "", attachment.CreatedAt, content)
+	}
+}
+
+// attachmentRef - '{id}' of 'Task' and '{aid}' of 'Attachment' from path
+func attachmentRef(r *http.Request) (model.AttachmentRef, error) {
+	id, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		return model.AttachmentRef{}, ErrTransportParam
+	}
+	aid, err := strconv.ParseUint(chi.URLParam(r, "aid"), 10, 64)
+	if err != nil {
+		return model.AttachmentRef{}, ErrTransportParam
+	}
+	return model.AttachmentRef{TaskID: uint(id), ID: aid}, nil
+}
@@ -0,0 +1,59 @@
+// board - columns of 'Task' ordered by rank keys (look: package rank)
+package transport
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/go-chi/chi/v5"
+
+	"github.com/Ekvo/golang-chi-postgres-api/internal/rank"
+	"github.com/Ekvo/golang-chi-postgres-api/internal/servises"
+	vr "github.com/Ekvo/golang-chi-postgres-api/internal/variables"
+	c "github.com/Ekvo/golang-chi-postgres-api/pkg/common"
+)
+
+// taskMove - 'POST /task/{id}/move' to column 'status' after 'after' and/or before 'before',
+// without anchors - to the end of column, only row of 'Task' is changed
+func taskMove(db taskFindUpdate, r *http.Request) responseData {
+	id, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		return errorData(http.StatusBadRequest, vr.Params, ErrTransportParam)
+	}
+	moveValidator := servises.NewMoveValidator()
+	if err := moveValidator.Decode(r, uint(id)); err != nil {
+		return decodeErrorData(err)
+	}
+	move := moveValidator.TaskMoveModel()
+	move.Rank, move.Keys = rank.Between, rank.Spread
+	task, err := db.MoveTask(r.Context(), move)
+	if err != nil {
+		return storeErrorData(vr.Task, err)
+	}
+	return responseData{http.StatusOK, c.Message{vr.Task: servises.NewBoardTaskResponse(task)}}
+}
+
+// taskBoard - 'GET /task/board' columns of 'Task' by filters of 'GET /task/' ('status' - one column),
+// 'limit' is 'maxSearchLimit' by default
+func taskBoard(db taskFindUpdate, r *http.Request) responseData {
+	schema, err := db.CustomFields(r.Context())
+	if err != nil {
+		return storeErrorData(vr.CustomFields, err)
+	}
+	filter, err := taskFilter(r, schema)
+	if err != nil {
+		return errorData(http.StatusBadRequest, vr.Params, err)
+	}
+	filter.Board = true
+	if filter.Limit == 0 {
+		filter.Limit = maxSearchLimit
+	}
+	if filter.Limit > maxSearchLimit {
+		return errorData(http.StatusBadRequest, vr.Params, errSearchLimit)
+	}
+	tasks, err := searchTasks(r, db, filter)
+	if err != nil {
+		return storeErrorData(vr.Board, err)
+	}
+	return responseData{http.StatusOK, c.Message{vr.Board: servises.NewBoardResponse(tasks)}}
+}
@@ -0,0 +1,92 @@
+// calendar - iCalendar feed of 'Task' with due time for calendar clients
+package transport
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/Ekvo/golang-chi-postgres-api/internal/servises"
+	vr "github.com/Ekvo/golang-chi-postgres-api/internal/variables"
+	c "github.com/Ekvo/golang-chi-postgres-api/pkg/common"
+)
+
+// calendarFeedPath - route of feed, it is outside of 'Authenticate' - clients of calendar can not set headers
+const calendarFeedPath = "/task/calendar.ics"
+
+// ErrTransportFeedToken - token of feed is missing, forged or of unknown user
+var ErrTransportFeedToken = errors.New("invalid feed token")
+
+// FeedToken - secret token of calendar feed of 'user': "<user>.<HMAC-SHA256(secret, user)>"
+//
+// token is not stored, new 'secret' revokes all tokens
+func FeedToken(secret, user string) string {
+	return user + "." + feedMAC(secret, user)
+}
+
+func feedMAC(secret, user string) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte("calendar\x00" + user))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+// userOfFeedToken - user of valid token, with 'users' (authentication is on) user must be one of them
+func userOfFeedToken(secret, token string, users map[string]bool) (string, bool) {
+	i := strings.LastIndexByte(token, '.')
+	if i < 1 {
+		return "", false
+	}
+	user := token[:i]
+	if !hmac.Equal([]byte(token[i+1:]), []byte(feedMAC(secret, user))) {
+		return "", false
+	}
+	return user, len(users) == 0 || users[user]
+}
+
+// CalendarHandler - 'GET /task/calendar.ics?token=' feed of 'Task' with due time (works without 'Timeout')
+//
+// params of 'servises.NewTaskFilter' ('order', 'limit', 'offset' ...) and 'component' ("VEVENT" - default, "VTODO")
+func CalendarHandler(db taskFindUpdate, secret string, keys map[string]string) http.HandlerFunc {
+	users := map[string]bool{}
+	for _, user := range keys {
+		users[user] = true
+	}
+	return func(w http.ResponseWriter, r *http.Request) {
+		if _, ok := userOfFeedToken(secret, r.URL.Query().Get("token"), users); !ok {
+			writeError(w, r, http.StatusUnauthorized, taskError{key: vr.Auth, err: ErrTransportFeedToken})
+			return
+		}
+		component := strings.ToUpper(r.URL.Query().Get("component"))
+		writer, err := servises.NewCalendarWriter(w, component, time.Now())
+		if err != nil {
+			writeError(w, r, http.StatusBadRequest, taskError{key: vr.Params, err: err})
+			return
+		}
+		filter, err := servises.NewTaskFilter(r.URL.Query())
+		if err != nil {
+			writeError(w, r, http.StatusBadRequest, taskError{key: vr.Params, err: err})
+			return
+		}
+		filter.DueOnly = true
+
+		streamTasks(w, r, db, writer, filter, "inline")
+	}
+}
+
+// calendarToken - token and URL of feed of user of Request, URL is relative to the server
+func calendarToken(secret string) taskFunc[taskFindUpdate] {
+	return func(_ taskFindUpdate, r *http.Request) responseData {
+		user := User(r.Context())
+		token := FeedToken(secret, user)
+		return responseData{http.StatusOK, c.Message{vr.CalendarFeed: servises.CalendarFeedResponse{
+			User:  user,
+			Token: token,
+			URL:   calendarFeedPath + "?token=" + url.QueryEscape(token),
+		}}}
+	}
+}
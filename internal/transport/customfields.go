@@ -0,0 +1,125 @@
+// customfields - registry of custom fields of 'Task' and search of 'Task' by them
+package transport
+
+import (
+	"net/http"
+
+	"github.com/go-chi/chi/v5"
+
+	"github.com/Ekvo/golang-chi-postgres-api/internal/model"
+	"github.com/Ekvo/golang-chi-postgres-api/internal/servises"
+	vr "github.com/Ekvo/golang-chi-postgres-api/internal/variables"
+	c "github.com/Ekvo/golang-chi-postgres-api/pkg/common"
+)
+
+// search of 'GET /task/', 'limit' by default and the largest one
+const (
+	searchLimit    = 100
+	maxSearchLimit = 1000
+)
+
+// customFieldRoutes - '/fields', schema is read by all users, it is changed only by 'admin'
+func customFieldRoutes(db model.CustomFieldStore, admin func(next http.Handler) http.Handler) chi.Router {
+	r := chi.NewRouter()
+	r.Use(Timeout(timeOut))
+	r.Get("/", TaskHandler(db, customFieldList))
+	r.Group(func(r chi.Router) {
+		r.Use(admin)
+		r.Put("/{name}", TaskHandler(db, customFieldSave))
+		r.Delete("/{name}", TaskHandler(db, customFieldRemove))
+	})
+	return r
+}
+
+func customFieldList(db model.CustomFieldStore, r *http.Request) responseData {
+	fields, err := db.CustomFields(r.Context())
+	if err != nil {
+		return storeErrorData(vr.CustomFields, err)
+	}
+	return responseData{http.StatusOK, c.Message{vr.CustomFields: servises.NewCustomFieldListResponse(fields)}}
+}
+
+// customFieldSave - create (201) or replace (200) field, values of 'Task' are checked by it on next write
+func customFieldSave(db model.CustomFieldStore, r *http.Request) responseData {
+	fieldValidator := servises.NewCustomFieldValidator()
+	if err := fieldValidator.Decode(r, chi.URLParam(r, "name")); err != nil {
+		return decodeErrorData(err)
+	}
+	field, err := db.SaveCustomField(r.Context(), fieldValidator.CustomFieldModel())
+	if err != nil {
+		return storeErrorData(vr.CustomField, err)
+	}
+	status := http.StatusOK
+	if field.UpdatedAt == nil {
+		status = http.StatusCreated
+	}
+	return responseData{status, c.Message{vr.CustomField: servises.NewCustomFieldResponse(field)}}
+}
+
+func customFieldRemove(db model.CustomFieldStore, r *http.Request) responseData {
+	if err := db.DeleteCustomField(r.Context(), chi.URLParam(r, "name")); err != nil {
+		return storeErrorData(vr.CustomField, err)
+	}
+	return responseData{http.StatusOK, c.Message{vr.CustomField: "deleted"}}
+}
+
+// taskSearch - 'GET /task/' Task of filter of export and of custom fields ("cf.component=api"),
+// 'limit' - from 1 to 'maxSearchLimit' ('searchLimit' by default)
+func taskSearch(db taskFindUpdate, r *http.Request) responseData {
+	schema, err := db.CustomFields(r.Context())
+	if err != nil {
+		return storeErrorData(vr.CustomFields, err)
+	}
+	filter, err := taskFilter(r, schema)
+	if err != nil {
+		return errorData(http.StatusBadRequest, vr.Params, err)
+	}
+	return findTasks(db, r, filter)
+}
+
+// findTasks - body of list of 'Task' of 'filter' with their totals of time
+func findTasks(db taskFindUpdate, r *http.Request, filter model.TaskFilter) responseData {
+	if filter.Limit == 0 {
+		filter.Limit = searchLimit
+	}
+	if filter.Limit > maxSearchLimit {
+		return errorData(http.StatusBadRequest, vr.Params, errSearchLimit)
+	}
+	tasks, err := searchTasks(r, db, filter)
+	if err != nil {
+		return storeErrorData(vr.TaskList, err)
+	}
+	ids := make([]uint, len(tasks))
+	for i, task := range tasks {
+		ids[i] = task.ID
+	}
+	tracked, err := timeTracked(r, db, ids)
+	if err != nil {
+		return storeErrorData(vr.TimeEntries, err)
+	}
+	serialize := servises.TaskListSerializer{Tasks: tasks, Tracked: tracked}
+	return responseData{http.StatusOK, c.Message{vr.TaskList: serialize.Response()}}
+}
+
+// errSearchLimit - 'limit' of search is more than 'maxSearchLimit'
+var errSearchLimit = servises.ValidationErrors{{Name: "limit", Reason: "must be number from 1 to 1000"}}
+
+// searchTasks - all 'Task' of 'filter', limit is checked before
+func searchTasks(r *http.Request, db model.TaskExporter, filter model.TaskFilter) ([]model.Task, error) {
+	tasks := []model.Task{}
+	_, err := db.ExportTasks(r.Context(), model.TaskExport{Filter: filter, Each: func(task model.Task) error {
+		tasks = append(tasks, task)
+		return nil
+	}})
+	return tasks, err
+}
+
+// taskFilter - 'model.TaskFilter' of params of Request with filter of custom fields of 'schema'
+func taskFilter(r *http.Request, schema []model.CustomField) (model.TaskFilter, error) {
+	filter, err := servises.NewTaskFilter(r.URL.Query())
+	if err != nil {
+		return filter, err
+	}
+	filter.CustomFields, err = servises.NewCustomFieldFilter(r.URL.Query(), schema)
+	return filter, err
+}
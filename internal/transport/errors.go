@@ -0,0 +1,110 @@
+// errors - central mapping of errors to the response body
+package transport
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/Ekvo/golang-chi-postgres-api/internal/ratelimit"
+	"github.com/Ekvo/golang-chi-postgres-api/internal/shed"
+	"github.com/Ekvo/golang-chi-postgres-api/internal/source"
+	vr "github.com/Ekvo/golang-chi-postgres-api/internal/variables"
+	c "github.com/Ekvo/golang-chi-postgres-api/pkg/common"
+)
+
+// taskError - body of 'responseData' with error, written by 'writeError'
+type taskError struct {
+	key string
+	err error
+}
+
+// errorData - response with error and the given status
+func errorData(status int, key string, err error) responseData {
+	return responseData{status, taskError{key: key, err: err}}
+}
+
+// storeErrorData - response with error from the store
+//
+// status is taken from the taxonomy of 'source' (look ~> ../source/errors.go), unknown error - 500
+func storeErrorData(key string, err error) responseData {
+	return errorData(storeStatus(err), key, err)
+}
+
+// storeStatus - HTTP status of error from the store
+func storeStatus(err error) int {
+	switch {
+	case errors.Is(err, source.ErrSourceNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, source.ErrSourceConflict):
+		return http.StatusConflict
+	case errors.Is(err, source.ErrSourceValidation):
+		return http.StatusUnprocessableEntity
+	case errors.Is(err, source.ErrSourceUnavailable):
+		return http.StatusServiceUnavailable
+	}
+	return http.StatusInternalServerError
+}
+
+// problemType - 'type' of 'Problem' by error, "about:blank" if error is unknown
+func problemType(status int, err error) string {
+	switch {
+	case errors.Is(err, ErrTransportParam):
+		return vr.ProblemInvalidParams
+	case errors.Is(err, ErrTransportUnauthorized):
+		return vr.ProblemUnauthorized
+	case errors.Is(err, ErrTransportForbidden):
+		return vr.ProblemForbidden
+	case errors.Is(err, ErrTransportRateLimited), errors.Is(err, ratelimit.ErrQuotaExceeded):
+		return vr.ProblemTooMany
+	case errors.Is(err, source.ErrSourceNotFound):
+		return vr.ProblemNotFound
+	case errors.Is(err, source.ErrSourceConflict), errors.Is(err, c.ErrCommonPatchTest),
+		errors.Is(err, ErrTransportIdempotencyInFlight):
+		return vr.ProblemConflict
+	case errors.Is(err, source.ErrSourceValidation):
+		return vr.ProblemValidation
+	case errors.Is(err, source.ErrSourceUnavailable), errors.Is(err, shed.ErrShed):
+		return vr.ProblemUnavailable
+	case status == http.StatusUnprocessableEntity:
+		return vr.ProblemValidation
+	}
+	return vr.ProblemDefault
+}
+
+// invalidParamser - error with field-level reasons
+type invalidParamser interface {
+	InvalidParams() []c.InvalidParam
+}
+
+// decodeErrorData - response with error of body of Request, 415 for unknown 'Content-Type', others 422
+func decodeErrorData(err error) responseData {
+	if errors.Is(err, c.ErrCommonInvalidMedia) {
+		return errorData(http.StatusUnsupportedMediaType, vr.Validator, err)
+	}
+	return errorData(http.StatusUnprocessableEntity, vr.Validator, err)
+}
+
+// writeError - body of error in format from context (look ErrorFormat),
+// media type by 'Accept' (look ~> ../../pkg/common/codec.go), JSON if 'Accept' is not supported
+func writeError(w http.ResponseWriter, r *http.Request, status int, te taskError) {
+	cd, _ := c.NegotiateCodec(r.Header.Get("Accept"))
+	var params invalidParamser
+	hasParams := errors.As(te.err, &params)
+	if errors.Is(te.err, ratelimit.ErrQuotaExceeded) {
+		w.Header().Set("Retry-After", seconds(ratelimit.FromContext(r.Context()).RetryAfter()))
+	}
+	if errorFormat(r.Context()) == vr.ErrorFormatLegacy {
+		err := te.err
+		// legacy body is kept as before field-level reasons, they are only in 'problem+json'
+		if e, ok := params.(error); hasParams && ok && errors.Unwrap(e) != nil {
+			err = errors.Unwrap(e)
+		}
+		c.Encode(w, cd, status, c.NewMessageError(te.key, err))
+		return
+	}
+	problem := c.NewProblem(problemType(status, te.err), status, te.err.Error(), r.URL.Path)
+	if hasParams {
+		problem.InvalidParams = params.InvalidParams()
+	}
+	c.Encode(w, cd, status, problem)
+}
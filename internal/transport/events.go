@@ -0,0 +1,155 @@
+// events - stream of changes of 'Task' as Server-Sent Events
+package transport
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Ekvo/golang-chi-postgres-api/internal/events"
+	"github.com/Ekvo/golang-chi-postgres-api/internal/model"
+	"github.com/Ekvo/golang-chi-postgres-api/internal/servises"
+	"github.com/Ekvo/golang-chi-postgres-api/internal/source"
+	vr "github.com/Ekvo/golang-chi-postgres-api/internal/variables"
+)
+
+// parameters of stream of events
+const (
+	// sseRetry - delay of reconnect of client ('retry' of SSE)
+	sseRetry = 3 * time.Second
+	// sseHeartbeat - comment for proxies when there are no events
+	sseHeartbeat = 15 * time.Second
+)
+
+// MediaEventStream - media type of Server-Sent Events
+const MediaEventStream = "text/event-stream"
+
+// EventsHandler - 'GET /task/events' (works without 'Timeout')
+//
+// each event: "id" - cursor of event ("tx-id", look: model.EventCursor), "event" - created|updated|deleted,
+// "data" - 'TaskEventResponse'
+// header 'Last-Event-ID' - events after it are sent first (from store),
+// if they are gone (or id is a number of old stream) - event "reset" (client must read tasks again) and stream goes on
+func EventsHandler(db taskFindUpdate, hub *events.Hub) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var last model.EventCursor
+		resume, reset := false, false
+		if v := r.Header.Get("Last-Event-ID"); v != "" {
+			cursor, err := parseEventCursor(v)
+			switch {
+			case err == nil:
+				last, resume = cursor, true
+			case isNumber(v):
+				reset = true
+			default:
+				writeError(w, r, http.StatusBadRequest, taskError{key: vr.Params, err: ErrTransportParam})
+				return
+			}
+		}
+		// subscription before reading of store - no event between them is lost
+		sub := hub.Subscribe()
+		defer sub.Close()
+
+		var replay []model.TaskEvent
+		if resume {
+			var err error
+			replay, err = db.TaskEventsSince(r.Context(), last)
+			if errors.Is(err, source.ErrSourceEventsGone) {
+				reset = true
+			} else if err != nil {
+				storeErr := storeErrorData(vr.DataBase, err)
+				writeError(w, r, storeErr.status, storeErr.body.(taskError))
+				return
+			}
+		}
+
+		rc := http.NewResponseController(w)
+		w.Header().Set("Content-Type", MediaEventStream)
+		w.Header().Set("Cache-Control", "no-cache")
+		w.Header().Set("X-Accel-Buffering", "no")
+		w.WriteHeader(http.StatusOK)
+		if _, err := fmt.Fprintf(w, "retry: %d\n\n", sseRetry.Milliseconds()); err != nil {
+			return
+		}
+		if reset {
+			if _, err := io.WriteString(w, "event: reset\ndata: {}\n\n"); err != nil {
+				return
+			}
+		}
+		for _, ev := range replay {
+			if err := writeEvent(w, ev); err != nil {
+				return
+			}
+			last = ev.Cursor()
+		}
+		if err := rc.Flush(); err != nil {
+			return
+		}
+
+		heartbeat := time.NewTicker(sseHeartbeat)
+		defer heartbeat.Stop()
+		for {
+			select {
+			case <-r.Context().Done():
+				return
+			case ev, ok := <-sub.Events():
+				// closed - server stops or client is too slow, client comes back with 'Last-Event-ID'
+				if !ok {
+					return
+				}
+				// event of store is sent again by hub
+				if !last.Less(ev.Cursor()) {
+					continue
+				}
+				if err := writeEvent(w, ev); err != nil {
+					return
+				}
+				last = ev.Cursor()
+			case <-heartbeat.C:
+				if _, err := io.WriteString(w, ": ping\n\n"); err != nil {
+					return
+				}
+			}
+			if err := rc.Flush(); err != nil {
+				return
+			}
+		}
+	}
+}
+
+func writeEvent(w io.Writer, ev model.TaskEvent) error {
+	serialize := servises.TaskEventSerializer{TaskEvent: ev}
+	data, err := json.Marshal(serialize.Response())
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintf(w, "id: %s\nevent: %s\ndata: %s\n\n", ev.Cursor(), ev.Type, data)
+	return err
+}
+
+// parseEventCursor - "tx-id" of 'model.EventCursor.String'
+func parseEventCursor(v string) (model.EventCursor, error) {
+	tx, id, ok := strings.Cut(v, "-")
+	if !ok {
+		return model.EventCursor{}, ErrTransportParam
+	}
+	cursor := model.EventCursor{}
+	var err error
+	if cursor.Tx, err = strconv.ParseUint(tx, 10, 64); err != nil {
+		return model.EventCursor{}, ErrTransportParam
+	}
+	if cursor.ID, err = strconv.ParseUint(id, 10, 64); err != nil {
+		return model.EventCursor{}, ErrTransportParam
+	}
+	return cursor, nil
+}
+
+func isNumber(v string) bool {
+	_, err := strconv.ParseUint(v, 10, 64)
+	return err == nil
+}
@@ -0,0 +1,111 @@
+// export - stream of many 'Task' directly to 'http.ResponseWriter'
+package transport
+
+import (
+	"log"
+	"mime"
+	"net/http"
+
+	"github.com/Ekvo/golang-chi-postgres-api/internal/model"
+	"github.com/Ekvo/golang-chi-postgres-api/internal/servises"
+	vr "github.com/Ekvo/golang-chi-postgres-api/internal/variables"
+)
+
+// exportFlush - rows between flushes of 'http.ResponseWriter'
+const exportFlush = 100
+
+// ExportHandler - 'GET /task/export?format=csv|ndjson|json' (works without 'Timeout')
+//
+// rows are written as they are read from the store (look: streamTasks)
+func ExportHandler(db taskFindUpdate) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		format := r.URL.Query().Get("format")
+		if format == "" {
+			format = servises.ExportJSON
+		}
+		writer, err := servises.NewTaskExportWriter(w, format)
+		if err != nil {
+			writeError(w, r, http.StatusBadRequest, taskError{key: vr.Params, err: err})
+			return
+		}
+		schema, err := db.CustomFields(r.Context())
+		if err != nil {
+			storeErr := storeErrorData(vr.CustomFields, err)
+			writeError(w, r, storeErr.status, storeErr.body.(taskError))
+			return
+		}
+		filter, err := taskFilter(r, schema)
+		if err != nil {
+			writeError(w, r, http.StatusBadRequest, taskError{key: vr.Params, err: err})
+			return
+		}
+
+		streamTasks(w, r, db, writer, filter, "attachment")
+	}
+}
+
+// taskWriter - format of stream of 'Task' (servises.TaskExportWriter, servises.CalendarWriter)
+type taskWriter interface {
+	ContentType() string
+	FileName() string
+	Begin() error
+	Write(task model.Task) error
+	Flush() error
+	End() error
+}
+
+// streamTasks - 'Task' of 'filter' to 'writer' as they are read from the store
+//
+// error before first row - error body, after first row - connection is aborted
+func streamTasks(w http.ResponseWriter, r *http.Request, db taskFindUpdate, writer taskWriter,
+	filter model.TaskFilter, disposition string) {
+	rc := http.NewResponseController(w)
+	started := false
+	start := func() error {
+		started = true
+		w.Header().Set("Content-Type", writer.ContentType())
+		w.Header().Set("Content-Disposition",
+			mime.FormatMediaType(disposition, map[string]string{"filename": writer.FileName()}))
+		w.WriteHeader(http.StatusOK)
+		return writer.Begin()
+	}
+	rows := 0
+	each := func(task model.Task) error {
+		if !started {
+			if err := start(); err != nil {
+				return err
+			}
+		}
+		if err := writer.Write(task); err != nil {
+			return err
+		}
+		if rows++; rows%exportFlush == 0 {
+			if err := writer.Flush(); err != nil {
+				return err
+			}
+			return rc.Flush()
+		}
+		return nil
+	}
+
+	_, err := db.ExportTasks(r.Context(), model.TaskExport{Filter: filter, Each: each})
+	if err != nil && !started {
+		storeErr := storeErrorData(vr.DataBase, err)
+		writeError(w, r, storeErr.status, storeErr.body.(taskError))
+		return
+	}
+	if err != nil {
+		log.Printf("transport: export stopped after %d rows - %v", rows, err)
+		panic(http.ErrAbortHandler)
+	}
+	// empty export is a valid file too
+	if !started {
+		if err := start(); err != nil {
+			log.Printf("transport: export error - %v", err)
+			return
+		}
+	}
+	if err := writer.End(); err != nil {
+		log.Printf("transport: export error - %v", err)
+	}
+}
@@ -2,9 +2,20 @@
 package transport
 
 import (
+	"bytes"
 	"context"
+	"crypto/subtle"
+	"errors"
+	"fmt"
+	"io"
+	"log"
 	"net/http"
+	"strings"
 	"time"
+
+	"github.com/Ekvo/golang-chi-postgres-api/internal/idempotency"
+	"github.com/Ekvo/golang-chi-postgres-api/internal/model"
+	vr "github.com/Ekvo/golang-chi-postgres-api/internal/variables"
 )
 
 // Timeout - middleware
@@ -25,3 +36,225 @@ func Timeout(timeout time.Duration) func(next http.Handler) http.Handler {
 		})
 	}
 }
+
+// StreamEnd - middleware
+// context of request is canceled when 'done' is canceled (start of shutdown),
+// so streams without 'Timeout' end and http.Server does not wait for them
+func StreamEnd(done context.Context) func(next http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			ctx, cancel := context.WithCancel(r.Context())
+			defer cancel()
+			stop := context.AfterFunc(done, cancel)
+			defer stop()
+
+			r = r.WithContext(ctx)
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+type errorFormatKey struct{}
+
+// ErrorFormat - middleware
+// sets format of error body for handlers: "problem" or "legacy"
+func ErrorFormat(format string) func(next http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			ctx := context.WithValue(r.Context(), errorFormatKey{}, format)
+			next.ServeHTTP(w, r.WithContext(ctx))
+		})
+	}
+}
+
+// errorFormat - "problem" if 'ErrorFormat' was not used
+func errorFormat(ctx context.Context) string {
+	if format, ok := ctx.Value(errorFormatKey{}).(string); ok && format != "" {
+		return format
+	}
+	return vr.ErrorFormatProblem
+}
+
+// ErrTransportUnauthorized - no key or unknown key of 'Authenticate'
+var ErrTransportUnauthorized = errors.New("unauthorized")
+
+// Anonymous - user of Request when 'Authenticate' is off
+const Anonymous = "anonymous"
+
+type userKey struct{}
+
+// Authenticate - middleware
+// key from 'Authorization: Bearer <key>' or param 'access_token' (WebSocket of browser can not set headers)
+// is found in 'keys' (key -> user) and user is set in context, unknown key - 401
+// empty 'keys' - authentication is off, user is 'Anonymous'
+func Authenticate(keys map[string]string) func(next http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			user := Anonymous
+			if len(keys) > 0 {
+				var ok bool
+				if user, ok = userOfKey(keys, requestKey(r)); !ok {
+					w.Header().Set("WWW-Authenticate", "Bearer")
+					writeError(w, r, http.StatusUnauthorized, taskError{key: vr.Auth, err: ErrTransportUnauthorized})
+					return
+				}
+			}
+			ctx := context.WithValue(r.Context(), userKey{}, user)
+			next.ServeHTTP(w, r.WithContext(ctx))
+		})
+	}
+}
+
+// ErrTransportForbidden - user of 'Authenticate' is not admin (look: Admin)
+var ErrTransportForbidden = errors.New("forbidden")
+
+// Admin - middleware, only users of 'admins' pass, others - 403
+// without 'keys' authentication is off and everyone is admin
+func Admin(keys map[string]string, admins map[string]bool) func(next http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if len(keys) > 0 && !admins[User(r.Context())] {
+				writeError(w, r, http.StatusForbidden, taskError{key: vr.Auth, err: ErrTransportForbidden})
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// User - user of Request set by 'Authenticate', 'Anonymous' if it was not used
+func User(ctx context.Context) string {
+	if user, ok := ctx.Value(userKey{}).(string); ok {
+		return user
+	}
+	return Anonymous
+}
+
+func requestKey(r *http.Request) string {
+	if auth := r.Header.Get("Authorization"); auth != "" {
+		scheme, key, ok := strings.Cut(auth, " ")
+		if ok && strings.EqualFold(scheme, "Bearer") {
+			return strings.TrimSpace(key)
+		}
+		return ""
+	}
+	return r.URL.Query().Get("access_token")
+}
+
+// userOfKey - compare of all keys in constant time
+func userOfKey(keys map[string]string, key string) (string, bool) {
+	found := ""
+	for k, user := range keys {
+		if subtle.ConstantTimeCompare([]byte(k), []byte(key)) == 1 {
+			found = user
+		}
+	}
+	return found, found != "" && key != ""
+}
+
+var (
+	// ErrTransportIdempotencyMismatch - 'Idempotency-Key' is reused with other method, URI or body
+	ErrTransportIdempotencyMismatch = errors.New("idempotency key is used by other request")
+
+	// ErrTransportIdempotencyInFlight - request with the same 'Idempotency-Key' is not finished
+	ErrTransportIdempotencyInFlight = errors.New("request of idempotency key is in progress")
+
+	// ErrTransportBodyTooLarge - body of request with 'Idempotency-Key' is longer than 'maxIdempotentBody'
+	ErrTransportBodyTooLarge = errors.New("body of request is too large")
+)
+
+const (
+	// maxIdempotentBody - body is read before handler for fingerprint of request
+	maxIdempotentBody = 1 << 20
+
+	// idempotencyLease - request in flight which is longer (replica is stopped) is abandoned, key is claimed again
+	idempotencyLease = 6 * timeOut
+
+	// afterRequestTimeout - changes of store after handler (key, quota), context of request may be done
+	afterRequestTimeout = 5 * time.Second
+)
+
+// Idempotency - middleware of POST with 'Idempotency-Key' of user of 'Authenticate'
+//
+// the first request is claimed and its response (status, headers, body) is kept for 'ttl',
+// retry with the same key gets kept response, key with other request - 422, request in flight - 409,
+// response 5xx is not kept - retry runs request again, timeout - key is released after 'idempotencyLease'
+func Idempotency(db model.IdempotencyStore, ttl time.Duration) func(next http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			key := r.Header.Get(idempotency.Header)
+			if r.Method != http.MethodPost || key == "" {
+				next.ServeHTTP(w, r)
+				return
+			}
+			if !idempotency.ValidKey(key) {
+				writeError(w, r, http.StatusBadRequest, taskError{
+					key: vr.Idempotency,
+					err: fmt.Errorf("%w - %v", ErrTransportParam, idempotency.ErrIdempotencyKey),
+				})
+				return
+			}
+			body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxIdempotentBody))
+			if err != nil {
+				var tooLarge *http.MaxBytesError
+				if errors.As(err, &tooLarge) {
+					writeError(w, r, http.StatusRequestEntityTooLarge, taskError{key: vr.Idempotency, err: ErrTransportBodyTooLarge})
+					return
+				}
+				writeError(w, r, http.StatusBadRequest, taskError{key: vr.Idempotency, err: err})
+				return
+			}
+			r.Body = io.NopCloser(bytes.NewReader(body))
+			record, claimed, err := db.ClaimIdempotencyKey(r.Context(), model.IdempotencyClaim{
+				User:        User(r.Context()),
+				Key:         key,
+				Fingerprint: idempotency.Fingerprint(r.Method, r.URL.RequestURI(), body),
+				TTL:         ttl,
+				Lease:       idempotencyLease,
+			})
+			if err != nil {
+				writeError(w, r, storeStatus(err), taskError{key: vr.Idempotency, err: err})
+				return
+			}
+			if !claimed {
+				replayIdempotent(w, r, record, body)
+				return
+			}
+			rec := idempotency.NewRecorder(w)
+			next.ServeHTTP(rec, r)
+			finishIdempotent(r, db, rec, record)
+		})
+	}
+}
+
+// replayIdempotent - kept response of key, 422 - other request, 409 - request is in flight
+func replayIdempotent(w http.ResponseWriter, r *http.Request, record model.IdempotencyRecord, body []byte) {
+	switch {
+	case record.Fingerprint != idempotency.Fingerprint(r.Method, r.URL.RequestURI(), body):
+		writeError(w, r, http.StatusUnprocessableEntity, taskError{key: vr.Idempotency, err: ErrTransportIdempotencyMismatch})
+	case record.Status == 0:
+		w.Header().Set("Retry-After", "1")
+		writeError(w, r, http.StatusConflict, taskError{key: vr.Idempotency, err: ErrTransportIdempotencyInFlight})
+	default:
+		idempotency.Replay(w, record)
+	}
+}
+
+// finishIdempotent - response of claimed key is kept, 5xx - key is released, timeout - key stays claimed till lease
+func finishIdempotent(r *http.Request, db model.IdempotencyStore, rec *idempotency.Recorder, record model.IdempotencyRecord) {
+	if rec.Status() == 0 && r.Context().Err() != nil {
+		// handler may still change the store, retry before lease gets 409
+		return
+	}
+	ctx, cancel := context.WithTimeout(context.WithoutCancel(r.Context()), afterRequestTimeout)
+	defer cancel()
+	if rec.Status() == 0 || rec.Status() >= http.StatusInternalServerError {
+		if err := db.ReleaseIdempotencyKey(ctx, record); err != nil {
+			log.Printf("transport: release idempotency key error - %v", err)
+		}
+		return
+	}
+	if err := db.SaveIdempotentResponse(ctx, rec.Record(record)); err != nil {
+		log.Printf("transport: save idempotent response error - %v", err)
+	}
+}
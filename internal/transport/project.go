@@ -0,0 +1,119 @@
+// project - projects of 'Task' with counts of open and done 'Task'
+package transport
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+
+	"github.com/Ekvo/golang-chi-postgres-api/internal/model"
+	"github.com/Ekvo/golang-chi-postgres-api/internal/servises"
+	vr "github.com/Ekvo/golang-chi-postgres-api/internal/variables"
+	c "github.com/Ekvo/golang-chi-postgres-api/pkg/common"
+)
+
+// projectRoutes - '/project', each route works with 'Timeout' and 'idempotent' (look: Idempotency)
+func projectRoutes(db taskFindUpdate, idempotent func(http.Handler) http.Handler) chi.Router {
+	r := chi.NewRouter()
+	r.Use(Timeout(timeOut), idempotent)
+	r.Post("/", TaskHandler(db, projectCreate))
+	r.Get("/", TaskHandler(db, projectList))
+	r.Get("/{id}", TaskHandler(db, projectByID))
+	r.Put("/{id}", TaskHandler(db, projectUpdate))
+	r.Delete("/{id}", TaskHandler(db, projectRemove))
+	r.Get("/{id}/tasks", TaskHandler(db, projectTasks))
+	return r
+}
+
+func projectCreate(db taskFindUpdate, r *http.Request) responseData {
+	projectValidator := servises.NewProjectValidator()
+	if err := projectValidator.Decode(r); err != nil {
+		return decodeErrorData(err)
+	}
+	project, err := db.SaveProject(r.Context(), projectValidator.ProjectModel())
+	if err != nil {
+		return storeErrorData(vr.Project, err)
+	}
+	return responseData{http.StatusCreated, c.Message{vr.Project: servises.NewProjectResponse(project)}}
+}
+
+// projectList - param 'archived=true' - with archived projects
+func projectList(db taskFindUpdate, r *http.Request) responseData {
+	archived, err := strconv.ParseBool(r.URL.Query().Get("archived"))
+	if err != nil && r.URL.Query().Has("archived") {
+		return errorData(http.StatusBadRequest, vr.Params, ErrTransportParam)
+	}
+	projects, err := db.FindProjects(r.Context(), model.ProjectList{Archived: archived})
+	if err != nil {
+		return storeErrorData(vr.Projects, err)
+	}
+	return responseData{http.StatusOK, c.Message{vr.Projects: servises.NewProjectListResponse(projects)}}
+}
+
+func projectByID(db taskFindUpdate, r *http.Request) responseData {
+	id, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		return errorData(http.StatusBadRequest, vr.Params, ErrTransportParam)
+	}
+	project, err := db.FindProject(r.Context(), uint(id))
+	if err != nil {
+		return storeErrorData(vr.Project, err)
+	}
+	return responseData{http.StatusOK, c.Message{vr.Project: servises.NewProjectResponse(project)}}
+}
+
+// projectUpdate - replace 'Project', 'archived' hides its 'Task' from default listings
+func projectUpdate(db taskFindUpdate, r *http.Request) responseData {
+	id, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		return errorData(http.StatusBadRequest, vr.Params, ErrTransportParam)
+	}
+	projectValidator := servises.NewProjectValidator()
+	if err := projectValidator.Decode(r); err != nil {
+		return decodeErrorData(err)
+	}
+	project := projectValidator.ProjectModel()
+	project.ID = uint(id)
+	updatedAt := time.Now().UTC()
+	project.UpdatedAt = &updatedAt
+	project, err = db.UpdateProject(r.Context(), project)
+	if err != nil {
+		return storeErrorData(vr.Project, err)
+	}
+	return responseData{http.StatusOK, c.Message{vr.Project: servises.NewProjectResponse(project)}}
+}
+
+// projectRemove - 'Task' of 'Project' are kept without project
+func projectRemove(db taskFindUpdate, r *http.Request) responseData {
+	id, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		return errorData(http.StatusBadRequest, vr.Params, ErrTransportParam)
+	}
+	if err := db.DeleteProject(r.Context(), uint(id)); err != nil {
+		return storeErrorData(vr.Project, err)
+	}
+	return responseData{http.StatusOK, c.Message{vr.Project: "deleted"}}
+}
+
+// projectTasks - 'Task' of 'Project' (archived too) by filter of 'GET /task/'
+func projectTasks(db taskFindUpdate, r *http.Request) responseData {
+	id, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		return errorData(http.StatusBadRequest, vr.Params, ErrTransportParam)
+	}
+	if _, err := db.FindProject(r.Context(), uint(id)); err != nil {
+		return storeErrorData(vr.Project, err)
+	}
+	schema, err := db.CustomFields(r.Context())
+	if err != nil {
+		return storeErrorData(vr.CustomFields, err)
+	}
+	filter, err := taskFilter(r, schema)
+	if err != nil {
+		return errorData(http.StatusBadRequest, vr.Params, err)
+	}
+	filter.ProjectID, filter.Archived = uint(id), true
+	return findTasks(db, r, filter)
+}
@@ -0,0 +1,162 @@
+// ratelimit - token buckets of routes and daily quota of tasks of clients
+package transport
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log"
+	"math"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Ekvo/golang-chi-postgres-api/internal/config"
+	"github.com/Ekvo/golang-chi-postgres-api/internal/model"
+	"github.com/Ekvo/golang-chi-postgres-api/internal/ratelimit"
+	vr "github.com/Ekvo/golang-chi-postgres-api/internal/variables"
+)
+
+var (
+	// ErrTransportRateLimited - bucket of client and route has no token
+	ErrTransportRateLimited = errors.New("too many requests")
+)
+
+// RateLimit - middleware
+// request takes token of bucket of client ('config.RateLimitBy') and of rule of route:
+// rule of method and the longest prefix of path, otherwise "default" rule, no rule - no limit
+//
+// response has 'RateLimit-Limit', 'RateLimit-Remaining', 'RateLimit-Reset' and 'RateLimit-Policy',
+// no token - 429 with 'Retry-After', error of 'limiter' - request passes
+func RateLimit(limiter model.RateLimiter, rules []config.RateLimit, by string) func(next http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			rule, ok := rateRule(rules, r)
+			if !ok {
+				next.ServeHTTP(w, r)
+				return
+			}
+			state, err := limiter.TakeRate(r.Context(), model.RateTake{
+				Key:      rateClient(r, by) + "|" + rule.Name(),
+				Interval: rule.Interval(),
+				Burst:    rule.Burst,
+			})
+			if err != nil {
+				log.Printf("transport: rate limit error - %v", err)
+				next.ServeHTTP(w, r)
+				return
+			}
+			decision := ratelimit.NewDecision(state, rule.Interval(), rule.Burst)
+			h := w.Header()
+			h.Set("RateLimit-Limit", strconv.Itoa(decision.Limit))
+			h.Set("RateLimit-Remaining", strconv.Itoa(decision.Remaining))
+			h.Set("RateLimit-Reset", seconds(decision.Reset))
+			h.Set("RateLimit-Policy", fmt.Sprintf("%d;w=%s", rule.Burst, seconds(time.Duration(rule.Burst)*rule.Interval())))
+			if !decision.Allowed {
+				h.Set("Retry-After", seconds(decision.RetryAfter))
+				writeError(w, r, http.StatusTooManyRequests, taskError{key: vr.RateLimit, err: ErrTransportRateLimited})
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// rateRule - rule of method (or of any method) with the longest prefix of path, otherwise "default"
+func rateRule(rules []config.RateLimit, r *http.Request) (config.RateLimit, bool) {
+	found, ok := config.RateLimit{}, false
+	for _, rule := range rules {
+		switch {
+		case rule.Path == "":
+			if !ok {
+				found, ok = rule, true
+			}
+		case (rule.Method == "" || rule.Method == r.Method) && strings.HasPrefix(r.URL.Path, rule.Path):
+			if found.Path == "" || len(rule.Path) > len(found.Path) ||
+				(len(rule.Path) == len(found.Path) && found.Method == "") {
+				found, ok = rule, true
+			}
+		}
+	}
+	return found, ok
+}
+
+// rateClient - API key (hash), user or IP of Request by 'by', without key or user - IP
+func rateClient(r *http.Request, by string) string {
+	switch by {
+	case config.RateLimitByKey:
+		if key := requestKey(r); key != "" {
+			sum := sha256.Sum256([]byte(key))
+			return "key:" + hex.EncodeToString(sum[:16])
+		}
+	case config.RateLimitByUser:
+		if user := User(r.Context()); user != Anonymous {
+			return "user:" + user
+		}
+	}
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		host = r.RemoteAddr
+	}
+	return "ip:" + host
+}
+
+// seconds - whole seconds rounded up
+func seconds(d time.Duration) string {
+	return strconv.FormatInt(int64(math.Ceil(d.Seconds())), 10)
+}
+
+// TaskQuota - middleware, 'ratelimit.Charge' of user (IP without user) in context of request,
+// handlers which create tasks take them from it (look: taskCreate, taskBatch, taskImport, gql),
+// used quota - 429 with 'Retry-After' till the next day (UTC)
+func TaskQuota(quota *ratelimit.TaskQuota) func(next http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			charge := quota.Begin(rateClient(r, config.RateLimitByUser))
+			next.ServeHTTP(w, r.WithContext(ratelimit.NewContext(r.Context(), charge)))
+		})
+	}
+}
+
+// quotaErrorData - response of used quota, 'Retry-After' is set by 'writeError'
+func quotaErrorData(err error) responseData {
+	return errorData(http.StatusTooManyRequests, vr.RateLimit, err)
+}
+
+// refundTasks - 'n' taken tasks are not created, after timeout store could create them - quota stays taken
+func refundTasks(r *http.Request, n int) {
+	if r.Context().Err() == nil {
+		ratelimit.FromContext(r.Context()).Refund(r.Context(), n)
+	}
+}
+
+// importQuotaBlock - tasks of quota taken by import at once
+const importQuotaBlock = 100
+
+// importQuota - 'Next' of import takes quota by blocks, the rest is given back after import
+type importQuota struct {
+	r        *http.Request
+	next     func() (model.Task, error)
+	reserved int
+	used     int
+}
+
+func (q *importQuota) Next() (model.Task, error) {
+	task, err := q.next()
+	if err != nil {
+		return task, err
+	}
+	if q.used == q.reserved {
+		ctx := q.r.Context()
+		n, err := ratelimit.FromContext(ctx).Reserve(ctx, importQuotaBlock)
+		if err != nil {
+			return model.Task{}, err
+		}
+		q.reserved += n
+	}
+	q.used++
+	return task, nil
+}
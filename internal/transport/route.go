@@ -2,13 +2,20 @@ package transport
 
 import (
 	"errors"
+	"log"
+	"mime"
 	"net/http"
 	"regexp"
 	"strconv"
+	"time"
 
 	"github.com/go-chi/chi/v5"
 
+	"github.com/Ekvo/golang-chi-postgres-api/internal/model"
+	"github.com/Ekvo/golang-chi-postgres-api/internal/ratelimit"
+	"github.com/Ekvo/golang-chi-postgres-api/internal/recurrence"
 	"github.com/Ekvo/golang-chi-postgres-api/internal/servises"
+	"github.com/Ekvo/golang-chi-postgres-api/internal/shed"
 	"github.com/Ekvo/golang-chi-postgres-api/internal/source"
 	vr "github.com/Ekvo/golang-chi-postgres-api/internal/variables"
 	c "github.com/Ekvo/golang-chi-postgres-api/pkg/common"
@@ -23,20 +30,33 @@ type responseData struct {
 	body   any
 }
 
-// taskFunc - layout of function for TashHandler
-type taskFunc func(db taskFindUpdate, r *http.Request) responseData
+// taskFunc - layout of function for TashHandler, 'S' - store of route ('taskFindUpdate', 'model.WebhookStore')
+type taskFunc[S any] func(db S, r *http.Request) responseData
 
 // TaskHandler - main function on route(work with Timeout see ./middlweare.go)
 //
 // call in goroutines 'taskFn' for get 'responseData' to chan 'response'
 // in 'select' checks execution time and create body for 'http.ResponseWriter'
-func TaskHandler(db taskFindUpdate, taskFn taskFunc) http.HandlerFunc {
+func TaskHandler[S any](db S, taskFn taskFunc[S]) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
+		cd, err := c.NegotiateCodec(r.Header.Get("Accept"))
+		if err != nil {
+			writeError(w, r, http.StatusNotAcceptable, taskError{key: vr.Params, err: err})
+			return
+		}
 		ctx := r.Context()
 		response := make(chan responseData)
 
+		// query may outlive handler after timeout, place of 'LoadShed' is free only after it
+		token := shed.FromContext(ctx)
+		if token != nil {
+			token.Hold()
+		}
 		go func() {
 			defer close(response)
+			if token != nil {
+				defer token.Done()
+			}
 			select {
 			case <-ctx.Done():
 				return
@@ -48,48 +68,316 @@ func TaskHandler(db taskFindUpdate, taskFn taskFunc) http.HandlerFunc {
 		case <-ctx.Done():
 			return
 		case responseData := <-response:
-			c.EncodeJSON(w, responseData.status, responseData.body)
+			if te, ok := responseData.body.(taskError); ok {
+				writeError(w, r, responseData.status, te)
+				return
+			}
+			c.Encode(w, cd, responseData.status, responseData.body)
 		}
 	}
 }
 
 func taskCreate(db taskFindUpdate, r *http.Request) responseData {
-	taskValidator := servises.NewTaskValidator()
-	if err := taskValidator.DecodeJSON(r); err != nil {
-		return responseData{http.StatusUnprocessableEntity, c.NewMessageError(vr.Validator, err)}
+	schema, err := db.CustomFields(r.Context())
+	if err != nil {
+		return storeErrorData(vr.CustomFields, err)
 	}
-	id, err := db.SaveOneTask(r.Context(), taskValidator.TaskModel())
+	taskValidator := servises.NewTaskValidator().WithCustomFields(schema)
+	if err := taskValidator.Decode(r); err != nil {
+		return decodeErrorData(err)
+	}
+	task := remindUser(taskValidator.TaskModel(), User(r.Context()))
+	if err := ratelimit.FromContext(r.Context()).Take(r.Context(), 1); err != nil {
+		return quotaErrorData(err)
+	}
+	id, err := db.SaveOneTask(r.Context(), task)
 	if err != nil {
-		return responseData{http.StatusInternalServerError, c.NewMessageError(vr.DataBase, err)}
+		refundTasks(r, 1)
+		return storeErrorData(vr.DataBase, err)
 	}
 	return responseData{http.StatusCreated, c.Message{vr.Task: id}}
 }
 
+// taskBatch - create, update and delete many 'Task' in one transaction
+//
+// atomic mode: 200 and results or error of first failed operation
+// best effort mode: 207 and status of each operation
+func taskBatch(db taskFindUpdate, r *http.Request) responseData {
+	batchValidator := servises.NewBatchValidator()
+	if err := batchValidator.Decode(r); err != nil {
+		return decodeErrorData(err)
+	}
+	batch := batchValidator.Batch()
+	items := make([]servises.BatchItemResponse, batchValidator.Size())
+	for i, err := range batchValidator.Invalid() {
+		items[i] = servises.BatchItemResponse{
+			Index:  i,
+			Op:     batchValidator.Data.Operations[i].Op,
+			Status: http.StatusUnprocessableEntity,
+			Error:  err.Error(),
+		}
+	}
+	if len(batch.Operations) > 0 {
+		creates := 0
+		for _, op := range batch.Operations {
+			if op.Op == model.BatchCreate {
+				creates++
+			}
+		}
+		if err := ratelimit.FromContext(r.Context()).Take(r.Context(), creates); err != nil {
+			return quotaErrorData(err)
+		}
+		results, err := db.ExecuteBatch(r.Context(), batch)
+		if (batch.Atomic && err != nil) || (err != nil && len(results) == 0) {
+			refundTasks(r, creates)
+			return storeErrorData(vr.TaskBatch, err)
+		}
+		for j, result := range results {
+			i := batchValidator.Index()[j]
+			item := servises.BatchItemResponse{Index: i, Op: batch.Operations[j].Op, ID: result.ID}
+			switch {
+			case result.Err != nil:
+				item.Status = storeStatus(result.Err)
+				item.Error = result.Err.Error()
+				item.ID = 0
+			case item.Op == model.BatchCreate:
+				item.Status = http.StatusCreated
+			default:
+				item.Status = http.StatusOK
+			}
+			items[i] = item
+		}
+		if err != nil {
+			// commit of best effort batch failed - nothing is saved
+			for i := range items {
+				if items[i].Error == "" {
+					items[i].Status = storeStatus(err)
+					items[i].Error = err.Error()
+					items[i].ID = 0
+				}
+			}
+		}
+		for _, item := range items {
+			if item.Op == model.BatchCreate && item.Status == http.StatusCreated {
+				creates--
+			}
+		}
+		refundTasks(r, creates)
+	}
+	status := http.StatusOK
+	if !batch.Atomic {
+		status = http.StatusMultiStatus
+	}
+	return responseData{status, c.Message{vr.TaskBatch: items}}
+}
+
+// importFormat - format of import from param 'format' or from 'Content-Type'
+func importFormat(r *http.Request) string {
+	if format := r.URL.Query().Get("format"); format != "" {
+		return format
+	}
+	media, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	switch media {
+	case "text/csv":
+		return servises.ImportCSV
+	case "application/x-ndjson", "application/jsonl":
+		return servises.ImportNDJSON
+	}
+	return ""
+}
+
+// taskImport - stream CSV or NDJSON from body into the store by COPY
+//
+// param 'dry_run=true' - rows are checked by store and nothing is saved
+func taskImport(db taskFindUpdate, r *http.Request) responseData {
+	defer func() {
+		if err := r.Body.Close(); err != nil {
+			log.Printf("transport: r.Body.Close error - %v", err)
+		}
+	}()
+	dryRun, err := strconv.ParseBool(r.URL.Query().Get("dry_run"))
+	if err != nil && r.URL.Query().Has("dry_run") {
+		return errorData(http.StatusBadRequest, vr.Params, ErrTransportParam)
+	}
+	format := importFormat(r)
+	if format != servises.ImportCSV && format != servises.ImportNDJSON {
+		return errorData(http.StatusUnsupportedMediaType, vr.Validator, servises.ErrservisesImportFormat)
+	}
+	reader, err := servises.NewTaskImportReader(r.Body, format)
+	if err != nil {
+		return errorData(http.StatusBadRequest, vr.Validator, err)
+	}
+	next := reader.Next
+	quota := &importQuota{r: r, next: reader.Next}
+	if !dryRun {
+		next = quota.Next
+	}
+	count, err := db.ImportTasks(r.Context(), model.TaskImport{Next: next, DryRun: dryRun})
+	if err != nil {
+		// import is one transaction - nothing is created
+		refundTasks(r, quota.reserved)
+	} else {
+		refundTasks(r, quota.reserved-count)
+	}
+	if errors.Is(err, servises.ErrservisesImportFormat) {
+		return errorData(http.StatusBadRequest, vr.Validator, err)
+	}
+	if errors.Is(err, ratelimit.ErrQuotaExceeded) {
+		return quotaErrorData(err)
+	}
+	if err != nil {
+		return storeErrorData(vr.TaskImport, err)
+	}
+	status := http.StatusCreated
+	if dryRun {
+		status = http.StatusOK
+	}
+	return responseData{status, c.Message{vr.TaskImport: servises.ImportResponse{
+		Imported:      count,
+		DryRun:        dryRun,
+		RejectedCount: reader.RejectedCount(),
+		Rejected:      reader.Rejected(),
+	}}}
+}
+
 func taskUpdate(db taskFindUpdate, r *http.Request) responseData {
 	id, err := strconv.Atoi(chi.URLParam(r, "id"))
 	if err != nil {
-		return responseData{http.StatusBadRequest, c.NewMessageError(vr.Params, ErrTransportParam)}
+		return errorData(http.StatusBadRequest, vr.Params, ErrTransportParam)
+	}
+	schema, err := db.CustomFields(r.Context())
+	if err != nil {
+		return storeErrorData(vr.CustomFields, err)
 	}
-	taskValidator := servises.NewTaskValidator()
-	if err := taskValidator.DecodeJSON(r); err != nil {
-		return responseData{http.StatusUnprocessableEntity, c.NewMessageError(vr.Validator, err)}
+	taskValidator := servises.NewTaskValidator().WithCustomFields(schema)
+	if err := taskValidator.Decode(r); err != nil {
+		return decodeErrorData(err)
 	}
-	task := taskValidator.TaskModel()
+	task := remindUser(taskValidator.TaskModel(), User(r.Context()))
 	task.ID = uint(id)
 	task.UpdatedAt = &task.CreatedAt
 	if err := db.UpdateTask(r.Context(), task); err != nil {
-		return responseData{http.StatusNotFound, c.NewMessageError(vr.Task, source.ErrSourceNotFound)}
+		return storeErrorData(vr.Task, err)
 	}
 	return responseData{http.StatusOK, c.Message{vr.Task: "updated"}}
 }
 
+// remindUser - reminder of 'task' is sent to 'user' who set it, 'task' without reminder has no user
+func remindUser(task model.Task, user string) model.Task {
+	task.RemindUser = ""
+	if task.RemindAt != nil {
+		task.RemindUser = user
+	}
+	return task
+}
+
+// sameTime - both are nil or equal
+func sameTime(a, b *time.Time) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	return a.Equal(*b)
+}
+
+// acceptPatch - value of header 'Accept-Patch' for PATCH route
+const acceptPatch = c.MediaMergePatch + ", " + c.MediaJSONPatch
+
+// taskPatch - change part of 'Task' by 'merge-patch+json' or 'json-patch+json', returns changed 'Task'
+func taskPatch(db taskFindUpdate, r *http.Request) responseData {
+	id, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		return errorData(http.StatusBadRequest, vr.Params, ErrTransportParam)
+	}
+	schema, err := db.CustomFields(r.Context())
+	if err != nil {
+		return storeErrorData(vr.CustomFields, err)
+	}
+	patchValidator := servises.NewTaskPatchValidator().WithCustomFields(schema)
+	if err := patchValidator.DecodePatch(r); err != nil {
+		if errors.Is(err, servises.ErrservisesPatchMedia) {
+			return errorData(http.StatusUnsupportedMediaType, vr.Validator, err)
+		}
+		return errorData(http.StatusBadRequest, vr.Validator, err)
+	}
+	user := User(r.Context())
+	task, err := db.PatchTask(r.Context(), model.TaskPatch{ID: uint(id), Apply: func(task model.Task) (model.Task, error) {
+		patched, err := patchValidator.Apply(task)
+		if err != nil || sameTime(task.RemindAt, patched.RemindAt) {
+			return patched, err
+		}
+		return remindUser(patched, user), nil
+	}})
+	switch {
+	case err == nil:
+	case errors.Is(err, c.ErrCommonPatchTest):
+		return errorData(http.StatusConflict, vr.Validator, err)
+	case errors.Is(err, c.ErrCommonPatchPath),
+		errors.Is(err, c.ErrCommonPatchOperation),
+		errors.Is(err, servises.ErrservisesPatchApply),
+		errors.Is(err, servises.ErrservisesValidatorInvalidTask):
+		return errorData(http.StatusUnprocessableEntity, vr.Validator, err)
+	default:
+		return storeErrorData(vr.Task, err)
+	}
+	serializer := servises.TaskSerializer{Task: task}
+	return responseData{http.StatusOK, c.Message{vr.Task: serializer.Response()}}
+}
+
+// taskComplete - current occurrence of recurring 'Task' is done, 'Task' moves to the next occurrence
+func taskComplete(db taskFindUpdate, r *http.Request) responseData {
+	id, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		return errorData(http.StatusBadRequest, vr.Params, ErrTransportParam)
+	}
+	task, err := db.PatchTask(r.Context(), model.TaskPatch{ID: uint(id), Apply: func(task model.Task) (model.Task, error) {
+		return recurrence.Complete(task, time.Now())
+	}})
+	if errors.Is(err, recurrence.ErrRecurrenceNone) {
+		return errorData(http.StatusConflict, vr.Validator, err)
+	}
+	if err != nil {
+		return storeErrorData(vr.Task, err)
+	}
+	serializer := servises.TaskSerializer{Task: task}
+	return responseData{http.StatusOK, c.Message{vr.Task: serializer.Response()}}
+}
+
+// occurrences of 'GET /task/{id}/occurrences', param 'until' (RFC 3339) is 'occurrencesPeriod' from now by default
+const (
+	occurrencesPeriod = 30 * 24 * time.Hour
+	maxOccurrences    = 500
+)
+
+// taskOccurrences - upcoming occurrences of 'Task' from its current due time till param 'until'
+func taskOccurrences(db taskFindUpdate, r *http.Request) responseData {
+	id, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		return errorData(http.StatusBadRequest, vr.Params, ErrTransportParam)
+	}
+	until := time.Now().Add(occurrencesPeriod)
+	if v := r.URL.Query().Get("until"); v != "" {
+		if until, err = time.Parse(time.RFC3339, v); err != nil {
+			return errorData(http.StatusBadRequest, vr.Params, ErrTransportParam)
+		}
+	}
+	task, err := db.FindOneTask(r.Context(), uint(id))
+	if err != nil {
+		return storeErrorData(vr.Task, err)
+	}
+	occurrences, err := recurrence.Occurrences(task, until, maxOccurrences)
+	if err != nil {
+		return storeErrorData(vr.Task, err)
+	}
+	return responseData{http.StatusOK, c.Message{vr.TaskOccurrences: servises.NewOccurrencesResponse(task, occurrences)}}
+}
+
 func taskRemove(db taskFindUpdate, r *http.Request) responseData {
 	id, err := strconv.Atoi(chi.URLParam(r, "id"))
 	if err != nil {
-		return responseData{http.StatusBadRequest, c.NewMessageError(vr.Params, ErrTransportParam)}
+		return errorData(http.StatusBadRequest, vr.Params, ErrTransportParam)
 	}
 	if err := db.EndTaskLife(r.Context(), uint(id)); err != nil {
-		return responseData{http.StatusNotFound, c.NewMessageError(vr.Task, source.ErrSourceNotFound)}
+		return storeErrorData(vr.Task, err)
 	}
 	return responseData{http.StatusOK, c.Message{vr.Task: "deleted"}}
 }
@@ -97,13 +385,17 @@ func taskRemove(db taskFindUpdate, r *http.Request) responseData {
 func taskByID(db taskFindUpdate, r *http.Request) responseData {
 	id, err := strconv.Atoi(chi.URLParam(r, "id"))
 	if err != nil {
-		return responseData{http.StatusBadRequest, c.NewMessageError(vr.Params, ErrTransportParam)}
+		return errorData(http.StatusBadRequest, vr.Params, ErrTransportParam)
 	}
 	task, err := db.FindOneTask(r.Context(), uint(id))
 	if err != nil {
-		return responseData{http.StatusNotFound, c.NewMessageError(vr.Task, source.ErrSourceNotFound)}
+		return storeErrorData(vr.Task, err)
 	}
-	serializer := servises.TaskSerializer{Task: task}
+	tracked, err := timeTracked(r, db, []uint{task.ID})
+	if err != nil {
+		return storeErrorData(vr.TimeEntries, err)
+	}
+	serializer := servises.TaskSerializer{Task: task, Tracked: tracked[task.ID]}
 	return responseData{http.StatusOK, c.Message{vr.Task: serializer.Response()}}
 }
 
@@ -134,12 +426,23 @@ func taskList(db taskFindUpdate, r *http.Request) responseData {
 	if !isValidOrder(order) ||
 		!relimit.MatchString(limit) ||
 		!reoffset.MatchString(offset) {
-		return responseData{http.StatusBadRequest, c.NewMessageError(vr.Params, ErrTransportParam)}
+		return errorData(http.StatusBadRequest, vr.Params, ErrTransportParam)
 	}
 	tasks, err := db.FindTaskList(r.Context(), []string{order, limit, offset})
-	if err != nil || len(tasks) == 0 {
-		return responseData{http.StatusNoContent, c.NewMessageError(vr.DataBase, source.ErrSourceNotFound)}
+	if err != nil {
+		return storeErrorData(vr.DataBase, err)
+	}
+	if len(tasks) == 0 {
+		return errorData(http.StatusNoContent, vr.DataBase, source.ErrSourceNotFound)
+	}
+	ids := make([]uint, len(tasks))
+	for i, task := range tasks {
+		ids[i] = task.ID
+	}
+	tracked, err := timeTracked(r, db, ids)
+	if err != nil {
+		return storeErrorData(vr.TimeEntries, err)
 	}
-	serialize := servises.TaskListSerializer{Tasks: tasks}
+	serialize := servises.TaskListSerializer{Tasks: tasks, Tracked: tracked}
 	return responseData{http.StatusOK, c.Message{vr.TaskList: serialize.Response()}}
 }
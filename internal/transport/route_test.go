@@ -1,38 +1,72 @@
 package transport
 
 import (
+	"bufio"
 	"bytes"
 	"context"
+	"encoding/json"
+	"errors"
+	"io"
 	"log"
+	"mime/multipart"
 	"net/http"
 	"net/http/httptest"
 	"sort"
 	"strconv"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/go-chi/chi/v5"
+	"github.com/gorilla/websocket"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 
+	"github.com/Ekvo/golang-chi-postgres-api/internal/blob"
+	"github.com/Ekvo/golang-chi-postgres-api/internal/config"
+	"github.com/Ekvo/golang-chi-postgres-api/internal/events"
+	"github.com/Ekvo/golang-chi-postgres-api/internal/idempotency"
 	"github.com/Ekvo/golang-chi-postgres-api/internal/model"
+	"github.com/Ekvo/golang-chi-postgres-api/internal/ratelimit"
+	"github.com/Ekvo/golang-chi-postgres-api/internal/servises"
+	"github.com/Ekvo/golang-chi-postgres-api/internal/shed"
 	"github.com/Ekvo/golang-chi-postgres-api/internal/source"
+	vr "github.com/Ekvo/golang-chi-postgres-api/internal/variables"
+	c "github.com/Ekvo/golang-chi-postgres-api/pkg/common"
 )
 
 type TasksMock struct {
 	nextID uint
 	tasks  map[uint]model.Task
+	// findMany - number of calls of 'FindTasksByID' (batching of GraphQL)
+	findMany int
+	// events - log of 'TaskEventsSince' in order of cursor, 'purged' - cursor of the last removed one
+	events []model.TaskEvent
+	purged model.EventCursor
+	// entries - time entries in order of save
+	entries []model.TimeEntry
+	// fields - schema of custom fields in order of name
+	fields []model.CustomField
+	// projects - by id, counts of 'Task' are made on read
+	projects map[uint]model.Project
 }
 
 func NewTasksMock() *TasksMock {
 	return &TasksMock{
-		nextID: 1,
-		tasks:  map[uint]model.Task{},
+		nextID:   1,
+		tasks:    map[uint]model.Task{},
+		projects: map[uint]model.Project{},
 	}
 }
 
 func (m *TasksMock) SaveOneTask(ctx context.Context, data any) (uint, error) {
 	newTask := data.(model.Task)
+	if err := m.checkProject(newTask); err != nil {
+		return 0, err
+	}
+	if newTask.Status == "" {
+		newTask.Status = model.StatusDefault
+	}
 	newTask.ID = m.nextID
 	m.tasks[newTask.ID] = newTask
 	m.nextID++
@@ -41,6 +75,9 @@ func (m *TasksMock) SaveOneTask(ctx context.Context, data any) (uint, error) {
 
 func (m *TasksMock) UpdateTask(ctx context.Context, data any) error {
 	newTask := data.(model.Task)
+	if err := m.checkProject(newTask); err != nil {
+		return err
+	}
 	if oldTask, ex := m.tasks[newTask.ID]; !ex {
 		return source.ErrSourceNotFound
 	} else {
@@ -48,6 +85,9 @@ func (m *TasksMock) UpdateTask(ctx context.Context, data any) error {
 		update := *newTask.UpdatedAt
 		newTask.UpdatedAt = &update
 		newTask.CreatedAt = oldTask.CreatedAt
+		if newTask.CustomFields == nil {
+			newTask.CustomFields = oldTask.CustomFields
+		}
 	}
 	m.tasks[newTask.ID] = newTask
 	return ctx.Err()
@@ -62,6 +102,349 @@ func (m *TasksMock) EndTaskLife(ctx context.Context, data any) error {
 	return ctx.Err()
 }
 
+func (m *TasksMock) PatchTask(ctx context.Context, data any) (model.Task, error) {
+	patch := data.(model.TaskPatch)
+	task, ex := m.tasks[patch.ID]
+	if !ex {
+		return model.Task{}, source.ErrSourceNotFound
+	}
+	task, err := patch.Apply(task)
+	if err != nil {
+		return model.Task{}, err
+	}
+	m.tasks[task.ID] = task
+	return task, ctx.Err()
+}
+
+func (m *TasksMock) ExecuteBatch(ctx context.Context, data any) ([]model.BatchResult, error) {
+	batch := data.(model.Batch)
+	// atomic - changes of copy are saved only if all operations succeed
+	tasks := make(map[uint]model.Task, len(m.tasks))
+	for id, task := range m.tasks {
+		tasks[id] = task
+	}
+	nextID := m.nextID
+	results := make([]model.BatchResult, len(batch.Operations))
+	for i, op := range batch.Operations {
+		_, ex := tasks[op.Task.ID]
+		switch {
+		case op.Op == model.BatchCreate:
+			op.Task.ID = nextID
+			tasks[nextID] = op.Task
+			nextID++
+		case !ex:
+			results[i].Err = source.ErrSourceNotFound
+			if batch.Atomic {
+				return results, source.ErrSourceNotFound
+			}
+			continue
+		case op.Op == model.BatchUpdate:
+			op.Task.CreatedAt = tasks[op.Task.ID].CreatedAt
+			tasks[op.Task.ID] = op.Task
+		default:
+			delete(tasks, op.Task.ID)
+		}
+		results[i].ID = op.Task.ID
+	}
+	m.tasks, m.nextID = tasks, nextID
+	return results, ctx.Err()
+}
+
+func (m *TasksMock) ImportTasks(ctx context.Context, data any) (int, error) {
+	taskImport := data.(model.TaskImport)
+	var tasks []model.Task
+	for {
+		task, err := taskImport.Next()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return 0, err
+		}
+		tasks = append(tasks, task)
+	}
+	if !taskImport.DryRun {
+		for _, task := range tasks {
+			if _, err := m.SaveOneTask(ctx, task); err != nil {
+				return 0, err
+			}
+		}
+	}
+	return len(tasks), ctx.Err()
+}
+
+func (m *TasksMock) ExportTasks(ctx context.Context, data any) (int, error) {
+	export := data.(model.TaskExport)
+	arrID := make([]uint, 0, len(m.tasks))
+	for id := range m.tasks {
+		arrID = append(arrID, id)
+	}
+	sort.Slice(arrID, func(i, j int) bool {
+		if a, b := m.tasks[arrID[i]], m.tasks[arrID[j]]; export.Filter.Board && a.Status != b.Status {
+			return a.Status < b.Status
+		}
+		if a, b := m.tasks[arrID[i]], m.tasks[arrID[j]]; export.Filter.Board && a.Position != b.Position {
+			return a.Position < b.Position
+		}
+		if export.Filter.Board {
+			return arrID[i] < arrID[j]
+		}
+		if export.Filter.Order == desc {
+			return arrID[i] > arrID[j]
+		}
+		return arrID[i] < arrID[j]
+	})
+	count, skipped := 0, 0
+	for _, id := range arrID {
+		task := m.tasks[id]
+		if from := export.Filter.CreatedFrom; from != nil && task.CreatedAt.Before(*from) {
+			continue
+		}
+		if to := export.Filter.CreatedTo; to != nil && !task.CreatedAt.Before(*to) {
+			continue
+		}
+		if export.Filter.DueOnly && task.DueAt == nil {
+			continue
+		}
+		if !containsFields(task.CustomFields, export.Filter.CustomFields) {
+			continue
+		}
+		if id := export.Filter.ProjectID; id != 0 && task.ProjectID != id {
+			continue
+		}
+		if !export.Filter.Archived && m.projects[task.ProjectID].Archived {
+			continue
+		}
+		if status := export.Filter.Status; status != "" && task.Status != status {
+			continue
+		}
+		if skipped < export.Filter.Offset {
+			skipped++
+			continue
+		}
+		if export.Filter.Limit > 0 && count == export.Filter.Limit {
+			break
+		}
+		if err := export.Each(task); err != nil {
+			return count, err
+		}
+		count++
+	}
+	return count, ctx.Err()
+}
+
+// containsFields - like '@>' of JSONB
+func containsFields(values, filter map[string]any) bool {
+	for name, value := range filter {
+		if values[name] != value {
+			return false
+		}
+	}
+	return true
+}
+
+func (m *TasksMock) SaveCustomField(ctx context.Context, data any) (model.CustomField, error) {
+	field := data.(model.CustomField)
+	field.CreatedAt = time.Now()
+	for i, old := range m.fields {
+		if old.Name == field.Name {
+			field.CreatedAt = old.CreatedAt
+			updatedAt := time.Now()
+			field.UpdatedAt = &updatedAt
+			m.fields[i] = field
+			return field, ctx.Err()
+		}
+	}
+	m.fields = append(m.fields, field)
+	sort.Slice(m.fields, func(i, j int) bool { return m.fields[i].Name < m.fields[j].Name })
+	return field, ctx.Err()
+}
+
+func (m *TasksMock) CustomFields(ctx context.Context) ([]model.CustomField, error) {
+	return append([]model.CustomField(nil), m.fields...), ctx.Err()
+}
+
+func (m *TasksMock) DeleteCustomField(ctx context.Context, data any) error {
+	name := data.(string)
+	for i, field := range m.fields {
+		if field.Name == name {
+			m.fields = append(m.fields[:i], m.fields[i+1:]...)
+			return ctx.Err()
+		}
+	}
+	return source.ErrSourceNotFound
+}
+
+// checkProject - like foreign key of 'tasks.project_id'
+func (m *TasksMock) checkProject(task model.Task) error {
+	if _, ex := m.projects[task.ProjectID]; task.ProjectID != 0 && !ex {
+		return &source.ValidationError{Params: []c.InvalidParam{{Name: "project_id", Reason: "unknown project"}}}
+	}
+	return nil
+}
+
+func (m *TasksMock) SaveProject(ctx context.Context, data any) (model.Project, error) {
+	project := data.(model.Project)
+	project.ID = uint(len(m.projects) + 1)
+	m.projects[project.ID] = project
+	return project, ctx.Err()
+}
+
+func (m *TasksMock) UpdateProject(ctx context.Context, data any) (model.Project, error) {
+	project := data.(model.Project)
+	old, ex := m.projects[project.ID]
+	if !ex {
+		return model.Project{}, source.ErrSourceNotFound
+	}
+	project.CreatedAt = old.CreatedAt
+	m.projects[project.ID] = project
+	return m.FindProject(ctx, project.ID)
+}
+
+func (m *TasksMock) DeleteProject(ctx context.Context, data any) error {
+	projectID := data.(uint)
+	if _, ex := m.projects[projectID]; !ex {
+		return source.ErrSourceNotFound
+	}
+	delete(m.projects, projectID)
+	for id, task := range m.tasks {
+		if task.ProjectID == projectID {
+			task.ProjectID = 0
+			m.tasks[id] = task
+		}
+	}
+	return ctx.Err()
+}
+
+func (m *TasksMock) FindProject(ctx context.Context, data any) (model.Project, error) {
+	project, ex := m.projects[data.(uint)]
+	if !ex {
+		return model.Project{}, source.ErrSourceNotFound
+	}
+	for _, task := range m.tasks {
+		switch {
+		case task.ProjectID != project.ID:
+		case task.Done:
+			project.Done++
+		default:
+			project.Open++
+		}
+	}
+	return project, ctx.Err()
+}
+
+func (m *TasksMock) FindProjects(ctx context.Context, data any) ([]model.Project, error) {
+	list := data.(model.ProjectList)
+	projects := []model.Project{}
+	for id, project := range m.projects {
+		if project.Archived && !list.Archived {
+			continue
+		}
+		project, err := m.FindProject(ctx, id)
+		if err != nil {
+			return nil, err
+		}
+		projects = append(projects, project)
+	}
+	sort.Slice(projects, func(i, j int) bool {
+		if projects[i].Position != projects[j].Position {
+			return projects[i].Position < projects[j].Position
+		}
+		return projects[i].ID < projects[j].ID
+	})
+	return projects, ctx.Err()
+}
+
+// column - 'Task' of column 'status' without 'exclude' in order of position and id
+func (m *TasksMock) column(status string, exclude uint) []model.Task {
+	var tasks []model.Task
+	for _, task := range m.tasks {
+		if task.Status == status && task.ID != exclude {
+			tasks = append(tasks, task)
+		}
+	}
+	sort.Slice(tasks, func(i, j int) bool {
+		if tasks[i].Position != tasks[j].Position {
+			return tasks[i].Position < tasks[j].Position
+		}
+		return tasks[i].ID < tasks[j].ID
+	})
+	return tasks
+}
+
+func (m *TasksMock) MoveTask(ctx context.Context, data any) (model.Task, error) {
+	move := data.(model.TaskMove)
+	task, ex := m.tasks[move.ID]
+	if !ex {
+		return model.Task{}, source.ErrSourceNotFound
+	}
+	if move.Status != "" {
+		task.Status = move.Status
+	}
+	for rebalanced := false; ; rebalanced = true {
+		tasks := m.column(task.Status, task.ID)
+		// neighbours by index in column, -1 and len(tasks) - edges
+		after, before := len(tasks)-1, len(tasks)
+		for i, other := range tasks {
+			if other.ID == move.After {
+				after, before = i, i+1
+			}
+			if other.ID == move.Before && move.After == 0 {
+				after, before = i-1, i
+			}
+		}
+		for _, anchor := range []struct {
+			name string
+			id   uint
+		}{{"after", move.After}, {"before", move.Before}} {
+			if other, ex := m.tasks[anchor.id]; anchor.id != 0 && (!ex || other.Status != task.Status) {
+				return model.Task{}, &source.ValidationError{Params: []c.InvalidParam{{Name: anchor.name, Reason: "unknown task"}}}
+			}
+		}
+		a, b := "", ""
+		if after >= 0 {
+			a = tasks[after].Position
+		}
+		if before < len(tasks) {
+			b = tasks[before].Position
+		}
+		if before == len(tasks) || a < b {
+			key, err := move.Rank(a, b)
+			if err != nil {
+				return model.Task{}, err
+			}
+			task.Position = key
+			break
+		}
+		if rebalanced {
+			return model.Task{}, source.ErrSourceConflict
+		}
+		for i, key := range move.Keys(len(tasks)) {
+			tasks[i].Position = key
+			m.tasks[tasks[i].ID] = tasks[i]
+		}
+	}
+	task.UpdatedAt = &move.UpdatedAt
+	m.tasks[task.ID] = task
+	return task, ctx.Err()
+}
+
+func (m *TasksMock) RebalancePositions(ctx context.Context, data any) (int, error) {
+	rebalance := data.(model.RankRebalance)
+	statuses := map[string]bool{}
+	for _, task := range m.tasks {
+		statuses[task.Status] = true
+	}
+	for status := range statuses {
+		tasks := m.column(status, 0)
+		for i, key := range rebalance.Keys(len(tasks)) {
+			tasks[i].Position = key
+			m.tasks[tasks[i].ID] = tasks[i]
+		}
+	}
+	return len(statuses), ctx.Err()
+}
+
 func (m *TasksMock) FindOneTask(ctx context.Context, data any) (model.Task, error) {
 	taskId := data.(uint)
 	if task, ex := m.tasks[taskId]; !ex {
@@ -71,10 +454,125 @@ func (m *TasksMock) FindOneTask(ctx context.Context, data any) (model.Task, erro
 	}
 }
 
+func (m *TasksMock) FindTasksByID(ctx context.Context, data any) ([]model.Task, error) {
+	ids := data.([]uint)
+	m.findMany++
+	tasks := make([]model.Task, 0, len(ids))
+	for _, id := range ids {
+		if task, ex := m.tasks[id]; ex {
+			tasks = append(tasks, task)
+		}
+	}
+	return tasks, ctx.Err()
+}
+
+func (m *TasksMock) TaskEventsSince(ctx context.Context, data any) ([]model.TaskEvent, error) {
+	after := data.(model.EventCursor)
+	if after.Less(m.purged) {
+		return nil, source.ErrSourceEventsGone
+	}
+	var evs []model.TaskEvent
+	for _, ev := range m.events {
+		if after.Less(ev.Cursor()) {
+			evs = append(evs, ev)
+		}
+	}
+	return evs, ctx.Err()
+}
+
+func (m *TasksMock) StartTimer(ctx context.Context, data any) (model.TimeEntry, error) {
+	entry := data.(model.TimeEntry)
+	for _, e := range m.entries {
+		if e.User == entry.User && e.StopAt == nil {
+			return model.TimeEntry{}, source.ErrSourceConflict
+		}
+	}
+	return m.SaveTimeEntry(ctx, entry)
+}
+
+func (m *TasksMock) StopTimer(ctx context.Context, data any) (model.TimeEntry, error) {
+	stop := data.(model.TimerStop)
+	for i, e := range m.entries {
+		if e.User == stop.User && e.TaskID == stop.TaskID && e.StopAt == nil {
+			at := stop.At.UTC()
+			m.entries[i].StopAt = &at
+			return m.entries[i], ctx.Err()
+		}
+	}
+	return model.TimeEntry{}, source.ErrSourceNotFound
+}
+
+func (m *TasksMock) SaveTimeEntry(ctx context.Context, data any) (model.TimeEntry, error) {
+	entry := data.(model.TimeEntry)
+	if _, ex := m.tasks[entry.TaskID]; !ex {
+		return model.TimeEntry{}, source.ErrSourceNotFound
+	}
+	entry.ID = uint64(len(m.entries) + 1)
+	m.entries = append(m.entries, entry)
+	return entry, ctx.Err()
+}
+
+func (m *TasksMock) TimeEntries(ctx context.Context, data any) ([]model.TimeEntry, error) {
+	taskID := data.(uint)
+	var entries []model.TimeEntry
+	for _, e := range m.entries {
+		if e.TaskID == taskID {
+			entries = append(entries, e)
+		}
+	}
+	sort.SliceStable(entries, func(i, j int) bool { return entries[i].StartAt.Before(entries[j].StartAt) })
+	return entries, ctx.Err()
+}
+
+func (m *TasksMock) TimeTotals(ctx context.Context, data any) ([]model.TimeTotal, error) {
+	report := data.(model.TimeReport)
+	sums := map[model.TimeTotal]time.Duration{}
+	for _, e := range m.entries {
+		if len(report.TaskIDs) > 0 && !containsID(report.TaskIDs, e.TaskID) ||
+			report.User != "" && e.User != report.User ||
+			report.From != nil && e.StartAt.Before(*report.From) ||
+			report.To != nil && !e.StartAt.Before(*report.To) {
+			continue
+		}
+		zone := report.TimeZone
+		if zone == "" {
+			zone = m.tasks[e.TaskID].TimeZone
+		}
+		loc, err := time.LoadLocation(zone)
+		if err != nil {
+			return nil, err
+		}
+		sums[model.TimeTotal{TaskID: e.TaskID, Day: e.StartAt.In(loc).Format(time.DateOnly)}] += e.Duration(time.Now())
+	}
+	totals := make([]model.TimeTotal, 0, len(sums))
+	for key, d := range sums {
+		key.Duration = d
+		totals = append(totals, key)
+	}
+	sort.Slice(totals, func(i, j int) bool {
+		if totals[i].TaskID != totals[j].TaskID {
+			return totals[i].TaskID < totals[j].TaskID
+		}
+		return totals[i].Day < totals[j].Day
+	})
+	return totals, ctx.Err()
+}
+
+func containsID(ids []uint, id uint) bool {
+	for _, v := range ids {
+		if v == id {
+			return true
+		}
+	}
+	return false
+}
+
 func (m *TasksMock) FindTaskList(ctx context.Context, data any) ([]model.Task, error) {
 	arrID := make([]uint, 0, len(m.tasks))
-	for id, _ := range m.tasks {
-		arrID = append(arrID, id)
+	for id, task := range m.tasks {
+		if !m.projects[task.ProjectID].Archived {
+			arrID = append(arrID, id)
+		}
 	}
 	taskList := data.([]string)
 
@@ -207,7 +705,7 @@ func TestRoute(t *testing.T) {
 
 	base := NewTasksMock()
 	r := chi.NewRouter()
-	h := NewTransport(r)
+	h := NewTransport(r, &config.Config{ErrorFormat: vr.ErrorFormatLegacy})
 	h.Routes(base)
 
 	for i, test := range routeTestData {
@@ -265,3 +763,3075 @@ func TestValidOrder(t *testing.T) {
 		asserts.Equal(test.expected, result, test.msg)
 	}
 }
+
+// errors in format 'application/problem+json'
+var problemTestData = []struct {
+	description    string
+	url            string
+	method         string
+	bodyData       string
+	expectedCode   int
+	expectedType   string
+	expectedParams []string
+	msg            string
+}{
+	{
+		description:    "Wrong task create",
+		url:            "/task/",
+		method:         http.MethodPost,
+		bodyData:       `{"task_update":{"note":"second task"}}`,
+		expectedCode:   http.StatusUnprocessableEntity,
+		expectedType:   vr.ProblemValidation,
+		expectedParams: []string{"description"},
+		msg:            "invalid - problem with type validation and status 422",
+	},
+	{
+		description:    "Wrong task create (all fields invalid)",
+		url:            "/task/",
+		method:         http.MethodPost,
+		bodyData:       `{"task_update":{"description":"` + strings.Repeat("я", 2049) + `","note":"bell \u0007"}}`,
+		expectedCode:   http.StatusUnprocessableEntity,
+		expectedType:   vr.ProblemValidation,
+		expectedParams: []string{"description", "note"},
+		msg:            "invalid - all field errors must be collected at once and status 422",
+	},
+	{
+		description:  "Wrong get task by id",
+		url:          "/task/alpha",
+		method:       http.MethodGet,
+		expectedCode: http.StatusBadRequest,
+		expectedType: vr.ProblemInvalidParams,
+		msg:          "invalid - problem with type invalid-params and status 400",
+	},
+	{
+		description:  "Wrong delete task by id (not found)",
+		url:          "/task/200",
+		method:       http.MethodDelete,
+		expectedCode: http.StatusNotFound,
+		expectedType: vr.ProblemNotFound,
+		msg:          "invalid - problem with type not-found and status 404",
+	},
+}
+
+func TestRouteProblem(t *testing.T) {
+	asserts := assert.New(t)
+	requires := require.New(t)
+
+	r := chi.NewRouter()
+	NewTransport(r, &config.Config{ErrorFormat: vr.ErrorFormatProblem}).Routes(NewTasksMock())
+
+	for i, test := range problemTestData {
+		log.Printf("\t %d test problem: %s\n", i+1, test.description)
+		req, err := http.NewRequest(test.method, test.url, strings.NewReader(test.bodyData))
+		requires.NoError(err, "http.NewRequest error")
+		req.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+
+		asserts.Equal(test.expectedCode, w.Code, test.msg)
+		asserts.Equal(c.MediaProblemJSON, w.Header().Get("Content-Type"), test.msg)
+
+		problem := c.Problem{}
+		requires.NoError(json.Unmarshal(w.Body.Bytes(), &problem), test.msg)
+		asserts.Equal(test.expectedType, problem.Type, test.msg)
+		asserts.Equal(test.expectedCode, problem.Status, test.msg)
+		asserts.Equal(http.StatusText(test.expectedCode), problem.Title, test.msg)
+		asserts.Equal(test.url, problem.Instance, test.msg)
+		asserts.NotEmpty(problem.Detail, test.msg)
+		params := make([]string, 0, len(problem.InvalidParams))
+		for _, p := range problem.InvalidParams {
+			params = append(params, p.Name)
+		}
+		if len(test.expectedParams) > 0 {
+			asserts.Equal(test.expectedParams, params, test.msg)
+		}
+	}
+}
+
+var storeErrorTestData = []struct {
+	err      error
+	expected int
+}{
+	{source.ErrSourceNotFound, http.StatusNotFound},
+	{source.ErrSourceConflict, http.StatusConflict},
+	{&source.ValidationError{}, http.StatusUnprocessableEntity},
+	{source.ErrSourceUnavailable, http.StatusServiceUnavailable},
+	{errors.New("unknown"), http.StatusInternalServerError},
+}
+
+func TestStoreErrorData(t *testing.T) {
+	for _, test := range storeErrorTestData {
+		assert.Equal(t, test.expected, storeErrorData(vr.Task, test.err).status, test.err.Error())
+	}
+}
+
+var patchTestData = []struct {
+	description    string
+	url            string
+	contentType    string
+	bodyData       string
+	expectedCode   int
+	responseRegexp string
+	msg            string
+}{
+	{
+		description:    "Merge patch - change note only",
+		url:            "/task/1",
+		contentType:    c.MediaMergePatch,
+		bodyData:       `{"note":"patched note"}`,
+		expectedCode:   http.StatusOK,
+		responseRegexp: `{"task":{"description":"first","note":"patched note","created_at":"[^"]+","updated_at":"[^"]+"}}`,
+		msg:            "valid - description must be kept and status 200",
+	},
+	{
+		description:    "Merge patch - remove note",
+		url:            "/task/1",
+		contentType:    c.MediaMergePatch,
+		bodyData:       `{"note":null}`,
+		expectedCode:   http.StatusOK,
+		responseRegexp: `{"task":{"description":"first","created_at":"[^"]+","updated_at":"[^"]+"}}`,
+		msg:            "valid - note must be removed and status 200",
+	},
+	{
+		description:    "JSON patch - test and replace",
+		url:            "/task/1",
+		contentType:    c.MediaJSONPatch,
+		bodyData:       `[{"op":"test","path":"/description","value":"first"},{"op":"replace","path":"/description","value":"second"}]`,
+		expectedCode:   http.StatusOK,
+		responseRegexp: `{"task":{"description":"second",`,
+		msg:            "valid - description must be replaced and status 200",
+	},
+	{
+		description:    "Wrong JSON patch - test failed",
+		url:            "/task/1",
+		contentType:    c.MediaJSONPatch,
+		bodyData:       `[{"op":"test","path":"/description","value":"first"},{"op":"remove","path":"/note"}]`,
+		expectedCode:   http.StatusConflict,
+		responseRegexp: `"type":"/problems/conflict"`,
+		msg:            "invalid - task must not be changed and status 409",
+	},
+	{
+		description:    "Wrong JSON patch - removed description is required",
+		url:            "/task/1",
+		contentType:    c.MediaJSONPatch,
+		bodyData:       `[{"op":"remove","path":"/description"}]`,
+		expectedCode:   http.StatusUnprocessableEntity,
+		responseRegexp: `"invalid_params":\[{"name":"description","reason":"required"}\]`,
+		msg:            "invalid - validation must be re-run and status 422",
+	},
+	{
+		description:    "Wrong merge patch - unknown field",
+		url:            "/task/1",
+		contentType:    c.MediaMergePatch,
+		bodyData:       `{"priority":1}`,
+		expectedCode:   http.StatusUnprocessableEntity,
+		responseRegexp: `"type":"/problems/validation"`,
+		msg:            "invalid - unknown field and status 422",
+	},
+	{
+		description:    "Merge patch - set reminder",
+		url:            "/task/1",
+		contentType:    c.MediaMergePatch,
+		bodyData:       `{"remind_at":"2025-03-01T10:00:00+01:00"}`,
+		expectedCode:   http.StatusOK,
+		responseRegexp: `"updated_at":"[^"]+","remind_at":"2025-03-01T09:00:00Z"}}`,
+		msg:            "valid - reminder must be set in UTC and status 200",
+	},
+	{
+		description:    "Wrong merge patch - reminder is not a time",
+		url:            "/task/1",
+		contentType:    c.MediaMergePatch,
+		bodyData:       `{"remind_at":"tomorrow"}`,
+		expectedCode:   http.StatusUnprocessableEntity,
+		responseRegexp: `"invalid_params":\[{"name":"remind_at","reason":"must be RFC 3339 time"}\]`,
+		msg:            "invalid - reminder must be RFC 3339 and status 422",
+	},
+	{
+		description:    "Merge patch - remove reminder",
+		url:            "/task/1",
+		contentType:    c.MediaMergePatch,
+		bodyData:       `{"remind_at":null}`,
+		expectedCode:   http.StatusOK,
+		responseRegexp: `"updated_at":"[^"]+"}}`,
+		msg:            "valid - reminder must be removed and status 200",
+	},
+	{
+		description:    "Wrong patch - malformed document",
+		url:            "/task/1",
+		contentType:    c.MediaJSONPatch,
+		bodyData:       `{"op":"remove"`,
+		expectedCode:   http.StatusBadRequest,
+		responseRegexp: `"status":400`,
+		msg:            "invalid - malformed document and status 400",
+	},
+	{
+		description:    "Wrong patch - media type",
+		url:            "/task/1",
+		contentType:    "application/json",
+		bodyData:       `{"note":"x"}`,
+		expectedCode:   http.StatusUnsupportedMediaType,
+		responseRegexp: `"status":415`,
+		msg:            "invalid - unsupported media and status 415",
+	},
+	{
+		description:    "Wrong patch - not found",
+		url:            "/task/200",
+		contentType:    c.MediaMergePatch,
+		bodyData:       `{"note":"x"}`,
+		expectedCode:   http.StatusNotFound,
+		responseRegexp: `"type":"/problems/not-found"`,
+		msg:            "invalid - task not found and status 404",
+	},
+}
+
+func TestRoutePatch(t *testing.T) {
+	asserts := assert.New(t)
+	requires := require.New(t)
+
+	base := NewTasksMock()
+	_, err := base.SaveOneTask(context.Background(), model.Task{Description: "first", Note: "note"})
+	requires.NoError(err)
+	r := chi.NewRouter()
+	NewTransport(r, &config.Config{ErrorFormat: vr.ErrorFormatProblem}).Routes(base)
+
+	for i, test := range patchTestData {
+		log.Printf("\t %d test patch: %s\n", i+1, test.description)
+		req, err := http.NewRequest(http.MethodPatch, test.url, strings.NewReader(test.bodyData))
+		requires.NoError(err, "http.NewRequest error")
+		req.Header.Set("Content-Type", test.contentType)
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+
+		asserts.Equal(test.expectedCode, w.Code, test.msg)
+		asserts.Equal(acceptPatch, w.Header().Get("Accept-Patch"), test.msg)
+		asserts.Regexp(test.responseRegexp, w.Body.String(), test.msg)
+	}
+}
+
+var recurrenceTestData = []struct {
+	description    string
+	method         string
+	url            string
+	bodyData       string
+	expectedCode   int
+	responseRegexp string
+	msg            string
+}{
+	{
+		description:    "Create recurring task",
+		method:         http.MethodPost,
+		url:            "/task/",
+		bodyData:       `{"task_update":{"description":"Water plants","due_at":"2025-03-24T09:00:00+01:00","time_zone":"Europe/Berlin","rrule":"freq=weekly;byday=MO;count=3"}}`,
+		expectedCode:   http.StatusCreated,
+		responseRegexp: `{"task":1}`,
+		msg:            "valid - task with rrule and status 201",
+	},
+	{
+		description:    "Occurrences through daylight saving time",
+		method:         http.MethodGet,
+		url:            "/task/1/occurrences?until=2025-05-01T00:00:00Z",
+		expectedCode:   http.StatusOK,
+		responseRegexp: `{"task_occurrences":{"task_id":1,"time_zone":"Europe/Berlin","rrule":"FREQ=WEEKLY;BYDAY=MO;COUNT=3","occurrences":\["2025-03-24T09:00:00\+01:00","2025-03-31T09:00:00\+02:00","2025-04-07T09:00:00\+02:00"\]}}`,
+		msg:            "valid - local time is kept after change of offset and status 200",
+	},
+	{
+		description:    "Complete occurrence",
+		method:         http.MethodPost,
+		url:            "/task/1/complete",
+		expectedCode:   http.StatusOK,
+		responseRegexp: `"due_at":"2025-03-31T09:00:00\+02:00","time_zone":"Europe/Berlin","rrule":"FREQ=WEEKLY;BYDAY=MO;COUNT=3"}}`,
+		msg:            "valid - task must move to next occurrence and status 200",
+	},
+	{
+		description:    "Occurrences from current due time",
+		method:         http.MethodGet,
+		url:            "/task/1/occurrences?until=2025-04-01T00:00:00%2B02:00",
+		expectedCode:   http.StatusOK,
+		responseRegexp: `"occurrences":\["2025-03-31T09:00:00\+02:00"\]}}`,
+		msg:            "valid - only occurrences till 'until' and status 200",
+	},
+	{
+		description:    "Complete last occurrences",
+		method:         http.MethodPost,
+		url:            "/task/1/complete",
+		expectedCode:   http.StatusOK,
+		responseRegexp: `"due_at":"2025-04-07T09:00:00\+02:00","time_zone":"Europe/Berlin","rrule":"FREQ=WEEKLY;BYDAY=MO;COUNT=3"}}`,
+		msg:            "valid - task must move to the last occurrence and status 200",
+	},
+	{
+		description:    "Complete end of series",
+		method:         http.MethodPost,
+		url:            "/task/1/complete",
+		expectedCode:   http.StatusOK,
+		responseRegexp: `"due_at":"2025-04-07T09:00:00\+02:00","time_zone":"Europe/Berlin"}}`,
+		msg:            "valid - rrule must be removed after the last occurrence and status 200",
+	},
+	{
+		description:    "Wrong complete - not recurring",
+		method:         http.MethodPost,
+		url:            "/task/1/complete",
+		expectedCode:   http.StatusConflict,
+		responseRegexp: `"detail":"task is not recurring"`,
+		msg:            "invalid - task without rrule and status 409",
+	},
+	{
+		description:    "Wrong create - unsupported frequency",
+		method:         http.MethodPost,
+		url:            "/task/",
+		bodyData:       `{"task_update":{"description":"Every hour","due_at":"2025-03-24T09:00:00Z","rrule":"FREQ=HOURLY"}}`,
+		expectedCode:   http.StatusUnprocessableEntity,
+		responseRegexp: `"invalid_params":\[{"name":"rrule","reason":"FREQ=HOURLY is not supported"}\]`,
+		msg:            "invalid - rrule and status 422",
+	},
+	{
+		description:    "Wrong create - rrule without due time and unknown time zone",
+		method:         http.MethodPost,
+		url:            "/task/",
+		bodyData:       `{"task_update":{"description":"Weekly","time_zone":"Mars/Olympus","rrule":"FREQ=WEEKLY"}}`,
+		expectedCode:   http.StatusUnprocessableEntity,
+		responseRegexp: `"invalid_params":\[{"name":"time_zone","reason":"must be IANA time zone"},{"name":"due_at","reason":"required with rrule"}\]`,
+		msg:            "invalid - all fields and status 422",
+	},
+	{
+		description:    "Wrong occurrences - until is not a time",
+		method:         http.MethodGet,
+		url:            "/task/1/occurrences?until=tomorrow",
+		expectedCode:   http.StatusBadRequest,
+		responseRegexp: `"status":400`,
+		msg:            "invalid - param and status 400",
+	},
+	{
+		description:    "Wrong occurrences - not found",
+		method:         http.MethodGet,
+		url:            "/task/200/occurrences",
+		expectedCode:   http.StatusNotFound,
+		responseRegexp: `"type":"/problems/not-found"`,
+		msg:            "invalid - task not found and status 404",
+	},
+}
+
+func TestRouteRecurrence(t *testing.T) {
+	asserts := assert.New(t)
+	requires := require.New(t)
+
+	r := chi.NewRouter()
+	NewTransport(r, &config.Config{ErrorFormat: vr.ErrorFormatProblem}).Routes(NewTasksMock())
+
+	for i, test := range recurrenceTestData {
+		log.Printf("\t %d test recurrence: %s\n", i+1, test.description)
+		req, err := http.NewRequest(test.method, test.url, strings.NewReader(test.bodyData))
+		requires.NoError(err, "http.NewRequest error")
+		req.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+
+		asserts.Equal(test.expectedCode, w.Code, test.msg)
+		asserts.Regexp(test.responseRegexp, w.Body.String(), test.msg)
+	}
+}
+
+var timeTrackingTestData = []struct {
+	description    string
+	method         string
+	url            string
+	key            string
+	bodyData       string
+	expectedCode   int
+	responseRegexp string
+	msg            string
+}{
+	{
+		description:    "Create task in zone",
+		method:         http.MethodPost,
+		url:            "/task/",
+		key:            "key-alice",
+		bodyData:       `{"task_update":{"description":"Write report","time_zone":"Asia/Tokyo"}}`,
+		expectedCode:   http.StatusCreated,
+		responseRegexp: `{"task":1}`,
+		msg:            "valid - task is created and status 201",
+	},
+	{
+		description:    "Manual entry with duration",
+		method:         http.MethodPost,
+		url:            "/task/1/time",
+		key:            "key-alice",
+		bodyData:       `{"time_entry":{"start":"2025-03-01T23:30:00Z","duration":"1h","note":"draft"}}`,
+		expectedCode:   http.StatusCreated,
+		responseRegexp: `{"time_entry":{"id":1,"task_id":1,"user":"alice","start":"2025-03-01T23:30:00Z","stop":"2025-03-02T00:30:00Z","seconds":3600,"running":false,"note":"draft",`,
+		msg:            "valid - stop from duration and status 201",
+	},
+	{
+		description:    "Manual entry with stop",
+		method:         http.MethodPost,
+		url:            "/task/1/time",
+		key:            "key-bob",
+		bodyData:       `{"time_entry":{"start":"2025-03-02T10:00:00+09:00","stop":"2025-03-02T10:30:00+09:00"}}`,
+		expectedCode:   http.StatusCreated,
+		responseRegexp: `"user":"bob","start":"2025-03-02T01:00:00Z","stop":"2025-03-02T01:30:00Z","seconds":1800,"running":false`,
+		msg:            "valid - entry of other user and status 201",
+	},
+	{
+		description:    "Wrong manual entry - stop before start and with duration",
+		method:         http.MethodPost,
+		url:            "/task/1/time",
+		key:            "key-alice",
+		bodyData:       `{"time_entry":{"start":"2025-03-02T10:00:00Z","stop":"2025-03-02T09:00:00Z","duration":"1h"}}`,
+		expectedCode:   http.StatusUnprocessableEntity,
+		responseRegexp: `"invalid_params":\[{"name":"stop","reason":"must be after start"},{"name":"duration","reason":"must not be with stop"}\]`,
+		msg:            "invalid - all fields and status 422",
+	},
+	{
+		description:    "Wrong manual entry - no end",
+		method:         http.MethodPost,
+		url:            "/task/1/time",
+		key:            "key-alice",
+		bodyData:       `{"time_entry":{"start":"2025-03-02T10:00:00Z"}}`,
+		expectedCode:   http.StatusUnprocessableEntity,
+		responseRegexp: `"invalid_params":\[{"name":"stop","reason":"required without duration"}\]`,
+		msg:            "invalid - stop or duration is required and status 422",
+	},
+	{
+		description:    "Wrong manual entry - unknown task",
+		method:         http.MethodPost,
+		url:            "/task/200/time",
+		key:            "key-alice",
+		bodyData:       `{"time_entry":{"start":"2025-03-02T10:00:00Z","duration":"30m"}}`,
+		expectedCode:   http.StatusNotFound,
+		responseRegexp: `"type":"/problems/not-found"`,
+		msg:            "invalid - task not found and status 404",
+	},
+	{
+		description:    "Start timer without body",
+		method:         http.MethodPost,
+		url:            "/task/1/timer/start",
+		key:            "key-alice",
+		expectedCode:   http.StatusCreated,
+		responseRegexp: `{"time_entry":{"id":3,"task_id":1,"user":"alice","start":"[^"]+","seconds":0,"running":true,`,
+		msg:            "valid - running timer and status 201",
+	},
+	{
+		description:    "Wrong start timer - timer of user is running",
+		method:         http.MethodPost,
+		url:            "/task/1/timer/start",
+		key:            "key-alice",
+		bodyData:       `{"time_entry":{"note":"again"}}`,
+		expectedCode:   http.StatusConflict,
+		responseRegexp: `"status":409`,
+		msg:            "invalid - one running timer per user and status 409",
+	},
+	{
+		description:    "Wrong stop timer - no running timer of user",
+		method:         http.MethodPost,
+		url:            "/task/1/timer/stop",
+		key:            "key-bob",
+		expectedCode:   http.StatusNotFound,
+		responseRegexp: `"type":"/problems/not-found"`,
+		msg:            "invalid - timer of other user is not stopped and status 404",
+	},
+	{
+		description:    "Stop timer",
+		method:         http.MethodPost,
+		url:            "/task/1/timer/stop",
+		key:            "key-alice",
+		expectedCode:   http.StatusOK,
+		responseRegexp: `{"time_entry":{"id":3,"task_id":1,"user":"alice","start":"[^"]+","stop":"[^"]+","seconds":0,"running":false,`,
+		msg:            "valid - timer is stopped and status 200",
+	},
+	{
+		description:    "Entries of task",
+		method:         http.MethodGet,
+		url:            "/task/1/time",
+		key:            "key-bob",
+		expectedCode:   http.StatusOK,
+		responseRegexp: `{"time_entries":{"entries":\[{"id":1,[^\]]+"id":2,[^\]]+"id":3,[^\]]+\],"total_seconds":5400,"days":\[{"day":"2025-03-02","seconds":5400},{"day":"[-0-9]+","seconds":0}\]}}`,
+		msg:            "valid - entries and totals by day in zone of task and status 200",
+	},
+	{
+		description:    "Task with totals",
+		method:         http.MethodGet,
+		url:            "/task/1",
+		key:            "key-bob",
+		expectedCode:   http.StatusOK,
+		responseRegexp: `"time_zone":"Asia/Tokyo","time_tracked":{"total_seconds":5400,"days":\[{"day":"2025-03-02","seconds":5400},`,
+		msg:            "valid - totals in task and status 200",
+	},
+	{
+		description:    "Report of user",
+		method:         http.MethodGet,
+		url:            "/task/time/report?user=alice&time_zone=UTC&to=2025-03-03T00:00:00Z",
+		key:            "key-bob",
+		expectedCode:   http.StatusOK,
+		responseRegexp: `{"time_report":{"to":"2025-03-03T00:00:00Z","user":"alice","time_zone":"UTC","total_seconds":3600,"tasks":\[{"task_id":1,"total_seconds":3600,"days":\[{"day":"2025-03-01","seconds":3600}\]}\],"days":\[{"day":"2025-03-01","seconds":3600}\]}}`,
+		msg:            "valid - totals by task and by day in zone of report and status 200",
+	},
+	{
+		description:    "Wrong report - params",
+		method:         http.MethodGet,
+		url:            "/task/time/report?time_zone=Mars/Olympus&task_id=1,x",
+		key:            "key-bob",
+		expectedCode:   http.StatusBadRequest,
+		responseRegexp: `"invalid_params":\[{"name":"time_zone","reason":"must be IANA time zone"},{"name":"task_id","reason":"\\"x\\" is not id of task"}\]`,
+		msg:            "invalid - all params and status 400",
+	},
+}
+
+func TestRouteTimeTracking(t *testing.T) {
+	asserts := assert.New(t)
+	requires := require.New(t)
+
+	r := chi.NewRouter()
+	cfg := &config.Config{ErrorFormat: vr.ErrorFormatProblem, APIKeys: "alice:key-alice,bob:key-bob"}
+	NewTransport(r, cfg).Routes(NewTasksMock())
+
+	for i, test := range timeTrackingTestData {
+		log.Printf("\t %d test time tracking: %s\n", i+1, test.description)
+		req, err := http.NewRequest(test.method, test.url, strings.NewReader(test.bodyData))
+		requires.NoError(err, "http.NewRequest error")
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("Authorization", "Bearer "+test.key)
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+
+		asserts.Equal(test.expectedCode, w.Code, test.msg)
+		asserts.Regexp(test.responseRegexp, w.Body.String(), test.msg)
+	}
+}
+
+var customFieldTestData = []struct {
+	description    string
+	method         string
+	url            string
+	key            string
+	contentType    string
+	bodyData       string
+	expectedCode   int
+	responseRegexp string
+	msg            string
+}{
+	{
+		description:    "Create field",
+		method:         http.MethodPut,
+		url:            "/fields/component",
+		key:            "key-alice",
+		bodyData:       `{"custom_field":{"type":"string","required":true,"allowed":["api","web"]}}`,
+		expectedCode:   http.StatusCreated,
+		responseRegexp: `{"custom_field":{"name":"component","type":"string","required":true,"allowed":\["api","web"\],"created_at":"[^"]+"}}`,
+		msg:            "valid - field is created by admin and status 201",
+	},
+	{
+		description:    "Replace field",
+		method:         http.MethodPut,
+		url:            "/fields/estimate",
+		key:            "key-alice",
+		bodyData:       `{"custom_field":{"type":"number"}}`,
+		expectedCode:   http.StatusCreated,
+		responseRegexp: `"name":"estimate","type":"number","required":false`,
+		msg:            "valid - second field and status 201",
+	},
+	{
+		description:    "Replace field",
+		method:         http.MethodPut,
+		url:            "/fields/estimate",
+		key:            "key-alice",
+		bodyData:       `{"custom_field":{"type":"number","allowed":[1,2,3]}}`,
+		expectedCode:   http.StatusOK,
+		responseRegexp: `"allowed":\[1,2,3\],"created_at":"[^"]+","updated_at":"[^"]+"`,
+		msg:            "valid - field is replaced and status 200",
+	},
+	{
+		description:    "Wrong field - not admin",
+		method:         http.MethodPut,
+		url:            "/fields/team",
+		key:            "key-bob",
+		bodyData:       `{"custom_field":{"type":"string"}}`,
+		expectedCode:   http.StatusForbidden,
+		responseRegexp: `"type":"/problems/forbidden"`,
+		msg:            "invalid - schema is changed only by admin and status 403",
+	},
+	{
+		description:    "Wrong field - schema",
+		method:         http.MethodPut,
+		url:            "/fields/Team",
+		key:            "key-alice",
+		bodyData:       `{"custom_field":{"type":"list"}}`,
+		expectedCode:   http.StatusUnprocessableEntity,
+		responseRegexp: `"invalid_params":\[{"name":"name","reason":"[^"]+"},{"name":"type","reason":"must be one of: string, number, boolean, date"}\]`,
+		msg:            "invalid - name and type and status 422",
+	},
+	{
+		description:    "List of fields",
+		method:         http.MethodGet,
+		url:            "/fields/",
+		key:            "key-bob",
+		expectedCode:   http.StatusOK,
+		responseRegexp: `{"custom_fields":\[{"name":"component","type":"string",[^}]+},{"name":"estimate",`,
+		msg:            "valid - fields in order of name are read by all and status 200",
+	},
+	{
+		description:    "Wrong task - custom fields",
+		method:         http.MethodPost,
+		url:            "/task/",
+		key:            "key-bob",
+		bodyData:       `{"task_update":{"description":"Fix login","custom_fields":{"component":"db","estimate":"2","team":"core"}}}`,
+		expectedCode:   http.StatusUnprocessableEntity,
+		responseRegexp: `"invalid_params":\[{"name":"custom_fields.component","reason":"must be one of api, web"},{"name":"custom_fields.estimate","reason":"must be number"},{"name":"custom_fields.team","reason":"unknown custom field"}\]`,
+		msg:            "invalid - values by schema and status 422",
+	},
+	{
+		description:    "Wrong task - required field",
+		method:         http.MethodPost,
+		url:            "/task/",
+		key:            "key-bob",
+		bodyData:       `{"task_update":{"description":"Fix login"}}`,
+		expectedCode:   http.StatusUnprocessableEntity,
+		responseRegexp: `"invalid_params":\[{"name":"custom_fields.component","reason":"required"}\]`,
+		msg:            "invalid - required field and status 422",
+	},
+	{
+		description:    "Create task",
+		method:         http.MethodPost,
+		url:            "/task/",
+		key:            "key-bob",
+		bodyData:       `{"task_update":{"description":"Fix login","custom_fields":{"component":"api","estimate":2}}}`,
+		expectedCode:   http.StatusCreated,
+		responseRegexp: `{"task":1}`,
+		msg:            "valid - task with custom fields and status 201",
+	},
+	{
+		description:    "Create task",
+		method:         http.MethodPost,
+		url:            "/task/",
+		key:            "key-bob",
+		bodyData:       `{"task_update":{"description":"New page","custom_fields":{"component":"web"}}}`,
+		expectedCode:   http.StatusCreated,
+		responseRegexp: `{"task":2}`,
+		msg:            "valid - second task and status 201",
+	},
+	{
+		description:    "Task with custom fields",
+		method:         http.MethodGet,
+		url:            "/task/1",
+		key:            "key-bob",
+		expectedCode:   http.StatusOK,
+		responseRegexp: `"custom_fields":{"component":"api","estimate":2}`,
+		msg:            "valid - custom fields in task and status 200",
+	},
+	{
+		description:    "Search by custom fields",
+		method:         http.MethodGet,
+		url:            "/task/?cf.component=web",
+		key:            "key-bob",
+		expectedCode:   http.StatusOK,
+		responseRegexp: `^{"task_list":\[{"description":"New page",[^\]]+"custom_fields":{"component":"web"}}\]}`,
+		msg:            "valid - only tasks with value of field and status 200",
+	},
+	{
+		description:    "Search without result",
+		method:         http.MethodGet,
+		url:            "/task/?cf.component=api&cf.estimate=3",
+		key:            "key-bob",
+		expectedCode:   http.StatusOK,
+		responseRegexp: `^{"task_list":\[\]}`,
+		msg:            "valid - empty list and status 200",
+	},
+	{
+		description:    "Wrong search - params",
+		method:         http.MethodGet,
+		url:            "/task/?cf.team=core&cf.estimate=x",
+		key:            "key-bob",
+		expectedCode:   http.StatusBadRequest,
+		responseRegexp: `"invalid_params":\[{"name":"cf.estimate","reason":"must be number"},{"name":"cf.team","reason":"unknown custom field"}\]`,
+		msg:            "invalid - unknown field and value and status 400",
+	},
+	{
+		description:    "Wrong search - limit",
+		method:         http.MethodGet,
+		url:            "/task/?limit=5000",
+		key:            "key-bob",
+		expectedCode:   http.StatusBadRequest,
+		responseRegexp: `"invalid_params":\[{"name":"limit","reason":"must be number from 1 to 1000"}\]`,
+		msg:            "invalid - limit and status 400",
+	},
+	{
+		description:    "Patch custom fields",
+		method:         http.MethodPatch,
+		url:            "/task/1",
+		key:            "key-bob",
+		contentType:    c.MediaMergePatch,
+		bodyData:       `{"custom_fields":{"estimate":null}}`,
+		expectedCode:   http.StatusOK,
+		responseRegexp: `"custom_fields":{"component":"api"}}}\n$`,
+		msg:            "valid - value is removed by merge patch and status 200",
+	},
+	{
+		description:    "Delete field",
+		method:         http.MethodDelete,
+		url:            "/fields/estimate",
+		key:            "key-alice",
+		expectedCode:   http.StatusOK,
+		responseRegexp: `{"custom_field":"deleted"}`,
+		msg:            "valid - field is deleted and status 200",
+	},
+	{
+		description:    "Wrong delete - no field",
+		method:         http.MethodDelete,
+		url:            "/fields/estimate",
+		key:            "key-alice",
+		expectedCode:   http.StatusNotFound,
+		responseRegexp: `"type":"/problems/not-found"`,
+		msg:            "invalid - field not found and status 404",
+	},
+}
+
+func TestRouteCustomFields(t *testing.T) {
+	asserts := assert.New(t)
+	requires := require.New(t)
+
+	r := chi.NewRouter()
+	cfg := &config.Config{ErrorFormat: vr.ErrorFormatProblem, APIKeys: "alice:key-alice,bob:key-bob", Admins: "alice"}
+	NewTransport(r, cfg).Routes(NewTasksMock())
+
+	for i, test := range customFieldTestData {
+		log.Printf("\t %d test custom fields: %s\n", i+1, test.description)
+		req, err := http.NewRequest(test.method, test.url, strings.NewReader(test.bodyData))
+		requires.NoError(err, "http.NewRequest error")
+		contentType := test.contentType
+		if contentType == "" {
+			contentType = "application/json"
+		}
+		req.Header.Set("Content-Type", contentType)
+		req.Header.Set("Authorization", "Bearer "+test.key)
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+
+		asserts.Equal(test.expectedCode, w.Code, test.msg)
+		asserts.Regexp(test.responseRegexp, w.Body.String(), test.msg)
+	}
+}
+
+var projectTestData = []struct {
+	description    string
+	method         string
+	url            string
+	bodyData       string
+	expectedCode   int
+	responseRegexp string
+	msg            string
+}{
+	{
+		description:    "Create project",
+		method:         http.MethodPost,
+		url:            "/project/",
+		bodyData:       `{"project":{"name":"Backend","description":"API and store","position":2}}`,
+		expectedCode:   http.StatusCreated,
+		responseRegexp: `{"project":{"id":1,"name":"Backend","description":"API and store","archived":false,"position":2,"created_at":"[^"]+","tasks":{"open":0,"done":0}}}`,
+		msg:            "valid - project is created and status 201",
+	},
+	{
+		description:    "Create project",
+		method:         http.MethodPost,
+		url:            "/project/",
+		bodyData:       `{"project":{"name":"Frontend","position":1}}`,
+		expectedCode:   http.StatusCreated,
+		responseRegexp: `{"project":{"id":2,"name":"Frontend",`,
+		msg:            "valid - second project and status 201",
+	},
+	{
+		description:    "Wrong project - rules",
+		method:         http.MethodPost,
+		url:            "/project/",
+		bodyData:       `{"project":{"name":" ","position":-1}}`,
+		expectedCode:   http.StatusUnprocessableEntity,
+		responseRegexp: `"invalid_params":\[{"name":"name","reason":"required"},{"name":"position","reason":"must be number from 0 to 1000000"}\]`,
+		msg:            "invalid - name and position and status 422",
+	},
+	{
+		description:    "Create task of project",
+		method:         http.MethodPost,
+		url:            "/task/",
+		bodyData:       `{"task_update":{"description":"Add index","project_id":1}}`,
+		expectedCode:   http.StatusCreated,
+		responseRegexp: `{"task":1}`,
+		msg:            "valid - task of project and status 201",
+	},
+	{
+		description:    "Create done task of project",
+		method:         http.MethodPost,
+		url:            "/task/",
+		bodyData:       `{"task_update":{"description":"Write migration","project_id":1,"done":true}}`,
+		expectedCode:   http.StatusCreated,
+		responseRegexp: `{"task":2}`,
+		msg:            "valid - done task of project and status 201",
+	},
+	{
+		description:    "Create task without project",
+		method:         http.MethodPost,
+		url:            "/task/",
+		bodyData:       `{"task_update":{"description":"Read mail"}}`,
+		expectedCode:   http.StatusCreated,
+		responseRegexp: `{"task":3}`,
+		msg:            "valid - task without project and status 201",
+	},
+	{
+		description:    "Wrong task - unknown project",
+		method:         http.MethodPost,
+		url:            "/task/",
+		bodyData:       `{"task_update":{"description":"Lost","project_id":100}}`,
+		expectedCode:   http.StatusUnprocessableEntity,
+		responseRegexp: `"invalid_params":\[{"name":"project_id","reason":"unknown project"}\]`,
+		msg:            "invalid - project must exist and status 422",
+	},
+	{
+		description:    "Project with counts",
+		method:         http.MethodGet,
+		url:            "/project/1",
+		expectedCode:   http.StatusOK,
+		responseRegexp: `"tasks":{"open":1,"done":1}}}`,
+		msg:            "valid - counts of open and done tasks and status 200",
+	},
+	{
+		description:    "List of projects",
+		method:         http.MethodGet,
+		url:            "/project/",
+		expectedCode:   http.StatusOK,
+		responseRegexp: `^{"projects":\[{"id":2,"name":"Frontend",[^\]]+},{"id":1,"name":"Backend",[^\]]+}\]}`,
+		msg:            "valid - projects in order of position and status 200",
+	},
+	{
+		description:    "Tasks of project",
+		method:         http.MethodGet,
+		url:            "/project/1/tasks?order=desc",
+		expectedCode:   http.StatusOK,
+		responseRegexp: `^{"task_list":\[{"description":"Write migration",[^}]+"project_id":1,"done":true},{"description":"Add index",[^}]+"project_id":1}\]}`,
+		msg:            "valid - only tasks of project and status 200",
+	},
+	{
+		description:    "Archive project",
+		method:         http.MethodPut,
+		url:            "/project/1",
+		bodyData:       `{"project":{"name":"Backend","archived":true,"position":2}}`,
+		expectedCode:   http.StatusOK,
+		responseRegexp: `{"project":{"id":1,"name":"Backend","archived":true,"position":2,"created_at":"[^"]+","updated_at":"[^"]+","tasks":{"open":1,"done":1}}}`,
+		msg:            "valid - project is archived and status 200",
+	},
+	{
+		description:    "List without archived",
+		method:         http.MethodGet,
+		url:            "/project/",
+		expectedCode:   http.StatusOK,
+		responseRegexp: `^{"projects":\[{"id":2,[^\]]+}\]}`,
+		msg:            "valid - archived project is hidden and status 200",
+	},
+	{
+		description:    "List with archived",
+		method:         http.MethodGet,
+		url:            "/project/?archived=true",
+		expectedCode:   http.StatusOK,
+		responseRegexp: `"id":1,"name":"Backend","archived":true`,
+		msg:            "valid - archived project by param and status 200",
+	},
+	{
+		description:    "Default listing",
+		method:         http.MethodGet,
+		url:            "/task/asc/10/0",
+		expectedCode:   http.StatusOK,
+		responseRegexp: `^{"task_list":\[{"description":"Read mail",[^\]]+\]}`,
+		msg:            "valid - tasks of archived project are hidden and status 200",
+	},
+	{
+		description:    "Search with archived",
+		method:         http.MethodGet,
+		url:            "/task/?archived=true",
+		expectedCode:   http.StatusOK,
+		responseRegexp: `"Add index",[^\]]+"Write migration",[^\]]+"Read mail"`,
+		msg:            "valid - tasks of archived project by param and status 200",
+	},
+	{
+		description:    "Tasks of archived project",
+		method:         http.MethodGet,
+		url:            "/project/1/tasks",
+		expectedCode:   http.StatusOK,
+		responseRegexp: `^{"task_list":\[{"description":"Add index",[^\]]+"Write migration"`,
+		msg:            "valid - project shows own tasks and status 200",
+	},
+	{
+		description:    "Wrong tasks - no project",
+		method:         http.MethodGet,
+		url:            "/project/100/tasks",
+		expectedCode:   http.StatusNotFound,
+		responseRegexp: `"type":"/problems/not-found"`,
+		msg:            "invalid - project not found and status 404",
+	},
+	{
+		description:    "Delete project",
+		method:         http.MethodDelete,
+		url:            "/project/1",
+		expectedCode:   http.StatusOK,
+		responseRegexp: `{"project":"deleted"}`,
+		msg:            "valid - project is deleted and status 200",
+	},
+	{
+		description:    "Task without deleted project",
+		method:         http.MethodGet,
+		url:            "/task/2",
+		expectedCode:   http.StatusOK,
+		responseRegexp: `{"task":{"description":"Write migration","created_at":"[^"]+","done":true}}`,
+		msg:            "valid - task is kept without project and status 200",
+	},
+	{
+		description:    "Wrong project - id",
+		method:         http.MethodGet,
+		url:            "/project/x",
+		expectedCode:   http.StatusBadRequest,
+		responseRegexp: `"type":"/problems/invalid-params"`,
+		msg:            "invalid - id of project and status 400",
+	},
+}
+
+func TestRouteProjects(t *testing.T) {
+	asserts := assert.New(t)
+	requires := require.New(t)
+
+	r := chi.NewRouter()
+	NewTransport(r, &config.Config{ErrorFormat: vr.ErrorFormatProblem}).Routes(NewTasksMock())
+
+	for i, test := range projectTestData {
+		log.Printf("\t %d test projects: %s\n", i+1, test.description)
+		req, err := http.NewRequest(test.method, test.url, strings.NewReader(test.bodyData))
+		requires.NoError(err, "http.NewRequest error")
+		req.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+
+		asserts.Equal(test.expectedCode, w.Code, test.msg)
+		asserts.Regexp(test.responseRegexp, w.Body.String(), test.msg)
+	}
+}
+
+var boardTestData = []struct {
+	description    string
+	method         string
+	url            string
+	bodyData       string
+	expectedCode   int
+	responseRegexp string
+	msg            string
+}{
+	{
+		description:    "Create task",
+		method:         http.MethodPost,
+		url:            "/task/",
+		bodyData:       `{"task_update":{"description":"Design"}}`,
+		expectedCode:   http.StatusCreated,
+		responseRegexp: `{"task":1}`,
+		msg:            "valid - task in default column and status 201",
+	},
+	{
+		description:    "Create task",
+		method:         http.MethodPost,
+		url:            "/task/",
+		bodyData:       `{"task_update":{"description":"Build"}}`,
+		expectedCode:   http.StatusCreated,
+		responseRegexp: `{"task":2}`,
+		msg:            "valid - second task and status 201",
+	},
+	{
+		description:    "Create task",
+		method:         http.MethodPost,
+		url:            "/task/",
+		bodyData:       `{"task_update":{"description":"Review"}}`,
+		expectedCode:   http.StatusCreated,
+		responseRegexp: `{"task":3}`,
+		msg:            "valid - third task and status 201",
+	},
+	{
+		description:    "Move to empty column",
+		method:         http.MethodPost,
+		url:            "/task/1/move",
+		bodyData:       `{"move":{"status":"doing"}}`,
+		expectedCode:   http.StatusOK,
+		responseRegexp: `{"task":{"id":1,"status":"doing","position":"V","description":"Design",`,
+		msg:            "valid - middle key of empty column and status 200",
+	},
+	{
+		description:    "Move after task",
+		method:         http.MethodPost,
+		url:            "/task/2/move",
+		bodyData:       `{"move":{"status":"doing","after":1}}`,
+		expectedCode:   http.StatusOK,
+		responseRegexp: `{"task":{"id":2,"status":"doing","position":"l",`,
+		msg:            "valid - key after anchor and status 200",
+	},
+	{
+		description:    "Move before task",
+		method:         http.MethodPost,
+		url:            "/task/3/move",
+		bodyData:       `{"move":{"status":"doing","before":2}}`,
+		expectedCode:   http.StatusOK,
+		responseRegexp: `{"task":{"id":3,"status":"doing","position":"d",`,
+		msg:            "valid - key between neighbours and status 200",
+	},
+	{
+		description:    "Column of board",
+		method:         http.MethodGet,
+		url:            "/task/board?status=doing",
+		expectedCode:   http.StatusOK,
+		responseRegexp: `^{"board":\[{"status":"doing","tasks":\[{"id":1,.*{"id":3,.*{"id":2,[^\]]*\]}\]}\n$`,
+		msg:            "valid - one column in order of keys and status 200",
+	},
+	{
+		description:    "Create unranked tasks",
+		method:         http.MethodPost,
+		url:            "/task/",
+		bodyData:       `{"task_update":{"description":"Deploy"}}`,
+		expectedCode:   http.StatusCreated,
+		responseRegexp: `{"task":4}`,
+		msg:            "valid - task without key and status 201",
+	},
+	{
+		description:    "Create unranked tasks",
+		method:         http.MethodPost,
+		url:            "/task/",
+		bodyData:       `{"task_update":{"description":"Announce"}}`,
+		expectedCode:   http.StatusCreated,
+		responseRegexp: `{"task":5}`,
+		msg:            "valid - second task without key and status 201",
+	},
+	{
+		description:    "Move between equal keys",
+		method:         http.MethodPost,
+		url:            "/task/3/move",
+		bodyData:       `{"move":{"status":"todo","after":4,"before":5}}`,
+		expectedCode:   http.StatusOK,
+		responseRegexp: `{"task":{"id":3,"status":"todo","position":"U",`,
+		msg:            "valid - column is rebalanced first and status 200",
+	},
+	{
+		description:    "Board",
+		method:         http.MethodGet,
+		url:            "/task/board",
+		expectedCode:   http.StatusOK,
+		responseRegexp: `^{"board":\[{"status":"doing","tasks":\[{"id":1,.*{"id":2,.*\]},{"status":"todo","tasks":\[{"id":4,"status":"todo","position":"K",.*{"id":3,.*{"id":5,"status":"todo","position":"e",`,
+		msg:            "valid - columns in order and status 200",
+	},
+	{
+		description:    "Wrong move - anchor in other column",
+		method:         http.MethodPost,
+		url:            "/task/4/move",
+		bodyData:       `{"move":{"after":1}}`,
+		expectedCode:   http.StatusUnprocessableEntity,
+		responseRegexp: `"invalid_params":\[{"name":"after",`,
+		msg:            "invalid - anchor is not in column and status 422",
+	},
+	{
+		description:    "Wrong move - rules",
+		method:         http.MethodPost,
+		url:            "/task/4/move",
+		bodyData:       `{"move":{"status":"Doing","after":4,"before":4}}`,
+		expectedCode:   http.StatusUnprocessableEntity,
+		responseRegexp: `"invalid_params":\[{"name":"status","reason":"[^"]+"},{"name":"after","reason":"must be other task"},{"name":"before","reason":"must be other task"},{"name":"before","reason":"must be other task than 'after'"}\]`,
+		msg:            "invalid - status and anchors and status 422",
+	},
+	{
+		description:    "Wrong move - not found",
+		method:         http.MethodPost,
+		url:            "/task/100/move",
+		bodyData:       `{"move":{"status":"doing"}}`,
+		expectedCode:   http.StatusNotFound,
+		responseRegexp: `"type":"/problems/not-found"`,
+		msg:            "invalid - unknown task and status 404",
+	},
+	{
+		description:    "Wrong move - id",
+		method:         http.MethodPost,
+		url:            "/task/one/move",
+		bodyData:       `{"move":{"status":"doing"}}`,
+		expectedCode:   http.StatusBadRequest,
+		responseRegexp: `"type":"/problems/`,
+		msg:            "invalid - id is not number and status 400",
+	},
+	{
+		description:    "Wrong board - status",
+		method:         http.MethodGet,
+		url:            "/task/board?status=To%20do",
+		expectedCode:   http.StatusBadRequest,
+		responseRegexp: `"invalid_params":\[{"name":"status",`,
+		msg:            "invalid - status of filter and status 400",
+	},
+}
+
+func TestRouteBoard(t *testing.T) {
+	asserts := assert.New(t)
+	requires := require.New(t)
+
+	r := chi.NewRouter()
+	NewTransport(r, &config.Config{ErrorFormat: vr.ErrorFormatProblem}).Routes(NewTasksMock())
+
+	for i, test := range boardTestData {
+		log.Printf("\t %d test board: %s\n", i+1, test.description)
+		req, err := http.NewRequest(test.method, test.url, strings.NewReader(test.bodyData))
+		requires.NoError(err, "http.NewRequest error")
+		req.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+
+		asserts.Equal(test.expectedCode, w.Code, test.msg)
+		asserts.Regexp(test.responseRegexp, w.Body.String(), test.msg)
+	}
+}
+
+var batchTestData = []struct {
+	description    string
+	bodyData       string
+	expectedCode   int
+	responseRegexp string
+	expectedTasks  int
+	msg            string
+}{
+	{
+		description: "Atomic batch",
+		bodyData: `{"task_batch":{"mode":"atomic","operations":[
+{"op":"create","task_update":{"description":"one"}},
+{"op":"create","task_update":{"description":"two","note":"2"}},
+{"op":"update","id":1,"task_update":{"description":"one up"}}]}}`,
+		expectedCode:   http.StatusOK,
+		responseRegexp: `{"task_batch":\[{"index":0,"op":"create","status":201,"id":2},{"index":1,"op":"create","status":201,"id":3},{"index":2,"op":"update","status":200,"id":1}\]}`,
+		expectedTasks:  3,
+		msg:            "valid - all operations must be applied and status 200",
+	},
+	{
+		description: "Wrong atomic batch - not found",
+		bodyData: `{"task_batch":{"operations":[
+{"op":"create","task_update":{"description":"three"}},
+{"op":"delete","id":200}]}}`,
+		expectedCode:   http.StatusNotFound,
+		responseRegexp: `"type":"/problems/not-found"`,
+		expectedTasks:  3,
+		msg:            "invalid - nothing must be applied and status 404",
+	},
+	{
+		description: "Wrong atomic batch - invalid operation",
+		bodyData: `{"task_batch":{"operations":[
+{"op":"create","task_update":{"description":"three"}},
+{"op":"update","task_update":{"description":""}}]}}`,
+		expectedCode:   http.StatusUnprocessableEntity,
+		responseRegexp: `"invalid_params":\[{"name":"operations\[1\].id","reason":"required"},{"name":"operations\[1\].task_update.description","reason":"required"}\]`,
+		expectedTasks:  3,
+		msg:            "invalid - all field errors of batch and status 422",
+	},
+	{
+		description: "Best effort batch",
+		bodyData: `{"task_batch":{"mode":"best_effort","operations":[
+{"op":"delete","id":2},
+{"op":"delete","id":200},
+{"op":"move","id":3},
+{"op":"create","task_update":{"description":"four"}}]}}`,
+		expectedCode:   http.StatusMultiStatus,
+		responseRegexp: `{"task_batch":\[{"index":0,"op":"delete","status":200,"id":2},{"index":1,"op":"delete","status":404,"error":"not found"},{"index":2,"op":"move","status":422,"error":"[^"]+"},{"index":3,"op":"create","status":201,"id":4}\]}`,
+		expectedTasks:  3,
+		msg:            "valid - each operation by itself and status 207",
+	},
+	{
+		description:    "Wrong batch - empty",
+		bodyData:       `{"task_batch":{"operations":[]}}`,
+		expectedCode:   http.StatusUnprocessableEntity,
+		responseRegexp: `invalid task batch`,
+		expectedTasks:  3,
+		msg:            "invalid - empty batch and status 422",
+	},
+}
+
+func TestRouteBatch(t *testing.T) {
+	asserts := assert.New(t)
+	requires := require.New(t)
+
+	base := NewTasksMock()
+	_, err := base.SaveOneTask(context.Background(), model.Task{Description: "first"})
+	requires.NoError(err)
+	r := chi.NewRouter()
+	NewTransport(r, &config.Config{ErrorFormat: vr.ErrorFormatProblem}).Routes(base)
+
+	for i, test := range batchTestData {
+		log.Printf("\t %d test batch: %s\n", i+1, test.description)
+		bodyData := strings.Replace(test.bodyData, "\n", "", -1)
+		req, err := http.NewRequest(http.MethodPost, "/task/batch", strings.NewReader(bodyData))
+		requires.NoError(err, "http.NewRequest error")
+		req.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+
+		asserts.Equal(test.expectedCode, w.Code, test.msg)
+		asserts.Regexp(test.responseRegexp, w.Body.String(), test.msg)
+		asserts.Len(base.tasks, test.expectedTasks, test.msg)
+	}
+}
+
+var importTestData = []struct {
+	description    string
+	url            string
+	contentType    string
+	bodyData       string
+	expectedCode   int
+	responseRegexp string
+	expectedTasks  int
+	msg            string
+}{
+	{
+		description:    "Import CSV",
+		url:            "/task/import",
+		contentType:    "text/csv",
+		bodyData:       "description,note\none,first\n,empty\n\"two\nlines\",\n",
+		expectedCode:   http.StatusCreated,
+		responseRegexp: `{"task_import":{"imported":2,"dry_run":false,"rejected_count":1,"rejected":\[{"line":3,"errors":\[{"name":"description","reason":"required"}\]}\]}}`,
+		expectedTasks:  2,
+		msg:            "valid - two rows must be imported, row on line 3 rejected and status 201",
+	},
+	{
+		description:    "Import NDJSON dry run",
+		url:            "/task/import?format=ndjson&dry_run=true",
+		contentType:    "application/octet-stream",
+		bodyData:       "{\"description\":\"three\"}\n\n{\"description\":\"four\",\"note\":\"4\"}\n",
+		expectedCode:   http.StatusOK,
+		responseRegexp: `{"task_import":{"imported":2,"dry_run":true,"rejected_count":0}}`,
+		expectedTasks:  2,
+		msg:            "valid - nothing must be saved in dry run and status 200",
+	},
+	{
+		description:    "Wrong import - broken NDJSON",
+		url:            "/task/import",
+		contentType:    "application/x-ndjson",
+		bodyData:       "{\"description\":\"five\"}\n{\"description\":",
+		expectedCode:   http.StatusBadRequest,
+		responseRegexp: `line 2`,
+		expectedTasks:  2,
+		msg:            "invalid - import must be rejected with line and status 400",
+	},
+	{
+		description:    "Wrong import - CSV without column description",
+		url:            "/task/import?format=csv",
+		contentType:    "text/plain",
+		bodyData:       "title,note\none,first\n",
+		expectedCode:   http.StatusBadRequest,
+		responseRegexp: `column description`,
+		expectedTasks:  2,
+		msg:            "invalid - header is broken and status 400",
+	},
+	{
+		description:    "Wrong import - format",
+		url:            "/task/import",
+		contentType:    "application/xml",
+		bodyData:       "<task/>",
+		expectedCode:   http.StatusUnsupportedMediaType,
+		responseRegexp: `invalid import format`,
+		expectedTasks:  2,
+		msg:            "invalid - unsupported format and status 415",
+	},
+}
+
+func TestRouteImport(t *testing.T) {
+	asserts := assert.New(t)
+	requires := require.New(t)
+
+	base := NewTasksMock()
+	r := chi.NewRouter()
+	NewTransport(r, &config.Config{ErrorFormat: vr.ErrorFormatProblem}).Routes(base)
+
+	for i, test := range importTestData {
+		log.Printf("\t %d test import: %s\n", i+1, test.description)
+		req, err := http.NewRequest(http.MethodPost, test.url, strings.NewReader(test.bodyData))
+		requires.NoError(err, "http.NewRequest error")
+		req.Header.Set("Content-Type", test.contentType)
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+
+		asserts.Equal(test.expectedCode, w.Code, test.msg)
+		asserts.Regexp(test.responseRegexp, w.Body.String(), test.msg)
+		asserts.Len(base.tasks, test.expectedTasks, test.msg)
+	}
+
+	log.Printf("\t %d test import: rows are checked by rules of create\n", len(importTestData)+1)
+	for _, row := range []string{
+		`{"description":"   "}`,
+		`{"description":"` + strings.Repeat("я", 2049) + `","note":"bell \u0007"}`,
+		`{"description":"ok","note":"` + strings.Repeat("n", 2049) + `"}`,
+	} {
+		req, err := http.NewRequest(http.MethodPost, "/task/", strings.NewReader(`{"task_update":`+row+`}`))
+		requires.NoError(err, "http.NewRequest error")
+		req.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		problem := c.Problem{}
+		requires.NoError(json.Unmarshal(w.Body.Bytes(), &problem))
+
+		req, err = http.NewRequest(http.MethodPost, "/task/import?format=ndjson&dry_run=true", strings.NewReader(row+"\n"))
+		requires.NoError(err, "http.NewRequest error")
+		w = httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		result := struct {
+			TaskImport servises.ImportResponse `json:"task_import"`
+		}{}
+		requires.NoError(json.Unmarshal(w.Body.Bytes(), &result))
+		var rejected []c.InvalidParam
+		if len(result.TaskImport.Rejected) > 0 {
+			rejected = result.TaskImport.Rejected[0].Errors
+		}
+		asserts.NotEmpty(rejected, "invalid - row is rejected - %s", row[:20])
+		asserts.Equal(problem.InvalidParams, rejected, "valid - the same reasons of row as of create - %s", row[:20])
+	}
+
+	log.Printf("\t %d test import: body above buffer of Idempotency-Key\n", len(importTestData)+2)
+	base = NewTasksMock()
+	r = chi.NewRouter()
+	NewTransport(r, &config.Config{ErrorFormat: vr.ErrorFormatProblem}).
+		WithIdempotency(NewIdempotencyMock(), time.Hour).Routes(base)
+	line := "{\"description\":\"stream\"}\n"
+	rows := maxIdempotentBody/len(line) + 1
+	req, err := http.NewRequest(http.MethodPost, "/task/import", strings.NewReader(strings.Repeat(line, rows)))
+	requires.NoError(err, "http.NewRequest error")
+	req.Header.Set("Content-Type", "application/x-ndjson")
+	req.Header.Set("Idempotency-Key", "import")
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	asserts.Equal(http.StatusCreated, w.Code, "valid - import is a stream, it is not read by 'Idempotency'")
+	asserts.Len(base.tasks, rows)
+}
+
+var exportTestData = []struct {
+	description    string
+	url            string
+	expectedCode   int
+	expectedType   string
+	responseRegexp string
+	msg            string
+}{
+	{
+		description:    "Export CSV",
+		url:            "/task/export?format=csv&order=desc",
+		expectedCode:   http.StatusOK,
+		expectedType:   "text/csv; charset=utf-8",
+		responseRegexp: `^id,description,note,created_at,updated_at\n2,"two, with comma",,2024-05-02T00:00:00Z,\n1,one,first,2024-05-01T00:00:00Z,\n$`,
+		msg:            "valid - all tasks in CSV and status 200",
+	},
+	{
+		description:    "Export NDJSON with filter",
+		url:            "/task/export?format=ndjson&created_from=2024-05-02T00:00:00Z",
+		expectedCode:   http.StatusOK,
+		expectedType:   "application/x-ndjson",
+		responseRegexp: `^{"id":2,"description":"two, with comma","created_at":"2024-05-02T00:00:00Z"}\n$`,
+		msg:            "valid - only filtered task and status 200",
+	},
+	{
+		description:    "Export JSON",
+		url:            "/task/export",
+		expectedCode:   http.StatusOK,
+		expectedType:   "application/json",
+		responseRegexp: `^\[\n{"id":1,[^\]]+},\n{"id":2,[^\]]+}\n\]\n$`,
+		msg:            "valid - JSON array and status 200",
+	},
+	{
+		description:    "Export empty JSON",
+		url:            "/task/export?created_to=2000-01-01T00:00:00Z",
+		expectedCode:   http.StatusOK,
+		expectedType:   "application/json",
+		responseRegexp: `^\[\]\n$`,
+		msg:            "valid - empty JSON array and status 200",
+	},
+	{
+		description:    "Wrong export - format",
+		url:            "/task/export?format=xlsx",
+		expectedCode:   http.StatusBadRequest,
+		expectedType:   c.MediaProblemJSON,
+		responseRegexp: `invalid export format`,
+		msg:            "invalid - unknown format and status 400",
+	},
+	{
+		description:    "Wrong export - filter",
+		url:            "/task/export?created_from=yesterday",
+		expectedCode:   http.StatusBadRequest,
+		expectedType:   c.MediaProblemJSON,
+		responseRegexp: `"name":"created_from"`,
+		msg:            "invalid - broken time and status 400",
+	},
+}
+
+func TestRouteExport(t *testing.T) {
+	asserts := assert.New(t)
+	requires := require.New(t)
+
+	base := NewTasksMock()
+	for i, task := range []model.Task{
+		{Description: "one", Note: "first", CreatedAt: time.Date(2024, 5, 1, 0, 0, 0, 0, time.UTC)},
+		{Description: "two, with comma", CreatedAt: time.Date(2024, 5, 2, 0, 0, 0, 0, time.UTC)},
+	} {
+		_, err := base.SaveOneTask(context.Background(), task)
+		requires.NoError(err, i)
+	}
+	r := chi.NewRouter()
+	NewTransport(r, &config.Config{ErrorFormat: vr.ErrorFormatProblem}).Routes(base)
+
+	for i, test := range exportTestData {
+		log.Printf("\t %d test export: %s\n", i+1, test.description)
+		req, err := http.NewRequest(http.MethodGet, test.url, nil)
+		requires.NoError(err, "http.NewRequest error")
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+
+		asserts.Equal(test.expectedCode, w.Code, test.msg)
+		asserts.Equal(test.expectedType, w.Header().Get("Content-Type"), test.msg)
+		asserts.Regexp(test.responseRegexp, w.Body.String(), test.msg)
+		if test.expectedCode == http.StatusOK {
+			asserts.Contains(w.Header().Get("Content-Disposition"), "attachment; filename=tasks.", test.msg)
+		}
+	}
+
+	log.Printf("\t %d test export: stream ends at shutdown of server\n", len(exportTestData)+1)
+	blocked := &exportBlockMock{TasksMock: base, started: make(chan struct{})}
+	r = chi.NewRouter()
+	routes := NewTransport(r, &config.Config{ErrorFormat: vr.ErrorFormatProblem})
+	routes.Routes(blocked)
+	srv := httptest.NewServer(r)
+	defer srv.Close()
+	srv.Config.RegisterOnShutdown(routes.CloseStreams)
+	read := make(chan error, 1)
+	go func() {
+		resp, err := http.Get(srv.URL + "/task/export?format=ndjson")
+		if err != nil {
+			read <- err
+			return
+		}
+		defer resp.Body.Close()
+		_, err = io.ReadAll(resp.Body)
+		read <- err
+	}()
+	<-blocked.started
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	asserts.NoError(srv.Config.Shutdown(ctx), "valid - export does not hold shutdown")
+	select {
+	case <-read:
+	case <-time.After(2 * time.Second):
+		asserts.Fail("invalid - stream is not ended")
+	}
+}
+
+// exportBlockMock - export writes one 'Task' and waits for end of request
+type exportBlockMock struct {
+	*TasksMock
+	started chan struct{}
+}
+
+func (m *exportBlockMock) ExportTasks(ctx context.Context, data any) (int, error) {
+	export := data.(model.TaskExport)
+	if err := export.Each(model.Task{ID: 1, Description: "one"}); err != nil {
+		return 0, err
+	}
+	close(m.started)
+	<-ctx.Done()
+	return 1, ctx.Err()
+}
+
+var calendarTestData = []struct {
+	description    string
+	url            string
+	expectedCode   int
+	expectedType   string
+	responseRegexp string
+	msg            string
+}{
+	{
+		description:    "Calendar of events",
+		url:            "/task/calendar.ics?token=" + FeedToken("calendar-secret-key", "alice"),
+		expectedCode:   http.StatusOK,
+		expectedType:   "text/calendar; charset=utf-8",
+		responseRegexp: `(?s)^BEGIN:VCALENDAR\r\n.*UID:task-2@.*DTSTART;TZID=Europe/Berlin:20250303T090000\r\nRRULE:FREQ=WEEKLY;BYDAY=MO\r\n.*TRIGGER:-PT30M\r\n.*UID:task-3@.*DTSTART:20250310T120000Z\r\n.*BEGIN:VTIMEZONE\r\nTZID:Europe/Berlin\r\n.*BEGIN:DAYLIGHT\r\n.*END:VCALENDAR\r\n$`,
+		msg:            "valid - tasks with due time, recurring task from DTSTART, zone and status 200",
+	},
+	{
+		description:    "Calendar of to-dos, limit",
+		url:            "/task/calendar.ics?component=vtodo&limit=1&token=" + FeedToken("calendar-secret-key", "bob"),
+		expectedCode:   http.StatusOK,
+		expectedType:   "text/calendar; charset=utf-8",
+		responseRegexp: `(?s)^BEGIN:VCALENDAR\r\n.*BEGIN:VTODO\r\nUID:task-2@.*DUE;TZID=Europe/Berlin:20250331T090000\r\n.*TRIGGER;RELATED=END:-PT30M\r\n.*END:VTODO\r\nBEGIN:VTIMEZONE`,
+		msg:            "valid - one current occurrence as VTODO and status 200",
+	},
+	{
+		description:    "Wrong calendar - token of other secret",
+		url:            "/task/calendar.ics?token=" + FeedToken("other-secret-key", "alice"),
+		expectedCode:   http.StatusUnauthorized,
+		expectedType:   c.MediaProblemJSON,
+		responseRegexp: `invalid feed token`,
+		msg:            "invalid - forged token and status 401",
+	},
+	{
+		description:    "Wrong calendar - unknown user",
+		url:            "/task/calendar.ics?token=" + FeedToken("calendar-secret-key", "carol"),
+		expectedCode:   http.StatusUnauthorized,
+		expectedType:   c.MediaProblemJSON,
+		responseRegexp: `invalid feed token`,
+		msg:            "invalid - user is not in keys and status 401",
+	},
+	{
+		description:    "Wrong calendar - component",
+		url:            "/task/calendar.ics?component=VJOURNAL&token=" + FeedToken("calendar-secret-key", "alice"),
+		expectedCode:   http.StatusBadRequest,
+		expectedType:   c.MediaProblemJSON,
+		responseRegexp: `invalid calendar component`,
+		msg:            "invalid - unknown component and status 400",
+	},
+}
+
+func timePtr(t time.Time) *time.Time {
+	return &t
+}
+
+func TestRouteCalendar(t *testing.T) {
+	asserts := assert.New(t)
+	requires := require.New(t)
+
+	base := NewTasksMock()
+	berlin := time.FixedZone("CET", 3600)
+	for i, task := range []model.Task{
+		{Description: "no due", CreatedAt: time.Date(2025, 3, 1, 0, 0, 0, 0, time.UTC)},
+		{Description: "weekly, in zone", CreatedAt: time.Date(2025, 3, 1, 0, 0, 0, 0, time.UTC),
+			DueAt:      timePtr(time.Date(2025, 3, 31, 7, 0, 0, 0, time.UTC)),
+			RemindAt:   timePtr(time.Date(2025, 3, 31, 6, 30, 0, 0, time.UTC)),
+			TimeZone:   "Europe/Berlin",
+			Recurrence: &model.Recurrence{RRule: "FREQ=WEEKLY;BYDAY=MO", Start: time.Date(2025, 3, 3, 9, 0, 0, 0, berlin)}},
+		{Description: "once, in UTC", CreatedAt: time.Date(2025, 3, 1, 0, 0, 0, 0, time.UTC),
+			DueAt: timePtr(time.Date(2025, 3, 10, 12, 0, 0, 0, time.UTC))},
+	} {
+		_, err := base.SaveOneTask(context.Background(), task)
+		requires.NoError(err, i)
+	}
+	r := chi.NewRouter()
+	cfg := &config.Config{ErrorFormat: vr.ErrorFormatProblem, APIKeys: "alice:key-alice,bob:key-bob",
+		CalendarSecret: "calendar-secret-key"}
+	NewTransport(r, cfg).Routes(base)
+
+	for i, test := range calendarTestData {
+		log.Printf("\t %d test calendar: %s\n", i+1, test.description)
+		req, err := http.NewRequest(http.MethodGet, test.url, nil)
+		requires.NoError(err, "http.NewRequest error")
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+
+		asserts.Equal(test.expectedCode, w.Code, test.msg)
+		asserts.Equal(test.expectedType, w.Header().Get("Content-Type"), test.msg)
+		asserts.Regexp(test.responseRegexp, w.Body.String(), test.msg)
+		if test.expectedCode == http.StatusOK {
+			asserts.NotContains(w.Body.String(), "no due", test.msg)
+		}
+	}
+
+	log.Printf("\t %d test calendar: token of user\n", len(calendarTestData)+1)
+	req, err := http.NewRequest(http.MethodGet, "/task/calendar/token", nil)
+	requires.NoError(err, "http.NewRequest error")
+	req.Header.Set("Authorization", "Bearer key-alice")
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	requires.Equal(http.StatusOK, w.Code)
+	asserts.Contains(w.Body.String(), `"token":"`+FeedToken("calendar-secret-key", "alice")+`"`)
+	asserts.Contains(w.Body.String(), `"url":"/task/calendar.ics?token=alice.`)
+
+	log.Printf("\t %d test calendar: token without key\n", len(calendarTestData)+2)
+	req, err = http.NewRequest(http.MethodGet, "/task/calendar/token", nil)
+	requires.NoError(err, "http.NewRequest error")
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	asserts.Equal(http.StatusUnauthorized, w.Code)
+}
+
+var negotiationTestData = []struct {
+	description    string
+	method         string
+	url            string
+	contentType    string
+	accept         string
+	bodyData       string
+	expectedCode   int
+	expectedType   string
+	responseRegexp string
+	msg            string
+}{
+	{
+		description:    "Task create from YAML, answer in XML",
+		method:         http.MethodPost,
+		url:            "/task/",
+		contentType:    "application/yaml",
+		accept:         "application/xml",
+		bodyData:       "task_update:\n  description: from yaml\n",
+		expectedCode:   http.StatusCreated,
+		expectedType:   c.MediaXML,
+		responseRegexp: `<response><task>2</task></response>`,
+		msg:            "valid - task is created and status 201",
+	},
+	{
+		description:    "Get task in YAML",
+		method:         http.MethodGet,
+		url:            "/task/2",
+		accept:         "application/yaml",
+		expectedCode:   http.StatusOK,
+		expectedType:   c.MediaYAML,
+		responseRegexp: `task:\n    created_at: "?[^\n]+\n    description: from yaml\n`,
+		msg:            "valid - task in YAML and status 200",
+	},
+	{
+		description:    "Wrong get task - not acceptable",
+		method:         http.MethodGet,
+		url:            "/task/2",
+		accept:         "text/html",
+		expectedCode:   http.StatusNotAcceptable,
+		expectedType:   c.MediaProblemJSON,
+		responseRegexp: `not acceptable media type`,
+		msg:            "invalid - no format for Accept and status 406",
+	},
+	{
+		description:    "Wrong task create - unsupported media type",
+		method:         http.MethodPost,
+		url:            "/task/",
+		contentType:    "text/plain",
+		bodyData:       "description",
+		expectedCode:   http.StatusUnsupportedMediaType,
+		expectedType:   c.MediaProblemJSON,
+		responseRegexp: `invalid media type`,
+		msg:            "invalid - unsupported Content-Type and status 415",
+	},
+	{
+		description:    "Wrong get task - error in XML",
+		method:         http.MethodGet,
+		url:            "/task/200",
+		accept:         "application/xml",
+		expectedCode:   http.StatusNotFound,
+		expectedType:   "application/problem+xml",
+		responseRegexp: `<problem xmlns="urn:ietf:rfc:7807">`,
+		msg:            "invalid - problem in XML and status 404",
+	},
+}
+
+func TestRouteNegotiation(t *testing.T) {
+	asserts := assert.New(t)
+	requires := require.New(t)
+
+	base := NewTasksMock()
+	_, err := base.SaveOneTask(context.Background(), model.Task{Description: "first"})
+	requires.NoError(err)
+	r := chi.NewRouter()
+	NewTransport(r, &config.Config{ErrorFormat: vr.ErrorFormatProblem}).Routes(base)
+
+	for i, test := range negotiationTestData {
+		log.Printf("\t %d test negotiation: %s\n", i+1, test.description)
+		req, err := http.NewRequest(test.method, test.url, strings.NewReader(test.bodyData))
+		requires.NoError(err, "http.NewRequest error")
+		if test.contentType != "" {
+			req.Header.Set("Content-Type", test.contentType)
+		}
+		req.Header.Set("Accept", test.accept)
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+
+		asserts.Equal(test.expectedCode, w.Code, test.msg)
+		asserts.Equal(test.expectedType, w.Header().Get("Content-Type"), test.msg)
+		asserts.Regexp(test.responseRegexp, w.Body.String(), test.msg)
+	}
+}
+
+var graphqlTestData = []struct {
+	description    string
+	bodyData       string
+	expectedCode   int
+	responseRegexp string
+	findMany       int
+	msg            string
+}{
+	{
+		description:    "Create task",
+		bodyData:       `{"query":"mutation($in: TaskInput!) { createTask(input: $in) { id description note } }","variables":{"in":{"description":" graph "}}}`,
+		expectedCode:   http.StatusOK,
+		responseRegexp: `^{"data":{"createTask":{"id":"3","description":"graph","note":""}}}`,
+		msg:            "valid - task is created by rules of TaskValidator",
+	},
+	{
+		description:    "Tasks by id in one batch",
+		bodyData:       `{"query":"{ a: task(id: \"1\") { description } b: task(id: \"2\") { description } c: task(id: \"1\") { id } none: task(id: \"200\") { id } }"}`,
+		expectedCode:   http.StatusOK,
+		responseRegexp: `^{"data":{"a":{"description":"one"},"b":{"description":"two"},"c":{"id":"1"},"none":null}}`,
+		findMany:       1,
+		msg:            "valid - all ids are read by one FindTasksByID",
+	},
+	{
+		description:    "Connection of tasks",
+		bodyData:       `{"query":"{ tasks(first: 2, order: DESC) { nodes { id } pageInfo { hasNextPage endCursor } } }"}`,
+		expectedCode:   http.StatusOK,
+		responseRegexp: `^{"data":{"tasks":{"nodes":\[{"id":"3"},{"id":"2"}\],"pageInfo":{"hasNextPage":true,"endCursor":"b2Zmc2V0OjI"}}}}`,
+		msg:            "valid - page of tasks with next page",
+	},
+	{
+		description:    "Next page of tasks",
+		bodyData:       `{"query":"{ tasks(first: 1, after: \"b2Zmc2V0OjE\") { edges { cursor node { id } } pageInfo { hasNextPage } } }"}`,
+		expectedCode:   http.StatusOK,
+		responseRegexp: `^{"data":{"tasks":{"edges":\[{"cursor":"b2Zmc2V0OjI","node":{"id":"2"}}\],"pageInfo":{"hasNextPage":true}}}}`,
+		msg:            "valid - page after cursor",
+	},
+	{
+		description:    "Update task",
+		bodyData:       `{"query":"mutation { updateTask(id: \"1\", input: {description: \"new\"}) { description updatedAt } }"}`,
+		expectedCode:   http.StatusOK,
+		responseRegexp: `^{"data":{"updateTask":{"description":"new","updatedAt":"\d{4}-`,
+		msg:            "valid - updated task is returned",
+	},
+	{
+		description:    "Delete task not found",
+		bodyData:       `{"query":"mutation { deleteTask(id: \"200\") }"}`,
+		expectedCode:   http.StatusOK,
+		responseRegexp: `"extensions":{"code":"NOT_FOUND"}`,
+		msg:            "invalid - code of error from taxonomy of store",
+	},
+	{
+		description:    "Invalid input",
+		bodyData:       `{"query":"mutation { createTask(input: {description: \"  \"}) { id } }"}`,
+		expectedCode:   http.StatusOK,
+		responseRegexp: `"extensions":{"code":"BAD_USER_INPUT","invalid_params":\[{"name":"description","reason":"required"}\]}`,
+		msg:            "invalid - field-level reasons in extensions",
+	},
+	{
+		description:    "Complexity",
+		bodyData:       `{"query":"query($n: Int) { tasks(first: $n) { edges { node { id description note createdAt } } nodes { id description note createdAt } } }","variables":{"n":100}}`,
+		expectedCode:   http.StatusOK,
+		responseRegexp: `^{"errors":\[{"message":"query complexity 1101 exceeds limit 1000","extensions":{"code":"QUERY_TOO_COMPLEX"`,
+		msg:            "invalid - complexity is checked before execution",
+	},
+	{
+		description:    "Depth",
+		bodyData:       `{"query":"{ __schema { types { fields { type { ofType { ofType { ofType { ofType { ofType { ofType { ofType { ofType { ofType { ofType { ofType { name } } } } } } } } } } } } } } } }"}`,
+		expectedCode:   http.StatusOK,
+		responseRegexp: `"message":"Field \\"name\\" has depth 16 that exceeds max depth 15".+"extensions":{"code":"QUERY_TOO_COMPLEX"`,
+		msg:            "invalid - depth is checked before execution",
+	},
+	{
+		description:    "Unknown field",
+		bodyData:       `{"query":"{ task(id: \"1\") { title } }"}`,
+		expectedCode:   http.StatusOK,
+		responseRegexp: `"extensions":{"code":"GRAPHQL_VALIDATION_FAILED"}`,
+		msg:            "invalid - query is validated by schema",
+	},
+	{
+		description:    "Broken body",
+		bodyData:       `{"query":`,
+		expectedCode:   http.StatusBadRequest,
+		responseRegexp: `^{"errors":\[{"message":`,
+		msg:            "invalid - status 400",
+	},
+}
+
+func TestRouteGraphQL(t *testing.T) {
+	asserts := assert.New(t)
+	requires := require.New(t)
+
+	base := NewTasksMock()
+	for i, description := range []string{"one", "two"} {
+		_, err := base.SaveOneTask(context.Background(), model.Task{Description: description, CreatedAt: time.Now().UTC()})
+		requires.NoError(err, i)
+	}
+	r := chi.NewRouter()
+	NewTransport(r, &config.Config{ErrorFormat: vr.ErrorFormatProblem}).Routes(base)
+
+	for i, test := range graphqlTestData {
+		log.Printf("\t %d test graphql: %s\n", i+1, test.description)
+		base.findMany = 0
+		req, err := http.NewRequest(http.MethodPost, "/graphql", strings.NewReader(test.bodyData))
+		requires.NoError(err, "http.NewRequest error")
+		req.Header.Set("Content-Type", c.MediaJSON)
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+
+		asserts.Equal(test.expectedCode, w.Code, test.msg)
+		asserts.Equal(c.MediaJSON, w.Header().Get("Content-Type"), test.msg)
+		asserts.Regexp(test.responseRegexp, w.Body.String(), test.msg)
+		asserts.Equal(test.findMany, base.findMany, test.msg)
+	}
+}
+
+var eventsTestData = []struct {
+	description  string
+	lastEventID  string
+	publish      []model.TaskEvent
+	expectedCode int
+	// expected - lines "id event" of stream
+	expected []string
+	msg      string
+}{
+	{
+		description:  "Live events",
+		publish:      []model.TaskEvent{{ID: 5, Tx: 9, Type: model.EventDeleted, TaskID: 1}},
+		expectedCode: http.StatusOK,
+		expected:     []string{"9-5 deleted"},
+		msg:          "valid - event of hub is sent",
+	},
+	{
+		description: "Resume by Last-Event-ID",
+		lastEventID: "6-2",
+		publish: []model.TaskEvent{
+			{ID: 3, Tx: 8, Type: model.EventUpdated, TaskID: 1},
+			{ID: 5, Tx: 9, Type: model.EventDeleted, TaskID: 1},
+		},
+		expectedCode: http.StatusOK,
+		expected:     []string{"7-4 created", "8-3 updated", "9-5 deleted"},
+		msg:          "valid - events of store in order of commit, lower id committed later is sent, repeated live event is skipped",
+	},
+	{
+		description:  "Events are gone",
+		lastEventID:  "5-1",
+		publish:      []model.TaskEvent{{ID: 5, Tx: 9, Type: model.EventDeleted, TaskID: 1}},
+		expectedCode: http.StatusOK,
+		expected:     []string{" reset", "9-5 deleted"},
+		msg:          "valid - reset and live events",
+	},
+	{
+		description:  "Id of old stream",
+		lastEventID:  "2",
+		publish:      []model.TaskEvent{{ID: 5, Tx: 9, Type: model.EventDeleted, TaskID: 1}},
+		expectedCode: http.StatusOK,
+		expected:     []string{" reset", "9-5 deleted"},
+		msg:          "valid - number is not cursor, reset and live events",
+	},
+	{
+		description:  "Wrong Last-Event-ID",
+		lastEventID:  "abc",
+		expectedCode: http.StatusBadRequest,
+		msg:          "invalid - Last-Event-ID is a cursor",
+	},
+}
+
+func TestRouteEvents(t *testing.T) {
+	asserts := assert.New(t)
+	requires := require.New(t)
+
+	base := NewTasksMock()
+	now := time.Now().UTC()
+	task := model.Task{ID: 1, Description: "one", CreatedAt: now}
+	// id 3 is taken before 4, but its transaction is committed later
+	base.events = []model.TaskEvent{
+		{ID: 4, Tx: 7, Type: model.EventCreated, TaskID: 1, Task: &task, At: now},
+		{ID: 3, Tx: 8, Type: model.EventUpdated, TaskID: 1, Task: &task, At: now},
+	}
+	base.purged = model.EventCursor{Tx: 6, ID: 1}
+	hub := events.NewHub()
+	r := chi.NewRouter()
+	NewTransport(r, &config.Config{ErrorFormat: vr.ErrorFormatProblem}).WithEvents(hub).Routes(base)
+	srv := httptest.NewServer(r)
+	defer srv.Close()
+
+	for i, test := range eventsTestData {
+		log.Printf("\t %d test events: %s\n", i+1, test.description)
+		ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, srv.URL+"/task/events", nil)
+		requires.NoError(err, "http.NewRequest error")
+		if test.lastEventID != "" {
+			req.Header.Set("Last-Event-ID", test.lastEventID)
+		}
+		resp, err := http.DefaultClient.Do(req)
+		requires.NoError(err, test.msg)
+		asserts.Equal(test.expectedCode, resp.StatusCode, test.msg)
+		if resp.StatusCode == http.StatusOK {
+			asserts.Equal(MediaEventStream, resp.Header.Get("Content-Type"), test.msg)
+			// headers - handler is subscribed
+			for _, ev := range test.publish {
+				hub.Publish(ev)
+			}
+			reader := bufio.NewReader(resp.Body)
+			var got []string
+			id := ""
+			for len(got) < len(test.expected) {
+				line, err := reader.ReadString('\n')
+				requires.NoError(err, test.msg)
+				switch {
+				case strings.HasPrefix(line, "id: "):
+					id = strings.TrimSpace(strings.TrimPrefix(line, "id: "))
+				case strings.HasPrefix(line, "event: "):
+					got = append(got, id+" "+strings.TrimSpace(strings.TrimPrefix(line, "event: ")))
+				case strings.HasPrefix(line, "data: ") && id == "7-4":
+					asserts.Contains(line, `"type":"created","task_id":1,"task":{"description":"one"`, test.msg)
+				}
+			}
+			asserts.Equal(test.expected, got, test.msg)
+		}
+		requires.NoError(resp.Body.Close())
+		cancel()
+	}
+}
+
+var authTestData = []struct {
+	description  string
+	header       string
+	query        string
+	expectedCode int
+	msg          string
+}{
+	{
+		description:  "No key",
+		expectedCode: http.StatusUnauthorized,
+		msg:          "invalid - key is required",
+	},
+	{
+		description:  "Unknown key",
+		header:       "Bearer nope",
+		expectedCode: http.StatusUnauthorized,
+		msg:          "invalid - key is unknown",
+	},
+	{
+		description:  "Wrong scheme",
+		header:       "Basic key-alice",
+		expectedCode: http.StatusUnauthorized,
+		msg:          "invalid - only Bearer",
+	},
+	{
+		description:  "Bearer key",
+		header:       "Bearer key-alice",
+		expectedCode: http.StatusNotFound,
+		msg:          "valid - request goes to handler",
+	},
+	{
+		description:  "Key in query",
+		query:        "?access_token=key-bob",
+		expectedCode: http.StatusNotFound,
+		msg:          "valid - request goes to handler",
+	},
+}
+
+func TestRouteAuthenticate(t *testing.T) {
+	asserts := assert.New(t)
+	requires := require.New(t)
+
+	r := chi.NewRouter()
+	cfg := &config.Config{ErrorFormat: vr.ErrorFormatProblem, APIKeys: "alice:key-alice, bob:key-bob"}
+	NewTransport(r, cfg).Routes(NewTasksMock())
+
+	for i, test := range authTestData {
+		log.Printf("\t %d test authenticate: %s\n", i+1, test.description)
+		req, err := http.NewRequest(http.MethodGet, "/task/200"+test.query, nil)
+		requires.NoError(err, "http.NewRequest error")
+		if test.header != "" {
+			req.Header.Set("Authorization", test.header)
+		}
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		asserts.Equal(test.expectedCode, w.Code, test.msg)
+		if w.Code == http.StatusUnauthorized {
+			asserts.Equal("Bearer", w.Header().Get("WWW-Authenticate"), test.msg)
+			asserts.Contains(w.Body.String(), vr.ProblemUnauthorized, test.msg)
+		}
+	}
+}
+
+func TestRouteWS(t *testing.T) {
+	asserts := assert.New(t)
+	requires := require.New(t)
+
+	hub := events.NewHub()
+	r := chi.NewRouter()
+	cfg := &config.Config{ErrorFormat: vr.ErrorFormatProblem, APIKeys: "alice:key-alice,bob:key-bob"}
+	NewTransport(r, cfg).WithEvents(hub).Routes(NewTasksMock())
+	srv := httptest.NewServer(r)
+	defer srv.Close()
+	url := "ws" + strings.TrimPrefix(srv.URL, "http") + "/ws"
+
+	dial := func(key string) (*websocket.Conn, error) {
+		header := http.Header{}
+		header.Set("Authorization", "Bearer "+key)
+		conn, resp, err := websocket.DefaultDialer.Dial(url, header)
+		if resp != nil {
+			requires.NoError(resp.Body.Close())
+		}
+		return conn, err
+	}
+	read := func(conn *websocket.Conn) wsReply {
+		requires.NoError(conn.SetReadDeadline(time.Now().Add(2 * time.Second)))
+		reply := wsReply{}
+		requires.NoError(conn.ReadJSON(&reply))
+		return reply
+	}
+
+	_, err := dial("nope")
+	requires.ErrorIs(err, websocket.ErrBadHandshake, "invalid - unknown key")
+
+	alice, err := dial("key-alice")
+	requires.NoError(err)
+	defer alice.Close()
+	bob, err := dial("key-bob")
+	requires.NoError(err)
+
+	log.Print("\t 1 test ws: subscribe by task and type of event\n")
+	requires.NoError(alice.WriteJSON(wsMessage{Type: wsSubscribe, ID: "s1", TaskIDs: []uint{5}, Events: []string{model.EventUpdated}}))
+	asserts.Equal(wsReply{Type: wsSubscribed, ID: "s1"}, read(alice))
+
+	log.Print("\t 2 test ws: events out of filter are skipped\n")
+	now := time.Now().UTC()
+	task := model.Task{ID: 5, Description: "five", CreatedAt: now}
+	other := model.Task{ID: 6, Description: "six", CreatedAt: now}
+	hub.Publish(model.TaskEvent{ID: 1, Type: model.EventUpdated, TaskID: 6, Task: &other, At: now})
+	hub.Publish(model.TaskEvent{ID: 2, Type: model.EventCreated, TaskID: 5, Task: &task, At: now})
+	hub.Publish(model.TaskEvent{ID: 3, Type: model.EventUpdated, TaskID: 5, Task: &task, At: now})
+	reply := read(alice)
+	asserts.Equal(wsEvent, reply.Type)
+	asserts.Equal("s1", reply.ID)
+	asserts.Equal(uint64(3), reply.EventID)
+	requires.NotNil(reply.Event)
+	asserts.Equal(uint(5), reply.Event.TaskID)
+
+	log.Print("\t 3 test ws: presence of other user\n")
+	requires.NoError(bob.WriteJSON(wsMessage{Type: wsPresence, TaskID: 5, State: "editing"}))
+	asserts.Equal(wsReply{Type: wsPresence, TaskID: 5, User: "bob", State: "editing"}, read(alice))
+
+	log.Print("\t 4 test ws: presence is sent on new subscription\n")
+	requires.NoError(alice.WriteJSON(wsMessage{Type: wsSubscribe, ID: "s2"}))
+	asserts.Equal(wsReply{Type: wsSubscribed, ID: "s2"}, read(alice))
+	asserts.Equal(wsReply{Type: wsPresence, TaskID: 5, User: "bob", State: "editing"}, read(alice))
+
+	log.Print("\t 5 test ws: wrong messages\n")
+	requires.NoError(alice.WriteJSON(wsMessage{Type: "shout"}))
+	asserts.Equal(wsReply{Type: wsError, Error: ErrTransportWSMessage.Error()}, read(alice))
+	requires.NoError(alice.WriteJSON(wsMessage{Type: wsSubscribe, ID: "s3", Events: []string{"moved"}}))
+	asserts.Equal(wsReply{Type: wsError, ID: "s3", Error: ErrTransportWSSubscription.Error()}, read(alice))
+	requires.NoError(alice.WriteMessage(websocket.TextMessage, []byte("{")))
+	asserts.Equal(wsReply{Type: wsError, Error: ErrTransportWSMessage.Error()}, read(alice))
+	requires.NoError(alice.WriteJSON(wsMessage{Type: wsPing}))
+	asserts.Equal(wsReply{Type: wsPong}, read(alice))
+
+	log.Print("\t 6 test ws: closed connection leaves\n")
+	requires.NoError(bob.Close())
+	asserts.Equal(wsReply{Type: wsPresence, TaskID: 5, User: "bob", State: presenceLeft}, read(alice))
+
+	log.Print("\t 7 test ws: stop of hub closes connection\n")
+	hub.Close()
+	requires.NoError(alice.SetReadDeadline(time.Now().Add(2 * time.Second)))
+	_, _, err = alice.ReadMessage()
+	asserts.True(websocket.IsCloseError(err, websocket.CloseGoingAway), "valid - server stops")
+}
+
+// WebhooksMock - 'model.WebhookStore' with one delivery of webhook 1
+type WebhooksMock struct {
+	nextID     uint
+	hooks      map[uint]model.Webhook
+	deliveries []model.WebhookDelivery
+}
+
+func NewWebhooksMock() *WebhooksMock {
+	now := time.Date(2025, 3, 1, 12, 0, 0, 0, time.UTC)
+	return &WebhooksMock{
+		nextID: 2,
+		hooks:  map[uint]model.Webhook{1: {ID: 1, URL: "https://example.com/hook", Secret: "0123456789abcdef", CreatedAt: now}},
+		deliveries: []model.WebhookDelivery{{
+			ID:         5,
+			WebhookID:  1,
+			Event:      model.TaskEvent{ID: 42, Type: model.EventDeleted, TaskID: 3, At: now},
+			Status:     model.DeliveryDead,
+			Attempts:   8,
+			LastStatus: http.StatusBadGateway,
+			LastError:  "status 502",
+			UpdatedAt:  &now,
+		}},
+	}
+}
+
+func (m *WebhooksMock) CreateWebhook(ctx context.Context, data any) (uint, error) {
+	hook := data.(model.Webhook)
+	hook.ID = m.nextID
+	m.hooks[hook.ID] = hook
+	m.nextID++
+	return hook.ID, ctx.Err()
+}
+
+func (m *WebhooksMock) FindWebhooks(ctx context.Context) ([]model.Webhook, error) {
+	hooks := make([]model.Webhook, 0, len(m.hooks))
+	for _, hook := range m.hooks {
+		hooks = append(hooks, hook)
+	}
+	sort.Slice(hooks, func(i, j int) bool { return hooks[i].ID < hooks[j].ID })
+	return hooks, ctx.Err()
+}
+
+func (m *WebhooksMock) DeleteWebhook(ctx context.Context, data any) error {
+	id := data.(uint)
+	if _, ex := m.hooks[id]; !ex {
+		return source.ErrSourceNotFound
+	}
+	delete(m.hooks, id)
+	return ctx.Err()
+}
+
+func (m *WebhooksMock) WebhookDeliveries(ctx context.Context, data any) ([]model.WebhookDelivery, error) {
+	filter := data.(model.DeliveryFilter)
+	if _, ex := m.hooks[filter.WebhookID]; !ex {
+		return nil, source.ErrSourceNotFound
+	}
+	var deliveries []model.WebhookDelivery
+	for _, dl := range m.deliveries {
+		if dl.WebhookID == filter.WebhookID && (filter.Status == "" || dl.Status == filter.Status) {
+			deliveries = append(deliveries, dl)
+		}
+	}
+	return deliveries, ctx.Err()
+}
+
+func (m *WebhooksMock) RedeliverWebhook(ctx context.Context, data any) error {
+	id := data.(uint64)
+	for i := range m.deliveries {
+		if m.deliveries[i].ID == id {
+			m.deliveries[i].Status = model.DeliveryPending
+			m.deliveries[i].Attempts = 0
+			return ctx.Err()
+		}
+	}
+	return source.ErrSourceNotFound
+}
+
+var webhookTestData = []struct {
+	description    string
+	url            string
+	method         string
+	bodyData       string
+	expectedCode   int
+	responseRegexp string
+	msg            string
+}{
+	{
+		description:    "Webhook create",
+		url:            "/webhooks/",
+		method:         http.MethodPost,
+		bodyData:       `{"webhook":{"url":"https://example.com/tasks","events":["created","deleted"],"secret":"abcdefghijklmnop"}}`,
+		expectedCode:   http.StatusCreated,
+		responseRegexp: `^{"webhook":2}`,
+		msg:            "valid - webhook is created -> get id with status 201",
+	},
+	{
+		description:    "Wrong webhook create",
+		url:            "/webhooks/",
+		method:         http.MethodPost,
+		bodyData:       `{"webhook":{"url":"ftp://example.com","events":["moved"],"secret":"short"}}`,
+		expectedCode:   http.StatusUnprocessableEntity,
+		responseRegexp: `{"errors":{"validator":"invalid task update"}}`,
+		msg:            "invalid - legacy body has no reasons of fields & status 422",
+	},
+	{
+		description:    "Webhook list",
+		url:            "/webhooks/",
+		method:         http.MethodGet,
+		expectedCode:   http.StatusOK,
+		responseRegexp: `^{"webhook_list":\[{"id":1,"url":"https://example.com/hook","events":\[\],"created_at":"2025-03-01T12:00:00Z"},{"id":2,[^}]*"events":\["created","deleted"\]`,
+		msg:            "valid - list without secrets",
+	},
+	{
+		description:    "Delivery log",
+		url:            "/webhooks/1/deliveries?status=dead",
+		method:         http.MethodGet,
+		expectedCode:   http.StatusOK,
+		responseRegexp: `^{"webhook_deliveries":\[{"id":5,"webhook_id":1,"event_id":42,"type":"deleted","task_id":3,"status":"dead","attempts":8,"last_status":502,"last_error":"status 502","updated_at":"2025-03-01T12:00:00Z"}\]}`,
+		msg:            "valid - dead deliveries of webhook",
+	},
+	{
+		description:    "Wrong delivery log (status)",
+		url:            "/webhooks/1/deliveries?status=lost",
+		method:         http.MethodGet,
+		expectedCode:   http.StatusBadRequest,
+		responseRegexp: `{"errors":{"param":"invalid params"}}`,
+		msg:            "invalid - unknown status",
+	},
+	{
+		description:    "Wrong delivery log (limit)",
+		url:            "/webhooks/1/deliveries?limit=0",
+		method:         http.MethodGet,
+		expectedCode:   http.StatusBadRequest,
+		responseRegexp: `{"errors":{"param":"invalid params"}}`,
+		msg:            "invalid - limit from 1",
+	},
+	{
+		description:    "Wrong delivery log (webhook)",
+		url:            "/webhooks/200/deliveries",
+		method:         http.MethodGet,
+		expectedCode:   http.StatusNotFound,
+		responseRegexp: `{"errors":{"webhook":"not found"}}`,
+		msg:            "invalid - no such webhook",
+	},
+	{
+		description:    "Redeliver",
+		url:            "/webhooks/deliveries/5/redeliver",
+		method:         http.MethodPost,
+		expectedCode:   http.StatusAccepted,
+		responseRegexp: `{"webhook_deliveries":"redelivery scheduled"}`,
+		msg:            "valid - dead delivery is pending again",
+	},
+	{
+		description:    "Delivery log after redeliver",
+		url:            "/webhooks/1/deliveries",
+		method:         http.MethodGet,
+		expectedCode:   http.StatusOK,
+		responseRegexp: `"status":"pending","attempts":0,"next_attempt_at":`,
+		msg:            "valid - redelivered is pending",
+	},
+	{
+		description:    "Wrong redeliver",
+		url:            "/webhooks/deliveries/6/redeliver",
+		method:         http.MethodPost,
+		expectedCode:   http.StatusNotFound,
+		responseRegexp: `{"errors":{"webhook_deliveries":"not found"}}`,
+		msg:            "invalid - no such delivery",
+	},
+	{
+		description:    "Webhook delete",
+		url:            "/webhooks/1",
+		method:         http.MethodDelete,
+		expectedCode:   http.StatusOK,
+		responseRegexp: `{"webhook":"deleted"}`,
+		msg:            "valid - webhook is deleted",
+	},
+	{
+		description:    "Wrong webhook delete",
+		url:            "/webhooks/1",
+		method:         http.MethodDelete,
+		expectedCode:   http.StatusNotFound,
+		responseRegexp: `{"errors":{"webhook":"not found"}}`,
+		msg:            "invalid - webhook is already deleted",
+	},
+	{
+		description:    "Wrong webhook delete (id)",
+		url:            "/webhooks/alpha",
+		method:         http.MethodDelete,
+		expectedCode:   http.StatusBadRequest,
+		responseRegexp: `{"errors":{"param":"invalid params"}}`,
+		msg:            "invalid - id is a number",
+	},
+}
+
+func TestRouteWebhooks(t *testing.T) {
+	asserts := assert.New(t)
+	requires := require.New(t)
+
+	r := chi.NewRouter()
+	NewTransport(r, &config.Config{ErrorFormat: vr.ErrorFormatLegacy}).WithWebhooks(NewWebhooksMock()).Routes(NewTasksMock())
+
+	for i, test := range webhookTestData {
+		log.Printf("\t %d test webhook: %s\n", i+1, test.description)
+		req, err := http.NewRequest(test.method, test.url, strings.NewReader(test.bodyData))
+		requires.NoError(err, "http.NewRequest error")
+		if test.bodyData != "" {
+			req.Header.Set("Content-Type", "application/json")
+		}
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+
+		asserts.Equal(test.expectedCode, w.Code, test.msg)
+		asserts.Regexp(test.responseRegexp, w.Body.String(), test.msg)
+	}
+
+	log.Printf("\t %d test webhook: Wrong webhook create - reasons of all fields in problem\n", len(webhookTestData)+1)
+	r = chi.NewRouter()
+	NewTransport(r, &config.Config{ErrorFormat: vr.ErrorFormatProblem}).WithWebhooks(NewWebhooksMock()).Routes(NewTasksMock())
+	req, err := http.NewRequest(http.MethodPost, "/webhooks/",
+		strings.NewReader(`{"webhook":{"url":"ftp://example.com","events":["moved"],"secret":"short"}}`))
+	requires.NoError(err, "http.NewRequest error")
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	asserts.Equal(http.StatusUnprocessableEntity, w.Code)
+	problem := c.Problem{}
+	requires.NoError(json.Unmarshal(w.Body.Bytes(), &problem))
+	asserts.Equal([]c.InvalidParam{
+		{Name: "url", Reason: "must be absolute http or https URL"},
+		{Name: "secret", Reason: "must be at least 16 characters"},
+		{Name: "events[0]", Reason: "must be one of: created, updated, deleted"},
+	}, problem.InvalidParams, "invalid - all fields are checked & status 422")
+
+	log.Printf("\t %d test webhook: Wrong user - not admin\n", len(webhookTestData)+2)
+	r = chi.NewRouter()
+	cfg := &config.Config{ErrorFormat: vr.ErrorFormatLegacy, APIKeys: "alice:key-alice,bob:key-bob", Admins: "alice"}
+	NewTransport(r, cfg).WithWebhooks(NewWebhooksMock()).Routes(NewTasksMock())
+	for key, code := range map[string]int{"key-alice": http.StatusOK, "key-bob": http.StatusForbidden} {
+		req, err := http.NewRequest(http.MethodGet, "/webhooks/", nil)
+		requires.NoError(err, "http.NewRequest error")
+		req.Header.Set("Authorization", "Bearer "+key)
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		asserts.Equal(code, w.Code, "invalid - webhooks only for admins")
+	}
+}
+
+// AttachmentsMock - 'model.AttachmentStore' of 'Task' 1, keys of deleted attachments are in 'cleaned'
+type AttachmentsMock struct {
+	nextID      uint64
+	attachments []model.Attachment
+	cleaned     []string
+}
+
+func NewAttachmentsMock() *AttachmentsMock {
+	return &AttachmentsMock{nextID: 1}
+}
+
+func (m *AttachmentsMock) SaveAttachment(_ context.Context, data any) (model.Attachment, error) {
+	attachment := data.(model.Attachment)
+	if attachment.TaskID != 1 {
+		return model.Attachment{}, source.ErrSourceNotFound
+	}
+	attachment.ID = m.nextID
+	m.nextID++
+	m.attachments = append(m.attachments, attachment)
+	return attachment, nil
+}
+
+func (m *AttachmentsMock) FindAttachment(_ context.Context, data any) (model.Attachment, error) {
+	ref := data.(model.AttachmentRef)
+	for _, attachment := range m.attachments {
+		if attachment.TaskID == ref.TaskID && attachment.ID == ref.ID {
+			return attachment, nil
+		}
+	}
+	return model.Attachment{}, source.ErrSourceNotFound
+}
+
+func (m *AttachmentsMock) TaskAttachments(_ context.Context, data any) ([]model.Attachment, error) {
+	taskID := data.(uint)
+	if taskID != 1 {
+		return nil, source.ErrSourceNotFound
+	}
+	var attachments []model.Attachment
+	for _, attachment := range m.attachments {
+		if attachment.TaskID == taskID {
+			attachments = append(attachments, attachment)
+		}
+	}
+	return attachments, nil
+}
+
+func (m *AttachmentsMock) DeleteAttachment(_ context.Context, data any) error {
+	ref := data.(model.AttachmentRef)
+	for i, attachment := range m.attachments {
+		if attachment.TaskID == ref.TaskID && attachment.ID == ref.ID {
+			m.attachments = append(m.attachments[:i], m.attachments[i+1:]...)
+			m.cleaned = append(m.cleaned, attachment.Key)
+			return nil
+		}
+	}
+	return source.ErrSourceNotFound
+}
+
+// pngHead - signature of PNG, enough for 'http.DetectContentType'
+const pngHead = "\x89PNG\r\n\x1a\n\x00\x00\x00\rIHDR"
+
+// multipartBody - form with files of 'field', name -> content
+func multipartBody(field string, files ...[2]string) (string, string) {
+	body := &bytes.Buffer{}
+	mw := multipart.NewWriter(body)
+	for _, file := range files {
+		fw, _ := mw.CreateFormFile(field, file[0])
+		_, _ = io.WriteString(fw, file[1])
+	}
+	_ = mw.Close()
+	return body.String(), mw.FormDataContentType()
+}
+
+var attachmentTestData = []struct {
+	description    string
+	method         string
+	url            string
+	body           func() (string, string)
+	header         map[string]string
+	expectedCode   int
+	responseRegexp string
+	msg            string
+}{
+	{
+		description: "Upload",
+		method:      http.MethodPost,
+		url:         "/task/1/attachments",
+		body: func() (string, string) {
+			return multipartBody("file", [2]string{"shot.png", pngHead}, [2]string{`..\notes.txt`, "0123456789"})
+		},
+		expectedCode:   http.StatusCreated,
+		responseRegexp: `{"attachments":\[{"id":1,"task_id":1,"name":"shot.png","content_type":"image/png","size":16,"sha256":"[0-9a-f]{64}","user":"alice",[^}]+},{"id":2,"task_id":1,"name":"notes.txt","content_type":"text/plain; charset=utf-8","size":10,"sha256":"84d89877f0d4041efb6bf91a16f0248f2fd573e6af05c19f96bedb9f882f7882",`,
+		msg:            "valid - files with checksum and sniffed type and status 201",
+	},
+	{
+		description: "Wrong upload - unknown task",
+		method:      http.MethodPost,
+		url:         "/task/9/attachments",
+		body: func() (string, string) {
+			return multipartBody("file", [2]string{"notes.txt", "text"})
+		},
+		expectedCode:   http.StatusNotFound,
+		responseRegexp: `"type":"/problems/not-found"`,
+		msg:            "invalid - no task and status 404",
+	},
+	{
+		description: "Wrong upload - not a form",
+		method:      http.MethodPost,
+		url:         "/task/1/attachments",
+		body: func() (string, string) {
+			return `{"file":"text"}`, "application/json"
+		},
+		expectedCode:   http.StatusBadRequest,
+		responseRegexp: `invalid attachment form`,
+		msg:            "invalid - body is not multipart and status 400",
+	},
+	{
+		description: "Wrong upload - no files",
+		method:      http.MethodPost,
+		url:         "/task/1/attachments",
+		body: func() (string, string) {
+			return multipartBody("document", [2]string{"notes.txt", "text"})
+		},
+		expectedCode:   http.StatusBadRequest,
+		responseRegexp: `invalid attachment form - no field \\"file\\"`,
+		msg:            "invalid - no field 'file' and status 400",
+	},
+	{
+		description: "Wrong upload - empty file",
+		method:      http.MethodPost,
+		url:         "/task/1/attachments",
+		body: func() (string, string) {
+			return multipartBody("file", [2]string{"empty.txt", ""})
+		},
+		expectedCode:   http.StatusBadRequest,
+		responseRegexp: `invalid attachment form - \\"empty.txt\\" is empty`,
+		msg:            "invalid - empty file and status 400",
+	},
+	{
+		description: "Wrong upload - large file",
+		method:      http.MethodPost,
+		url:         "/task/1/attachments",
+		body: func() (string, string) {
+			return multipartBody("file", [2]string{"large.txt", strings.Repeat("a", 65)})
+		},
+		expectedCode:   http.StatusRequestEntityTooLarge,
+		responseRegexp: `invalid attachment size - \\"large.txt\\" is larger than 64 bytes`,
+		msg:            "invalid - size of file and status 413",
+	},
+	{
+		description: "Wrong upload - type",
+		method:      http.MethodPost,
+		url:         "/task/1/attachments",
+		body: func() (string, string) {
+			return multipartBody("file", [2]string{"page.txt", "<html><body>hi</body></html>"})
+		},
+		expectedCode:   http.StatusUnsupportedMediaType,
+		responseRegexp: `unsupported attachment type - \\"page.txt\\": text/html`,
+		msg:            "invalid - type of content and status 415",
+	},
+	{
+		description:    "List",
+		method:         http.MethodGet,
+		url:            "/task/1/attachments",
+		expectedCode:   http.StatusOK,
+		responseRegexp: `{"attachments":\[{"id":1,[^}]+},{"id":2,[^}]+}\]}`,
+		msg:            "valid - attachments of failed uploads are not saved and status 200",
+	},
+	{
+		description:    "Wrong list - unknown task",
+		method:         http.MethodGet,
+		url:            "/task/9/attachments",
+		expectedCode:   http.StatusNotFound,
+		responseRegexp: `"type":"/problems/not-found"`,
+		msg:            "invalid - no task and status 404",
+	},
+	{
+		description:    "Download",
+		method:         http.MethodGet,
+		url:            "/task/1/attachments/2",
+		expectedCode:   http.StatusOK,
+		responseRegexp: `^0123456789$`,
+		msg:            "valid - content of file and status 200",
+	},
+	{
+		description:    "Download range",
+		method:         http.MethodGet,
+		url:            "/task/1/attachments/2",
+		header:         map[string]string{"Range": "bytes=2-5"},
+		expectedCode:   http.StatusPartialContent,
+		responseRegexp: `^2345$`,
+		msg:            "valid - part of content and status 206",
+	},
+	{
+		description:    "Download not modified",
+		method:         http.MethodGet,
+		url:            "/task/1/attachments/2",
+		header:         map[string]string{"If-None-Match": `"84d89877f0d4041efb6bf91a16f0248f2fd573e6af05c19f96bedb9f882f7882"`},
+		expectedCode:   http.StatusNotModified,
+		responseRegexp: `^$`,
+		msg:            "valid - ETag is checksum and status 304",
+	},
+	{
+		description:    "Delete",
+		method:         http.MethodDelete,
+		url:            "/task/1/attachments/2",
+		expectedCode:   http.StatusOK,
+		responseRegexp: `{"attachment":"deleted"}`,
+		msg:            "valid - attachment is deleted and status 200",
+	},
+	{
+		description:    "Wrong download - deleted",
+		method:         http.MethodGet,
+		url:            "/task/1/attachments/2",
+		expectedCode:   http.StatusNotFound,
+		responseRegexp: `"type":"/problems/not-found"`,
+		msg:            "invalid - no attachment and status 404",
+	},
+	{
+		description:    "Wrong download - id",
+		method:         http.MethodGet,
+		url:            "/task/1/attachments/x",
+		expectedCode:   http.StatusBadRequest,
+		responseRegexp: `"type":"/problems/invalid-params"`,
+		msg:            "invalid - id of attachment and status 400",
+	},
+}
+
+func TestRouteAttachments(t *testing.T) {
+	asserts := assert.New(t)
+	requires := require.New(t)
+
+	blobs, err := blob.NewLocalStore(t.TempDir())
+	requires.NoError(err)
+	attachments := NewAttachmentsMock()
+	r := chi.NewRouter()
+	cfg := &config.Config{ErrorFormat: vr.ErrorFormatProblem, APIKeys: "alice:key-alice"}
+	NewTransport(r, cfg).WithAttachments(attachments, blobs, 64).Routes(NewTasksMock())
+
+	for i, test := range attachmentTestData {
+		log.Printf("\t %d test attachments: %s\n", i+1, test.description)
+		body, contentType := "", ""
+		if test.body != nil {
+			body, contentType = test.body()
+		}
+		req, err := http.NewRequest(test.method, test.url, strings.NewReader(body))
+		requires.NoError(err, "http.NewRequest error")
+		if contentType != "" {
+			req.Header.Set("Content-Type", contentType)
+		}
+		for name, value := range test.header {
+			req.Header.Set(name, value)
+		}
+		req.Header.Set("Authorization", "Bearer key-alice")
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+
+		asserts.Equal(test.expectedCode, w.Code, test.msg)
+		asserts.Regexp(test.responseRegexp, w.Body.String(), test.msg)
+		if test.description == "Download" {
+			asserts.Equal("text/plain; charset=utf-8", w.Header().Get("Content-Type"), test.msg)
+			asserts.Equal(`attachment; filename=notes.txt`, w.Header().Get("Content-Disposition"), test.msg)
+			asserts.Equal("nosniff", w.Header().Get("X-Content-Type-Options"), test.msg)
+			asserts.Equal("bytes", w.Header().Get("Accept-Ranges"), test.msg)
+		}
+	}
+	requires.Len(attachments.cleaned, 1)
+	content, err := blobs.Open(context.Background(), attachments.cleaned[0], 10)
+	requires.NoError(err, "blob is removed later by cleanup job")
+	requires.NoError(content.Close())
+}
+
+// IdempotencyMock - 'model.IdempotencyStore' in memory, keys do not expire
+type IdempotencyMock struct {
+	records map[string]model.IdempotencyRecord
+}
+
+func NewIdempotencyMock() *IdempotencyMock {
+	return &IdempotencyMock{records: map[string]model.IdempotencyRecord{}}
+}
+
+func (m *IdempotencyMock) ClaimIdempotencyKey(ctx context.Context, data any) (model.IdempotencyRecord, bool, error) {
+	claim := data.(model.IdempotencyClaim)
+	if record, ex := m.records[claim.User+"/"+claim.Key]; ex {
+		return record, false, ctx.Err()
+	}
+	record := model.IdempotencyRecord{User: claim.User, Key: claim.Key, Fingerprint: claim.Fingerprint}
+	m.records[claim.User+"/"+claim.Key] = record
+	return record, true, ctx.Err()
+}
+
+func (m *IdempotencyMock) SaveIdempotentResponse(ctx context.Context, data any) error {
+	record := data.(model.IdempotencyRecord)
+	if stored, ex := m.records[record.User+"/"+record.Key]; !ex || stored.Status != 0 {
+		return source.ErrSourceConflict
+	}
+	m.records[record.User+"/"+record.Key] = record
+	return ctx.Err()
+}
+
+func (m *IdempotencyMock) ReleaseIdempotencyKey(ctx context.Context, data any) error {
+	record := data.(model.IdempotencyRecord)
+	delete(m.records, record.User+"/"+record.Key)
+	return ctx.Err()
+}
+
+func (m *IdempotencyMock) PurgeIdempotencyKeys(ctx context.Context, data any) (int64, error) {
+	return 0, ctx.Err()
+}
+
+var idempotencyTestData = []struct {
+	description    string
+	method         string
+	url            string
+	apiKey         string
+	idempotencyKey string
+	bodyData       string
+	expectedCode   int
+	responseRegexp string
+	replayed       bool
+	expectedTasks  int
+	msg            string
+}{
+	{
+		description:    "First request",
+		method:         http.MethodPost,
+		url:            "/task/",
+		apiKey:         "alice-key",
+		idempotencyKey: "c1a4f3e0-create-1",
+		bodyData:       `{"task_update":{"description":"Buy milk"}}`,
+		expectedCode:   http.StatusCreated,
+		responseRegexp: `{"task":1}`,
+		expectedTasks:  1,
+		msg:            "valid - task is created and response is kept, status 201",
+	},
+	{
+		description:    "Retry",
+		method:         http.MethodPost,
+		url:            "/task/",
+		apiKey:         "alice-key",
+		idempotencyKey: "c1a4f3e0-create-1",
+		bodyData:       `{"task_update":{"description":"Buy milk"}}`,
+		expectedCode:   http.StatusCreated,
+		responseRegexp: `^{"task":1}\n$`,
+		replayed:       true,
+		expectedTasks:  1,
+		msg:            "valid - kept response without new task, status 201",
+	},
+	{
+		description:    "Same key of other user",
+		method:         http.MethodPost,
+		url:            "/task/",
+		apiKey:         "bob-key",
+		idempotencyKey: "c1a4f3e0-create-1",
+		bodyData:       `{"task_update":{"description":"Buy milk"}}`,
+		expectedCode:   http.StatusCreated,
+		responseRegexp: `{"task":2}`,
+		expectedTasks:  2,
+		msg:            "valid - keys of users are apart, status 201",
+	},
+	{
+		description:    "Wrong retry - other body",
+		method:         http.MethodPost,
+		url:            "/task/",
+		apiKey:         "alice-key",
+		idempotencyKey: "c1a4f3e0-create-1",
+		bodyData:       `{"task_update":{"description":"Buy bread"}}`,
+		expectedCode:   http.StatusUnprocessableEntity,
+		responseRegexp: `"detail":"idempotency key is used by other request"`,
+		expectedTasks:  2,
+		msg:            "invalid - key with other body, status 422",
+	},
+	{
+		description:    "Wrong retry - other route",
+		method:         http.MethodPost,
+		url:            "/project/",
+		apiKey:         "alice-key",
+		idempotencyKey: "c1a4f3e0-create-1",
+		bodyData:       `{"task_update":{"description":"Buy milk"}}`,
+		expectedCode:   http.StatusUnprocessableEntity,
+		responseRegexp: `"type":"/problems/validation"`,
+		expectedTasks:  2,
+		msg:            "invalid - key with other URI, status 422",
+	},
+	{
+		description:    "Wrong retry - in flight",
+		method:         http.MethodPost,
+		url:            "/task/",
+		apiKey:         "alice-key",
+		idempotencyKey: "in-flight",
+		bodyData:       `{"task_update":{"description":"Call Bob"}}`,
+		expectedCode:   http.StatusConflict,
+		responseRegexp: `"type":"/problems/conflict"`,
+		expectedTasks:  2,
+		msg:            "invalid - first request is not finished, status 409",
+	},
+	{
+		description:    "Error response",
+		method:         http.MethodPost,
+		url:            "/task/",
+		apiKey:         "alice-key",
+		idempotencyKey: "invalid-task",
+		bodyData:       `{"task_update":{"description":""}}`,
+		expectedCode:   http.StatusUnprocessableEntity,
+		responseRegexp: `"invalid_params":\[{"name":"description","reason":"required"}\]`,
+		expectedTasks:  2,
+		msg:            "valid - response 4xx is kept too, status 422",
+	},
+	{
+		description:    "Retry of error response",
+		method:         http.MethodPost,
+		url:            "/task/",
+		apiKey:         "alice-key",
+		idempotencyKey: "invalid-task",
+		bodyData:       `{"task_update":{"description":""}}`,
+		expectedCode:   http.StatusUnprocessableEntity,
+		responseRegexp: `"invalid_params":\[{"name":"description","reason":"required"}\]`,
+		replayed:       true,
+		expectedTasks:  2,
+		msg:            "valid - kept 4xx response, status 422",
+	},
+	{
+		description:    "Wrong key",
+		method:         http.MethodPost,
+		url:            "/task/",
+		apiKey:         "alice-key",
+		idempotencyKey: "key with spaces",
+		bodyData:       `{"task_update":{"description":"Buy milk"}}`,
+		expectedCode:   http.StatusBadRequest,
+		responseRegexp: `"type":"/problems/invalid-params"`,
+		expectedTasks:  2,
+		msg:            "invalid - key is not visible ASCII, status 400",
+	},
+	{
+		description:    "Wrong body - too large",
+		method:         http.MethodPost,
+		url:            "/task/",
+		apiKey:         "alice-key",
+		idempotencyKey: "large",
+		bodyData:       `{"task_update":{"description":"` + strings.Repeat("a", maxIdempotentBody) + `"}}`,
+		expectedCode:   http.StatusRequestEntityTooLarge,
+		responseRegexp: `"detail":"body of request is too large"`,
+		expectedTasks:  2,
+		msg:            "invalid - body is not read for fingerprint, status 413",
+	},
+	{
+		description:    "Not POST",
+		method:         http.MethodGet,
+		url:            "/task/1",
+		apiKey:         "alice-key",
+		idempotencyKey: "c1a4f3e0-create-1",
+		expectedCode:   http.StatusOK,
+		responseRegexp: `"description":"Buy milk"`,
+		expectedTasks:  2,
+		msg:            "valid - key of other methods is ignored, status 200",
+	},
+}
+
+func TestRouteIdempotency(t *testing.T) {
+	asserts := assert.New(t)
+	requires := require.New(t)
+
+	db, keys := NewTasksMock(), NewIdempotencyMock()
+	keys.records["alice/in-flight"] = model.IdempotencyRecord{User: "alice", Key: "in-flight",
+		Fingerprint: idempotency.Fingerprint(http.MethodPost, "/task/", []byte(`{"task_update":{"description":"Call Bob"}}`))}
+	r := chi.NewRouter()
+	cfg := &config.Config{ErrorFormat: vr.ErrorFormatProblem, APIKeys: "alice:alice-key,bob:bob-key"}
+	NewTransport(r, cfg).WithIdempotency(keys, time.Hour).Routes(db)
+
+	for i, test := range idempotencyTestData {
+		log.Printf("\t %d test idempotency: %s\n", i+1, test.description)
+		req, err := http.NewRequest(test.method, test.url, strings.NewReader(test.bodyData))
+		requires.NoError(err, "http.NewRequest error")
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("Authorization", "Bearer "+test.apiKey)
+		req.Header.Set("Idempotency-Key", test.idempotencyKey)
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+
+		asserts.Equal(test.expectedCode, w.Code, test.msg)
+		asserts.Regexp(test.responseRegexp, w.Body.String(), test.msg)
+		asserts.Equal(test.replayed, w.Header().Get("Idempotent-Replayed") == "true", test.msg)
+		asserts.Len(db.tasks, test.expectedTasks, test.msg)
+	}
+
+	log.Print("\t 12 test idempotency: response 5xx is not kept\n")
+	handler := Idempotency(keys, time.Hour)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	req := httptest.NewRequest(http.MethodPost, "/task/", strings.NewReader(`{}`))
+	req.Header.Set("Idempotency-Key", "unavailable")
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, req)
+	asserts.Equal(http.StatusServiceUnavailable, w.Code)
+	_, kept := keys.records[Anonymous+"/unavailable"]
+	asserts.False(kept, "valid - key is released for retry")
+}
+
+var rateLimitTestData = []struct {
+	description    string
+	method         string
+	url            string
+	apiKey         string
+	bodyData       string
+	expectedCode   int
+	responseRegexp string
+	limit          string
+	remaining      string
+	retryAfter     string
+	msg            string
+}{
+	{
+		description:    "Route bucket",
+		method:         http.MethodPost,
+		url:            "/task/",
+		apiKey:         "alice-key",
+		bodyData:       `{"task_update":{"description":"one"}}`,
+		expectedCode:   http.StatusCreated,
+		responseRegexp: `{"task":1}`,
+		limit:          "2",
+		remaining:      "1",
+		msg:            "valid - token of 'POST /task', status 201",
+	},
+	{
+		description:    "Route bucket",
+		method:         http.MethodPost,
+		url:            "/task/",
+		apiKey:         "alice-key",
+		bodyData:       `{"task_update":{"description":"two"}}`,
+		expectedCode:   http.StatusCreated,
+		responseRegexp: `{"task":2}`,
+		limit:          "2",
+		remaining:      "0",
+		msg:            "valid - last token, status 201",
+	},
+	{
+		description:    "Wrong request - empty bucket",
+		method:         http.MethodPost,
+		url:            "/task/",
+		apiKey:         "alice-key",
+		bodyData:       `{"task_update":{"description":"three"}}`,
+		expectedCode:   http.StatusTooManyRequests,
+		responseRegexp: `"type":"/problems/too-many-requests"`,
+		limit:          "2",
+		remaining:      "0",
+		retryAfter:     "30",
+		msg:            "invalid - no token, status 429",
+	},
+	{
+		description:    "Bucket of other client",
+		method:         http.MethodPost,
+		url:            "/task/",
+		apiKey:         "bob-key",
+		bodyData:       `{"task_update":{"description":"three"}}`,
+		expectedCode:   http.StatusCreated,
+		responseRegexp: `{"task":3}`,
+		limit:          "2",
+		remaining:      "1",
+		msg:            "valid - bucket of bob, status 201",
+	},
+	{
+		description:    "Default bucket",
+		method:         http.MethodGet,
+		url:            "/task/1",
+		apiKey:         "alice-key",
+		expectedCode:   http.StatusOK,
+		responseRegexp: `"description":"one"`,
+		limit:          "100",
+		remaining:      "99",
+		msg:            "valid - other route has default bucket, status 200",
+	},
+	{
+		description:    "Bucket of any method",
+		method:         http.MethodGet,
+		url:            "/task/export",
+		apiKey:         "alice-key",
+		expectedCode:   http.StatusOK,
+		responseRegexp: `"description":"one"`,
+		limit:          "1",
+		remaining:      "0",
+		msg:            "valid - the longest prefix, status 200",
+	},
+}
+
+func TestRouteRateLimit(t *testing.T) {
+	asserts := assert.New(t)
+	requires := require.New(t)
+
+	r := chi.NewRouter()
+	cfg := &config.Config{
+		ErrorFormat: vr.ErrorFormatProblem,
+		APIKeys:     "alice:alice-key,bob:bob-key",
+		RateLimits:  "default=100/s,POST /task=2/m:2,/task/export=1/h",
+		RateLimitBy: config.RateLimitByUser,
+	}
+	NewTransport(r, cfg).WithRateLimiter(ratelimit.NewMemory()).Routes(NewTasksMock())
+
+	for i, test := range rateLimitTestData {
+		log.Printf("\t %d test rate limit: %s\n", i+1, test.description)
+		req, err := http.NewRequest(test.method, test.url, strings.NewReader(test.bodyData))
+		requires.NoError(err, "http.NewRequest error")
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("Authorization", "Bearer "+test.apiKey)
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+
+		asserts.Equal(test.expectedCode, w.Code, test.msg)
+		asserts.Regexp(test.responseRegexp, w.Body.String(), test.msg)
+		asserts.Equal(test.limit, w.Header().Get("RateLimit-Limit"), test.msg)
+		asserts.Equal(test.remaining, w.Header().Get("RateLimit-Remaining"), test.msg)
+		asserts.Equal(test.retryAfter, w.Header().Get("Retry-After"), test.msg)
+	}
+}
+
+func TestRouteTaskQuota(t *testing.T) {
+	asserts := assert.New(t)
+	requires := require.New(t)
+
+	r := chi.NewRouter()
+	cfg := &config.Config{ErrorFormat: vr.ErrorFormatProblem, APIKeys: "alice:alice-key", TaskDailyQuota: "2"}
+	db := NewTasksMock()
+	NewTransport(r, cfg).WithRateLimiter(ratelimit.NewMemory()).WithIdempotency(NewIdempotencyMock(), time.Hour).Routes(db)
+
+	for i, test := range []struct {
+		description    string
+		idempotencyKey string
+		bodyData       string
+		expectedCode   int
+		msg            string
+	}{
+		{"Task of quota", "first", `{"task_update":{"description":"one"}}`, http.StatusCreated,
+			"valid - first task of day, status 201"},
+		{"Replay", "first", `{"task_update":{"description":"one"}}`, http.StatusCreated,
+			"valid - replay of Idempotency-Key does not take quota, status 201"},
+		{"Invalid task", "", `{"task_update":{"description":""}}`, http.StatusUnprocessableEntity,
+			"valid - invalid task does not take quota, status 422"},
+		{"Task of quota", "", `{"task_update":{"description":"two"}}`, http.StatusCreated,
+			"valid - second task of day, status 201"},
+		{"Wrong task - quota is used", "", `{"task_update":{"description":"three"}}`, http.StatusTooManyRequests,
+			"invalid - quota of day is used, status 429"},
+	} {
+		log.Printf("\t %d test task quota: %s\n", i+1, test.description)
+		req, err := http.NewRequest(http.MethodPost, "/task/", strings.NewReader(test.bodyData))
+		requires.NoError(err, "http.NewRequest error")
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("Authorization", "Bearer alice-key")
+		if test.idempotencyKey != "" {
+			req.Header.Set("Idempotency-Key", test.idempotencyKey)
+		}
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+
+		asserts.Equal(test.expectedCode, w.Code, test.msg)
+		if w.Code == http.StatusTooManyRequests {
+			asserts.Regexp(`"detail":"daily quota of tasks is exceeded"`, w.Body.String(), test.msg)
+			asserts.NotEmpty(w.Header().Get("Retry-After"), test.msg)
+		}
+	}
+	asserts.Len(db.tasks, 2)
+
+	log.Print("\t 6 test task quota: legacy format of error has 'Retry-After'\n")
+	r = chi.NewRouter()
+	cfg = &config.Config{ErrorFormat: vr.ErrorFormatLegacy, APIKeys: "alice:alice-key", TaskDailyQuota: "1"}
+	NewTransport(r, cfg).WithRateLimiter(ratelimit.NewMemory()).Routes(NewTasksMock())
+	for _, expectedCode := range []int{http.StatusCreated, http.StatusTooManyRequests} {
+		req, err := http.NewRequest(http.MethodPost, "/task/", strings.NewReader(`{"task_update":{"description":"one"}}`))
+		requires.NoError(err, "http.NewRequest error")
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("Authorization", "Bearer alice-key")
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		requires.Equal(expectedCode, w.Code)
+		if w.Code == http.StatusTooManyRequests {
+			asserts.Regexp(`^\{"errors":\{"[a-z_]+":"daily quota of tasks is exceeded"\}\}`, w.Body.String())
+			asserts.NotEmpty(w.Header().Get("Retry-After"), "valid - quota of legacy format has 'Retry-After'")
+		}
+	}
+}
+
+func TestRouteTaskQuotaBulk(t *testing.T) {
+	asserts := assert.New(t)
+	requires := require.New(t)
+
+	r := chi.NewRouter()
+	cfg := &config.Config{ErrorFormat: vr.ErrorFormatProblem, APIKeys: "alice:alice-key", TaskDailyQuota: "3"}
+	db := NewTasksMock()
+	NewTransport(r, cfg).WithRateLimiter(ratelimit.NewMemory()).Routes(db)
+
+	for i, test := range []struct {
+		description   string
+		url           string
+		bodyData      string
+		expectedCode  int
+		expectedTasks int
+		msg           string
+	}{
+		{"Failed batch", "/task/batch", `{"task_batch":{"operations":[
+{"op":"create","task_update":{"description":"one"}},{"op":"delete","id":200}]}}`,
+			http.StatusNotFound, 0, "valid - quota of not created task is returned, status 404"},
+		{"Batch", "/task/batch", `{"task_batch":{"operations":[
+{"op":"create","task_update":{"description":"one"}},{"op":"create","task_update":{"description":"two"}}]}}`,
+			http.StatusOK, 2, "valid - two tasks of quota, status 200"},
+		{"Wrong import - above quota", "/task/import?format=ndjson",
+			"{\"description\":\"three\"}\n{\"description\":\"four\"}\n",
+			http.StatusTooManyRequests, 2, "invalid - import above quota is not saved, status 429"},
+		{"Import dry run", "/task/import?format=ndjson&dry_run=true",
+			"{\"description\":\"three\"}\n{\"description\":\"four\"}\n",
+			http.StatusOK, 2, "valid - dry run does not take quota, status 200"},
+		{"Import", "/task/import?format=ndjson", "{\"description\":\"three\"}\n",
+			http.StatusCreated, 3, "valid - last task of quota, status 201"},
+		{"Wrong batch - quota is used", "/task/batch", `{"task_batch":{"operations":[
+{"op":"create","task_update":{"description":"five"}}]}}`,
+			http.StatusTooManyRequests, 3, "invalid - quota of day is used, status 429"},
+	} {
+		log.Printf("\t %d test task quota of bulk: %s\n", i+1, test.description)
+		req, err := http.NewRequest(http.MethodPost, test.url, strings.NewReader(test.bodyData))
+		requires.NoError(err, "http.NewRequest error")
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("Authorization", "Bearer alice-key")
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+
+		asserts.Equal(test.expectedCode, w.Code, test.msg)
+		asserts.Len(db.tasks, test.expectedTasks, test.msg)
+		if w.Code == http.StatusTooManyRequests {
+			asserts.Regexp(`"type":"/problems/too-many-requests"`, w.Body.String(), test.msg)
+			asserts.NotEmpty(w.Header().Get("Retry-After"), test.msg)
+		}
+	}
+}
+
+func TestRouteLoadShed(t *testing.T) {
+	asserts := assert.New(t)
+	requires := require.New(t)
+
+	r := chi.NewRouter()
+	cfg := &config.Config{ErrorFormat: vr.ErrorFormatProblem, APIKeys: "alice:alice-key,bob:bob-key", Admins: "alice"}
+	shedder := shed.NewLimiter(1, 10*time.Millisecond, time.Second)
+	NewTransport(r, cfg).WithLoadShedder(shedder).Routes(NewTasksMock())
+
+	send := func(method, url, apiKey, body string) *httptest.ResponseRecorder {
+		req, err := http.NewRequest(method, url, strings.NewReader(body))
+		requires.NoError(err, "http.NewRequest error")
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("Authorization", "Bearer "+apiKey)
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		return w
+	}
+
+	log.Print("\t 1 test load shed: request of free limit\n")
+	w := send(http.MethodPost, "/task/", "alice-key", `{"task_update":{"description":"one"}}`)
+	asserts.Equal(http.StatusCreated, w.Code)
+
+	log.Print("\t 2 test load shed: request waits longer than target\n")
+	token, err := shedder.Acquire(context.Background(), shed.Write)
+	requires.NoError(err)
+	w = send(http.MethodGet, "/task/1", "alice-key", "")
+	asserts.Equal(http.StatusServiceUnavailable, w.Code)
+	asserts.Equal("1", w.Header().Get("Retry-After"))
+	asserts.Regexp(`"type":"/problems/unavailable"`, w.Body.String())
+	w = send(http.MethodPost, "/task/", "alice-key", `{"task_update":{"description":"two"}}`)
+	asserts.Equal(http.StatusServiceUnavailable, w.Code)
+	token.Done()
+
+	log.Print("\t 3 test load shed: place is free after request\n")
+	w = send(http.MethodGet, "/task/1", "alice-key", "")
+	asserts.Equal(http.StatusOK, w.Code)
+	stats := shedder.Stats()
+	asserts.Equal(0, stats.InFlight)
+	asserts.Equal(int64(1), stats.RejectedReads)
+	asserts.Equal(int64(1), stats.RejectedWrites)
+
+	log.Print("\t 4 test load shed: counters of admins\n")
+	w = send(http.MethodGet, "/debug/vars", "bob-key", "")
+	asserts.Equal(http.StatusForbidden, w.Code)
+	w = send(http.MethodGet, "/debug/vars", "alice-key", "")
+	asserts.Equal(http.StatusOK, w.Code)
+	asserts.Regexp(`"memstats"`, w.Body.String())
+}
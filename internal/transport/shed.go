@@ -0,0 +1,51 @@
+// shed - limit of task requests in flight, shedding of load above it
+package transport
+
+import (
+	"net/http"
+
+	"github.com/Ekvo/golang-chi-postgres-api/internal/shed"
+	vr "github.com/Ekvo/golang-chi-postgres-api/internal/variables"
+)
+
+// shedRetryAfter - 'Retry-After' of shed request in seconds
+const shedRetryAfter = "1"
+
+// LoadShed - middleware
+// request takes place of 'limiter' (reads - GET, HEAD, OPTIONS - after writes),
+// too long wait in queue - 503 with 'Retry-After', place is given back by the last holder of 'shed.Token'
+// (look: TaskHandler)
+func LoadShed(limiter *shed.Limiter) func(next http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			token, err := limiter.Acquire(r.Context(), priority(r))
+			if err != nil {
+				w.Header().Set("Retry-After", shedRetryAfter)
+				writeError(w, r, http.StatusServiceUnavailable, taskError{key: vr.Load, err: shed.ErrShed})
+				return
+			}
+			defer token.Done()
+			next.ServeHTTP(w, r.WithContext(shed.NewContext(r.Context(), token)))
+		})
+	}
+}
+
+// BulkLoad - middleware
+// time of request of many rows (/task/batch) does not cut limit of 'LoadShed'
+func BulkLoad(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if token := shed.FromContext(r.Context()); token != nil {
+			token.Bulk()
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// priority - 'shed.Read' of safe methods, otherwise 'shed.Write'
+func priority(r *http.Request) shed.Priority {
+	switch r.Method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions:
+		return shed.Read
+	}
+	return shed.Write
+}
@@ -0,0 +1,120 @@
+// timetrack - timers and manual entries of time spent on 'Task', report of totals
+package transport
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+
+	"github.com/Ekvo/golang-chi-postgres-api/internal/model"
+	"github.com/Ekvo/golang-chi-postgres-api/internal/servises"
+	vr "github.com/Ekvo/golang-chi-postgres-api/internal/variables"
+	c "github.com/Ekvo/golang-chi-postgres-api/pkg/common"
+)
+
+// timerStart - running timer of user on 'Task', other running timer of user - 409
+func timerStart(db taskFindUpdate, r *http.Request) responseData {
+	id, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		return errorData(http.StatusBadRequest, vr.Params, ErrTransportParam)
+	}
+	entryValidator := servises.NewTimeEntryValidator()
+	if err := entryValidator.DecodeTimer(r); err != nil {
+		return decodeErrorData(err)
+	}
+	entry := entryValidator.TimeEntryModel()
+	entry.TaskID = uint(id)
+	entry.User = User(r.Context())
+	entry, err = db.StartTimer(r.Context(), entry)
+	if err != nil {
+		return storeErrorData(vr.TimeEntry, err)
+	}
+	return responseData{http.StatusCreated, c.Message{vr.TimeEntry: servises.NewTimeEntryResponse(entry, time.Now())}}
+}
+
+// timerStop - stop running timer of user on 'Task', no running timer - 404
+func timerStop(db taskFindUpdate, r *http.Request) responseData {
+	id, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		return errorData(http.StatusBadRequest, vr.Params, ErrTransportParam)
+	}
+	now := time.Now()
+	entry, err := db.StopTimer(r.Context(), model.TimerStop{TaskID: uint(id), User: User(r.Context()), At: now})
+	if err != nil {
+		return storeErrorData(vr.TimeEntry, err)
+	}
+	return responseData{http.StatusOK, c.Message{vr.TimeEntry: servises.NewTimeEntryResponse(entry, now)}}
+}
+
+// timeEntryCreate - manual entry of user with 'start' and 'stop' or 'duration'
+func timeEntryCreate(db taskFindUpdate, r *http.Request) responseData {
+	id, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		return errorData(http.StatusBadRequest, vr.Params, ErrTransportParam)
+	}
+	entryValidator := servises.NewTimeEntryValidator()
+	if err := entryValidator.Decode(r); err != nil {
+		return decodeErrorData(err)
+	}
+	entry := entryValidator.TimeEntryModel()
+	entry.TaskID = uint(id)
+	entry.User = User(r.Context())
+	entry, err = db.SaveTimeEntry(r.Context(), entry)
+	if err != nil {
+		return storeErrorData(vr.TimeEntry, err)
+	}
+	return responseData{http.StatusCreated, c.Message{vr.TimeEntry: servises.NewTimeEntryResponse(entry, time.Now())}}
+}
+
+// timeEntryList - entries of 'Task' with totals by day in time zone of 'Task'
+func timeEntryList(db taskFindUpdate, r *http.Request) responseData {
+	id, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		return errorData(http.StatusBadRequest, vr.Params, ErrTransportParam)
+	}
+	if _, err := db.FindOneTask(r.Context(), uint(id)); err != nil {
+		return storeErrorData(vr.Task, err)
+	}
+	entries, err := db.TimeEntries(r.Context(), uint(id))
+	if err != nil {
+		return storeErrorData(vr.TimeEntries, err)
+	}
+	tracked, err := timeTracked(r, db, []uint{uint(id)})
+	if err != nil {
+		return storeErrorData(vr.TimeEntries, err)
+	}
+	now := time.Now()
+	resp := servises.TimeEntriesResponse{Entries: make([]servises.TimeEntryResponse, len(entries))}
+	for i, entry := range entries {
+		resp.Entries[i] = servises.NewTimeEntryResponse(entry, now)
+	}
+	resp.Days = []servises.DayTotalResponse{}
+	if total := tracked[uint(id)]; total != nil {
+		resp.TotalSeconds, resp.Days = total.TotalSeconds, total.Days
+	}
+	return responseData{http.StatusOK, c.Message{vr.TimeEntries: resp}}
+}
+
+// timeReport - totals by 'Task' and by day, params of 'servises.NewTimeReport'
+func timeReport(db taskFindUpdate, r *http.Request) responseData {
+	report, err := servises.NewTimeReport(r.URL.Query())
+	if err != nil {
+		return errorData(http.StatusBadRequest, vr.Params, err)
+	}
+	totals, err := db.TimeTotals(r.Context(), report)
+	if err != nil {
+		return storeErrorData(vr.TimeReport, err)
+	}
+	return responseData{http.StatusOK, c.Message{vr.TimeReport: servises.NewTimeReportResponse(report, totals)}}
+}
+
+// timeTracked - totals of 'Task' of 'ids' by day in time zone of each 'Task'
+func timeTracked(r *http.Request, db taskFindUpdate, ids []uint) (map[uint]*servises.TimeTrackedResponse, error) {
+	totals, err := db.TimeTotals(r.Context(), model.TimeReport{TaskIDs: ids})
+	if err != nil {
+		return nil, err
+	}
+	return servises.NewTimeTracked(totals), nil
+}
@@ -2,41 +2,221 @@
 package transport
 
 import (
+	"context"
+	"expvar"
+	"fmt"
+	"net/http"
 	"time"
 
 	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/chi/v5/middleware"
 
+	"github.com/Ekvo/golang-chi-postgres-api/internal/blob"
+	"github.com/Ekvo/golang-chi-postgres-api/internal/config"
+	"github.com/Ekvo/golang-chi-postgres-api/internal/events"
+	"github.com/Ekvo/golang-chi-postgres-api/internal/gql"
 	"github.com/Ekvo/golang-chi-postgres-api/internal/model"
+	"github.com/Ekvo/golang-chi-postgres-api/internal/ratelimit"
+	"github.com/Ekvo/golang-chi-postgres-api/internal/shed"
 )
 
 // Transport - contain HTTP route multiplexer
 type Transport struct {
 	*chi.Mux
+	cfg   *config.Config
+	hub   *events.Hub
+	hooks model.WebhookStore
+
+	attachments model.AttachmentStore
+	blobs       blob.Store
+	attachMax   int64
+
+	idempotency    model.IdempotencyStore
+	idempotencyTTL time.Duration
+
+	limiter model.RateLimiter
+	shedder *shed.Limiter
+
+	streams      context.Context
+	closeStreams context.CancelFunc
+}
+
+func NewTransport(r *chi.Mux, cfg *config.Config) *Transport {
+	streams, closeStreams := context.WithCancel(context.Background())
+	return &Transport{Mux: r, cfg: cfg, streams: streams, closeStreams: closeStreams}
+}
+
+// CloseStreams - end of export and calendar feed ('StreamEnd'), it is called at start of shutdown
+// as 'events.Hub.Close' for streams of events
+func (r *Transport) CloseStreams() {
+	r.closeStreams()
+}
+
+// WithEvents - source of 'GET /task/events' and 'GET /ws', without it the routes are not registered
+func (r *Transport) WithEvents(hub *events.Hub) *Transport {
+	r.hub = hub
+	return r
+}
+
+// WithWebhooks - store of '/webhooks', without it the routes are not registered
+func (r *Transport) WithWebhooks(hooks model.WebhookStore) *Transport {
+	r.hooks = hooks
+	return r
+}
+
+// WithAttachments - store of '/task/{id}/attachments' and of content of files,
+// 'maxSize' - max size of one file in bytes, without it the routes are not registered
+func (r *Transport) WithAttachments(attachments model.AttachmentStore, blobs blob.Store, maxSize int64) *Transport {
+	r.attachments = attachments
+	r.blobs = blobs
+	r.attachMax = maxSize
+	return r
+}
+
+// WithIdempotency - store of responses of POST with 'Idempotency-Key' of '/task' and '/project',
+// they are kept for 'ttl', without it the header is ignored
+func (r *Transport) WithIdempotency(store model.IdempotencyStore, ttl time.Duration) *Transport {
+	r.idempotency = store
+	r.idempotencyTTL = ttl
+	return r
+}
+
+// WithRateLimiter - store of buckets of 'RATE_LIMITS' and of 'TASK_DAILY_QUOTA', without it there are no limits
+func (r *Transport) WithRateLimiter(limiter model.RateLimiter) *Transport {
+	r.limiter = limiter
+	return r
+}
+
+// WithLoadShedder - limit of requests of '/task' in flight, 'GET /debug/vars' (admins) shows its counters,
+// without it there is no limit
+func (r *Transport) WithLoadShedder(shedder *shed.Limiter) *Transport {
+	r.shedder = shedder
+	return r
+}
+
+// idempotent - middleware 'Idempotency' or nothing without 'WithIdempotency'
+func (r *Transport) idempotent() func(next http.Handler) http.Handler {
+	if r.idempotency == nil {
+		return func(next http.Handler) http.Handler { return next }
+	}
+	return Idempotency(r.idempotency, r.idempotencyTTL)
+}
+
+// taskQuota - middleware 'TaskQuota' or nothing without 'WithRateLimiter' or 'TASK_DAILY_QUOTA',
+// it is after 'idempotent', so replay of 'Idempotency-Key' does not take quota
+func (r *Transport) taskQuota() func(next http.Handler) http.Handler {
+	quota := r.cfg.DailyTaskQuota()
+	if r.limiter == nil || quota == 0 {
+		return func(next http.Handler) http.Handler { return next }
+	}
+	return TaskQuota(ratelimit.NewTaskQuota(r.limiter, quota))
 }
 
-func NewTransport(r *chi.Mux) *Transport {
-	return &Transport{Mux: r}
+// loadShed - middleware 'LoadShed' or nothing without 'WithLoadShedder'
+func (r *Transport) loadShed() func(next http.Handler) http.Handler {
+	if r.shedder == nil {
+		return func(next http.Handler) http.Handler { return next }
+	}
+	return LoadShed(r.shedder)
 }
 
 // in pair with 'func Timeout(timeout time.Duration) func(next http.Handler) http.Handler'
 const timeOut = 10 * time.Second
 
+// importTimeOut - 'POST /task/import', stream of body and COPY of all rows
+const importTimeOut = 10 * time.Minute
+
 type taskFindUpdate interface {
-	model.TaskFind
-	model.TaskUpdate
+	model.TaskStore
+	model.TimeTracker
+	model.CustomFieldStore
+	model.ProjectStore
+	model.TaskBoard
 }
 
 func (r *Transport) Routes(db taskFindUpdate) {
-	r.Use(Timeout(timeOut))
-	r.Mount("/task", taskRoutes(db))
+	// 'SRV_API_KEYS' is checked by 'config.NewConfig', error here - 'Config' was made by hand
+	keys, err := r.cfg.APIKeyUsers()
+	if err != nil {
+		panic(fmt.Sprintf("transport: SRV_API_KEYS - %v", err))
+	}
+	admins, err := r.cfg.AdminUsers()
+	if err != nil {
+		panic(fmt.Sprintf("transport: SRV_ADMINS - %v", err))
+	}
+	rules, err := r.cfg.RateLimitRules()
+	if err != nil {
+		panic(fmt.Sprintf("transport: RATE_LIMITS - %v", err))
+	}
+	r.Use(ErrorFormat(r.cfg.ErrorFormat))
+	if secret := r.cfg.CalendarSecret; secret != "" {
+		// feed has own token of user in URL (look: FeedToken)
+		r.With(StreamEnd(r.streams)).Get(calendarFeedPath, CalendarHandler(db, secret, keys))
+	}
+	r.Group(func(g chi.Router) {
+		g.Use(Authenticate(keys))
+		if r.limiter != nil && len(rules) > 0 {
+			g.Use(RateLimit(r.limiter, rules, r.cfg.RateLimitBy))
+		}
+		if secret := r.cfg.CalendarSecret; secret != "" {
+			g.With(Timeout(timeOut)).Get("/task/calendar/token", TaskHandler(db, calendarToken(secret)))
+		}
+		g.Mount("/task", r.taskRoutes(db))
+		g.Mount("/project", projectRoutes(db, r.idempotent()))
+		g.Mount("/fields", customFieldRoutes(db, Admin(keys, admins)))
+		g.With(Timeout(timeOut), r.taskQuota()).Method(http.MethodPost, "/graphql", gql.NewHandler(db))
+		if r.hooks != nil {
+			// URL and secrets of webhooks are shared by all users - only admins
+			g.With(Admin(keys, admins)).Mount("/webhooks", webhookRoutes(r.hooks))
+		}
+		if r.hub != nil {
+			g.Get("/ws", WSHandler(r.hub, newPresence()))
+		}
+		if r.shedder != nil {
+			g.With(Admin(keys, admins)).Handle("/debug/vars", expvar.Handler())
+		}
+	})
 }
 
-func taskRoutes(db taskFindUpdate) chi.Router {
+func (t *Transport) taskRoutes(db taskFindUpdate) chi.Router {
 	r := chi.NewRouter()
+	// streams - without 'Timeout', they end with the client, the data or shutdown
+	r.With(StreamEnd(t.streams)).Get("/export", ExportHandler(db))
+	if t.hub != nil {
+		r.Get("/events", EventsHandler(db, t.hub))
+	}
+	if t.attachments != nil {
+		r.Mount("/{id}/attachments", attachmentRoutes(t.attachments, t.blobs, t.attachMax))
+	}
+	// body is a stream - without buffer of 'idempotent', 'loadShed' and with own deadline
+	r.With(Timeout(importTimeOut), t.taskQuota()).Post("/import", TaskHandler(db, taskImport))
+
+	r.Group(func(r chi.Router) {
+		// wait in queue of 'loadShed' is not part of 'Timeout'
+		r.Use(t.loadShed(), Timeout(timeOut), t.idempotent(), t.taskQuota())
+		taskCRUDRoutes(r, db)
+	})
+	return r
+}
+
+// taskCRUDRoutes - routes of 'TaskHandler', each works with 'Timeout'
+func taskCRUDRoutes(r chi.Router, db taskFindUpdate) {
+	r.Get("/", TaskHandler(db, taskSearch))
 	r.Post("/", TaskHandler(db, taskCreate))
+	r.With(BulkLoad).Post("/batch", TaskHandler(db, taskBatch))
+	r.Get("/time/report", TaskHandler(db, timeReport))
+	r.Get("/board", TaskHandler(db, taskBoard))
 	r.Get("/{id}", TaskHandler(db, taskByID))
 	r.Put("/{id}", TaskHandler(db, taskUpdate))
+	r.With(middleware.SetHeader("Accept-Patch", acceptPatch)).
+		Patch("/{id}", TaskHandler(db, taskPatch))
 	r.Delete("/{id}", TaskHandler(db, taskRemove))
+	r.Post("/{id}/complete", TaskHandler(db, taskComplete))
+	r.Post("/{id}/move", TaskHandler(db, taskMove))
+	r.Get("/{id}/occurrences", TaskHandler(db, taskOccurrences))
+	r.Post("/{id}/timer/start", TaskHandler(db, timerStart))
+	r.Post("/{id}/timer/stop", TaskHandler(db, timerStop))
+	r.Post("/{id}/time", TaskHandler(db, timeEntryCreate))
+	r.Get("/{id}/time", TaskHandler(db, timeEntryList))
 	r.Get("/{order}/{limit}/{offset}", TaskHandler(db, taskList))
-	return r
 }
@@ -0,0 +1,105 @@
+// webhook - subscriptions of webhooks and log of their deliveries
+package transport
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/go-chi/chi/v5"
+
+	"github.com/Ekvo/golang-chi-postgres-api/internal/model"
+	"github.com/Ekvo/golang-chi-postgres-api/internal/servises"
+	vr "github.com/Ekvo/golang-chi-postgres-api/internal/variables"
+	c "github.com/Ekvo/golang-chi-postgres-api/pkg/common"
+)
+
+// size of page of log of deliveries
+const (
+	deliveryLimit    = 50
+	maxDeliveryLimit = 500
+)
+
+// webhookRoutes - routes of '/webhooks', each works with 'Timeout'
+func webhookRoutes(db model.WebhookStore) chi.Router {
+	r := chi.NewRouter()
+	r.Use(Timeout(timeOut))
+	r.Post("/", TaskHandler(db, webhookCreate))
+	r.Get("/", TaskHandler(db, webhookList))
+	r.Delete("/{id}", TaskHandler(db, webhookRemove))
+	r.Get("/{id}/deliveries", TaskHandler(db, webhookDeliveryLog))
+	r.Post("/deliveries/{id}/redeliver", TaskHandler(db, webhookRedeliver))
+	return r
+}
+
+func webhookCreate(db model.WebhookStore, r *http.Request) responseData {
+	webhookValidator := servises.NewWebhookValidator()
+	if err := webhookValidator.Decode(r); err != nil {
+		return decodeErrorData(err)
+	}
+	id, err := db.CreateWebhook(r.Context(), webhookValidator.WebhookModel())
+	if err != nil {
+		return storeErrorData(vr.DataBase, err)
+	}
+	return responseData{http.StatusCreated, c.Message{vr.Webhook: id}}
+}
+
+func webhookList(db model.WebhookStore, r *http.Request) responseData {
+	hooks, err := db.FindWebhooks(r.Context())
+	if err != nil {
+		return storeErrorData(vr.DataBase, err)
+	}
+	serialize := servises.WebhookListSerializer{Webhooks: hooks}
+	return responseData{http.StatusOK, c.Message{vr.WebhookList: serialize.Response()}}
+}
+
+func webhookRemove(db model.WebhookStore, r *http.Request) responseData {
+	id, err := strconv.ParseUint(chi.URLParam(r, "id"), 10, 32)
+	if err != nil || id == 0 {
+		return errorData(http.StatusBadRequest, vr.Params, ErrTransportParam)
+	}
+	if err := db.DeleteWebhook(r.Context(), uint(id)); err != nil {
+		return storeErrorData(vr.Webhook, err)
+	}
+	return responseData{http.StatusOK, c.Message{vr.Webhook: "deleted"}}
+}
+
+// webhookDeliveryLog - deliveries of webhook, newest first
+//
+// params: 'status' - pending|succeeded|dead, 'limit' - from 1 to 'maxDeliveryLimit'
+func webhookDeliveryLog(db model.WebhookStore, r *http.Request) responseData {
+	id, err := strconv.ParseUint(chi.URLParam(r, "id"), 10, 32)
+	if err != nil || id == 0 {
+		return errorData(http.StatusBadRequest, vr.Params, ErrTransportParam)
+	}
+	filter := model.DeliveryFilter{WebhookID: uint(id), Status: r.URL.Query().Get("status"), Limit: deliveryLimit}
+	switch filter.Status {
+	case "", model.DeliveryPending, model.DeliverySucceeded, model.DeliveryDead:
+	default:
+		return errorData(http.StatusBadRequest, vr.Params, ErrTransportParam)
+	}
+	if v := r.URL.Query().Get("limit"); v != "" {
+		limit, err := strconv.Atoi(v)
+		if err != nil || limit < 1 || limit > maxDeliveryLimit {
+			return errorData(http.StatusBadRequest, vr.Params, ErrTransportParam)
+		}
+		filter.Limit = limit
+	}
+	deliveries, err := db.WebhookDeliveries(r.Context(), filter)
+	if err != nil {
+		return storeErrorData(vr.Webhook, err)
+	}
+	serialize := servises.WebhookDeliveriesSerializer{Deliveries: deliveries}
+	return responseData{http.StatusOK, c.Message{vr.WebhookDeliveries: serialize.Response()}}
+}
+
+// webhookRedeliver - delivery is pending again with all attempts, worker sends it on next claim
+func webhookRedeliver(db model.WebhookStore, r *http.Request) responseData {
+	id, err := strconv.ParseUint(chi.URLParam(r, "id"), 10, 64)
+	if err != nil || id == 0 {
+		return errorData(http.StatusBadRequest, vr.Params, ErrTransportParam)
+	}
+	if err := db.RedeliverWebhook(r.Context(), id); err != nil {
+		return storeErrorData(vr.WebhookDeliveries, err)
+	}
+	return responseData{http.StatusAccepted, c.Message{vr.WebhookDeliveries: "redelivery scheduled"}}
+}
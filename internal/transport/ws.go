@@ -0,0 +1,392 @@
+// ws - WebSocket of changes of 'Task' with subscriptions by filters and presence of users
+package transport
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/gorilla/websocket"
+
+	"github.com/Ekvo/golang-chi-postgres-api/internal/events"
+	"github.com/Ekvo/golang-chi-postgres-api/internal/model"
+	"github.com/Ekvo/golang-chi-postgres-api/internal/servises"
+)
+
+// parameters of WebSocket
+const (
+	// wsSendBuffer - messages waiting for a slow client, more - connection is closed
+	wsSendBuffer = 64
+	wsPingPeriod = 30 * time.Second
+	wsPongWait   = 2 * wsPingPeriod
+	wsWriteWait  = 10 * time.Second
+	wsMaxMessage = 4 << 10
+	// wsMaxSubscriptions - subscriptions of one connection
+	wsMaxSubscriptions = 32
+	// wsMaxTaskIDs - ids of one subscription
+	wsMaxTaskIDs = 100
+	// wsMaxState - length of state of presence
+	wsMaxState = 32
+)
+
+// types of messages of WebSocket
+const (
+	wsSubscribe   = "subscribe"
+	wsUnsubscribe = "unsubscribe"
+	wsSubscribed  = "subscribed"
+	wsEvent       = "event"
+	wsPresence    = "presence"
+	wsPing        = "ping"
+	wsPong        = "pong"
+	wsError       = "error"
+	// presenceLeft - state of presence which removes user from task
+	presenceLeft = "left"
+)
+
+var (
+	ErrTransportWSMessage      = errors.New("unknown message")
+	ErrTransportWSSubscription = errors.New("invalid subscription")
+)
+
+// wsMessage - message of client
+//
+//	{"type":"subscribe","id":"s1","task_ids":[5],"events":["updated"]} - empty lists are all
+//	{"type":"unsubscribe","id":"s1"}
+//	{"type":"presence","task_id":5,"state":"editing"} - "left" removes presence
+//	{"type":"ping"}
+type wsMessage struct {
+	Type    string   `json:"type"`
+	ID      string   `json:"id,omitempty"`
+	TaskIDs []uint   `json:"task_ids,omitempty"`
+	Events  []string `json:"events,omitempty"`
+	TaskID  uint     `json:"task_id,omitempty"`
+	State   string   `json:"state,omitempty"`
+}
+
+// wsReply - message of server: "subscribed", "event", "presence", "pong", "error"
+type wsReply struct {
+	Type    string                      `json:"type"`
+	ID      string                      `json:"id,omitempty"`
+	EventID uint64                      `json:"event_id,omitempty"`
+	Event   *servises.TaskEventResponse `json:"event,omitempty"`
+	TaskID  uint                        `json:"task_id,omitempty"`
+	User    string                      `json:"user,omitempty"`
+	State   string                      `json:"state,omitempty"`
+	Error   string                      `json:"error,omitempty"`
+}
+
+// wsFilter - subscription of connection, empty field - no condition
+type wsFilter struct {
+	taskIDs map[uint]bool
+	events  map[string]bool
+}
+
+func (f wsFilter) watches(taskID uint) bool {
+	return len(f.taskIDs) == 0 || f.taskIDs[taskID]
+}
+
+func (f wsFilter) matches(ev model.TaskEvent) bool {
+	return f.watches(ev.TaskID) && (len(f.events) == 0 || f.events[ev.Type])
+}
+
+// wsConn - one client, all writes to socket are in 'writeLoop'
+type wsConn struct {
+	ws   *websocket.Conn
+	user string
+	send chan wsReply
+	mu   sync.Mutex
+	subs map[string]wsFilter
+	// closed - reason of close, 'done' is closed with it
+	closed    *websocket.CloseError
+	done      chan struct{}
+	closeOnce sync.Once
+}
+
+// enqueue - message to 'send' without waiting, full buffer - client is too slow and connection is closed
+func (c *wsConn) enqueue(reply wsReply) {
+	select {
+	case <-c.done:
+	case c.send <- reply:
+	default:
+		c.close(websocket.CloseTryAgainLater, "client is too slow")
+	}
+}
+
+func (c *wsConn) close(code int, text string) {
+	c.closeOnce.Do(func() {
+		c.closed = &websocket.CloseError{Code: code, Text: text}
+		close(c.done)
+	})
+}
+
+func (c *wsConn) watches(taskID uint) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for _, f := range c.subs {
+		if f.watches(taskID) {
+			return true
+		}
+	}
+	return false
+}
+
+// presence - who works with which 'Task' (users of this process)
+type presence struct {
+	mu     sync.Mutex
+	conns  map[*wsConn]struct{}
+	states map[uint]map[*wsConn]string
+}
+
+func newPresence() *presence {
+	return &presence{conns: map[*wsConn]struct{}{}, states: map[uint]map[*wsConn]string{}}
+}
+
+func (p *presence) join(c *wsConn) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.conns[c] = struct{}{}
+}
+
+// leave - connection is closed, its presence is "left" for others
+func (p *presence) leave(c *wsConn) {
+	p.mu.Lock()
+	var tasks []uint
+	for taskID, conns := range p.states {
+		if _, ok := conns[c]; ok {
+			tasks = append(tasks, taskID)
+		}
+	}
+	delete(p.conns, c)
+	p.mu.Unlock()
+	for _, taskID := range tasks {
+		p.set(c, taskID, presenceLeft)
+	}
+}
+
+// set - state of user of 'c' for task, sent to connections which watch the task
+func (p *presence) set(c *wsConn, taskID uint, state string) {
+	p.mu.Lock()
+	if state == presenceLeft {
+		delete(p.states[taskID], c)
+		if len(p.states[taskID]) == 0 {
+			delete(p.states, taskID)
+		}
+	} else {
+		if p.states[taskID] == nil {
+			p.states[taskID] = map[*wsConn]string{}
+		}
+		p.states[taskID][c] = state
+	}
+	others := make([]*wsConn, 0, len(p.conns))
+	for other := range p.conns {
+		if other != c {
+			others = append(others, other)
+		}
+	}
+	p.mu.Unlock()
+
+	reply := wsReply{Type: wsPresence, TaskID: taskID, User: c.user, State: state}
+	for _, other := range others {
+		if other.watches(taskID) {
+			other.enqueue(reply)
+		}
+	}
+}
+
+// snapshot - current presence of tasks of filter for new subscription
+func (p *presence) snapshot(c *wsConn, f wsFilter) []wsReply {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	var replies []wsReply
+	for taskID, conns := range p.states {
+		if !f.watches(taskID) {
+			continue
+		}
+		for other, state := range conns {
+			if other != c {
+				replies = append(replies, wsReply{Type: wsPresence, TaskID: taskID, User: other.user, State: state})
+			}
+		}
+	}
+	slices.SortFunc(replies, func(a, b wsReply) int { return int(a.TaskID) - int(b.TaskID) })
+	return replies
+}
+
+var wsUpgrader = websocket.Upgrader{
+	ReadBufferSize:  1024,
+	WriteBufferSize: 1024,
+}
+
+// WSHandler - 'GET /ws' (user from 'Authenticate', works without 'Timeout')
+//
+// events of 'hub' are sent by subscriptions of client, presence is shared between clients of process,
+// server sends ping each 'wsPingPeriod', client without pong is closed,
+// slow client (full buffer of 'wsSendBuffer' messages) is closed with code 1013
+func WSHandler(hub *events.Hub, pr *presence) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ws, err := wsUpgrader.Upgrade(w, r, nil)
+		if err != nil {
+			// Upgrade has written the error
+			return
+		}
+		c := &wsConn{
+			ws:   ws,
+			user: User(r.Context()),
+			send: make(chan wsReply, wsSendBuffer),
+			subs: map[string]wsFilter{},
+			done: make(chan struct{}),
+		}
+		sub := hub.Subscribe()
+		pr.join(c)
+		defer func() {
+			sub.Close()
+			pr.leave(c)
+		}()
+
+		go c.eventLoop(sub)
+		go c.readLoop(pr)
+		c.writeLoop()
+	}
+}
+
+// eventLoop - events of 'hub' by subscriptions of client
+func (c *wsConn) eventLoop(sub *events.Subscription) {
+	for {
+		select {
+		case <-c.done:
+			return
+		case ev, ok := <-sub.Events():
+			if !ok {
+				if sub.Lagged() {
+					c.close(websocket.CloseTryAgainLater, "client is too slow")
+				} else {
+					c.close(websocket.CloseGoingAway, "server stops")
+				}
+				return
+			}
+			c.mu.Lock()
+			var ids []string
+			for id, f := range c.subs {
+				if f.matches(ev) {
+					ids = append(ids, id)
+				}
+			}
+			c.mu.Unlock()
+			slices.Sort(ids)
+			serialize := servises.TaskEventSerializer{TaskEvent: ev}
+			body := serialize.Response()
+			for _, id := range ids {
+				c.enqueue(wsReply{Type: wsEvent, ID: id, EventID: ev.ID, Event: &body})
+			}
+		}
+	}
+}
+
+// readLoop - messages of client until error of socket
+func (c *wsConn) readLoop(pr *presence) {
+	defer c.close(websocket.CloseNormalClosure, "")
+	c.ws.SetReadLimit(wsMaxMessage)
+	if err := c.ws.SetReadDeadline(time.Now().Add(wsPongWait)); err != nil {
+		return
+	}
+	c.ws.SetPongHandler(func(string) error {
+		return c.ws.SetReadDeadline(time.Now().Add(wsPongWait))
+	})
+	for {
+		_, data, err := c.ws.ReadMessage()
+		if err != nil {
+			if websocket.IsUnexpectedCloseError(err, websocket.CloseNormalClosure, websocket.CloseGoingAway) {
+				log.Printf("transport: ws read error - %v", err)
+			}
+			return
+		}
+		msg := wsMessage{}
+		if err := json.Unmarshal(data, &msg); err != nil {
+			c.enqueue(wsReply{Type: wsError, Error: ErrTransportWSMessage.Error()})
+			continue
+		}
+		if err := c.handle(pr, msg); err != nil {
+			c.enqueue(wsReply{Type: wsError, ID: msg.ID, Error: err.Error()})
+		}
+	}
+}
+
+func (c *wsConn) handle(pr *presence, msg wsMessage) error {
+	switch msg.Type {
+	case wsSubscribe:
+		if msg.ID == "" || len(msg.TaskIDs) > wsMaxTaskIDs {
+			return ErrTransportWSSubscription
+		}
+		f := wsFilter{taskIDs: map[uint]bool{}, events: map[string]bool{}}
+		for _, id := range msg.TaskIDs {
+			f.taskIDs[id] = true
+		}
+		for _, typ := range msg.Events {
+			if typ != model.EventCreated && typ != model.EventUpdated && typ != model.EventDeleted {
+				return ErrTransportWSSubscription
+			}
+			f.events[typ] = true
+		}
+		c.mu.Lock()
+		_, ex := c.subs[msg.ID]
+		if !ex && len(c.subs) >= wsMaxSubscriptions {
+			c.mu.Unlock()
+			return ErrTransportWSSubscription
+		}
+		c.subs[msg.ID] = f
+		c.mu.Unlock()
+		c.enqueue(wsReply{Type: wsSubscribed, ID: msg.ID})
+		for _, reply := range pr.snapshot(c, f) {
+			c.enqueue(reply)
+		}
+	case wsUnsubscribe:
+		c.mu.Lock()
+		delete(c.subs, msg.ID)
+		c.mu.Unlock()
+	case wsPresence:
+		if msg.TaskID == 0 || msg.State == "" || len(msg.State) > wsMaxState {
+			return ErrTransportWSMessage
+		}
+		pr.set(c, msg.TaskID, msg.State)
+	case wsPing:
+		c.enqueue(wsReply{Type: wsPong})
+	default:
+		return ErrTransportWSMessage
+	}
+	return nil
+}
+
+// writeLoop - messages of 'send' and ping, at the end - close frame with reason of 'close'
+func (c *wsConn) writeLoop() {
+	ping := time.NewTicker(wsPingPeriod)
+	defer func() {
+		ping.Stop()
+		if err := c.ws.Close(); err != nil {
+			log.Printf("transport: ws close error - %v", err)
+		}
+	}()
+	for {
+		select {
+		case <-c.done:
+			msg := websocket.FormatCloseMessage(c.closed.Code, c.closed.Text)
+			_ = c.ws.WriteControl(websocket.CloseMessage, msg, time.Now().Add(wsWriteWait))
+			return
+		case reply := <-c.send:
+			if err := c.ws.SetWriteDeadline(time.Now().Add(wsWriteWait)); err != nil {
+				c.close(websocket.CloseInternalServerErr, "")
+				continue
+			}
+			if err := c.ws.WriteJSON(reply); err != nil {
+				c.close(websocket.CloseInternalServerErr, "")
+			}
+		case <-ping.C:
+			if err := c.ws.WriteControl(websocket.PingMessage, nil, time.Now().Add(wsWriteWait)); err != nil {
+				c.close(websocket.CloseGoingAway, "")
+			}
+		}
+	}
+}
@@ -4,9 +4,49 @@ package variables
 const RFC3339Milli = "2006-01-02T15:04:05.999Z07:00"
 
 const (
-	Task      = "task"
-	TaskList  = "task_list"
-	Params    = "param"
-	DataBase  = "data_base"
-	Validator = "validator"
+	Task              = "task"
+	TaskList          = "task_list"
+	TaskBatch         = "task_batch"
+	TaskImport        = "task_import"
+	TaskOccurrences   = "task_occurrences"
+	CalendarFeed      = "calendar_feed"
+	TimeEntry         = "time_entry"
+	TimeEntries       = "time_entries"
+	TimeReport        = "time_report"
+	Attachment        = "attachment"
+	Attachments       = "attachments"
+	CustomField       = "custom_field"
+	CustomFields      = "custom_fields"
+	Project           = "project"
+	Projects          = "projects"
+	Board             = "board"
+	Idempotency       = "idempotency"
+	RateLimit         = "rate_limit"
+	Load              = "load"
+	Webhook           = "webhook"
+	WebhookList       = "webhook_list"
+	WebhookDeliveries = "webhook_deliveries"
+	Params            = "param"
+	DataBase          = "data_base"
+	Validator         = "validator"
+	Auth              = "auth"
+)
+
+// format of error body, look 'SRV_ERROR_FORMAT' in .env
+const (
+	ErrorFormatProblem = "problem"
+	ErrorFormatLegacy  = "legacy"
+)
+
+// 'type' of 'application/problem+json'
+const (
+	ProblemDefault       = "about:blank"
+	ProblemInvalidParams = "/problems/invalid-params"
+	ProblemValidation    = "/problems/validation"
+	ProblemNotFound      = "/problems/not-found"
+	ProblemConflict      = "/problems/conflict"
+	ProblemUnavailable   = "/problems/unavailable"
+	ProblemUnauthorized  = "/problems/unauthorized"
+	ProblemForbidden     = "/problems/forbidden"
+	ProblemTooMany       = "/problems/too-many-requests"
 )
@@ -0,0 +1,54 @@
+package webhook
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/netip"
+	"syscall"
+	"time"
+)
+
+// ErrWebhookAddress - URL of webhook is resolved to loopback, private or other not public address
+var ErrWebhookAddress = errors.New("address of webhook is not public")
+
+// dialTimeout - connection of one attempt, part of 'RequestTimeout'
+const dialTimeout = 5 * time.Second
+
+// newClient - client of deliveries, 'control' checks each address before connection
+// (after DNS, so name of webhook can not be pointed to internal host later), redirects are not followed
+func newClient(control func(network, address string, c syscall.RawConn) error) *http.Client {
+	dialer := &net.Dialer{Timeout: dialTimeout, Control: control}
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.Proxy = nil
+	transport.DialContext = dialer.DialContext
+	return &http.Client{
+		Transport: transport,
+		Timeout:   RequestTimeout,
+		CheckRedirect: func(*http.Request, []*http.Request) error {
+			// status of redirect is result of attempt
+			return http.ErrUseLastResponse
+		},
+	}
+}
+
+// publicOnly - 'net.Dialer.Control', only global unicast not private addresses
+func publicOnly(_, address string, _ syscall.RawConn) error {
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		return err
+	}
+	addr, err := netip.ParseAddr(host)
+	if err != nil {
+		return err
+	}
+	addr = addr.Unmap()
+	if !addr.IsGlobalUnicast() || addr.IsPrivate() || sharedAddress.Contains(addr) {
+		return fmt.Errorf("%w - %s", ErrWebhookAddress, addr)
+	}
+	return nil
+}
+
+// sharedAddress - carrier-grade NAT (RFC 6598), not reachable from outside as private ranges
+var sharedAddress = netip.MustParsePrefix("100.64.0.0/10")
@@ -0,0 +1,197 @@
+// webhook - delivery of outbox of webhooks with signature and retries
+package webhook
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/Ekvo/golang-chi-postgres-api/internal/jobs"
+	"github.com/Ekvo/golang-chi-postgres-api/internal/model"
+	"github.com/Ekvo/golang-chi-postgres-api/internal/servises"
+	c "github.com/Ekvo/golang-chi-postgres-api/pkg/common"
+)
+
+// parameters of delivery
+const (
+	// MaxAttempts - after the last failed attempt delivery is dead (only manual redelivery)
+	MaxAttempts = 8
+	// BaseBackoff - delay after first failed attempt, each next is twice longer up to 'MaxBackoff'
+	BaseBackoff = 10 * time.Second
+	MaxBackoff  = time.Hour
+
+	// RequestTimeout - time of one POST to webhook
+	RequestTimeout = 10 * time.Second
+	// claimLease - claimed delivery is hidden from other workers, longer than 'RequestTimeout'
+	claimLease = 6 * RequestTimeout
+	claimLimit = 20
+	// maxResponseRead - body of response is read for reuse of connection
+	maxResponseRead = 4 << 10
+
+	// KindDeliver - periodic job of delivery of due deliveries
+	KindDeliver     = "webhook.deliver"
+	deliverInterval = 2 * time.Second
+	// KindPurge - periodic job of removal of finished deliveries
+	KindPurge     = "webhook.purge"
+	purgeInterval = time.Hour
+	// Retention - succeeded deliveries are kept for log, dead ones - for manual redelivery
+	Retention     = 7 * 24 * time.Hour
+	DeadRetention = 30 * 24 * time.Hour
+)
+
+// headers of delivery
+const (
+	HeaderDelivery  = "X-Webhook-Delivery"
+	HeaderEvent     = "X-Webhook-Event"
+	HeaderTimestamp = "X-Webhook-Timestamp"
+	// HeaderSignature - "sha256=" + hex of HMAC-SHA256 of "<timestamp>.<body>" with secret of webhook
+	HeaderSignature = "X-Webhook-Signature"
+)
+
+// Sign - value of 'HeaderSignature', receiver computes it the same way and compares in constant time
+func Sign(secret string, timestamp int64, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(strconv.FormatInt(timestamp, 10)))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// Backoff - delay after failed attempt number 'attempt' (from 1)
+func Backoff(attempt int) time.Duration {
+	delay := BaseBackoff
+	for i := 1; i < attempt && delay < MaxBackoff; i++ {
+		delay *= 2
+	}
+	return min(delay, MaxBackoff)
+}
+
+// Worker - delivers pending deliveries of 'model.WebhookOutbox' and removes finished ones,
+// runs as periodic jobs of 'jobs.Pool'
+//
+// success - any 2xx status, other status or error of network - next attempt by 'Backoff'
+type Worker struct {
+	db     model.WebhookOutbox
+	client *http.Client
+	now    func() time.Time
+}
+
+func NewWorker(db model.WebhookOutbox) *Worker {
+	return &Worker{
+		db:     db,
+		client: newClient(publicOnly),
+		now:    time.Now,
+	}
+}
+
+// Register - 'Deliver' and 'Purge' are periodic jobs of 'pool'
+func (w *Worker) Register(pool *jobs.Pool) {
+	pool.Every(KindDeliver, deliverInterval, w.Deliver)
+	pool.Every(KindPurge, purgeInterval, w.Purge)
+}
+
+// Deliver - claims while they are full and there is time for one more before end of 'ctx',
+// end of 'ctx' (timeout of job, shutdown of pool) cancels deliveries in progress,
+// not finished ones are taken again after 'claimLease'
+func (w *Worker) Deliver(ctx context.Context, job model.Job) error {
+	for {
+		n, err := w.DeliverPending(ctx)
+		if err != nil {
+			return err
+		}
+		deadline, ok := ctx.Deadline()
+		if n < claimLimit || (ok && time.Until(deadline) < RequestTimeout) {
+			return nil
+		}
+	}
+}
+
+// Purge - succeeded deliveries after 'Retention' and dead ones after 'DeadRetention'
+func (w *Worker) Purge(ctx context.Context, job model.Job) error {
+	_, err := w.db.PurgeDeliveries(ctx, model.DeliveryPurge{Succeeded: Retention, Dead: DeadRetention})
+	return err
+}
+
+// DeliverPending - one claim of due deliveries, all of them are sent in parallel, returns number of claimed
+func (w *Worker) DeliverPending(ctx context.Context) (int, error) {
+	deliveries, err := w.db.ClaimDeliveries(ctx, model.DeliveryClaim{Limit: claimLimit, Lease: claimLease})
+	if err != nil {
+		return 0, err
+	}
+	var wg sync.WaitGroup
+	for _, dl := range deliveries {
+		wg.Add(1)
+		go func(dl model.WebhookDelivery) {
+			defer wg.Done()
+			result := w.deliver(ctx, dl)
+			if err := w.db.FinishDelivery(ctx, result); err != nil {
+				log.Printf("webhook: finish of delivery %d error - %v", dl.ID, err)
+			}
+		}(dl)
+	}
+	wg.Wait()
+	return len(deliveries), nil
+}
+
+// deliver - one attempt of 'dl'
+func (w *Worker) deliver(ctx context.Context, dl model.WebhookDelivery) model.DeliveryResult {
+	result := model.DeliveryResult{ID: dl.ID}
+	result.Status, result.Err = w.post(ctx, dl)
+	now := w.now()
+	result.NextAttemptAt = now
+	if result.Err != nil {
+		attempt := dl.Attempts + 1
+		result.Dead = attempt >= MaxAttempts
+		if !result.Dead {
+			result.NextAttemptAt = now.Add(Backoff(attempt))
+		}
+	}
+	return result
+}
+
+// post - signed POST of payload of 'dl', returns status of response
+func (w *Worker) post(ctx context.Context, dl model.WebhookDelivery) (int, error) {
+	body, err := json.Marshal(servises.NewWebhookPayload(dl.Event))
+	if err != nil {
+		return 0, err
+	}
+	ctx, cancel := context.WithTimeout(ctx, RequestTimeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, dl.URL, bytes.NewReader(body))
+	if err != nil {
+		return 0, err
+	}
+	timestamp := w.now().Unix()
+	req.Header.Set("Content-Type", c.MediaJSON)
+	req.Header.Set("User-Agent", "task-webhook/1")
+	req.Header.Set(HeaderDelivery, strconv.FormatUint(dl.ID, 10))
+	req.Header.Set(HeaderEvent, dl.Event.Type)
+	req.Header.Set(HeaderTimestamp, strconv.FormatInt(timestamp, 10))
+	req.Header.Set(HeaderSignature, Sign(dl.Secret, timestamp, body))
+	resp, err := w.client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer func() {
+		if _, err := io.Copy(io.Discard, io.LimitReader(resp.Body, maxResponseRead)); err != nil {
+			log.Printf("webhook: read of response error - %v", err)
+		}
+		if err := resp.Body.Close(); err != nil {
+			log.Printf("webhook: resp.Body.Close error - %v", err)
+		}
+	}()
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return resp.StatusCode, fmt.Errorf("status %d", resp.StatusCode)
+	}
+	return resp.StatusCode, nil
+}
@@ -0,0 +1,310 @@
+package webhook
+
+import (
+	"context"
+	"crypto/hmac"
+	"encoding/json"
+	"io"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/Ekvo/golang-chi-postgres-api/internal/model"
+	"github.com/Ekvo/golang-chi-postgres-api/internal/servises"
+)
+
+// outboxMock - claim returns 'pending' once, results of 'FinishDelivery' and data of purge are kept
+type outboxMock struct {
+	mu      sync.Mutex
+	pending []model.WebhookDelivery
+	results map[uint64]model.DeliveryResult
+	purge   model.DeliveryPurge
+}
+
+func (m *outboxMock) ClaimDeliveries(ctx context.Context, data any) ([]model.WebhookDelivery, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	claim := data.(model.DeliveryClaim)
+	n := min(claim.Limit, len(m.pending))
+	claimed := m.pending[:n]
+	m.pending = m.pending[n:]
+	return claimed, ctx.Err()
+}
+
+func (m *outboxMock) FinishDelivery(ctx context.Context, data any) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	result := data.(model.DeliveryResult)
+	m.results[result.ID] = result
+	return ctx.Err()
+}
+
+func (m *outboxMock) PurgeDeliveries(ctx context.Context, data any) (int64, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.purge = data.(model.DeliveryPurge)
+	return 0, ctx.Err()
+}
+
+// receiver - stand-in of webhook, checks signature and answers with status by path
+type receiver struct {
+	mu       sync.Mutex
+	payloads map[string]servises.WebhookPayload
+}
+
+func (rc *receiver) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	timestamp, err := strconv.ParseInt(r.Header.Get(HeaderTimestamp), 10, 64)
+	if err != nil ||
+		!hmac.Equal([]byte(r.Header.Get(HeaderSignature)), []byte(Sign("0123456789abcdef", timestamp, body))) {
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+	payload := servises.WebhookPayload{}
+	if err := json.Unmarshal(body, &payload); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	rc.mu.Lock()
+	rc.payloads[r.Header.Get(HeaderDelivery)] = payload
+	rc.mu.Unlock()
+	switch r.URL.Path {
+	case "/ok":
+		w.WriteHeader(http.StatusNoContent)
+	case "/moved":
+		http.Redirect(w, r, "/ok", http.StatusFound)
+	default:
+		w.WriteHeader(http.StatusInternalServerError)
+	}
+}
+
+var workerTestData = []struct {
+	description string
+	path        string
+	secret      string
+	attempts    int
+	status      int
+	hasErr      bool
+	dead        bool
+	delay       time.Duration
+	msg         string
+}{
+	{
+		description: "Delivered",
+		path:        "/ok",
+		secret:      "0123456789abcdef",
+		status:      http.StatusNoContent,
+		msg:         "valid - 2xx is success",
+	},
+	{
+		description: "First failure",
+		path:        "/fail",
+		secret:      "0123456789abcdef",
+		status:      http.StatusInternalServerError,
+		hasErr:      true,
+		delay:       BaseBackoff,
+		msg:         "invalid - next attempt after 'BaseBackoff'",
+	},
+	{
+		description: "Fourth failure",
+		path:        "/fail",
+		secret:      "0123456789abcdef",
+		attempts:    3,
+		status:      http.StatusInternalServerError,
+		hasErr:      true,
+		delay:       8 * BaseBackoff,
+		msg:         "invalid - delay grows twice",
+	},
+	{
+		description: "Last failure",
+		path:        "/fail",
+		secret:      "0123456789abcdef",
+		attempts:    MaxAttempts - 1,
+		status:      http.StatusInternalServerError,
+		hasErr:      true,
+		dead:        true,
+		msg:         "invalid - delivery is dead",
+	},
+	{
+		description: "Redirect",
+		path:        "/moved",
+		secret:      "0123456789abcdef",
+		status:      http.StatusFound,
+		hasErr:      true,
+		delay:       BaseBackoff,
+		msg:         "invalid - redirect is not followed",
+	},
+	{
+		description: "Wrong secret",
+		path:        "/ok",
+		secret:      "fedcba9876543210",
+		status:      http.StatusUnauthorized,
+		hasErr:      true,
+		delay:       BaseBackoff,
+		msg:         "invalid - receiver rejects signature",
+	},
+	{
+		description: "No receiver",
+		secret:      "0123456789abcdef",
+		hasErr:      true,
+		delay:       BaseBackoff,
+		msg:         "invalid - error of network, no status",
+	},
+}
+
+func TestWorkerDeliverPending(t *testing.T) {
+	asserts := assert.New(t)
+	requires := require.New(t)
+
+	rc := &receiver{payloads: map[string]servises.WebhookPayload{}}
+	srv := httptest.NewServer(rc)
+	defer srv.Close()
+	// closed server - error of network
+	gone := httptest.NewServer(rc)
+	gone.Close()
+
+	now := time.Date(2025, 3, 1, 12, 0, 0, 0, time.UTC)
+	task := model.Task{ID: 7, Description: "seven", CreatedAt: now}
+	db := &outboxMock{results: map[uint64]model.DeliveryResult{}}
+	for i, test := range workerTestData {
+		url := srv.URL + test.path
+		if test.path == "" {
+			url = gone.URL
+		}
+		db.pending = append(db.pending, model.WebhookDelivery{
+			ID:       uint64(i + 1),
+			Event:    model.TaskEvent{ID: 42, Type: model.EventUpdated, TaskID: 7, Task: &task, At: now},
+			Status:   model.DeliveryPending,
+			Attempts: test.attempts,
+			URL:      url,
+			Secret:   test.secret,
+		})
+	}
+
+	w := NewWorker(db)
+	// receivers of test are on loopback
+	w.client = newClient(nil)
+	w.now = func() time.Time { return now }
+	n, err := w.DeliverPending(context.Background())
+	requires.NoError(err)
+	requires.Equal(len(workerTestData), n)
+
+	for i, test := range workerTestData {
+		log.Printf("\t %d test worker: %s\n", i+1, test.description)
+		result, ok := db.results[uint64(i+1)]
+		requires.True(ok, test.msg)
+		asserts.Equal(test.status, result.Status, test.msg)
+		asserts.Equal(test.hasErr, result.Err != nil, test.msg)
+		asserts.Equal(test.dead, result.Dead, test.msg)
+		if !test.dead {
+			asserts.Equal(now.Add(test.delay), result.NextAttemptAt, test.msg)
+		}
+	}
+	payload, ok := rc.payloads["1"]
+	requires.True(ok, "payload of delivered event")
+	asserts.Equal(uint64(42), payload.EventID)
+	asserts.Equal(model.EventUpdated, payload.Type)
+	asserts.Equal(uint(7), payload.TaskID)
+	requires.NotNil(payload.Task)
+	asserts.Equal("seven", payload.Task.Description)
+}
+
+func TestWorkerPublicOnly(t *testing.T) {
+	asserts := assert.New(t)
+	requires := require.New(t)
+
+	for i, test := range []struct {
+		address string
+		allowed bool
+	}{
+		{"93.184.216.34:443", true},
+		{"[2606:2800:220:1::1]:443", true},
+		{"127.0.0.1:80", false},
+		{"[::1]:80", false},
+		{"10.1.2.3:80", false},
+		{"192.168.0.1:80", false},
+		{"169.254.169.254:80", false},
+		{"100.64.0.1:80", false},
+		{"0.0.0.0:80", false},
+		{"[::ffff:127.0.0.1]:80", false},
+	} {
+		log.Printf("\t %d test public only: %s\n", i+1, test.address)
+		err := publicOnly("tcp", test.address, nil)
+		if test.allowed {
+			asserts.NoError(err, test.address)
+		} else {
+			asserts.ErrorIs(err, ErrWebhookAddress, test.address)
+		}
+	}
+
+	rc := &receiver{payloads: map[string]servises.WebhookPayload{}}
+	srv := httptest.NewServer(rc)
+	defer srv.Close()
+	db := &outboxMock{results: map[uint64]model.DeliveryResult{}}
+	db.pending = []model.WebhookDelivery{{ID: 1, URL: srv.URL + "/ok", Secret: "0123456789abcdef"}}
+	n, err := NewWorker(db).DeliverPending(context.Background())
+	requires.NoError(err)
+	requires.Equal(1, n)
+	asserts.ErrorIs(db.results[1].Err, ErrWebhookAddress, "invalid - webhook on loopback is not called")
+	asserts.Empty(rc.payloads)
+}
+
+func TestBackoff(t *testing.T) {
+	asserts := assert.New(t)
+
+	asserts.Equal(BaseBackoff, Backoff(1))
+	asserts.Equal(2*BaseBackoff, Backoff(2))
+	asserts.Equal(MaxBackoff, Backoff(20))
+	asserts.Equal(MaxBackoff, Backoff(1000))
+}
+
+func TestWorkerJobs(t *testing.T) {
+	asserts := assert.New(t)
+	requires := require.New(t)
+
+	log.Print("\t 1 test worker jobs: claims while they are full\n")
+	rc := &receiver{payloads: map[string]servises.WebhookPayload{}}
+	srv := httptest.NewServer(rc)
+	defer srv.Close()
+	db := &outboxMock{results: map[uint64]model.DeliveryResult{}}
+	for i := 0; i < claimLimit+3; i++ {
+		db.pending = append(db.pending, model.WebhookDelivery{ID: uint64(i + 1), URL: srv.URL + "/ok",
+			Secret: "0123456789abcdef", Event: model.TaskEvent{Type: model.EventDeleted}})
+	}
+	w := NewWorker(db)
+	w.client = newClient(nil)
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+	requires.NoError(w.Deliver(ctx, model.Job{}))
+	asserts.Len(db.results, claimLimit+3)
+	asserts.Empty(db.pending)
+
+	log.Print("\t 2 test worker jobs: end of ctx cancels delivery in progress\n")
+	hang := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// after body the server sees close of connection
+		_, _ = io.Copy(io.Discard, r.Body)
+		<-r.Context().Done()
+	}))
+	defer hang.Close()
+	db.pending = []model.WebhookDelivery{{ID: 100, URL: hang.URL}}
+	short, stop := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer stop()
+	start := time.Now()
+	requires.NoError(w.Deliver(short, model.Job{}))
+	asserts.Less(time.Since(start), RequestTimeout)
+
+	log.Print("\t 3 test worker jobs: finished deliveries after retention\n")
+	requires.NoError(w.Purge(context.Background(), model.Job{}))
+	asserts.Equal(model.DeliveryPurge{Succeeded: Retention, Dead: DeadRetention}, db.purge)
+}
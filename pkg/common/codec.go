@@ -0,0 +1,540 @@
+// codec - registry of encoders and decoders of body by 'Accept' and 'Content-Type'
+package common
+
+import (
+	"bytes"
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"mime"
+	"net/http"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"github.com/fxamacker/cbor/v2"
+	"github.com/vmihailenco/msgpack/v5"
+	"gopkg.in/yaml.v3"
+)
+
+var ErrCommonNotAcceptable = errors.New("not acceptable media type")
+
+// media types of registry
+const (
+	MediaJSON    = "application/json"
+	MediaYAML    = "application/yaml"
+	MediaXML     = "application/xml"
+	MediaMsgPack = "application/msgpack"
+	MediaCBOR    = "application/cbor"
+)
+
+// Codec - encoder and decoder of one format
+//
+// all formats use 'json' tags of objects: object is converted to tree of JSON (maps, slices, values)
+// and tree is written in format, decode - tree from format then 'json.Unmarshal' into object
+type Codec struct {
+	Media   string
+	Aliases []string
+	// ProblemMedia - media type of 'Problem' in this format
+	ProblemMedia string
+	encode       func(w io.Writer, tree any) error
+	decode       func(r io.Reader) (any, error)
+}
+
+// codecs - order is preference for 'Accept: */*'
+var codecs = []Codec{
+	{
+		Media:        MediaJSON,
+		ProblemMedia: MediaProblemJSON,
+	},
+	{
+		Media:        MediaYAML,
+		Aliases:      []string{"application/x-yaml", "text/yaml"},
+		ProblemMedia: "application/problem+yaml",
+		encode: func(w io.Writer, tree any) error {
+			enc := yaml.NewEncoder(w)
+			if err := enc.Encode(tree); err != nil {
+				return err
+			}
+			return enc.Close()
+		},
+		decode: func(r io.Reader) (any, error) {
+			var tree any
+			err := yaml.NewDecoder(r).Decode(&tree)
+			return tree, err
+		},
+	},
+	{
+		Media:        MediaXML,
+		Aliases:      []string{"text/xml"},
+		ProblemMedia: "application/problem+xml",
+		encode:       encodeXML,
+		decode:       decodeXML,
+	},
+	{
+		Media:        MediaMsgPack,
+		Aliases:      []string{"application/x-msgpack", "application/vnd.msgpack"},
+		ProblemMedia: MediaMsgPack,
+		encode: func(w io.Writer, tree any) error {
+			return msgpack.NewEncoder(w).Encode(tree)
+		},
+		decode: func(r io.Reader) (any, error) {
+			var tree any
+			dec := msgpack.NewDecoder(r)
+			dec.SetMapDecoder(func(d *msgpack.Decoder) (any, error) {
+				return d.DecodeUntypedMap()
+			})
+			err := dec.Decode(&tree)
+			return tree, err
+		},
+	},
+	{
+		Media:        MediaCBOR,
+		ProblemMedia: MediaCBOR,
+		encode: func(w io.Writer, tree any) error {
+			return cbor.NewEncoder(w).Encode(tree)
+		},
+		decode: func(r io.Reader) (any, error) {
+			var tree any
+			err := cborDecMode.NewDecoder(r).Decode(&tree)
+			return tree, err
+		},
+	},
+}
+
+var cborDecMode, _ = cbor.DecOptions{DefaultMapType: reflect.TypeOf(map[string]any{})}.DecMode()
+
+func (cd Codec) matches(media string) bool {
+	if media == cd.Media {
+		return true
+	}
+	for _, alias := range cd.Aliases {
+		if media == alias {
+			return true
+		}
+	}
+	return false
+}
+
+// Encode - write 'obj' in format of codec
+func (cd Codec) Encode(w io.Writer, obj any) error {
+	if cd.encode == nil {
+		return json.NewEncoder(w).Encode(obj)
+	}
+	tree, err := toTree(obj)
+	if err != nil {
+		return err
+	}
+	if _, ok := obj.(Problem); ok && cd.Media == MediaXML {
+		return encodeXMLRoot(w, "problem", tree)
+	}
+	return cd.encode(w, tree)
+}
+
+// Decode - read 'obj' in format of codec, unknown fields are not allowed
+func (cd Codec) Decode(r io.Reader, obj any) error {
+	if cd.decode == nil {
+		dec := json.NewDecoder(r)
+		dec.DisallowUnknownFields()
+		return dec.Decode(obj)
+	}
+	tree, err := cd.decode(r)
+	if err != nil {
+		return err
+	}
+	if cd.Media == MediaXML {
+		tree = xmlTypes(tree, reflect.TypeOf(obj))
+	}
+	line, err := json.Marshal(tree)
+	if err != nil {
+		return err
+	}
+	dec := json.NewDecoder(bytes.NewReader(line))
+	dec.DisallowUnknownFields()
+	return dec.Decode(obj)
+}
+
+// CodecByContentType - codec of body of Request, ErrCommonInvalidMedia (415) if format is unknown
+func CodecByContentType(contentType string) (Codec, error) {
+	media, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return Codec{}, ErrCommonInvalidMedia
+	}
+	for _, cd := range codecs {
+		if cd.matches(media) {
+			return cd, nil
+		}
+	}
+	return Codec{}, ErrCommonInvalidMedia
+}
+
+// NegotiateCodec - codec of Response by header 'Accept' (RFC 9110 quality values)
+//
+// empty 'Accept' is 'application/json',
+// if nothing matches - codec of 'application/json' for body of error and ErrCommonNotAcceptable (406)
+func NegotiateCodec(accept string) (Codec, error) {
+	if strings.TrimSpace(accept) == "" {
+		return codecs[0], nil
+	}
+	type acceptRange struct {
+		media string
+		q     float64
+	}
+	var ranges []acceptRange
+	for _, part := range strings.Split(accept, ",") {
+		media, params, err := mime.ParseMediaType(strings.TrimSpace(part))
+		if err != nil {
+			continue
+		}
+		q := 1.0
+		if v, ok := params["q"]; ok {
+			if q, err = strconv.ParseFloat(v, 64); err != nil {
+				continue
+			}
+		}
+		ranges = append(ranges, acceptRange{media: media, q: q})
+	}
+	sort.SliceStable(ranges, func(i, j int) bool { return ranges[i].q > ranges[j].q })
+	for _, ar := range ranges {
+		if ar.q <= 0 {
+			break
+		}
+		for _, cd := range codecs {
+			if ar.media == "*/*" || cd.matches(ar.media) ||
+				(strings.HasSuffix(ar.media, "/*") && strings.HasPrefix(cd.Media, strings.TrimSuffix(ar.media, "*"))) ||
+				ar.media == cd.ProblemMedia {
+				return cd, nil
+			}
+		}
+	}
+	return codecs[0], ErrCommonNotAcceptable
+}
+
+// Decode - get object from 'Request' in format of 'Content-Type'
+// (look ~> ../../internal/servises/validator.go)
+func Decode(r *http.Request, obj any) error {
+	cd, err := CodecByContentType(r.Header.Get("Content-Type"))
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err := r.Body.Close(); err != nil {
+			log.Printf("common: r.Body.Close error - %v", err)
+		}
+	}()
+	return cd.Decode(r.Body, obj)
+}
+
+// Encode - write the status and the object in format 'cd' to 'ResponseWriter'
+func Encode(w http.ResponseWriter, cd Codec, status int, obj any) {
+	media := cd.Media
+	if _, ok := obj.(Problem); ok {
+		media = cd.ProblemMedia
+	}
+	w.Header().Set("Content-Type", media)
+	w.Header().Add("Vary", "Accept")
+	w.WriteHeader(status)
+	if err := cd.Encode(w, obj); err != nil {
+		log.Printf("%s encode error - %v", cd.Media, err)
+	}
+}
+
+// toTree - object as tree of JSON, integer numbers are int64
+func toTree(obj any) (any, error) {
+	line, err := json.Marshal(obj)
+	if err != nil {
+		return nil, err
+	}
+	dec := json.NewDecoder(bytes.NewReader(line))
+	dec.UseNumber()
+	var tree any
+	if err := dec.Decode(&tree); err != nil {
+		return nil, err
+	}
+	return numbers(tree), nil
+}
+
+func numbers(tree any) any {
+	switch v := tree.(type) {
+	case map[string]any:
+		for k, item := range v {
+			v[k] = numbers(item)
+		}
+	case []any:
+		for i, item := range v {
+			v[i] = numbers(item)
+		}
+	case json.Number:
+		if n, err := v.Int64(); err == nil {
+			return n
+		}
+		f, _ := v.Float64()
+		return f
+	}
+	return tree
+}
+
+// encodeXML - tree in element <response>, members of object are elements (sorted by name),
+// items of array are elements <item>, null is skipped
+//
+// member with name which is not XML name ("operations[1].id") is element <entry name="operations[1].id">
+func encodeXML(w io.Writer, tree any) error {
+	return encodeXMLRoot(w, "response", tree)
+}
+
+func encodeXMLRoot(w io.Writer, root string, tree any) error {
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+	enc := xml.NewEncoder(w)
+	start := xml.StartElement{Name: xml.Name{Local: root}}
+	if root == "problem" {
+		start.Attr = []xml.Attr{{Name: xml.Name{Local: "xmlns"}, Value: "urn:ietf:rfc:7807"}}
+	}
+	if err := encodeXMLElement(enc, start, tree); err != nil {
+		return err
+	}
+	return enc.Flush()
+}
+
+func encodeXMLElement(enc *xml.Encoder, start xml.StartElement, tree any) error {
+	if tree == nil {
+		return nil
+	}
+	if err := enc.EncodeToken(start); err != nil {
+		return err
+	}
+	switch v := tree.(type) {
+	case map[string]any:
+		keys := make([]string, 0, len(v))
+		for k := range v {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			child := xml.StartElement{Name: xml.Name{Local: k}}
+			if !isXMLName(k) {
+				child = xml.StartElement{Name: xml.Name{Local: xmlEntry}, Attr: []xml.Attr{{Name: xml.Name{Local: "name"}, Value: k}}}
+			}
+			if err := encodeXMLElement(enc, child, v[k]); err != nil {
+				return err
+			}
+		}
+	case []any:
+		for _, item := range v {
+			if err := encodeXMLElement(enc, xml.StartElement{Name: xml.Name{Local: "item"}}, item); err != nil {
+				return err
+			}
+		}
+	default:
+		if err := enc.EncodeToken(xml.CharData(fmt.Sprint(v))); err != nil {
+			return err
+		}
+	}
+	return enc.EncodeToken(start.End())
+}
+
+// xmlEntry - element of member of object with name which is not XML name
+const xmlEntry = "entry"
+
+// isXMLName - name of element without namespace: letter or '_', then letters, digits, '-', '_', '.',
+// prefix 'xml' is reserved
+func isXMLName(name string) bool {
+	if name == "" || strings.HasPrefix(strings.ToLower(name), "xml") {
+		return false
+	}
+	for i, r := range name {
+		switch {
+		case unicode.IsLetter(r), r == '_':
+		case i > 0 && (unicode.IsDigit(r) || r == '-' || r == '.'):
+		default:
+			return false
+		}
+	}
+	return true
+}
+
+// decodeXML - tree from XML, root element is skipped
+//
+// element with text - string, element with children - object,
+// repeated children or children <item> - array, <entry name="..."> - member "...",
+// values are strings, 'xmlTypes' converts them by type of object
+func decodeXML(r io.Reader) (any, error) {
+	dec := xml.NewDecoder(r)
+	for {
+		tok, err := dec.Token()
+		if err != nil {
+			return nil, err
+		}
+		if start, ok := tok.(xml.StartElement); ok {
+			return decodeXMLElement(dec, start)
+		}
+	}
+}
+
+func decodeXMLElement(dec *xml.Decoder, start xml.StartElement) (any, error) {
+	obj := map[string]any{}
+	// lists - names of children which are repeated, their value in 'obj' is array
+	lists := map[string]bool{}
+	text := strings.Builder{}
+	for {
+		tok, err := dec.Token()
+		if err != nil {
+			return nil, err
+		}
+		switch t := tok.(type) {
+		case xml.StartElement:
+			child, err := decodeXMLElement(dec, t)
+			if err != nil {
+				return nil, err
+			}
+			name := t.Name.Local
+			if name == xmlEntry {
+				for _, attr := range t.Attr {
+					if attr.Name.Local == "name" {
+						name = attr.Value
+					}
+				}
+			}
+			prev, ok := obj[name]
+			switch {
+			case lists[name]:
+				obj[name] = append(prev.([]any), child)
+			case ok:
+				obj[name] = []any{prev, child}
+				lists[name] = true
+			case name == "item":
+				obj[name] = []any{child}
+				lists[name] = true
+			default:
+				obj[name] = child
+			}
+		case xml.CharData:
+			text.Write(t)
+		case xml.EndElement:
+			if len(obj) == 0 {
+				return strings.TrimSpace(text.String()), nil
+			}
+			if items, ok := obj["item"]; ok && len(obj) == 1 {
+				return items, nil
+			}
+			return obj, nil
+		}
+	}
+}
+
+var jsonUnmarshaler = reflect.TypeOf((*json.Unmarshaler)(nil)).Elem()
+
+// xmlTypes - strings of XML tree by type 't' of object: numbers, booleans,
+// array of one element or empty element, empty object; type with 'UnmarshalJSON' and 'any' get strings
+func xmlTypes(tree any, t reflect.Type) any {
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	if reflect.PointerTo(t).Implements(jsonUnmarshaler) {
+		return tree
+	}
+	text, isText := tree.(string)
+	switch t.Kind() {
+	case reflect.Bool:
+		if b, err := strconv.ParseBool(text); isText && err == nil {
+			return b
+		}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64,
+		reflect.Float32, reflect.Float64:
+		// not a number - error of 'json.Unmarshal' with name of field
+		if _, err := strconv.ParseFloat(text, 64); isText && err == nil {
+			return json.Number(text)
+		}
+	case reflect.Slice, reflect.Array:
+		if t.Kind() == reflect.Slice && t.Elem().Kind() == reflect.Uint8 {
+			// []byte - base64 string
+			return tree
+		}
+		items, ok := tree.([]any)
+		switch {
+		case ok:
+		case isText && text == "":
+			items = []any{}
+		default:
+			items = []any{tree}
+		}
+		for i, item := range items {
+			items[i] = xmlTypes(item, t.Elem())
+		}
+		return items
+	case reflect.Map:
+		if isText && text == "" {
+			return map[string]any{}
+		}
+		if obj, ok := tree.(map[string]any); ok {
+			for k, v := range obj {
+				obj[k] = xmlTypes(v, t.Elem())
+			}
+		}
+	case reflect.Struct:
+		if isText && text == "" {
+			return map[string]any{}
+		}
+		if obj, ok := tree.(map[string]any); ok {
+			for k, v := range obj {
+				if f, ok := jsonField(t, k); ok {
+					obj[k] = xmlTypes(v, f.Type)
+				}
+			}
+		}
+	}
+	return tree
+}
+
+// jsonField - field of struct by name of 'json' tag (as 'json.Unmarshal': exact, then without case),
+// then fields of embedded structs
+func jsonField(t reflect.Type, name string) (reflect.StructField, bool) {
+	var fold reflect.StructField
+	found := false
+	var embedded []reflect.Type
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		tag, _, _ := strings.Cut(f.Tag.Get("json"), ",")
+		if tag == "-" {
+			continue
+		}
+		if f.Anonymous && tag == "" {
+			ft := f.Type
+			if ft.Kind() == reflect.Pointer {
+				ft = ft.Elem()
+			}
+			if ft.Kind() == reflect.Struct {
+				embedded = append(embedded, ft)
+				continue
+			}
+		}
+		if !f.IsExported() {
+			continue
+		}
+		if tag == "" {
+			tag = f.Name
+		}
+		if tag == name {
+			return f, true
+		}
+		if !found && strings.EqualFold(tag, name) {
+			fold, found = f, true
+		}
+	}
+	if found {
+		return fold, true
+	}
+	for _, ft := range embedded {
+		if f, ok := jsonField(ft, name); ok {
+			return f, true
+		}
+	}
+	return fold, false
+}
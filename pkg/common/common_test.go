@@ -2,6 +2,7 @@ package common
 
 import (
 	"bytes"
+	"encoding/json"
 	"errors"
 	"fmt"
 	"log"
@@ -9,6 +10,7 @@ import (
 	"net/http/httptest"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/go-chi/chi/v5"
 	"github.com/stretchr/testify/assert"
@@ -127,3 +129,213 @@ func TestNewMessageError(t *testing.T) {
 	asserts.IsType(MessageError{}, msgError, "should be type - MessageError")
 	asserts.Equal(Message{"param": "invalid media type"}, msgError.Msg, `shoud be - map[string]any{"param": "invalid media type"}`)
 }
+
+func TestEncodeProblem(t *testing.T) {
+	asserts := assert.New(t)
+
+	problem := NewProblem("/problems/not-found", http.StatusNotFound, "not found", "/task/7")
+	problem.InvalidParams = []InvalidParam{{Name: "id", Reason: "unknown"}}
+	w := httptest.NewRecorder()
+	EncodeProblem(w, problem)
+
+	asserts.Equal(http.StatusNotFound, w.Code)
+	asserts.Equal(MediaProblemJSON, w.Header().Get("Content-Type"))
+	asserts.JSONEq(`{
+"type":"/problems/not-found",
+"title":"Not Found",
+"status":404,
+"detail":"not found",
+"instance":"/task/7",
+"invalid_params":[{"name":"id","reason":"unknown"}]}`, w.Body.String())
+}
+
+func TestMergePatch(t *testing.T) {
+	var mergeTestData = []struct {
+		doc      string
+		patch    string
+		expected string
+	}{
+		{`{"a":"b"}`, `{"a":"c"}`, `{"a":"c"}`},
+		{`{"a":"b"}`, `{"b":"c"}`, `{"a":"b","b":"c"}`},
+		{`{"a":"b"}`, `{"a":null}`, `{}`},
+		{`{"a":{"b":"c"}}`, `{"a":{"b":"d","c":null}}`, `{"a":{"b":"d"}}`},
+		{`{"a":["b"]}`, `{"a":"c"}`, `{"a":"c"}`},
+		{`{"a":"foo"}`, `"bar"`, `"bar"`},
+		{`{"e":null}`, `{"a":1}`, `{"e":null,"a":1}`},
+	}
+
+	for _, test := range mergeTestData {
+		var doc, patch any
+		require.NoError(t, json.Unmarshal([]byte(test.doc), &doc))
+		require.NoError(t, json.Unmarshal([]byte(test.patch), &patch))
+		result, err := json.Marshal(MergePatch(doc, patch))
+		require.NoError(t, err)
+		assert.JSONEq(t, test.expected, string(result), test.patch)
+	}
+}
+
+func TestJSONPatch(t *testing.T) {
+	var patchTestData = []struct {
+		doc      string
+		patch    string
+		expected string
+		err      error
+	}{
+		{`{"foo":"bar"}`, `[{"op":"add","path":"/baz","value":"qux"}]`, `{"foo":"bar","baz":"qux"}`, nil},
+		{`{"foo":["bar","baz"]}`, `[{"op":"add","path":"/foo/1","value":"qux"}]`, `{"foo":["bar","qux","baz"]}`, nil},
+		{`{"foo":["bar"]}`, `[{"op":"add","path":"/foo/-","value":"baz"}]`, `{"foo":["bar","baz"]}`, nil},
+		{`{"baz":"qux","foo":"bar"}`, `[{"op":"remove","path":"/baz"}]`, `{"foo":"bar"}`, nil},
+		{`{"baz":"qux"}`, `[{"op":"replace","path":"/baz","value":"boo"}]`, `{"baz":"boo"}`, nil},
+		{`{"foo":{"bar":"baz"}}`, `[{"op":"move","from":"/foo/bar","path":"/qux"}]`, `{"foo":{},"qux":"baz"}`, nil},
+		{`{"foo":"bar"}`, `[{"op":"copy","from":"/foo","path":"/a~1b"}]`, `{"foo":"bar","a/b":"bar"}`, nil},
+		{`{"baz":"qux"}`, `[{"op":"test","path":"/baz","value":"qux"}]`, `{"baz":"qux"}`, nil},
+		{`{"baz":"qux"}`, `[{"op":"test","path":"/baz","value":"bar"}]`, ``, ErrCommonPatchTest},
+		{`{"baz":"qux"}`, `[{"op":"replace","path":"/foo","value":"bar"}]`, ``, ErrCommonPatchPath},
+		{`{"baz":"qux"}`, `[{"op":"add","path":"/foo"}]`, ``, ErrCommonPatchOperation},
+		{`{"baz":"qux"}`, `[{"op":"jump","path":"/baz"}]`, ``, ErrCommonPatchOperation},
+	}
+
+	for _, test := range patchTestData {
+		var doc any
+		var ops []PatchOperation
+		require.NoError(t, json.Unmarshal([]byte(test.doc), &doc))
+		require.NoError(t, json.Unmarshal([]byte(test.patch), &ops))
+		result, err := JSONPatch(doc, ops)
+		if test.err != nil {
+			assert.ErrorIs(t, err, test.err, test.patch)
+			continue
+		}
+		require.NoError(t, err, test.patch)
+		line, err := json.Marshal(result)
+		require.NoError(t, err)
+		assert.JSONEq(t, test.expected, string(line), test.patch)
+	}
+}
+
+func TestCodecRoundTrip(t *testing.T) {
+	type payload struct {
+		Data struct {
+			Description string `json:"description"`
+			Note        string `json:"note,omitempty"`
+		} `json:"task_update"`
+	}
+
+	for _, media := range []string{MediaJSON, MediaYAML, MediaXML, MediaMsgPack, MediaCBOR} {
+		cd, err := CodecByContentType(media + "; charset=utf-8")
+		require.NoError(t, err, media)
+
+		in := payload{}
+		in.Data.Description = "Hello <world> & co"
+		in.Data.Note = "note"
+		buf := bytes.Buffer{}
+		require.NoError(t, cd.Encode(&buf, in), media)
+
+		out := payload{}
+		require.NoError(t, cd.Decode(&buf, &out), media)
+		assert.Equal(t, in, out, media)
+	}
+}
+
+func TestCodecDecodeUnknownField(t *testing.T) {
+	type payload struct {
+		Body string `json:"body"`
+	}
+	var unknownTestData = []struct {
+		media string
+		body  string
+	}{
+		{MediaJSON, `{"body":"x","alien":"UFO"}`},
+		{MediaYAML, "body: x\nalien: UFO\n"},
+		{MediaXML, `<request><body>x</body><alien>UFO</alien></request>`},
+	}
+
+	for _, test := range unknownTestData {
+		cd, err := CodecByContentType(test.media)
+		require.NoError(t, err, test.media)
+		err = cd.Decode(strings.NewReader(test.body), &payload{})
+		assert.ErrorContains(t, err, `unknown field "alien"`, test.media)
+	}
+}
+
+func TestCodecXMLTypes(t *testing.T) {
+	type item struct {
+		ID uint `json:"id"`
+	}
+	type payload struct {
+		item
+		Done   bool           `json:"done"`
+		Rate   float64        `json:"rate"`
+		Tags   []string       `json:"tags"`
+		Items  []item         `json:"items"`
+		Extra  map[string]int `json:"extra"`
+		At     *time.Time     `json:"at"`
+		Custom map[string]any `json:"custom"`
+	}
+	cd, err := CodecByContentType(MediaXML)
+	require.NoError(t, err)
+
+	out := payload{}
+	body := `<request><id>7</id><done>true</done><rate>1.5</rate><tags>one</tags><items><id>2</id></items>` +
+		`<extra><a>2</a></extra><at>2024-01-02T00:00:00Z</at><custom><n>5</n></custom></request>`
+	require.NoError(t, cd.Decode(strings.NewReader(body), &out))
+	assert.Equal(t, uint(7), out.ID, "valid - field of embedded struct")
+	assert.True(t, out.Done)
+	assert.Equal(t, 1.5, out.Rate)
+	assert.Equal(t, []string{"one"}, out.Tags, "valid - one element is array")
+	assert.Equal(t, []item{{ID: 2}}, out.Items)
+	assert.Equal(t, map[string]int{"a": 2}, out.Extra)
+	assert.Equal(t, time.Date(2024, 1, 2, 0, 0, 0, 0, time.UTC), *out.At)
+	assert.Equal(t, map[string]any{"n": "5"}, out.Custom, "valid - type is not known, value is string")
+
+	err = cd.Decode(strings.NewReader(`<request><id>x</id></request>`), &payload{})
+	assert.ErrorContains(t, err, "id", "invalid - not a number")
+
+	in := map[string]any{"operations[1].id": "required", "1st": "a", "xmlns": "b", "ok": map[string]any{"a b": "c"}}
+	buf := bytes.Buffer{}
+	require.NoError(t, cd.Encode(&buf, in))
+	assert.Contains(t, buf.String(), `<entry name="operations[1].id">required</entry>`)
+	assert.Contains(t, buf.String(), `<ok><entry name="a b">c</entry></ok>`)
+	back := map[string]any{}
+	require.NoError(t, cd.Decode(&buf, &back))
+	assert.Equal(t, in, back)
+}
+
+func TestNegotiateCodec(t *testing.T) {
+	var negotiateTestData = []struct {
+		accept   string
+		expected string
+		err      error
+	}{
+		{"", MediaJSON, nil},
+		{"*/*", MediaJSON, nil},
+		{"application/xml", MediaXML, nil},
+		{"text/yaml", MediaYAML, nil},
+		{"application/json;q=0.5, application/cbor", MediaCBOR, nil},
+		{"application/msgpack;q=0, application/*;q=0.1", MediaJSON, nil},
+		{"application/problem+json", MediaJSON, nil},
+		{"text/html", MediaJSON, ErrCommonNotAcceptable},
+	}
+
+	for _, test := range negotiateTestData {
+		cd, err := NegotiateCodec(test.accept)
+		assert.Equal(t, test.expected, cd.Media, test.accept)
+		assert.Equal(t, test.err, err, test.accept)
+	}
+}
+
+func TestCodecByContentType(t *testing.T) {
+	_, err := CodecByContentType("text/plain")
+	assert.ErrorIs(t, err, ErrCommonInvalidMedia)
+	_, err = CodecByContentType("")
+	assert.ErrorIs(t, err, ErrCommonInvalidMedia)
+}
+
+func TestEncodeProblemXML(t *testing.T) {
+	cd, err := NegotiateCodec("application/problem+xml")
+	require.NoError(t, err)
+	w := httptest.NewRecorder()
+	Encode(w, cd, http.StatusNotFound, NewProblem("/problems/not-found", http.StatusNotFound, "not found", "/task/7"))
+
+	assert.Equal(t, "application/problem+xml", w.Header().Get("Content-Type"))
+	assert.Contains(t, w.Body.String(), `<problem xmlns="urn:ietf:rfc:7807"><detail>not found</detail><instance>/task/7</instance><status>404</status>`)
+}
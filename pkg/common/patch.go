@@ -0,0 +1,287 @@
+// patch - JSON Merge Patch (RFC 7396) and JSON Patch (RFC 6902) over documents of 'json.Unmarshal'
+package common
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+const (
+	MediaMergePatch = "application/merge-patch+json"
+	MediaJSONPatch  = "application/json-patch+json"
+)
+
+var (
+	// ErrCommonPatchPath - JSON Pointer is broken or points to nothing
+	ErrCommonPatchPath = errors.New("invalid patch path")
+
+	// ErrCommonPatchOperation - unknown 'op' or operation without required member
+	ErrCommonPatchOperation = errors.New("invalid patch operation")
+
+	// ErrCommonPatchTest - operation 'test' failed
+	ErrCommonPatchTest = errors.New("patch test failed")
+)
+
+// MergePatch - apply 'patch' to 'doc' by RFC 7396, 'doc' may be changed
+//
+// null in 'patch' removes member, not object 'patch' replaces 'doc'
+func MergePatch(doc, patch any) any {
+	patchObj, ok := patch.(map[string]any)
+	if !ok {
+		return patch
+	}
+	docObj, ok := doc.(map[string]any)
+	if !ok {
+		docObj = map[string]any{}
+	}
+	for k, v := range patchObj {
+		if v == nil {
+			delete(docObj, k)
+			continue
+		}
+		docObj[k] = MergePatch(docObj[k], v)
+	}
+	return docObj
+}
+
+// PatchOperation - one operation of JSON Patch document
+//
+// 'Value' is nil if member "value" is absent, null is json.RawMessage("null")
+type PatchOperation struct {
+	Op    string          `json:"op"`
+	Path  string          `json:"path"`
+	From  string          `json:"from,omitempty"`
+	Value json.RawMessage `json:"value,omitempty"`
+}
+
+// JSONPatch - apply operations to 'doc' by RFC 6902, 'doc' may be changed
+//
+// operations are applied in order, on first error the result must be discarded
+func JSONPatch(doc any, ops []PatchOperation) (any, error) {
+	for i, op := range ops {
+		var err error
+		if doc, err = applyOperation(doc, op); err != nil {
+			return nil, fmt.Errorf("operation %d (%s %s): %w", i, op.Op, op.Path, err)
+		}
+	}
+	return doc, nil
+}
+
+func applyOperation(doc any, op PatchOperation) (any, error) {
+	path, err := parsePointer(op.Path)
+	if err != nil {
+		return nil, err
+	}
+	switch op.Op {
+	case "add", "replace", "test":
+		if op.Value == nil {
+			return nil, ErrCommonPatchOperation
+		}
+		var value any
+		if err := json.Unmarshal(op.Value, &value); err != nil {
+			return nil, ErrCommonPatchOperation
+		}
+		switch op.Op {
+		case "add":
+			return setPointer(doc, path, value, true)
+		case "replace":
+			return setPointer(doc, path, value, false)
+		}
+		current, err := getPointer(doc, path)
+		if err != nil {
+			return nil, err
+		}
+		if !reflect.DeepEqual(current, value) {
+			return nil, ErrCommonPatchTest
+		}
+		return doc, nil
+	case "remove":
+		doc, _, err = removePointer(doc, path)
+		return doc, err
+	case "move", "copy":
+		from, err := parsePointer(op.From)
+		if err != nil {
+			return nil, err
+		}
+		var value any
+		if op.Op == "move" {
+			if doc, value, err = removePointer(doc, from); err != nil {
+				return nil, err
+			}
+		} else if value, err = getPointer(doc, from); err != nil {
+			return nil, err
+		} else {
+			value = deepCopy(value)
+		}
+		return setPointer(doc, path, value, true)
+	}
+	return nil, ErrCommonPatchOperation
+}
+
+// parsePointer - tokens of JSON Pointer (RFC 6901), "" - whole document
+func parsePointer(pointer string) ([]string, error) {
+	if pointer == "" {
+		return nil, nil
+	}
+	if pointer[0] != '/' {
+		return nil, ErrCommonPatchPath
+	}
+	tokens := strings.Split(pointer[1:], "/")
+	for i, t := range tokens {
+		tokens[i] = strings.ReplaceAll(strings.ReplaceAll(t, "~1", "/"), "~0", "~")
+	}
+	return tokens, nil
+}
+
+// arrayIndex - index of element, "-" is index after last element
+func arrayIndex(token string, size int) (int, error) {
+	if token == "-" {
+		return size, nil
+	}
+	if token == "" || (len(token) > 1 && token[0] == '0') {
+		return 0, ErrCommonPatchPath
+	}
+	idx, err := strconv.Atoi(token)
+	if err != nil || idx < 0 || idx > size {
+		return 0, ErrCommonPatchPath
+	}
+	return idx, nil
+}
+
+func getPointer(doc any, path []string) (any, error) {
+	for _, token := range path {
+		switch node := doc.(type) {
+		case map[string]any:
+			value, ok := node[token]
+			if !ok {
+				return nil, ErrCommonPatchPath
+			}
+			doc = value
+		case []any:
+			idx, err := arrayIndex(token, len(node))
+			if err != nil || idx == len(node) {
+				return nil, ErrCommonPatchPath
+			}
+			doc = node[idx]
+		default:
+			return nil, ErrCommonPatchPath
+		}
+	}
+	return doc, nil
+}
+
+// setPointer - 'insert' true is "add" (insert into array), false is "replace" (target must exist)
+func setPointer(doc any, path []string, value any, insert bool) (any, error) {
+	if len(path) == 0 {
+		return value, nil
+	}
+	token, last := path[0], len(path) == 1
+	switch node := doc.(type) {
+	case map[string]any:
+		child, ok := node[token]
+		if last {
+			if !insert && !ok {
+				return nil, ErrCommonPatchPath
+			}
+			node[token] = value
+			return node, nil
+		}
+		if !ok {
+			return nil, ErrCommonPatchPath
+		}
+		child, err := setPointer(child, path[1:], value, insert)
+		if err != nil {
+			return nil, err
+		}
+		node[token] = child
+		return node, nil
+	case []any:
+		idx, err := arrayIndex(token, len(node))
+		if err != nil {
+			return nil, err
+		}
+		if last && insert {
+			node = append(node, nil)
+			copy(node[idx+1:], node[idx:])
+			node[idx] = value
+			return node, nil
+		}
+		if idx == len(node) {
+			return nil, ErrCommonPatchPath
+		}
+		if last {
+			node[idx] = value
+			return node, nil
+		}
+		child, err := setPointer(node[idx], path[1:], value, insert)
+		if err != nil {
+			return nil, err
+		}
+		node[idx] = child
+		return node, nil
+	}
+	return nil, ErrCommonPatchPath
+}
+
+// removePointer - returns changed document and removed value
+func removePointer(doc any, path []string) (any, any, error) {
+	if len(path) == 0 {
+		return nil, nil, ErrCommonPatchPath
+	}
+	token, last := path[0], len(path) == 1
+	switch node := doc.(type) {
+	case map[string]any:
+		child, ok := node[token]
+		if !ok {
+			return nil, nil, ErrCommonPatchPath
+		}
+		if last {
+			delete(node, token)
+			return node, child, nil
+		}
+		child, removed, err := removePointer(child, path[1:])
+		if err != nil {
+			return nil, nil, err
+		}
+		node[token] = child
+		return node, removed, nil
+	case []any:
+		idx, err := arrayIndex(token, len(node))
+		if err != nil || idx == len(node) {
+			return nil, nil, ErrCommonPatchPath
+		}
+		if last {
+			removed := node[idx]
+			return append(node[:idx], node[idx+1:]...), removed, nil
+		}
+		child, removed, err := removePointer(node[idx], path[1:])
+		if err != nil {
+			return nil, nil, err
+		}
+		node[idx] = child
+		return node, removed, nil
+	}
+	return nil, nil, ErrCommonPatchPath
+}
+
+func deepCopy(value any) any {
+	switch v := value.(type) {
+	case map[string]any:
+		cp := make(map[string]any, len(v))
+		for k, item := range v {
+			cp[k] = deepCopy(item)
+		}
+		return cp
+	case []any:
+		cp := make([]any, len(v))
+		for i, item := range v {
+			cp[i] = deepCopy(item)
+		}
+		return cp
+	}
+	return value
+}
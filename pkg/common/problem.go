@@ -0,0 +1,46 @@
+// problem - error body format of RFC 7807 'application/problem+json'
+package common
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+)
+
+const MediaProblemJSON = "application/problem+json"
+
+// InvalidParam - one rejected field of a request
+type InvalidParam struct {
+	Name   string `json:"name"`
+	Reason string `json:"reason"`
+}
+
+// Problem - RFC 7807 'Problem Details for HTTP APIs'
+type Problem struct {
+	Type          string         `json:"type"`
+	Title         string         `json:"title"`
+	Status        int            `json:"status"`
+	Detail        string         `json:"detail,omitempty"`
+	Instance      string         `json:"instance,omitempty"`
+	InvalidParams []InvalidParam `json:"invalid_params,omitempty"`
+}
+
+// NewProblem - 'Title' is taken from 'http.StatusText'
+func NewProblem(typ string, status int, detail, instance string) Problem {
+	return Problem{
+		Type:     typ,
+		Title:    http.StatusText(status),
+		Status:   status,
+		Detail:   detail,
+		Instance: instance,
+	}
+}
+
+// EncodeProblem - write 'Problem' with media type 'application/problem+json' to 'ResponseWriter'
+func EncodeProblem(w http.ResponseWriter, p Problem) {
+	w.Header().Set("Content-Type", MediaProblemJSON)
+	w.WriteHeader(p.Status)
+	if err := json.NewEncoder(w).Encode(p); err != nil {
+		log.Printf("json.Encode error - %v", err)
+	}
+}